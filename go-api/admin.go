@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/adaptive"
+	"github.com/example/ai-check/internal/adminui"
+	"github.com/example/ai-check/internal/config"
+	"github.com/example/ai-check/internal/cron"
+	"github.com/example/ai-check/internal/dbpool"
+	"github.com/example/ai-check/internal/disputes"
+	"github.com/example/ai-check/internal/handlers"
+	"github.com/example/ai-check/internal/health"
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/livemetrics"
+	"github.com/example/ai-check/internal/metering"
+	"github.com/example/ai-check/internal/metrics"
+	"github.com/example/ai-check/internal/middleware"
+	"github.com/example/ai-check/internal/tenants"
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/users"
+	"github.com/example/ai-check/internal/webhooks"
+	"github.com/example/ai-check/internal/worker"
+)
+
+// adminServices are the components whose admin APIs the operations listener serves.
+// Nil fields leave their routes out.
+type adminServices struct {
+	verifications *usecase.VerificationUseCase
+	readiness     *health.Checker
+	liveMetrics   *livemetrics.Feed
+	metrics       *metrics.Metrics
+	meter         *metering.Meter
+	users         *users.Service
+	disputes      *disputes.Service
+	tenants       *tenants.Store
+	// webhooks serves the endpoints of tenants next to their settings.
+	webhooks  *webhooks.Service
+	scheduler *cron.Scheduler
+	queue     *worker.Queue
+	// databasePool is nil in development mode.
+	databasePool *dbpool.Tuner
+	// adaptiveLimit is nil unless limits.adaptive is enabled.
+	adaptiveLimit *adaptive.Limiter
+}
+
+// newAdminRouter builds the router for the operations listener. Routes that should not
+// be reachable from the public listener (profiling, metrics, admin APIs) belong here.
+func newAdminRouter(cfg config.AdminConfig, services adminServices) *gin.Engine {
+	router := gin.New()
+	// The admin listener is reached directly, never through the public load balancer.
+	_ = router.SetTrustedProxies(nil)
+	router.Use(middleware.RequestID(), httperr.Recovery())
+	router.NoRoute(httperr.NotFound)
+
+	handlers.RegisterHealthRoutes(router)
+	handlers.RegisterVersionRoutes(router)
+	if services.readiness != nil {
+		handlers.RegisterReadinessRoutes(router, services.readiness)
+	}
+	if services.verifications != nil {
+		handlers.RegisterAdminRoutes(router, services.verifications)
+	}
+	if services.liveMetrics != nil {
+		handlers.RegisterMetricsStreamAdminRoutes(router, services.liveMetrics)
+	}
+	if services.metrics != nil {
+		router.GET("/metrics", gin.WrapH(services.metrics.Handler()))
+	}
+	if services.meter != nil {
+		handlers.RegisterUsageAdminRoutes(router, services.meter)
+	}
+	if services.users != nil {
+		handlers.RegisterUserAdminRoutes(router, services.users)
+	}
+	if services.disputes != nil {
+		handlers.RegisterDisputeAdminRoutes(router, services.disputes)
+	}
+	if services.tenants != nil {
+		handlers.RegisterTenantAdminRoutes(router, services.tenants, services.webhooks)
+	}
+	if services.queue != nil {
+		handlers.RegisterJobAdminRoutes(router, services.queue, services.scheduler)
+	}
+	if services.databasePool != nil {
+		handlers.RegisterDatabasePoolAdminRoutes(router, services.databasePool)
+	}
+	if services.adaptiveLimit != nil {
+		handlers.RegisterAdaptiveLimitAdminRoutes(router, services.adaptiveLimit)
+	}
+	if cfg.EnableUI {
+		adminui.Register(router, "/admin/ui")
+	}
+
+	if cfg.EnablePprof {
+		debug := router.Group("/debug/pprof")
+		debug.GET("/", gin.WrapF(pprof.Index))
+		debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+		debug.GET("/profile", gin.WrapF(pprof.Profile))
+		debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/trace", gin.WrapF(pprof.Trace))
+		debug.GET("/:profile", func(c *gin.Context) {
+			pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+		})
+	}
+
+	return router
+}
+
+// startAdminServer serves the admin router in the background. The returned server must
+// be shut down by the caller.
+func startAdminServer(addr string, handler http.Handler, logger *zap.Logger) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("admin server failed", zap.Error(err), zap.String("addr", addr))
+		}
+	}()
+	logger.Info("admin listener started", zap.String("addr", addr))
+	return server
+}
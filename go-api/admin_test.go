@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/config"
+	"github.com/example/ai-check/internal/handlers"
+	"github.com/example/ai-check/internal/metrics"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+func TestAdminRouterServesHealthAndProfiling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := newAdminRouter(config.AdminConfig{EnablePprof: true}, adminServices{})
+	for _, path := range []string{"/health", "/debug/pprof/", "/debug/pprof/goroutine"} {
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected %s to return 200, got %d", path, resp.Code)
+		}
+	}
+}
+
+func TestAdminRouterHidesProfilingWhenDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := newAdminRouter(config.AdminConfig{}, adminServices{})
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("expected profiling to be disabled, got %d", resp.Code)
+	}
+}
+
+func TestPrometheusMetricsAreOnlyServedOnTheAdminListener(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	promMetrics := metrics.New()
+	public := handlers.NewHandler(&usecase.VerificationUseCase{}, func(c *gin.Context) { c.Next() }, handlers.Options{Metrics: promMetrics})
+	resp := httptest.NewRecorder()
+	public.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("expected the public listener not to serve metrics, got %d", resp.Code)
+	}
+
+	router := newAdminRouter(config.AdminConfig{}, adminServices{metrics: promMetrics})
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "# TYPE") {
+		t.Fatalf("expected the admin listener to serve metrics, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestAdminRouterServesEmbeddedUI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := newAdminRouter(config.AdminConfig{EnableUI: true}, adminServices{})
+	cases := map[string]string{
+		"/admin/ui/":         "<title>ai-check console</title>",
+		"/admin/ui/app.js":   "loadMetrics",
+		"/admin/ui/settings": "<title>ai-check console</title>",
+	}
+	for path, fragment := range cases {
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected %s to return 200, got %d", path, resp.Code)
+		}
+		if !strings.Contains(resp.Body.String(), fragment) {
+			t.Fatalf("expected %s to contain %q", path, fragment)
+		}
+	}
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/admin/ui", nil))
+	if resp.Code != http.StatusMovedPermanently {
+		t.Fatalf("expected /admin/ui to redirect, got %d", resp.Code)
+	}
+}
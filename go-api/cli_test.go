@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRunCLIRejectsUnknownCommand(t *testing.T) {
+	if code := runCLI([]string{"frobnicate"}, zap.NewNop()); code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+}
+
+func TestRunCLIReportsUsageErrors(t *testing.T) {
+	if code := runCLI([]string{"purge"}, zap.NewNop()); code != 2 {
+		t.Fatalf("expected exit code 2 for missing -older-than, got %d", code)
+	}
+}
+
+func TestOpenAPICommandMatchesCommittedDocument(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "openapi.json")
+	if code := runCLI([]string{"openapi", "-o", output}, zap.NewNop()); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	generated, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, committed) {
+		t.Fatal("openapi.json is out of date with the routes; run go generate in go-api")
+	}
+}
+
+func TestHealthcheckCommand(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if code := runCLI([]string{"healthcheck", "-url", server.URL}, zap.NewNop()); code != 0 {
+		t.Fatalf("expected healthy probe to exit 0, got %d", code)
+	}
+
+	healthy = false
+	if code := runCLI([]string{"healthcheck", "-url", server.URL}, zap.NewNop()); code != 1 {
+		t.Fatalf("expected unhealthy probe to exit 1, got %d", code)
+	}
+}
+
+func TestLocalHealthURL(t *testing.T) {
+	cases := map[string]string{
+		":8080":         "http://127.0.0.1:8080/health",
+		"0.0.0.0:9000":  "http://127.0.0.1:9000/health",
+		"10.0.0.5:8443": "http://10.0.0.5:8443/health",
+	}
+	for addr, expected := range cases {
+		if got := localHealthURL(addr, false, "/health"); got != expected {
+			t.Fatalf("localHealthURL(%q) = %q, expected %q", addr, got, expected)
+		}
+	}
+	if got := localHealthURL(":8443", true, "/health"); got != "https://127.0.0.1:8443/health" {
+		t.Fatalf("expected https scheme, got %q", got)
+	}
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/config"
+	"github.com/example/ai-check/internal/dbpool"
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/eventbus"
+	"github.com/example/ai-check/internal/experiment"
+	"github.com/example/ai-check/internal/grpcclient"
+	"github.com/example/ai-check/internal/health"
+	"github.com/example/ai-check/internal/imageprocessor"
+	"github.com/example/ai-check/internal/imageprocessor/httpprocessor"
+	"github.com/example/ai-check/internal/malwarescan"
+	"github.com/example/ai-check/internal/metering"
+	"github.com/example/ai-check/internal/metrics"
+	"github.com/example/ai-check/internal/notify"
+	"github.com/example/ai-check/internal/ratelimit"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/sigv4"
+	"github.com/example/ai-check/internal/storage"
+	"github.com/example/ai-check/internal/tenants"
+	"github.com/example/ai-check/internal/tracing"
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/warehouse"
+	"github.com/example/ai-check/internal/webhooks"
+	"github.com/example/ai-check/internal/worker"
+)
+
+// dependencies are the external services the verification use case is built on.
+type dependencies struct {
+	db        *gorm.DB
+	redis     *redis.Client
+	processor imageprocessor.Client
+	// processorReady reports whether the processor can take calls; nil when its
+	// backend cannot tell.
+	processorReady func(ctx context.Context) error
+}
+
+// readiness builds the checks served at /health/ready, each bounded by its timeout
+// in cfg.
+func (d *dependencies) readiness(cfg config.HealthConfig) *health.Checker {
+	checker := health.NewChecker(cfg.Timeout)
+	checker.RegisterWithTimeout("database", cfg.DatabaseTimeout, func(ctx context.Context) error {
+		sqlDB, err := d.db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+	checker.RegisterWithTimeout("redis", cfg.RedisTimeout, func(ctx context.Context) error {
+		return d.redis.Ping(ctx).Err()
+	})
+	if d.processorReady != nil {
+		checker.RegisterWithTimeout("processor", cfg.ProcessorTimeout, d.processorReady)
+	}
+	return checker
+}
+
+// connectDependencies connects to Postgres, Redis and the image processor, registering
+// each with plan as soon as it is open. Credentials rotated in store apply to new
+// Postgres and Redis connections. With tracer, calls to the processor are traced.
+func connectDependencies(ctx context.Context, cfg *config.Config, store *secretStore, promMetrics *metrics.Metrics, tracer *tracing.Tracer, plan *shutdownPlan, logger *zap.Logger) (*dependencies, error) {
+	dbPassword, redisCredentials := store.connectCredentials()
+	db, err := initDatabase(ctx, cfg.Database, cfg.Startup, logger, dbPassword)
+	if err != nil {
+		return nil, err
+	}
+	plan.addDatabase(db)
+
+	redisClient, err := initRedis(ctx, cfg.Redis, cfg.Startup, logger, redisCredentials)
+	if err != nil {
+		return nil, err
+	}
+	plan.addCloser("redis", redisClient.Close)
+
+	backends := processorBackends(cfg, promMetrics, tracer, logger)
+	backend, err := backends.New(ctx, cfg.Processor.Backend, imageprocessor.Options{Addr: cfg.Processor.Addr, Timeout: cfg.Processor.Timeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to image processor: %w", err)
+	}
+	if backend.Close != nil {
+		plan.addCloser("processor", backend.Close)
+	}
+
+	return &dependencies{db: db, redis: redisClient, processor: backend.Client, processorReady: backend.Ready}, nil
+}
+
+// processorBackends returns the image processors processor.backend can select: the
+// Rust service over gRPC, a REST processor and the stub of development mode.
+func processorBackends(cfg *config.Config, promMetrics *metrics.Metrics, tracer *tracing.Tracer, logger *zap.Logger) *imageprocessor.Registry {
+	backends := imageprocessor.NewRegistry()
+	backends.Register("grpc", func(ctx context.Context, opts imageprocessor.Options) (*imageprocessor.Backend, error) {
+		var (
+			client imageprocessor.Client
+			conn   *grpc.ClientConn
+		)
+		err := waitForDependency(ctx, cfg.Startup, logger, "processor", func(ctx context.Context) error {
+			var dialErr error
+			client, conn, dialErr = grpcclient.DialImageProcessorWithOptions(ctx, opts.Addr, logger, processorDialOptions(cfg.Processor, grpcclient.DialOptions{Block: true}, promMetrics, tracer))
+			return dialErr
+		})
+		if err != nil {
+			return nil, err
+		}
+		if conn == nil {
+			// Degraded start: let gRPC keep connecting in the background.
+			client, conn, err = grpcclient.DialImageProcessorWithOptions(ctx, opts.Addr, logger, processorDialOptions(cfg.Processor, grpcclient.DialOptions{}, promMetrics, tracer))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &imageprocessor.Backend{Client: client, Ready: grpcclient.ReadinessCheck(conn), Close: conn.Close}, nil
+	})
+	backends.Register(httpprocessor.Name, httpprocessor.Factory(logger))
+	backends.Register("mock", func(ctx context.Context, opts imageprocessor.Options) (*imageprocessor.Backend, error) {
+		logger.Warn("using the stub image processor; scores are derived from the image bytes")
+		return &imageprocessor.Backend{Client: devmode.Processor{}}, nil
+	})
+	return backends
+}
+
+// processorDialOptions adds the health checks of cfg and the instruments of
+// promMetrics and tracer, when set, to opts.
+func processorDialOptions(cfg config.ProcessorConfig, opts grpcclient.DialOptions, promMetrics *metrics.Metrics, tracer *tracing.Tracer) grpcclient.DialOptions {
+	opts.HealthCheck, opts.HealthService = cfg.HealthCheck, cfg.HealthService
+	opts.Calls = grpcclient.CallPolicy{
+		Timeout:        cfg.Timeout,
+		MaxAttempts:    cfg.RetryAttempts,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+		HedgeDelay:     cfg.HedgeDelay,
+	}
+	if promMetrics != nil {
+		opts.UnaryInterceptors = append(opts.UnaryInterceptors, promMetrics.UnaryClientInterceptor())
+		opts.StreamInterceptors = append(opts.StreamInterceptors, promMetrics.StreamClientInterceptor())
+	}
+	if tracer != nil {
+		opts.UnaryInterceptors = append(opts.UnaryInterceptors, tracer.UnaryClientInterceptor())
+		opts.StreamInterceptors = append(opts.StreamInterceptors, tracer.StreamClientInterceptor())
+	}
+	return opts
+}
+
+// newTracer returns the tracer exporting to the configured collector, or nil when
+// tracing is disabled.
+func newTracer(cfg config.TracingConfig, logger *zap.Logger) *tracing.Tracer {
+	if !cfg.Enabled {
+		return nil
+	}
+	opts := tracing.DefaultOptions()
+	opts.Endpoint = cfg.Endpoint
+	opts.ServiceName = cfg.ServiceName
+	opts.SampleRatio = cfg.SampleRatio
+	opts.ExportInterval = cfg.ExportInterval
+	if len(cfg.Headers) > 0 {
+		opts.Headers = make(map[string]string, len(cfg.Headers))
+		for _, header := range cfg.Headers {
+			name, value, _ := strings.Cut(header, "=")
+			opts.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return tracing.NewWithOptions(opts, logger)
+}
+
+// startDevDependencies replaces every external service with an in-process stand-in
+// and logs a bearer token that the local API accepts.
+func startDevDependencies(plan *shutdownPlan, sqlitePath string, authCfg config.AuthConfig, logger *zap.Logger) (*dependencies, error) {
+	logger.Warn("development mode: using SQLite, in-process Redis and a stub image processor", zap.String("sqlite", sqlitePath))
+
+	db, err := devmode.OpenDatabase(sqlitePath)
+	if err != nil {
+		return nil, err
+	}
+	plan.addDatabase(db)
+
+	redisServer, redisClient, err := devmode.StartRedis()
+	if err != nil {
+		return nil, err
+	}
+	plan.addCloser("miniredis", func() error {
+		redisServer.Close()
+		return nil
+	})
+	plan.addCloser("redis", redisClient.Close)
+
+	token, err := devmode.Token(authCfg.JWTSecret, authCfg.JWTAudience, "dev-user", 24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("sign development token: %w", err)
+	}
+	logger.Info("development bearer token for user dev-user", zap.String("token", token))
+
+	return &dependencies{db: db, redis: redisClient, processor: devmode.Processor{Latency: 50 * time.Millisecond}}, nil
+}
+
+// newPoolTuner takes over the parameters of the database pool of db, which
+// openDatabase already applied, so they can be changed at runtime.
+func newPoolTuner(db *gorm.DB, cfg config.DatabaseConfig, logger *zap.Logger) (*dbpool.Tuner, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	opts := dbpool.DefaultOptions()
+	opts.Interval = cfg.Adaptive.Interval
+	opts.WaitThreshold = cfg.Adaptive.WaitThreshold
+	return dbpool.NewTunerWithOptions(sqlDB, dbpool.Settings{
+		MaxOpenConns:      cfg.MaxOpenConns,
+		MaxIdleConns:      cfg.MaxIdleConns,
+		ConnMaxLifetime:   cfg.ConnMaxLifetime,
+		Adaptive:          cfg.Adaptive.Enabled,
+		MinOpenConns:      cfg.Adaptive.MinOpenConns,
+		MaxOpenConnsLimit: cfg.Adaptive.MaxOpenConns,
+	}, logger, opts)
+}
+
+// newImageStore returns the configured object store for uploaded images, or nil
+// when images are not kept.
+func newImageStore(cfg config.StorageConfig) (usecase.ImageStore, error) {
+	opts := storage.S3Options{
+		Endpoint:    cfg.Endpoint,
+		Region:      cfg.Region,
+		Bucket:      cfg.Bucket,
+		Prefix:      cfg.Prefix,
+		PathStyle:   cfg.PathStyle,
+		Credentials: sigv4.Credentials{AccessKeyID: cfg.AccessKeyID, SecretAccessKey: cfg.SecretAccessKey},
+		Client:      &http.Client{Timeout: 30 * time.Second},
+	}
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "local":
+		return storage.NewLocal(storage.LocalOptions{
+			Dir:        cfg.Dir,
+			Prefix:     cfg.Prefix,
+			BaseURL:    strings.TrimRight(cfg.PublicURL, "/") + "/images",
+			SigningKey: []byte(cfg.SigningKey),
+		})
+	case "s3":
+	case "minio":
+		opts.PathStyle = true
+		if opts.Region == "" {
+			opts.Region = "us-east-1"
+		}
+	case "gcs":
+		if opts.Endpoint == "" {
+			opts.Endpoint = "https://storage.googleapis.com"
+		}
+		if opts.Region == "" {
+			opts.Region = "auto"
+		}
+	default:
+		return nil, fmt.Errorf("unknown storage provider %q", cfg.Provider)
+	}
+	return storage.NewS3(opts)
+}
+
+// newMalwareScanner returns the scanner checking uploads, or nil when they are not
+// scanned.
+func newMalwareScanner(cfg config.MalwareScanConfig) malwarescan.Scanner {
+	switch cfg.Provider {
+	case "clamav":
+		return malwarescan.NewClamAV(cfg.Addr, cfg.Timeout)
+	case "http":
+		return malwarescan.NewHTTP(cfg.URL, cfg.Token, cfg.Timeout)
+	}
+	return nil
+}
+
+// newWebhookService returns the webhook service, or nil when webhooks are disabled.
+// Its deliveries are sent by whichever process runs the worker.
+func newWebhookService(db *gorm.DB, queue *worker.Queue, cfg config.WebhooksConfig, logger *zap.Logger) *webhooks.Service {
+	if !cfg.Enabled {
+		return nil
+	}
+	return webhooks.NewServiceWithOptions(repository.NewWebhookRepository(db, logger), queue, logger, webhooks.Options{
+		Timeout:              cfg.Timeout,
+		MaxAttempts:          cfg.MaxAttempts,
+		MaxEndpoints:         cfg.MaxEndpoints,
+		AllowHTTP:            cfg.AllowHTTP,
+		AllowPrivateNetworks: cfg.AllowPrivateNetworks,
+	})
+}
+
+// newTenantStore returns the store of per-tenant settings.
+func newTenantStore(db *gorm.DB, client *redis.Client, cfg config.TenantsConfig, logger *zap.Logger) *tenants.Store {
+	return tenants.NewStoreWithOptions(repository.NewTenantRepository(db, logger), client, logger, tenants.Options{
+		CacheTTL: cfg.CacheTTL,
+	})
+}
+
+// newRateLimiter returns the limiter of the configured route groups. It limits
+// nothing while rate limiting is disabled, until a reload enables it.
+func newRateLimiter(cfg config.RateLimitConfig, client *redis.Client, logger *zap.Logger) *ratelimit.Limiter {
+	return ratelimit.New(client, logger, rateLimitGroups(cfg)...)
+}
+
+// rateLimitGroups returns the configured route groups, or none when rate limiting
+// is disabled.
+func rateLimitGroups(cfg config.RateLimitConfig) []ratelimit.Group {
+	if !cfg.Enabled {
+		return nil
+	}
+	names := make([]string, 0, len(cfg.Groups))
+	for name := range cfg.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	groups := make([]ratelimit.Group, 0, len(names))
+	for _, name := range names {
+		group := cfg.Groups[name]
+		groups = append(groups, ratelimit.Group{
+			Name:    name,
+			Routes:  group.Routes,
+			PerUser: ratelimit.Rate{Requests: group.PerUser.Requests, Per: group.PerUser.Per, Burst: group.PerUser.Burst},
+			PerIP:   ratelimit.Rate{Requests: group.PerIP.Requests, Per: group.PerIP.Per, Burst: group.PerIP.Burst},
+		})
+	}
+	return groups
+}
+
+// newMeter returns the usage meter, or nil when metering is disabled. Usage is only
+// reported to Stripe when an API key is configured.
+func newMeter(db *gorm.DB, cfg config.MeteringConfig, logger *zap.Logger) *metering.Meter {
+	if !cfg.Enabled {
+		return nil
+	}
+	var reporter metering.Reporter
+	if cfg.Stripe.APIKey != "" {
+		reporter = &metering.Stripe{
+			APIKey:    cfg.Stripe.APIKey,
+			EventName: cfg.Stripe.MeterEventName,
+			Client:    &http.Client{Timeout: 30 * time.Second},
+		}
+	}
+	opts := metering.DefaultOptions()
+	opts.UnitsPerVerification = cfg.UnitsPerVerification
+	return metering.NewMeterWithOptions(repository.NewUsageRepository(db, logger), reporter, logger, opts)
+}
+
+// newExporter returns the warehouse exporter, or nil when no sink is configured.
+func newExporter(db *gorm.DB, repo *repository.VerificationRepository, cfg config.WarehouseConfig, logger *zap.Logger) (*warehouse.Exporter, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+	var sink warehouse.Sink
+	var destination string
+	switch cfg.Sink {
+	case "":
+		return nil, nil
+	case "bigquery":
+		credentials, err := os.ReadFile(cfg.BigQuery.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read bigquery credentials: %w", err)
+		}
+		bigQuery, err := warehouse.NewBigQuery(warehouse.BigQueryOptions{
+			Project:     cfg.BigQuery.Project,
+			Dataset:     cfg.BigQuery.Dataset,
+			Table:       cfg.Table,
+			Credentials: credentials,
+			Client:      client,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("bigquery: %w", err)
+		}
+		sink, destination = bigQuery, cfg.BigQuery.Project+"."+cfg.BigQuery.Dataset
+	case "snowflake":
+		key, err := os.ReadFile(cfg.Snowflake.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read snowflake private key: %w", err)
+		}
+		snowflake, err := warehouse.NewSnowflake(warehouse.SnowflakeOptions{
+			Account:    cfg.Snowflake.Account,
+			User:       cfg.Snowflake.User,
+			PrivateKey: key,
+			Database:   cfg.Snowflake.Database,
+			Schema:     cfg.Snowflake.Schema,
+			Table:      cfg.Table,
+			Warehouse:  cfg.Snowflake.Warehouse,
+			Role:       cfg.Snowflake.Role,
+			Client:     client,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("snowflake: %w", err)
+		}
+		sink, destination = snowflake, cfg.Snowflake.Database+"."+cfg.Snowflake.Schema
+	case "clickhouse":
+		clickHouse, err := warehouse.NewClickHouse(warehouse.ClickHouseOptions{
+			URL:      cfg.ClickHouse.URL,
+			Database: cfg.ClickHouse.Database,
+			Table:    cfg.Table,
+			Username: cfg.ClickHouse.Username,
+			Password: cfg.ClickHouse.Password,
+			Client:   client,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("clickhouse: %w", err)
+		}
+		sink, destination = clickHouse, cfg.ClickHouse.Database
+	default:
+		return nil, fmt.Errorf("unknown warehouse sink %q", cfg.Sink)
+	}
+	return warehouse.NewExporterWithOptions(repo, repository.NewExportRepository(db, logger), sink, logger, warehouse.Options{
+		// The checkpoint is per destination table, so pointing the export elsewhere
+		// starts it over.
+		Name:       cfg.Sink + ":" + destination + "." + cfg.Table,
+		BatchSize:  cfg.BatchSize,
+		MaxBatches: cfg.MaxBatches,
+		Lag:        cfg.Lag,
+	}), nil
+}
+
+// newEventRelay returns the relay streaming events to the configured broker, or nil
+// when no broker is configured.
+func newEventRelay(queue *worker.Queue, client *redis.Client, cfg config.EventsConfig, logger *zap.Logger) (*eventbus.Relay, error) {
+	var broker eventbus.Broker
+	switch cfg.Broker {
+	case "":
+		return nil, nil
+	case "kafka":
+		kafka, err := eventbus.NewKafka(eventbus.KafkaOptions{
+			URL:      cfg.Kafka.RESTURL,
+			Topic:    cfg.Kafka.Topic,
+			Username: cfg.Kafka.Username,
+			Password: cfg.Kafka.Password,
+			Client:   &http.Client{Timeout: cfg.Timeout},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("kafka: %w", err)
+		}
+		broker = kafka
+	case "nats":
+		nats, err := eventbus.NewNATS(eventbus.NATSOptions{
+			URL:       cfg.NATS.URL,
+			Subject:   cfg.NATS.Subject,
+			Token:     cfg.NATS.Token,
+			Username:  cfg.NATS.Username,
+			Password:  cfg.NATS.Password,
+			JetStream: cfg.NATS.JetStream,
+			Timeout:   cfg.Timeout,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("nats: %w", err)
+		}
+		broker = nats
+	case "redis":
+		stream, err := eventbus.NewRedisStream(client, eventbus.RedisStreamOptions{
+			Stream: cfg.Redis.Stream,
+			MaxLen: cfg.Redis.MaxLen,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		broker = stream
+	default:
+		return nil, fmt.Errorf("unknown event broker %q", cfg.Broker)
+	}
+	opts := eventbus.DefaultOptions()
+	opts.Format = cfg.Format
+	return eventbus.NewRelayWithOptions(queue, broker, logger, opts), nil
+}
+
+// newMonitor returns the alerting monitor, or nil when no notification destination
+// is configured.
+func newMonitor(cfg config.NotificationsConfig, client *redis.Client, logger *zap.Logger) (*notify.Monitor, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	var notifiers []notify.Notifier
+	if cfg.Slack.WebhookURL != "" {
+		notifiers = append(notifiers, &notify.Slack{WebhookURL: cfg.Slack.WebhookURL, Client: &http.Client{Timeout: cfg.Timeout}})
+	}
+	if cfg.SMTP.Addr != "" {
+		notifiers = append(notifiers, &notify.SMTP{
+			Addr:     cfg.SMTP.Addr,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+			To:       cfg.SMTP.To,
+		})
+	}
+	return notify.NewMonitorWithOptions(client, notifiers, logger, notify.Options{
+		ConsecutiveFailures: cfg.ConsecutiveFailures,
+		ErrorRateThreshold:  cfg.ErrorRate.Threshold,
+		ErrorRateWindow:     cfg.ErrorRate.Window,
+		MinRequests:         cfg.ErrorRate.MinRequests,
+		Cooldown:            cfg.Cooldown,
+		Timeout:             cfg.Timeout,
+		Templates:           cfg.Templates,
+	})
+}
+
+// newExperiment returns the configured model experiment, or nil without variants.
+// Variants without their own processor address share processor. The others connect
+// in the background, so an unavailable candidate model fails only its own share of
+// verifications. With monitor, their failures count towards the error rate rule.
+func newExperiment(ctx context.Context, cfg config.ExperimentConfig, processorCfg config.ProcessorConfig, processor imageprocessor.Client, dev bool, monitor *notify.Monitor, promMetrics *metrics.Metrics, tracer *tracing.Tracer, plan *shutdownPlan, logger *zap.Logger) (*experiment.Experiment, error) {
+	if len(cfg.Variants) == 0 {
+		return nil, nil
+	}
+	variants := make([]experiment.Variant, 0, len(cfg.Variants))
+	for _, variantCfg := range cfg.Variants {
+		variant := experiment.Variant{Name: variantCfg.Name, Percent: variantCfg.Percent, Users: variantCfg.Users, Processor: processor}
+		if variantCfg.ProcessorAddr != "" && !dev {
+			client, conn, err := grpcclient.DialImageProcessorWithOptions(ctx, variantCfg.ProcessorAddr, logger, processorDialOptions(processorCfg, grpcclient.DialOptions{}, promMetrics, tracer))
+			if err != nil {
+				return nil, fmt.Errorf("variant %s: %w", variantCfg.Name, err)
+			}
+			plan.addCloser("grpc-"+variantCfg.Name, conn.Close)
+			if monitor != nil {
+				client = monitor.ObserveProcessor(client)
+			}
+			variant.Processor = client
+		}
+		variants = append(variants, variant)
+	}
+	return experiment.New(cfg.Name, variants)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/grpcserver"
+	"github.com/example/ai-check/internal/imagelimits"
+	"github.com/example/ai-check/internal/tenants"
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/users"
+)
+
+// startGRPCServer serves the gRPC verification API on addr until the shutdown plan
+// stops it. A non-nil tlsConfig is the public listener's, so both share certificates.
+func startGRPCServer(plan *shutdownPlan, addr string, tlsConfig *tls.Config, uc *usecase.VerificationUseCase, creds *auth.Credentials, accounts *users.Service, tenantStore *tenants.Store, maxUploadSize int64, imageLimits *imagelimits.Limits, logger *zap.Logger) error {
+	opts := grpcserver.DefaultOptions()
+	opts.MaxUploadSize = maxUploadSize
+	opts.ImageLimits = imageLimits
+	opts.Users = accounts
+	opts.Tenants = tenantStore
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+		// gRPC requires HTTP/2, whatever the public listener negotiates.
+		tlsConfig.NextProtos = []string{"h2"}
+		opts.ServerOptions = append(opts.ServerOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	listener, err := listenTCP(addr, false)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	server := grpcserver.NewServer(uc, creds, logger, opts)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			logger.Error("grpc server failed", zap.Error(err), zap.String("addr", addr))
+		}
+	}()
+	plan.add("grpc", func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			server.Stop()
+			return ctx.Err()
+		}
+	})
+	logger.Info("gRPC API listening", zap.String("addr", addr), zap.Bool("tls", tlsConfig != nil))
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/example/ai-check/internal/config"
+)
+
+// configureHTTP2 applies the HTTP/2 limits to server. Call it after TLSConfig is set:
+// TLS listeners negotiate h2 through ALPN, while plain listeners only speak HTTP/2
+// when h2c is enabled.
+func configureHTTP2(server *http.Server, cfg config.HTTP2Config) error {
+	h2s := &http2.Server{
+		MaxConcurrentStreams: uint32(cfg.MaxConcurrentStreams),
+		MaxReadFrameSize:     uint32(cfg.MaxReadFrameSize),
+		IdleTimeout:          cfg.IdleTimeout,
+	}
+	if server.TLSConfig != nil {
+		return http2.ConfigureServer(server, h2s)
+	}
+	if cfg.H2C {
+		server.Handler = h2c.NewHandler(server.Handler, h2s)
+	}
+	return nil
+}
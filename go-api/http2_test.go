@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/http2"
+
+	"github.com/example/ai-check/internal/config"
+)
+
+func TestConfigureHTTP2ServesCleartextH2WhenEnabled(t *testing.T) {
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+	})}
+	if err := configureHTTP2(server, config.HTTP2Config{H2C: true, MaxConcurrentStreams: 10, MaxReadFrameSize: 1 << 20}); err != nil {
+		t.Fatalf("configureHTTP2 returned error: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(server.Handler)
+	ts.Start()
+	defer ts.Close()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("h2c request failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Proto"); got != "HTTP/2.0" {
+		t.Fatalf("expected the request to be served over HTTP/2, got %q", got)
+	}
+}
+
+func TestConfigureHTTP2LeavesPlainHandlerWithoutH2C(t *testing.T) {
+	handler := http.NotFoundHandler()
+	server := &http.Server{Handler: handler}
+	if err := configureHTTP2(server, config.HTTP2Config{MaxConcurrentStreams: 10, MaxReadFrameSize: 1 << 20}); err != nil {
+		t.Fatalf("configureHTTP2 returned error: %v", err)
+	}
+	if server.TLSNextProto != nil {
+		t.Fatal("expected no HTTP/2 configuration for a plain listener without h2c")
+	}
+}
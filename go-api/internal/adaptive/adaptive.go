@@ -0,0 +1,218 @@
+// Package adaptive bounds concurrent verifications with a limit that follows the
+// health of their dependencies. The limit grows by one per round trip while the
+// processor and database answer as fast as usual (additive increase) and is cut by a
+// fraction as soon as either slows down well beyond its baseline or times out
+// (multiplicative decrease), so load is shed before queued work runs into timeouts.
+package adaptive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrInvalidSettings is returned for settings the limiter cannot run with.
+var ErrInvalidSettings = errors.New("invalid adaptive limit settings")
+
+// Settings bound the limit and say when a dependency counts as congested.
+type Settings struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	// Tolerance is how many times slower than its baseline a dependency may answer
+	// before the limit is cut, e.g. 2.
+	Tolerance float64
+	// Backoff multiplies the limit when a dependency is congested, e.g. 0.9.
+	Backoff float64
+}
+
+// DefaultSettings returns the settings used when none are configured.
+func DefaultSettings() Settings {
+	return Settings{InitialLimit: 20, MinLimit: 4, MaxLimit: 200, Tolerance: 2, Backoff: 0.9}
+}
+
+func (s Settings) validate() error {
+	switch {
+	case s.MinLimit <= 0 || s.MinLimit > s.MaxLimit:
+		return fmt.Errorf("%w: limits must satisfy 0 < min_limit <= max_limit", ErrInvalidSettings)
+	case s.InitialLimit < s.MinLimit || s.InitialLimit > s.MaxLimit:
+		return fmt.Errorf("%w: initial_limit must be between min_limit and max_limit", ErrInvalidSettings)
+	case s.Tolerance <= 1:
+		return fmt.Errorf("%w: tolerance must be above 1", ErrInvalidSettings)
+	case s.Backoff <= 0 || s.Backoff >= 1:
+		return fmt.Errorf("%w: backoff must be between 0 and 1", ErrInvalidSettings)
+	}
+	return nil
+}
+
+const (
+	// shortWeight smooths the recent latency of a dependency over about ten samples.
+	shortWeight = 0.2
+	// baselineWeight lets the baseline follow lasting changes, such as a slower
+	// model, over a few hundred samples.
+	baselineWeight = 0.005
+)
+
+// latency tracks the recent and the usual latency of one dependency.
+type latency struct {
+	short    float64
+	baseline float64
+}
+
+// Status is the current limit and usage.
+type Status struct {
+	Settings Settings
+	Limit    int
+	InFlight int
+	// Shed counts the verifications rejected since the limiter started.
+	Shed int64
+	// Baselines is the usual latency of each dependency observed so far.
+	Baselines map[string]time.Duration
+	// LastDecrease is when the limit was last cut; zero when it never was.
+	LastDecrease time.Time
+}
+
+// Limiter admits verifications up to the current limit. It is safe for concurrent
+// use.
+type Limiter struct {
+	logger *zap.Logger
+	now    func() time.Time
+
+	mu           sync.Mutex
+	settings     Settings
+	limit        float64
+	inFlight     int
+	shed         int64
+	latencies    map[string]*latency
+	lastDecrease time.Time
+}
+
+// NewLimiter returns a limiter starting at settings.InitialLimit.
+func NewLimiter(settings Settings, logger *zap.Logger) (*Limiter, error) {
+	if err := settings.validate(); err != nil {
+		return nil, err
+	}
+	return &Limiter{
+		logger:    logger.Named("adaptive_limit"),
+		now:       time.Now,
+		settings:  settings,
+		limit:     float64(settings.InitialLimit),
+		latencies: make(map[string]*latency),
+	}, nil
+}
+
+// Acquire takes a slot, or returns false when the limit is reached. Every
+// successful Acquire must be followed by Release.
+func (l *Limiter) Acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight >= int(l.limit) {
+		l.shed++
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+// Release returns a slot taken by Acquire.
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+}
+
+// Observe records how long a call to dependency took and adjusts the limit. A call
+// that timed out or found the dependency unavailable counts as congestion, whatever
+// its latency; other errors are ignored.
+func (l *Limiter) Observe(dependency string, took time.Duration, err error) {
+	if err != nil && !overloaded(err) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats, ok := l.latencies[dependency]
+	if err != nil {
+		var roundTrip time.Duration
+		if ok {
+			roundTrip = time.Duration(stats.short)
+		}
+		l.decrease(dependency, roundTrip)
+		return
+	}
+	sample := float64(took)
+	if !ok {
+		stats = &latency{short: sample, baseline: sample}
+		l.latencies[dependency] = stats
+	}
+	stats.short += shortWeight * (sample - stats.short)
+	stats.baseline += baselineWeight * (sample - stats.baseline)
+	if stats.short > stats.baseline*l.settings.Tolerance {
+		l.decrease(dependency, time.Duration(stats.short))
+		return
+	}
+	// Grow only while the limit is actually used, so an idle server does not
+	// drift to the maximum.
+	if l.inFlight*2 >= int(l.limit) {
+		l.limit = math.Min(l.limit+1/l.limit, float64(l.settings.MaxLimit))
+	}
+}
+
+// decrease cuts the limit at most once per round trip of the congested dependency,
+// since the calls completing meanwhile were started under the old limit.
+func (l *Limiter) decrease(dependency string, roundTrip time.Duration) {
+	now := l.now()
+	if now.Sub(l.lastDecrease) < roundTrip {
+		return
+	}
+	previous := int(l.limit)
+	l.limit = math.Max(l.limit*l.settings.Backoff, float64(l.settings.MinLimit))
+	l.lastDecrease = now
+	if next := int(l.limit); next != previous {
+		l.logger.Info("lowered concurrent verification limit",
+			zap.String("dependency", dependency),
+			zap.Int("from", previous),
+			zap.Int("to", next),
+			zap.Duration("latency", roundTrip),
+			zap.Int("in_flight", l.inFlight),
+		)
+	}
+}
+
+// Status returns the limit next to its current usage.
+func (l *Limiter) Status() Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	baselines := make(map[string]time.Duration, len(l.latencies))
+	for dependency, stats := range l.latencies {
+		baselines[dependency] = time.Duration(stats.baseline)
+	}
+	return Status{
+		Settings:     l.settings,
+		Limit:        int(l.limit),
+		InFlight:     l.inFlight,
+		Shed:         l.shed,
+		Baselines:    baselines,
+		LastDecrease: l.lastDecrease,
+	}
+}
+
+// overloaded reports whether err means the dependency could not keep up: a timeout,
+// or a gRPC Unavailable or ResourceExhausted status.
+func overloaded(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package adaptive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLimiterGrowsWhileBusyAndBacksOffOnCongestion(t *testing.T) {
+	limiter, err := NewLimiter(Settings{InitialLimit: 4, MinLimit: 2, MaxLimit: 6, Tolerance: 2, Backoff: 0.5}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewLimiter returned error: %v", err)
+	}
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		if !limiter.Acquire() {
+			t.Fatalf("expected slot %d to be admitted", i+1)
+		}
+	}
+	if limiter.Acquire() || limiter.Status().Shed != 1 {
+		t.Fatalf("expected the fifth verification to be shed, got %+v", limiter.Status())
+	}
+
+	// Fast answers under load grow the limit by about one per round trip.
+	for i := 0; i < 8; i++ {
+		limiter.Observe("processor", 10*time.Millisecond, nil)
+	}
+	if got := limiter.Status().Limit; got != 5 {
+		t.Fatalf("expected the limit to grow to 5, got %d", got)
+	}
+	if !limiter.Acquire() {
+		t.Fatal("expected the grown limit to admit another verification")
+	}
+
+	// Errors that say nothing about load are ignored.
+	limiter.Observe("processor", time.Second, status.Error(codes.InvalidArgument, "bad image"))
+	if got := limiter.Status().Limit; got != 5 {
+		t.Fatalf("expected a rejected image to keep the limit, got %d", got)
+	}
+
+	// A slowdown well beyond the baseline halves the limit once per round trip.
+	for i := 0; i < 5; i++ {
+		limiter.Observe("processor", 100*time.Millisecond, nil)
+	}
+	status := limiter.Status()
+	if status.Limit != 2 || status.LastDecrease != now {
+		t.Fatalf("expected one cut to 2, got %+v", status)
+	}
+	now = now.Add(time.Second)
+	limiter.Observe("database", time.Second, context.DeadlineExceeded)
+	if got := limiter.Status().Limit; got != 2 {
+		t.Fatalf("expected the limit to stay at the minimum, got %d", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		limiter.Release()
+	}
+	if got := limiter.Status().InFlight; got != 0 {
+		t.Fatalf("expected no verifications in flight, got %d", got)
+	}
+}
+
+func TestLimiterDoesNotGrowWhileIdle(t *testing.T) {
+	limiter, err := NewLimiter(DefaultSettings(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewLimiter returned error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		limiter.Observe("processor", 10*time.Millisecond, nil)
+	}
+	if got := limiter.Status().Limit; got != DefaultSettings().InitialLimit {
+		t.Fatalf("expected an unused limit to stay at %d, got %d", DefaultSettings().InitialLimit, got)
+	}
+}
+
+func TestNewLimiterRejectsInvalidSettings(t *testing.T) {
+	for _, settings := range []Settings{
+		{InitialLimit: 1, MinLimit: 0, MaxLimit: 10, Tolerance: 2, Backoff: 0.9},
+		{InitialLimit: 20, MinLimit: 1, MaxLimit: 10, Tolerance: 2, Backoff: 0.9},
+		{InitialLimit: 5, MinLimit: 1, MaxLimit: 10, Tolerance: 1, Backoff: 0.9},
+		{InitialLimit: 5, MinLimit: 1, MaxLimit: 10, Tolerance: 2, Backoff: 1},
+	} {
+		if _, err := NewLimiter(settings, zap.NewNop()); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("expected ErrInvalidSettings for %+v, got %v", settings, err)
+		}
+	}
+}
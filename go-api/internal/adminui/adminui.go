@@ -0,0 +1,41 @@
+// Package adminui embeds the single-page operations console served on the admin
+// listener. The console only talks to same-origin admin APIs.
+package adminui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed static
+var static embed.FS
+
+// Register serves the console under prefix (for example "/admin/ui"). Unknown paths
+// below the prefix fall back to index.html so client-side routes survive a reload.
+func Register(router gin.IRouter, prefix string) {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err) // the embedded tree is fixed at compile time
+	}
+	fileServer := http.StripPrefix(prefix, http.FileServer(http.FS(files)))
+
+	router.GET(prefix, func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, prefix+"/")
+	})
+	router.GET(prefix+"/*filepath", func(c *gin.Context) {
+		name := strings.TrimPrefix(path.Clean(c.Param("filepath")), "/")
+		if name != "" {
+			if _, err := fs.Stat(files, name); err != nil {
+				c.Request.URL.Path = prefix + "/"
+			}
+		}
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		fileServer.ServeHTTP(c.Writer, c.Request)
+	})
+}
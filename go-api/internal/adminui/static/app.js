@@ -0,0 +1,150 @@
+"use strict";
+
+// All requests are same-origin calls to the admin listener.
+const api = {
+  metrics: "../api/metrics/summary",
+  search: "../api/search",
+  review: "../api/review-queue",
+  version: "../../version",
+  health: "../../health",
+};
+
+async function getJSON(url) {
+  const resp = await fetch(url, { headers: { Accept: "application/json" } });
+  if (resp.status === 404) {
+    const err = new Error("This API is not enabled on this server.");
+    err.unavailable = true;
+    throw err;
+  }
+  if (!resp.ok) {
+    throw new Error(`${resp.status} ${resp.statusText}`);
+  }
+  return resp.json();
+}
+
+function el(tag, attrs = {}, children = []) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs)) {
+    if (key === "text") node.textContent = value;
+    else node.setAttribute(key, value);
+  }
+  for (const child of children) node.appendChild(child);
+  return node;
+}
+
+function notice(target, err) {
+  target.replaceChildren(el("div", { class: err.unavailable ? "notice" : "notice error", text: err.message }));
+}
+
+function table(target, rows, columns) {
+  if (!rows.length) {
+    target.replaceChildren(el("div", { class: "notice", text: "Nothing to show." }));
+    return;
+  }
+  const head = el("tr", {}, columns.map((c) => el("th", { text: c.label })));
+  const body = rows.map((row) => el("tr", {}, columns.map((c) => el("td", { text: format(row[c.key]) }))));
+  target.replaceChildren(el("table", {}, [el("thead", {}, [head]), el("tbody", {}, body)]));
+}
+
+function format(value) {
+  if (value === undefined || value === null) return "–";
+  if (typeof value === "number" && !Number.isInteger(value)) return value.toFixed(3);
+  return String(value);
+}
+
+const itemColumns = [
+  { key: "request_id", label: "Request" },
+  { key: "user_id", label: "User" },
+  { key: "score", label: "Score" },
+  { key: "success", label: "Success" },
+  { key: "created_at", label: "Created" },
+];
+
+const reviewColumns = [
+  ...itemColumns,
+  { key: "state", label: "State" },
+  { key: "reason", label: "Reason" },
+];
+
+async function loadMetrics() {
+  const target = document.getElementById("metrics");
+  try {
+    const m = await getJSON(api.metrics);
+    const cards = [
+      ["Total requests", m.total_requests],
+      ["Successful", m.successful_requests],
+      ["Success rate", `${(m.success_rate * 100).toFixed(1)}%`],
+      ["Average score", format(m.average_score)],
+      ["Avg latency (ms)", format(m.average_processing_latency_ms)],
+    ];
+    target.replaceChildren(...cards.map(([label, value]) =>
+      el("div", { class: "card" }, [el("div", { class: "label", text: label }), el("div", { class: "value", text: String(value) })])));
+    document.getElementById("metrics-updated").textContent = `Updated ${new Date().toLocaleTimeString()}`;
+  } catch (err) {
+    notice(target, err);
+  }
+}
+
+async function search(event) {
+  event.preventDefault();
+  const target = document.getElementById("search-results");
+  const query = document.getElementById("search-query").value.trim();
+  try {
+    const data = await getJSON(`${api.search}?q=${encodeURIComponent(query)}`);
+    table(target, data.items || [], itemColumns);
+  } catch (err) {
+    notice(target, err);
+  }
+}
+
+async function loadReviewQueue() {
+  const target = document.getElementById("review-results");
+  try {
+    const data = await getJSON(api.review);
+    table(target, data.items || [], reviewColumns);
+  } catch (err) {
+    notice(target, err);
+  }
+}
+
+async function loadHeader() {
+  const badge = document.getElementById("health");
+  try {
+    await getJSON(api.health);
+    badge.textContent = "healthy";
+    badge.className = "badge ok";
+  } catch (err) {
+    badge.textContent = "unhealthy";
+    badge.className = "badge bad";
+  }
+  try {
+    const v = await getJSON(api.version);
+    document.getElementById("build").textContent = `${v.version} · ${(v.commit || "unknown").slice(0, 12)}`;
+  } catch (err) {
+    // Version information is optional.
+  }
+}
+
+const loaders = { metrics: loadMetrics, review: loadReviewQueue };
+
+function show() {
+  const view = (location.hash || "#metrics").slice(1);
+  for (const section of document.querySelectorAll(".view")) {
+    section.hidden = section.id !== `view-${view}`;
+  }
+  for (const link of document.querySelectorAll("nav a")) {
+    link.classList.toggle("active", link.dataset.view === view);
+  }
+  if (loaders[view]) loaders[view]();
+}
+
+document.getElementById("metrics-refresh").addEventListener("click", loadMetrics);
+document.getElementById("review-refresh").addEventListener("click", loadReviewQueue);
+document.getElementById("search-form").addEventListener("submit", search);
+window.addEventListener("hashchange", show);
+
+loadHeader();
+show();
+setInterval(() => {
+  if (!document.getElementById("view-metrics").hidden) loadMetrics();
+}, 30000);
@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// tokenUseRefresh is the token_use claim of refresh tokens, which are refused where
+// an access token is expected.
+const tokenUseRefresh = "refresh"
+
+// revokedKeyPrefix starts the Redis key remembering a revoked token ID.
+const revokedKeyPrefix = "auth:revoked:"
+
+var (
+	// ErrInvalidClient is returned for unknown clients and wrong secrets.
+	ErrInvalidClient = errors.New("invalid client credentials")
+	// ErrInvalidGrant is returned for refresh tokens that are malformed, expired,
+	// revoked, already used or issued to a client that was removed.
+	ErrInvalidGrant = errors.New("invalid refresh token")
+)
+
+// Client is an application allowed to obtain tokens with its ID and secret.
+type Client struct {
+	ID string
+	// SecretHash is the bcrypt hash of the client's secret.
+	SecretHash string
+	// Subject is the sub claim of the client's tokens; empty uses ID.
+	Subject string
+	// TenantID is the tenant claim of the client's tokens, if any.
+	TenantID string
+	// Roles is the roles claim of the client's tokens.
+	Roles []string
+	// Scopes limits the client's tokens; nil issues tokens without a scope claim.
+	Scopes []string
+}
+
+func (c Client) subject() string {
+	if c.Subject != "" {
+		return c.Subject
+	}
+	return c.ID
+}
+
+// Revocations remembers revoked token IDs in Redis until the tokens expire.
+type Revocations struct {
+	redis *redis.Client
+}
+
+// NewRevocations returns a revocation list kept in client.
+func NewRevocations(client *redis.Client) *Revocations {
+	return &Revocations{redis: client}
+}
+
+// Revoke revokes the token with ID jti until expiresAt. It reports false when the
+// token was already revoked, so a refresh token is only ever exchanged once.
+func (r *Revocations) Revoke(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return false, nil
+	}
+	added, err := r.redis.SetNX(ctx, revokedKeyPrefix+jti, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("revoke token %s: %w", jti, err)
+	}
+	return added, nil
+}
+
+// IsRevoked reports whether the token with ID jti was revoked.
+func (r *Revocations) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := r.redis.Exists(ctx, revokedKeyPrefix+jti).Result()
+	if err != nil {
+		return false, fmt.Errorf("check token %s: %w", jti, err)
+	}
+	return n > 0, nil
+}
+
+// IssuerOptions configures an Issuer.
+type IssuerOptions struct {
+	// AccessTTL is how long access tokens are valid.
+	AccessTTL time.Duration
+	// RefreshTTL is how long refresh tokens are valid.
+	RefreshTTL time.Duration
+}
+
+// TokenPair is an access token with the refresh token exchanging it for a new pair.
+type TokenPair struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// Issuer signs tokens for configured clients with the current secret of a credential
+// set, so the service can run without an external identity provider. It is safe for
+// concurrent use.
+type Issuer struct {
+	creds       *Credentials
+	revocations *Revocations
+	opts        IssuerOptions
+
+	mu      sync.RWMutex
+	clients map[string]Client
+}
+
+// NewIssuer returns an issuer signing with creds and remembering used and revoked
+// tokens in revocations.
+func NewIssuer(creds *Credentials, revocations *Revocations, opts IssuerOptions, clients []Client) *Issuer {
+	i := &Issuer{creds: creds, revocations: revocations, opts: opts}
+	i.SetClients(clients)
+	return i
+}
+
+// SetClients replaces the clients, e.g. after a configuration reload. Refresh tokens
+// of removed clients stop working.
+func (i *Issuer) SetClients(clients []Client) {
+	byID := make(map[string]Client, len(clients))
+	for _, client := range clients {
+		byID[client.ID] = client
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.clients = byID
+}
+
+func (i *Issuer) client(id string) (Client, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	client, ok := i.clients[id]
+	return client, ok
+}
+
+// IssueForClient authenticates a client by its ID and secret and issues it a token
+// pair.
+func (i *Issuer) IssueForClient(ctx context.Context, clientID, secret string) (*TokenPair, error) {
+	client, ok := i.client(clientID)
+	if !ok || secret == "" {
+		return nil, ErrInvalidClient
+	}
+	if bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret)) != nil {
+		return nil, ErrInvalidClient
+	}
+	return i.issue(client, time.Now())
+}
+
+// Refresh exchanges a refresh token for a new pair. The refresh token is revoked, so
+// it cannot be used again.
+func (i *Issuer) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := i.parse(refreshToken)
+	if err != nil || claims.TokenUse != tokenUseRefresh || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, ErrInvalidGrant
+	}
+	client, ok := i.client(claims.ClientID)
+	if !ok {
+		return nil, ErrInvalidGrant
+	}
+	revoked, err := i.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		return nil, ErrInvalidGrant
+	}
+	return i.issue(client, time.Now())
+}
+
+// Revoke revokes an access or refresh token until it expires. Tokens that are not
+// valid are ignored, as they are refused anyway.
+func (i *Issuer) Revoke(ctx context.Context, token string) error {
+	claims, err := i.parse(token)
+	if err != nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	_, err = i.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+	return err
+}
+
+// parse validates a token signed with one of the accepted secrets and its audience,
+// whatever its use.
+func (i *Issuer) parse(token string) (*tokenClaims, error) {
+	secrets, _, audience := i.creds.snapshot()
+	claims, err := parseClaims(token, secrets)
+	if err != nil {
+		return nil, err
+	}
+	if audience != "" && !containsAudience(claims.Audience, audience) {
+		return nil, errors.New("invalid audience")
+	}
+	return claims, nil
+}
+
+func (i *Issuer) issue(client Client, now time.Time) (*TokenPair, error) {
+	secrets, _, audience := i.creds.snapshot()
+	if len(secrets) == 0 {
+		return nil, errors.New("missing JWT secret")
+	}
+	sign := func(use string, ttl time.Duration) (string, time.Time, error) {
+		expiresAt := now.Add(ttl)
+		claims := tokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        uuid.NewString(),
+				Subject:   client.subject(),
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+			Tenant:   client.TenantID,
+			Roles:    client.Roles,
+			Scope:    strings.Join(client.Scopes, " "),
+			ClientID: client.ID,
+			TokenUse: use,
+		}
+		if audience != "" {
+			claims.Audience = jwt.ClaimStrings{audience}
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secrets[0]))
+		return signed, expiresAt, err
+	}
+
+	var pair TokenPair
+	var err error
+	if pair.AccessToken, pair.AccessExpiresAt, err = sign("", i.opts.AccessTTL); err != nil {
+		return nil, fmt.Errorf("sign access token: %w", err)
+	}
+	if pair.RefreshToken, pair.RefreshExpiresAt, err = sign(tokenUseRefresh, i.opts.RefreshTTL); err != nil {
+		return nil, fmt.Errorf("sign refresh token: %w", err)
+	}
+	return &pair, nil
+}
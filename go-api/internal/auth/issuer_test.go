@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestIssuerIssuesRefreshesAndRevokesTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	hash, err := bcrypt.GenerateFromPassword([]byte("client-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash secret: %v", err)
+	}
+	creds := NewCredentials("ai-check", "secret")
+	revocations := NewRevocations(client)
+	creds.SetRevocations(revocations)
+	issuer := NewIssuer(creds, revocations, IssuerOptions{AccessTTL: time.Minute, RefreshTTL: time.Hour}, []Client{
+		{ID: "reporting", SecretHash: string(hash), TenantID: "acme", Roles: []string{"admin"}, Scopes: []string{"verify:read"}},
+	})
+	router := gin.New()
+	router.GET("/", JWTMiddlewareWithCredentials(creds), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	ctx := context.Background()
+
+	if _, err := issuer.IssueForClient(ctx, "reporting", "wrong"); !errors.Is(err, ErrInvalidClient) {
+		t.Fatalf("expected a wrong secret to be refused, got %v", err)
+	}
+	pair, err := issuer.IssueForClient(ctx, "reporting", "client-secret")
+	if err != nil {
+		t.Fatalf("IssueForClient returned error: %v", err)
+	}
+	identity, err := creds.Identify(pair.AccessToken)
+	if err != nil || identity.UserID != "reporting" || identity.TenantID != "acme" || !HasRole(identity.WithContext(ctx), "admin") ||
+		HasScope(identity.WithContext(ctx), ScopeVerifyWrite) || !HasScope(identity.WithContext(ctx), "verify:read") {
+		t.Fatalf("unexpected identity %+v (%v)", identity, err)
+	}
+	if _, err := creds.Identify(pair.RefreshToken); err == nil {
+		t.Fatal("expected a refresh token to be refused as an access token")
+	}
+
+	refreshed, err := issuer.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if _, err := issuer.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("expected a used refresh token to be refused, got %v", err)
+	}
+	if _, err := issuer.Refresh(ctx, refreshed.AccessToken); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("expected an access token not to refresh, got %v", err)
+	}
+
+	if code := serve(router, refreshed.AccessToken); code != http.StatusOK {
+		t.Fatalf("expected the refreshed token to be accepted, got %d", code)
+	}
+	if err := issuer.Revoke(ctx, refreshed.AccessToken); err != nil {
+		t.Fatalf("Revoke returned error: %v", err)
+	}
+	if code := serve(router, refreshed.AccessToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected a revoked token to be refused, got %d", code)
+	}
+
+	// Removing a client stops its refresh tokens from working.
+	issuer.SetClients(nil)
+	if _, err := issuer.Refresh(ctx, refreshed.RefreshToken); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("expected a removed client's refresh token to be refused, got %v", err)
+	}
+}
+
+func TestTokensAreRefusedWhileRevocationsCannotBeRead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	defer client.Close()
+	hash, err := bcrypt.GenerateFromPassword([]byte("client-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash secret: %v", err)
+	}
+	creds := NewCredentials("ai-check", "secret")
+	revocations := NewRevocations(client)
+	creds.SetRevocations(revocations)
+	issuer := NewIssuer(creds, revocations, IssuerOptions{AccessTTL: time.Minute, RefreshTTL: time.Hour}, []Client{
+		{ID: "reporting", SecretHash: string(hash)},
+	})
+	router := gin.New()
+	router.GET("/", JWTMiddlewareWithCredentials(creds), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	pair, err := issuer.IssueForClient(context.Background(), "reporting", "client-secret")
+	if err != nil {
+		t.Fatalf("IssueForClient returned error: %v", err)
+	}
+
+	server.Close()
+	if _, err := creds.Identify(pair.AccessToken); !errors.Is(err, ErrRevocationCheckFailed) {
+		t.Fatalf("expected ErrRevocationCheckFailed, got %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusServiceUnavailable || resp.Header().Get("Retry-After") == "" || !strings.Contains(resp.Body.String(), `"unavailable"`) {
+		t.Fatalf("expected the token to be refused with 503 and Retry-After, got %d %q: %s", resp.Code, resp.Header().Get("Retry-After"), resp.Body.String())
+	}
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// publicKeyMethods are the signing algorithms verified with a KeySet.
+var publicKeyMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// KeySetOptions tunes a KeySet.
+type KeySetOptions struct {
+	// Issuer, when set, must be the iss claim of the tokens verified with the keys.
+	Issuer string
+	// RefreshInterval is how long fetched keys are used before they are fetched
+	// again.
+	RefreshInterval time.Duration
+	// MinRefreshInterval spaces fetches, so tokens naming unknown key IDs cannot
+	// hammer the identity provider and an unreachable one does not slow every
+	// request down.
+	MinRefreshInterval time.Duration
+	// Timeout bounds one fetch.
+	Timeout time.Duration
+}
+
+// DefaultKeySetOptions returns the options used by NewKeySet.
+func DefaultKeySetOptions() KeySetOptions {
+	return KeySetOptions{RefreshInterval: time.Hour, MinRefreshInterval: 30 * time.Second, Timeout: 5 * time.Second}
+}
+
+// KeySet verifies RSA and ECDSA signed tokens with the keys an identity provider,
+// such as Auth0 or Keycloak, publishes as a JSON Web Key Set. Keys are cached and
+// fetched again every RefreshInterval, or earlier when a token names an unknown
+// key, so rotated keys are picked up without a restart. It is safe for concurrent
+// use.
+type KeySet struct {
+	url    string
+	opts   KeySetOptions
+	client *http.Client
+
+	// fetchMu lets one fetch run at a time.
+	fetchMu sync.Mutex
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+// NewKeySet returns a key set fetched from url with DefaultKeySetOptions. Keys are
+// first fetched when a token needs them, or by Refresh.
+func NewKeySet(url string) *KeySet {
+	return NewKeySetWithOptions(url, DefaultKeySetOptions())
+}
+
+// NewKeySetWithOptions returns a key set fetched from url with explicit options.
+func NewKeySetWithOptions(url string, opts KeySetOptions) *KeySet {
+	return &KeySet{url: url, opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+}
+
+// Refresh fetches the keys now.
+func (k *KeySet) Refresh(ctx context.Context) error {
+	k.fetchMu.Lock()
+	defer k.fetchMu.Unlock()
+	return k.fetch(ctx)
+}
+
+// key returns the key with the ID kid. A token without a key ID may use the only
+// key of a set.
+func (k *KeySet) key(kid string) (crypto.PublicKey, error) {
+	key, found, stale, mayFetch := k.lookup(kid)
+	if found && !stale {
+		return key, nil
+	}
+	if mayFetch {
+		k.fetchMu.Lock()
+		// Another request may have fetched the keys while this one waited.
+		if key, found, stale, mayFetch = k.lookup(kid); (!found || stale) && mayFetch {
+			err := k.fetch(context.Background())
+			if err != nil && !found {
+				k.fetchMu.Unlock()
+				return nil, err
+			}
+			// A failed refresh keeps using the keys fetched before.
+			if err == nil {
+				key, found, _, _ = k.lookup(kid)
+			}
+		}
+		k.fetchMu.Unlock()
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookup finds kid in the cached keys, reporting whether the keys are due for a
+// refresh and whether a fetch may be tried now.
+func (k *KeySet) lookup(kid string) (key crypto.PublicKey, found, stale, mayFetch bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, found = k.keys[kid]
+	if !found && kid == "" && len(k.keys) == 1 {
+		for _, only := range k.keys {
+			key, found = only, true
+		}
+	}
+	stale = time.Since(k.fetchedAt) >= k.opts.RefreshInterval
+	mayFetch = time.Since(k.triedAt) >= k.opts.MinRefreshInterval
+	return key, found, stale, mayFetch
+}
+
+// jsonWebKey holds the members of the RSA and EC keys of RFC 7517 and RFC 7518.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetch replaces the cached keys with those published at the URL. Call it with
+// fetchMu held.
+func (k *KeySet) fetch(ctx context.Context) error {
+	k.mu.Lock()
+	k.triedAt = time.Now()
+	k.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch JWKS: %s answered %s", k.url, resp.Status)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of other types, such as symmetric ones, are never accepted.
+		key, err := jwk.publicKey()
+		if err != nil || key == nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("decode JWKS: %s holds no RSA or EC signing keys", k.url)
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.fetchedAt = time.Now()
+	k.mu.Unlock()
+	return nil
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var checked ecdh.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve, checked = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, checked = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, checked = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		// Reject points off the curve by parsing their uncompressed encoding.
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x.Bytes()) > size || len(y.Bytes()) > size {
+			return nil, errors.New("invalid EC point")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		x.FillBytes(point[1 : 1+size])
+		y.FillBytes(point[1+size:])
+		if _, err := checked.NewPublicKey(point); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
+
+// parseClaims validates a token signed with one of the keys.
+func (k *KeySet) parseClaims(tokenString string) (*tokenClaims, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods(publicKeyMethods)}
+	if k.opts.Issuer != "" {
+		options = append(options, jwt.WithIssuer(k.opts.Issuer))
+	}
+	claims := &tokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return k.key(kid)
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
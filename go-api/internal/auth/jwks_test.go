@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// identityProvider publishes a JWKS that tests can rotate.
+type identityProvider struct {
+	mu      sync.Mutex
+	keys    []map[string]string
+	fetches atomic.Int32
+}
+
+func (p *identityProvider) publish(keys ...map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = keys
+}
+
+func (p *identityProvider) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	p.fetches.Add(1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": p.keys})
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func rsaJWK(t *testing.T, kid string) (*rsa.PrivateKey, map[string]string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	return key, map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": encodeInt(key.N), "e": encodeInt(big.NewInt(int64(key.E)))}
+}
+
+func signWithKey(t *testing.T, method jwt.SigningMethod, key interface{}, kid, issuer, subject string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    issuer,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestCredentialsVerifyTokensWithTheJWKS(t *testing.T) {
+	provider := &identityProvider{}
+	server := httptest.NewServer(provider)
+	defer server.Close()
+
+	rsaKey, rsaPublic := rsaJWK(t, "rsa-1")
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	ecPublic := map[string]string{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encodeInt(ecKey.X), "y": encodeInt(ecKey.Y)}
+	provider.publish(rsaPublic, ecPublic, map[string]string{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"})
+
+	opts := DefaultKeySetOptions()
+	opts.Issuer = "https://idp.example.com/"
+	keys := NewKeySetWithOptions(server.URL, opts)
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	creds := NewCredentials("", "secret")
+	creds.SetKeySet(keys)
+
+	for _, token := range []string{
+		signWithKey(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", opts.Issuer, "user-1"),
+		signWithKey(t, jwt.SigningMethodES256, ecKey, "ec-1", opts.Issuer, "user-1"),
+		signToken(t, "secret", "user-1"),
+	} {
+		if userID, err := creds.Authenticate(token); err != nil || userID != "user-1" {
+			t.Fatalf("expected the token to be accepted, got %q (%v)", userID, err)
+		}
+	}
+	for name, token := range map[string]string{
+		"wrong issuer":   signWithKey(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", "https://other.example.com/", "user-1"),
+		"wrong key":      signWithKey(t, jwt.SigningMethodRS256, rsaKey, "ec-1", opts.Issuer, "user-1"),
+		"symmetric key":  signWithKey(t, jwt.SigningMethodHS256, []byte("c2VjcmV0"), "hmac", opts.Issuer, "user-1"),
+		"unknown secret": signToken(t, "other-secret", "user-1"),
+	} {
+		if _, err := creds.Authenticate(token); err == nil {
+			t.Fatalf("expected the token with the %s to be rejected", name)
+		}
+	}
+	if got := provider.fetches.Load(); got != 1 {
+		t.Fatalf("expected the keys to be fetched once, got %d fetches", got)
+	}
+}
+
+func TestKeySetPicksUpRotatedKeys(t *testing.T) {
+	provider := &identityProvider{}
+	server := httptest.NewServer(provider)
+	defer server.Close()
+	oldKey, oldPublic := rsaJWK(t, "old")
+	newKey, newPublic := rsaJWK(t, "new")
+	provider.publish(oldPublic)
+
+	opts := DefaultKeySetOptions()
+	opts.MinRefreshInterval = 0
+	keys := NewKeySetWithOptions(server.URL, opts)
+	creds := NewCredentials("")
+	creds.SetKeySet(keys)
+	if _, err := creds.Authenticate(signWithKey(t, jwt.SigningMethodRS256, oldKey, "old", "", "user-1")); err != nil {
+		t.Fatalf("expected the first token to fetch the keys, got %v", err)
+	}
+
+	// A token signed with a key published since triggers a fetch.
+	provider.publish(oldPublic, newPublic)
+	if _, err := creds.Authenticate(signWithKey(t, jwt.SigningMethodRS256, newKey, "new", "", "user-1")); err != nil {
+		t.Fatalf("expected the rotated key to be fetched, got %v", err)
+	}
+
+	// Unknown key IDs are looked up at most once per MinRefreshInterval.
+	keys.opts.MinRefreshInterval = time.Hour
+	fetches := provider.fetches.Load()
+	for i := 0; i < 3; i++ {
+		if _, err := creds.Authenticate(signWithKey(t, jwt.SigningMethodRS256, newKey, "forged", "", "user-1")); err == nil {
+			t.Fatal("expected an unknown key ID to be rejected")
+		}
+	}
+	if got := provider.fetches.Load(); got != fetches {
+		t.Fatalf("expected no fetches for unknown key IDs, got %d", got-fetches)
+	}
+}
@@ -3,17 +3,31 @@ package auth
 import (
 	"context"
 	"errors"
-	"net/http"
+	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/logging"
 )
 
 type contextKey string
 
-const userIDKey contextKey = "authUserID"
+const (
+	userIDKey   contextKey = "authUserID"
+	tenantIDKey contextKey = "authTenantID"
+	rolesKey    contextKey = "authRoles"
+	scopesKey   contextKey = "authScopes"
+)
+
+// ScopeVerifyWrite is the scope routes that change data require, such as POST
+// /verify. Tokens limited to other scopes can only read.
+const ScopeVerifyWrite = "verify:write"
 
 // GetUserID retrieves the authenticated subject from context.
 func GetUserID(ctx context.Context) (string, bool) {
@@ -26,60 +40,374 @@ func GetUserID(ctx context.Context) (string, bool) {
 	return "", false
 }
 
+// GetTenantID retrieves the tenant of the authenticated subject from context. It is
+// absent for tokens without a tenant claim.
+func GetTenantID(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	if value, ok := ctx.Value(tenantIDKey).(string); ok && value != "" {
+		return value, true
+	}
+	return "", false
+}
+
+// GetRoles retrieves the roles of the authenticated subject from context. It is
+// empty for tokens without a roles claim.
+func GetRoles(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	roles, _ := ctx.Value(rolesKey).([]string)
+	return roles
+}
+
+// HasRole reports whether the authenticated subject was granted role.
+func HasRole(ctx context.Context, role string) bool {
+	for _, granted := range GetRoles(ctx) {
+		if granted == role {
+			return true
+		}
+	}
+	return false
+}
+
+// GetScopes retrieves the scopes the token of the authenticated subject is limited
+// to from context. limited is false for tokens without a scope claim, which may use
+// every scope.
+func GetScopes(ctx context.Context) (scopes []string, limited bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	scopes, limited = ctx.Value(scopesKey).([]string)
+	return scopes, limited
+}
+
+// HasScope reports whether the token of the authenticated subject may use scope.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, limited := GetScopes(ctx)
+	if !limited {
+		return true
+	}
+	for _, granted := range scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Identity is who a token was issued to.
+type Identity struct {
+	UserID string
+	// TenantID is empty for tokens without a tenant claim.
+	TenantID string
+	// Roles are empty for tokens without a roles claim.
+	Roles []string
+	// Scopes limits what the token may do. It is nil for tokens without a scope
+	// claim, which may do everything unless the credentials require scopes.
+	Scopes []string
+}
+
+// WithContext returns a context carrying the identity.
+func (i Identity) WithContext(ctx context.Context) context.Context {
+	ctx = WithUserID(ctx, i.UserID)
+	if i.TenantID != "" {
+		ctx = WithTenantID(ctx, i.TenantID)
+	}
+	if len(i.Roles) > 0 {
+		ctx = WithRoles(ctx, i.Roles...)
+	}
+	if i.Scopes != nil {
+		ctx = WithScopes(ctx, i.Scopes...)
+	}
+	return ctx
+}
+
+// Credentials holds the keys and audience used to validate tokens. It is safe for
+// concurrent use, so secrets can be rotated while requests are being served.
+type Credentials struct {
+	mu          sync.RWMutex
+	secrets     []string
+	keys        *KeySet
+	audience    string
+	revocations *Revocations
+	// requireScopes limits tokens without a scope claim to no scope.
+	requireScopes bool
+}
+
+// NewCredentials builds a credential set. The first secret is the current one; any
+// additional secrets are still accepted so tokens signed before a rotation stay valid.
+func NewCredentials(audience string, secrets ...string) *Credentials {
+	c := &Credentials{}
+	c.Update(audience, secrets...)
+	return c
+}
+
+// Update replaces the accepted secrets and expected audience.
+func (c *Credentials) Update(audience string, secrets ...string) {
+	trimmed := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			trimmed = append(trimmed, secret)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.secrets = trimmed
+	c.audience = strings.TrimSpace(audience)
+}
+
+// SetKeySet also accepts RSA and ECDSA signed tokens verified with keys. HMAC signed
+// tokens are still verified with the secrets.
+func (c *Credentials) SetKeySet(keys *KeySet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys = keys
+}
+
+// SetRevocations refuses tokens revoked in revocations. When the list cannot be
+// read, tokens with an ID are refused too, with ErrRevocationCheckFailed, as one of
+// them may have been revoked.
+func (c *Credentials) SetRevocations(revocations *Revocations) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.revocations = revocations
+}
+
+// SetRequireScopes limits tokens without a scope claim to no scope when require is
+// set, so they can only read. Otherwise they may use every scope.
+func (c *Credentials) SetRequireScopes(require bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requireScopes = require
+}
+
+func (c *Credentials) snapshot() ([]string, *KeySet, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.secrets, c.keys, c.audience
+}
+
+// ErrRevocationCheckFailed reports a token refused because the revocation list could
+// not be read. Retrying may help.
+var ErrRevocationCheckFailed = errors.New("token revocation could not be checked")
+
+// revocationRetryAfter is the Retry-After, in seconds, of requests refused while the
+// revocation list cannot be read.
+const revocationRetryAfter = "5"
+
 // JWTMiddleware validates bearer tokens and injects user identity.
 func JWTMiddleware(secret, audience string) gin.HandlerFunc {
-	secret = strings.TrimSpace(secret)
-	audience = strings.TrimSpace(audience)
+	return JWTMiddlewareWithCredentials(NewCredentials(audience, secret))
+}
 
+// JWTMiddlewareWithCredentials validates bearer tokens against a rotatable credential set.
+func JWTMiddlewareWithCredentials(creds *Credentials) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString, err := extractBearerToken(c.Request.Header.Get("Authorization"))
+		tokenString, err := BearerToken(c.Request.Header.Get("Authorization"))
 		if err != nil {
 			unauthorized(c, err.Error())
 			return
 		}
 
-		if secret == "" {
-			secret = strings.TrimSpace(os.Getenv("JWT_SECRET"))
+		identity, err := creds.IdentifyContext(c.Request.Context(), tokenString)
+		if errors.Is(err, ErrRevocationCheckFailed) {
+			logging.FromContext(c.Request.Context(), zap.L()).Error("refused token, failed to check its revocation", zap.Error(err))
+			c.Header("Retry-After", revocationRetryAfter)
+			httperr.Write(c, httperr.CodeUnavailable, ErrRevocationCheckFailed.Error())
+			return
 		}
-		if secret == "" {
-			unauthorized(c, "missing JWT secret")
+		if err != nil {
+			unauthorized(c, err.Error())
 			return
 		}
 
-		claims := &jwt.RegisteredClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, errors.New("unexpected signing method")
-			}
-			return []byte(secret), nil
-		})
-		if err != nil || !token.Valid {
-			unauthorized(c, "invalid token")
+		c.Request = c.Request.WithContext(identity.WithContext(c.Request.Context()))
+		c.Set(string(userIDKey), identity.UserID)
+
+		c.Next()
+	}
+}
+
+// RequireScope rejects requests whose token may not use scope with 403. It must run
+// after the authentication middleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasScope(c.Request.Context(), scope) {
+			httperr.Write(c, httperr.CodeForbidden, "the "+scope+" scope is required")
 			return
 		}
+		c.Next()
+	}
+}
 
-		if audience == "" {
-			audience = strings.TrimSpace(os.Getenv("JWT_AUDIENCE"))
-		}
-		if audience != "" && !containsAudience(claims.Audience, audience) {
-			unauthorized(c, "invalid audience")
+// RequireRole rejects requests whose token does not grant role with 403. It must
+// run after the authentication middleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(c.Request.Context(), role) {
+			httperr.Write(c, httperr.CodeForbidden, "the "+role+" role is required")
 			return
 		}
+		c.Next()
+	}
+}
 
-		if claims.Subject == "" {
-			unauthorized(c, "missing subject")
-			return
+// Authenticate validates a token and returns its subject. Errors are those of
+// Identify.
+func (c *Credentials) Authenticate(tokenString string) (string, error) {
+	identity, err := c.Identify(tokenString)
+	return identity.UserID, err
+}
+
+// Identify validates a token and returns its subject, tenant and roles. Errors are safe to
+// show to the caller, except those wrapping ErrRevocationCheckFailed, whose cause
+// should only be logged.
+func (c *Credentials) Identify(tokenString string) (Identity, error) {
+	return c.IdentifyContext(context.Background(), tokenString)
+}
+
+// IdentifyContext is Identify, also refusing revoked tokens when revocations are set.
+func (c *Credentials) IdentifyContext(ctx context.Context, tokenString string) (Identity, error) {
+	secrets, keys, audience := c.snapshot()
+	if len(secrets) == 0 {
+		if secret := strings.TrimSpace(os.Getenv("JWT_SECRET")); secret != "" {
+			secrets = []string{secret}
 		}
+	}
+	if len(secrets) == 0 && keys == nil {
+		return Identity{}, errors.New("missing JWT secret")
+	}
+
+	var claims *tokenClaims
+	var err error
+	if keys != nil && signedWithPublicKey(tokenString) {
+		claims, err = keys.parseClaims(tokenString)
+	} else if len(secrets) > 0 {
+		claims, err = parseClaims(tokenString, secrets)
+	} else {
+		err = errors.New("unexpected signing method")
+	}
+	if err != nil || claims.TokenUse == tokenUseRefresh {
+		return Identity{}, errors.New("invalid token")
+	}
 
-		ctx := context.WithValue(c.Request.Context(), userIDKey, claims.Subject)
-		c.Request = c.Request.WithContext(ctx)
-		c.Set(string(userIDKey), claims.Subject)
+	if audience == "" {
+		audience = strings.TrimSpace(os.Getenv("JWT_AUDIENCE"))
+	}
+	if audience != "" && !containsAudience(claims.Audience, audience) {
+		return Identity{}, errors.New("invalid audience")
+	}
 
-		c.Next()
+	if claims.Subject == "" {
+		return Identity{}, errors.New("missing subject")
+	}
+	c.mu.RLock()
+	revocations, requireScopes := c.revocations, c.requireScopes
+	c.mu.RUnlock()
+	if revocations != nil && claims.ID != "" {
+		revoked, err := revocations.IsRevoked(ctx, claims.ID)
+		if err != nil {
+			return Identity{}, fmt.Errorf("%w: %v", ErrRevocationCheckFailed, err)
+		}
+		if revoked {
+			return Identity{}, errors.New("token revoked")
+		}
+	}
+	identity := Identity{UserID: claims.Subject, TenantID: strings.TrimSpace(claims.Tenant)}
+	for _, role := range claims.Roles {
+		if role = strings.TrimSpace(role); role != "" {
+			identity.Roles = append(identity.Roles, role)
+		}
+	}
+	if claims.Scope != "" || claims.Scopes != nil || requireScopes {
+		identity.Scopes = append([]string{}, strings.Fields(claims.Scope)...)
+		for _, scope := range claims.Scopes {
+			identity.Scopes = append(identity.Scopes, strings.Fields(scope)...)
+		}
+	}
+	return identity, nil
+}
+
+// WithUserID returns a context carrying an authenticated subject.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// WithTenantID returns a context carrying the tenant of the authenticated subject.
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDKey, tenantID)
+}
+
+// WithRoles returns a context carrying the roles of the authenticated subject.
+func WithRoles(ctx context.Context, roles ...string) context.Context {
+	return context.WithValue(ctx, rolesKey, roles)
+}
+
+// WithScopes returns a context whose token is limited to scopes.
+func WithScopes(ctx context.Context, scopes ...string) context.Context {
+	return context.WithValue(ctx, scopesKey, append([]string{}, scopes...))
+}
+
+// tokenClaims are the registered claims plus the private "tenant" claim naming the
+// tenant a subject belongs to and the "roles" claim, a string or an array of
+// strings, naming the roles it was granted. The scopes of a token are read from the
+// OAuth "scope" claim, space-separated, and the "scp" claim some identity providers
+// use instead. Tokens signed by an Issuer also name the client they were issued to,
+// and refresh tokens carry a "token_use" claim.
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Tenant   string           `json:"tenant,omitempty"`
+	Roles    jwt.ClaimStrings `json:"roles,omitempty"`
+	Scope    string           `json:"scope,omitempty"`
+	Scopes   jwt.ClaimStrings `json:"scp,omitempty"`
+	ClientID string           `json:"client_id,omitempty"`
+	TokenUse string           `json:"token_use,omitempty"`
+}
+
+// signedWithPublicKey reports whether the header of the token names an RSA or ECDSA
+// algorithm. The signature is verified later.
+func signedWithPublicKey(tokenString string) bool {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &tokenClaims{})
+	if err != nil {
+		return false
+	}
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		return true
+	}
+	return false
+}
+
+// parseClaims validates the token against each accepted secret in turn.
+func parseClaims(tokenString string, secrets []string) (*tokenClaims, error) {
+	var lastErr error
+	for _, secret := range secrets {
+		claims := &tokenClaims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+			return []byte(secret), nil
+		})
+		if err == nil && token.Valid {
+			return claims, nil
+		}
+		if err == nil {
+			err = errors.New("invalid token")
+		}
+		lastErr = err
+		if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			break
+		}
 	}
+	return nil, lastErr
 }
 
-func extractBearerToken(header string) (string, error) {
+// BearerToken extracts the token of an "Authorization: Bearer <token>" value.
+func BearerToken(header string) (string, error) {
 	if header == "" {
 		return "", errors.New("authorization header required")
 	}
@@ -95,7 +423,7 @@ func extractBearerToken(header string) (string, error) {
 }
 
 func unauthorized(c *gin.Context, message string) {
-	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+	httperr.Write(c, httperr.CodeUnauthorized, message)
 }
 
 func containsAudience(claims jwt.ClaimStrings, expected string) bool {
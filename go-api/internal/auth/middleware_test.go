@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWTMiddlewareAcceptsRotatedSecrets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	creds := NewCredentials("", "old-secret")
+	router := gin.New()
+	router.GET("/", JWTMiddlewareWithCredentials(creds), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	oldToken := signToken(t, "old-secret", "user-1")
+	if code := serve(router, oldToken); code != http.StatusOK {
+		t.Fatalf("expected old token to be accepted before rotation, got %d", code)
+	}
+
+	creds.Update("", "new-secret", "old-secret")
+	if code := serve(router, oldToken); code != http.StatusOK {
+		t.Fatalf("expected old token to be accepted during rotation, got %d", code)
+	}
+	if code := serve(router, signToken(t, "new-secret", "user-1")); code != http.StatusOK {
+		t.Fatalf("expected new token to be accepted, got %d", code)
+	}
+
+	creds.Update("", "new-secret")
+	if code := serve(router, oldToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected old token to be rejected after rotation, got %d", code)
+	}
+}
+
+func TestIdentifyReadsTheTenantClaim(t *testing.T) {
+	creds := NewCredentials("", "secret")
+
+	claims := jwt.MapClaims{"sub": "user-1", "tenant": " acme ", "exp": time.Now().Add(time.Hour).Unix()}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	identity, err := creds.Identify(token)
+	if err != nil || identity.UserID != "user-1" || identity.TenantID != "acme" {
+		t.Fatalf("unexpected identity %+v (%v)", identity, err)
+	}
+	ctx := identity.WithContext(context.Background())
+	if tenantID, ok := GetTenantID(ctx); !ok || tenantID != "acme" {
+		t.Fatalf("expected the tenant in the context, got %q", tenantID)
+	}
+
+	identity, err = creds.Identify(signToken(t, "secret", "user-1"))
+	if err != nil || identity.TenantID != "" {
+		t.Fatalf("expected no tenant without the claim, got %+v (%v)", identity, err)
+	}
+	if _, ok := GetTenantID(identity.WithContext(context.Background())); ok {
+		t.Fatal("expected no tenant in the context")
+	}
+}
+
+func TestRequireRoleChecksTheRolesClaim(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	creds := NewCredentials("", "secret")
+	router := gin.New()
+	router.GET("/", JWTMiddlewareWithCredentials(creds), RequireRole("admin"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	signRoles := func(roles interface{}) string {
+		claims := jwt.MapClaims{"sub": "user-1", "roles": roles, "exp": time.Now().Add(time.Hour).Unix()}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return token
+	}
+
+	for _, roles := range []interface{}{"admin", []string{"viewer", "admin"}} {
+		if code := serve(router, signRoles(roles)); code != http.StatusOK {
+			t.Fatalf("expected roles %v to be accepted, got %d", roles, code)
+		}
+	}
+	if code := serve(router, signRoles([]string{"viewer"})); code != http.StatusForbidden {
+		t.Fatalf("expected a token without the role to be forbidden, got %d", code)
+	}
+	if code := serve(router, signToken(t, "secret", "user-1")); code != http.StatusForbidden {
+		t.Fatalf("expected a token without roles to be forbidden, got %d", code)
+	}
+}
+
+func TestRequireScopeChecksTheScopeClaims(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	creds := NewCredentials("", "secret")
+	router := gin.New()
+	router.GET("/", JWTMiddlewareWithCredentials(creds), RequireScope(ScopeVerifyWrite), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	signScopes := func(claim string, scopes interface{}) string {
+		claims := jwt.MapClaims{"sub": "user-1", claim: scopes, "exp": time.Now().Add(time.Hour).Unix()}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return token
+	}
+
+	for _, token := range []string{
+		signScopes("scope", "verify:read verify:write"),
+		signScopes("scp", []string{"verify:read", "verify:write"}),
+		signToken(t, "secret", "user-1"),
+	} {
+		if code := serve(router, token); code != http.StatusOK {
+			t.Fatalf("expected the token to be accepted, got %d", code)
+		}
+	}
+	for _, token := range []string{signScopes("scope", "verify:read"), signScopes("scp", "verify:read"), signScopes("scope", " ")} {
+		if code := serve(router, token); code != http.StatusForbidden {
+			t.Fatalf("expected a read-only token to be forbidden, got %d", code)
+		}
+	}
+
+	creds.SetRequireScopes(true)
+	if code := serve(router, signToken(t, "secret", "user-1")); code != http.StatusForbidden {
+		t.Fatalf("expected a token without scopes to be forbidden once scopes are required, got %d", code)
+	}
+	if code := serve(router, signScopes("scope", "verify:write")); code != http.StatusOK {
+		t.Fatalf("expected a token with the scope to be accepted, got %d", code)
+	}
+}
+
+func serve(router *gin.Engine, token string) int {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp.Code
+}
+
+func signToken(t *testing.T, secret, subject string) string {
+	t.Helper()
+	claims := jwt.RegisteredClaims{
+		Subject:   subject,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
@@ -0,0 +1,19 @@
+package buildinfo
+
+import "testing"
+
+func TestGetPrefersLinkTimeValues(t *testing.T) {
+	defer func(version, commit, buildTime string) {
+		Version, Commit, BuildTime = version, commit, buildTime
+	}(Version, Commit, BuildTime)
+
+	Version, Commit, BuildTime = "v1.2.3", "abc123", "2024-01-02T03:04:05Z"
+
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "abc123" || info.BuildTime != "2024-01-02T03:04:05Z" {
+		t.Fatalf("expected link-time values, got %+v", info)
+	}
+	if info.GoVersion == "" {
+		t.Fatal("expected the Go version to be reported")
+	}
+}
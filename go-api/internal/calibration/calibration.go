@@ -0,0 +1,45 @@
+// Package calibration maps the raw scores of each model version onto a common scale,
+// so thresholds and historical metrics keep their meaning when the processor swaps
+// in a new model.
+package calibration
+
+// Point maps a raw score of the model to its calibrated score.
+type Point struct {
+	Raw        float32
+	Calibrated float32
+}
+
+// Curve calibrates scores by linear interpolation between its points, which are
+// ordered by increasing Raw. Scores outside the curve take the calibrated score of
+// the nearest point. An empty curve leaves scores unchanged.
+type Curve []Point
+
+// Apply returns the calibrated score of raw.
+func (c Curve) Apply(raw float32) float32 {
+	if len(c) == 0 {
+		return raw
+	}
+	if raw <= c[0].Raw {
+		return c[0].Calibrated
+	}
+	for i := 1; i < len(c); i++ {
+		low, high := c[i-1], c[i]
+		if raw > high.Raw {
+			continue
+		}
+		if high.Raw == low.Raw {
+			return high.Calibrated
+		}
+		return low.Calibrated + (raw-low.Raw)/(high.Raw-low.Raw)*(high.Calibrated-low.Calibrated)
+	}
+	return c[len(c)-1].Calibrated
+}
+
+// Curves holds the curve of each model version.
+type Curves map[string]Curve
+
+// Apply returns the calibrated score of a raw score produced by modelVersion. Scores
+// of versions without a curve are returned unchanged.
+func (c Curves) Apply(modelVersion string, raw float32) float32 {
+	return c[modelVersion].Apply(raw)
+}
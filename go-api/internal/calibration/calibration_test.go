@@ -0,0 +1,35 @@
+package calibration
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCurvesInterpolateAndClamp(t *testing.T) {
+	curves := Curves{
+		"2": {{Raw: 0.2, Calibrated: 0}, {Raw: 0.6, Calibrated: 0.5}, {Raw: 0.8, Calibrated: 1}},
+	}
+	for _, tc := range []struct {
+		version string
+		raw     float32
+		want    float32
+	}{
+		{"2", 0.1, 0},
+		{"2", 0.4, 0.25},
+		{"2", 0.6, 0.5},
+		{"2", 0.7, 0.75},
+		{"2", 0.9, 1},
+		// Versions without a curve keep their raw scores.
+		{"1", 0.42, 0.42},
+		{"", 0.42, 0.42},
+	} {
+		if got := curves.Apply(tc.version, tc.raw); math.Abs(float64(got-tc.want)) > 1e-6 {
+			t.Fatalf("Apply(%q, %v) = %v, want %v", tc.version, tc.raw, got, tc.want)
+		}
+	}
+
+	var none Curves
+	if got := none.Apply("2", 0.3); got != 0.3 {
+		t.Fatalf("expected no curves to leave scores unchanged, got %v", got)
+	}
+}
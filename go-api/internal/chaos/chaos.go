@@ -0,0 +1,207 @@
+// Package chaos injects latency and errors into the dependencies of the service at
+// configurable rates, to check that retries, failover and load shedding engage
+// before a real incident does. It is wired in only by 'serve -dev -chaos' and by
+// tests; production builds never enable it.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Targets that rules apply to.
+const (
+	// TargetRepository is every query sent through a database instrumented with
+	// Injector.Database.
+	TargetRepository = "repository"
+	// TargetCache is the verification result cache.
+	TargetCache = "cache"
+	// TargetProcessor is the image processor client.
+	TargetProcessor = "processor"
+)
+
+// Kind is the fault a rule injects.
+type Kind string
+
+const (
+	// Latency delays the call by Rule.Delay and then lets it run.
+	Latency Kind = "latency"
+	// Timeout fails the call with an error callers treat as transient.
+	Timeout Kind = "timeout"
+	// Unavailable fails the call as if the dependency refused connections.
+	Unavailable Kind = "unavailable"
+)
+
+// ErrInvalidRule is returned for rules that cannot be parsed or make no sense.
+var ErrInvalidRule = errors.New("invalid chaos rule")
+
+// Rule injects one kind of fault into a share of the calls to a target.
+type Rule struct {
+	Target string
+	Kind   Kind
+	// Rate is the share of calls hit, from 0 to 1.
+	Rate float64
+	// Delay is how long Latency rules hold a call.
+	Delay time.Duration
+	// Times stops the rule after it hit that many calls; zero never stops it.
+	Times int
+}
+
+func (r Rule) validate() error {
+	switch {
+	case r.Target != TargetRepository && r.Target != TargetCache && r.Target != TargetProcessor:
+		return fmt.Errorf("%w: unknown target %q", ErrInvalidRule, r.Target)
+	case r.Kind != Latency && r.Kind != Timeout && r.Kind != Unavailable:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidRule, r.Kind)
+	case r.Rate <= 0 || r.Rate > 1:
+		return fmt.Errorf("%w: rate must be above 0 and at most 1", ErrInvalidRule)
+	case r.Kind == Latency && r.Delay <= 0:
+		return fmt.Errorf("%w: latency rules need a positive delay", ErrInvalidRule)
+	case r.Times < 0:
+		return fmt.Errorf("%w: times must not be negative", ErrInvalidRule)
+	}
+	return nil
+}
+
+// ParseRules parses a comma-separated list of target:kind:rate[:delay] rules, e.g.
+// "processor:latency:0.2:500ms,repository:timeout:0.05,cache:unavailable:0.01".
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		parts := strings.Split(field, ":")
+		if len(parts) < 3 || len(parts) > 4 {
+			return nil, fmt.Errorf("%w: %q is not target:kind:rate[:delay]", ErrInvalidRule, field)
+		}
+		rule := Rule{Target: parts[0], Kind: Kind(parts[1])}
+		rate, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: rate of %q: %v", ErrInvalidRule, field, err)
+		}
+		rule.Rate = rate
+		if len(parts) == 4 {
+			if rule.Delay, err = time.ParseDuration(parts[3]); err != nil {
+				return nil, fmt.Errorf("%w: delay of %q: %v", ErrInvalidRule, field, err)
+			}
+		}
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Injector decides which calls are hit by its rules. It is safe for concurrent
+// use.
+type Injector struct {
+	mu       sync.Mutex
+	rules    []Rule
+	hits     []int
+	injected map[string]int64
+}
+
+// New returns an injector applying rules, which must be valid.
+func New(rules ...Rule) (*Injector, error) {
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &Injector{rules: rules, hits: make([]int, len(rules)), injected: make(map[string]int64)}, nil
+}
+
+// Inject applies the rules of target to one call: it sleeps for the latency rules
+// that hit it and returns the error of the first failing rule that does. It returns
+// early with ctx's error when ctx ends while sleeping.
+func (i *Injector) Inject(ctx context.Context, target string) error {
+	var delay time.Duration
+	var err error
+	i.mu.Lock()
+	for n, rule := range i.rules {
+		if rule.Target != target || (rule.Times > 0 && i.hits[n] >= rule.Times) || rand.Float64() >= rule.Rate {
+			continue
+		}
+		if rule.Kind != Latency && err != nil {
+			continue
+		}
+		i.hits[n]++
+		i.injected[target]++
+		if rule.Kind == Latency {
+			delay += rule.Delay
+		} else {
+			err = &injectedError{target: target, kind: rule.Kind}
+		}
+	}
+	i.mu.Unlock()
+
+	if delay > 0 {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+// Injected returns how many faults were injected into calls to target so far.
+func (i *Injector) Injected(target string) int64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.injected[target]
+}
+
+func (i *Injector) targets(target string) bool {
+	for _, rule := range i.rules {
+		if rule.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// injectedError is returned by injected faults. It satisfies net.Error and carries
+// a gRPC status, so callers classify it like the network failure it stands for.
+type injectedError struct {
+	target string
+	kind   Kind
+}
+
+func (e *injectedError) Error() string {
+	return fmt.Sprintf("chaos: injected %s of %s", e.kind, e.target)
+}
+
+func (e *injectedError) Timeout() bool { return e.kind == Timeout }
+
+func (e *injectedError) Temporary() bool { return e.kind == Timeout }
+
+func (e *injectedError) GRPCStatus() *status.Status {
+	if e.kind == Timeout {
+		return status.New(codes.DeadlineExceeded, e.Error())
+	}
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// IsInjected reports whether err was injected by an Injector.
+func IsInjected(err error) bool {
+	var injected *injectedError
+	return errors.As(err, &injected)
+}
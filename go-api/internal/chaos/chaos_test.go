@@ -0,0 +1,86 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("processor:latency:0.2:500ms, repository:timeout:1,cache:unavailable:0.01")
+	if err != nil {
+		t.Fatalf("ParseRules returned error: %v", err)
+	}
+	want := []Rule{
+		{Target: TargetProcessor, Kind: Latency, Rate: 0.2, Delay: 500 * time.Millisecond},
+		{Target: TargetRepository, Kind: Timeout, Rate: 1},
+		{Target: TargetCache, Kind: Unavailable, Rate: 0.01},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("expected %d rules, got %+v", len(want), rules)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Fatalf("rule %d: expected %+v, got %+v", i, want[i], rules[i])
+		}
+	}
+
+	for _, spec := range []string{
+		"processor:latency:0.2",
+		"queue:timeout:1",
+		"cache:slow:1",
+		"cache:timeout:0",
+		"cache:timeout:1.5",
+		"cache:timeout",
+		"cache:latency:1:soon",
+	} {
+		if _, err := ParseRules(spec); !errors.Is(err, ErrInvalidRule) {
+			t.Fatalf("expected ErrInvalidRule for %q, got %v", spec, err)
+		}
+	}
+}
+
+func TestInjectorFaultsLookLikeNetworkFailures(t *testing.T) {
+	ctx := context.Background()
+	injector, err := New(
+		Rule{Target: TargetCache, Kind: Timeout, Rate: 1, Times: 2},
+		Rule{Target: TargetProcessor, Kind: Unavailable, Rate: 1},
+		Rule{Target: TargetProcessor, Kind: Latency, Rate: 1, Delay: time.Hour},
+	)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err := injector.Inject(ctx, TargetCache)
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() || !IsInjected(err) {
+			t.Fatalf("expected an injected timeout, got %v", err)
+		}
+	}
+	if err := injector.Inject(ctx, TargetCache); err != nil {
+		t.Fatalf("expected the rule to stop after two hits, got %v", err)
+	}
+	if got := injector.Injected(TargetCache); got != 2 {
+		t.Fatalf("expected 2 injected faults, got %d", got)
+	}
+
+	// The delay is cut short by the caller's deadline.
+	deadline, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := injector.Inject(deadline, TargetProcessor); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to end the delay, got %v", err)
+	}
+	injector.rules = injector.rules[:2]
+	if err := injector.Inject(ctx, TargetProcessor); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected an Unavailable status, got %v", err)
+	}
+	if err := injector.Inject(ctx, TargetRepository); err != nil {
+		t.Fatalf("expected no fault without repository rules, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/adaptive"
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/logbuffer"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/worker"
+)
+
+// The tests below run the service's own resilience mechanisms against injected
+// faults and check that each of them engages.
+
+func openDatabase(t *testing.T) (*gorm.DB, *repository.VerificationRepository) {
+	t.Helper()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepositoryWithRetry(db, zap.NewNop(), repository.RetryPolicy{
+		Attempts:       3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	})
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	return db, repo
+}
+
+func newInjector(t *testing.T, rules ...Rule) *Injector {
+	t.Helper()
+	injector, err := New(rules...)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	return injector
+}
+
+func newUseCase(t *testing.T, repo usecase.VerificationRepository, injector *Injector) (*usecase.VerificationUseCase, *worker.Queue) {
+	t.Helper()
+	server, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	opts := usecase.DefaultOptions()
+	opts.RetryAttempts = 5
+	opts.RetryBudget = 2
+	opts.InitialBackoff = time.Millisecond
+	opts.MaxBackoff = time.Millisecond
+	cache := injector.Cache(usecase.NewRedisCache(client))
+	processor := injector.Processor(devmode.Processor{})
+	return usecase.NewVerificationUseCaseWithOptions(repo, cache, processor, zap.NewNop(), opts), worker.NewQueue(client)
+}
+
+func TestRepositoryRetriesInjectedTimeouts(t *testing.T) {
+	ctx := context.Background()
+	db, repo := openDatabase(t)
+	injector := newInjector(t, Rule{Target: TargetRepository, Kind: Timeout, Rate: 1, Times: 2})
+	if err := injector.Database(db); err != nil {
+		t.Fatalf("Database returned error: %v", err)
+	}
+
+	if err := repo.SaveLog(ctx, &repository.VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash-1"}); err != nil {
+		t.Fatalf("expected the save to succeed on its third attempt, got %v", err)
+	}
+	if got := injector.Injected(TargetRepository); got != 2 {
+		t.Fatalf("expected 2 injected timeouts, got %d", got)
+	}
+}
+
+func TestCacheRetriesStopAtTheBudgetAndFallBackToTheDatabase(t *testing.T) {
+	ctx := context.Background()
+	_, repo := openDatabase(t)
+	if err := repo.SaveLog(ctx, &repository.VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash-1", Score: 0.7}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	injector := newInjector(t, Rule{Target: TargetCache, Kind: Timeout, Rate: 1})
+	uc, _ := newUseCase(t, repo, injector)
+
+	log, err := uc.GetResult(ctx, "user-1", "req-1")
+	if err != nil || log.Score != 0.7 {
+		t.Fatalf("expected the result from the database, got %+v (%v)", log, err)
+	}
+	// One attempt plus the two retries of the request's budget, although five
+	// attempts are allowed per operation.
+	if got := injector.Injected(TargetCache); got != 3 {
+		t.Fatalf("expected 3 cache calls, got %d", got)
+	}
+}
+
+func TestUnavailableDatabaseBuffersVerificationLogs(t *testing.T) {
+	ctx := context.Background()
+	db, repo := openDatabase(t)
+	injector := newInjector(t, Rule{Target: TargetRepository, Kind: Unavailable, Rate: 1})
+	if err := injector.Database(db); err != nil {
+		t.Fatalf("Database returned error: %v", err)
+	}
+	uc, queue := newUseCase(t, repo, injector)
+	uc.SetLogBuffer(logbuffer.New(queue, repo, zap.NewNop()))
+
+	if _, _, _, err := uc.VerifyImage(ctx, "user-1", []byte("image")); err != nil {
+		t.Fatalf("expected the verification to succeed degraded, got %v", err)
+	}
+	if stats, err := queue.Stats(ctx); err != nil || stats.Ready != 1 {
+		t.Fatalf("expected the log to be buffered, got %+v (%v)", stats, err)
+	}
+	if injector.Injected(TargetRepository) == 0 {
+		t.Fatal("expected the save to hit the injected fault")
+	}
+}
+
+func TestUnavailableDatabaseFailsOverReadsToTheReplica(t *testing.T) {
+	ctx := context.Background()
+	db, repo := openDatabase(t)
+	replicaDB, replica := openDatabase(t)
+	if err := replica.SaveLog(ctx, &repository.VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash-1"}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	repo.SetReadReplica(replicaDB, time.Minute)
+	injector := newInjector(t, Rule{Target: TargetRepository, Kind: Unavailable, Rate: 1})
+	if err := injector.Database(db); err != nil {
+		t.Fatalf("Database returned error: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := repo.FindByRequestIDAndUser(ctx, "req-1", "user-1"); err != nil {
+			t.Fatalf("expected the replica to answer, got %v", err)
+		}
+	}
+	// Like an open circuit breaker, the failover stops trying the local database
+	// for the cooldown after its first failure.
+	if got := injector.Injected(TargetRepository); got != 1 {
+		t.Fatalf("expected the local database to be tried once, got %d", got)
+	}
+}
+
+func TestProcessorFaultsLowerTheAdaptiveLimit(t *testing.T) {
+	ctx := context.Background()
+	_, repo := openDatabase(t)
+	injector := newInjector(t, Rule{Target: TargetProcessor, Kind: Unavailable, Rate: 1})
+	uc, _ := newUseCase(t, repo, injector)
+	settings := adaptive.DefaultSettings()
+	limiter, err := adaptive.NewLimiter(settings, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewLimiter returned error: %v", err)
+	}
+	uc.SetConcurrencyLimiter(limiter)
+
+	for i := 0; i < 5; i++ {
+		if _, _, _, err := uc.VerifyImage(ctx, "user-1", []byte("image")); !IsInjected(err) {
+			t.Fatalf("expected the injected fault, got %v", err)
+		}
+	}
+	if status := limiter.Status(); status.Limit >= settings.InitialLimit || status.LastDecrease.IsZero() {
+		t.Fatalf("expected the limit to be lowered, got %+v", status)
+	}
+}
@@ -0,0 +1,105 @@
+package chaos
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/imageprocessor"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+// Processor wraps client so its calls are subject to the processor rules. Without
+// such rules client is returned as is.
+func (i *Injector) Processor(client imageprocessor.Client) imageprocessor.Client {
+	if !i.targets(TargetProcessor) {
+		return client
+	}
+	return &faultyProcessor{Client: client, injector: i}
+}
+
+type faultyProcessor struct {
+	imageprocessor.Client
+	injector *Injector
+}
+
+func (p *faultyProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
+	if err := p.injector.Inject(ctx, TargetProcessor); err != nil {
+		return nil, err
+	}
+	return p.Client.Process(ctx, userID, imageBytes)
+}
+
+// ProcessStream implements imageprocessor.StreamClient, streaming when the wrapped
+// client does.
+func (p *faultyProcessor) ProcessStream(ctx context.Context, userID string, image io.Reader) (*imageprocessor.Result, error) {
+	if err := p.injector.Inject(ctx, TargetProcessor); err != nil {
+		return nil, err
+	}
+	return imageprocessor.ProcessReader(ctx, p.Client, userID, image)
+}
+
+// Cache wraps cache so its calls are subject to the cache rules. Without such rules
+// cache is returned as is.
+func (i *Injector) Cache(cache usecase.Cache) usecase.Cache {
+	if !i.targets(TargetCache) {
+		return cache
+	}
+	return &faultyCache{cache: cache, injector: i}
+}
+
+type faultyCache struct {
+	cache    usecase.Cache
+	injector *Injector
+}
+
+func (c *faultyCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if err := c.injector.Inject(ctx, TargetCache); err != nil {
+		return err
+	}
+	return c.cache.Set(ctx, key, value, expiration)
+}
+
+func (c *faultyCache) Get(ctx context.Context, key string) (string, error) {
+	if err := c.injector.Inject(ctx, TargetCache); err != nil {
+		return "", err
+	}
+	return c.cache.Get(ctx, key)
+}
+
+func (c *faultyCache) Delete(ctx context.Context, keys ...string) error {
+	if err := c.injector.Inject(ctx, TargetCache); err != nil {
+		return err
+	}
+	return c.cache.Delete(ctx, keys...)
+}
+
+// Database subjects every statement run through db to the repository rules. The
+// faults are injected below the repositories, so their own retries and read
+// replica failover see them like real database errors.
+func (i *Injector) Database(db *gorm.DB) error {
+	if !i.targets(TargetRepository) {
+		return nil
+	}
+	inject := func(tx *gorm.DB) {
+		if err := i.Inject(tx.Statement.Context, TargetRepository); err != nil {
+			tx.AddError(err) //nolint:errcheck
+		}
+	}
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("*").Register("chaos:create", inject),
+		callbacks.Query().Before("*").Register("chaos:query", inject),
+		callbacks.Update().Before("*").Register("chaos:update", inject),
+		callbacks.Delete().Before("*").Register("chaos:delete", inject),
+		callbacks.Row().Before("*").Register("chaos:row", inject),
+		callbacks.Raw().Before("*").Register("chaos:raw", inject),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,1530 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the full runtime configuration tree for the API.
+type Config struct {
+	HTTP          HTTPConfig          `yaml:"http"`
+	Admin         AdminConfig         `yaml:"admin"`
+	GRPC          GRPCConfig          `yaml:"grpc"`
+	Limits        LimitsConfig        `yaml:"limits"`
+	Startup       StartupConfig       `yaml:"startup"`
+	Database      DatabaseConfig      `yaml:"database"`
+	Region        RegionConfig        `yaml:"region"`
+	Redis         RedisConfig         `yaml:"redis"`
+	Processor     ProcessorConfig     `yaml:"processor"`
+	Experiment    ExperimentConfig    `yaml:"experiment"`
+	Auth          AuthConfig          `yaml:"auth"`
+	Verification  VerificationConfig  `yaml:"verification"`
+	Shutdown      ShutdownConfig      `yaml:"shutdown"`
+	Log           LogConfig           `yaml:"log"`
+	Reload        ReloadConfig        `yaml:"reload"`
+	Secrets       SecretsConfig       `yaml:"secrets"`
+	Worker        WorkerConfig        `yaml:"worker"`
+	Storage       StorageConfig       `yaml:"storage"`
+	MalwareScan   MalwareScanConfig   `yaml:"malware_scan"`
+	Preprocess    PreprocessConfig    `yaml:"preprocess"`
+	Users         UsersConfig         `yaml:"users"`
+	Webhooks      WebhooksConfig      `yaml:"webhooks"`
+	Tenants       TenantsConfig       `yaml:"tenants"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Metering      MeteringConfig      `yaml:"metering"`
+	Cron          CronConfig          `yaml:"cron"`
+	Warehouse     WarehouseConfig     `yaml:"warehouse"`
+	Events        EventsConfig        `yaml:"events"`
+	Tracing       TracingConfig       `yaml:"tracing"`
+	Health        HealthConfig        `yaml:"health"`
+}
+
+// HealthConfig bounds the dependency checks of the readiness endpoint.
+type HealthConfig struct {
+	// Timeout bounds each check without a timeout of its own.
+	Timeout          time.Duration `yaml:"timeout"`
+	DatabaseTimeout  time.Duration `yaml:"database_timeout"`
+	RedisTimeout     time.Duration `yaml:"redis_timeout"`
+	ProcessorTimeout time.Duration `yaml:"processor_timeout"`
+}
+
+// TracingConfig exports traces of requests across the HTTP API, the database,
+// Redis and the image processor to an OpenTelemetry collector over OTLP/HTTP.
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Endpoint is the base URL of the collector's OTLP/HTTP receiver; spans are
+	// posted to its /v1/traces.
+	Endpoint string `yaml:"endpoint"`
+	// Headers are "name=value" pairs sent with every export.
+	Headers     []string `yaml:"headers"`
+	ServiceName string   `yaml:"service_name"`
+	// SampleRatio is the share of new traces recorded, from 0 to 1. Traces
+	// continued from a caller follow the caller's decision.
+	SampleRatio float64 `yaml:"sample_ratio"`
+	// ExportInterval is how long ended spans wait to be exported in a batch.
+	ExportInterval time.Duration `yaml:"export_interval"`
+}
+
+// CronConfig schedules maintenance tasks. Every serve and worker process runs the
+// scheduler, and a lease in Redis elects the one that fires tasks. Tasks enqueue
+// worker jobs, so a worker must run.
+type CronConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// LeaseTTL is how long tasks pause when the elected process dies.
+	LeaseTTL    time.Duration     `yaml:"lease_ttl"`
+	PurgeLogs   PurgeLogsConfig   `yaml:"purge_logs"`
+	PurgeErased PurgeErasedConfig `yaml:"purge_erased"`
+}
+
+// PurgeLogsConfig deletes old verification logs on a schedule.
+type PurgeLogsConfig struct {
+	// Schedule is a five-field cron expression in UTC, "@every <duration>" or one of
+	// "@hourly", "@daily", "@weekly" and "@monthly".
+	Schedule string `yaml:"schedule"`
+	// Retention is the age after which logs are deleted; 0 disables the task.
+	Retention time.Duration `yaml:"retention"`
+	BatchSize int           `yaml:"batch_size"`
+}
+
+// PurgeErasedConfig deletes the verification logs erased through DELETE /me/data,
+// with their images, on a schedule.
+type PurgeErasedConfig struct {
+	// Schedule is in the format of PurgeLogsConfig.Schedule; empty disables the task.
+	Schedule string `yaml:"schedule"`
+	// Grace is how long erased logs are kept before they are purged, so an erasure
+	// made in error can still be undone by hand.
+	Grace     time.Duration `yaml:"grace"`
+	BatchSize int           `yaml:"batch_size"`
+}
+
+// WarehouseConfig copies verification logs to an analytics warehouse on a schedule,
+// in incremental batches run by the background worker.
+type WarehouseConfig struct {
+	// Sink is "bigquery", "snowflake" or "clickhouse"; empty disables the export.
+	Sink string `yaml:"sink"`
+	// Table is created, and extended with new columns, by the exporter.
+	Table string `yaml:"table"`
+	// Schedule is a cron expression in UTC or "@every <duration>".
+	Schedule string `yaml:"schedule"`
+	// BatchSize is the number of logs per insert, and MaxBatches the inserts per run.
+	BatchSize  int `yaml:"batch_size"`
+	MaxBatches int `yaml:"max_batches"`
+	// Lag holds back logs younger than it, so logs committed late are not skipped.
+	Lag time.Duration `yaml:"lag"`
+	// Timeout bounds each request to the warehouse.
+	Timeout    time.Duration    `yaml:"timeout"`
+	BigQuery   BigQueryConfig   `yaml:"bigquery"`
+	Snowflake  SnowflakeConfig  `yaml:"snowflake"`
+	ClickHouse ClickHouseConfig `yaml:"clickhouse"`
+}
+
+// BigQueryConfig locates the BigQuery dataset and the service account key used to
+// write to it.
+type BigQueryConfig struct {
+	Project         string `yaml:"project"`
+	Dataset         string `yaml:"dataset"`
+	CredentialsFile string `yaml:"credentials_file"`
+}
+
+// SnowflakeConfig connects to Snowflake with key pair authentication.
+type SnowflakeConfig struct {
+	// Account is the account identifier, e.g. "myorg-myaccount".
+	Account        string `yaml:"account"`
+	User           string `yaml:"user"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	Database       string `yaml:"database"`
+	Schema         string `yaml:"schema"`
+	Warehouse      string `yaml:"warehouse"`
+	Role           string `yaml:"role"`
+}
+
+// ClickHouseConfig connects to the ClickHouse HTTP interface.
+type ClickHouseConfig struct {
+	URL      string `yaml:"url"`
+	Database string `yaml:"database"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// EventsConfig streams verification events to Kafka or NATS. Events are queued as
+// worker jobs, so a worker must run.
+type EventsConfig struct {
+	// Broker is "kafka", "nats" or "redis"; empty disables streaming.
+	Broker string `yaml:"broker"`
+	// Format is "json" or "avro".
+	Format string `yaml:"format"`
+	// Timeout bounds each publish.
+	Timeout time.Duration     `yaml:"timeout"`
+	Kafka   KafkaConfig       `yaml:"kafka"`
+	NATS    NATSConfig        `yaml:"nats"`
+	Redis   RedisStreamConfig `yaml:"redis"`
+	Outbox  OutboxConfig      `yaml:"outbox"`
+}
+
+// RedisStreamConfig appends events to a stream in the service's Redis.
+type RedisStreamConfig struct {
+	Stream string `yaml:"stream"`
+	// MaxLen trims the stream to about this many entries; 0 keeps every entry.
+	MaxLen int64 `yaml:"max_len"`
+}
+
+// OutboxConfig saves verification events in the database, in the transaction that
+// saves the verification, and relays them to the publishers from there, so an event
+// is never lost once its verification is stored.
+type OutboxConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// PollInterval is how often each instance looks for events to relay.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// BatchSize is how many events one poll relays at most.
+	BatchSize int `yaml:"batch_size"`
+	// Retention is how long relayed events are kept before they are deleted.
+	Retention time.Duration `yaml:"retention"`
+}
+
+// KafkaConfig produces to a topic through a Kafka REST proxy.
+type KafkaConfig struct {
+	RESTURL  string `yaml:"rest_url"`
+	Topic    string `yaml:"topic"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// NATSConfig publishes to NATS subjects named <subject>.<event type>.
+type NATSConfig struct {
+	URL      string `yaml:"url"`
+	Subject  string `yaml:"subject"`
+	Token    string `yaml:"token"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// JetStream waits for a stream to acknowledge every event.
+	JetStream bool `yaml:"jetstream"`
+}
+
+// MeteringConfig counts billable verification units per user and month, and
+// optionally reports them to Stripe metered billing through the background worker.
+type MeteringConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// UnitsPerVerification is how many units each completed verification costs.
+	UnitsPerVerification int64        `yaml:"units_per_verification"`
+	Stripe               StripeConfig `yaml:"stripe"`
+}
+
+// StripeConfig reports usage as Stripe billing meter events. Reporting is off until
+// an API key is set.
+type StripeConfig struct {
+	APIKey string `yaml:"api_key"`
+	// MeterEventName is the event name of the Stripe meter to report to.
+	MeterEventName string `yaml:"meter_event_name"`
+	// ReportInterval is how often unreported units are sent.
+	ReportInterval time.Duration `yaml:"report_interval"`
+}
+
+// NotificationsConfig alerts operators through Slack and/or email. Alerting is off
+// until at least one destination is configured.
+type NotificationsConfig struct {
+	Slack SlackConfig `yaml:"slack"`
+	SMTP  SMTPConfig  `yaml:"smtp"`
+	// ConsecutiveFailures alerts when a user's last N verifications failed; 0 disables it.
+	ConsecutiveFailures int             `yaml:"consecutive_failures"`
+	ErrorRate           ErrorRateConfig `yaml:"error_rate"`
+	// Cooldown is the minimum time between two alerts of the same kind.
+	Cooldown time.Duration `yaml:"cooldown"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// Templates overrides the Go text/template of a rule's message, keyed by
+	// "consecutive_failures" or "error_rate". The first line is the email subject.
+	Templates map[string]string `yaml:"templates"`
+}
+
+// SlackConfig posts alerts to an incoming webhook.
+type SlackConfig struct {
+	WebhookURL string `yaml:"webhook_url"`
+}
+
+// SMTPConfig emails alerts.
+type SMTPConfig struct {
+	// Addr is host:port of the mail server; empty disables email.
+	Addr     string   `yaml:"addr"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// ErrorRateConfig alerts when too many image processor calls fail.
+type ErrorRateConfig struct {
+	// Threshold is the failing fraction that raises an alert; 0 disables the rule.
+	Threshold   float64       `yaml:"threshold"`
+	Window      time.Duration `yaml:"window"`
+	MinRequests int           `yaml:"min_requests"`
+}
+
+// Enabled reports whether any destination is configured.
+func (n NotificationsConfig) Enabled() bool {
+	return n.Slack.WebhookURL != "" || n.SMTP.Addr != ""
+}
+
+// TenantsConfig controls the per-tenant settings store.
+type TenantsConfig struct {
+	// CacheTTL is how long replicas cache a tenant's settings in Redis, and so how
+	// long a change made through the admin API may take to apply everywhere.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// WebhooksConfig controls user-registered webhook endpoints. Deliveries are sent by
+// the background worker, so it must run either in-process or as the worker command.
+type WebhooksConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Timeout bounds each delivery attempt.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxAttempts is how often a delivery is tried before it is marked failed.
+	MaxAttempts int `yaml:"max_attempts"`
+	// MaxEndpoints caps the endpoints a single user may register.
+	MaxEndpoints int `yaml:"max_endpoints"`
+	// AllowHTTP accepts plain http:// endpoint URLs; by default only https:// is.
+	AllowHTTP bool `yaml:"allow_http"`
+	// AllowPrivateNetworks permits deliveries to loopback, private and link-local
+	// addresses, which are otherwise refused to keep webhooks from reaching
+	// internal services.
+	AllowPrivateNetworks bool `yaml:"allow_private_networks"`
+}
+
+// UsersConfig sets the monthly verification quotas of users whose profile sets none.
+// Operators set a user's own quota with PUT /admin/api/users/:id/plan.
+type UsersConfig struct {
+	// TierQuotas are the quotas of users of a tier, e.g. {"free": 100}; 0 is
+	// unlimited.
+	TierQuotas map[string]int64 `yaml:"tier_quotas"`
+	// DefaultMonthlyQuota applies to users whose tier has no quota; 0 is unlimited.
+	DefaultMonthlyQuota int64 `yaml:"default_monthly_quota"`
+}
+
+// MalwareScanConfig scans every image for malware before it is verified. Infected
+// images are rejected with 422 and recorded as failed verifications.
+type MalwareScanConfig struct {
+	// Provider is "clamav", "http" or empty to not scan images.
+	Provider string `yaml:"provider"`
+	// Addr is the host:port of the clamd TCP socket for clamav.
+	Addr string `yaml:"addr"`
+	// URL receives the images POSTed for http, sent with Token as a bearer token
+	// when it is set.
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"`
+	// Timeout bounds each scan.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// PreprocessConfig prepares images before they are sent to the image processor.
+// Hashes, capture metadata and stored images still come from the uploaded image.
+type PreprocessConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxWidth and MaxHeight scale down larger images, keeping their aspect ratio;
+	// 0 leaves that side unbounded.
+	MaxWidth  int `yaml:"max_width"`
+	MaxHeight int `yaml:"max_height"`
+	// AutoRotate turns images upright according to their EXIF orientation.
+	AutoRotate bool `yaml:"auto_rotate"`
+	// StripMetadata removes EXIF, XMP and IPTC blocks, comments and text chunks.
+	StripMetadata bool `yaml:"strip_metadata"`
+	// Format is "jpeg", "png" or empty to keep the format of the upload.
+	Format      string `yaml:"format"`
+	JPEGQuality int    `yaml:"jpeg_quality"`
+}
+
+// StorageConfig keeps uploaded images in S3-compatible object storage or on local
+// disk so they can be retrieved later through signed URLs. GCS is used through its
+// S3 interoperability API with HMAC keys.
+type StorageConfig struct {
+	// Provider is "s3", "minio", "gcs", "local" or empty to not keep images.
+	Provider string `yaml:"provider"`
+	Bucket   string `yaml:"bucket"`
+	Region   string `yaml:"region"`
+	Endpoint string `yaml:"endpoint"`
+	Prefix   string `yaml:"prefix"`
+	// PathStyle addresses the bucket in the URL path; always on for minio.
+	PathStyle       bool   `yaml:"path_style"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	// SignedURLTTL is how long image URLs handed to clients stay valid.
+	SignedURLTTL time.Duration `yaml:"signed_url_ttl"`
+	// Dir holds the images of the local provider, which serves them at
+	// <PublicURL>/images/ through URLs signed with SigningKey.
+	Dir        string `yaml:"dir"`
+	PublicURL  string `yaml:"public_url"`
+	SigningKey string `yaml:"signing_key"`
+}
+
+// WorkerConfig controls the background job runner. It always runs in the worker
+// command; InProcess additionally runs it inside serve for single-process setups.
+type WorkerConfig struct {
+	InProcess         bool          `yaml:"in_process"`
+	Concurrency       int           `yaml:"concurrency"`
+	PollInterval      time.Duration `yaml:"poll_interval"`
+	VisibilityTimeout time.Duration `yaml:"visibility_timeout"`
+	MaxAttempts       int           `yaml:"max_attempts"`
+	InitialBackoff    time.Duration `yaml:"initial_backoff"`
+	MaxBackoff        time.Duration `yaml:"max_backoff"`
+	// MetricsAddr is where the worker command serves the Prometheus metrics of its
+	// jobs at /metrics, e.g. :9102; empty disables them. Its -metrics-addr flag
+	// overrides it.
+	MetricsAddr string `yaml:"metrics_addr"`
+}
+
+// LogConfig controls logging output.
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+// ReloadConfig controls hot reloading of the configuration file. SIGHUP always
+// triggers a reload; WatchInterval additionally polls the file for changes.
+type ReloadConfig struct {
+	WatchInterval time.Duration `yaml:"watch_interval"`
+}
+
+// StartupConfig controls how long the process waits for Postgres, Redis and the image
+// processor at boot. With Degraded set, serve starts even if a dependency is still
+// unreachable once the attempts are exhausted and relies on reconnecting later.
+type StartupConfig struct {
+	Attempts       int           `yaml:"attempts"`
+	AttemptTimeout time.Duration `yaml:"attempt_timeout"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Degraded       bool          `yaml:"degraded"`
+}
+
+// ShutdownConfig bounds how long each dependency may take to close after the HTTP
+// server has drained.
+type ShutdownConfig struct {
+	StageTimeout time.Duration `yaml:"stage_timeout"`
+	// DrainDelay keeps serving after SIGTERM while readiness fails, so endpoint
+	// removal propagates before connections are closed.
+	DrainDelay time.Duration `yaml:"drain_delay"`
+}
+
+// HTTPConfig controls the public HTTP listener.
+type HTTPConfig struct {
+	Addr            string        `yaml:"addr"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	MaxUploadSize   int64         `yaml:"max_upload_size"`
+	Spool           SpoolConfig   `yaml:"spool"`
+	Images          ImageLimits   `yaml:"images"`
+	ImageURLs       ImageURLs     `yaml:"image_urls"`
+	ReusePort       bool          `yaml:"reuse_port"`
+	TLS             TLSConfig     `yaml:"tls"`
+	UnixSocket      UnixSocket    `yaml:"unix_socket"`
+	HTTP2           HTTP2Config   `yaml:"http2"`
+	Proxy           ProxyConfig   `yaml:"proxy"`
+	// Metrics records Prometheus metrics of requests and serves them at GET /metrics
+	// on the admin listener.
+	Metrics bool `yaml:"metrics"`
+	// Docs serves the OpenAPI document at GET /openapi.json and Swagger UI at GET
+	// /docs, without authentication.
+	Docs bool `yaml:"docs"`
+}
+
+// SpoolConfig keeps large uploads on disk rather than in memory. Uploads up to
+// Threshold bytes stay in memory; larger ones are written to temporary files in Dir,
+// or in the default directory for temporary files when Dir is empty.
+type SpoolConfig struct {
+	Threshold int64  `yaml:"threshold"`
+	Dir       string `yaml:"dir"`
+}
+
+// ImageLimits bounds the dimensions of uploaded images, read from their headers
+// before they are verified. Zero disables a limit; corrupt headers are always rejected.
+type ImageLimits struct {
+	MaxWidth  int   `yaml:"max_width"`
+	MaxHeight int   `yaml:"max_height"`
+	MaxPixels int64 `yaml:"max_pixels"`
+	MinWidth  int   `yaml:"min_width"`
+	MinHeight int   `yaml:"min_height"`
+	// MaxAspectRatio bounds the longer side divided by the shorter one.
+	MaxAspectRatio float64 `yaml:"max_aspect_ratio"`
+}
+
+// ImageURLs lets POST /verify download the image from an image_url instead of taking
+// an upload. Downloads are bounded by max_upload_size and Timeout.
+type ImageURLs struct {
+	Enabled bool          `yaml:"enabled"`
+	Timeout time.Duration `yaml:"timeout"`
+	// AllowHTTP accepts plain http:// URLs besides https://.
+	AllowHTTP bool `yaml:"allow_http"`
+	// AllowPrivateNetworks permits downloads from loopback, private and link-local
+	// addresses. Leave it off unless every user is trusted.
+	AllowPrivateNetworks bool `yaml:"allow_private_networks"`
+}
+
+// ProxyConfig controls how the client address is derived behind load balancers.
+// Forwarding headers are only believed from TrustedProxies; with none configured the
+// peer address is used as is.
+type ProxyConfig struct {
+	TrustedProxies  []string `yaml:"trusted_proxies"`
+	ClientIPHeaders []string `yaml:"client_ip_headers"`
+	// TrustedPlatform is "cloudflare", "google-app-engine" or the name of a header
+	// set by the edge that always carries the client address.
+	TrustedPlatform string `yaml:"trusted_platform"`
+}
+
+// HTTP2Config tunes HTTP/2. It is negotiated automatically over TLS; H2C additionally
+// accepts cleartext HTTP/2 for in-cluster clients that speak it with prior knowledge.
+type HTTP2Config struct {
+	H2C                  bool          `yaml:"h2c"`
+	MaxConcurrentStreams int           `yaml:"max_concurrent_streams"`
+	MaxReadFrameSize     int           `yaml:"max_read_frame_size"`
+	IdleTimeout          time.Duration `yaml:"idle_timeout"`
+}
+
+// UnixSocket additionally serves the API on a Unix domain socket, for deployments
+// behind a local proxy or sidecar. TLS is never applied to the socket.
+type UnixSocket struct {
+	Path string `yaml:"path"`
+	Mode string `yaml:"mode"`
+}
+
+// FileMode parses Mode as an octal permission string such as "0660".
+func (u UnixSocket) FileMode() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(u.Mode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid octal file mode %q", u.Mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// AdminConfig controls the operations listener serving health, profiling and admin
+// routes. It should be bound to a loopback or cluster-internal address.
+type AdminConfig struct {
+	Addr        string `yaml:"addr"`
+	EnablePprof bool   `yaml:"enable_pprof"`
+	EnableUI    bool   `yaml:"enable_ui"`
+}
+
+// GRPCConfig enables the gRPC verification API next to the REST API. It accepts the
+// same tokens and uses http.tls when that is enabled. An empty Addr disables it.
+type GRPCConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+// LimitsConfig bounds the number of requests handled concurrently. Requests over a
+// limit are rejected with 503 instead of queueing. Zero disables a limit.
+type LimitsConfig struct {
+	MaxInFlight int            `yaml:"max_in_flight"`
+	Routes      map[string]int `yaml:"routes"`
+	RetryAfter  time.Duration  `yaml:"retry_after"`
+	// Adaptive bounds concurrent verifications by a limit that follows the latency
+	// of the processor and the database.
+	Adaptive AdaptiveLimitConfig `yaml:"adaptive"`
+	// Rate throttles authenticated routes per user and per client address.
+	Rate RateLimitConfig `yaml:"rate"`
+}
+
+// RateLimitConfig throttles groups of routes with token buckets kept in Redis, so
+// every replica enforces the same limits.
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Groups are keyed by a name that also keys their buckets in Redis.
+	Groups map[string]RateLimitGroup `yaml:"groups"`
+}
+
+// RateLimitGroup is a set of routes sharing limits.
+type RateLimitGroup struct {
+	// Routes are keyed by method and route pattern, e.g. "POST /verify".
+	Routes  []string  `yaml:"routes"`
+	PerUser RateLimit `yaml:"per_user"`
+	PerIP   RateLimit `yaml:"per_ip"`
+}
+
+// RateLimit allows Requests per Per on average and bursts of up to Burst requests,
+// or Requests when Burst is zero. A zero Requests disables the limit.
+type RateLimit struct {
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+	Burst    int           `yaml:"burst"`
+}
+
+// AdaptiveLimitConfig tunes the adaptive verification limit. The limit starts at
+// InitialLimit, grows while the processor and the database answer as fast as usual
+// and shrinks when either slows down, staying between MinLimit and MaxLimit.
+type AdaptiveLimitConfig struct {
+	Enabled      bool `yaml:"enabled"`
+	InitialLimit int  `yaml:"initial_limit"`
+	MinLimit     int  `yaml:"min_limit"`
+	MaxLimit     int  `yaml:"max_limit"`
+	// Tolerance is how many times slower than usual a dependency may answer before
+	// the limit shrinks.
+	Tolerance float64 `yaml:"tolerance"`
+	// Backoff multiplies the limit when it shrinks.
+	Backoff float64 `yaml:"backoff"`
+}
+
+// TLSConfig enables native HTTPS termination, either from certificate files or via ACME.
+type TLSConfig struct {
+	CertFile     string         `yaml:"cert_file"`
+	KeyFile      string         `yaml:"key_file"`
+	MinVersion   string         `yaml:"min_version"`
+	RedirectAddr string         `yaml:"redirect_addr"`
+	Autocert     AutocertConfig `yaml:"autocert"`
+}
+
+// AutocertConfig configures certificate issuance through Let's Encrypt.
+type AutocertConfig struct {
+	Domains  []string `yaml:"domains"`
+	CacheDir string   `yaml:"cache_dir"`
+	Email    string   `yaml:"email"`
+}
+
+// Enabled reports whether the HTTP listener should terminate TLS.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || len(t.Autocert.Domains) > 0
+}
+
+// DatabaseConfig controls the PostgreSQL connection pool and retry policy.
+type DatabaseConfig struct {
+	DSN string `yaml:"dsn"`
+	// Password, when set, replaces the password in DSN for every new connection.
+	Password string `yaml:"password"`
+	// ReaderDSN connects to a streaming replica of the database that serves result
+	// lookups, duplicate searches and metrics aggregations, so they do not compete
+	// with writes. Password applies to it as well. Empty reads from DSN.
+	ReaderDSN       string        `yaml:"reader_dsn"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	RetryAttempts   int           `yaml:"retry_attempts"`
+	InitialBackoff  time.Duration `yaml:"initial_backoff"`
+	MaxBackoff      time.Duration `yaml:"max_backoff"`
+	// PrepareStatements prepares each distinct query once per connection and reuses
+	// it. Disable it behind poolers that do not keep sessions, such as PgBouncer in
+	// transaction mode.
+	PrepareStatements bool `yaml:"prepare_statements"`
+	// Adaptive lets serve resize the pool from how long queries wait for connections.
+	Adaptive AdaptivePoolConfig `yaml:"adaptive"`
+	// MigrateOnStart lets serve apply pending migrations at startup. Otherwise it
+	// refuses to start until they were applied with the migrate command.
+	MigrateOnStart bool `yaml:"migrate_on_start"`
+}
+
+// AdaptivePoolConfig bounds the adaptive sizing of the database pool. MaxOpenConns of
+// the database is the starting size.
+type AdaptivePoolConfig struct {
+	Enabled      bool `yaml:"enabled"`
+	MinOpenConns int  `yaml:"min_open_conns"`
+	MaxOpenConns int  `yaml:"max_open_conns"`
+	// Interval is how often the pool statistics are sampled.
+	Interval time.Duration `yaml:"interval"`
+	// WaitThreshold is the average wait for a connection over an interval above
+	// which the pool grows.
+	WaitThreshold time.Duration `yaml:"wait_threshold"`
+}
+
+// RegionConfig supports active/active deployments in several regions behind geo
+// DNS.
+type RegionConfig struct {
+	// Name tags logs, cache keys, verification logs and metrics, e.g. "eu-west-1".
+	// Empty for single-region deployments.
+	Name string `yaml:"name"`
+	// ReplicaDSN connects to a read replica in another region. When set, reads fall
+	// back to it while the local database is unreachable; writes never do.
+	ReplicaDSN string `yaml:"replica_dsn"`
+	// FailoverCooldown is how long reads stay on the replica after the local
+	// database failed before it is tried again.
+	FailoverCooldown time.Duration `yaml:"failover_cooldown"`
+}
+
+// RedisConfig controls the Redis connection.
+type RedisConfig struct {
+	Addr        string        `yaml:"addr"`
+	Username    string        `yaml:"username"`
+	Password    string        `yaml:"password"`
+	DialTimeout time.Duration `yaml:"dial_timeout"`
+	// Sentinel finds the master through Redis Sentinel instead of Addr, following it
+	// across failovers.
+	Sentinel RedisSentinelConfig `yaml:"sentinel"`
+	// CacheCluster keeps the result cache on a Redis Cluster instead of the Redis
+	// above, which still holds the queues, rate limits and other shared state.
+	CacheCluster RedisClusterConfig `yaml:"cache_cluster"`
+}
+
+// RedisSentinelConfig locates a Redis master through Sentinel. It is off while
+// MasterName is empty.
+type RedisSentinelConfig struct {
+	MasterName string   `yaml:"master_name"`
+	Addrs      []string `yaml:"addrs"`
+	// Password authenticates with the sentinels, which may differ from the master.
+	Password string `yaml:"password"`
+}
+
+// RedisClusterConfig connects to a Redis Cluster through any of its nodes. It is off
+// while Addrs is empty.
+type RedisClusterConfig struct {
+	Addrs []string `yaml:"addrs"`
+}
+
+// ProcessorConfig controls the gRPC image processor connection.
+type ProcessorConfig struct {
+	// Backend selects the processor: "grpc" for the Rust service, "http" for a REST
+	// processor or "mock" for the deterministic stub of development mode.
+	Backend string `yaml:"backend"`
+	// Addr is, with the grpc backend, a host:port, a comma-separated list of them or
+	// a gRPC target such as dns:///host:port. Calls are spread round-robin over the
+	// replicas it names. With the http backend it is the URL images are POSTed to.
+	Addr string `yaml:"addr"`
+	// Timeout bounds each call to the processor, and with the grpc backend each
+	// attempt of a call; 0 leaves calls bounded by the verification only.
+	Timeout time.Duration `yaml:"timeout"`
+	// RetryAttempts is how often the grpc backend tries a call failing with
+	// UNAVAILABLE or DEADLINE_EXCEEDED, backing off exponentially from
+	// InitialBackoff up to MaxBackoff between attempts.
+	RetryAttempts  int           `yaml:"retry_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	// HedgeDelay makes the grpc backend send a second copy of a call still
+	// unanswered after it to another replica, keeping the first answer; 0 disables
+	// hedging.
+	HedgeDelay time.Duration `yaml:"hedge_delay"`
+	// HealthCheck watches the gRPC health service of every replica and skips those
+	// reporting NOT_SERVING.
+	HealthCheck bool `yaml:"health_check"`
+	// HealthService is the service the health checks ask about; empty asks about
+	// the whole server.
+	HealthService string `yaml:"health_service"`
+}
+
+// ExperimentConfig splits verifications between processor models to compare them on
+// live traffic. Without variants every verification uses processor.addr.
+type ExperimentConfig struct {
+	// Name salts the assignment of users to variants; renaming the experiment
+	// reshuffles them.
+	Name     string                    `yaml:"name"`
+	Variants []ExperimentVariantConfig `yaml:"variants"`
+}
+
+// ExperimentVariantConfig is one arm of the experiment.
+type ExperimentVariantConfig struct {
+	Name string `yaml:"name"`
+	// ProcessorAddr serves the variant's model; empty uses processor.addr.
+	ProcessorAddr string `yaml:"processor_addr"`
+	// Percent is the share of users assigned to the variant. The percentages of all
+	// variants add up to 100.
+	Percent int `yaml:"percent"`
+	// Users always get this variant, e.g. internal testers.
+	Users []string `yaml:"users"`
+}
+
+// SecretsConfig loads the JWT secret and the Postgres and Redis credentials from an
+// external secrets manager at startup, re-reading them every RefreshInterval. Values
+// found there take precedence over the file and environment.
+type SecretsConfig struct {
+	// Provider is "vault", "aws" or empty to disable the integration.
+	Provider        string           `yaml:"provider"`
+	RefreshInterval time.Duration    `yaml:"refresh_interval"`
+	Timeout         time.Duration    `yaml:"timeout"`
+	Vault           VaultConfig      `yaml:"vault"`
+	AWS             AWSSecretsConfig `yaml:"aws"`
+}
+
+// VaultConfig reads a HashiCorp Vault KV version 2 secret with token authentication.
+type VaultConfig struct {
+	Addr      string `yaml:"addr"`
+	Token     string `yaml:"token"`
+	TokenFile string `yaml:"token_file"`
+	Namespace string `yaml:"namespace"`
+	Mount     string `yaml:"mount"`
+	Path      string `yaml:"path"`
+}
+
+// AWSSecretsConfig reads a JSON secret from AWS Secrets Manager. Credentials come from
+// the standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables.
+type AWSSecretsConfig struct {
+	Region   string `yaml:"region"`
+	SecretID string `yaml:"secret_id"`
+	// Endpoint overrides the regional endpoint, e.g. for a VPC interface endpoint.
+	Endpoint string `yaml:"endpoint"`
+}
+
+// AuthConfig controls bearer token validation.
+type AuthConfig struct {
+	JWTSecret          string   `yaml:"jwt_secret"`
+	JWTPreviousSecrets []string `yaml:"jwt_previous_secrets"`
+	JWTAudience        string   `yaml:"jwt_audience"`
+	// JWKSURL, when set, also accepts RSA and ECDSA signed tokens, verified with the
+	// keys an identity provider such as Auth0 or Keycloak publishes at this URL.
+	JWKSURL string `yaml:"jwks_url"`
+	// JWKSRefreshInterval is how long fetched keys are used before they are fetched
+	// again. Tokens signed with an unknown key fetch them earlier.
+	JWKSRefreshInterval time.Duration `yaml:"jwks_refresh_interval"`
+	// JWTIssuer, when set, must be the iss claim of tokens verified with the JWKS.
+	JWTIssuer string `yaml:"jwt_issuer"`
+	// AdminRole is the entry of the roles claim that grants access to the /admin
+	// routes of the public listener. Empty disables them.
+	AdminRole string `yaml:"admin_role"`
+	// RequireScopes treats tokens without a scope or scp claim as read-only, so
+	// only tokens granted the verify:write scope can change data. Otherwise such
+	// tokens may do everything.
+	RequireScopes bool `yaml:"require_scopes"`
+	// Tokens, when enabled, issues tokens at POST /auth/token.
+	Tokens TokensConfig `yaml:"tokens"`
+}
+
+// TokensConfig lets the service issue its own tokens, signed with jwt_secret, to the
+// configured clients, so it can run without an external identity provider.
+type TokensConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	AccessTTL  time.Duration `yaml:"access_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+	Clients    []TokenClient `yaml:"clients"`
+}
+
+// TokenClient is an application that may obtain tokens with its ID and secret.
+type TokenClient struct {
+	ID string `yaml:"id"`
+	// SecretHash is the bcrypt hash of the client's secret.
+	SecretHash string `yaml:"secret_hash"`
+	// Subject is the sub claim of the client's tokens; empty uses ID.
+	Subject string   `yaml:"subject"`
+	Tenant  string   `yaml:"tenant"`
+	Roles   []string `yaml:"roles"`
+	// Scopes is the scope claim of the client's tokens; empty issues tokens without
+	// one.
+	Scopes []string `yaml:"scopes"`
+}
+
+// VerificationConfig holds the tunables of the verification use case.
+type VerificationConfig struct {
+	RetryAttempts  int           `yaml:"retry_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	// RetryJitter randomly shortens each Redis backoff by up to this fraction, from
+	// 0 to 1, so requests that failed together do not retry in lockstep.
+	RetryJitter float64 `yaml:"retry_jitter"`
+	// RetryBudget caps the Redis retries of one request across its cache
+	// operations. 0 leaves only RetryAttempts per operation.
+	RetryBudget   int           `yaml:"retry_budget"`
+	ProcessingTTL time.Duration `yaml:"processing_ttl"`
+	ResultTTL     time.Duration `yaml:"result_ttl"`
+	// NotFoundTTL caches that a request ID has no result for this long, so polling
+	// an unknown ID does not reach the database each time. 0 disables it.
+	NotFoundTTL time.Duration `yaml:"not_found_ttl"`
+	// MetricsSummaryTTL caches the totals of /metrics/summary for this long, and
+	// MetricsSummaryStaleTTL serves them for that much longer while they are
+	// recomputed in the background. 0 disables the cache or the stale serving.
+	MetricsSummaryTTL      time.Duration `yaml:"metrics_summary_ttl"`
+	MetricsSummaryStaleTTL time.Duration `yaml:"metrics_summary_stale_ttl"`
+	// DetachedTimeout bounds a verification whose upload was read, independently of
+	// the client, which may disconnect and fetch the result later. 0 cancels
+	// verifications with their request.
+	DetachedTimeout time.Duration `yaml:"detached_timeout"`
+	// RequestIDFormat is how request IDs are generated: "uuid" (random), or the
+	// time-ordered "uuidv7" or "ulid", which keep recent verifications together in
+	// the request_id index.
+	RequestIDFormat string `yaml:"request_id_format"`
+	// ReviewThreshold flags verifications scoring below it as needing review, in
+	// addition to those the processor did not verify.
+	ReviewThreshold float64 `yaml:"review_threshold"`
+	// RejectThreshold rejects verifications scoring below it outright, whatever the
+	// processor said; 0 rejects none.
+	RejectThreshold float64 `yaml:"reject_threshold"`
+	// CategoryThresholds flags each moderation category the processor scores at or
+	// above its threshold; a flagged category also needs review.
+	CategoryThresholds CategoryThresholdsConfig `yaml:"category_thresholds"`
+	// Calibration maps the scores of each model version, as reported by the
+	// processor, onto the scale the thresholds were set for. The points of a version
+	// are ordered by raw score; scores between them are interpolated. Versions
+	// without points keep their raw scores.
+	Calibration map[string][]CalibrationPoint `yaml:"calibration"`
+	// MaxDuplicates caps the duplicates returned by one duplicate report page.
+	MaxDuplicates int `yaml:"max_duplicates"`
+	// DuplicatePrecheck answers an image the user already verified with the earlier
+	// result, without calling the processor. The image is then read in full before
+	// it is processed.
+	DuplicatePrecheck bool `yaml:"duplicate_precheck"`
+	// WriteBuffer queues verification logs in Redis while the database is
+	// unavailable, for the worker to save once it recovers.
+	WriteBuffer WriteBufferConfig `yaml:"write_buffer"`
+	// Deferred queues verifications while the image processor is unavailable, to
+	// be completed from their stored image once it is back.
+	Deferred DeferredConfig `yaml:"deferred"`
+}
+
+// DeferredConfig controls queueing verifications during image processor outages.
+type DeferredConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxAttempts is how often a queued verification is tried before it is
+	// dead-lettered; with the worker backoff it bounds the outage it survives.
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+// WriteBufferConfig controls buffering verification logs during database outages.
+type WriteBufferConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxAttempts is how often the worker tries to save a buffered log before it is
+	// dead-lettered; with the worker backoff it bounds the outage a log survives.
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+// CalibrationPoint maps a raw score of a model to its calibrated score.
+type CalibrationPoint struct {
+	Raw        float64 `yaml:"raw"`
+	Calibrated float64 `yaml:"calibrated"`
+}
+
+// CategoryThresholdsConfig holds a threshold per moderation category. A threshold of
+// 0 records the category's score without ever flagging it.
+type CategoryThresholdsConfig struct {
+	AIGenerated float64 `yaml:"ai_generated"`
+	Manipulated float64 `yaml:"manipulated"`
+	NSFW        float64 `yaml:"nsfw"`
+	Watermarked float64 `yaml:"watermarked"`
+}
+
+// Default returns the configuration used when no file or environment overrides are present.
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Addr:            ":8080",
+			ShutdownTimeout: 15 * time.Second,
+			MaxUploadSize:   8 << 20,
+			Metrics:         true,
+			Docs:            true,
+			Spool: SpoolConfig{
+				Threshold: 1 << 20,
+			},
+			ImageURLs: ImageURLs{
+				Timeout: 10 * time.Second,
+			},
+			Images: ImageLimits{
+				MaxWidth:  16384,
+				MaxHeight: 16384,
+				MaxPixels: 100_000_000,
+			},
+			TLS: TLSConfig{
+				MinVersion: "1.2",
+				Autocert: AutocertConfig{
+					CacheDir: "autocert-cache",
+				},
+			},
+			UnixSocket: UnixSocket{
+				Mode: "0660",
+			},
+			HTTP2: HTTP2Config{
+				MaxConcurrentStreams: 250,
+				MaxReadFrameSize:     1 << 20,
+			},
+			Proxy: ProxyConfig{
+				ClientIPHeaders: []string{"X-Forwarded-For", "X-Real-IP"},
+			},
+		},
+		Admin: AdminConfig{
+			Addr:        "127.0.0.1:9090",
+			EnablePprof: true,
+			EnableUI:    true,
+		},
+		Limits: LimitsConfig{
+			RetryAfter: time.Second,
+			Adaptive: AdaptiveLimitConfig{
+				InitialLimit: 20,
+				MinLimit:     4,
+				MaxLimit:     200,
+				Tolerance:    2,
+				Backoff:      0.9,
+			},
+		},
+		Startup: StartupConfig{
+			Attempts:       5,
+			AttemptTimeout: 5 * time.Second,
+			InitialBackoff: 500 * time.Millisecond,
+			MaxBackoff:     10 * time.Second,
+		},
+		Database: DatabaseConfig{
+			DSN:               "host=postgres user=postgres password=postgres dbname=aiverify port=5432 sslmode=disable",
+			MaxIdleConns:      5,
+			MaxOpenConns:      10,
+			ConnMaxLifetime:   time.Hour,
+			RetryAttempts:     3,
+			InitialBackoff:    100 * time.Millisecond,
+			MaxBackoff:        2 * time.Second,
+			PrepareStatements: true,
+			Adaptive: AdaptivePoolConfig{
+				MinOpenConns:  5,
+				MaxOpenConns:  50,
+				Interval:      15 * time.Second,
+				WaitThreshold: 5 * time.Millisecond,
+			},
+		},
+		Region: RegionConfig{
+			FailoverCooldown: 30 * time.Second,
+		},
+		Redis: RedisConfig{
+			Addr:        "redis:6379",
+			DialTimeout: 5 * time.Second,
+		},
+		Processor: ProcessorConfig{
+			Backend:        "grpc",
+			Addr:           "rust-service:50051",
+			RetryAttempts:  1,
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     time.Second,
+			HealthCheck:    true,
+		},
+		Auth: AuthConfig{
+			JWTSecret:           "dev-secret",
+			JWKSRefreshInterval: time.Hour,
+			AdminRole:           "admin",
+			Tokens: TokensConfig{
+				AccessTTL:  15 * time.Minute,
+				RefreshTTL: 30 * 24 * time.Hour,
+			},
+		},
+		Verification: VerificationConfig{
+			RetryAttempts:          3,
+			InitialBackoff:         50 * time.Millisecond,
+			MaxBackoff:             time.Second,
+			RetryJitter:            0.5,
+			RetryBudget:            3,
+			ProcessingTTL:          time.Minute,
+			ResultTTL:              5 * time.Minute,
+			NotFoundTTL:            2 * time.Second,
+			MetricsSummaryTTL:      5 * time.Second,
+			MetricsSummaryStaleTTL: time.Minute,
+			DetachedTimeout:        time.Minute,
+			RequestIDFormat:        "uuid",
+			ReviewThreshold:        0.5,
+			MaxDuplicates:          100,
+			WriteBuffer: WriteBufferConfig{
+				MaxAttempts: 100,
+			},
+			Deferred: DeferredConfig{
+				MaxAttempts: 50,
+			},
+			CategoryThresholds: CategoryThresholdsConfig{
+				AIGenerated: 0.5,
+				Manipulated: 0.5,
+				NSFW:        0.5,
+				Watermarked: 0.5,
+			},
+		},
+		Shutdown: ShutdownConfig{
+			StageTimeout: 5 * time.Second,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Worker: WorkerConfig{
+			Concurrency:       4,
+			PollInterval:      time.Second,
+			VisibilityTimeout: 30 * time.Second,
+			MaxAttempts:       5,
+			InitialBackoff:    time.Second,
+			MaxBackoff:        5 * time.Minute,
+		},
+		Storage: StorageConfig{
+			Prefix:       "images/",
+			SignedURLTTL: 15 * time.Minute,
+		},
+		MalwareScan: MalwareScanConfig{
+			Timeout: 30 * time.Second,
+		},
+		Preprocess: PreprocessConfig{
+			MaxWidth:      4096,
+			MaxHeight:     4096,
+			AutoRotate:    true,
+			StripMetadata: true,
+			JPEGQuality:   90,
+		},
+		Notifications: NotificationsConfig{
+			ConsecutiveFailures: 5,
+			ErrorRate: ErrorRateConfig{
+				Threshold:   0.5,
+				Window:      5 * time.Minute,
+				MinRequests: 20,
+			},
+			Cooldown: 15 * time.Minute,
+			Timeout:  10 * time.Second,
+		},
+		Cron: CronConfig{
+			Enabled:  true,
+			LeaseTTL: 15 * time.Second,
+			PurgeLogs: PurgeLogsConfig{
+				Schedule:  "0 3 * * *",
+				BatchSize: 1000,
+			},
+			PurgeErased: PurgeErasedConfig{
+				Schedule:  "0 4 * * *",
+				Grace:     72 * time.Hour,
+				BatchSize: 1000,
+			},
+		},
+		Warehouse: WarehouseConfig{
+			Table:      "verification_logs",
+			Schedule:   "*/15 * * * *",
+			BatchSize:  5000,
+			MaxBatches: 20,
+			Lag:        time.Minute,
+			Timeout:    time.Minute,
+			Snowflake: SnowflakeConfig{
+				Schema: "PUBLIC",
+			},
+			ClickHouse: ClickHouseConfig{
+				Database: "default",
+			},
+		},
+		Health: HealthConfig{
+			Timeout: 2 * time.Second,
+		},
+		Tracing: TracingConfig{
+			Endpoint:       "http://localhost:4318",
+			ServiceName:    "ai-check",
+			SampleRatio:    1,
+			ExportInterval: 5 * time.Second,
+		},
+		Events: EventsConfig{
+			Format:  "json",
+			Timeout: 10 * time.Second,
+			Kafka: KafkaConfig{
+				Topic: "ai-check.verifications",
+			},
+			NATS: NATSConfig{
+				Subject: "ai-check",
+			},
+			Redis: RedisStreamConfig{
+				Stream: "ai-check:events",
+				MaxLen: 1_000_000,
+			},
+			Outbox: OutboxConfig{
+				PollInterval: time.Second,
+				BatchSize:    100,
+				Retention:    24 * time.Hour,
+			},
+		},
+		Metering: MeteringConfig{
+			UnitsPerVerification: 1,
+			Stripe: StripeConfig{
+				MeterEventName: "ai_check_verifications",
+				ReportInterval: time.Hour,
+			},
+		},
+		Webhooks: WebhooksConfig{
+			Timeout:      10 * time.Second,
+			MaxAttempts:  8,
+			MaxEndpoints: 10,
+		},
+		Tenants: TenantsConfig{
+			CacheTTL: time.Minute,
+		},
+		Secrets: SecretsConfig{
+			RefreshInterval: 5 * time.Minute,
+			Timeout:         10 * time.Second,
+			Vault: VaultConfig{
+				Mount: "secret",
+			},
+		},
+	}
+}
+
+// Load builds the configuration from defaults, an optional YAML or TOML file, and environment overrides,
+// then validates the resulting tree.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	// Malformed environment values and validation failures are reported together so
+	// operators can fix every problem in one go.
+	problems := unjoin(cfg.applyEnv(os.LookupEnv))
+	problems = append(problems, cfg.problems()...)
+	if err := newValidationError(problems); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", "":
+	case ".toml":
+		// The configuration tree is described by its yaml tags, so a TOML file is
+		// decoded into a map and re-encoded as YAML, whose strict decoding below
+		// rejects unknown keys of either format.
+		var tree map[string]any
+		if err := toml.Unmarshal(data, &tree); err != nil {
+			return fmt.Errorf("parse config file %s: %w", path, err)
+		}
+		if data, err = yaml.Marshal(tree); err != nil {
+			return fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file format %q", filepath.Ext(path))
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+type envBinding struct {
+	key   string
+	path  string
+	apply func(c *Config, value string) error
+}
+
+var envBindings = []envBinding{
+	{"HTTP_ADDR", "http.addr", stringSetter(func(c *Config) *string { return &c.HTTP.Addr })},
+	{"HTTP_SHUTDOWN_TIMEOUT", "http.shutdown_timeout", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.ShutdownTimeout })},
+	{"HTTP_MAX_UPLOAD_SIZE", "http.max_upload_size", int64Setter(func(c *Config) *int64 { return &c.HTTP.MaxUploadSize })},
+	{"HTTP_SPOOL_THRESHOLD", "http.spool.threshold", int64Setter(func(c *Config) *int64 { return &c.HTTP.Spool.Threshold })},
+	{"HTTP_SPOOL_DIR", "http.spool.dir", stringSetter(func(c *Config) *string { return &c.HTTP.Spool.Dir })},
+	{"HTTP_IMAGES_MAX_WIDTH", "http.images.max_width", intSetter(func(c *Config) *int { return &c.HTTP.Images.MaxWidth })},
+	{"HTTP_IMAGES_MAX_HEIGHT", "http.images.max_height", intSetter(func(c *Config) *int { return &c.HTTP.Images.MaxHeight })},
+	{"HTTP_IMAGES_MAX_PIXELS", "http.images.max_pixels", int64Setter(func(c *Config) *int64 { return &c.HTTP.Images.MaxPixels })},
+	{"HTTP_IMAGES_MIN_WIDTH", "http.images.min_width", intSetter(func(c *Config) *int { return &c.HTTP.Images.MinWidth })},
+	{"HTTP_IMAGES_MIN_HEIGHT", "http.images.min_height", intSetter(func(c *Config) *int { return &c.HTTP.Images.MinHeight })},
+	{"HTTP_IMAGES_MAX_ASPECT_RATIO", "http.images.max_aspect_ratio", float64Setter(func(c *Config) *float64 { return &c.HTTP.Images.MaxAspectRatio })},
+	{"HTTP_IMAGE_URLS_ENABLED", "http.image_urls.enabled", boolSetter(func(c *Config) *bool { return &c.HTTP.ImageURLs.Enabled })},
+	{"HTTP_IMAGE_URLS_TIMEOUT", "http.image_urls.timeout", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.ImageURLs.Timeout })},
+	{"HTTP_IMAGE_URLS_ALLOW_HTTP", "http.image_urls.allow_http", boolSetter(func(c *Config) *bool { return &c.HTTP.ImageURLs.AllowHTTP })},
+	{"HTTP_IMAGE_URLS_ALLOW_PRIVATE_NETWORKS", "http.image_urls.allow_private_networks", boolSetter(func(c *Config) *bool { return &c.HTTP.ImageURLs.AllowPrivateNetworks })},
+	{"HTTP_REUSE_PORT", "http.reuse_port", boolSetter(func(c *Config) *bool { return &c.HTTP.ReusePort })},
+	{"HTTP_TLS_CERT_FILE", "http.tls.cert_file", stringSetter(func(c *Config) *string { return &c.HTTP.TLS.CertFile })},
+	{"HTTP_TLS_KEY_FILE", "http.tls.key_file", stringSetter(func(c *Config) *string { return &c.HTTP.TLS.KeyFile })},
+	{"HTTP_TLS_MIN_VERSION", "http.tls.min_version", stringSetter(func(c *Config) *string { return &c.HTTP.TLS.MinVersion })},
+	{"HTTP_TLS_REDIRECT_ADDR", "http.tls.redirect_addr", stringSetter(func(c *Config) *string { return &c.HTTP.TLS.RedirectAddr })},
+	{"HTTP_TLS_AUTOCERT_DOMAINS", "http.tls.autocert.domains", listSetter(func(c *Config) *[]string { return &c.HTTP.TLS.Autocert.Domains })},
+	{"HTTP_TLS_AUTOCERT_CACHE_DIR", "http.tls.autocert.cache_dir", stringSetter(func(c *Config) *string { return &c.HTTP.TLS.Autocert.CacheDir })},
+	{"HTTP_TLS_AUTOCERT_EMAIL", "http.tls.autocert.email", stringSetter(func(c *Config) *string { return &c.HTTP.TLS.Autocert.Email })},
+	{"HTTP_UNIX_SOCKET_PATH", "http.unix_socket.path", stringSetter(func(c *Config) *string { return &c.HTTP.UnixSocket.Path })},
+	{"HTTP_UNIX_SOCKET_MODE", "http.unix_socket.mode", stringSetter(func(c *Config) *string { return &c.HTTP.UnixSocket.Mode })},
+	{"HTTP_H2C", "http.http2.h2c", boolSetter(func(c *Config) *bool { return &c.HTTP.HTTP2.H2C })},
+	{"HTTP_HTTP2_MAX_CONCURRENT_STREAMS", "http.http2.max_concurrent_streams", intSetter(func(c *Config) *int { return &c.HTTP.HTTP2.MaxConcurrentStreams })},
+	{"HTTP_HTTP2_MAX_READ_FRAME_SIZE", "http.http2.max_read_frame_size", intSetter(func(c *Config) *int { return &c.HTTP.HTTP2.MaxReadFrameSize })},
+	{"HTTP_HTTP2_IDLE_TIMEOUT", "http.http2.idle_timeout", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.HTTP2.IdleTimeout })},
+	{"HTTP_TRUSTED_PROXIES", "http.proxy.trusted_proxies", listSetter(func(c *Config) *[]string { return &c.HTTP.Proxy.TrustedProxies })},
+	{"HTTP_CLIENT_IP_HEADERS", "http.proxy.client_ip_headers", listSetter(func(c *Config) *[]string { return &c.HTTP.Proxy.ClientIPHeaders })},
+	{"HTTP_METRICS", "http.metrics", boolSetter(func(c *Config) *bool { return &c.HTTP.Metrics })},
+	{"HTTP_DOCS", "http.docs", boolSetter(func(c *Config) *bool { return &c.HTTP.Docs })},
+	{"HTTP_TRUSTED_PLATFORM", "http.proxy.trusted_platform", stringSetter(func(c *Config) *string { return &c.HTTP.Proxy.TrustedPlatform })},
+	{"ADMIN_ADDR", "admin.addr", stringSetter(func(c *Config) *string { return &c.Admin.Addr })},
+	{"ADMIN_ENABLE_PPROF", "admin.enable_pprof", boolSetter(func(c *Config) *bool { return &c.Admin.EnablePprof })},
+	{"ADMIN_ENABLE_UI", "admin.enable_ui", boolSetter(func(c *Config) *bool { return &c.Admin.EnableUI })},
+	{"GRPC_ADDR", "grpc.addr", stringSetter(func(c *Config) *string { return &c.GRPC.Addr })},
+	{"LIMITS_MAX_IN_FLIGHT", "limits.max_in_flight", intSetter(func(c *Config) *int { return &c.Limits.MaxInFlight })},
+	{"LIMITS_RETRY_AFTER", "limits.retry_after", durationSetter(func(c *Config) *time.Duration { return &c.Limits.RetryAfter })},
+	{"LIMITS_ADAPTIVE_ENABLED", "limits.adaptive.enabled", boolSetter(func(c *Config) *bool { return &c.Limits.Adaptive.Enabled })},
+	{"LIMITS_ADAPTIVE_INITIAL_LIMIT", "limits.adaptive.initial_limit", intSetter(func(c *Config) *int { return &c.Limits.Adaptive.InitialLimit })},
+	{"LIMITS_ADAPTIVE_MIN_LIMIT", "limits.adaptive.min_limit", intSetter(func(c *Config) *int { return &c.Limits.Adaptive.MinLimit })},
+	{"LIMITS_ADAPTIVE_MAX_LIMIT", "limits.adaptive.max_limit", intSetter(func(c *Config) *int { return &c.Limits.Adaptive.MaxLimit })},
+	{"LIMITS_ADAPTIVE_TOLERANCE", "limits.adaptive.tolerance", float64Setter(func(c *Config) *float64 { return &c.Limits.Adaptive.Tolerance })},
+	{"LIMITS_ADAPTIVE_BACKOFF", "limits.adaptive.backoff", float64Setter(func(c *Config) *float64 { return &c.Limits.Adaptive.Backoff })},
+	{"LIMITS_RATE_ENABLED", "limits.rate.enabled", boolSetter(func(c *Config) *bool { return &c.Limits.Rate.Enabled })},
+	{"STARTUP_ATTEMPTS", "startup.attempts", intSetter(func(c *Config) *int { return &c.Startup.Attempts })},
+	{"STARTUP_ATTEMPT_TIMEOUT", "startup.attempt_timeout", durationSetter(func(c *Config) *time.Duration { return &c.Startup.AttemptTimeout })},
+	{"STARTUP_INITIAL_BACKOFF", "startup.initial_backoff", durationSetter(func(c *Config) *time.Duration { return &c.Startup.InitialBackoff })},
+	{"STARTUP_MAX_BACKOFF", "startup.max_backoff", durationSetter(func(c *Config) *time.Duration { return &c.Startup.MaxBackoff })},
+	{"STARTUP_DEGRADED", "startup.degraded", boolSetter(func(c *Config) *bool { return &c.Startup.Degraded })},
+	{"DATABASE_DSN", "database.dsn", stringSetter(func(c *Config) *string { return &c.Database.DSN })},
+	{"DATABASE_PASSWORD", "database.password", stringSetter(func(c *Config) *string { return &c.Database.Password })},
+	{"DATABASE_READER_DSN", "database.reader_dsn", stringSetter(func(c *Config) *string { return &c.Database.ReaderDSN })},
+	{"DATABASE_MAX_IDLE_CONNS", "database.max_idle_conns", intSetter(func(c *Config) *int { return &c.Database.MaxIdleConns })},
+	{"DATABASE_MAX_OPEN_CONNS", "database.max_open_conns", intSetter(func(c *Config) *int { return &c.Database.MaxOpenConns })},
+	{"DATABASE_CONN_MAX_LIFETIME", "database.conn_max_lifetime", durationSetter(func(c *Config) *time.Duration { return &c.Database.ConnMaxLifetime })},
+	{"DATABASE_RETRY_ATTEMPTS", "database.retry_attempts", intSetter(func(c *Config) *int { return &c.Database.RetryAttempts })},
+	{"DATABASE_PREPARE_STATEMENTS", "database.prepare_statements", boolSetter(func(c *Config) *bool { return &c.Database.PrepareStatements })},
+	{"DATABASE_MIGRATE_ON_START", "database.migrate_on_start", boolSetter(func(c *Config) *bool { return &c.Database.MigrateOnStart })},
+	{"DATABASE_ADAPTIVE_ENABLED", "database.adaptive.enabled", boolSetter(func(c *Config) *bool { return &c.Database.Adaptive.Enabled })},
+	{"DATABASE_ADAPTIVE_MIN_OPEN_CONNS", "database.adaptive.min_open_conns", intSetter(func(c *Config) *int { return &c.Database.Adaptive.MinOpenConns })},
+	{"DATABASE_ADAPTIVE_MAX_OPEN_CONNS", "database.adaptive.max_open_conns", intSetter(func(c *Config) *int { return &c.Database.Adaptive.MaxOpenConns })},
+	{"DATABASE_ADAPTIVE_INTERVAL", "database.adaptive.interval", durationSetter(func(c *Config) *time.Duration { return &c.Database.Adaptive.Interval })},
+	{"DATABASE_ADAPTIVE_WAIT_THRESHOLD", "database.adaptive.wait_threshold", durationSetter(func(c *Config) *time.Duration { return &c.Database.Adaptive.WaitThreshold })},
+	{"REGION_NAME", "region.name", stringSetter(func(c *Config) *string { return &c.Region.Name })},
+	{"REGION_REPLICA_DSN", "region.replica_dsn", stringSetter(func(c *Config) *string { return &c.Region.ReplicaDSN })},
+	{"REGION_FAILOVER_COOLDOWN", "region.failover_cooldown", durationSetter(func(c *Config) *time.Duration { return &c.Region.FailoverCooldown })},
+	{"EXPERIMENT_NAME", "experiment.name", stringSetter(func(c *Config) *string { return &c.Experiment.Name })},
+	{"REDIS_ADDR", "redis.addr", stringSetter(func(c *Config) *string { return &c.Redis.Addr })},
+	{"REDIS_USERNAME", "redis.username", stringSetter(func(c *Config) *string { return &c.Redis.Username })},
+	{"REDIS_PASSWORD", "redis.password", stringSetter(func(c *Config) *string { return &c.Redis.Password })},
+	{"REDIS_SENTINEL_MASTER", "redis.sentinel.master_name", stringSetter(func(c *Config) *string { return &c.Redis.Sentinel.MasterName })},
+	{"REDIS_SENTINEL_ADDRS", "redis.sentinel.addrs", listSetter(func(c *Config) *[]string { return &c.Redis.Sentinel.Addrs })},
+	{"REDIS_SENTINEL_PASSWORD", "redis.sentinel.password", stringSetter(func(c *Config) *string { return &c.Redis.Sentinel.Password })},
+	{"REDIS_CACHE_CLUSTER_ADDRS", "redis.cache_cluster.addrs", listSetter(func(c *Config) *[]string { return &c.Redis.CacheCluster.Addrs })},
+	{"IMAGE_PROCESSOR_BACKEND", "processor.backend", stringSetter(func(c *Config) *string { return &c.Processor.Backend })},
+	{"IMAGE_PROCESSOR_ADDR", "processor.addr", stringSetter(func(c *Config) *string { return &c.Processor.Addr })},
+	{"IMAGE_PROCESSOR_HEALTH_CHECK", "processor.health_check", boolSetter(func(c *Config) *bool { return &c.Processor.HealthCheck })},
+	{"IMAGE_PROCESSOR_HEALTH_SERVICE", "processor.health_service", stringSetter(func(c *Config) *string { return &c.Processor.HealthService })},
+	{"IMAGE_PROCESSOR_TIMEOUT", "processor.timeout", durationSetter(func(c *Config) *time.Duration { return &c.Processor.Timeout })},
+	{"IMAGE_PROCESSOR_RETRY_ATTEMPTS", "processor.retry_attempts", intSetter(func(c *Config) *int { return &c.Processor.RetryAttempts })},
+	{"IMAGE_PROCESSOR_INITIAL_BACKOFF", "processor.initial_backoff", durationSetter(func(c *Config) *time.Duration { return &c.Processor.InitialBackoff })},
+	{"IMAGE_PROCESSOR_MAX_BACKOFF", "processor.max_backoff", durationSetter(func(c *Config) *time.Duration { return &c.Processor.MaxBackoff })},
+	{"IMAGE_PROCESSOR_HEDGE_DELAY", "processor.hedge_delay", durationSetter(func(c *Config) *time.Duration { return &c.Processor.HedgeDelay })},
+	{"JWT_SECRET", "auth.jwt_secret", stringSetter(func(c *Config) *string { return &c.Auth.JWTSecret })},
+	{"JWT_PREVIOUS_SECRETS", "auth.jwt_previous_secrets", listSetter(func(c *Config) *[]string { return &c.Auth.JWTPreviousSecrets })},
+	{"JWT_AUDIENCE", "auth.jwt_audience", stringSetter(func(c *Config) *string { return &c.Auth.JWTAudience })},
+	{"JWT_ISSUER", "auth.jwt_issuer", stringSetter(func(c *Config) *string { return &c.Auth.JWTIssuer })},
+	{"JWKS_URL", "auth.jwks_url", stringSetter(func(c *Config) *string { return &c.Auth.JWKSURL })},
+	{"JWKS_REFRESH_INTERVAL", "auth.jwks_refresh_interval", durationSetter(func(c *Config) *time.Duration { return &c.Auth.JWKSRefreshInterval })},
+	{"JWT_ADMIN_ROLE", "auth.admin_role", stringSetter(func(c *Config) *string { return &c.Auth.AdminRole })},
+	{"JWT_REQUIRE_SCOPES", "auth.require_scopes", boolSetter(func(c *Config) *bool { return &c.Auth.RequireScopes })},
+	{"AUTH_TOKENS_ENABLED", "auth.tokens.enabled", boolSetter(func(c *Config) *bool { return &c.Auth.Tokens.Enabled })},
+	{"AUTH_TOKENS_ACCESS_TTL", "auth.tokens.access_ttl", durationSetter(func(c *Config) *time.Duration { return &c.Auth.Tokens.AccessTTL })},
+	{"AUTH_TOKENS_REFRESH_TTL", "auth.tokens.refresh_ttl", durationSetter(func(c *Config) *time.Duration { return &c.Auth.Tokens.RefreshTTL })},
+	{"VERIFICATION_RETRY_ATTEMPTS", "verification.retry_attempts", intSetter(func(c *Config) *int { return &c.Verification.RetryAttempts })},
+	{"VERIFICATION_RETRY_JITTER", "verification.retry_jitter", float64Setter(func(c *Config) *float64 { return &c.Verification.RetryJitter })},
+	{"VERIFICATION_RETRY_BUDGET", "verification.retry_budget", intSetter(func(c *Config) *int { return &c.Verification.RetryBudget })},
+	{"VERIFICATION_PROCESSING_TTL", "verification.processing_ttl", durationSetter(func(c *Config) *time.Duration { return &c.Verification.ProcessingTTL })},
+	{"VERIFICATION_DETACHED_TIMEOUT", "verification.detached_timeout", durationSetter(func(c *Config) *time.Duration { return &c.Verification.DetachedTimeout })},
+	{"VERIFICATION_WRITE_BUFFER_ENABLED", "verification.write_buffer.enabled", boolSetter(func(c *Config) *bool { return &c.Verification.WriteBuffer.Enabled })},
+	{"VERIFICATION_WRITE_BUFFER_MAX_ATTEMPTS", "verification.write_buffer.max_attempts", intSetter(func(c *Config) *int { return &c.Verification.WriteBuffer.MaxAttempts })},
+	{"VERIFICATION_DEFERRED_ENABLED", "verification.deferred.enabled", boolSetter(func(c *Config) *bool { return &c.Verification.Deferred.Enabled })},
+	{"VERIFICATION_DEFERRED_MAX_ATTEMPTS", "verification.deferred.max_attempts", intSetter(func(c *Config) *int { return &c.Verification.Deferred.MaxAttempts })},
+	{"VERIFICATION_MAX_DUPLICATES", "verification.max_duplicates", intSetter(func(c *Config) *int { return &c.Verification.MaxDuplicates })},
+	{"VERIFICATION_DUPLICATE_PRECHECK", "verification.duplicate_precheck", boolSetter(func(c *Config) *bool { return &c.Verification.DuplicatePrecheck })},
+	{"VERIFICATION_REQUEST_ID_FORMAT", "verification.request_id_format", stringSetter(func(c *Config) *string { return &c.Verification.RequestIDFormat })},
+	{"VERIFICATION_RESULT_TTL", "verification.result_ttl", durationSetter(func(c *Config) *time.Duration { return &c.Verification.ResultTTL })},
+	{"VERIFICATION_NOT_FOUND_TTL", "verification.not_found_ttl", durationSetter(func(c *Config) *time.Duration { return &c.Verification.NotFoundTTL })},
+	{"VERIFICATION_METRICS_SUMMARY_TTL", "verification.metrics_summary_ttl", durationSetter(func(c *Config) *time.Duration { return &c.Verification.MetricsSummaryTTL })},
+	{"VERIFICATION_METRICS_SUMMARY_STALE_TTL", "verification.metrics_summary_stale_ttl", durationSetter(func(c *Config) *time.Duration { return &c.Verification.MetricsSummaryStaleTTL })},
+	{"VERIFICATION_REVIEW_THRESHOLD", "verification.review_threshold", float64Setter(func(c *Config) *float64 { return &c.Verification.ReviewThreshold })},
+	{"VERIFICATION_REJECT_THRESHOLD", "verification.reject_threshold", float64Setter(func(c *Config) *float64 { return &c.Verification.RejectThreshold })},
+	{"VERIFICATION_THRESHOLD_AI_GENERATED", "verification.category_thresholds.ai_generated", float64Setter(func(c *Config) *float64 { return &c.Verification.CategoryThresholds.AIGenerated })},
+	{"VERIFICATION_THRESHOLD_MANIPULATED", "verification.category_thresholds.manipulated", float64Setter(func(c *Config) *float64 { return &c.Verification.CategoryThresholds.Manipulated })},
+	{"VERIFICATION_THRESHOLD_NSFW", "verification.category_thresholds.nsfw", float64Setter(func(c *Config) *float64 { return &c.Verification.CategoryThresholds.NSFW })},
+	{"VERIFICATION_THRESHOLD_WATERMARKED", "verification.category_thresholds.watermarked", float64Setter(func(c *Config) *float64 { return &c.Verification.CategoryThresholds.Watermarked })},
+	{"SHUTDOWN_STAGE_TIMEOUT", "shutdown.stage_timeout", durationSetter(func(c *Config) *time.Duration { return &c.Shutdown.StageTimeout })},
+	{"SHUTDOWN_DRAIN_DELAY", "shutdown.drain_delay", durationSetter(func(c *Config) *time.Duration { return &c.Shutdown.DrainDelay })},
+	{"LOG_LEVEL", "log.level", stringSetter(func(c *Config) *string { return &c.Log.Level })},
+	{"CONFIG_WATCH_INTERVAL", "reload.watch_interval", durationSetter(func(c *Config) *time.Duration { return &c.Reload.WatchInterval })},
+	{"WORKER_IN_PROCESS", "worker.in_process", boolSetter(func(c *Config) *bool { return &c.Worker.InProcess })},
+	{"WORKER_CONCURRENCY", "worker.concurrency", intSetter(func(c *Config) *int { return &c.Worker.Concurrency })},
+	{"WORKER_POLL_INTERVAL", "worker.poll_interval", durationSetter(func(c *Config) *time.Duration { return &c.Worker.PollInterval })},
+	{"WORKER_VISIBILITY_TIMEOUT", "worker.visibility_timeout", durationSetter(func(c *Config) *time.Duration { return &c.Worker.VisibilityTimeout })},
+	{"WORKER_MAX_ATTEMPTS", "worker.max_attempts", intSetter(func(c *Config) *int { return &c.Worker.MaxAttempts })},
+	{"WORKER_INITIAL_BACKOFF", "worker.initial_backoff", durationSetter(func(c *Config) *time.Duration { return &c.Worker.InitialBackoff })},
+	{"WORKER_MAX_BACKOFF", "worker.max_backoff", durationSetter(func(c *Config) *time.Duration { return &c.Worker.MaxBackoff })},
+	{"WORKER_METRICS_ADDR", "worker.metrics_addr", stringSetter(func(c *Config) *string { return &c.Worker.MetricsAddr })},
+	{"STORAGE_PROVIDER", "storage.provider", stringSetter(func(c *Config) *string { return &c.Storage.Provider })},
+	{"STORAGE_BUCKET", "storage.bucket", stringSetter(func(c *Config) *string { return &c.Storage.Bucket })},
+	{"STORAGE_REGION", "storage.region", stringSetter(func(c *Config) *string { return &c.Storage.Region })},
+	{"STORAGE_ENDPOINT", "storage.endpoint", stringSetter(func(c *Config) *string { return &c.Storage.Endpoint })},
+	{"STORAGE_PREFIX", "storage.prefix", stringSetter(func(c *Config) *string { return &c.Storage.Prefix })},
+	{"STORAGE_PATH_STYLE", "storage.path_style", boolSetter(func(c *Config) *bool { return &c.Storage.PathStyle })},
+	{"STORAGE_ACCESS_KEY_ID", "storage.access_key_id", stringSetter(func(c *Config) *string { return &c.Storage.AccessKeyID })},
+	{"STORAGE_SECRET_ACCESS_KEY", "storage.secret_access_key", stringSetter(func(c *Config) *string { return &c.Storage.SecretAccessKey })},
+	{"STORAGE_SIGNED_URL_TTL", "storage.signed_url_ttl", durationSetter(func(c *Config) *time.Duration { return &c.Storage.SignedURLTTL })},
+	{"STORAGE_DIR", "storage.dir", stringSetter(func(c *Config) *string { return &c.Storage.Dir })},
+	{"STORAGE_PUBLIC_URL", "storage.public_url", stringSetter(func(c *Config) *string { return &c.Storage.PublicURL })},
+	{"STORAGE_SIGNING_KEY", "storage.signing_key", stringSetter(func(c *Config) *string { return &c.Storage.SigningKey })},
+	{"USERS_DEFAULT_MONTHLY_QUOTA", "users.default_monthly_quota", int64Setter(func(c *Config) *int64 { return &c.Users.DefaultMonthlyQuota })},
+	{"MALWARE_SCAN_PROVIDER", "malware_scan.provider", stringSetter(func(c *Config) *string { return &c.MalwareScan.Provider })},
+	{"MALWARE_SCAN_ADDR", "malware_scan.addr", stringSetter(func(c *Config) *string { return &c.MalwareScan.Addr })},
+	{"MALWARE_SCAN_URL", "malware_scan.url", stringSetter(func(c *Config) *string { return &c.MalwareScan.URL })},
+	{"MALWARE_SCAN_TOKEN", "malware_scan.token", stringSetter(func(c *Config) *string { return &c.MalwareScan.Token })},
+	{"MALWARE_SCAN_TIMEOUT", "malware_scan.timeout", durationSetter(func(c *Config) *time.Duration { return &c.MalwareScan.Timeout })},
+	{"PREPROCESS_ENABLED", "preprocess.enabled", boolSetter(func(c *Config) *bool { return &c.Preprocess.Enabled })},
+	{"PREPROCESS_MAX_WIDTH", "preprocess.max_width", intSetter(func(c *Config) *int { return &c.Preprocess.MaxWidth })},
+	{"PREPROCESS_MAX_HEIGHT", "preprocess.max_height", intSetter(func(c *Config) *int { return &c.Preprocess.MaxHeight })},
+	{"PREPROCESS_AUTO_ROTATE", "preprocess.auto_rotate", boolSetter(func(c *Config) *bool { return &c.Preprocess.AutoRotate })},
+	{"PREPROCESS_STRIP_METADATA", "preprocess.strip_metadata", boolSetter(func(c *Config) *bool { return &c.Preprocess.StripMetadata })},
+	{"PREPROCESS_FORMAT", "preprocess.format", stringSetter(func(c *Config) *string { return &c.Preprocess.Format })},
+	{"PREPROCESS_JPEG_QUALITY", "preprocess.jpeg_quality", intSetter(func(c *Config) *int { return &c.Preprocess.JPEGQuality })},
+	{"WEBHOOKS_ENABLED", "webhooks.enabled", boolSetter(func(c *Config) *bool { return &c.Webhooks.Enabled })},
+	{"WEBHOOKS_TIMEOUT", "webhooks.timeout", durationSetter(func(c *Config) *time.Duration { return &c.Webhooks.Timeout })},
+	{"WEBHOOKS_MAX_ATTEMPTS", "webhooks.max_attempts", intSetter(func(c *Config) *int { return &c.Webhooks.MaxAttempts })},
+	{"WEBHOOKS_MAX_ENDPOINTS", "webhooks.max_endpoints", intSetter(func(c *Config) *int { return &c.Webhooks.MaxEndpoints })},
+	{"WEBHOOKS_ALLOW_HTTP", "webhooks.allow_http", boolSetter(func(c *Config) *bool { return &c.Webhooks.AllowHTTP })},
+	{"NOTIFY_SLACK_WEBHOOK_URL", "notifications.slack.webhook_url", stringSetter(func(c *Config) *string { return &c.Notifications.Slack.WebhookURL })},
+	{"NOTIFY_SMTP_ADDR", "notifications.smtp.addr", stringSetter(func(c *Config) *string { return &c.Notifications.SMTP.Addr })},
+	{"NOTIFY_SMTP_USERNAME", "notifications.smtp.username", stringSetter(func(c *Config) *string { return &c.Notifications.SMTP.Username })},
+	{"NOTIFY_SMTP_PASSWORD", "notifications.smtp.password", stringSetter(func(c *Config) *string { return &c.Notifications.SMTP.Password })},
+	{"NOTIFY_SMTP_FROM", "notifications.smtp.from", stringSetter(func(c *Config) *string { return &c.Notifications.SMTP.From })},
+	{"NOTIFY_SMTP_TO", "notifications.smtp.to", listSetter(func(c *Config) *[]string { return &c.Notifications.SMTP.To })},
+	{"NOTIFY_CONSECUTIVE_FAILURES", "notifications.consecutive_failures", intSetter(func(c *Config) *int { return &c.Notifications.ConsecutiveFailures })},
+	{"NOTIFY_ERROR_RATE_THRESHOLD", "notifications.error_rate.threshold", float64Setter(func(c *Config) *float64 { return &c.Notifications.ErrorRate.Threshold })},
+	{"NOTIFY_ERROR_RATE_WINDOW", "notifications.error_rate.window", durationSetter(func(c *Config) *time.Duration { return &c.Notifications.ErrorRate.Window })},
+	{"NOTIFY_ERROR_RATE_MIN_REQUESTS", "notifications.error_rate.min_requests", intSetter(func(c *Config) *int { return &c.Notifications.ErrorRate.MinRequests })},
+	{"NOTIFY_COOLDOWN", "notifications.cooldown", durationSetter(func(c *Config) *time.Duration { return &c.Notifications.Cooldown })},
+	{"NOTIFY_TIMEOUT", "notifications.timeout", durationSetter(func(c *Config) *time.Duration { return &c.Notifications.Timeout })},
+	{"WEBHOOKS_ALLOW_PRIVATE_NETWORKS", "webhooks.allow_private_networks", boolSetter(func(c *Config) *bool { return &c.Webhooks.AllowPrivateNetworks })},
+	{"TENANTS_CACHE_TTL", "tenants.cache_ttl", durationSetter(func(c *Config) *time.Duration { return &c.Tenants.CacheTTL })},
+	{"METERING_ENABLED", "metering.enabled", boolSetter(func(c *Config) *bool { return &c.Metering.Enabled })},
+	{"METERING_UNITS_PER_VERIFICATION", "metering.units_per_verification", int64Setter(func(c *Config) *int64 { return &c.Metering.UnitsPerVerification })},
+	{"STRIPE_API_KEY", "metering.stripe.api_key", stringSetter(func(c *Config) *string { return &c.Metering.Stripe.APIKey })},
+	{"STRIPE_METER_EVENT_NAME", "metering.stripe.meter_event_name", stringSetter(func(c *Config) *string { return &c.Metering.Stripe.MeterEventName })},
+	{"CRON_ENABLED", "cron.enabled", boolSetter(func(c *Config) *bool { return &c.Cron.Enabled })},
+	{"CRON_LEASE_TTL", "cron.lease_ttl", durationSetter(func(c *Config) *time.Duration { return &c.Cron.LeaseTTL })},
+	{"CRON_PURGE_LOGS_SCHEDULE", "cron.purge_logs.schedule", stringSetter(func(c *Config) *string { return &c.Cron.PurgeLogs.Schedule })},
+	{"CRON_PURGE_LOGS_RETENTION", "cron.purge_logs.retention", durationSetter(func(c *Config) *time.Duration { return &c.Cron.PurgeLogs.Retention })},
+	{"CRON_PURGE_LOGS_BATCH_SIZE", "cron.purge_logs.batch_size", intSetter(func(c *Config) *int { return &c.Cron.PurgeLogs.BatchSize })},
+	{"CRON_PURGE_ERASED_SCHEDULE", "cron.purge_erased.schedule", stringSetter(func(c *Config) *string { return &c.Cron.PurgeErased.Schedule })},
+	{"CRON_PURGE_ERASED_GRACE", "cron.purge_erased.grace", durationSetter(func(c *Config) *time.Duration { return &c.Cron.PurgeErased.Grace })},
+	{"CRON_PURGE_ERASED_BATCH_SIZE", "cron.purge_erased.batch_size", intSetter(func(c *Config) *int { return &c.Cron.PurgeErased.BatchSize })},
+	{"STRIPE_REPORT_INTERVAL", "metering.stripe.report_interval", durationSetter(func(c *Config) *time.Duration { return &c.Metering.Stripe.ReportInterval })},
+	{"WAREHOUSE_SINK", "warehouse.sink", stringSetter(func(c *Config) *string { return &c.Warehouse.Sink })},
+	{"WAREHOUSE_TABLE", "warehouse.table", stringSetter(func(c *Config) *string { return &c.Warehouse.Table })},
+	{"WAREHOUSE_SCHEDULE", "warehouse.schedule", stringSetter(func(c *Config) *string { return &c.Warehouse.Schedule })},
+	{"WAREHOUSE_BATCH_SIZE", "warehouse.batch_size", intSetter(func(c *Config) *int { return &c.Warehouse.BatchSize })},
+	{"WAREHOUSE_MAX_BATCHES", "warehouse.max_batches", intSetter(func(c *Config) *int { return &c.Warehouse.MaxBatches })},
+	{"WAREHOUSE_LAG", "warehouse.lag", durationSetter(func(c *Config) *time.Duration { return &c.Warehouse.Lag })},
+	{"WAREHOUSE_TIMEOUT", "warehouse.timeout", durationSetter(func(c *Config) *time.Duration { return &c.Warehouse.Timeout })},
+	{"BIGQUERY_PROJECT", "warehouse.bigquery.project", stringSetter(func(c *Config) *string { return &c.Warehouse.BigQuery.Project })},
+	{"BIGQUERY_DATASET", "warehouse.bigquery.dataset", stringSetter(func(c *Config) *string { return &c.Warehouse.BigQuery.Dataset })},
+	{"BIGQUERY_CREDENTIALS_FILE", "warehouse.bigquery.credentials_file", stringSetter(func(c *Config) *string { return &c.Warehouse.BigQuery.CredentialsFile })},
+	{"SNOWFLAKE_ACCOUNT", "warehouse.snowflake.account", stringSetter(func(c *Config) *string { return &c.Warehouse.Snowflake.Account })},
+	{"SNOWFLAKE_USER", "warehouse.snowflake.user", stringSetter(func(c *Config) *string { return &c.Warehouse.Snowflake.User })},
+	{"SNOWFLAKE_PRIVATE_KEY_FILE", "warehouse.snowflake.private_key_file", stringSetter(func(c *Config) *string { return &c.Warehouse.Snowflake.PrivateKeyFile })},
+	{"SNOWFLAKE_DATABASE", "warehouse.snowflake.database", stringSetter(func(c *Config) *string { return &c.Warehouse.Snowflake.Database })},
+	{"SNOWFLAKE_SCHEMA", "warehouse.snowflake.schema", stringSetter(func(c *Config) *string { return &c.Warehouse.Snowflake.Schema })},
+	{"SNOWFLAKE_WAREHOUSE", "warehouse.snowflake.warehouse", stringSetter(func(c *Config) *string { return &c.Warehouse.Snowflake.Warehouse })},
+	{"SNOWFLAKE_ROLE", "warehouse.snowflake.role", stringSetter(func(c *Config) *string { return &c.Warehouse.Snowflake.Role })},
+	{"CLICKHOUSE_URL", "warehouse.clickhouse.url", stringSetter(func(c *Config) *string { return &c.Warehouse.ClickHouse.URL })},
+	{"CLICKHOUSE_DATABASE", "warehouse.clickhouse.database", stringSetter(func(c *Config) *string { return &c.Warehouse.ClickHouse.Database })},
+	{"CLICKHOUSE_USERNAME", "warehouse.clickhouse.username", stringSetter(func(c *Config) *string { return &c.Warehouse.ClickHouse.Username })},
+	{"CLICKHOUSE_PASSWORD", "warehouse.clickhouse.password", stringSetter(func(c *Config) *string { return &c.Warehouse.ClickHouse.Password })},
+	{"EVENTS_BROKER", "events.broker", stringSetter(func(c *Config) *string { return &c.Events.Broker })},
+	{"EVENTS_FORMAT", "events.format", stringSetter(func(c *Config) *string { return &c.Events.Format })},
+	{"EVENTS_TIMEOUT", "events.timeout", durationSetter(func(c *Config) *time.Duration { return &c.Events.Timeout })},
+	{"KAFKA_REST_URL", "events.kafka.rest_url", stringSetter(func(c *Config) *string { return &c.Events.Kafka.RESTURL })},
+	{"KAFKA_TOPIC", "events.kafka.topic", stringSetter(func(c *Config) *string { return &c.Events.Kafka.Topic })},
+	{"KAFKA_USERNAME", "events.kafka.username", stringSetter(func(c *Config) *string { return &c.Events.Kafka.Username })},
+	{"KAFKA_PASSWORD", "events.kafka.password", stringSetter(func(c *Config) *string { return &c.Events.Kafka.Password })},
+	{"NATS_URL", "events.nats.url", stringSetter(func(c *Config) *string { return &c.Events.NATS.URL })},
+	{"NATS_SUBJECT", "events.nats.subject", stringSetter(func(c *Config) *string { return &c.Events.NATS.Subject })},
+	{"NATS_TOKEN", "events.nats.token", stringSetter(func(c *Config) *string { return &c.Events.NATS.Token })},
+	{"NATS_USERNAME", "events.nats.username", stringSetter(func(c *Config) *string { return &c.Events.NATS.Username })},
+	{"NATS_PASSWORD", "events.nats.password", stringSetter(func(c *Config) *string { return &c.Events.NATS.Password })},
+	{"NATS_JETSTREAM", "events.nats.jetstream", boolSetter(func(c *Config) *bool { return &c.Events.NATS.JetStream })},
+	{"EVENTS_REDIS_STREAM", "events.redis.stream", stringSetter(func(c *Config) *string { return &c.Events.Redis.Stream })},
+	{"EVENTS_REDIS_MAX_LEN", "events.redis.max_len", int64Setter(func(c *Config) *int64 { return &c.Events.Redis.MaxLen })},
+	{"EVENTS_OUTBOX_ENABLED", "events.outbox.enabled", boolSetter(func(c *Config) *bool { return &c.Events.Outbox.Enabled })},
+	{"EVENTS_OUTBOX_POLL_INTERVAL", "events.outbox.poll_interval", durationSetter(func(c *Config) *time.Duration { return &c.Events.Outbox.PollInterval })},
+	{"EVENTS_OUTBOX_BATCH_SIZE", "events.outbox.batch_size", intSetter(func(c *Config) *int { return &c.Events.Outbox.BatchSize })},
+	{"EVENTS_OUTBOX_RETENTION", "events.outbox.retention", durationSetter(func(c *Config) *time.Duration { return &c.Events.Outbox.Retention })},
+	{"TRACING_ENABLED", "tracing.enabled", boolSetter(func(c *Config) *bool { return &c.Tracing.Enabled })},
+	{"TRACING_ENDPOINT", "tracing.endpoint", stringSetter(func(c *Config) *string { return &c.Tracing.Endpoint })},
+	{"TRACING_HEADERS", "tracing.headers", listSetter(func(c *Config) *[]string { return &c.Tracing.Headers })},
+	{"TRACING_SERVICE_NAME", "tracing.service_name", stringSetter(func(c *Config) *string { return &c.Tracing.ServiceName })},
+	{"TRACING_SAMPLE_RATIO", "tracing.sample_ratio", float64Setter(func(c *Config) *float64 { return &c.Tracing.SampleRatio })},
+	{"TRACING_EXPORT_INTERVAL", "tracing.export_interval", durationSetter(func(c *Config) *time.Duration { return &c.Tracing.ExportInterval })},
+	{"HEALTH_TIMEOUT", "health.timeout", durationSetter(func(c *Config) *time.Duration { return &c.Health.Timeout })},
+	{"HEALTH_DATABASE_TIMEOUT", "health.database_timeout", durationSetter(func(c *Config) *time.Duration { return &c.Health.DatabaseTimeout })},
+	{"HEALTH_REDIS_TIMEOUT", "health.redis_timeout", durationSetter(func(c *Config) *time.Duration { return &c.Health.RedisTimeout })},
+	{"HEALTH_PROCESSOR_TIMEOUT", "health.processor_timeout", durationSetter(func(c *Config) *time.Duration { return &c.Health.ProcessorTimeout })},
+	{"SECRETS_PROVIDER", "secrets.provider", stringSetter(func(c *Config) *string { return &c.Secrets.Provider })},
+	{"SECRETS_REFRESH_INTERVAL", "secrets.refresh_interval", durationSetter(func(c *Config) *time.Duration { return &c.Secrets.RefreshInterval })},
+	{"SECRETS_TIMEOUT", "secrets.timeout", durationSetter(func(c *Config) *time.Duration { return &c.Secrets.Timeout })},
+	{"VAULT_ADDR", "secrets.vault.addr", stringSetter(func(c *Config) *string { return &c.Secrets.Vault.Addr })},
+	{"VAULT_TOKEN", "secrets.vault.token", stringSetter(func(c *Config) *string { return &c.Secrets.Vault.Token })},
+	{"VAULT_TOKEN_FILE", "secrets.vault.token_file", stringSetter(func(c *Config) *string { return &c.Secrets.Vault.TokenFile })},
+	{"VAULT_NAMESPACE", "secrets.vault.namespace", stringSetter(func(c *Config) *string { return &c.Secrets.Vault.Namespace })},
+	{"VAULT_KV_MOUNT", "secrets.vault.mount", stringSetter(func(c *Config) *string { return &c.Secrets.Vault.Mount })},
+	{"VAULT_SECRET_PATH", "secrets.vault.path", stringSetter(func(c *Config) *string { return &c.Secrets.Vault.Path })},
+	{"AWS_REGION", "secrets.aws.region", stringSetter(func(c *Config) *string { return &c.Secrets.AWS.Region })},
+	{"AWS_SECRETS_MANAGER_SECRET_ID", "secrets.aws.secret_id", stringSetter(func(c *Config) *string { return &c.Secrets.AWS.SecretID })},
+	{"AWS_SECRETS_MANAGER_ENDPOINT", "secrets.aws.endpoint", stringSetter(func(c *Config) *string { return &c.Secrets.AWS.Endpoint })},
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	for _, binding := range envBindings {
+		value, ok := lookup(binding.key)
+		if !ok || strings.TrimSpace(value) == "" {
+			continue
+		}
+		if err := binding.apply(c, strings.TrimSpace(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", binding.key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func stringSetter(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func listSetter(field func(*Config) *[]string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*field(c) = items
+		return nil
+	}
+}
+
+func boolSetter(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func intSetter(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func int64Setter(field func(*Config) *int64) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func float64Setter(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func durationSetter(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
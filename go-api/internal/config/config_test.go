@@ -0,0 +1,236 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadLayersFileAndEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	contents := `
+http:
+  addr: ":9090"
+verification:
+  result_ttl: 10m
+redis:
+  addr: "file-redis:6379"
+`
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	t.Setenv("REDIS_ADDR", "env-redis:6379")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected config to load, got error: %v", err)
+	}
+	if cfg.HTTP.Addr != ":9090" {
+		t.Fatalf("expected file value for http.addr, got %q", cfg.HTTP.Addr)
+	}
+	if cfg.Verification.ResultTTL != 10*time.Minute {
+		t.Fatalf("expected result ttl of 10m, got %s", cfg.Verification.ResultTTL)
+	}
+	if cfg.Redis.Addr != "env-redis:6379" {
+		t.Fatalf("expected env override for redis.addr, got %q", cfg.Redis.Addr)
+	}
+	if cfg.Database.MaxOpenConns != 10 {
+		t.Fatalf("expected default max open conns, got %d", cfg.Database.MaxOpenConns)
+	}
+}
+
+func TestLoadRejectsUnknownFileKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("http:\n  adr: \":9090\"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected unknown key to be rejected")
+	}
+}
+
+func TestLoadReadsTOMLFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	contents := `
+[http]
+addr = ":9090"
+
+[verification]
+result_ttl = "10m"
+
+[database]
+max_open_conns = 25
+`
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected config to load, got error: %v", err)
+	}
+	if cfg.HTTP.Addr != ":9090" || cfg.Verification.ResultTTL != 10*time.Minute || cfg.Database.MaxOpenConns != 25 {
+		t.Fatalf("expected the file values, got %q, %s, %d", cfg.HTTP.Addr, cfg.Verification.ResultTTL, cfg.Database.MaxOpenConns)
+	}
+
+	if err := os.WriteFile(path, []byte("[http]\nadr = \":9090\"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "adr") {
+		t.Fatalf("expected unknown key to be rejected, got %v", err)
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.HTTP.Addr = ""
+	cfg.Database.MaxOpenConns = 0
+	cfg.Verification.ResultTTL = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error, got nil")
+	}
+	for _, fragment := range []string{"http.addr", "database.max_open_conns", "verification.result_ttl"} {
+		if !strings.Contains(err.Error(), fragment) {
+			t.Fatalf("expected error to mention %s, got %v", fragment, err)
+		}
+	}
+}
+
+func TestApplyEnvRejectsMalformedValues(t *testing.T) {
+	cfg := Default()
+	env := map[string]string{"HTTP_SHUTDOWN_TIMEOUT": "soon"}
+
+	err := cfg.applyEnv(func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	})
+	if err == nil || !strings.Contains(err.Error(), "HTTP_SHUTDOWN_TIMEOUT") {
+		t.Fatalf("expected malformed duration error, got %v", err)
+	}
+}
+
+func TestValidateTLSSettings(t *testing.T) {
+	cfg := Default()
+	cfg.HTTP.TLS.CertFile = "server.crt"
+	cfg.HTTP.TLS.Autocert.Domains = []string{"api.example.com"}
+	cfg.HTTP.TLS.MinVersion = "1.0"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error, got nil")
+	}
+	for _, fragment := range []string{"mutually exclusive", "must be set together", "min_version"} {
+		if !strings.Contains(err.Error(), fragment) {
+			t.Fatalf("expected error to mention %q, got %v", fragment, err)
+		}
+	}
+
+	cfg = Default()
+	t.Setenv("HTTP_TLS_AUTOCERT_DOMAINS", "api.example.com, www.example.com")
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		t.Fatalf("unexpected env error: %v", err)
+	}
+	if !cfg.HTTP.TLS.Enabled() || len(cfg.HTTP.TLS.Autocert.Domains) != 2 {
+		t.Fatalf("expected autocert domains to enable TLS, got %+v", cfg.HTTP.TLS)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected autocert config to validate, got %v", err)
+	}
+}
+
+func TestValidateProxySettings(t *testing.T) {
+	cfg := Default()
+	cfg.HTTP.Proxy.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1", "lb.internal"}
+	cfg.HTTP.Proxy.TrustedPlatform = "X Client IP"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error, got nil")
+	}
+	for _, fragment := range []string{"trusted_proxies[2] \"lb.internal\"", "trusted_platform"} {
+		if !strings.Contains(err.Error(), fragment) {
+			t.Fatalf("expected error to mention %s, got %v", fragment, err)
+		}
+	}
+	if strings.Contains(err.Error(), "trusted_proxies[0]") || strings.Contains(err.Error(), "trusted_proxies[1]") {
+		t.Fatalf("expected CIDRs and addresses to be accepted, got %v", err)
+	}
+}
+
+func TestValidateProcessorAddresses(t *testing.T) {
+	cfg := Default()
+	for _, addr := range []string{"rust-service:50051", "dns:///rust-service-headless:50051", "processor-1:50051, processor-2:50051"} {
+		cfg.Processor.Addr = addr
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("expected %q to be accepted, got %v", addr, err)
+		}
+	}
+	cfg.Processor.Addr = "processor-1:50051,dns:///processor-2:50051"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "processor.addr") {
+		t.Fatalf("expected a list of targets to be rejected, got %v", err)
+	}
+}
+
+func TestValidateProcessorBackends(t *testing.T) {
+	cfg := Default()
+	cfg.Processor.Backend, cfg.Processor.Addr = "http", "https://processor.internal/v1/verify"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected a REST processor URL to be accepted, got %v", err)
+	}
+	cfg.Processor.Addr = "rust-service:50051"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "processor.addr") {
+		t.Fatalf("expected a gRPC address to be rejected with the http backend, got %v", err)
+	}
+	cfg.Processor.Backend, cfg.Processor.Addr = "mock", ""
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected the mock backend to need no address, got %v", err)
+	}
+	cfg.Processor.Backend = "onnx"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "processor.backend") {
+		t.Fatalf("expected an unknown backend to be rejected, got %v", err)
+	}
+}
+
+func TestLoadReportsEnvironmentAndValidationProblemsTogether(t *testing.T) {
+	t.Setenv("HTTP_SHUTDOWN_TIMEOUT", "soon")
+	t.Setenv("DATABASE_DSN", "postgres://user@db:notaport/app")
+	t.Setenv("JWT_SECRET", "too-short")
+	t.Setenv("REDIS_ADDR", "redis")
+
+	_, err := Load("")
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	if len(validationErr.Problems) != 4 {
+		t.Fatalf("expected 4 problems, got %d: %v", len(validationErr.Problems), err)
+	}
+	for _, fragment := range []string{
+		"HTTP_SHUTDOWN_TIMEOUT",
+		"database.dsn is not a valid PostgreSQL connection string",
+		"auth.jwt_secret must be at least 32 bytes, got 9 (env JWT_SECRET)",
+		"redis.addr \"redis\" must be host:port (env REDIS_ADDR)",
+	} {
+		if !strings.Contains(err.Error(), fragment) {
+			t.Fatalf("expected report to contain %q, got:\n%v", fragment, err)
+		}
+	}
+}
+
+func TestExampleConfigLoads(t *testing.T) {
+	cfg, err := Load(filepath.Join("..", "..", "config.example.yaml"))
+	if err != nil {
+		t.Fatalf("expected config.example.yaml to load, got %v", err)
+	}
+	if warnings := cfg.Warnings(); len(warnings) == 0 {
+		t.Fatal("expected a warning for the development JWT secret")
+	}
+}
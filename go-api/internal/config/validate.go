@@ -0,0 +1,647 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DevJWTSecret is the built-in signing secret meant for local development only.
+const DevJWTSecret = "dev-secret"
+
+// minJWTSecretLength is the shortest accepted HMAC secret; RFC 7518 requires keys of
+// at least the hash size, 32 bytes for HS256.
+const minJWTSecretLength = 32
+
+// ValidationError lists every configuration problem found in one pass.
+type ValidationError struct {
+	Problems []error
+}
+
+func newValidationError(problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d configuration problem(s):", len(e.Problems))
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(problem.Error())
+	}
+	return b.String()
+}
+
+// Unwrap exposes the individual problems to errors.Is and errors.As.
+func (e *ValidationError) Unwrap() []error { return e.Problems }
+
+// Validate checks the full configuration tree and reports every problem found.
+func (c *Config) Validate() error {
+	return newValidationError(c.problems())
+}
+
+// Warnings reports settings that are valid but unsafe outside local development.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.Auth.Tokens.Enabled && len(c.Auth.Tokens.Clients) == 0 {
+		warnings = append(warnings, "auth.tokens is enabled without clients; no tokens can be issued")
+	}
+	if c.Auth.JWTSecret == DevJWTSecret {
+		warnings = append(warnings, "auth.jwt_secret uses the built-in development secret; set JWT_SECRET before exposing this instance")
+	}
+	if c.Admin.Addr != "" && c.Admin.EnablePprof && !isLoopback(c.Admin.Addr) {
+		warnings = append(warnings, "admin.addr is not a loopback address and exposes pprof; restrict access at the network level")
+	}
+	if c.HTTP.ImageURLs.Enabled && c.HTTP.ImageURLs.AllowPrivateNetworks {
+		warnings = append(warnings, "http.image_urls.allow_private_networks lets users make this service call internal addresses; enable it only for trusted users")
+	}
+	if c.Webhooks.Enabled && c.Webhooks.AllowPrivateNetworks {
+		warnings = append(warnings, "webhooks.allow_private_networks lets users make this service call internal addresses; enable it only for trusted users")
+	}
+	return warnings
+}
+
+func (c *Config) problems() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, withEnvHint(fmt.Errorf(format, args...)))
+		}
+	}
+
+	check(c.HTTP.Addr != "", "http.addr must not be empty")
+	check(c.HTTP.Addr == "" || validListenAddr(c.HTTP.Addr), "http.addr %q must be host:port or :port", c.HTTP.Addr)
+	check(c.HTTP.ShutdownTimeout > 0, "http.shutdown_timeout must be positive")
+	check(c.HTTP.MaxUploadSize > 0, "http.max_upload_size must be positive")
+	check(c.HTTP.Spool.Threshold > 0, "http.spool.threshold must be positive")
+	check(c.HTTP.Images.MaxWidth >= 0, "http.images.max_width must not be negative")
+	check(c.HTTP.Images.MaxHeight >= 0, "http.images.max_height must not be negative")
+	check(c.HTTP.Images.MaxPixels >= 0, "http.images.max_pixels must not be negative")
+	check(c.HTTP.Images.MinWidth >= 0, "http.images.min_width must not be negative")
+	check(c.HTTP.Images.MinHeight >= 0, "http.images.min_height must not be negative")
+	check(c.HTTP.Images.MaxWidth == 0 || c.HTTP.Images.MinWidth <= c.HTTP.Images.MaxWidth, "http.images.min_width must not exceed http.images.max_width")
+	check(c.HTTP.Images.MaxHeight == 0 || c.HTTP.Images.MinHeight <= c.HTTP.Images.MaxHeight, "http.images.min_height must not exceed http.images.max_height")
+	check(c.HTTP.Images.MaxAspectRatio == 0 || c.HTTP.Images.MaxAspectRatio >= 1, "http.images.max_aspect_ratio must be 0 or at least 1")
+	check(!c.HTTP.ImageURLs.Enabled || c.HTTP.ImageURLs.Timeout > 0, "http.image_urls.timeout must be positive")
+	if tlsCfg := c.HTTP.TLS; tlsCfg.Enabled() {
+		usesFiles := tlsCfg.CertFile != "" || tlsCfg.KeyFile != ""
+		usesAutocert := len(tlsCfg.Autocert.Domains) > 0
+		check(!(usesFiles && usesAutocert), "http.tls: cert_file/key_file and autocert.domains are mutually exclusive")
+		check(!usesFiles || (tlsCfg.CertFile != "" && tlsCfg.KeyFile != ""), "http.tls.cert_file and http.tls.key_file must be set together")
+		check(!usesAutocert || tlsCfg.Autocert.CacheDir != "", "http.tls.autocert.cache_dir must not be empty")
+		check(tlsCfg.MinVersion == "1.2" || tlsCfg.MinVersion == "1.3", "http.tls.min_version must be 1.2 or 1.3, got %q", tlsCfg.MinVersion)
+		check(tlsCfg.RedirectAddr == "" || tlsCfg.RedirectAddr != c.HTTP.Addr, "http.tls.redirect_addr must differ from http.addr")
+		check(tlsCfg.RedirectAddr == "" || validListenAddr(tlsCfg.RedirectAddr), "http.tls.redirect_addr %q must be host:port or :port", tlsCfg.RedirectAddr)
+	}
+	if c.HTTP.UnixSocket.Path != "" {
+		_, modeErr := c.HTTP.UnixSocket.FileMode()
+		check(modeErr == nil, "http.unix_socket.mode: %v", modeErr)
+	}
+	check(c.HTTP.HTTP2.MaxConcurrentStreams > 0, "http.http2.max_concurrent_streams must be positive")
+	check(c.HTTP.HTTP2.MaxReadFrameSize >= 16<<10 && c.HTTP.HTTP2.MaxReadFrameSize <= 16<<20,
+		"http.http2.max_read_frame_size must be between 16KiB and 16MiB")
+	check(c.HTTP.HTTP2.IdleTimeout >= 0, "http.http2.idle_timeout must not be negative")
+	for i, proxy := range c.HTTP.Proxy.TrustedProxies {
+		check(validIPOrCIDR(proxy), "http.proxy.trusted_proxies[%d] %q must be an IP address or CIDR", i, proxy)
+	}
+	for i, header := range c.HTTP.Proxy.ClientIPHeaders {
+		check(validHeaderName(header), "http.proxy.client_ip_headers[%d] %q is not a valid header name", i, header)
+	}
+	if platform := c.HTTP.Proxy.TrustedPlatform; platform != "" && platform != "cloudflare" && platform != "google-app-engine" {
+		check(validHeaderName(platform), "http.proxy.trusted_platform %q must be cloudflare, google-app-engine or a header name", platform)
+	}
+
+	check(c.Admin.Addr == "" || c.Admin.Addr != c.HTTP.Addr, "admin.addr must differ from http.addr")
+	check(c.Admin.Addr == "" || validListenAddr(c.Admin.Addr), "admin.addr %q must be host:port or :port", c.Admin.Addr)
+	check(c.GRPC.Addr == "" || (c.GRPC.Addr != c.HTTP.Addr && c.GRPC.Addr != c.Admin.Addr), "grpc.addr must differ from http.addr and admin.addr")
+	check(c.GRPC.Addr == "" || validListenAddr(c.GRPC.Addr), "grpc.addr %q must be host:port or :port", c.GRPC.Addr)
+
+	check(c.Limits.MaxInFlight >= 0, "limits.max_in_flight must not be negative")
+	check(c.Limits.RetryAfter > 0, "limits.retry_after must be positive")
+	if adaptive := c.Limits.Adaptive; adaptive.Enabled {
+		check(adaptive.MinLimit > 0 && adaptive.MinLimit <= adaptive.MaxLimit, "limits.adaptive.min_limit must be positive and not exceed limits.adaptive.max_limit")
+		check(adaptive.InitialLimit >= adaptive.MinLimit && adaptive.InitialLimit <= adaptive.MaxLimit, "limits.adaptive.initial_limit must be between limits.adaptive.min_limit and limits.adaptive.max_limit")
+		check(adaptive.Tolerance > 1, "limits.adaptive.tolerance must be above 1")
+		check(adaptive.Backoff > 0 && adaptive.Backoff < 1, "limits.adaptive.backoff must be between 0 and 1")
+	}
+	for route, limit := range c.Limits.Routes {
+		method, path, found := strings.Cut(route, " ")
+		check(found && method != "" && strings.HasPrefix(path, "/"), "limits.routes key %q must look like \"METHOD /path\"", route)
+		check(limit >= 0, "limits.routes[%q] must not be negative", route)
+		check(c.Limits.MaxInFlight == 0 || limit <= c.Limits.MaxInFlight,
+			"limits.routes[%q] (%d) must not exceed limits.max_in_flight (%d)", route, limit, c.Limits.MaxInFlight)
+	}
+
+	if c.Limits.Rate.Enabled {
+		check(len(c.Limits.Rate.Groups) > 0, "limits.rate.groups must not be empty when limits.rate.enabled is set")
+	}
+	grouped := make(map[string]string)
+	for name, group := range c.Limits.Rate.Groups {
+		check(validSlug(name), "limits.rate.groups key %q must be 1-32 lowercase letters, digits or '-'", name)
+		check(len(group.Routes) > 0, "limits.rate.groups[%q].routes must not be empty", name)
+		for _, route := range group.Routes {
+			method, path, found := strings.Cut(route, " ")
+			check(found && method != "" && strings.HasPrefix(path, "/"), "limits.rate.groups[%q] route %q must look like \"METHOD /path\"", name, route)
+			other, taken := grouped[route]
+			check(!taken, "limits.rate.groups route %q is in both %q and %q", route, other, name)
+			grouped[route] = name
+		}
+		for scope, limit := range map[string]RateLimit{"per_user": group.PerUser, "per_ip": group.PerIP} {
+			check(limit.Requests >= 0 && limit.Burst >= 0, "limits.rate.groups[%q].%s must not be negative", name, scope)
+			check(limit.Requests == 0 || limit.Per >= time.Millisecond, "limits.rate.groups[%q].%s.per must be at least 1ms", name, scope)
+		}
+	}
+
+	check(c.Database.DSN != "", "database.dsn must not be empty")
+	if c.Database.DSN != "" {
+		_, dsnErr := pgconn.ParseConfig(c.Database.DSN)
+		check(dsnErr == nil, "database.dsn is not a valid PostgreSQL connection string: %v", dsnErr)
+	}
+	if c.Database.ReaderDSN != "" {
+		_, dsnErr := pgconn.ParseConfig(c.Database.ReaderDSN)
+		check(dsnErr == nil, "database.reader_dsn is not a valid PostgreSQL connection string: %v", dsnErr)
+	}
+	check(c.Database.MaxOpenConns > 0, "database.max_open_conns must be positive")
+	check(c.Database.MaxIdleConns >= 0 && c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns must be between 0 and database.max_open_conns")
+	check(c.Database.RetryAttempts >= 1, "database.retry_attempts must be at least 1")
+	check(c.Database.InitialBackoff <= c.Database.MaxBackoff, "database.initial_backoff must not exceed database.max_backoff")
+	if adaptive := c.Database.Adaptive; adaptive.Enabled {
+		check(adaptive.MinOpenConns > 0 && adaptive.MinOpenConns <= adaptive.MaxOpenConns,
+			"database.adaptive.min_open_conns must be positive and not exceed database.adaptive.max_open_conns")
+		check(adaptive.Interval > 0, "database.adaptive.interval must be positive")
+		check(adaptive.WaitThreshold >= 0, "database.adaptive.wait_threshold must not be negative")
+	}
+
+	check(c.Region.Name == "" || validSlug(c.Region.Name), "region.name %q must be 1-32 lowercase letters, digits or '-'", c.Region.Name)
+	if c.Region.ReplicaDSN != "" {
+		_, dsnErr := pgconn.ParseConfig(c.Region.ReplicaDSN)
+		check(dsnErr == nil, "region.replica_dsn is not a valid PostgreSQL connection string: %v", dsnErr)
+		check(c.Region.FailoverCooldown > 0, "region.failover_cooldown must be positive")
+	}
+
+	if sentinel := c.Redis.Sentinel; sentinel.MasterName != "" {
+		check(len(sentinel.Addrs) > 0, "redis.sentinel.addrs must not be empty when redis.sentinel.master_name is set")
+		for i, addr := range sentinel.Addrs {
+			check(validDialAddr(addr), "redis.sentinel.addrs[%d] %q must be host:port", i, addr)
+		}
+	} else {
+		check(len(sentinel.Addrs) == 0, "redis.sentinel.master_name must be set when redis.sentinel.addrs are")
+		check(c.Redis.Addr != "", "redis.addr must not be empty")
+		check(c.Redis.Addr == "" || validDialAddr(c.Redis.Addr), "redis.addr %q must be host:port", c.Redis.Addr)
+	}
+	for i, addr := range c.Redis.CacheCluster.Addrs {
+		check(validDialAddr(addr), "redis.cache_cluster.addrs[%d] %q must be host:port", i, addr)
+	}
+	check(c.Redis.DialTimeout > 0, "redis.dial_timeout must be positive")
+
+	switch c.Processor.Backend {
+	case "grpc":
+		check(c.Processor.Addr != "", "processor.addr must not be empty")
+		check(c.Processor.Addr == "" || validProcessorAddr(c.Processor.Addr), "processor.addr %q must be host:port, a comma-separated list of them or a gRPC target such as dns:///host:port", c.Processor.Addr)
+	case "http":
+		processorURL, urlErr := url.Parse(c.Processor.Addr)
+		check(urlErr == nil && processorURL.Host != "" && (processorURL.Scheme == "http" || processorURL.Scheme == "https"),
+			"processor.addr %q must be an http:// or https:// URL with the http backend", c.Processor.Addr)
+	case "mock":
+	default:
+		check(false, "processor.backend must be grpc, http or mock, not %q", c.Processor.Backend)
+	}
+	check(c.Processor.Timeout >= 0, "processor.timeout must not be negative")
+	check(c.Processor.RetryAttempts >= 1, "processor.retry_attempts must be at least 1")
+	check(c.Processor.InitialBackoff <= c.Processor.MaxBackoff, "processor.initial_backoff must not exceed processor.max_backoff")
+	check(c.Processor.HedgeDelay >= 0, "processor.hedge_delay must not be negative")
+	check(c.Processor.Timeout == 0 || c.Processor.HedgeDelay < c.Processor.Timeout, "processor.hedge_delay must be shorter than processor.timeout")
+
+	if experiment := c.Experiment; experiment.Name != "" || len(experiment.Variants) > 0 {
+		check(experiment.Name != "", "experiment.name must not be empty when experiment.variants are set")
+		check(len(experiment.Variants) > 0, "experiment.variants must not be empty when experiment.name is set")
+		total := 0
+		names := make(map[string]bool, len(experiment.Variants))
+		cohorts := make(map[string]bool)
+		check(c.Processor.Backend == "grpc" || !hasVariantProcessors(experiment.Variants), "experiment.variants[].processor_addr needs the grpc processor backend")
+		for i, variant := range experiment.Variants {
+			check(validSlug(variant.Name), "experiment.variants[%d].name %q must be 1-32 lowercase letters, digits or '-'", i, variant.Name)
+			check(!names[variant.Name], "experiment.variants[%d].name %q is used twice", i, variant.Name)
+			names[variant.Name] = true
+			check(variant.Percent >= 0 && variant.Percent <= 100, "experiment.variants[%d].percent must be between 0 and 100", i)
+			total += variant.Percent
+			check(variant.ProcessorAddr == "" || validProcessorAddr(variant.ProcessorAddr),
+				"experiment.variants[%d].processor_addr %q must be host:port, a comma-separated list of them or a gRPC target such as dns:///host:port", i, variant.ProcessorAddr)
+			for _, userID := range variant.Users {
+				check(!cohorts[userID], "experiment.variants[%d].users: %q is in more than one variant", i, userID)
+				cohorts[userID] = true
+			}
+		}
+		check(len(experiment.Variants) == 0 || total == 100, "experiment.variants percentages must add up to 100, not %d", total)
+	}
+
+	check(c.Auth.JWTSecret != "" || c.Auth.JWKSURL != "", "auth.jwt_secret must not be empty unless auth.jwks_url is set")
+	if c.Auth.JWKSURL != "" {
+		jwksURL, urlErr := url.Parse(c.Auth.JWKSURL)
+		check(urlErr == nil && (jwksURL.Scheme == "http" || jwksURL.Scheme == "https") && jwksURL.Host != "",
+			"auth.jwks_url must be an http or https URL")
+		check(c.Auth.JWKSRefreshInterval > 0, "auth.jwks_refresh_interval must be positive")
+	}
+	check(c.Auth.JWTSecret == "" || c.Auth.JWTSecret == DevJWTSecret || len(c.Auth.JWTSecret) >= minJWTSecretLength,
+		"auth.jwt_secret must be at least %d bytes, got %d", minJWTSecretLength, len(c.Auth.JWTSecret))
+	for i, secret := range c.Auth.JWTPreviousSecrets {
+		check(len(secret) >= minJWTSecretLength, "auth.jwt_previous_secrets[%d] must be at least %d bytes", i, minJWTSecretLength)
+	}
+	if tokens := c.Auth.Tokens; tokens.Enabled {
+		check(c.Auth.JWTSecret != "", "auth.jwt_secret must be set to sign the tokens of auth.tokens")
+		check(tokens.AccessTTL > 0, "auth.tokens.access_ttl must be positive")
+		check(tokens.RefreshTTL > tokens.AccessTTL, "auth.tokens.refresh_ttl must be longer than auth.tokens.access_ttl")
+		clientIDs := map[string]bool{}
+		for i, client := range tokens.Clients {
+			check(client.ID != "", "auth.tokens.clients[%d].id must not be empty", i)
+			check(!clientIDs[client.ID], "auth.tokens.clients[%d].id %q is used twice", i, client.ID)
+			clientIDs[client.ID] = true
+			_, costErr := bcrypt.Cost([]byte(client.SecretHash))
+			check(costErr == nil, "auth.tokens.clients[%d].secret_hash must be a bcrypt hash", i)
+		}
+	}
+
+	check(c.Verification.RetryAttempts >= 1, "verification.retry_attempts must be at least 1")
+	check(c.Verification.InitialBackoff <= c.Verification.MaxBackoff, "verification.initial_backoff must not exceed verification.max_backoff")
+	check(c.Verification.RetryJitter >= 0 && c.Verification.RetryJitter <= 1, "verification.retry_jitter must be between 0 and 1")
+	check(c.Verification.RetryBudget >= 0, "verification.retry_budget must not be negative")
+	check(c.Verification.ProcessingTTL > 0, "verification.processing_ttl must be positive")
+	check(c.Verification.DetachedTimeout >= 0, "verification.detached_timeout must not be negative")
+	check(c.Verification.MaxDuplicates >= 1, "verification.max_duplicates must be at least 1")
+	check(c.Verification.WriteBuffer.MaxAttempts >= 1, "verification.write_buffer.max_attempts must be at least 1")
+	check(c.Verification.Deferred.MaxAttempts >= 1, "verification.deferred.max_attempts must be at least 1")
+	check(!c.Verification.Deferred.Enabled || c.Storage.Provider != "",
+		"verification.deferred.enabled requires storage.provider, as queued verifications are completed from the stored image")
+	switch c.Verification.RequestIDFormat {
+	case "uuid", "uuidv7", "ulid":
+	default:
+		check(false, "verification.request_id_format must be uuid, uuidv7 or ulid, got %q", c.Verification.RequestIDFormat)
+	}
+	check(c.Verification.ResultTTL > 0, "verification.result_ttl must be positive")
+	check(c.Verification.NotFoundTTL >= 0, "verification.not_found_ttl must not be negative")
+	check(c.Verification.MetricsSummaryTTL >= 0, "verification.metrics_summary_ttl must not be negative")
+	check(c.Verification.MetricsSummaryStaleTTL >= 0, "verification.metrics_summary_stale_ttl must not be negative")
+	check(c.Verification.ReviewThreshold >= 0 && c.Verification.ReviewThreshold <= 1,
+		"verification.review_threshold must be between 0 and 1")
+	check(c.Verification.RejectThreshold >= 0 && c.Verification.RejectThreshold <= c.Verification.ReviewThreshold,
+		"verification.reject_threshold must be between 0 and verification.review_threshold")
+	thresholds := c.Verification.CategoryThresholds
+	for _, category := range []struct {
+		name      string
+		threshold float64
+	}{
+		{"ai_generated", thresholds.AIGenerated},
+		{"manipulated", thresholds.Manipulated},
+		{"nsfw", thresholds.NSFW},
+		{"watermarked", thresholds.Watermarked},
+	} {
+		check(category.threshold >= 0 && category.threshold <= 1, "verification.category_thresholds.%s must be between 0 and 1", category.name)
+	}
+	for version, points := range c.Verification.Calibration {
+		for i, point := range points {
+			check(point.Raw >= 0 && point.Raw <= 1 && point.Calibrated >= 0 && point.Calibrated <= 1,
+				"verification.calibration.%s[%d] scores must be between 0 and 1", version, i)
+			if i > 0 {
+				check(point.Raw > points[i-1].Raw, "verification.calibration.%s[%d] raw score must exceed the previous point's", version, i)
+				check(point.Calibrated >= points[i-1].Calibrated, "verification.calibration.%s[%d] calibrated score must not be below the previous point's", version, i)
+			}
+		}
+	}
+
+	check(c.Startup.Attempts >= 1, "startup.attempts must be at least 1")
+	check(c.Startup.AttemptTimeout > 0, "startup.attempt_timeout must be positive")
+	check(c.Startup.InitialBackoff <= c.Startup.MaxBackoff, "startup.initial_backoff must not exceed startup.max_backoff")
+
+	check(c.Shutdown.StageTimeout > 0, "shutdown.stage_timeout must be positive")
+	check(c.Shutdown.DrainDelay >= 0, "shutdown.drain_delay must not be negative")
+
+	_, levelErr := zapcore.ParseLevel(c.Log.Level)
+	check(levelErr == nil, "log.level %q is not a valid level", c.Log.Level)
+	check(c.Reload.WatchInterval >= 0, "reload.watch_interval must not be negative")
+
+	check(c.Worker.Concurrency >= 1, "worker.concurrency must be at least 1")
+	check(c.Worker.PollInterval > 0, "worker.poll_interval must be positive")
+	check(c.Worker.VisibilityTimeout >= 3*time.Second, "worker.visibility_timeout must be at least 3s")
+	check(c.Worker.MaxAttempts >= 1, "worker.max_attempts must be at least 1")
+	check(c.Worker.InitialBackoff <= c.Worker.MaxBackoff, "worker.initial_backoff must not exceed worker.max_backoff")
+	check(c.Worker.MetricsAddr == "" || validListenAddr(c.Worker.MetricsAddr), "worker.metrics_addr %q must be host:port or :port", c.Worker.MetricsAddr)
+
+	if storage := c.Storage; storage.Provider == "local" {
+		// Images on local disk are served by the API itself through HMAC-signed URLs.
+		check(storage.Dir != "", "storage.dir must not be empty for local")
+		publicURL, publicURLErr := url.Parse(storage.PublicURL)
+		check(publicURLErr == nil && (publicURL.Scheme == "http" || publicURL.Scheme == "https") && publicURL.Host != "",
+			"storage.public_url %q must be an http(s) URL for local", storage.PublicURL)
+		check(len(storage.SigningKey) >= minJWTSecretLength,
+			"storage.signing_key must be at least %d bytes for local, got %d", minJWTSecretLength, len(storage.SigningKey))
+	} else if storage.Provider != "" {
+		check(storage.Provider == "s3" || storage.Provider == "minio" || storage.Provider == "gcs",
+			"storage.provider must be s3, minio, gcs, local or empty, got %q", storage.Provider)
+		check(storage.Bucket != "", "storage.bucket must not be empty")
+		check(storage.Provider != "s3" || storage.Region != "", "storage.region must not be empty for s3")
+		check(storage.Provider != "minio" || storage.Endpoint != "", "storage.endpoint must be set for minio")
+		if storage.Endpoint != "" {
+			endpoint, endpointErr := url.Parse(storage.Endpoint)
+			check(endpointErr == nil && (endpoint.Scheme == "http" || endpoint.Scheme == "https") && endpoint.Host != "",
+				"storage.endpoint %q must be an http(s) URL", storage.Endpoint)
+		}
+		check(storage.AccessKeyID != "" && storage.SecretAccessKey != "",
+			"storage.access_key_id and storage.secret_access_key must be set")
+	}
+	// SigV4 presigned URLs are valid for at most seven days.
+	check(c.Storage.SignedURLTTL > 0 && c.Storage.SignedURLTTL <= 7*24*time.Hour,
+		"storage.signed_url_ttl must be positive and at most 168h")
+
+	check(c.Users.DefaultMonthlyQuota >= 0, "users.default_monthly_quota must not be negative")
+	for tier, quota := range c.Users.TierQuotas {
+		check(quota >= 0, "users.tier_quotas.%s must not be negative", tier)
+	}
+
+	switch scan := c.MalwareScan; scan.Provider {
+	case "":
+	case "clamav":
+		_, _, addrErr := net.SplitHostPort(scan.Addr)
+		check(addrErr == nil, "malware_scan.addr %q must be a host:port for clamav", scan.Addr)
+	case "http":
+		scanURL, scanURLErr := url.Parse(scan.URL)
+		check(scanURLErr == nil && (scanURL.Scheme == "http" || scanURL.Scheme == "https") && scanURL.Host != "",
+			"malware_scan.url %q must be an http(s) URL for http", scan.URL)
+	default:
+		check(false, "malware_scan.provider must be clamav, http or empty, got %q", scan.Provider)
+	}
+	check(c.MalwareScan.Timeout > 0, "malware_scan.timeout must be positive")
+
+	if pre := c.Preprocess; pre.Enabled {
+		check(pre.MaxWidth >= 0 && pre.MaxHeight >= 0, "preprocess.max_width and preprocess.max_height must not be negative")
+		check(pre.Format == "" || pre.Format == "jpeg" || pre.Format == "png",
+			"preprocess.format must be jpeg, png or empty, got %q", pre.Format)
+		check(pre.JPEGQuality >= 1 && pre.JPEGQuality <= 100, "preprocess.jpeg_quality must be between 1 and 100")
+	}
+
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive")
+	check(c.Webhooks.MaxAttempts >= 1, "webhooks.max_attempts must be at least 1")
+	check(c.Webhooks.MaxEndpoints >= 1, "webhooks.max_endpoints must be at least 1")
+	check(c.Tenants.CacheTTL > 0, "tenants.cache_ttl must be positive")
+
+	if c.Cron.Enabled {
+		// A lease shorter than a few ticks of the one-second scheduler would flap.
+		check(c.Cron.LeaseTTL >= 5*time.Second, "cron.lease_ttl must be at least 5s")
+		check(c.Cron.PurgeLogs.Retention >= 0, "cron.purge_logs.retention must not be negative")
+		if c.Cron.PurgeLogs.Retention > 0 {
+			check(strings.TrimSpace(c.Cron.PurgeLogs.Schedule) != "", "cron.purge_logs.schedule must not be empty")
+			check(c.Cron.PurgeLogs.BatchSize >= 1, "cron.purge_logs.batch_size must be at least 1")
+		}
+		if strings.TrimSpace(c.Cron.PurgeErased.Schedule) != "" {
+			check(c.Cron.PurgeErased.Grace >= 0, "cron.purge_erased.grace must not be negative")
+			check(c.Cron.PurgeErased.BatchSize >= 1, "cron.purge_erased.batch_size must be at least 1")
+		}
+	}
+
+	if warehouse := c.Warehouse; warehouse.Sink != "" {
+		check(warehouse.Table != "", "warehouse.table must not be empty")
+		check(strings.TrimSpace(warehouse.Schedule) != "", "warehouse.schedule must not be empty")
+		check(c.Cron.Enabled, "warehouse.sink requires cron.enabled")
+		check(warehouse.BatchSize >= 1, "warehouse.batch_size must be at least 1")
+		check(warehouse.MaxBatches >= 1, "warehouse.max_batches must be at least 1")
+		check(warehouse.Lag >= 0, "warehouse.lag must not be negative")
+		check(warehouse.Timeout > 0, "warehouse.timeout must be positive")
+		switch warehouse.Sink {
+		case "bigquery":
+			check(warehouse.BigQuery.Project != "" && warehouse.BigQuery.Dataset != "",
+				"warehouse.bigquery.project and warehouse.bigquery.dataset are required")
+			check(warehouse.BigQuery.CredentialsFile != "", "warehouse.bigquery.credentials_file is required")
+		case "snowflake":
+			check(warehouse.Snowflake.Account != "" && warehouse.Snowflake.User != "",
+				"warehouse.snowflake.account and warehouse.snowflake.user are required")
+			check(warehouse.Snowflake.PrivateKeyFile != "", "warehouse.snowflake.private_key_file is required")
+			check(warehouse.Snowflake.Database != "" && warehouse.Snowflake.Schema != "",
+				"warehouse.snowflake.database and warehouse.snowflake.schema are required")
+		case "clickhouse":
+			chURL, urlErr := url.Parse(warehouse.ClickHouse.URL)
+			check(urlErr == nil && (chURL.Scheme == "http" || chURL.Scheme == "https") && chURL.Host != "",
+				"warehouse.clickhouse.url must be an http or https URL")
+		default:
+			check(false, "warehouse.sink must be bigquery, snowflake or clickhouse, got %q", warehouse.Sink)
+		}
+	}
+
+	if events := c.Events; events.Broker != "" {
+		check(events.Format == "json" || events.Format == "avro", "events.format must be json or avro, got %q", events.Format)
+		check(events.Timeout > 0, "events.timeout must be positive")
+		switch events.Broker {
+		case "kafka":
+			restURL, urlErr := url.Parse(events.Kafka.RESTURL)
+			check(urlErr == nil && (restURL.Scheme == "http" || restURL.Scheme == "https") && restURL.Host != "",
+				"events.kafka.rest_url must be an http or https URL")
+			check(events.Kafka.Topic != "", "events.kafka.topic must not be empty")
+		case "nats":
+			natsURL, urlErr := url.Parse(events.NATS.URL)
+			check(urlErr == nil && (natsURL.Scheme == "nats" || natsURL.Scheme == "tls") && natsURL.Host != "",
+				"events.nats.url must be a nats:// or tls:// URL")
+			check(!strings.ContainsAny(events.NATS.Subject, " \t*>"), "events.nats.subject must not contain spaces or wildcards")
+		case "redis":
+			check(events.Redis.Stream != "", "events.redis.stream must not be empty")
+			check(events.Redis.MaxLen >= 0, "events.redis.max_len must not be negative")
+		default:
+			check(false, "events.broker must be kafka, nats or redis, got %q", events.Broker)
+		}
+	}
+	if outbox := c.Events.Outbox; outbox.Enabled {
+		check(outbox.PollInterval > 0, "events.outbox.poll_interval must be positive")
+		check(outbox.BatchSize > 0, "events.outbox.batch_size must be positive")
+		check(outbox.Retention > 0, "events.outbox.retention must be positive")
+	}
+
+	check(c.Health.Timeout > 0, "health.timeout must be positive")
+	check(c.Health.DatabaseTimeout >= 0 && c.Health.RedisTimeout >= 0 && c.Health.ProcessorTimeout >= 0,
+		"health.database_timeout, health.redis_timeout and health.processor_timeout must not be negative")
+
+	if tracing := c.Tracing; tracing.Enabled {
+		endpoint, endpointErr := url.Parse(tracing.Endpoint)
+		check(endpointErr == nil && (endpoint.Scheme == "http" || endpoint.Scheme == "https") && endpoint.Host != "",
+			"tracing.endpoint must be an http or https URL")
+		for _, header := range tracing.Headers {
+			name, _, ok := strings.Cut(header, "=")
+			check(ok && strings.TrimSpace(name) != "", "tracing.headers must be name=value pairs, got %q", header)
+		}
+		check(tracing.ServiceName != "", "tracing.service_name must not be empty")
+		check(tracing.SampleRatio >= 0 && tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+		check(tracing.ExportInterval > 0, "tracing.export_interval must be positive")
+	}
+
+	if c.Metering.Enabled {
+		check(c.Metering.UnitsPerVerification >= 1, "metering.units_per_verification must be at least 1")
+		if c.Metering.Stripe.APIKey != "" {
+			check(c.Metering.Stripe.MeterEventName != "", "metering.stripe.meter_event_name must not be empty")
+			check(c.Metering.Stripe.ReportInterval >= time.Minute, "metering.stripe.report_interval must be at least 1m")
+		}
+	}
+
+	if notifications := c.Notifications; notifications.Enabled() {
+		if notifications.Slack.WebhookURL != "" {
+			hook, hookErr := url.Parse(notifications.Slack.WebhookURL)
+			check(hookErr == nil && hook.Scheme == "https" && hook.Host != "",
+				"notifications.slack.webhook_url must be an https URL")
+		}
+		if smtpCfg := notifications.SMTP; smtpCfg.Addr != "" {
+			_, port, addrErr := net.SplitHostPort(smtpCfg.Addr)
+			check(addrErr == nil && port != "", "notifications.smtp.addr %q must be host:port", smtpCfg.Addr)
+			check(smtpCfg.From != "", "notifications.smtp.from must not be empty")
+			check(len(smtpCfg.To) > 0, "notifications.smtp.to must list at least one recipient")
+		}
+		check(notifications.ConsecutiveFailures >= 0, "notifications.consecutive_failures must not be negative")
+		check(notifications.ErrorRate.Threshold >= 0 && notifications.ErrorRate.Threshold <= 1,
+			"notifications.error_rate.threshold must be between 0 and 1")
+		check(notifications.ErrorRate.Window > 0, "notifications.error_rate.window must be positive")
+		check(notifications.ErrorRate.MinRequests >= 1, "notifications.error_rate.min_requests must be at least 1")
+		check(notifications.Cooldown >= 0, "notifications.cooldown must not be negative")
+		check(notifications.Timeout > 0, "notifications.timeout must be positive")
+		for rule := range notifications.Templates {
+			check(rule == "consecutive_failures" || rule == "error_rate",
+				"notifications.templates has unknown rule %q; use consecutive_failures or error_rate", rule)
+		}
+	}
+
+	switch c.Secrets.Provider {
+	case "":
+	case "vault":
+		vault := c.Secrets.Vault
+		addr, addrErr := url.Parse(vault.Addr)
+		check(addrErr == nil && (addr.Scheme == "http" || addr.Scheme == "https") && addr.Host != "",
+			"secrets.vault.addr %q must be an http(s) URL", vault.Addr)
+		check(vault.Token != "" || vault.TokenFile != "", "secrets.vault.token or secrets.vault.token_file must be set")
+		check(vault.Mount != "", "secrets.vault.mount must not be empty")
+		check(vault.Path != "", "secrets.vault.path must not be empty")
+	case "aws":
+		check(c.Secrets.AWS.Region != "", "secrets.aws.region must not be empty")
+		check(c.Secrets.AWS.SecretID != "", "secrets.aws.secret_id must not be empty")
+	default:
+		check(false, "secrets.provider must be vault, aws or empty, got %q", c.Secrets.Provider)
+	}
+	check(c.Secrets.RefreshInterval >= 0, "secrets.refresh_interval must not be negative")
+	check(c.Secrets.Timeout > 0, "secrets.timeout must be positive")
+
+	return errs
+}
+
+// withEnvHint names the environment variable that sets the key a problem refers to.
+func withEnvHint(err error) error {
+	key, _, _ := strings.Cut(err.Error(), " ")
+	for _, binding := range envBindings {
+		if binding.path == key {
+			return fmt.Errorf("%w (env %s)", err, binding.key)
+		}
+	}
+	return err
+}
+
+// unjoin flattens an errors.Join result back into its parts.
+func unjoin(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+func validListenAddr(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && validPort(port)
+}
+
+func validDialAddr(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	return err == nil && host != "" && validPort(port)
+}
+
+func validGRPCTarget(target string) bool {
+	if scheme, rest, found := strings.Cut(target, ":"); found && (strings.HasPrefix(rest, "//") || scheme == "unix") {
+		return scheme != "" && strings.TrimLeft(rest, "/") != ""
+	}
+	return validDialAddr(target)
+}
+
+// hasVariantProcessors reports whether a variant names a processor of its own.
+func hasVariantProcessors(variants []ExperimentVariantConfig) bool {
+	for _, variant := range variants {
+		if variant.ProcessorAddr != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// validProcessorAddr accepts the addresses of grpcclient.DialImageProcessor: a gRPC
+// target, or host:port addresses separated by commas.
+func validProcessorAddr(addr string) bool {
+	if !strings.Contains(addr, ",") {
+		return validGRPCTarget(addr)
+	}
+	for _, part := range strings.Split(addr, ",") {
+		if !validDialAddr(strings.TrimSpace(part)) {
+			return false
+		}
+	}
+	return true
+}
+
+func validIPOrCIDR(value string) bool {
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
+	}
+	return net.ParseIP(value) != nil
+}
+
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func validSlug(name string) bool {
+	if name == "" || len(name) > 32 {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 0 && n <= 65535
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
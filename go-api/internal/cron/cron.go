@@ -0,0 +1,297 @@
+// Package cron runs maintenance tasks on schedules. Every replica runs a Scheduler,
+// but only the one holding a lease in Redis fires tasks, so each activation happens
+// once across the deployment. Tasks are expected to be quick, typically enqueueing a
+// worker job that does the actual work.
+package cron
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const keyPrefix = "ai-check:cron:"
+
+const leaderKey = keyPrefix + "leader"
+
+// Func runs one activation of a task. scheduled is the activation time, which is
+// the same on every replica and can serve as a deduplication key.
+type Func func(ctx context.Context, scheduled time.Time) error
+
+// Options tunes leader election.
+type Options struct {
+	// LeaseTTL is how long leadership survives without renewal, and so how long
+	// tasks pause when the leader dies.
+	LeaseTTL time.Duration
+	// TickInterval is how often the lease is renewed and due tasks are checked.
+	TickInterval time.Duration
+}
+
+// DefaultOptions returns the tunables used by NewScheduler.
+func DefaultOptions() Options {
+	return Options{LeaseTTL: 15 * time.Second, TickInterval: time.Second}
+}
+
+// TaskStatus describes a registered task.
+type TaskStatus struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	LastRun  *time.Time `json:"last_run,omitempty"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	// LastError is the error of the last activation, if it failed.
+	LastError string `json:"last_error,omitempty"`
+}
+
+type task struct {
+	name     string
+	spec     string
+	schedule Schedule
+	run      Func
+}
+
+// Scheduler fires registered tasks while this replica is the leader.
+type Scheduler struct {
+	redis  *redis.Client
+	logger *zap.Logger
+	opts   Options
+	id     string
+	now    func() time.Time
+
+	mu     sync.Mutex
+	tasks  []*task
+	leader bool
+}
+
+// NewScheduler returns a scheduler with DefaultOptions.
+func NewScheduler(client *redis.Client, logger *zap.Logger) *Scheduler {
+	return NewSchedulerWithOptions(client, logger, DefaultOptions())
+}
+
+// NewSchedulerWithOptions returns a scheduler with explicit tunables.
+func NewSchedulerWithOptions(client *redis.Client, logger *zap.Logger, opts Options) *Scheduler {
+	return &Scheduler{
+		redis:  client,
+		logger: logger.Named("cron"),
+		opts:   opts,
+		id:     instanceID(),
+		now:    time.Now,
+	}
+}
+
+func instanceID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return host + "-" + hex.EncodeToString(suffix)
+}
+
+// InstanceID identifies this scheduler in the leader lease.
+func (s *Scheduler) InstanceID() string {
+	return s.id
+}
+
+// Register adds a task. It must be called before Run.
+func (s *Scheduler) Register(name, spec string, run Func) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.tasks {
+		if existing.name == name {
+			return fmt.Errorf("task %q is already registered", name)
+		}
+	}
+	s.tasks = append(s.tasks, &task{name: name, spec: spec, schedule: schedule, run: run})
+	return nil
+}
+
+// Run checks for due tasks every TickInterval until ctx ends, then gives up
+// leadership so another replica can take over without waiting for the lease.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.TickInterval)
+	defer ticker.Stop()
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			s.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context) {
+	leader, err := s.campaign(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Warn("cron leader election failed", zap.Error(err))
+		}
+		return
+	}
+	if !leader {
+		return
+	}
+	s.mu.Lock()
+	tasks := append([]*task(nil), s.tasks...)
+	s.mu.Unlock()
+	for _, t := range tasks {
+		if err := s.runIfDue(ctx, t); err != nil && ctx.Err() == nil {
+			s.logger.Error("cron task bookkeeping failed", zap.String("task", t.name), zap.Error(err))
+		}
+	}
+}
+
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// campaign renews the lease this replica holds or tries to acquire a free one.
+func (s *Scheduler) campaign(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	wasLeader := s.leader
+	s.mu.Unlock()
+
+	var leader bool
+	if wasLeader {
+		renewed, err := renewScript.Run(ctx, s.redis, []string{leaderKey}, s.id, s.opts.LeaseTTL.Milliseconds()).Int()
+		if err != nil {
+			return false, err
+		}
+		leader = renewed == 1
+	}
+	if !leader {
+		acquired, err := s.redis.SetNX(ctx, leaderKey, s.id, s.opts.LeaseTTL).Result()
+		if err != nil {
+			return false, err
+		}
+		leader = acquired
+	}
+
+	if leader != wasLeader {
+		if leader {
+			s.logger.Info("acquired cron leadership", zap.String("instance", s.id))
+		} else {
+			s.logger.Warn("lost cron leadership", zap.String("instance", s.id))
+		}
+	}
+	s.mu.Lock()
+	s.leader = leader
+	s.mu.Unlock()
+	return leader, nil
+}
+
+func (s *Scheduler) resign() {
+	s.mu.Lock()
+	wasLeader := s.leader
+	s.leader = false
+	s.mu.Unlock()
+	if !wasLeader {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := releaseScript.Run(ctx, s.redis, []string{leaderKey}, s.id).Err(); err != nil {
+		s.logger.Warn("failed to release cron leadership", zap.Error(err))
+	}
+}
+
+func taskKey(name string) string {
+	return keyPrefix + "task:" + name
+}
+
+// runIfDue fires t when an activation passed since its last run. Activations missed
+// while no replica was leader are collapsed into the latest one. A failed activation
+// is not retried; the task runs again at its next activation.
+func (s *Scheduler) runIfDue(ctx context.Context, t *task) error {
+	now := s.now().UTC()
+	key := taskKey(t.name)
+	// last_scheduled is the latest activation handled; a new task starts counting from
+	// now rather than firing immediately.
+	raw, err := s.redis.HGet(ctx, key, "last_scheduled").Result()
+	if errors.Is(err, redis.Nil) {
+		return s.redis.HSetNX(ctx, key, "last_scheduled", now.Unix()).Err()
+	}
+	if err != nil {
+		return err
+	}
+	lastUnix, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return fmt.Errorf("corrupt last_scheduled %q: %w", raw, err)
+	}
+
+	scheduled := t.schedule.Next(time.Unix(lastUnix, 0).UTC())
+	if scheduled.IsZero() || scheduled.After(now) {
+		return nil
+	}
+	for i := 0; i < 10000; i++ {
+		next := t.schedule.Next(scheduled)
+		if next.IsZero() || next.After(now) {
+			break
+		}
+		scheduled = next
+	}
+
+	runErr := t.run(ctx, scheduled)
+	lastError := ""
+	if runErr != nil {
+		lastError = runErr.Error()
+		s.logger.Error("cron task failed", zap.String("task", t.name), zap.Time("scheduled", scheduled), zap.Error(runErr))
+	} else {
+		s.logger.Info("cron task ran", zap.String("task", t.name), zap.Time("scheduled", scheduled))
+	}
+	return s.redis.HSet(ctx, key, "last_scheduled", scheduled.Unix(), "last_run", now.Unix(), "last_error", lastError).Err()
+}
+
+// Status reports every registered task and the instance currently holding the
+// lease, which is empty while no replica leads.
+func (s *Scheduler) Status(ctx context.Context) ([]TaskStatus, string, error) {
+	leader, err := s.redis.Get(ctx, leaderKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, "", err
+	}
+	s.mu.Lock()
+	tasks := append([]*task(nil), s.tasks...)
+	s.mu.Unlock()
+
+	statuses := make([]TaskStatus, 0, len(tasks))
+	for _, t := range tasks {
+		fields, err := s.redis.HGetAll(ctx, taskKey(t.name)).Result()
+		if err != nil {
+			return nil, "", err
+		}
+		status := TaskStatus{Name: t.name, Schedule: t.spec, LastError: fields["last_error"]}
+		if lastUnix, err := strconv.ParseInt(fields["last_run"], 10, 64); err == nil {
+			last := time.Unix(lastUnix, 0).UTC()
+			status.LastRun = &last
+		}
+		if scheduledUnix, err := strconv.ParseInt(fields["last_scheduled"], 10, 64); err == nil {
+			if next := t.schedule.Next(time.Unix(scheduledUnix, 0).UTC()); !next.IsZero() {
+				status.NextRun = &next
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, leader, nil
+}
@@ -0,0 +1,144 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// 2024-01-01 is a Monday.
+	from := time.Date(2024, 1, 1, 10, 30, 15, 0, time.UTC)
+	cases := map[string]time.Time{
+		"*/15 * * * *":   time.Date(2024, 1, 1, 10, 45, 0, 0, time.UTC),
+		"0 3 * * *":      time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC),
+		"30 10 * * *":    time.Date(2024, 1, 2, 10, 30, 0, 0, time.UTC),
+		"0 9-17/4 * * *": time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC),
+		"0 0 * * 7":      time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC),
+		"0 0 15 * 3":     time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":     time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		"@monthly":       time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		"@every 2h":      time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		"0 0 31 2 *":     {},
+	}
+	for spec, want := range cases {
+		schedule, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("%s: Parse returned error: %v", spec, err)
+		}
+		if got := schedule.Next(from); !got.Equal(want) {
+			t.Fatalf("%s: expected %s, got %s", spec, want, got)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "@every 10ms", "@yearly"} {
+		if _, err := Parse(spec); err == nil {
+			t.Fatalf("%q: expected an error", spec)
+		}
+	}
+}
+
+func newTestScheduler(t *testing.T, client *redis.Client, now *time.Time) *Scheduler {
+	t.Helper()
+	scheduler := NewSchedulerWithOptions(client, zap.NewNop(), Options{LeaseTTL: 15 * time.Second, TickInterval: time.Second})
+	scheduler.now = func() time.Time { return *now }
+	return scheduler
+}
+
+func TestOnlyTheLeaderRunsTasks(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 10, 0, 30, 0, time.UTC)
+
+	var runs []time.Time
+	var fail bool
+	record := func(ctx context.Context, scheduled time.Time) error {
+		runs = append(runs, scheduled)
+		if fail {
+			return errors.New("queue unavailable")
+		}
+		return nil
+	}
+	first := newTestScheduler(t, client, &now)
+	second := newTestScheduler(t, client, &now)
+	for _, scheduler := range []*Scheduler{first, second} {
+		if err := scheduler.Register("purge", "*/5 * * * *", record); err != nil {
+			t.Fatalf("Register returned error: %v", err)
+		}
+	}
+	if err := first.Register("purge", "@hourly", record); err == nil {
+		t.Fatal("expected a duplicate task name to be rejected")
+	}
+
+	first.tick(ctx)
+	second.tick(ctx)
+	if !first.leader || second.leader {
+		t.Fatal("expected exactly the first scheduler to lead")
+	}
+	if len(runs) != 0 {
+		t.Fatalf("expected a new task not to run immediately, got %v", runs)
+	}
+
+	// Three activations passed; only the latest runs.
+	now = now.Add(15 * time.Minute)
+	first.tick(ctx)
+	second.tick(ctx)
+	if len(runs) != 1 || !runs[0].Equal(time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)) {
+		t.Fatalf("expected one run for 10:15, got %v", runs)
+	}
+	first.tick(ctx)
+	if len(runs) != 1 {
+		t.Fatalf("expected no run before the next activation, got %v", runs)
+	}
+
+	// The second scheduler takes over once the leader resigns.
+	first.resign()
+	now = now.Add(5 * time.Minute)
+	fail = true
+	second.tick(ctx)
+	if !second.leader || len(runs) != 2 {
+		t.Fatalf("expected the second scheduler to take over, leader=%v runs=%v", second.leader, runs)
+	}
+
+	statuses, leader, err := second.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status returned error: %v", err)
+	}
+	if leader != second.InstanceID() || len(statuses) != 1 {
+		t.Fatalf("unexpected status %v led by %q", statuses, leader)
+	}
+	status := statuses[0]
+	if status.LastError != "queue unavailable" || status.LastRun == nil || status.NextRun == nil || !status.NextRun.Equal(time.Date(2024, 1, 1, 10, 25, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected task status %+v", status)
+	}
+}
+
+func TestLeadershipExpiresWithoutRenewal(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+	now := time.Now()
+
+	first := newTestScheduler(t, client, &now)
+	second := newTestScheduler(t, client, &now)
+	first.tick(ctx)
+	second.tick(ctx)
+	if second.leader {
+		t.Fatal("expected the lease to be held by the first scheduler")
+	}
+
+	server.FastForward(20 * time.Second)
+	second.tick(ctx)
+	first.tick(ctx)
+	if !second.leader || first.leader {
+		t.Fatalf("expected the lease to move after expiring, first=%v second=%v", first.leader, second.leader)
+	}
+}
@@ -0,0 +1,170 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes when a task runs next.
+type Schedule interface {
+	// Next returns the first activation strictly after t.
+	Next(t time.Time) time.Time
+}
+
+// Parse reads a schedule: a five-field cron expression (minute, hour, day of month,
+// month, day of week) with "*", lists, ranges and steps; "@every <duration>"; or
+// one of "@hourly", "@daily", "@weekly" and "@monthly". Times are in UTC.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	}
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || interval < time.Second {
+			return nil, fmt.Errorf("invalid schedule %q: @every needs a duration of at least 1s", spec)
+		}
+		return every(interval), nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields, @every or a predefined schedule", spec)
+	}
+	var expr cronExpr
+	for i, bounds := range fieldBounds {
+		bits, err := parseField(fields[i], bounds)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %s: %w", spec, bounds.name, err)
+		}
+		expr.fields[i] = bits
+	}
+	// Day 7 is Sunday as well.
+	if expr.fields[dowField]&(1<<7) != 0 {
+		expr.fields[dowField] |= 1
+	}
+	// As in Vixie cron, a day field starting with "*" leaves days unrestricted by it.
+	expr.domAny = strings.HasPrefix(fields[domField], "*")
+	expr.dowAny = strings.HasPrefix(fields[dowField], "*")
+	return &expr, nil
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Truncate(time.Duration(e)).Add(time.Duration(e))
+}
+
+const (
+	minuteField = iota
+	hourField
+	domField
+	monthField
+	dowField
+)
+
+type fieldRange struct {
+	name     string
+	min, max int
+}
+
+var fieldBounds = [5]fieldRange{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+type cronExpr struct {
+	fields         [5]uint64
+	domAny, dowAny bool
+}
+
+func parseField(field string, bounds fieldRange) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+		low, high := bounds.min, bounds.max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q", from)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %q", to)
+				}
+			} else if hasStep {
+				high = bounds.max
+			}
+		}
+		if low < bounds.min || high > bounds.max || low > high {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, bounds.min, bounds.max)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (e *cronExpr) has(field, value int) bool {
+	return e.fields[field]&(1<<value) != 0
+}
+
+// dayMatches applies cron's rule that a day matches either restricted day field
+// when both are restricted.
+func (e *cronExpr) dayMatches(t time.Time) bool {
+	dom := e.has(domField, t.Day())
+	dow := e.has(dowField, int(t.Weekday()))
+	switch {
+	case e.domAny:
+		return dow
+	case e.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next implements Schedule. Expressions that never match, such as "0 0 31 2 *",
+// return the zero time.
+func (e *cronExpr) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !e.has(monthField, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !e.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !e.has(hourField, t.Hour()):
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !e.has(minuteField, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
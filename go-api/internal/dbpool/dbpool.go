@@ -0,0 +1,220 @@
+// Package dbpool sizes the database connection pool at runtime. Operators can change
+// the pool parameters without a restart, and an adaptive tuner can grow the pool while
+// queries wait for connections and shrink it again once the load drops.
+package dbpool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrInvalidSettings is returned for settings the pool cannot run with.
+var ErrInvalidSettings = errors.New("invalid pool settings")
+
+// DB is the connection pool being tuned. *sql.DB implements it.
+type DB interface {
+	Stats() sql.DBStats
+	SetMaxOpenConns(n int)
+	SetMaxIdleConns(n int)
+	SetConnMaxLifetime(d time.Duration)
+}
+
+// Settings are the parameters of the pool.
+type Settings struct {
+	MaxOpenConns int
+	// MaxIdleConns is capped at MaxOpenConns while the tuner keeps the pool smaller.
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// Adaptive lets the tuner move MaxOpenConns between MinOpenConns and
+	// MaxOpenConnsLimit. MaxOpenConns is clamped to those bounds.
+	Adaptive          bool
+	MinOpenConns      int
+	MaxOpenConnsLimit int
+}
+
+// validate returns an error wrapping ErrInvalidSettings for settings the pool cannot
+// run with.
+func (s Settings) validate() error {
+	switch {
+	case s.MaxOpenConns <= 0:
+		return fmt.Errorf("%w: max_open_conns must be positive", ErrInvalidSettings)
+	case s.MaxIdleConns < 0 || s.MaxIdleConns > s.MaxOpenConns:
+		return fmt.Errorf("%w: max_idle_conns must be between 0 and max_open_conns", ErrInvalidSettings)
+	case s.ConnMaxLifetime < 0:
+		return fmt.Errorf("%w: conn_max_lifetime must not be negative", ErrInvalidSettings)
+	case s.Adaptive && (s.MinOpenConns <= 0 || s.MinOpenConns > s.MaxOpenConnsLimit):
+		return fmt.Errorf("%w: adaptive bounds must satisfy 0 < min_open_conns <= max_open_conns_limit", ErrInvalidSettings)
+	}
+	return nil
+}
+
+// Options tunes how the adaptive tuner reacts to load.
+type Options struct {
+	// Interval is how often the pool statistics are sampled.
+	Interval time.Duration
+	// WaitThreshold is the average time queries of an interval may wait for a
+	// connection before the pool grows.
+	WaitThreshold time.Duration
+	// ShrinkAfter is the number of consecutive intervals without waits, and with at
+	// most half the connections in use, before the pool shrinks.
+	ShrinkAfter int
+}
+
+// DefaultOptions returns the tunables used by NewTuner.
+func DefaultOptions() Options {
+	return Options{Interval: 15 * time.Second, WaitThreshold: 5 * time.Millisecond, ShrinkAfter: 4}
+}
+
+// Status is the current pool configuration and usage.
+type Status struct {
+	Settings        Settings
+	OpenConnections int
+	InUse           int
+	Idle            int
+	WaitCount       int64
+	WaitDuration    time.Duration
+	// LastAdjusted is when the adaptive tuner last resized the pool; zero when it
+	// never did.
+	LastAdjusted time.Time
+}
+
+// Tuner owns the parameters of a pool. It is safe for concurrent use.
+type Tuner struct {
+	db     DB
+	logger *zap.Logger
+	opts   Options
+	now    func() time.Time
+
+	mu           sync.Mutex
+	settings     Settings
+	previous     sql.DBStats
+	calm         int
+	lastAdjusted time.Time
+}
+
+// NewTuner applies settings to db and returns a tuner with DefaultOptions.
+func NewTuner(db DB, settings Settings, logger *zap.Logger) (*Tuner, error) {
+	return NewTunerWithOptions(db, settings, logger, DefaultOptions())
+}
+
+// NewTunerWithOptions applies settings to db and returns a tuner with explicit
+// tunables.
+func NewTunerWithOptions(db DB, settings Settings, logger *zap.Logger, opts Options) (*Tuner, error) {
+	t := &Tuner{db: db, logger: logger.Named("dbpool"), opts: opts, now: time.Now}
+	if err := t.Apply(settings); err != nil {
+		return nil, err
+	}
+	t.previous = db.Stats()
+	return t, nil
+}
+
+// Settings returns the parameters the pool runs with.
+func (t *Tuner) Settings() Settings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.settings
+}
+
+// Apply validates settings and applies them to the pool right away. Connections over
+// a lowered limit are closed as they are released.
+func (t *Tuner) Apply(settings Settings) error {
+	if err := settings.validate(); err != nil {
+		return err
+	}
+	if settings.Adaptive {
+		settings.MaxOpenConns = max(settings.MinOpenConns, min(settings.MaxOpenConns, settings.MaxOpenConnsLimit))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.settings = settings
+	t.calm = 0
+	t.db.SetMaxOpenConns(settings.MaxOpenConns)
+	t.db.SetMaxIdleConns(min(settings.MaxIdleConns, settings.MaxOpenConns))
+	t.db.SetConnMaxLifetime(settings.ConnMaxLifetime)
+	return nil
+}
+
+// Status returns the pool settings next to its current usage.
+func (t *Tuner) Status() Status {
+	stats := t.db.Stats()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Status{
+		Settings:        t.settings,
+		OpenConnections: stats.OpenConnections,
+		InUse:           stats.InUse,
+		Idle:            stats.Idle,
+		WaitCount:       stats.WaitCount,
+		WaitDuration:    stats.WaitDuration,
+		LastAdjusted:    t.lastAdjusted,
+	}
+}
+
+// Run samples the pool every Options.Interval and resizes it while it is adaptive,
+// until ctx is done.
+func (t *Tuner) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.adjust()
+		}
+	}
+}
+
+// adjust grows the pool by a quarter when queries waited longer than the threshold on
+// average since the last sample, and shrinks it by an eighth after ShrinkAfter calm
+// samples.
+func (t *Tuner) adjust() {
+	stats := t.db.Stats()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	waits := stats.WaitCount - t.previous.WaitCount
+	waited := stats.WaitDuration - t.previous.WaitDuration
+	t.previous = stats
+	if !t.settings.Adaptive {
+		return
+	}
+
+	current := t.settings.MaxOpenConns
+	next := current
+	switch {
+	case waits > 0 && waited/time.Duration(waits) >= t.opts.WaitThreshold:
+		t.calm = 0
+		next = min(current+max(1, current/4), t.settings.MaxOpenConnsLimit)
+	case waits == 0 && stats.InUse <= current/2:
+		t.calm++
+		if t.calm >= t.opts.ShrinkAfter {
+			t.calm = 0
+			next = max(current-max(1, current/8), t.settings.MinOpenConns)
+		}
+	default:
+		t.calm = 0
+	}
+	if next == current {
+		return
+	}
+
+	t.settings.MaxOpenConns = next
+	t.db.SetMaxOpenConns(next)
+	// Shrinking lowers the idle limit of the pool, so restore it when growing back.
+	t.db.SetMaxIdleConns(min(t.settings.MaxIdleConns, next))
+	t.lastAdjusted = t.now()
+	t.logger.Info("resized database pool",
+		zap.Int("from", current),
+		zap.Int("to", next),
+		zap.Int64("waits", waits),
+		zap.Duration("waited", waited),
+		zap.Int("in_use", stats.InUse),
+	)
+}
@@ -0,0 +1,123 @@
+package dbpool
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type fakeDB struct {
+	stats    sql.DBStats
+	maxOpen  int
+	maxIdle  int
+	lifetime time.Duration
+}
+
+func (f *fakeDB) Stats() sql.DBStats                 { return f.stats }
+func (f *fakeDB) SetMaxOpenConns(n int)              { f.maxOpen = n }
+func (f *fakeDB) SetMaxIdleConns(n int)              { f.maxIdle = n }
+func (f *fakeDB) SetConnMaxLifetime(d time.Duration) { f.lifetime = d }
+
+func (f *fakeDB) wait(count int64, each time.Duration) {
+	f.stats.WaitCount += count
+	f.stats.WaitDuration += time.Duration(count) * each
+}
+
+func TestTunerGrowsOnWaitsAndShrinksWhenCalm(t *testing.T) {
+	db := &fakeDB{}
+	tuner, err := NewTunerWithOptions(db, Settings{
+		MaxOpenConns:      10,
+		MaxIdleConns:      5,
+		ConnMaxLifetime:   time.Hour,
+		Adaptive:          true,
+		MinOpenConns:      8,
+		MaxOpenConnsLimit: 14,
+	}, zap.NewNop(), Options{Interval: time.Second, WaitThreshold: 5 * time.Millisecond, ShrinkAfter: 2})
+	if err != nil {
+		t.Fatalf("NewTunerWithOptions returned error: %v", err)
+	}
+	if db.maxOpen != 10 || db.maxIdle != 5 || db.lifetime != time.Hour {
+		t.Fatalf("expected the settings to be applied, got %+v", db)
+	}
+
+	// Short waits do not grow the pool.
+	db.wait(100, time.Millisecond)
+	tuner.adjust()
+	if db.maxOpen != 10 {
+		t.Fatalf("expected short waits to keep 10 connections, got %d", db.maxOpen)
+	}
+
+	db.wait(10, 20*time.Millisecond)
+	tuner.adjust()
+	if db.maxOpen != 12 {
+		t.Fatalf("expected the pool to grow to 12, got %d", db.maxOpen)
+	}
+	db.wait(10, 20*time.Millisecond)
+	tuner.adjust()
+	if db.maxOpen != 14 || tuner.Status().LastAdjusted.IsZero() {
+		t.Fatalf("expected the pool to grow to its limit of 14, got %d", db.maxOpen)
+	}
+	db.wait(10, 20*time.Millisecond)
+	tuner.adjust()
+	if db.maxOpen != 14 {
+		t.Fatalf("expected the pool to stay at its limit, got %d", db.maxOpen)
+	}
+
+	// Busy intervals without waits keep the size.
+	db.stats.InUse = 12
+	tuner.adjust()
+	tuner.adjust()
+	if db.maxOpen != 14 {
+		t.Fatalf("expected a busy pool to keep 14 connections, got %d", db.maxOpen)
+	}
+	db.stats.InUse = 2
+	tuner.adjust()
+	if db.maxOpen != 14 {
+		t.Fatalf("expected one calm interval not to shrink the pool, got %d", db.maxOpen)
+	}
+	for range 12 {
+		tuner.adjust()
+	}
+	if db.maxOpen != 8 || db.maxIdle != 5 {
+		t.Fatalf("expected the pool to shrink to its minimum of 8 with 5 idle, got %+v", db)
+	}
+}
+
+func TestApplyValidatesAndClampsSettings(t *testing.T) {
+	db := &fakeDB{}
+	tuner, err := NewTuner(db, Settings{MaxOpenConns: 10, MaxIdleConns: 5}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewTuner returned error: %v", err)
+	}
+
+	// Without adaptive sizing the pool keeps its size whatever the load.
+	db.wait(10, time.Second)
+	tuner.adjust()
+	if db.maxOpen != 10 {
+		t.Fatalf("expected a fixed pool to keep 10 connections, got %d", db.maxOpen)
+	}
+
+	for _, settings := range []Settings{
+		{MaxOpenConns: 0},
+		{MaxOpenConns: 5, MaxIdleConns: 6},
+		{MaxOpenConns: 5, ConnMaxLifetime: -time.Second},
+		{MaxOpenConns: 5, Adaptive: true, MinOpenConns: 10, MaxOpenConnsLimit: 4},
+	} {
+		if err := tuner.Apply(settings); !errors.Is(err, ErrInvalidSettings) {
+			t.Fatalf("expected %+v to be rejected, got %v", settings, err)
+		}
+	}
+	if db.maxOpen != 10 {
+		t.Fatalf("expected rejected settings to leave the pool alone, got %d", db.maxOpen)
+	}
+
+	if err := tuner.Apply(Settings{MaxOpenConns: 5, MaxIdleConns: 5, Adaptive: true, MinOpenConns: 20, MaxOpenConnsLimit: 40}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if settings := tuner.Settings(); settings.MaxOpenConns != 20 || db.maxOpen != 20 {
+		t.Fatalf("expected max_open_conns to be raised to the adaptive minimum, got %+v", settings)
+	}
+}
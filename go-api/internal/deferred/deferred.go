@@ -0,0 +1,83 @@
+// Package deferred queues verifications accepted while the image processor was
+// unavailable, and completes them once it is back. Their images are kept in object
+// storage and only the request is queued, on its own Redis queue served by every
+// API instance, as only those talk to the processor.
+package deferred
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/example/ai-check/internal/worker"
+)
+
+// ProcessJob is the worker job type that completes one queued verification.
+const ProcessJob = "verification.process_queued"
+
+// QueuePrefix namespaces the queue keys apart from the jobs of the worker command.
+const QueuePrefix = "ai-check:{deferred}"
+
+// Processor completes queued verifications, such as a *usecase.VerificationUseCase.
+type Processor interface {
+	ProcessQueued(ctx context.Context, requestID, userID string) error
+}
+
+// Options tunes a Queue.
+type Options struct {
+	// MaxAttempts is how often a verification is tried before it is dead-lettered.
+	// With the worker's backoff it bounds the outage a verification survives.
+	MaxAttempts int
+}
+
+// DefaultOptions returns the options used by New.
+func DefaultOptions() Options {
+	return Options{MaxAttempts: 50}
+}
+
+// Queue queues verifications as jobs.
+type Queue struct {
+	queue *worker.Queue
+	opts  Options
+}
+
+// New returns a queue with DefaultOptions.
+func New(queue *worker.Queue) *Queue {
+	return NewWithOptions(queue, DefaultOptions())
+}
+
+// NewWithOptions returns a queue with explicit options.
+func NewWithOptions(queue *worker.Queue, opts Options) *Queue {
+	return &Queue{queue: queue, opts: opts}
+}
+
+type payload struct {
+	RequestID string `json:"request_id"`
+	UserID    string `json:"user_id"`
+}
+
+// Defer queues the verification requestID of userID. Deferring the same request
+// twice queues it once.
+func (q *Queue) Defer(ctx context.Context, requestID, userID string) error {
+	job, err := worker.NewJob(ProcessJob, payload{RequestID: requestID, UserID: userID})
+	if err != nil {
+		return err
+	}
+	job.ID = ProcessJob + ":" + requestID
+	job.MaxAttempts = q.opts.MaxAttempts
+	if _, err := q.queue.Enqueue(ctx, job); err != nil {
+		return fmt.Errorf("queue verification: %w", err)
+	}
+	return nil
+}
+
+// Handler returns the worker.Handler for ProcessJob. A returned error retries the
+// verification with backoff.
+func Handler(processor Processor) worker.Handler {
+	return func(ctx context.Context, job *worker.Job) error {
+		var p payload
+		if err := job.Decode(&p); err != nil {
+			return worker.Permanent(fmt.Errorf("decode payload: %w", err))
+		}
+		return processor.ProcessQueued(ctx, p.RequestID, p.UserID)
+	}
+}
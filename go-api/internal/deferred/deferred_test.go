@@ -0,0 +1,54 @@
+package deferred
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/example/ai-check/internal/worker"
+)
+
+type processorFunc func(ctx context.Context, requestID, userID string) error
+
+func (f processorFunc) ProcessQueued(ctx context.Context, requestID, userID string) error {
+	return f(ctx, requestID, userID)
+}
+
+func TestDeferredVerificationsAreQueuedOnce(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	queue := worker.NewQueueWithPrefix(client, QueuePrefix)
+	deferred := NewWithOptions(queue, Options{MaxAttempts: 7})
+
+	for i := 0; i < 2; i++ {
+		if err := deferred.Defer(ctx, "req-1", "user-1"); err != nil {
+			t.Fatalf("Defer returned error: %v", err)
+		}
+	}
+	if stats, err := queue.Stats(ctx); err != nil || stats.Ready != 1 {
+		t.Fatalf("expected the verification to be queued once, got %+v (%v)", stats, err)
+	}
+	job, err := queue.Claim(ctx, time.Minute)
+	if err != nil || job == nil || job.Type != ProcessJob || job.MaxAttempts != 7 {
+		t.Fatalf("expected a process job, got %+v (%v)", job, err)
+	}
+
+	unavailable := errors.New("processor unavailable")
+	var processed []string
+	handler := Handler(processorFunc(func(ctx context.Context, requestID, userID string) error {
+		processed = append(processed, requestID+"/"+userID)
+		return unavailable
+	}))
+	if err := handler(ctx, job); !errors.Is(err, unavailable) {
+		t.Fatalf("expected the processor error to retry the job, got %v", err)
+	}
+	if len(processed) != 1 || processed[0] != "req-1/user-1" {
+		t.Fatalf("expected the queued verification to be processed, got %v", processed)
+	}
+}
@@ -0,0 +1,101 @@
+// Package devmode provides in-process stand-ins for Postgres, Redis and the image
+// processor so the API can run on a laptop without Docker.
+package devmode
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/example/ai-check/internal/imageprocessor"
+)
+
+// OpenDatabase opens (or creates) a SQLite database at path. Use ":memory:" for a
+// throwaway database.
+func OpenDatabase(path string) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{
+		Logger:      gormlogger.Default.LogMode(gormlogger.Warn),
+		PrepareStmt: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database %s: %w", path, err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	// SQLite serialises writers; a single connection avoids "database is locked".
+	sqlDB.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// StartRedis runs an in-process Redis server and returns a client connected to it.
+// Closing the server discards all cached data.
+func StartRedis() (*miniredis.Miniredis, *redis.Client, error) {
+	server, err := miniredis.Run()
+	if err != nil {
+		return nil, nil, fmt.Errorf("start in-process redis: %w", err)
+	}
+	return server, redis.NewClient(&redis.Options{Addr: server.Addr()}), nil
+}
+
+// Processor stands in for the Rust image processor. It derives a stable score from
+// the image bytes, so repeated uploads of the same image get the same result.
+type Processor struct {
+	// Latency is added to every call to mimic a remote round trip.
+	Latency time.Duration
+}
+
+// Process implements imageprocessor.Client.
+func (p Processor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
+	if p.Latency > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(p.Latency):
+		}
+	}
+	sum := sha256.Sum256(imageBytes)
+	scoreAt := func(i int) float32 {
+		return float32(binary.BigEndian.Uint16(sum[i:i+2])) / float32(^uint16(0))
+	}
+	score := scoreAt(0)
+	categories := []string{
+		imageprocessor.CategoryAIGenerated,
+		imageprocessor.CategoryManipulated,
+		imageprocessor.CategoryNSFW,
+		imageprocessor.CategoryWatermarked,
+	}
+	result := &imageprocessor.Result{
+		Success: score >= 0.5,
+		Score:   score,
+		Message: "development processor",
+	}
+	for i, category := range categories {
+		result.Categories = append(result.Categories, imageprocessor.CategoryScore{Category: category, Score: scoreAt(2 + 2*i)})
+	}
+	return result, nil
+}
+
+// Token signs a bearer token for subject that the API accepts with the given secret
+// and audience, valid for ttl.
+func Token(secret, audience, subject string, ttl time.Duration) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Subject:   subject,
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+	}
+	if audience != "" {
+		claims.Audience = jwt.ClaimStrings{audience}
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
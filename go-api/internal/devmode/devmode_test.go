@@ -0,0 +1,62 @@
+package devmode
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/repository"
+)
+
+func TestOpenDatabaseSupportsRepository(t *testing.T) {
+	db, err := OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+
+	ctx := context.Background()
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	if err := repo.SaveLog(ctx, &repository.VerificationLog{RequestID: "req-1", UserID: "user-1", Success: true, Score: 0.9}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	metrics, err := repo.AggregateMetrics(ctx)
+	if err != nil {
+		t.Fatalf("AggregateMetrics returned error: %v", err)
+	}
+	if metrics.TotalCount != 1 || metrics.SuccessCount != 1 {
+		t.Fatalf("unexpected metrics: %+v", metrics)
+	}
+}
+
+func TestStartRedisServesClient(t *testing.T) {
+	server, client, err := StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer server.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := client.Set(ctx, "key", "value", time.Minute).Err(); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if got, err := client.Get(ctx, "key").Result(); err != nil || got != "value" {
+		t.Fatalf("expected cached value, got %q (%v)", got, err)
+	}
+}
+
+func TestProcessorIsDeterministic(t *testing.T) {
+	first, err := Processor{}.Process(context.Background(), "user", []byte("image"))
+	if err != nil {
+		t.Fatalf("Process returned error: %v", err)
+	}
+	second, _ := Processor{}.Process(context.Background(), "user", []byte("image"))
+	if first.Score != second.Score || first.Score < 0 || first.Score > 1 {
+		t.Fatalf("expected a stable score in [0,1], got %v and %v", first.Score, second.Score)
+	}
+}
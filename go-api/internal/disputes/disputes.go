@@ -0,0 +1,372 @@
+// Package disputes lets users object to the verdict of a verification. Disputes wait
+// in the review queue until an operator accepts (the verdict was wrong) or rejects
+// (the verdict stands) them; resolved disputes are exported as labeled examples for
+// training the next model.
+package disputes
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/repository"
+)
+
+// The states of a dispute. Open disputes move to in review, and either may be
+// resolved as accepted or rejected. Resolutions are final.
+const (
+	StateOpen     = "open"
+	StateInReview = "in_review"
+	StateAccepted = "accepted"
+	StateRejected = "rejected"
+)
+
+// MaxReasonLength is the longest reason, in characters, a user may give.
+const MaxReasonLength = 2000
+
+const exportBatchSize = 500
+
+var (
+	// ErrNotFound is returned for unknown disputes, and for verifications that do not
+	// exist or belong to another user.
+	ErrNotFound = errors.New("dispute not found")
+	// ErrAlreadyDisputed is returned when the verification already has a dispute.
+	ErrAlreadyDisputed = errors.New("verification is already disputed")
+	// ErrInvalidReason is returned for empty or overlong reasons.
+	ErrInvalidReason = fmt.Errorf("reason must be 1-%d characters", MaxReasonLength)
+	// ErrInvalidState is returned for unknown states and disallowed transitions.
+	ErrInvalidState = errors.New("state must be in_review, accepted or rejected, and resolved disputes cannot change")
+	// ErrInvalidCursor is returned for cursors that were not issued by Queue.
+	ErrInvalidCursor = errors.New("cursor is invalid")
+	// ErrInvalidFormat is returned for unknown export formats.
+	ErrInvalidFormat = errors.New("format must be jsonl or csv")
+)
+
+var transitions = map[string][]string{
+	StateOpen:     {StateInReview, StateAccepted, StateRejected},
+	StateInReview: {StateAccepted, StateRejected},
+}
+
+// Dispute is a dispute together with the verdict it contests.
+type Dispute struct {
+	ID           uint       `json:"id"`
+	RequestID    string     `json:"request_id"`
+	UserID       string     `json:"user_id"`
+	Reason       string     `json:"reason"`
+	State        string     `json:"state"`
+	ReviewerNote string     `json:"reviewer_note,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+	// Score and Success are the disputed verdict. They are absent once the
+	// verification was purged.
+	Score   *float32 `json:"score,omitempty"`
+	Success *bool    `json:"success,omitempty"`
+}
+
+// Page is one page of disputes, oldest first. NextCursor is empty on the last page.
+type Page struct {
+	Items      []*Dispute `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// Example is a resolved dispute as a labeled training example. Label is the verdict
+// the reviewer settled on: the original one for rejected disputes, its opposite for
+// accepted ones.
+type Example struct {
+	RequestID  string             `json:"request_id"`
+	SHA256Hash string             `json:"sha256_hash"`
+	SHA1Hash   string             `json:"sha1_hash"`
+	ImageKey   string             `json:"image_key,omitempty"`
+	Variant    string             `json:"variant,omitempty"`
+	Score      float32            `json:"score"`
+	Categories map[string]float32 `json:"categories,omitempty"`
+	Predicted  bool               `json:"predicted"`
+	Label      bool               `json:"label"`
+	State      string             `json:"state"`
+	Reason     string             `json:"reason"`
+	ResolvedAt *time.Time         `json:"resolved_at,omitempty"`
+}
+
+// Service manages disputes.
+type Service struct {
+	repo   *repository.DisputeRepository
+	logger *zap.Logger
+}
+
+// NewService returns a dispute service.
+func NewService(repo *repository.DisputeRepository, logger *zap.Logger) *Service {
+	return &Service{repo: repo, logger: logger.Named("disputes")}
+}
+
+// Submit opens a dispute of the user's verification.
+func (s *Service) Submit(ctx context.Context, userID, requestID, reason string) (*Dispute, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || utf8.RuneCountInString(reason) > MaxReasonLength {
+		return nil, ErrInvalidReason
+	}
+	logs, err := s.repo.FindLogs(ctx, []string{requestID})
+	if err != nil {
+		return nil, err
+	}
+	log := logs[requestID]
+	if log == nil || log.UserID != userID {
+		return nil, ErrNotFound
+	}
+	if _, err := s.repo.FindByRequestID(ctx, requestID); err == nil {
+		return nil, ErrAlreadyDisputed
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	dispute := &repository.Dispute{RequestID: requestID, UserID: userID, Reason: reason, State: StateOpen}
+	if err := s.repo.Create(ctx, dispute); err != nil {
+		// A concurrent submission won the unique index.
+		if _, findErr := s.repo.FindByRequestID(ctx, requestID); findErr == nil {
+			return nil, ErrAlreadyDisputed
+		}
+		return nil, err
+	}
+	s.logger.Info("verification disputed", zap.String("request_id", requestID), zap.String("user_id", userID))
+	return newDispute(dispute, log), nil
+}
+
+// Get returns the dispute of the user's verification.
+func (s *Service) Get(ctx context.Context, userID, requestID string) (*Dispute, error) {
+	dispute, err := s.repo.FindByRequestID(ctx, requestID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && dispute.UserID != userID) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.withLog(ctx, dispute)
+}
+
+// Queue returns up to limit unresolved disputes with IDs after cursor, oldest first.
+func (s *Service) Queue(ctx context.Context, cursor string, limit int) (*Page, error) {
+	afterID, err := parseCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	// One extra dispute tells whether another page follows.
+	rows, err := s.repo.List(ctx, []string{StateOpen, StateInReview}, afterID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page{}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		page.NextCursor = strconv.FormatUint(uint64(rows[limit-1].ID), 10)
+	}
+	logs, err := s.repo.FindLogs(ctx, requestIDs(rows))
+	if err != nil {
+		return nil, err
+	}
+	page.Items = make([]*Dispute, 0, len(rows))
+	for _, row := range rows {
+		page.Items = append(page.Items, newDispute(row, logs[row.RequestID]))
+	}
+	return page, nil
+}
+
+// Transition moves a dispute to state and records the reviewer's note.
+func (s *Service) Transition(ctx context.Context, id uint, state, note string) (*Dispute, error) {
+	dispute, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !allowed(dispute.State, state) {
+		return nil, ErrInvalidState
+	}
+	dispute.State = state
+	dispute.ReviewerNote = strings.TrimSpace(note)
+	if state == StateAccepted || state == StateRejected {
+		now := time.Now().UTC()
+		dispute.ResolvedAt = &now
+	}
+	if err := s.repo.Save(ctx, dispute); err != nil {
+		return nil, err
+	}
+	s.logger.Info("dispute updated", zap.Uint("id", id), zap.String("request_id", dispute.RequestID), zap.String("state", state))
+	return s.withLog(ctx, dispute)
+}
+
+// Export writes every resolved dispute whose verification still exists to w as a
+// labeled example, one JSON object per line ("jsonl") or one CSV row ("csv"). Writers
+// with a Flush method, such as HTTP responses, are flushed after every batch.
+func (s *Service) Export(ctx context.Context, w io.Writer, format string) (int, error) {
+	var write func(*Example) error
+	flush := func() error { return nil }
+	switch format {
+	case "jsonl":
+		encoder := json.NewEncoder(w)
+		write = func(example *Example) error { return encoder.Encode(example) }
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvHeader); err != nil {
+			return 0, err
+		}
+		write = func(example *Example) error { return writer.Write(example.csvRecord()) }
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	default:
+		return 0, ErrInvalidFormat
+	}
+
+	written := 0
+	var afterID uint
+	for {
+		rows, err := s.repo.List(ctx, []string{StateAccepted, StateRejected}, afterID, exportBatchSize)
+		if err != nil {
+			return written, err
+		}
+		if len(rows) == 0 {
+			return written, flush()
+		}
+		logs, err := s.repo.FindLogs(ctx, requestIDs(rows))
+		if err != nil {
+			return written, err
+		}
+		for _, row := range rows {
+			log := logs[row.RequestID]
+			if log == nil {
+				continue
+			}
+			if err := write(newExample(row, log)); err != nil {
+				return written, err
+			}
+			written++
+		}
+		// Send each batch as it is written, so a large export starts arriving at once.
+		if err := flush(); err != nil {
+			return written, err
+		}
+		if flusher, ok := w.(interface{ Flush() }); ok {
+			flusher.Flush()
+		}
+		afterID = rows[len(rows)-1].ID
+	}
+}
+
+var csvHeader = []string{"request_id", "sha1_hash", "image_key", "variant", "score", "categories", "predicted", "label", "state", "reason", "resolved_at", "sha256_hash"}
+
+func (e *Example) csvRecord() []string {
+	categories := ""
+	if len(e.Categories) > 0 {
+		encoded, _ := json.Marshal(e.Categories)
+		categories = string(encoded)
+	}
+	resolvedAt := ""
+	if e.ResolvedAt != nil {
+		resolvedAt = e.ResolvedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		e.RequestID,
+		e.SHA1Hash,
+		e.ImageKey,
+		e.Variant,
+		strconv.FormatFloat(float64(e.Score), 'f', -1, 32),
+		categories,
+		strconv.FormatBool(e.Predicted),
+		strconv.FormatBool(e.Label),
+		e.State,
+		e.Reason,
+		resolvedAt,
+		e.SHA256Hash,
+	}
+}
+
+func (s *Service) withLog(ctx context.Context, dispute *repository.Dispute) (*Dispute, error) {
+	logs, err := s.repo.FindLogs(ctx, []string{dispute.RequestID})
+	if err != nil {
+		return nil, err
+	}
+	return newDispute(dispute, logs[dispute.RequestID]), nil
+}
+
+func newDispute(row *repository.Dispute, log *repository.VerificationLog) *Dispute {
+	dispute := &Dispute{
+		ID:           row.ID,
+		RequestID:    row.RequestID,
+		UserID:       row.UserID,
+		Reason:       row.Reason,
+		State:        row.State,
+		ReviewerNote: row.ReviewerNote,
+		CreatedAt:    row.CreatedAt,
+		UpdatedAt:    row.UpdatedAt,
+		ResolvedAt:   row.ResolvedAt,
+	}
+	if log != nil {
+		score, success := log.Score, log.Success
+		dispute.Score = &score
+		dispute.Success = &success
+	}
+	return dispute
+}
+
+func newExample(row *repository.Dispute, log *repository.VerificationLog) *Example {
+	example := &Example{
+		RequestID:  row.RequestID,
+		SHA256Hash: log.SHA256Hash,
+		SHA1Hash:   log.SHA1Hash,
+		ImageKey:   log.ImageKey,
+		Variant:    log.Variant,
+		Score:      log.Score,
+		Predicted:  log.Success,
+		Label:      log.Success != (row.State == StateAccepted),
+		State:      row.State,
+		Reason:     row.Reason,
+		ResolvedAt: row.ResolvedAt,
+	}
+	if len(log.Categories) > 0 {
+		example.Categories = make(map[string]float32, len(log.Categories))
+		for _, category := range log.Categories {
+			example.Categories[category.Category] = category.Score
+		}
+	}
+	return example
+}
+
+func allowed(from, to string) bool {
+	for _, state := range transitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+func requestIDs(rows []*repository.Dispute) []string {
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.RequestID)
+	}
+	return ids
+}
+
+func parseCursor(cursor string) (uint, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(cursor, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return uint(id), nil
+}
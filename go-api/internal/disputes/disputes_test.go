@@ -0,0 +1,174 @@
+package disputes
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/repository"
+)
+
+func newTestService(t *testing.T) (*Service, *repository.VerificationRepository) {
+	t.Helper()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	logs := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := logs.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	repo := repository.NewDisputeRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	return NewService(repo, zap.NewNop()), logs
+}
+
+func saveLog(t *testing.T, logs *repository.VerificationRepository, requestID, userID string, success bool) {
+	t.Helper()
+	err := logs.SaveLog(context.Background(), &repository.VerificationLog{
+		RequestID: requestID,
+		UserID:    userID,
+		SHA1Hash:  "hash-" + requestID,
+		Score:     0.75,
+		Success:   success,
+		CreatedAt: time.Now(),
+		Categories: []repository.VerificationCategory{
+			{Category: "nsfw", Score: 0.5, Threshold: 0.7},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+}
+
+func TestSubmitChecksOwnershipReasonAndDuplicates(t *testing.T) {
+	service, logs := newTestService(t)
+	ctx := context.Background()
+	saveLog(t, logs, "req-1", "alice", true)
+
+	if _, err := service.Submit(ctx, "alice", "req-1", "   "); !errors.Is(err, ErrInvalidReason) {
+		t.Fatalf("expected ErrInvalidReason for a blank reason, got %v", err)
+	}
+	if _, err := service.Submit(ctx, "alice", "req-1", strings.Repeat("x", MaxReasonLength+1)); !errors.Is(err, ErrInvalidReason) {
+		t.Fatalf("expected ErrInvalidReason for a long reason, got %v", err)
+	}
+	if _, err := service.Submit(ctx, "bob", "req-1", "wrong"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected another user's verification to be hidden, got %v", err)
+	}
+	if _, err := service.Submit(ctx, "alice", "missing", "wrong"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown verification, got %v", err)
+	}
+
+	dispute, err := service.Submit(ctx, "alice", "req-1", " this is a real photo ")
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	if dispute.State != StateOpen || dispute.Reason != "this is a real photo" || dispute.Success == nil || !*dispute.Success {
+		t.Fatalf("unexpected dispute %+v", dispute)
+	}
+	if _, err := service.Submit(ctx, "alice", "req-1", "again"); !errors.Is(err, ErrAlreadyDisputed) {
+		t.Fatalf("expected ErrAlreadyDisputed, got %v", err)
+	}
+	if _, err := service.Get(ctx, "bob", "req-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected another user's dispute to be hidden, got %v", err)
+	}
+}
+
+func TestDisputesMoveThroughTheQueueAndExportAsLabels(t *testing.T) {
+	service, logs := newTestService(t)
+	ctx := context.Background()
+	saveLog(t, logs, "req-1", "alice", true)
+	saveLog(t, logs, "req-2", "alice", false)
+	saveLog(t, logs, "req-3", "bob", true)
+	var ids []uint
+	for _, requestID := range []string{"req-1", "req-2", "req-3"} {
+		user := "alice"
+		if requestID == "req-3" {
+			user = "bob"
+		}
+		dispute, err := service.Submit(ctx, user, requestID, "wrong verdict")
+		if err != nil {
+			t.Fatalf("Submit returned error: %v", err)
+		}
+		ids = append(ids, dispute.ID)
+	}
+
+	page, err := service.Queue(ctx, "", 2)
+	if err != nil {
+		t.Fatalf("Queue returned error: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[0].RequestID != "req-1" || page.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	if _, err := service.Queue(ctx, "nope", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+
+	if _, err := service.Transition(ctx, ids[0], StateInReview, ""); err != nil {
+		t.Fatalf("Transition returned error: %v", err)
+	}
+	accepted, err := service.Transition(ctx, ids[0], StateAccepted, "model missed the watermark")
+	if err != nil || accepted.ResolvedAt == nil || accepted.ReviewerNote != "model missed the watermark" {
+		t.Fatalf("Transition returned %+v, %v", accepted, err)
+	}
+	if _, err := service.Transition(ctx, ids[0], StateRejected, ""); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expected resolved disputes to be final, got %v", err)
+	}
+	if _, err := service.Transition(ctx, ids[1], StateOpen, ""); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expected ErrInvalidState, got %v", err)
+	}
+	if _, err := service.Transition(ctx, ids[1], StateRejected, ""); err != nil {
+		t.Fatalf("Transition returned error: %v", err)
+	}
+	if _, err := service.Transition(ctx, 999, StateRejected, ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	page, err = service.Queue(ctx, "", 10)
+	if err != nil || len(page.Items) != 1 || page.Items[0].RequestID != "req-3" {
+		t.Fatalf("expected only the unresolved dispute in the queue, got %+v, %v", page, err)
+	}
+
+	var jsonl bytes.Buffer
+	written, err := service.Export(ctx, &jsonl, "jsonl")
+	if err != nil || written != 2 {
+		t.Fatalf("Export returned %d, %v", written, err)
+	}
+	var examples []Example
+	for _, line := range strings.Split(strings.TrimSpace(jsonl.String()), "\n") {
+		var example Example
+		if err := json.Unmarshal([]byte(line), &example); err != nil {
+			t.Fatalf("invalid export line %q: %v", line, err)
+		}
+		examples = append(examples, example)
+	}
+	// An accepted dispute flips the verdict; a rejected one keeps it.
+	if examples[0].RequestID != "req-1" || !examples[0].Predicted || examples[0].Label || examples[0].Categories["nsfw"] != 0.5 {
+		t.Fatalf("unexpected accepted example %+v", examples[0])
+	}
+	if examples[1].RequestID != "req-2" || examples[1].Predicted || examples[1].Label {
+		t.Fatalf("unexpected rejected example %+v", examples[1])
+	}
+
+	var table bytes.Buffer
+	if _, err := service.Export(ctx, &table, "csv"); err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+	records, err := csv.NewReader(&table).ReadAll()
+	if err != nil || len(records) != 3 || records[0][0] != "request_id" || records[1][7] != "false" {
+		t.Fatalf("unexpected csv %v, %v", records, err)
+	}
+	if _, err := service.Export(ctx, &table, "xml"); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("expected ErrInvalidFormat, got %v", err)
+	}
+}
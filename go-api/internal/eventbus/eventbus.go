@@ -0,0 +1,122 @@
+// Package eventbus streams verification events to Kafka, NATS or a Redis stream so
+// downstream systems, such as fraud scoring, can consume results as they happen.
+package eventbus
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/worker"
+)
+
+// PublishJob is the worker job type that sends one event to the broker.
+const PublishJob = "eventbus.publish"
+
+// Message is an encoded event ready for the broker.
+type Message struct {
+	// ID is the event ID. Brokers that deduplicate use it to drop messages sent
+	// twice.
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Key is the user ID, so the events of one user keep their order on brokers
+	// that partition by key.
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	Value       []byte `json:"value"`
+}
+
+// Broker sends messages to a message broker.
+type Broker interface {
+	Publish(ctx context.Context, message Message) error
+}
+
+// Options tunes the relay.
+type Options struct {
+	// Format is FormatJSON or FormatAvro.
+	Format string
+	// Types lists the event types sent to the broker.
+	Types []string
+}
+
+// DefaultOptions returns the tunables used by NewRelay.
+func DefaultOptions() Options {
+	return Options{
+		Format: FormatJSON,
+		Types:  []string{usecase.EventVerificationCompleted, usecase.EventVerificationFailed},
+	}
+}
+
+// Relay publishes events through the background worker: Publish encodes an event
+// and queues it, and Send, run by the worker, hands it to the broker. A broker
+// outage therefore delays events, with retries and dead-lettering, instead of
+// failing or slowing verifications.
+type Relay struct {
+	queue  *worker.Queue
+	broker Broker
+	logger *zap.Logger
+	opts   Options
+}
+
+// NewRelay returns a relay with DefaultOptions.
+func NewRelay(queue *worker.Queue, broker Broker, logger *zap.Logger) *Relay {
+	return NewRelayWithOptions(queue, broker, logger, DefaultOptions())
+}
+
+// NewRelayWithOptions returns a relay with explicit tunables.
+func NewRelayWithOptions(queue *worker.Queue, broker Broker, logger *zap.Logger, opts Options) *Relay {
+	return &Relay{queue: queue, broker: broker, logger: logger.Named("eventbus"), opts: opts}
+}
+
+func (r *Relay) relays(eventType string) bool {
+	for _, t := range r.opts.Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish implements usecase.EventPublisher. The job ID is derived from the event
+// ID, so an event published twice while still queued is sent once.
+func (r *Relay) Publish(ctx context.Context, event usecase.Event) error {
+	if !r.relays(event.Type) {
+		return nil
+	}
+	record, ok := NewRecord(event)
+	if !ok {
+		return nil
+	}
+	value, err := Encode(record, r.opts.Format)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", event.Type, err)
+	}
+	job, err := worker.NewJob(PublishJob, Message{
+		ID:          event.ID,
+		Type:        event.Type,
+		Key:         event.UserID,
+		ContentType: ContentType(r.opts.Format),
+		Value:       value,
+	})
+	if err != nil {
+		return err
+	}
+	job.ID = PublishJob + ":" + event.ID
+	_, err = r.queue.Enqueue(ctx, job)
+	return err
+}
+
+// Send is the worker handler for PublishJob.
+func (r *Relay) Send(ctx context.Context, job *worker.Job) error {
+	var message Message
+	if err := job.Decode(&message); err != nil {
+		return worker.Permanent(fmt.Errorf("decode payload: %w", err))
+	}
+	if err := r.broker.Publish(ctx, message); err != nil {
+		return fmt.Errorf("publish %s event %s: %w", message.Type, message.ID, err)
+	}
+	r.logger.Debug("published event", zap.String("event", message.Type), zap.String("id", message.ID))
+	return nil
+}
@@ -0,0 +1,294 @@
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/worker"
+)
+
+type recordingBroker struct {
+	messages []Message
+	fail     error
+}
+
+func (b *recordingBroker) Publish(ctx context.Context, message Message) error {
+	if b.fail != nil {
+		return b.fail
+	}
+	b.messages = append(b.messages, message)
+	return nil
+}
+
+func TestRelayQueuesSelectedEventsOnce(t *testing.T) {
+	server, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer server.Close()
+	defer client.Close()
+	ctx := context.Background()
+	queue := worker.NewQueue(client)
+	broker := &recordingBroker{}
+	relay := NewRelay(queue, broker, zap.NewNop())
+
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	completed := usecase.Event{
+		ID: "event-1", Type: usecase.EventVerificationCompleted, UserID: "user-1", CreatedAt: createdAt,
+		Data: usecase.VerificationEvent{RequestID: "request-1", Verified: true, Score: 0.9, SHA1Hash: "abc"},
+	}
+	for _, event := range []usecase.Event{
+		completed,
+		completed,
+		{ID: "event-2", Type: usecase.EventVerificationNeedsReview, UserID: "user-1", Data: usecase.VerificationEvent{}},
+		{ID: "event-3", Type: usecase.EventVerificationFailed, UserID: "user-1", CreatedAt: createdAt,
+			Data: usecase.VerificationFailedEvent{RequestID: "request-2", Reason: "usecase.grpc_process_image"}},
+	} {
+		if err := relay.Publish(ctx, event); err != nil {
+			t.Fatalf("Publish returned error: %v", err)
+		}
+	}
+	stats, err := queue.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats returned error: %v", err)
+	}
+	if stats.Ready != 2 {
+		t.Fatalf("expected the completed and failed events to be queued once, got %+v", stats)
+	}
+
+	broker.fail = errors.New("broker unavailable")
+	job, _ := queue.Claim(ctx, time.Minute)
+	if err := relay.Send(ctx, job); err == nil {
+		t.Fatal("expected the broker failure to fail the job so it is retried")
+	}
+	broker.fail = nil
+	if err := relay.Send(ctx, job); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	job, _ = queue.Claim(ctx, time.Minute)
+	if err := relay.Send(ctx, job); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if len(broker.messages) != 2 {
+		t.Fatalf("expected 2 messages, got %+v", broker.messages)
+	}
+	var record Record
+	if err := json.Unmarshal(broker.messages[0].Value, &record); err != nil {
+		t.Fatalf("message is not JSON: %v", err)
+	}
+	if broker.messages[0].Key != "user-1" || broker.messages[0].ContentType != "application/json" ||
+		record.Version != SchemaVersion || record.RequestID != "request-1" || !record.Verified || record.Categories == nil {
+		t.Fatalf("unexpected message %+v with record %+v", broker.messages[0], record)
+	}
+	if err := json.Unmarshal(broker.messages[1].Value, &record); err != nil || record.Type != usecase.EventVerificationFailed || record.Reason != "usecase.grpc_process_image" {
+		t.Fatalf("unexpected failure record %+v", record)
+	}
+}
+
+func TestAvroSingleObjectEncoding(t *testing.T) {
+	// Fingerprint of the schema "null" from the Avro specification's test suite.
+	if got := avroFingerprint(`"null"`); got != 7195948357588979594 {
+		t.Fatalf("unexpected fingerprint %d", got)
+	}
+	canonical := canonicalSchema(AvroSchema)
+	if !strings.HasPrefix(canonical, `{"name":"ai_check.events.VerificationEvent","type":"record","fields":[{"name":"id","type":"string"}`) ||
+		!strings.Contains(canonical, `{"name":"occurred_at","type":"long"}`) ||
+		!strings.Contains(canonical, `"items":{"name":"ai_check.events.CategoryOutcome","type":"record"`) {
+		t.Fatalf("unexpected canonical form %s", canonical)
+	}
+
+	encoded, err := Encode(Record{
+		ID: "e", Type: "t", Version: 1, OccurredAt: time.UnixMilli(1000), Verified: true, Score: 1,
+		Categories: []usecase.CategoryOutcome{{Category: "n", Score: 0.5, Flagged: true}},
+	}, FormatAvro)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+	if encoded[0] != 0xC3 || encoded[1] != 0x01 || binary.LittleEndian.Uint64(encoded[2:10]) != AvroFingerprint {
+		t.Fatalf("unexpected header % x", encoded[:10])
+	}
+	want := []byte{
+		2, 'e', 2, 't', 2, // id, type, version
+		0, 0, // user_id, request_id
+		0xd0, 0x0f, // occurred_at 1000
+		1, 0, 0, 0x80, 0x3f, // verified, score
+		0, 0, // message, sha1_hash
+		2, 2, 'n', 0, 0, 0, 0x3f, 0, 0, 0, 0, 1, 0, // one category, end of array
+		0, // reason
+		0, // sha256_hash
+	}
+	if got := encoded[10:]; string(got) != string(want) {
+		t.Fatalf("unexpected record\n got % x\nwant % x", got, want)
+	}
+}
+
+func TestKafkaProducesKeyedBinaryRecords(t *testing.T) {
+	var produced map[string][]map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "producer" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/topics/verifications" || r.Header.Get("Content-Type") != "application/vnd.kafka.binary.v2+json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&produced)
+		if string(mustDecode(t, produced["records"][0]["value"])) == "rejected" {
+			w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":40403,"error":"schema not found"}]}`))
+			return
+		}
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":7,"error_code":null,"error":null}]}`))
+	}))
+	defer server.Close()
+
+	kafka, err := NewKafka(KafkaOptions{URL: server.URL + "/", Topic: "verifications", Username: "producer", Password: "secret"})
+	if err != nil {
+		t.Fatalf("NewKafka returned error: %v", err)
+	}
+	ctx := context.Background()
+	if err := kafka.Publish(ctx, Message{Key: "user-1", Value: []byte(`{"id":"e"}`)}); err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+	record := produced["records"][0]
+	if string(mustDecode(t, record["key"])) != "user-1" || string(mustDecode(t, record["value"])) != `{"id":"e"}` {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	if err := kafka.Publish(ctx, Message{Key: "user-1", Value: []byte("rejected")}); err == nil || !strings.Contains(err.Error(), "40403") {
+		t.Fatalf("expected the rejected record to fail, got %v", err)
+	}
+}
+
+func mustDecode(t *testing.T, value string) []byte {
+	t.Helper()
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatalf("invalid base64 %q", value)
+	}
+	return decoded
+}
+
+// fakeNATS accepts one connection and acknowledges JetStream publishes, reporting
+// every HPUB it receives. The first publish is rejected with a JetStream error.
+func fakeNATS(t *testing.T, published chan<- string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned error: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveNATS(conn, published)
+		}
+	}()
+	return "nats://token-1@" + listener.Addr().String()
+}
+
+func serveNATS(conn net.Conn, published chan<- string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "INFO {\"headers\":true}\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "CONNECT":
+			if !strings.Contains(line, `"auth_token":"token-1"`) {
+				fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case "PING":
+			fmt.Fprint(conn, "PING\r\n")
+			reader.ReadString('\n') // PONG
+			fmt.Fprint(conn, "PONG\r\n")
+		case "HPUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			data := make([]byte, size+2)
+			io.ReadFull(reader, data)
+			published <- fields[1] + " " + string(data[:size])
+			ack := `{"stream":"EVENTS","seq":1}`
+			if len(published) == 1 {
+				ack = `{"error":{"code":503,"description":"stream offline"}}`
+			}
+			fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", fields[2], len(ack), ack)
+		}
+	}
+}
+
+func TestNATSWaitsForJetStreamAck(t *testing.T) {
+	published := make(chan string, 2)
+	nats, err := NewNATS(NATSOptions{URL: fakeNATS(t, published), Subject: "ai-check", JetStream: true, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("NewNATS returned error: %v", err)
+	}
+	defer nats.Close()
+	message := Message{ID: "event-1", Type: usecase.EventVerificationCompleted, ContentType: "application/json", Value: []byte(`{"id":"event-1"}`)}
+	ctx := context.Background()
+	if err := nats.Publish(ctx, message); err == nil || !strings.Contains(err.Error(), "stream offline") {
+		t.Fatalf("expected the JetStream error, got %v", err)
+	}
+	// The failed publish dropped the connection; the next one dials again.
+	if err := nats.Publish(ctx, message); err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+	got := <-published
+	want := "ai-check.verification.completed NATS/1.0\r\nNats-Msg-Id: event-1\r\nContent-Type: application/json\r\n\r\n{\"id\":\"event-1\"}"
+	if got != want {
+		t.Fatalf("unexpected publish %q", got)
+	}
+
+	if _, err := NewNATS(NATSOptions{URL: "http://nats:4222"}); err == nil {
+		t.Fatal("expected a non-nats URL to be rejected")
+	}
+}
+
+func TestRedisStreamAppendsEntries(t *testing.T) {
+	server, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer server.Close()
+	defer client.Close()
+	stream, err := NewRedisStream(client, RedisStreamOptions{Stream: "ai-check:events", MaxLen: 1000})
+	if err != nil {
+		t.Fatalf("NewRedisStream returned error: %v", err)
+	}
+
+	message := Message{ID: "event-1", Type: usecase.EventVerificationCompleted, Key: "user-1", ContentType: ContentType(FormatJSON), Value: []byte(`{"id":"event-1"}`)}
+	if err := stream.Publish(context.Background(), message); err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+	entries, err := client.XRange(context.Background(), "ai-check:events", "-", "+").Result()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one entry, got %v (%v)", entries, err)
+	}
+	values := entries[0].Values
+	if values["id"] != "event-1" || values["type"] != usecase.EventVerificationCompleted || values["key"] != "user-1" || values["value"] != `{"id":"event-1"}` {
+		t.Fatalf("unexpected entry %v", values)
+	}
+}
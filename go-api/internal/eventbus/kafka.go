@@ -0,0 +1,93 @@
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// KafkaOptions configures a Kafka topic reached through a REST proxy speaking the
+// Confluent REST Proxy v2 API, which Redpanda's HTTP proxy also serves.
+type KafkaOptions struct {
+	// URL is the REST proxy, e.g. http://kafka-rest:8082.
+	URL   string
+	Topic string
+	// Username and Password are sent with basic authentication when set.
+	Username string
+	Password string
+	Client   *http.Client
+}
+
+// Kafka produces messages to one topic, keyed by user ID. The value is sent as
+// binary, so JSON and Avro messages reach consumers byte for byte.
+type Kafka struct {
+	opts     KafkaOptions
+	endpoint string
+}
+
+// NewKafka returns a broker producing to opts.Topic.
+func NewKafka(opts KafkaOptions) (*Kafka, error) {
+	if opts.URL == "" || opts.Topic == "" {
+		return nil, errors.New("url and topic are required")
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &Kafka{opts: opts, endpoint: strings.TrimRight(opts.URL, "/") + "/topics/" + url.PathEscape(opts.Topic)}, nil
+}
+
+type kafkaRecord struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Publish implements Broker.
+func (k *Kafka) Publish(ctx context.Context, message Message) error {
+	body, err := json.Marshal(struct {
+		Records []kafkaRecord `json:"records"`
+	}{[]kafkaRecord{{Key: []byte(message.Key), Value: message.Value}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.binary.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if k.opts.Username != "" {
+		req.SetBasicAuth(k.opts.Username, k.opts.Password)
+	}
+	resp, err := k.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("kafka: status %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	// The proxy answers 200 even when the broker rejected a record, reporting the
+	// failure per record.
+	var produced struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&produced); err != nil {
+		return fmt.Errorf("kafka: decode response: %w", err)
+	}
+	for _, offset := range produced.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("kafka: record rejected with code %d: %s", *offset.ErrorCode, offset.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NATSOptions configures a NATS server reached over the client protocol.
+type NATSOptions struct {
+	// URL is the server, e.g. nats://nats:4222; tls:// requires TLS. Credentials
+	// in the URL are used when Username and Token are empty.
+	URL string
+	// Subject prefixes the event type, e.g. "ai-check" publishes to
+	// "ai-check.verification.completed".
+	Subject  string
+	Token    string
+	Username string
+	Password string
+	// JetStream waits for a stream to acknowledge each message and sets
+	// Nats-Msg-Id, so the stream drops messages sent twice. Without it a message
+	// is only confirmed to have reached the server.
+	JetStream bool
+	// Timeout bounds connecting and each publish; defaults to 10 seconds.
+	Timeout   time.Duration
+	TLSConfig *tls.Config
+}
+
+// NATS publishes messages on one connection, dialed on first use and again after
+// any error.
+type NATS struct {
+	opts NATSOptions
+	url  *url.URL
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	inbox  string
+	seq    uint64
+}
+
+// NewNATS returns a broker publishing under opts.Subject.
+func NewNATS(opts NATSOptions) (*NATS, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+		return nil, errors.New("url must be a nats:// or tls:// URL")
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if opts.Username == "" && opts.Token == "" && u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			opts.Username, opts.Password = u.User.Username(), password
+		} else {
+			opts.Token = u.User.Username()
+		}
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	return &NATS{opts: opts, url: u}, nil
+}
+
+// Publish implements Broker.
+func (n *NATS) Publish(ctx context.Context, message Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	err := n.publish(ctx, message)
+	if err != nil && n.conn != nil {
+		n.conn.Close()
+		n.conn = nil
+	}
+	return err
+}
+
+// Close closes the connection.
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn = nil
+	return err
+}
+
+func (n *NATS) publish(ctx context.Context, message Message) error {
+	deadline, ok := ctx.Deadline()
+	if limit := time.Now().Add(n.opts.Timeout); !ok || limit.Before(deadline) {
+		deadline = limit
+	}
+	if n.conn == nil {
+		if err := n.connect(ctx, deadline); err != nil {
+			return fmt.Errorf("nats: connect: %w", err)
+		}
+	}
+	if err := n.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	subject := message.Type
+	if n.opts.Subject != "" {
+		subject = n.opts.Subject + "." + message.Type
+	}
+	headers := "NATS/1.0\r\nNats-Msg-Id: " + message.ID + "\r\nContent-Type: " + message.ContentType + "\r\n\r\n"
+	reply := ""
+	if n.opts.JetStream {
+		n.seq++
+		reply = n.inbox + "." + strconv.FormatUint(n.seq, 10)
+	}
+	var command strings.Builder
+	command.WriteString("HPUB " + subject + " ")
+	if reply != "" {
+		command.WriteString(reply + " ")
+	}
+	fmt.Fprintf(&command, "%d %d\r\n%s%s\r\n", len(headers), len(headers)+len(message.Value), headers, message.Value)
+	if !n.opts.JetStream {
+		// The server answers PING only after processing the publish, so an error
+		// such as a permissions violation arrives first.
+		command.WriteString("PING\r\n")
+	}
+	if _, err := io.WriteString(n.conn, command.String()); err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+
+	payload, err := n.await(reply)
+	if err != nil || !n.opts.JetStream {
+		return err
+	}
+	var ack struct {
+		Stream string `json:"stream"`
+		Error  *struct {
+			Code        int    `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(payload, &ack); err != nil {
+		return fmt.Errorf("nats: decode jetstream ack: %w", err)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("nats: jetstream: %d %s", ack.Error.Code, ack.Error.Description)
+	}
+	if ack.Stream == "" {
+		return fmt.Errorf("nats: no jetstream stream acknowledged %s", subject)
+	}
+	return nil
+}
+
+// connect dials the server and authenticates, upgrading to TLS when the URL or the
+// server asks for it.
+func (n *NATS) connect(ctx context.Context, deadline time.Time) error {
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", n.url.Host)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+		Headers     bool `json:"headers"`
+	}
+	if !strings.HasPrefix(line, "INFO ") || json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info) != nil {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+	if !info.Headers {
+		conn.Close()
+		return errors.New("server does not support message headers")
+	}
+	secure := n.url.Scheme == "tls" || info.TLSRequired
+	if secure {
+		config := n.opts.TLSConfig
+		if config == nil {
+			config = &tls.Config{ServerName: n.url.Hostname(), MinVersion: tls.VersionTLS12}
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn, reader = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	options := map[string]interface{}{
+		"verbose":      false,
+		"pedantic":     false,
+		"tls_required": secure,
+		"name":         "ai-check",
+		"lang":         "go",
+		"version":      "1",
+		"protocol":     1,
+		"headers":      true,
+	}
+	if n.opts.Token != "" {
+		options["auth_token"] = n.opts.Token
+	}
+	if n.opts.Username != "" {
+		options["user"] = n.opts.Username
+		options["pass"] = n.opts.Password
+	}
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	commands := "CONNECT " + string(encoded) + "\r\n"
+	inbox := "_INBOX." + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if n.opts.JetStream {
+		commands += "SUB " + inbox + ".* 1\r\n"
+	}
+	if _, err := io.WriteString(conn, commands+"PING\r\n"); err != nil {
+		conn.Close()
+		return err
+	}
+	n.conn, n.reader, n.inbox = conn, reader, inbox
+	if _, err := n.await(""); err != nil {
+		n.conn.Close()
+		n.conn = nil
+		return err
+	}
+	return nil
+}
+
+// await reads protocol messages until the reply to subject arrives, returning its
+// payload, or until PONG when subject is empty.
+func (n *NATS) await(subject string) ([]byte, error) {
+	for {
+		line, err := n.reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("nats: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			if _, err := io.WriteString(n.conn, "PONG\r\n"); err != nil {
+				return nil, fmt.Errorf("nats: %w", err)
+			}
+		case "PONG":
+			if subject == "" {
+				return nil, nil
+			}
+		case "-ERR":
+			return nil, fmt.Errorf("nats: %s", strings.Trim(strings.TrimPrefix(line, fields[0]), " '"))
+		case "MSG", "HMSG":
+			// MSG <subject> <sid> [reply] <size>
+			// HMSG <subject> <sid> [reply] <header size> <total size>
+			headerSize := 0
+			if fields[0] == "HMSG" {
+				if len(fields) < 5 {
+					return nil, fmt.Errorf("nats: malformed %q", line)
+				}
+				headerSize, _ = strconv.Atoi(fields[len(fields)-2])
+			} else if len(fields) < 4 {
+				return nil, fmt.Errorf("nats: malformed %q", line)
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || headerSize > size {
+				return nil, fmt.Errorf("nats: malformed %q", line)
+			}
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(n.reader, data); err != nil {
+				return nil, fmt.Errorf("nats: %w", err)
+			}
+			if fields[1] != subject || subject == "" {
+				continue
+			}
+			if headerSize > 0 {
+				// A reply with only a status header, e.g. 503, means no stream
+				// listens on the subject.
+				status := strings.SplitN(string(data[:headerSize]), "\r\n", 2)[0]
+				if size == headerSize {
+					return nil, fmt.Errorf("nats: jetstream: %s", strings.TrimSpace(strings.TrimPrefix(status, "NATS/1.0")))
+				}
+			}
+			return data[headerSize:size], nil
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package eventbus
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/example/ai-check/internal/usecase"
+)
+
+// SchemaVersion is the version of Record. Fields are only ever added, with a
+// default, so consumers of an older version keep working.
+const SchemaVersion = 2
+
+// Record is the message published for every event. The same fields are encoded as
+// JSON or Avro; unset fields hold their zero value rather than being omitted.
+type Record struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Version   int    `json:"version"`
+	UserID    string `json:"user_id"`
+	RequestID string `json:"request_id"`
+	// OccurredAt is when the verification completed or failed, in UTC.
+	OccurredAt time.Time                 `json:"occurred_at"`
+	Verified   bool                      `json:"verified"`
+	Score      float32                   `json:"score"`
+	Message    string                    `json:"message"`
+	SHA1Hash   string                    `json:"sha1_hash"`
+	Categories []usecase.CategoryOutcome `json:"categories"`
+	// Reason names the failed step of a verification.failed event.
+	Reason string `json:"reason"`
+	// SHA256Hash was added in version 2; SHA1Hash is deprecated in its favour.
+	SHA256Hash string `json:"sha256_hash"`
+}
+
+// NewRecord converts an event, reporting false for event data it does not know.
+func NewRecord(event usecase.Event) (Record, bool) {
+	record := Record{
+		ID:         event.ID,
+		Type:       event.Type,
+		Version:    SchemaVersion,
+		UserID:     event.UserID,
+		OccurredAt: event.CreatedAt.UTC(),
+		Categories: []usecase.CategoryOutcome{},
+	}
+	switch data := event.Data.(type) {
+	case usecase.VerificationEvent:
+		record.RequestID = data.RequestID
+		record.Verified = data.Verified
+		record.Score = data.Score
+		record.Message = data.Message
+		record.SHA1Hash = data.SHA1Hash
+		record.SHA256Hash = data.SHA256Hash
+		if data.Categories != nil {
+			record.Categories = data.Categories
+		}
+	case usecase.VerificationFailedEvent:
+		record.RequestID = data.RequestID
+		record.Reason = data.Reason
+	default:
+		return Record{}, false
+	}
+	return record, true
+}
+
+// Formats a Record is encoded in.
+const (
+	FormatJSON = "json"
+	FormatAvro = "avro"
+)
+
+// AvroSchema is the Avro schema of Record. occurred_at is in milliseconds since the
+// Unix epoch.
+const AvroSchema = `{
+  "type": "record",
+  "name": "VerificationEvent",
+  "namespace": "ai_check.events",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "type", "type": "string"},
+    {"name": "version", "type": "int"},
+    {"name": "user_id", "type": "string"},
+    {"name": "request_id", "type": "string"},
+    {"name": "occurred_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "verified", "type": "boolean"},
+    {"name": "score", "type": "float"},
+    {"name": "message", "type": "string"},
+    {"name": "sha1_hash", "type": "string"},
+    {"name": "categories", "type": {"type": "array", "items": {
+      "type": "record",
+      "name": "CategoryOutcome",
+      "fields": [
+        {"name": "category", "type": "string"},
+        {"name": "score", "type": "float"},
+        {"name": "threshold", "type": "float"},
+        {"name": "flagged", "type": "boolean"}
+      ]
+    }}},
+    {"name": "reason", "type": "string"},
+    {"name": "sha256_hash", "type": "string", "default": ""}
+  ]
+}`
+
+// AvroFingerprint is the CRC-64-AVRO fingerprint of the canonical form of
+// AvroSchema, which prefixes every Avro message.
+var AvroFingerprint = avroFingerprint(canonicalSchema(AvroSchema))
+
+// ContentType returns the content type of messages in format.
+func ContentType(format string) string {
+	if format == FormatAvro {
+		return "application/avro"
+	}
+	return "application/json"
+}
+
+// Encode encodes record in format. Avro messages use the single-object encoding:
+// the bytes C3 01, the schema fingerprint in little endian, then the record.
+func Encode(record Record, format string) ([]byte, error) {
+	switch format {
+	case FormatJSON, "":
+		return json.Marshal(record)
+	case FormatAvro:
+		out := []byte{0xC3, 0x01}
+		out = binary.LittleEndian.AppendUint64(out, AvroFingerprint)
+		return appendAvroRecord(out, record), nil
+	default:
+		return nil, fmt.Errorf("unknown event format %q", format)
+	}
+}
+
+func appendAvroRecord(out []byte, record Record) []byte {
+	out = appendAvroString(out, record.ID)
+	out = appendAvroString(out, record.Type)
+	out = appendAvroLong(out, int64(record.Version))
+	out = appendAvroString(out, record.UserID)
+	out = appendAvroString(out, record.RequestID)
+	out = appendAvroLong(out, record.OccurredAt.UnixMilli())
+	out = appendAvroBool(out, record.Verified)
+	out = appendAvroFloat(out, record.Score)
+	out = appendAvroString(out, record.Message)
+	out = appendAvroString(out, record.SHA1Hash)
+	if len(record.Categories) > 0 {
+		out = appendAvroLong(out, int64(len(record.Categories)))
+		for _, category := range record.Categories {
+			out = appendAvroString(out, category.Category)
+			out = appendAvroFloat(out, category.Score)
+			out = appendAvroFloat(out, category.Threshold)
+			out = appendAvroBool(out, category.Flagged)
+		}
+	}
+	out = appendAvroLong(out, 0)
+	out = appendAvroString(out, record.Reason)
+	return appendAvroString(out, record.SHA256Hash)
+}
+
+// appendAvroLong writes a zig-zag encoded variable-length integer, which Avro uses
+// for both int and long.
+func appendAvroLong(out []byte, v int64) []byte {
+	return binary.AppendUvarint(out, uint64((v<<1)^(v>>63)))
+}
+
+func appendAvroString(out []byte, s string) []byte {
+	return append(appendAvroLong(out, int64(len(s))), s...)
+}
+
+func appendAvroBool(out []byte, b bool) []byte {
+	if b {
+		return append(out, 1)
+	}
+	return append(out, 0)
+}
+
+func appendAvroFloat(out []byte, f float32) []byte {
+	return binary.LittleEndian.AppendUint32(out, math.Float32bits(f))
+}
+
+// canonicalSchema returns the Parsing Canonical Form of an Avro schema: full names,
+// only the attributes that affect parsing, in a fixed order and without whitespace.
+// It supports the subset of Avro AvroSchema uses.
+func canonicalSchema(schema string) string {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(schema), &parsed); err != nil {
+		panic(err)
+	}
+	var b strings.Builder
+	writeCanonical(&b, parsed, "")
+	return b.String()
+}
+
+func writeCanonical(b *strings.Builder, schema interface{}, namespace string) {
+	switch s := schema.(type) {
+	case string:
+		b.WriteString(`"` + s + `"`)
+	case []interface{}:
+		b.WriteString("[")
+		for i, branch := range s {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			writeCanonical(b, branch, namespace)
+		}
+		b.WriteString("]")
+	case map[string]interface{}:
+		switch s["type"] {
+		case "record":
+			name, _ := s["name"].(string)
+			if ns, ok := s["namespace"].(string); ok {
+				namespace = ns
+			}
+			if !strings.Contains(name, ".") && namespace != "" {
+				name = namespace + "." + name
+			}
+			b.WriteString(`{"name":"` + name + `","type":"record","fields":[`)
+			fields, _ := s["fields"].([]interface{})
+			for i, field := range fields {
+				f, _ := field.(map[string]interface{})
+				if i > 0 {
+					b.WriteString(",")
+				}
+				b.WriteString(`{"name":"` + f["name"].(string) + `","type":`)
+				writeCanonical(b, f["type"], namespace)
+				b.WriteString("}")
+			}
+			b.WriteString("]}")
+		case "array":
+			b.WriteString(`{"type":"array","items":`)
+			writeCanonical(b, s["items"], namespace)
+			b.WriteString("}")
+		default:
+			// A primitive with attributes such as logicalType.
+			writeCanonical(b, s["type"], namespace)
+		}
+	default:
+		panic(fmt.Sprintf("unsupported avro schema %v", schema))
+	}
+}
+
+// avroFingerprint is the 64-bit Rabin fingerprint the Avro specification calls
+// CRC-64-AVRO.
+func avroFingerprint(canonical string) uint64 {
+	const empty = 0xc15d213aa4d7a795
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (empty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	fp := uint64(empty)
+	for i := 0; i < len(canonical); i++ {
+		fp = (fp >> 8) ^ table[byte(fp)^canonical[i]]
+	}
+	return fp
+}
@@ -0,0 +1,52 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStreamOptions configures a Redis stream events are appended to.
+type RedisStreamOptions struct {
+	// Stream is the key of the stream, e.g. "ai-check:events".
+	Stream string
+	// MaxLen trims the stream to about this many entries; 0 keeps every entry.
+	MaxLen int64
+}
+
+// RedisStream appends messages to a Redis stream, which consumer groups read with
+// XREADGROUP. Each entry has the fields id, type, key, content_type and value.
+type RedisStream struct {
+	client *redis.Client
+	opts   RedisStreamOptions
+}
+
+// NewRedisStream returns a broker appending to opts.Stream through client.
+func NewRedisStream(client *redis.Client, opts RedisStreamOptions) (*RedisStream, error) {
+	if opts.Stream == "" {
+		return nil, errors.New("stream is required")
+	}
+	return &RedisStream{client: client, opts: opts}, nil
+}
+
+// Publish implements Broker.
+func (s *RedisStream) Publish(ctx context.Context, message Message) error {
+	err := s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.opts.Stream,
+		MaxLen: s.opts.MaxLen,
+		Approx: s.opts.MaxLen > 0,
+		Values: map[string]interface{}{
+			"id":           message.ID,
+			"type":         message.Type,
+			"key":          message.Key,
+			"content_type": message.ContentType,
+			"value":        message.Value,
+		},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("append to stream %s: %w", s.opts.Stream, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package experiment splits verification traffic between processor models, so a new
+// model can be compared with the current one on live traffic before it takes over.
+package experiment
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/example/ai-check/internal/imageprocessor"
+)
+
+// Variant is one arm of an experiment.
+type Variant struct {
+	// Name is recorded on every verification the variant handles.
+	Name string
+	// Percent is the share of users, from 0 to 100, assigned to the variant. The
+	// percentages of an experiment add up to 100.
+	Percent int
+	// Users always get this variant, whatever their bucket, e.g. internal testers.
+	Users []string
+	// Processor scores the images of the variant's users.
+	Processor imageprocessor.Client
+}
+
+// Experiment assigns users to variants. Assignment is sticky: a user keeps their
+// variant as long as the experiment name and the percentages are unchanged, so their
+// results are comparable over time.
+type Experiment struct {
+	name     string
+	variants []Variant
+	cohorts  map[string]int
+}
+
+// New validates the variants and returns the experiment. name salts the assignment,
+// so renaming an experiment reshuffles users between variants.
+func New(name string, variants []Variant) (*Experiment, error) {
+	if name == "" {
+		return nil, errors.New("experiment: name is required")
+	}
+	if len(variants) == 0 {
+		return nil, errors.New("experiment: at least one variant is required")
+	}
+	e := &Experiment{name: name, variants: variants, cohorts: make(map[string]int)}
+	total := 0
+	seen := make(map[string]bool, len(variants))
+	for i, variant := range variants {
+		switch {
+		case variant.Name == "":
+			return nil, fmt.Errorf("experiment: variant %d has no name", i)
+		case seen[variant.Name]:
+			return nil, fmt.Errorf("experiment: variant %q is defined twice", variant.Name)
+		case variant.Percent < 0 || variant.Percent > 100:
+			return nil, fmt.Errorf("experiment: variant %q percent must be between 0 and 100", variant.Name)
+		case variant.Processor == nil:
+			return nil, fmt.Errorf("experiment: variant %q has no processor", variant.Name)
+		}
+		seen[variant.Name] = true
+		total += variant.Percent
+		for _, userID := range variant.Users {
+			if other, ok := e.cohorts[userID]; ok {
+				return nil, fmt.Errorf("experiment: user %q is in variants %q and %q", userID, variants[other].Name, variant.Name)
+			}
+			e.cohorts[userID] = i
+		}
+	}
+	if total != 100 {
+		return nil, fmt.Errorf("experiment: variant percentages add up to %d, not 100", total)
+	}
+	return e, nil
+}
+
+// Name returns the experiment name.
+func (e *Experiment) Name() string {
+	return e.name
+}
+
+// Variants returns the variants in their configured order.
+func (e *Experiment) Variants() []Variant {
+	return e.variants
+}
+
+// Assign returns the variant of a user and the processor that serves it.
+func (e *Experiment) Assign(userID string) (string, imageprocessor.Client) {
+	variant := e.variants[e.index(userID)]
+	return variant.Name, variant.Processor
+}
+
+func (e *Experiment) index(userID string) int {
+	if i, ok := e.cohorts[userID]; ok {
+		return i
+	}
+	h := fnv.New32a()
+	h.Write([]byte(e.name))
+	h.Write([]byte{0})
+	h.Write([]byte(userID))
+	bucket := int(h.Sum32() % 100)
+	for i, variant := range e.variants {
+		if bucket < variant.Percent {
+			return i
+		}
+		bucket -= variant.Percent
+	}
+	return len(e.variants) - 1
+}
@@ -0,0 +1,63 @@
+package experiment
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/example/ai-check/internal/imageprocessor"
+)
+
+type namedProcessor string
+
+func (namedProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
+	return &imageprocessor.Result{}, nil
+}
+
+func TestAssignSplitsUsersByPercentAndCohort(t *testing.T) {
+	experiment, err := New("model-v2", []Variant{
+		{Name: "control", Percent: 80, Processor: namedProcessor("v1")},
+		{Name: "candidate", Percent: 20, Users: []string{"qa-1"}, Processor: namedProcessor("v2")},
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		variant, processor := experiment.Assign(userID)
+		if again, _ := experiment.Assign(userID); again != variant {
+			t.Fatalf("expected %s to keep variant %s, got %s", userID, variant, again)
+		}
+		if want := map[string]namedProcessor{"control": "v1", "candidate": "v2"}[variant]; processor != want {
+			t.Fatalf("variant %s got processor %v", variant, processor)
+		}
+		counts[variant]++
+	}
+	if counts["candidate"] < 1800 || counts["candidate"] > 2200 {
+		t.Fatalf("expected about 20%% of users in candidate, got %v", counts)
+	}
+
+	for i := 0; i < 100; i++ {
+		if variant, _ := experiment.Assign("qa-1"); variant != "candidate" {
+			t.Fatalf("expected the cohort user to get candidate, got %s", variant)
+		}
+	}
+}
+
+func TestNewRejectsInvalidExperiments(t *testing.T) {
+	processor := namedProcessor("v1")
+	for name, variants := range map[string][]Variant{
+		"no variants":    nil,
+		"unnamed":        {{Percent: 100, Processor: processor}},
+		"duplicate name": {{Name: "a", Percent: 50, Processor: processor}, {Name: "a", Percent: 50, Processor: processor}},
+		"percent sum":    {{Name: "a", Percent: 60, Processor: processor}, {Name: "b", Percent: 30, Processor: processor}},
+		"no processor":   {{Name: "a", Percent: 100}},
+		"user twice":     {{Name: "a", Percent: 50, Users: []string{"u"}, Processor: processor}, {Name: "b", Percent: 50, Users: []string{"u"}, Processor: processor}},
+	} {
+		if _, err := New("exp", variants); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
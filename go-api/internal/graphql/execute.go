@@ -0,0 +1,855 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Error is a GraphQL error. Path is set for errors raised while executing a field.
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Response is the result of a request. Data is only encoded when execution started:
+// requests failing to parse or validate carry errors alone.
+type Response struct {
+	Data     interface{}
+	Errors   []*Error
+	executed bool
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r *Response) MarshalJSON() ([]byte, error) {
+	body := struct {
+		Errors []*Error     `json:"errors,omitempty"`
+		Data   *interface{} `json:"data,omitempty"`
+	}{Errors: r.Errors}
+	if r.executed {
+		body.Data = &r.Data
+	}
+	return json.Marshal(body)
+}
+
+// Executed reports whether the operation ran, i.e. the request was valid.
+func (r *Response) Executed() bool { return r.executed }
+
+func failed(errs ...*Error) *Response {
+	return &Response{Errors: errs}
+}
+
+// Execute runs the query of req. Errors never make it fail; they are reported in the
+// response, next to the data that could be computed.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return failed(err.(*Error))
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return failed(err.(*Error))
+	}
+	if errs := s.validate(doc, op); len(errs) > 0 {
+		return failed(errs...)
+	}
+	variables, errs := s.coerceVariables(op, req.Variables)
+	if len(errs) > 0 {
+		return failed(errs...)
+	}
+
+	e := &executor{schema: s, doc: doc, variables: variables}
+	data, _ := e.executeSelections(ctx, s.query, nil, op.selections, nil)
+	response := &Response{Errors: e.errors, executed: true}
+	if data != nil {
+		response.Data = data
+	}
+	return response
+}
+
+func selectOperation(doc *document, name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) != 1 {
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+}
+
+// validate checks the operation against the schema before anything is resolved.
+func (s *Schema) validate(doc *document, op *operation) []*Error {
+	v := &validator{schema: s, doc: doc, variables: map[string]*variableDefinition{}, used: map[string]bool{}}
+	names := map[string]bool{}
+	for _, other := range doc.operations {
+		if other.name == "" && len(doc.operations) > 1 {
+			v.errorf(other.loc, "This anonymous operation must be the only defined operation.")
+		} else if other.name != "" && names[other.name] {
+			v.errorf(other.loc, "There can be only one operation named %q.", other.name)
+		}
+		names[other.name] = true
+	}
+	if op.kind != "query" {
+		v.errorf(op.loc, "%s operations are not supported.", op.kind)
+		return v.errors
+	}
+	for _, frag := range doc.fragments {
+		if _, ok := s.types[frag.typeCondition].(*Object); !ok {
+			v.errorf(frag.loc, "Unknown type %q.", frag.typeCondition)
+		}
+	}
+	for _, definition := range op.variables {
+		if v.variables[definition.name] != nil {
+			v.errorf(definition.loc, "There can be only one variable named \"$%s\".", definition.name)
+		}
+		v.variables[definition.name] = definition
+		t, ok := s.inputType(definition.typ)
+		if !ok {
+			v.errorf(definition.loc, "Variable \"$%s\" cannot be non-input type %q.", definition.name, definition.typ)
+			continue
+		}
+		if definition.defValue != nil {
+			if _, err := coerceLiteral(t, definition.defValue, nil); err != nil {
+				v.errorf(definition.defValue.loc, "Variable \"$%s\" has invalid default value: %s", definition.name, err)
+			}
+		}
+	}
+	v.validateDirectives(op.directives)
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	v.validateSelectionSet(s.query, op.selections, map[string]bool{})
+	for _, definition := range op.variables {
+		if !v.used[definition.name] {
+			v.errorf(definition.loc, "Variable \"$%s\" is never used.", definition.name)
+		}
+	}
+	if s.opts.MaxFields > 0 && v.fields > s.opts.MaxFields {
+		v.errorf(op.loc, "The query selects %d fields, more than the limit of %d.", v.fields, s.opts.MaxFields)
+	}
+	return v.errors
+}
+
+// inputType resolves a variable type.
+func (s *Schema) inputType(ref *typeRef) (Type, bool) {
+	var t Type
+	if ref.elem != nil {
+		elem, ok := s.inputType(ref.elem)
+		if !ok {
+			return nil, false
+		}
+		t = NewList(elem)
+	} else if scalar, ok := s.types[ref.name].(*Scalar); ok {
+		t = scalar
+	} else {
+		return nil, false
+	}
+	if ref.nonNull {
+		t = NewNonNull(t)
+	}
+	return t, true
+}
+
+type validator struct {
+	schema    *Schema
+	doc       *document
+	variables map[string]*variableDefinition
+	used      map[string]bool
+	fields    int
+	errors    []*Error
+}
+
+func (v *validator) errorf(loc Location, format string, args ...interface{}) {
+	v.errors = append(v.errors, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}})
+}
+
+// validateSelectionSet checks a selection set on parent. spreading holds the
+// fragments being expanded, to detect cycles.
+func (v *validator) validateSelectionSet(parent *Object, selections []selection, spreading map[string]bool) {
+	v.checkMerge(selections, map[string]*field{}, map[string]bool{})
+	v.validateSelections(parent, selections, spreading)
+}
+
+// validateSelections checks selections of a selection set, including the ones of
+// the fragments it spreads.
+func (v *validator) validateSelections(parent *Object, selections []selection, spreading map[string]bool) {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			v.validateField(parent, sel, spreading)
+		case *inlineFragment:
+			v.validateDirectives(sel.directives)
+			if sel.typeCondition != "" && sel.typeCondition != parent.Name {
+				v.errorf(sel.loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", parent.Name, sel.typeCondition)
+				continue
+			}
+			v.validateSelections(parent, sel.selections, spreading)
+		case *fragmentSpread:
+			v.validateDirectives(sel.directives)
+			frag, ok := v.doc.fragments[sel.name]
+			if !ok {
+				v.errorf(sel.loc, "Unknown fragment %q.", sel.name)
+				continue
+			}
+			if spreading[sel.name] {
+				v.errorf(sel.loc, "Cannot spread fragment %q within itself.", sel.name)
+				continue
+			}
+			if frag.typeCondition != parent.Name {
+				v.errorf(sel.loc, "Fragment %q cannot be spread here as objects of type %q can never be of type %q.", sel.name, parent.Name, frag.typeCondition)
+				continue
+			}
+			spreading[sel.name] = true
+			v.validateSelections(parent, frag.selections, spreading)
+			delete(spreading, sel.name)
+		}
+	}
+}
+
+// checkMerge reports fields sharing a response key that select different fields or
+// arguments, which could not be merged into one result.
+func (v *validator) checkMerge(selections []selection, seen map[string]*field, spreading map[string]bool) {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			key := sel.responseKey()
+			other, ok := seen[key]
+			if !ok {
+				seen[key] = sel
+				continue
+			}
+			if other.name != sel.name {
+				v.errorf(sel.loc, "Fields %q conflict because %q and %q are different fields.", key, other.name, sel.name)
+			} else if printArguments(other.arguments) != printArguments(sel.arguments) {
+				v.errorf(sel.loc, "Fields %q conflict because they have differing arguments.", key)
+			}
+		case *inlineFragment:
+			v.checkMerge(sel.selections, seen, spreading)
+		case *fragmentSpread:
+			if frag, ok := v.doc.fragments[sel.name]; ok && !spreading[sel.name] {
+				spreading[sel.name] = true
+				v.checkMerge(frag.selections, seen, spreading)
+				delete(spreading, sel.name)
+			}
+		}
+	}
+}
+
+func (v *validator) validateField(parent *Object, f *field, spreading map[string]bool) {
+	v.fields++
+	v.validateDirectives(f.directives)
+	if f.name == "__typename" {
+		if len(f.arguments) > 0 {
+			v.errorf(f.arguments[0].loc, "Unknown argument %q on field \"__typename\".", f.arguments[0].name)
+		}
+		if len(f.selections) > 0 {
+			v.errorf(f.loc, "Field \"__typename\" must not have a selection since type \"String!\" has no subfields.")
+		}
+		return
+	}
+	definition, ok := parent.Fields[f.name]
+	if !ok {
+		v.errorf(f.loc, "Cannot query field %q on type %q.", f.name, parent.Name)
+		return
+	}
+	v.validateArguments(fmt.Sprintf("%s.%s", parent.Name, f.name), definition.Args, f.arguments, f.loc)
+
+	object, isObject := namedType(definition.Type).(*Object)
+	switch {
+	case isObject && len(f.selections) == 0:
+		v.errorf(f.loc, "Field %q of type %q must have a selection of subfields.", f.name, definition.Type)
+	case !isObject && len(f.selections) > 0:
+		v.errorf(f.loc, "Field %q must not have a selection since type %q has no subfields.", f.name, definition.Type)
+	case isObject:
+		v.validateSelectionSet(object, f.selections, spreading)
+	}
+}
+
+var directiveArgs = []*Argument{{Name: "if", Type: NewNonNull(Boolean)}}
+
+func (v *validator) validateDirectives(directives []*directive) {
+	seen := map[string]bool{}
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			v.errorf(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		if seen[d.name] {
+			v.errorf(d.loc, "The directive \"@%s\" can only be used once at this location.", d.name)
+		}
+		seen[d.name] = true
+		v.validateArguments("@"+d.name, directiveArgs, d.arguments, d.loc)
+	}
+}
+
+func (v *validator) validateArguments(owner string, definitions []*Argument, arguments []*argument, loc Location) {
+	given := map[string]*argument{}
+	for _, arg := range arguments {
+		if given[arg.name] != nil {
+			v.errorf(arg.loc, "There can be only one argument named %q.", arg.name)
+		}
+		given[arg.name] = arg
+		definition := findArgument(definitions, arg.name)
+		if definition == nil {
+			v.errorf(arg.loc, "Unknown argument %q on %s.", arg.name, owner)
+			continue
+		}
+		v.validateValue(definition, arg.value)
+	}
+	for _, definition := range definitions {
+		if _, required := definition.Type.(*NonNull); required && definition.DefaultValue == nil && given[definition.Name] == nil {
+			v.errorf(loc, "Argument %q of type %q is required, but it was not provided.", definition.Name, definition.Type)
+		}
+	}
+}
+
+func findArgument(definitions []*Argument, name string) *Argument {
+	for _, definition := range definitions {
+		if definition.Name == name {
+			return definition
+		}
+	}
+	return nil
+}
+
+// validateValue checks a literal against the argument type and records the variables
+// it uses, checking their types are compatible.
+func (v *validator) validateValue(definition *Argument, val *value) {
+	var walk func(val *value, expected Type)
+	walk = func(val *value, expected Type) {
+		switch val.kind {
+		case valueVariable:
+			v.used[val.raw] = true
+			variable := v.variables[val.raw]
+			if variable == nil {
+				v.errorf(val.loc, "Variable \"$%s\" is not defined.", val.raw)
+				return
+			}
+			if !variableAllowed(variable, expected, definition.DefaultValue != nil) {
+				v.errorf(val.loc, "Variable \"$%s\" of type %q used in position expecting type %q.", val.raw, variable.typ, expected)
+			}
+		case valueList:
+			elem := expected
+			if nonNull, ok := elem.(*NonNull); ok {
+				elem = nonNull.OfType
+			}
+			if list, ok := elem.(*List); ok {
+				for _, item := range val.list {
+					walk(item, list.OfType)
+				}
+			}
+		}
+	}
+	walk(val, definition.Type)
+	if _, err := coerceLiteral(definition.Type, val, nil); err != nil {
+		v.errorf(val.loc, "Argument %q has invalid value %s: %s", definition.Name, printValue(val), err)
+	}
+}
+
+// variableAllowed reports whether a variable may be used where expected is
+// required. A nullable variable may fill a non-null position when either side has
+// a default value.
+func variableAllowed(variable *variableDefinition, expected Type, locationDefault bool) bool {
+	actual := variable.typ.String()
+	if actual == expected.String() {
+		return true
+	}
+	if variable.typ.nonNull && actual == expected.String()+"!" {
+		return true
+	}
+	if nonNull, ok := expected.(*NonNull); ok && (variable.defValue != nil || locationDefault) {
+		return actual == nonNull.OfType.String()
+	}
+	return false
+}
+
+func namedType(t Type) Type {
+	for {
+		switch wrapped := t.(type) {
+		case *List:
+			t = wrapped.OfType
+		case *NonNull:
+			t = wrapped.OfType
+		default:
+			return t
+		}
+	}
+}
+
+func (s *Schema) coerceVariables(op *operation, values map[string]interface{}) (map[string]interface{}, []*Error) {
+	coerced := map[string]interface{}{}
+	var errs []*Error
+	for _, definition := range op.variables {
+		t, _ := s.inputType(definition.typ)
+		raw, provided := values[definition.name]
+		switch {
+		case !provided && definition.defValue != nil:
+			value, _ := coerceLiteral(t, definition.defValue, nil)
+			coerced[definition.name] = value
+		case !provided:
+			if _, required := t.(*NonNull); required {
+				errs = append(errs, &Error{
+					Message:   fmt.Sprintf("Variable \"$%s\" of required type %q was not provided.", definition.name, t),
+					Locations: []Location{definition.loc},
+				})
+			}
+		default:
+			value, err := coerceInput(t, raw)
+			if err != nil {
+				errs = append(errs, &Error{
+					Message:   fmt.Sprintf("Variable \"$%s\" got invalid value %s; %s", definition.name, printJSON(raw), err),
+					Locations: []Location{definition.loc},
+				})
+				continue
+			}
+			coerced[definition.name] = value
+		}
+	}
+	return coerced, errs
+}
+
+// coerceInput converts a variable value decoded from JSON.
+func coerceInput(t Type, raw interface{}) (interface{}, error) {
+	switch t := t.(type) {
+	case *NonNull:
+		if raw == nil {
+			return nil, fmt.Errorf("expected non-nullable type %q not to be null", t)
+		}
+		return coerceInput(t.OfType, raw)
+	case *List:
+		if raw == nil {
+			return nil, nil
+		}
+		items, ok := raw.([]interface{})
+		if !ok {
+			item, err := coerceInput(t.OfType, raw)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		coerced := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			value, err := coerceInput(t.OfType, item)
+			if err != nil {
+				return nil, err
+			}
+			coerced = append(coerced, value)
+		}
+		return coerced, nil
+	case *Scalar:
+		if raw == nil {
+			return nil, nil
+		}
+		switch t {
+		case Int:
+			if number, ok := toFloat(raw); ok && number == math.Trunc(number) && number >= math.MinInt32 && number <= math.MaxInt32 {
+				return int(number), nil
+			}
+		case Float:
+			if number, ok := toFloat(raw); ok {
+				return number, nil
+			}
+		case String:
+			if s, ok := raw.(string); ok {
+				return s, nil
+			}
+		case Boolean:
+			if b, ok := raw.(bool); ok {
+				return b, nil
+			}
+		case ID:
+			switch id := raw.(type) {
+			case string:
+				return id, nil
+			default:
+				if number, ok := toFloat(raw); ok && number == math.Trunc(number) {
+					return strconv.FormatFloat(number, 'f', -1, 64), nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("%s cannot represent %s", t.Name, printJSON(raw))
+	}
+	return nil, fmt.Errorf("%q is not an input type", t)
+}
+
+// toFloat accepts the numbers of decoded JSON and of variables built in Go.
+func toFloat(raw interface{}) (float64, bool) {
+	switch number := raw.(type) {
+	case float64:
+		return number, true
+	case float32:
+		return float64(number), true
+	case int:
+		return float64(number), true
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case json.Number:
+		f, err := number.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// coerceLiteral converts a literal. Variables are looked up in variables; with nil
+// variables, as during validation, they are accepted as they are.
+func coerceLiteral(t Type, val *value, variables map[string]interface{}) (interface{}, error) {
+	if val.kind == valueVariable {
+		if variables == nil {
+			return nil, nil
+		}
+		value := variables[val.raw]
+		if _, required := t.(*NonNull); required && value == nil {
+			return nil, fmt.Errorf("expected non-nullable type %q not to be null", t)
+		}
+		return value, nil
+	}
+	switch t := t.(type) {
+	case *NonNull:
+		if val.kind == valueNull {
+			return nil, fmt.Errorf("expected non-nullable type %q not to be null", t)
+		}
+		return coerceLiteral(t.OfType, val, variables)
+	case *List:
+		if val.kind == valueNull {
+			return nil, nil
+		}
+		if val.kind != valueList {
+			item, err := coerceLiteral(t.OfType, val, variables)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		coerced := make([]interface{}, 0, len(val.list))
+		for _, item := range val.list {
+			value, err := coerceLiteral(t.OfType, item, variables)
+			if err != nil {
+				return nil, err
+			}
+			coerced = append(coerced, value)
+		}
+		return coerced, nil
+	case *Scalar:
+		if val.kind == valueNull {
+			return nil, nil
+		}
+		switch {
+		case t == Int && val.kind == valueInt:
+			if n, err := strconv.ParseInt(val.raw, 10, 32); err == nil {
+				return int(n), nil
+			}
+		case t == Float && (val.kind == valueInt || val.kind == valueFloat):
+			if n, err := strconv.ParseFloat(val.raw, 64); err == nil {
+				return n, nil
+			}
+		case t == String && val.kind == valueString:
+			return val.raw, nil
+		case t == Boolean && val.kind == valueBoolean:
+			return val.raw == "true", nil
+		case t == ID && (val.kind == valueString || val.kind == valueInt):
+			return val.raw, nil
+		}
+		return nil, fmt.Errorf("%s cannot represent %s", t.Name, printValue(val))
+	}
+	return nil, fmt.Errorf("%q is not an input type", t)
+}
+
+func printJSON(v interface{}) string {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(encoded)
+}
+
+func printValue(val *value) string {
+	switch val.kind {
+	case valueVariable:
+		return "$" + val.raw
+	case valueString:
+		return strconv.Quote(val.raw)
+	case valueList:
+		var b bytes.Buffer
+		b.WriteByte('[')
+		for i, item := range val.list {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(printValue(item))
+		}
+		b.WriteByte(']')
+		return b.String()
+	case valueObject:
+		var b bytes.Buffer
+		b.WriteByte('{')
+		for i, field := range val.fields {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(field.name + ": " + printValue(field.value))
+		}
+		b.WriteByte('}')
+		return b.String()
+	default:
+		return val.raw
+	}
+}
+
+func printArguments(arguments []*argument) string {
+	var b bytes.Buffer
+	for _, arg := range arguments {
+		b.WriteString(arg.name + ":" + printValue(arg.value) + ",")
+	}
+	return b.String()
+}
+
+// orderedMap is a JSON object keeping the order of the selected fields.
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		b.Write(name)
+		b.WriteByte(':')
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+type executor struct {
+	schema    *Schema
+	doc       *document
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+func (e *executor) fieldError(f *field, path []interface{}, message string) {
+	e.errors = append(e.errors, &Error{
+		Message:   message,
+		Locations: []Location{f.loc},
+		Path:      append([]interface{}(nil), path...),
+	})
+}
+
+// executeSelections resolves the selections on source. The result is nil when a
+// non-null field failed, so the object itself becomes null.
+func (e *executor) executeSelections(ctx context.Context, object *Object, source interface{}, selections []selection, path []interface{}) (*orderedMap, bool) {
+	grouped := &fieldGroups{fields: map[string][]*field{}}
+	e.collectFields(object, selections, grouped, map[string]bool{})
+	result := &orderedMap{values: map[string]interface{}{}}
+	for _, key := range grouped.keys {
+		fields := grouped.fields[key]
+		fieldPath := append(path[:len(path):len(path)], key)
+		if fields[0].name == "__typename" {
+			result.set(key, object.Name)
+			continue
+		}
+		definition := object.Fields[fields[0].name]
+		value, _ := e.executeField(ctx, definition, source, fields, fieldPath)
+		if _, required := definition.Type.(*NonNull); required && value == nil {
+			return nil, true
+		}
+		result.set(key, value)
+	}
+	return result, false
+}
+
+type fieldGroups struct {
+	keys   []string
+	fields map[string][]*field
+}
+
+// collectFields groups the fields to resolve by response key, expanding fragments and
+// dropping selections excluded by @skip or @include.
+func (e *executor) collectFields(object *Object, selections []selection, groups *fieldGroups, visited map[string]bool) {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			if !e.included(sel.directives) {
+				continue
+			}
+			key := sel.responseKey()
+			if _, ok := groups.fields[key]; !ok {
+				groups.keys = append(groups.keys, key)
+			}
+			groups.fields[key] = append(groups.fields[key], sel)
+		case *inlineFragment:
+			if e.included(sel.directives) {
+				e.collectFields(object, sel.selections, groups, visited)
+			}
+		case *fragmentSpread:
+			if visited[sel.name] || !e.included(sel.directives) {
+				continue
+			}
+			visited[sel.name] = true
+			e.collectFields(object, e.doc.fragments[sel.name].selections, groups, visited)
+		}
+	}
+}
+
+func (e *executor) included(directives []*directive) bool {
+	for _, d := range directives {
+		condition, _ := coerceLiteral(NewNonNull(Boolean), d.arguments[0].value, e.variables)
+		if (d.name == "skip") == (condition == true) {
+			return false
+		}
+	}
+	return true
+}
+
+// executeField resolves a field and completes its value. The second result reports
+// whether the value is null because of an error that was already recorded.
+func (e *executor) executeField(ctx context.Context, definition *Field, source interface{}, fields []*field, path []interface{}) (interface{}, bool) {
+	f := fields[0]
+	args := map[string]interface{}{}
+	for _, arg := range definition.Args {
+		if given := findGiven(f.arguments, arg.Name); given != nil {
+			if given.value.kind == valueVariable {
+				if _, ok := e.variables[given.value.raw]; !ok {
+					if arg.DefaultValue != nil {
+						args[arg.Name] = arg.DefaultValue
+					}
+					continue
+				}
+			}
+			value, err := coerceLiteral(arg.Type, given.value, e.variables)
+			if err != nil {
+				e.fieldError(f, path, fmt.Sprintf("Argument %q has invalid value: %s", arg.Name, err))
+				return nil, true
+			}
+			args[arg.Name] = value
+		} else if arg.DefaultValue != nil {
+			args[arg.Name] = arg.DefaultValue
+		}
+	}
+
+	resolved, err := resolve(definition, f.name, ResolveParams{Context: ctx, Source: source, Args: args})
+	if err != nil {
+		e.fieldError(f, path, err.Error())
+		return nil, true
+	}
+	return e.completeValue(ctx, definition.Type, fields, resolved, path)
+}
+
+func findGiven(arguments []*argument, name string) *argument {
+	for _, arg := range arguments {
+		if arg.name == name {
+			return arg
+		}
+	}
+	return nil
+}
+
+func resolve(definition *Field, name string, params ResolveParams) (value interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			value, err = nil, fmt.Errorf("internal error")
+		}
+	}()
+	if definition.Resolve != nil {
+		return definition.Resolve(params)
+	}
+	if source, ok := params.Source.(map[string]interface{}); ok {
+		return source[name], nil
+	}
+	return nil, nil
+}
+
+// completeValue shapes a resolved value according to its type. The second result
+// reports whether a nil value comes from an error that was already recorded.
+func (e *executor) completeValue(ctx context.Context, t Type, fields []*field, resolved interface{}, path []interface{}) (interface{}, bool) {
+	if nonNull, ok := t.(*NonNull); ok {
+		value, failed := e.completeValue(ctx, nonNull.OfType, fields, resolved, path)
+		if value == nil && !failed {
+			e.fieldError(fields[0], path, fmt.Sprintf("Cannot return null for non-nullable field %s.", fields[0].name))
+		}
+		return value, value == nil
+	}
+	if isNull(resolved) {
+		return nil, false
+	}
+	switch t := t.(type) {
+	case *List:
+		items := reflect.ValueOf(resolved)
+		if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+			e.fieldError(fields[0], path, fmt.Sprintf("Expected a list for field %s.", fields[0].name))
+			return nil, true
+		}
+		_, itemRequired := t.OfType.(*NonNull)
+		completed := make([]interface{}, items.Len())
+		for i := range completed {
+			value, failed := e.completeValue(ctx, t.OfType, fields, items.Index(i).Interface(), append(path[:len(path):len(path)], i))
+			if itemRequired && value == nil {
+				return nil, failed
+			}
+			completed[i] = value
+		}
+		return completed, false
+	case *Object:
+		var selections []selection
+		for _, f := range fields {
+			selections = append(selections, f.selections...)
+		}
+		object, failed := e.executeSelections(ctx, t, resolved, selections, path)
+		if object == nil {
+			return nil, failed
+		}
+		return object, false
+	default:
+		return resolved, false
+	}
+}
+
+func isNull(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type book struct {
+	Title  string
+	Pages  int
+	Author *author
+}
+
+type author struct {
+	Name string
+}
+
+func testSchema(t *testing.T, opts Options) *Schema {
+	t.Helper()
+	authorType := &Object{Name: "Author", Fields: Fields{
+		"name": {Type: NewNonNull(String), Resolve: func(p ResolveParams) (interface{}, error) {
+			return p.Source.(*author).Name, nil
+		}},
+	}}
+	bookType := &Object{Name: "Book", Fields: Fields{
+		"title": {Type: NewNonNull(String), Resolve: func(p ResolveParams) (interface{}, error) {
+			return p.Source.(*book).Title, nil
+		}},
+		"pages": {Type: Int, Resolve: func(p ResolveParams) (interface{}, error) {
+			return p.Source.(*book).Pages, nil
+		}},
+		"author": {Type: NewNonNull(authorType), Resolve: func(p ResolveParams) (interface{}, error) {
+			return p.Source.(*book).Author, nil
+		}},
+	}}
+	books := []*book{
+		{Title: "Dune", Pages: 412, Author: &author{Name: "Herbert"}},
+		{Title: "Emma", Pages: 474, Author: &author{Name: "Austen"}},
+		{Title: "Anonymous"},
+	}
+	query := &Object{Name: "Query", Fields: Fields{
+		"books": {
+			Type: NewNonNull(NewList(NewNonNull(bookType))),
+			Args: []*Argument{{Name: "first", Type: Int, DefaultValue: 2}, {Name: "titles", Type: NewList(NewNonNull(String))}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				if titles, ok := p.Args["titles"].([]interface{}); ok {
+					var selected []*book
+					for _, b := range books {
+						for _, title := range titles {
+							if b.Title == title {
+								selected = append(selected, b)
+							}
+						}
+					}
+					return selected, nil
+				}
+				return books[:p.Args["first"].(int)], nil
+			},
+		},
+		"book": {
+			Type: bookType,
+			Args: []*Argument{{Name: "title", Type: NewNonNull(String)}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				for _, b := range books {
+					if b.Title == p.Args["title"] {
+						return b, nil
+					}
+				}
+				return nil, errors.New("book not found")
+			},
+		},
+		"stats": {Type: NewNonNull(&Object{Name: "Stats", Fields: Fields{"count": {Type: NewNonNull(Int)}}}), Resolve: func(p ResolveParams) (interface{}, error) {
+			return map[string]interface{}{"count": len(books)}, nil
+		}},
+	}}
+	schema, err := NewSchemaWithOptions(query, opts)
+	if err != nil {
+		t.Fatalf("NewSchema returned error: %v", err)
+	}
+	return schema
+}
+
+func run(t *testing.T, schema *Schema, req Request) string {
+	t.Helper()
+	encoded, err := json.Marshal(schema.Execute(context.Background(), req))
+	if err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	return string(encoded)
+}
+
+func TestExecuteSelectsRequestedFieldsInOrder(t *testing.T) {
+	schema := testSchema(t, DefaultOptions())
+	got := run(t, schema, Request{
+		Query: `
+			query Library($first: Int, $skipPages: Boolean!) {
+				all: books(first: $first) { ...details author { name } }
+				stats { count __typename }
+				single: book(title: "Emma") { ... on Book { title } pages @skip(if: $skipPages) }
+			}
+			fragment details on Book { title pages @include(if: true) }`,
+		Variables: map[string]interface{}{"first": 1, "skipPages": true},
+	})
+	want := `{"data":{"all":[{"title":"Dune","pages":412,"author":{"name":"Herbert"}}],"stats":{"count":3,"__typename":"Stats"},"single":{"title":"Emma"}}}`
+	if got != want {
+		t.Fatalf("unexpected response\n got %s\nwant %s", got, want)
+	}
+
+	got = run(t, schema, Request{Query: `{ books(titles: "Emma") { title } }`})
+	if want := `{"data":{"books":[{"title":"Emma"}]}}`; got != want {
+		t.Fatalf("expected a single value to be coerced to a list, got %s", got)
+	}
+}
+
+func TestExecuteReportsFieldErrorsWithPartialData(t *testing.T) {
+	schema := testSchema(t, DefaultOptions())
+	got := run(t, schema, Request{Query: `{ book(title: "Missing") { title } stats { count } }`})
+	want := `{"errors":[{"message":"book not found","locations":[{"line":1,"column":3}],"path":["book"]}],"data":{"book":null,"stats":{"count":3}}}`
+	if got != want {
+		t.Fatalf("unexpected response\n got %s\nwant %s", got, want)
+	}
+
+	// The third book has no author, so the non-null list item and then the non-null
+	// list fail, nulling the whole data once with a single error.
+	got = run(t, schema, Request{Query: `{ books(first: 3) { author { name } } }`})
+	want = `{"errors":[{"message":"Cannot return null for non-nullable field author.","locations":[{"line":1,"column":21}],"path":["books",2,"author"]}],"data":null}`
+	if got != want {
+		t.Fatalf("unexpected response\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestExecuteRejectsInvalidRequestsWithoutData(t *testing.T) {
+	schema := testSchema(t, Options{MaxFields: 4})
+	for _, tc := range []struct {
+		name    string
+		req     Request
+		message string
+	}{
+		{"syntax", Request{Query: `{ books { title }`}, `Syntax Error: expected name, found <EOF>`},
+		{"unknown field", Request{Query: `{ books { isbn } }`}, `Cannot query field "isbn" on type "Book".`},
+		{"missing argument", Request{Query: `{ book { title } }`}, `Argument "title" of type "String!" is required, but it was not provided.`},
+		{"bad literal", Request{Query: `{ books(first: "two") { title } }`}, `Argument "first" has invalid value "two": Int cannot represent "two"`},
+		{"leaf selection", Request{Query: `{ books }`}, `Field "books" of type "[Book!]!" must have a selection of subfields.`},
+		{"conflict", Request{Query: `{ books { title: pages title } }`}, `Fields "title" conflict because "pages" and "title" are different fields.`},
+		{"fragment cycle", Request{Query: `{ books { ...a } } fragment a on Book { author { name } ...a }`}, `Cannot spread fragment "a" within itself.`},
+		{"undefined variable", Request{Query: `{ books(first: $n) { title } }`}, `Variable "$n" is not defined.`},
+		{"variable type", Request{Query: `query($t: Int) { book(title: $t) { title } }`}, `Variable "$t" of type "Int" used in position expecting type "String!".`},
+		{"variable value", Request{Query: `query($n: Int) { books(first: $n) { title } }`, Variables: map[string]interface{}{"n": 1.5}}, `Variable "$n" got invalid value 1.5; Int cannot represent 1.5`},
+		{"mutation", Request{Query: `mutation { books { title } }`}, `mutation operations are not supported.`},
+		{"operation name", Request{Query: `query a { stats { count } } query b { stats { count } }`}, `Must provide operation name if query contains multiple operations.`},
+		{"too many fields", Request{Query: `{ books { title pages author { name } } }`}, `The query selects 5 fields, more than the limit of 4.`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			response := schema.Execute(context.Background(), tc.req)
+			if response.Executed() || len(response.Errors) == 0 || response.Errors[0].Message != tc.message {
+				t.Fatalf("unexpected response %s", run(t, schema, tc.req))
+			}
+			if got := run(t, schema, tc.req); strings.Contains(got, `"data"`) {
+				t.Fatalf("expected no data for an invalid request, got %s", got)
+			}
+		})
+	}
+}
+
+func TestSchemaString(t *testing.T) {
+	got := testSchema(t, DefaultOptions()).String()
+	for _, want := range []string{
+		"type Query {\n  book(title: String!): Book\n  books(first: Int = 2, titles: [String!]): [Book!]!\n  stats: Stats!\n}\n",
+		"type Author {\n  name: String!\n}\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in\n%s", want, got)
+		}
+	}
+}
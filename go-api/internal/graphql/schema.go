@@ -0,0 +1,258 @@
+// Package graphql executes GraphQL queries against a schema declared in Go. It
+// implements the query side of the October 2021 specification that the API needs:
+// variables, aliases, arguments, named and inline fragments, @skip and @include, and
+// __typename. Mutations, subscriptions, interfaces, unions, input objects and
+// introspection are not supported; Schema.String prints the schema instead.
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is a GraphQL type: a *Scalar, *Object, *List or *NonNull.
+type Type interface {
+	String() string
+	isType()
+}
+
+// Scalar is a leaf type. Resolvers return values of the matching Go type, which are
+// encoded as they are.
+type Scalar struct {
+	Name string
+}
+
+// The built-in scalars. Arguments are coerced to int, float64, string, bool and
+// string respectively.
+var (
+	Int     = &Scalar{Name: "Int"}
+	Float   = &Scalar{Name: "Float"}
+	String  = &Scalar{Name: "String"}
+	Boolean = &Scalar{Name: "Boolean"}
+	ID      = &Scalar{Name: "ID"}
+)
+
+// Object is an object type. Fields may be added after the object is created, so an
+// object can refer to itself.
+type Object struct {
+	Name        string
+	Description string
+	Fields      Fields
+}
+
+// Fields maps field names to their definitions.
+type Fields map[string]*Field
+
+// Field defines a field of an object.
+type Field struct {
+	Description string
+	Type        Type
+	Args        []*Argument
+	// Resolve computes the field. When nil, the field is looked up in a
+	// map[string]interface{} source.
+	Resolve ResolveFunc
+}
+
+// Argument defines an argument of a field. Argument types are scalars or lists of
+// scalars, possibly non-null.
+type Argument struct {
+	Name         string
+	Type         Type
+	DefaultValue interface{}
+}
+
+// List is a list of another type.
+type List struct {
+	OfType Type
+}
+
+// NonNull is a type that is never null.
+type NonNull struct {
+	OfType Type
+}
+
+// NewList returns the list type of t.
+func NewList(t Type) *List { return &List{OfType: t} }
+
+// NewNonNull returns the non-null type of t.
+func NewNonNull(t Type) *NonNull { return &NonNull{OfType: t} }
+
+func (t *Scalar) String() string  { return t.Name }
+func (t *Object) String() string  { return t.Name }
+func (t *List) String() string    { return "[" + t.OfType.String() + "]" }
+func (t *NonNull) String() string { return t.OfType.String() + "!" }
+
+func (*Scalar) isType()  {}
+func (*Object) isType()  {}
+func (*List) isType()    {}
+func (*NonNull) isType() {}
+
+// ResolveParams are passed to resolvers.
+type ResolveParams struct {
+	Context context.Context
+	// Source is the value the parent field resolved to; nil for root fields.
+	Source interface{}
+	// Args holds the coerced arguments, including defaults. Arguments that were
+	// omitted and have no default are absent.
+	Args map[string]interface{}
+}
+
+// ResolveFunc computes the value of a field. A returned error is reported in the
+// response with the field's path and the field becomes null, so its message must be
+// safe to show to clients.
+type ResolveFunc func(p ResolveParams) (interface{}, error)
+
+// Options tunes query execution.
+type Options struct {
+	// MaxFields rejects queries selecting more fields, counting every field of an
+	// expanded fragment. It bounds the work one request can cause. Zero disables it.
+	MaxFields int
+}
+
+// DefaultOptions returns the limits used by NewSchema.
+func DefaultOptions() Options {
+	return Options{MaxFields: 500}
+}
+
+// Schema is an executable schema.
+type Schema struct {
+	query *Object
+	types map[string]Type
+	opts  Options
+}
+
+// NewSchema returns a schema whose root query type is query.
+func NewSchema(query *Object) (*Schema, error) {
+	return NewSchemaWithOptions(query, DefaultOptions())
+}
+
+// NewSchemaWithOptions returns a schema using explicit limits. It fails when two
+// types share a name or a field has no type.
+func NewSchemaWithOptions(query *Object, opts Options) (*Schema, error) {
+	s := &Schema{query: query, opts: opts, types: map[string]Type{}}
+	for _, scalar := range []*Scalar{Int, Float, String, Boolean, ID} {
+		s.types[scalar.Name] = scalar
+	}
+	if err := s.addType(query); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schema) addType(t Type) error {
+	switch t := t.(type) {
+	case *List:
+		return s.addType(t.OfType)
+	case *NonNull:
+		return s.addType(t.OfType)
+	}
+	name := t.String()
+	if existing, ok := s.types[name]; ok {
+		if existing != t {
+			return fmt.Errorf("graphql: two types are named %q", name)
+		}
+		return nil
+	}
+	s.types[name] = t
+	object, ok := t.(*Object)
+	if !ok {
+		return nil
+	}
+	for fieldName, field := range object.Fields {
+		if field == nil || field.Type == nil {
+			return fmt.Errorf("graphql: field %s.%s has no type", name, fieldName)
+		}
+		if err := s.addType(field.Type); err != nil {
+			return err
+		}
+		for _, arg := range field.Args {
+			if !isInputType(arg.Type) {
+				return fmt.Errorf("graphql: argument %s.%s(%s) must be a scalar type", name, fieldName, arg.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func isInputType(t Type) bool {
+	switch t := t.(type) {
+	case *Scalar:
+		return true
+	case *List:
+		return isInputType(t.OfType)
+	case *NonNull:
+		return isInputType(t.OfType)
+	}
+	return false
+}
+
+// String prints the schema in the GraphQL schema definition language, the query
+// type first and fields in alphabetical order.
+func (s *Schema) String() string {
+	var objects []*Object
+	for _, t := range s.types {
+		if object, ok := t.(*Object); ok && object != s.query {
+			objects = append(objects, object)
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	objects = append([]*Object{s.query}, objects...)
+
+	var b strings.Builder
+	for i, object := range objects {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if object.Description != "" {
+			fmt.Fprintf(&b, "%s\n", strconv.Quote(object.Description))
+		}
+		fmt.Fprintf(&b, "type %s {\n", object.Name)
+		names := make([]string, 0, len(object.Fields))
+		for name := range object.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			field := object.Fields[name]
+			if field.Description != "" {
+				fmt.Fprintf(&b, "  %s\n", strconv.Quote(field.Description))
+			}
+			fmt.Fprintf(&b, "  %s", name)
+			if len(field.Args) > 0 {
+				args := make([]string, 0, len(field.Args))
+				for _, arg := range field.Args {
+					definition := arg.Name + ": " + arg.Type.String()
+					if arg.DefaultValue != nil {
+						definition += " = " + literal(arg.DefaultValue)
+					}
+					args = append(args, definition)
+				}
+				fmt.Fprintf(&b, "(%s)", strings.Join(args, ", "))
+			}
+			fmt.Fprintf(&b, ": %s\n", field.Type)
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// literal prints an argument default as a GraphQL value.
+func literal(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, literal(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
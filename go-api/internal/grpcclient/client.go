@@ -2,52 +2,290 @@ package grpcclient
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/health" // client-side health checking
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
 
 	"github.com/example/ai-check/internal/imageprocessor"
 	"github.com/example/ai-check/internal/logging"
 	proto "github.com/example/ai-check/proto"
 )
 
+// DialOptions controls how the connection to the image processor is established.
+type DialOptions struct {
+	// Block waits until the connection is ready, up to Timeout. Without it the
+	// connection is established in the background and calls fail until it is up.
+	Block   bool
+	Timeout time.Duration
+	// UnaryInterceptors and StreamInterceptors wrap every call, e.g. to record
+	// metrics.
+	UnaryInterceptors  []grpc.UnaryClientInterceptor
+	StreamInterceptors []grpc.StreamClientInterceptor
+	// HealthCheck makes the connection to each replica watch its gRPC health
+	// service, so calls skip replicas reporting NOT_SERVING until they recover.
+	// Replicas without the health service count as healthy.
+	HealthCheck bool
+	// HealthService is the service the health checks ask about; empty asks about
+	// the server as a whole.
+	HealthService string
+	// Calls bounds, retries and hedges the calls made through the client.
+	Calls CallPolicy
+}
+
+// DefaultDialOptions returns the options used by DialImageProcessor.
+func DefaultDialOptions() DialOptions {
+	return DialOptions{Block: true, Timeout: 5 * time.Second, HealthCheck: true}
+}
+
+// poolScheme is the resolver scheme of comma-separated address lists.
+const poolScheme = "processor-pool"
+
 // DialImageProcessor returns a ready-to-use gRPC client for the Rust service.
+//
+// addr is a host:port, a gRPC target such as dns:///host:port, or a comma-separated
+// list of host:port addresses. The client keeps a connection to every replica the
+// target resolves to and spreads calls round-robin over those that are ready.
+// Calls skip replicas whose connection failed, while gRPC reconnects to them in the
+// background with backoff; a dns:/// target is resolved again when one fails.
 func DialImageProcessor(ctx context.Context, addr string, logger *zap.Logger) (imageprocessor.Client, *grpc.ClientConn, error) {
-	dialCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+	return DialImageProcessorWithOptions(ctx, addr, logger, DefaultDialOptions())
+}
 
-	conn, err := grpc.DialContext(
-		dialCtx,
-		addr,
+// DialImageProcessorWithOptions returns a gRPC client for the Rust service using explicit dial options.
+func DialImageProcessorWithOptions(ctx context.Context, addr string, logger *zap.Logger, opts DialOptions) (imageprocessor.Client, *grpc.ClientConn, error) {
+	target, resolverOpts := poolTarget(addr)
+	serviceConfig, err := json.Marshal(serviceConfig(opts))
+	if err != nil {
+		return nil, nil, err
+	}
+	dialOpts := []grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
-	)
+		grpc.WithDefaultServiceConfig(string(serviceConfig)),
+	}
+	dialOpts = append(dialOpts, resolverOpts...)
+	if opts.Block {
+		dialOpts = append(dialOpts, grpc.WithBlock())
+	}
+	if len(opts.UnaryInterceptors) > 0 {
+		dialOpts = append(dialOpts, grpc.WithChainUnaryInterceptor(opts.UnaryInterceptors...))
+	}
+	if len(opts.StreamInterceptors) > 0 {
+		dialOpts = append(dialOpts, grpc.WithChainStreamInterceptor(opts.StreamInterceptors...))
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	conn, err := grpc.DialContext(ctx, target, dialOpts...)
 	if err != nil {
 		wrapped := logging.NewOperationError("grpcclient.dial_image_processor", "", err)
 		logger.Error("failed to dial image processor", zap.Error(wrapped), zap.String("addr", addr))
 		return nil, nil, wrapped
 	}
 	client := proto.NewImageProcessorClient(conn)
-	return &grpcImageProcessor{client: client, logger: logger}, conn, nil
+	return &grpcImageProcessor{client: client, policy: opts.Calls, logger: logger}, conn, nil
+}
+
+// poolTarget returns the dial target of addr, with the resolver it needs when addr
+// lists several addresses.
+func poolTarget(addr string) (string, []grpc.DialOption) {
+	if !strings.Contains(addr, ",") {
+		return addr, nil
+	}
+	var addresses []resolver.Address
+	for _, part := range strings.Split(addr, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			addresses = append(addresses, resolver.Address{Addr: part})
+		}
+	}
+	builder := manual.NewBuilderWithScheme(poolScheme)
+	builder.InitialState(resolver.State{Addresses: addresses})
+	return poolScheme + ":///processor", []grpc.DialOption{grpc.WithResolvers(builder)}
+}
+
+// serviceConfig balances calls round-robin over the ready connections, and turns
+// on the health checks of opts.
+func serviceConfig(opts DialOptions) map[string]interface{} {
+	config := map[string]interface{}{
+		"loadBalancingConfig": []map[string]interface{}{{"round_robin": map[string]interface{}{}}},
+	}
+	if opts.HealthCheck {
+		config["healthCheckConfig"] = map[string]interface{}{"serviceName": opts.HealthService}
+	}
+	return config
 }
 
 type grpcImageProcessor struct {
 	client proto.ImageProcessorClient
+	policy CallPolicy
 	logger *zap.Logger
 }
 
 func (g *grpcImageProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
-	resp, err := g.client.ProcessImage(ctx, &proto.VerifyRequest{UserId: userID, ImageData: imageBytes})
+	ctx = withRequestID(ctx)
+	resp, err := g.call(ctx, "grpcclient.process_image", userID, func(ctx context.Context) (*proto.VerifyResponse, error) {
+		return g.client.ProcessImage(ctx, &proto.VerifyRequest{UserId: userID, ImageData: imageBytes})
+	}, nil)
 	if err != nil {
 		wrapped := logging.NewOperationError("grpcclient.process_image", userID, err)
-		g.logger.Error("image processor call failed", zap.Error(wrapped), zap.String("user_id", userID))
+		logging.FromContext(ctx, g.logger).Error("image processor call failed", zap.Error(wrapped), zap.String("user_id", userID))
 		return nil, wrapped
 	}
+	return result(resp), nil
+}
+
+// streamChunkSize is the size of the chunks ProcessStream sends.
+const streamChunkSize = 64 << 10
+
+// ProcessStream implements imageprocessor.StreamClient. Failures to read image are
+// returned as *imageprocessor.ReadError and abort the call.
+//
+// A failed call is only retried when none of image was read yet, or when image can
+// seek back to where it started. Hedged calls send the image twice at once, so with
+// hedging on the image is read whole and sent with Process.
+func (g *grpcImageProcessor) ProcessStream(ctx context.Context, userID string, image io.Reader) (*imageprocessor.Result, error) {
+	if g.policy.HedgeDelay > 0 {
+		imageBytes, err := io.ReadAll(image)
+		if err != nil {
+			return nil, logging.NewOperationError("grpcclient.process_image_stream", userID, &imageprocessor.ReadError{Err: err})
+		}
+		return g.Process(ctx, userID, imageBytes)
+	}
+	ctx = withRequestID(ctx)
+	counted := &countingReader{r: image}
+	seeker, _ := image.(io.Seeker)
+	start := int64(-1)
+	if seeker != nil {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			start = offset
+		}
+	}
+	rewind := func() bool {
+		if counted.n == 0 {
+			return true
+		}
+		if start < 0 {
+			return false
+		}
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return false
+		}
+		counted.n = 0
+		return true
+	}
+
+	resp, err := g.call(ctx, "grpcclient.process_image_stream", userID, func(ctx context.Context) (*proto.VerifyResponse, error) {
+		return g.stream(ctx, userID, counted)
+	}, rewind)
+	var readErr *imageprocessor.ReadError
+	if errors.As(err, &readErr) {
+		return nil, logging.NewOperationError("grpcclient.process_image_stream", userID, err)
+	}
+	if err != nil {
+		wrapped := logging.NewOperationError("grpcclient.process_image_stream", userID, err)
+		logging.FromContext(ctx, g.logger).Error("image processor call failed", zap.Error(wrapped), zap.String("user_id", userID))
+		return nil, wrapped
+	}
+	return result(resp), nil
+}
+
+// stream sends image to the processor in chunks and returns its answer.
+func (g *grpcImageProcessor) stream(ctx context.Context, userID string, image io.Reader) (*proto.VerifyResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := g.client.ProcessImageStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	chunk := &proto.VerifyChunk{UserId: userID}
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, readErr := io.ReadFull(image, buf)
+		if n > 0 {
+			chunk.Data = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				// io.EOF means the server ended the call; its status follows.
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, err
+			}
+			chunk = &proto.VerifyChunk{}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			// Cancelling the call tells the processor to drop the partial image.
+			return nil, &imageprocessor.ReadError{Err: readErr}
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// countingReader counts the bytes read from r, so a call can tell whether it
+// already consumed part of the image.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// requestIDMetadata is the metadata key carrying the X-Request-ID of the request a
+// call is made for, so the processor can log it too.
+const requestIDMetadata = "x-request-id"
+
+func withRequestID(ctx context.Context) context.Context {
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		return metadata.AppendToOutgoingContext(ctx, requestIDMetadata, requestID)
+	}
+	return ctx
+}
+
+func result(resp *proto.VerifyResponse) *imageprocessor.Result {
+	categories := make([]imageprocessor.CategoryScore, 0, len(resp.GetCategories()))
+	for _, category := range resp.GetCategories() {
+		categories = append(categories, imageprocessor.CategoryScore{Category: category.GetCategory(), Score: category.GetScore()})
+	}
 	return &imageprocessor.Result{
-		Success: resp.GetSuccess(),
-		Score:   resp.GetScore(),
-		Message: resp.GetMessage(),
-	}, nil
+		Success:      resp.GetSuccess(),
+		Score:        resp.GetScore(),
+		Message:      resp.GetMessage(),
+		Categories:   categories,
+		ModelVersion: resp.GetModelVersion(),
+	}
+}
+
+// ReadinessCheck reports whether conn can currently carry calls, i.e. whether at
+// least one replica is ready. An idle connection counts as ready; a failing one is
+// nudged to reconnect.
+func ReadinessCheck(conn *grpc.ClientConn) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		switch state := conn.GetState(); state {
+		case connectivity.Ready, connectivity.Idle:
+			return nil
+		default:
+			conn.Connect()
+			return fmt.Errorf("connection is %s", state)
+		}
+	}
 }
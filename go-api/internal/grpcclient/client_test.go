@@ -0,0 +1,201 @@
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/example/ai-check/internal/imageprocessor"
+	proto "github.com/example/ai-check/proto"
+)
+
+type namedProcessor struct {
+	proto.UnimplementedImageProcessorServer
+	name string
+}
+
+func (p *namedProcessor) ProcessImage(ctx context.Context, req *proto.VerifyRequest) (*proto.VerifyResponse, error) {
+	return &proto.VerifyResponse{Success: true, Message: p.name}, nil
+}
+
+// startReplica serves a processor answering with name, and its health service.
+func startReplica(t *testing.T, name string) (string, *grpc.Server, *health.Server) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := grpc.NewServer()
+	proto.RegisterImageProcessorServer(server, &namedProcessor{name: name})
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String(), server, healthServer
+}
+
+// callsUntil processes images until the replicas answering the last few calls are
+// exactly want, or fails the test after a few seconds.
+func callsUntil(t *testing.T, processor imageprocessor.Client, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		seen := map[string]bool{}
+		for i := 0; i < 2*len(want)+2; i++ {
+			if result, err := processor.Process(context.Background(), "user-1", []byte("image")); err == nil {
+				seen[result.Message] = true
+			}
+		}
+		matched := len(seen) == len(want)
+		for _, name := range want {
+			matched = matched && seen[name]
+		}
+		if matched {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected calls to reach %v, got %v", want, seen)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestDialImageProcessorBalancesOverHealthyReplicas(t *testing.T) {
+	addrA, _, _ := startReplica(t, "a")
+	addrB, serverB, healthB := startReplica(t, "b")
+	healthB.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	processor, conn, err := DialImageProcessor(context.Background(), addrA+", "+addrB, zap.NewNop())
+	if err != nil {
+		t.Fatalf("DialImageProcessor returned error: %v", err)
+	}
+	defer conn.Close()
+
+	callsUntil(t, processor, "a")
+	healthB.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	callsUntil(t, processor, "a", "b")
+
+	// A replica that goes away is skipped while the pool reconnects to it.
+	serverB.Stop()
+	callsUntil(t, processor, "a")
+	if err := ReadinessCheck(conn)(context.Background()); err != nil {
+		t.Fatalf("expected the pool to stay ready with one replica, got %v", err)
+	}
+}
+
+// flakyProcessor fails its first failures calls with UNAVAILABLE and stalls its
+// first stalls calls until they are cancelled, then answers.
+type flakyProcessor struct {
+	proto.UnimplementedImageProcessorServer
+	mu       sync.Mutex
+	calls    int
+	failures int
+	stalls   int
+}
+
+func (p *flakyProcessor) answer(ctx context.Context, image []byte) (*proto.VerifyResponse, error) {
+	p.mu.Lock()
+	p.calls++
+	call := p.calls
+	p.mu.Unlock()
+	switch {
+	case call <= p.failures:
+		return nil, status.Error(codes.Unavailable, "warming up")
+	case call <= p.failures+p.stalls:
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &proto.VerifyResponse{Success: true, Message: string(image)}, nil
+}
+
+func (p *flakyProcessor) ProcessImage(ctx context.Context, req *proto.VerifyRequest) (*proto.VerifyResponse, error) {
+	return p.answer(ctx, req.GetImageData())
+}
+
+func (p *flakyProcessor) ProcessImageStream(stream proto.ImageProcessor_ProcessImageStreamServer) error {
+	var image []byte
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		image = append(image, chunk.GetData()...)
+	}
+	resp, err := p.answer(stream.Context(), image)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(resp)
+}
+
+func dialFlaky(t *testing.T, processor *flakyProcessor, policy CallPolicy) imageprocessor.StreamClient {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := grpc.NewServer()
+	proto.RegisterImageProcessorServer(server, processor)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	opts := DefaultDialOptions()
+	opts.Calls = policy
+	client, conn, err := DialImageProcessorWithOptions(context.Background(), listener.Addr().String(), zap.NewNop(), opts)
+	if err != nil {
+		t.Fatalf("DialImageProcessorWithOptions returned error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return client.(imageprocessor.StreamClient)
+}
+
+func TestProcessRetriesUnavailableCalls(t *testing.T) {
+	processor := &flakyProcessor{failures: 2}
+	client := dialFlaky(t, processor, CallPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	if result, err := client.Process(context.Background(), "user-1", []byte("image")); err != nil || result.Message != "image" || processor.calls != 3 {
+		t.Fatalf("expected the third attempt to answer, got %+v, %v after %d calls", result, err, processor.calls)
+	}
+
+	// A stream that can seek back to the image's start is sent again in full.
+	processor.calls = 0
+	if result, err := client.ProcessStream(context.Background(), "user-1", strings.NewReader("image")); err != nil || result.Message != "image" || processor.calls != 3 {
+		t.Fatalf("expected the stream to be retried, got %+v, %v after %d calls", result, err, processor.calls)
+	}
+
+	// One that cannot is not, once part of the image was read.
+	processor.calls = 0
+	_, err := client.ProcessStream(context.Background(), "user-1", io.MultiReader(strings.NewReader("image")))
+	if status.Code(err) != codes.Unavailable || processor.calls != 1 {
+		t.Fatalf("expected the unreplayable stream to fail once, got %v after %d calls", err, processor.calls)
+	}
+}
+
+func TestProcessBoundsAttemptsAndHedgesSlowCalls(t *testing.T) {
+	processor := &flakyProcessor{stalls: 1}
+	client := dialFlaky(t, processor, CallPolicy{Timeout: 50 * time.Millisecond, MaxAttempts: 2, InitialBackoff: time.Millisecond})
+	if result, err := client.Process(context.Background(), "user-1", []byte("image")); err != nil || result.Message != "image" || processor.calls != 2 {
+		t.Fatalf("expected the stalled attempt to time out and be retried, got %+v, %v after %d calls", result, err, processor.calls)
+	}
+
+	processor = &flakyProcessor{stalls: 1}
+	client = dialFlaky(t, processor, CallPolicy{HedgeDelay: 10 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if result, err := client.ProcessStream(ctx, "user-1", io.MultiReader(strings.NewReader("image"))); err != nil || result.Message != "image" || processor.calls != 2 {
+		t.Fatalf("expected the hedged copy to answer, got %+v, %v after %d calls", result, err, processor.calls)
+	}
+}
@@ -0,0 +1,118 @@
+package grpcclient
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/example/ai-check/internal/logging"
+	proto "github.com/example/ai-check/proto"
+)
+
+// CallPolicy bounds, retries and hedges the calls of the image processor client.
+// The zero value makes one attempt, bounded by the caller's context only.
+type CallPolicy struct {
+	// Timeout bounds each attempt, so a stuck replica fails the attempt rather than
+	// the whole verification; 0 leaves attempts bounded by the caller's context.
+	Timeout time.Duration
+	// MaxAttempts is how often a call failing with UNAVAILABLE or DEADLINE_EXCEEDED
+	// is tried, waiting InitialBackoff before the first retry and twice as long
+	// before each next one, up to MaxBackoff. 0 and 1 try once.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// HedgeDelay sends a second copy of an attempt still unanswered after it, which
+	// the round-robin balancer gives to another replica, and keeps the first answer.
+	// 0 disables hedging.
+	HedgeDelay time.Duration
+}
+
+// retryable reports whether err is worth another attempt: the replica was
+// unreachable or did not answer in time.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// attemptFunc makes one call to the processor.
+type attemptFunc func(ctx context.Context) (*proto.VerifyResponse, error)
+
+// call runs attempt under the policy until it succeeds, fails for good or ctx ends.
+// rewind is called before each retry and reports whether the call can be made
+// again; nil always allows it.
+func (g *grpcImageProcessor) call(ctx context.Context, operation, userID string, attempt attemptFunc, rewind func() bool) (*proto.VerifyResponse, error) {
+	backoff := g.policy.InitialBackoff
+	for try := 1; ; try++ {
+		resp, err := g.try(ctx, attempt)
+		if err == nil || try >= g.policy.MaxAttempts || !retryable(err) || ctx.Err() != nil {
+			return resp, err
+		}
+		if rewind != nil && !rewind() {
+			return resp, err
+		}
+		logging.WithOperation(logging.FromContext(ctx, g.logger), operation, userID).Warn("image processor call failed, retrying", zap.Error(err), zap.Int("attempt", try), zap.Duration("backoff", backoff))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		if backoff *= 2; g.policy.MaxBackoff > 0 && backoff > g.policy.MaxBackoff {
+			backoff = g.policy.MaxBackoff
+		}
+	}
+}
+
+// try makes one attempt, bounded by the policy's Timeout.
+func (g *grpcImageProcessor) try(ctx context.Context, attempt attemptFunc) (*proto.VerifyResponse, error) {
+	if g.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.policy.Timeout)
+		defer cancel()
+	}
+	if g.policy.HedgeDelay <= 0 {
+		return attempt(ctx)
+	}
+	return hedge(ctx, g.policy.HedgeDelay, attempt)
+}
+
+// hedge runs attempt and, when it has not answered after delay, a second copy of
+// it. The first success wins and cancels the other copy. A failure is returned once
+// no copy is left running, or at once when another attempt would not help.
+func hedge(ctx context.Context, delay time.Duration, attempt attemptFunc) (*proto.VerifyResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type answer struct {
+		resp *proto.VerifyResponse
+		err  error
+	}
+	answers := make(chan answer, 2)
+	run := func() {
+		resp, err := attempt(ctx)
+		answers <- answer{resp: resp, err: err}
+	}
+	go run()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	hedged, pending := timer.C, 1
+	for {
+		select {
+		case <-hedged:
+			hedged = nil
+			pending++
+			go run()
+		case a := <-answers:
+			pending--
+			if a.err == nil || pending == 0 || !retryable(a.err) {
+				return a.resp, a.err
+			}
+		}
+	}
+}
@@ -0,0 +1,366 @@
+// Package grpcserver serves the verification API over gRPC for internal callers
+// that prefer protobuf contracts. It shares the use case, token validation and
+// account checks with the REST API.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/handlers"
+	"github.com/example/ai-check/internal/imagelimits"
+	"github.com/example/ai-check/internal/logging"
+	"github.com/example/ai-check/internal/middleware"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/tenants"
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/users"
+	pb "github.com/example/ai-check/proto"
+)
+
+// Options tunes the server.
+type Options struct {
+	// MaxUploadSize is the largest image accepted, as for the REST API.
+	MaxUploadSize int64
+	// Users, when set, rejects calls of suspended users and Verify calls beyond the
+	// user's monthly quota.
+	Users *users.Service
+	// Tenants, when set, enforces the accepted upload types and quotas of tenants.
+	Tenants *tenants.Store
+	// ImageLimits, when set, rejects images with a corrupt header or larger
+	// dimensions.
+	ImageLimits *imagelimits.Limits
+	// ServerOptions are passed to grpc.NewServer, e.g. transport credentials.
+	ServerOptions []grpc.ServerOption
+}
+
+// DefaultOptions returns the limits used by NewServer.
+func DefaultOptions() Options {
+	return Options{MaxUploadSize: handlers.MaxUploadSize}
+}
+
+// NewServer returns a gRPC server with the verification service and the standard
+// health service registered. Every verification call must carry an
+// "authorization: Bearer <jwt>" metadata entry validated against creds.
+func NewServer(uc *usecase.VerificationUseCase, creds *auth.Credentials, logger *zap.Logger, opts Options) *grpc.Server {
+	logger = logger.Named("grpc")
+	serverOptions := append([]grpc.ServerOption{
+		// Leave room for the rest of the request next to the largest image.
+		grpc.MaxRecvMsgSize(int(opts.MaxUploadSize) + 64<<10),
+		grpc.ChainUnaryInterceptor(
+			recoveryInterceptor(logger),
+			loggingInterceptor(logger),
+			authInterceptor(creds, opts.Users),
+		),
+	}, opts.ServerOptions...)
+	server := grpc.NewServer(serverOptions...)
+	pb.RegisterVerificationServiceServer(server, &service{uc: uc, opts: opts})
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	return server
+}
+
+// requestIDMetadata carries the correlation ID of a call, in both directions.
+const requestIDMetadata = "x-request-id"
+
+// healthService is served without a token, so probes need no credentials.
+const healthService = "/grpc.health.v1.Health/"
+
+func authInterceptor(creds *auth.Credentials, accounts *users.Service) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, healthService) {
+			return handler(ctx, req)
+		}
+		var header string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("authorization"); len(values) > 0 {
+				header = values[0]
+			}
+		}
+		token, err := auth.BearerToken(header)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		identity, err := creds.IdentifyContext(ctx, token)
+		if errors.Is(err, auth.ErrRevocationCheckFailed) {
+			logging.FromContext(ctx, zap.L()).Error("refused token, failed to check its revocation", zap.Error(err))
+			return nil, status.Error(codes.Unavailable, auth.ErrRevocationCheckFailed.Error())
+		}
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if accounts != nil {
+			if err := accounts.CheckActive(ctx, identity.UserID); err != nil {
+				if errors.Is(err, users.ErrSuspended) {
+					return nil, status.Error(codes.PermissionDenied, "account suspended")
+				}
+				return nil, status.Error(codes.Internal, "failed to check account status")
+			}
+		}
+		return handler(identity.WithContext(ctx), req)
+	}
+}
+
+// loggingInterceptor gives every call a correlation ID, from its x-request-id
+// metadata or generated, like X-Request-ID over HTTP. The ID is sent back in the
+// x-request-id header and tags the logger the call hands to the use case.
+func loggingInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		started := time.Now()
+		var requestID string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(requestIDMetadata); len(values) > 0 {
+				requestID = values[0]
+			}
+		}
+		if !middleware.ValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, requestID))
+		callLogger := logger.With(zap.String(logging.CorrelationField, requestID))
+		ctx = logging.WithLogger(logging.WithRequestID(ctx, requestID), callLogger)
+
+		resp, err := handler(ctx, req)
+		callLogger.Info("grpc call",
+			zap.String("method", info.FullMethod),
+			zap.String("code", status.Code(err).String()),
+			zap.Duration("latency", time.Since(started)))
+		return resp, err
+	}
+}
+
+func recoveryInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logger.Error("grpc handler panicked", zap.String("method", info.FullMethod), zap.Any("panic", recovered), zap.Stack("stack"))
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+type service struct {
+	pb.UnimplementedVerificationServiceServer
+	uc   *usecase.VerificationUseCase
+	opts Options
+}
+
+func callerID(ctx context.Context) (string, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return userID, nil
+}
+
+// Verify implements pb.VerificationServiceServer. Images are checked like uploads to
+// POST /verify: their content must be an accepted type matching the declared one,
+// if any, and the token must grant the auth.ScopeVerifyWrite scope.
+func (s *service) Verify(ctx context.Context, req *pb.VerifyImageRequest) (*pb.VerifyImageResponse, error) {
+	userID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.HasScope(ctx, auth.ScopeVerifyWrite) {
+		return nil, status.Error(codes.PermissionDenied, "the "+auth.ScopeVerifyWrite+" scope is required")
+	}
+	if len(req.GetImage()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "image is required")
+	}
+	if int64(len(req.GetImage())) > s.opts.MaxUploadSize {
+		return nil, status.Error(codes.InvalidArgument, "image is too large")
+	}
+	contentType, ok := handlers.DetectImageType(req.GetImage(), req.GetContentType())
+	if !ok {
+		if handlers.IsAllowedContentType(contentType) && handlers.IsAllowedContentType(req.GetContentType()) {
+			return nil, status.Error(codes.InvalidArgument, "image content does not match its content type")
+		}
+		return nil, status.Error(codes.InvalidArgument, "unsupported content type")
+	}
+	if s.opts.ImageLimits != nil {
+		if _, err := s.opts.ImageLimits.Check(req.GetImage()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if tenantID, ok := auth.GetTenantID(ctx); ok && s.opts.Tenants != nil {
+		if err := s.opts.Tenants.CheckUpload(ctx, tenantID, contentType); err != nil {
+			switch {
+			case errors.Is(err, tenants.ErrContentTypeNotAllowed):
+				return nil, status.Error(codes.InvalidArgument, "content type not allowed for tenant")
+			case errors.Is(err, tenants.ErrQuotaExceeded):
+				return nil, status.Error(codes.ResourceExhausted, "monthly verification quota exceeded")
+			case errors.Is(err, tenants.ErrInvalidTenant):
+				return nil, status.Error(codes.Unauthenticated, "invalid tenant claim")
+			default:
+				return nil, status.Error(codes.Internal, "failed to check tenant settings")
+			}
+		}
+	}
+	if s.opts.Users != nil {
+		if err := s.opts.Users.CheckQuota(ctx, userID); err != nil {
+			if errors.Is(err, users.ErrQuotaExceeded) {
+				return nil, status.Error(codes.ResourceExhausted, "monthly verification quota exceeded")
+			}
+			return nil, status.Error(codes.Internal, "failed to check quota")
+		}
+	}
+
+	requestID, result, metadata, err := s.uc.VerifyImage(ctx, userID, req.GetImage())
+	if err != nil {
+		var duplicateErr *usecase.DuplicateImageError
+		if errors.As(err, &duplicateErr) {
+			return nil, status.Errorf(codes.AlreadyExists, "image was already verified in request %s", duplicateErr.RequestID)
+		}
+		var malwareErr *usecase.MalwareError
+		if errors.As(err, &malwareErr) {
+			return nil, status.Errorf(codes.InvalidArgument, "image contains malware: %s", malwareErr.Signature)
+		}
+		if errors.Is(err, usecase.ErrOverloaded) {
+			return nil, status.Error(codes.Unavailable, "server is overloaded, retry later")
+		}
+		// The response has no status to carry, so the request ID the verification
+		// was queued as is told in the message, to fetch with GetResult.
+		var queuedErr *usecase.QueuedError
+		if errors.As(err, &queuedErr) {
+			return nil, status.Errorf(codes.Unavailable, "image processor unavailable, verification queued as request %s", queuedErr.RequestID)
+		}
+		// Processor failures keep their code, but not the processor's message.
+		switch code := status.Code(err); code {
+		case codes.InvalidArgument, codes.ResourceExhausted, codes.Unavailable, codes.DeadlineExceeded:
+			return nil, status.Error(code, "image processor failed: "+code.String())
+		}
+		return nil, status.Error(codes.Internal, "verification failed")
+	}
+	response := &pb.VerifyImageResponse{
+		RequestId: requestID,
+		Verified:  result.Success,
+		Score:     result.Score,
+		Message:   result.Message,
+	}
+	if metadata != nil {
+		response.CreatedAt = timestamp(metadata.Timestamp)
+		response.Categories = categories(metadata.Categories)
+	}
+	return response, nil
+}
+
+// GetResult implements pb.VerificationServiceServer.
+func (s *service) GetResult(ctx context.Context, req *pb.GetResultRequest) (*pb.VerificationResult, error) {
+	userID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetRequestId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "request_id is required")
+	}
+	log, err := s.uc.GetResult(ctx, userID, req.GetRequestId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "result not found")
+	}
+	if log.UserID == "" {
+		log.UserID = userID
+	}
+	if log.RequestID == "" {
+		log.RequestID = req.GetRequestId()
+	}
+	return &pb.VerificationResult{
+		RequestId:  log.RequestID,
+		UserId:     log.UserID,
+		Score:      log.Score,
+		Success:    log.Success,
+		Details:    log.Details,
+		Sha1Hash:   log.SHA1Hash,
+		Sha256Hash: log.SHA256Hash,
+		CreatedAt:  timestamp(log.CreatedAt),
+		Categories: categories(usecase.CategoryOutcomes(log.Categories)),
+	}, nil
+}
+
+// GetDuplicates implements pb.VerificationServiceServer.
+func (s *service) GetDuplicates(ctx context.Context, req *pb.GetDuplicatesRequest) (*pb.DuplicateReport, error) {
+	userID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetRequestId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "request_id is required")
+	}
+	report, err := s.uc.GetDuplicateReport(ctx, userID, req.GetRequestId(), req.GetPageToken(), int(req.GetPageSize()))
+	if errors.Is(err, usecase.ErrInvalidCursor) {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "result not found")
+	}
+	return &pb.DuplicateReport{
+		RequestId:     report.Request.RequestID,
+		UserId:        report.Request.UserID,
+		Sha1Hash:      report.Request.SHA1Hash,
+		Sha256Hash:    report.Request.SHA256Hash,
+		Duplicates:    duplicates(report.Duplicates),
+		NextPageToken: report.NextCursor,
+		TotalCount:    report.Total,
+	}, nil
+}
+
+// Metrics implements pb.VerificationServiceServer.
+func (s *service) Metrics(ctx context.Context, _ *pb.MetricsRequest) (*pb.MetricsSummary, error) {
+	summary, err := s.uc.GetMetricsSummary(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to load metrics")
+	}
+	return &pb.MetricsSummary{
+		TotalRequests:              summary.TotalRequests,
+		SuccessfulRequests:         summary.SuccessfulRequests,
+		SuccessRate:                summary.SuccessRate,
+		AverageScore:               summary.AverageScore,
+		AverageProcessingLatencyMs: summary.AverageProcessingLatencyMs,
+	}, nil
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func categories(outcomes []usecase.CategoryOutcome) []*pb.CategoryOutcome {
+	converted := make([]*pb.CategoryOutcome, 0, len(outcomes))
+	for _, outcome := range outcomes {
+		converted = append(converted, &pb.CategoryOutcome{
+			Category:  outcome.Category,
+			Score:     outcome.Score,
+			Threshold: outcome.Threshold,
+			Flagged:   outcome.Flagged,
+		})
+	}
+	return converted
+}
+
+func duplicates(logs []*repository.VerificationLog) []*pb.Duplicate {
+	converted := make([]*pb.Duplicate, 0, len(logs))
+	for _, log := range logs {
+		converted = append(converted, &pb.Duplicate{
+			RequestId: log.RequestID,
+			Score:     log.Score,
+			Success:   log.Success,
+			Details:   log.Details,
+			CreatedAt: timestamp(log.CreatedAt),
+		})
+	}
+	return converted
+}
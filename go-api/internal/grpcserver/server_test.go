@@ -0,0 +1,152 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/users"
+	pb "github.com/example/ai-check/proto"
+)
+
+const testJWTSecret = "grpc-test-secret"
+
+// pngImage is the PNG signature followed by padding, enough to be sniffed as PNG.
+var pngImage = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+
+func buildTestToken(t *testing.T, subject string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   subject,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	signed, err := token.SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func withToken(ctx context.Context, t *testing.T, subject string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+buildTestToken(t, subject))
+}
+
+func TestVerificationServiceSharesUseCaseAndAuth(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	userRepo := repository.NewUserRepository(db, zap.NewNop())
+	for _, migrate := range []func(context.Context) error{repo.AutoMigrate, userRepo.AutoMigrate} {
+		if err := migrate(ctx); err != nil {
+			t.Fatalf("AutoMigrate returned error: %v", err)
+		}
+	}
+	redisServer, redisClient, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer redisServer.Close()
+	defer redisClient.Close()
+	accounts := users.NewService(userRepo, zap.NewNop())
+	uc := usecase.NewVerificationUseCase(repo, usecase.NewRedisCache(redisClient), devmode.Processor{}, zap.NewNop())
+
+	opts := DefaultOptions()
+	opts.Users = accounts
+	server := NewServer(uc, auth.NewCredentials("", testJWTSecret), zap.NewNop(), opts)
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	defer server.Stop()
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Dial returned error: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewVerificationServiceClient(conn)
+
+	if _, err := client.Verify(ctx, &pb.VerifyImageRequest{Image: pngImage}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected a call without a token to be unauthenticated, got %v", err)
+	}
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("expected health checks without a token, got %v", err)
+	}
+	if _, err := client.Verify(withToken(ctx, t, "user-1"), &pb.VerifyImageRequest{Image: []byte("plain text")}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected a sniffed text upload to be rejected, got %v", err)
+	}
+	if _, err := client.Verify(withToken(ctx, t, "user-1"), &pb.VerifyImageRequest{Image: pngImage, ContentType: "image/jpeg"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected a PNG declared as JPEG to be rejected, got %v", err)
+	}
+
+	readOnly, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "user-1", "scope": "verify:read", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	readOnlyCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+readOnly)
+	if _, err := client.Verify(readOnlyCtx, &pb.VerifyImageRequest{Image: pngImage}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected a read-only token to be denied verifications, got %v", err)
+	}
+
+	verified, err := client.Verify(withToken(ctx, t, "user-1"), &pb.VerifyImageRequest{Image: pngImage})
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if verified.RequestId == "" || verified.CreatedAt == "" || len(verified.Categories) == 0 {
+		t.Fatalf("unexpected response %+v", verified)
+	}
+	if _, err := client.Verify(withToken(ctx, t, "user-1"), &pb.VerifyImageRequest{Image: append(pngImage, 1), ContentType: "image/png"}); err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+
+	result, err := client.GetResult(withToken(ctx, t, "user-1"), &pb.GetResultRequest{RequestId: verified.RequestId})
+	if err != nil {
+		t.Fatalf("GetResult returned error: %v", err)
+	}
+	if result.UserId != "user-1" || result.Score != verified.Score || result.Sha1Hash == "" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	report, err := client.GetDuplicates(withToken(ctx, t, "user-1"), &pb.GetDuplicatesRequest{RequestId: verified.RequestId})
+	if err != nil {
+		t.Fatalf("GetDuplicates returned error: %v", err)
+	}
+	if report.RequestId != verified.RequestId || report.Sha1Hash != result.Sha1Hash || len(report.Duplicates) != 0 {
+		t.Fatalf("unexpected duplicate report %+v", report)
+	}
+	if _, err := client.GetDuplicates(withToken(ctx, t, "user-2"), &pb.GetDuplicatesRequest{RequestId: verified.RequestId}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected other users to get NotFound, got %v", err)
+	}
+	if _, err := client.GetResult(readOnlyCtx, &pb.GetResultRequest{RequestId: verified.RequestId}); err != nil {
+		t.Fatalf("expected a read-only token to read results, got %v", err)
+	}
+	metrics, err := client.Metrics(withToken(ctx, t, "user-1"), &pb.MetricsRequest{})
+	if err != nil || metrics.TotalRequests != 2 {
+		t.Fatalf("unexpected metrics %+v: %v", metrics, err)
+	}
+
+	if _, err := accounts.Suspend(ctx, "user-1", "abuse"); err != nil {
+		t.Fatalf("Suspend returned error: %v", err)
+	}
+	if _, err := client.Metrics(withToken(ctx, t, "user-1"), &pb.MetricsRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected suspended users to be denied, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/adaptive"
+)
+
+// RegisterAdaptiveLimitAdminRoutes exposes the adaptive verification limit and the
+// dependency latencies it follows. Mount them only on the admin listener.
+func RegisterAdaptiveLimitAdminRoutes(router gin.IRouter, limiter *adaptive.Limiter) {
+	router.GET("/admin/api/limits/adaptive", func(c *gin.Context) {
+		status := limiter.Status()
+		baselines := make(gin.H, len(status.Baselines))
+		for dependency, baseline := range status.Baselines {
+			baselines[dependency] = float64(baseline) / float64(time.Millisecond)
+		}
+		response := gin.H{
+			"limit":               status.Limit,
+			"in_flight":           status.InFlight,
+			"shed":                status.Shed,
+			"min_limit":           status.Settings.MinLimit,
+			"max_limit":           status.Settings.MaxLimit,
+			"baseline_latency_ms": baselines,
+		}
+		if !status.LastDecrease.IsZero() {
+			response["last_decrease_at"] = status.LastDecrease.UTC()
+		}
+		c.JSON(http.StatusOK, response)
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+// defaultAdminPageSize is the page size of the /admin routes without a limit.
+const defaultAdminPageSize = 50
+
+// registerRoleAdminRoutes exposes the verifications and metrics of every user to
+// tokens granted role. Unlike the admin listener's routes, they are served on the
+// public listener behind bearer authentication.
+func registerRoleAdminRoutes(router gin.IRouter, uc *usecase.VerificationUseCase, role string) {
+	admin := router.Group("/admin", auth.RequireRole(role))
+
+	admin.GET("/logs", func(c *gin.Context) {
+		limit, ok := limitQuery(c, defaultAdminPageSize, usecase.MaxPageSize)
+		if !ok {
+			return
+		}
+		filter, ok := adminFilter(c)
+		if !ok {
+			return
+		}
+
+		page, err := uc.SearchVerifications(c.Request.Context(), filter, c.Query("cursor"), limit)
+		if err != nil {
+			if errors.Is(err, usecase.ErrInvalidCursor) {
+				httperr.InvalidParameter(c, "cursor", err.Error())
+				return
+			}
+			httperr.Write(c, httperr.CodeInternal, "failed to search verifications")
+			return
+		}
+
+		logs := make([]gin.H, 0, len(page.Logs))
+		for _, log := range page.Logs {
+			entry := historyEntry(log)
+			entry["user_id"] = log.UserID
+			logs = append(logs, entry)
+		}
+		response := gin.H{"logs": logs}
+		if page.NextCursor != "" {
+			response["next_cursor"] = page.NextCursor
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	admin.GET("/metrics", func(c *gin.Context) {
+		limit, ok := limitQuery(c, defaultAdminPageSize, usecase.MaxPageSize)
+		if !ok {
+			return
+		}
+		filter, ok := adminFilter(c)
+		if !ok {
+			return
+		}
+
+		page, err := uc.GetUserMetrics(c.Request.Context(), filter, c.Query("cursor"), limit)
+		if err != nil {
+			if errors.Is(err, usecase.ErrInvalidCursor) {
+				httperr.InvalidParameter(c, "cursor", err.Error())
+				return
+			}
+			httperr.Write(c, httperr.CodeInternal, "failed to load metrics")
+			return
+		}
+
+		response := gin.H{"users": page.Users}
+		if page.NextCursor != "" {
+			response["next_cursor"] = page.NextCursor
+		}
+		c.JSON(http.StatusOK, response)
+	})
+}
+
+// adminFilter reads the parameters of historyFilter plus user_id, min_score and
+// max_score.
+func adminFilter(c *gin.Context) (repository.LogFilter, bool) {
+	filter, ok := historyFilter(c)
+	if !ok {
+		return filter, false
+	}
+	filter.UserID = c.Query("user_id")
+	for _, param := range []struct {
+		name string
+		dst  **float32
+	}{{"min_score", &filter.MinScore}, {"max_score", &filter.MaxScore}} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(raw, 32)
+		if err != nil {
+			httperr.InvalidParameter(c, param.name, param.name+" must be a number")
+			return filter, false
+		}
+		score := float32(parsed)
+		*param.dst = &score
+	}
+	if filter.MinScore != nil && filter.MaxScore != nil && *filter.MinScore > *filter.MaxScore {
+		httperr.InvalidParameter(c, "max_score", "max_score must not be below min_score")
+		return filter, false
+	}
+	return filter, true
+}
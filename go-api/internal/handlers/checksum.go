@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/httperr"
+)
+
+// checksumHeader declares the SHA-256 of the image sent to POST /verify, in hex or
+// base64, so uploads truncated or corrupted on the way are rejected.
+const checksumHeader = "X-Content-SHA256"
+
+// reasonChecksumMismatch is the details.reason of images whose SHA-256 differs from
+// the one declared in checksumHeader.
+const reasonChecksumMismatch = "checksum_mismatch"
+
+// checksumMismatchError reports an image whose SHA-256 differs from the declared one.
+type checksumMismatchError struct {
+	expected, actual []byte
+}
+
+func (e *checksumMismatchError) Error() string {
+	return "image SHA-256 " + hex.EncodeToString(e.actual) + " does not match the declared " + hex.EncodeToString(e.expected)
+}
+
+// checksumReader hashes the image as it is read and fails the read that reaches its
+// end with *checksumMismatchError when the digest differs from expected. The use case
+// reads every image to its end before it saves the verification, so a mismatched
+// image is never recorded.
+type checksumReader struct {
+	reader   io.Reader
+	hash     hash.Hash
+	expected []byte
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) {
+		if actual := r.hash.Sum(nil); !bytes.Equal(actual, r.expected) {
+			return n, &checksumMismatchError{expected: r.expected, actual: actual}
+		}
+	}
+	return n, err
+}
+
+// verifyChecksum wraps image to check it against the digest of checksumHeader, when
+// the request declares one. It answers 400 and returns false for a malformed header.
+func verifyChecksum(c *gin.Context, image io.Reader) (io.Reader, bool) {
+	value := strings.TrimSpace(c.GetHeader(checksumHeader))
+	if value == "" {
+		return image, true
+	}
+	expected, err := hex.DecodeString(value)
+	if err != nil || len(expected) != sha256.Size {
+		expected, err = base64.StdEncoding.DecodeString(value)
+	}
+	if err != nil || len(expected) != sha256.Size {
+		httperr.InvalidParameter(c, checksumHeader, checksumHeader+" must be a SHA-256 digest in hex or base64")
+		return nil, false
+	}
+	return &checksumReader{reader: image, hash: sha256.New(), expected: expected}, true
+}
+
+// writeChecksumMismatch answers an image whose SHA-256 differs from the declared one.
+func writeChecksumMismatch(c *gin.Context, err *checksumMismatchError) {
+	httperr.WriteWithDetails(c, httperr.CodeUnprocessableImage, "the image does not match "+checksumHeader, map[string]interface{}{
+		"reason":          reasonChecksumMismatch,
+		"expected_sha256": hex.EncodeToString(err.expected),
+		"actual_sha256":   hex.EncodeToString(err.actual),
+	})
+}
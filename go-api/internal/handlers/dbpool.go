@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/dbpool"
+	"github.com/example/ai-check/internal/httperr"
+)
+
+// RegisterDatabasePoolAdminRoutes exposes the database pool parameters and usage, and
+// changes them at runtime. Changes last until the process restarts. Mount them only
+// on the admin listener.
+func RegisterDatabasePoolAdminRoutes(router gin.IRouter, tuner *dbpool.Tuner) {
+	router.GET("/admin/api/database/pool", func(c *gin.Context) {
+		c.JSON(http.StatusOK, databasePoolResponse(tuner.Status()))
+	})
+
+	// Fields left out of the body keep their current value.
+	router.PATCH("/admin/api/database/pool", func(c *gin.Context) {
+		var request struct {
+			MaxOpenConns           *int   `json:"max_open_conns"`
+			MaxIdleConns           *int   `json:"max_idle_conns"`
+			ConnMaxLifetimeSeconds *int64 `json:"conn_max_lifetime_seconds"`
+			Adaptive               *bool  `json:"adaptive"`
+			MinOpenConns           *int   `json:"min_open_conns"`
+			MaxOpenConnsLimit      *int   `json:"max_open_conns_limit"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			httperr.InvalidBody(c, err)
+			return
+		}
+		settings := tuner.Settings()
+		if request.MaxOpenConns != nil {
+			settings.MaxOpenConns = *request.MaxOpenConns
+		}
+		if request.MaxIdleConns != nil {
+			settings.MaxIdleConns = *request.MaxIdleConns
+		}
+		if request.ConnMaxLifetimeSeconds != nil {
+			settings.ConnMaxLifetime = time.Duration(*request.ConnMaxLifetimeSeconds) * time.Second
+		}
+		if request.Adaptive != nil {
+			settings.Adaptive = *request.Adaptive
+		}
+		if request.MinOpenConns != nil {
+			settings.MinOpenConns = *request.MinOpenConns
+		}
+		if request.MaxOpenConnsLimit != nil {
+			settings.MaxOpenConnsLimit = *request.MaxOpenConnsLimit
+		}
+		if err := tuner.Apply(settings); err != nil {
+			if errors.Is(err, dbpool.ErrInvalidSettings) {
+				httperr.Write(c, httperr.CodeInvalidRequest, err.Error())
+				return
+			}
+			httperr.Write(c, httperr.CodeInternal, "failed to update database pool")
+			return
+		}
+		c.JSON(http.StatusOK, databasePoolResponse(tuner.Status()))
+	})
+}
+
+func databasePoolResponse(status dbpool.Status) gin.H {
+	settings := status.Settings
+	response := gin.H{
+		"max_open_conns":            settings.MaxOpenConns,
+		"max_idle_conns":            settings.MaxIdleConns,
+		"conn_max_lifetime_seconds": int64(settings.ConnMaxLifetime / time.Second),
+		"adaptive":                  settings.Adaptive,
+		"min_open_conns":            settings.MinOpenConns,
+		"max_open_conns_limit":      settings.MaxOpenConnsLimit,
+		"open_connections":          status.OpenConnections,
+		"in_use":                    status.InUse,
+		"idle":                      status.Idle,
+		"wait_count":                status.WaitCount,
+		"wait_duration_ms":          float64(status.WaitDuration) / float64(time.Millisecond),
+	}
+	if !status.LastAdjusted.IsZero() {
+		response["last_adjusted_at"] = status.LastAdjusted.UTC()
+	}
+	return response
+}
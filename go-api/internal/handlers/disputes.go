@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/disputes"
+	"github.com/example/ai-check/internal/httperr"
+)
+
+const (
+	defaultReviewPageSize = 50
+	maxReviewPageSize     = 500
+)
+
+// RegisterDisputeRoutes lets users dispute the verdicts of their verifications, with
+// tokens that may use the auth.ScopeVerifyWrite scope.
+func RegisterDisputeRoutes(router gin.IRouter, service *disputes.Service) {
+	router.POST("/result/:id/feedback", auth.RequireScope(auth.ScopeVerifyWrite), func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+		var request struct {
+			Reason string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			httperr.InvalidBody(c, err)
+			return
+		}
+		dispute, err := service.Submit(c.Request.Context(), userID, c.Param("id"), request.Reason)
+		switch {
+		case err == nil:
+			c.JSON(http.StatusCreated, dispute)
+		case errors.Is(err, disputes.ErrInvalidReason):
+			httperr.InvalidParameter(c, "reason", err.Error())
+		case errors.Is(err, disputes.ErrNotFound):
+			httperr.Write(c, httperr.CodeResultNotFound, "result not found")
+		case errors.Is(err, disputes.ErrAlreadyDisputed):
+			httperr.Write(c, httperr.CodeConflict, err.Error())
+		default:
+			httperr.Write(c, httperr.CodeInternal, "failed to submit feedback")
+		}
+	})
+
+	router.GET("/result/:id/feedback", func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+		dispute, err := service.Get(c.Request.Context(), userID, c.Param("id"))
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, dispute)
+		case errors.Is(err, disputes.ErrNotFound):
+			httperr.Write(c, httperr.CodeNotFound, "no feedback for this result")
+		default:
+			httperr.Write(c, httperr.CodeInternal, "failed to load feedback")
+		}
+	})
+}
+
+// RegisterDisputeAdminRoutes exposes the review queue of disputed verdicts and the
+// export of resolved ones. Mount them only on the admin listener.
+func RegisterDisputeAdminRoutes(router gin.IRouter, service *disputes.Service) {
+	router.GET("/admin/api/review-queue", func(c *gin.Context) {
+		limit, ok := limitQuery(c, defaultReviewPageSize, maxReviewPageSize)
+		if !ok {
+			return
+		}
+		page, err := service.Queue(c.Request.Context(), c.Query("cursor"), limit)
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, page)
+		case errors.Is(err, disputes.ErrInvalidCursor):
+			httperr.InvalidParameter(c, "cursor", err.Error())
+		default:
+			httperr.Write(c, httperr.CodeInternal, "failed to load the review queue")
+		}
+	})
+
+	group := router.Group("/admin/api/disputes")
+
+	group.POST("/:id/state", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			httperr.Write(c, httperr.CodeNotFound, "dispute not found")
+			return
+		}
+		var request struct {
+			State string `json:"state"`
+			Note  string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			httperr.InvalidBody(c, err)
+			return
+		}
+		dispute, err := service.Transition(c.Request.Context(), uint(id), request.State, request.Note)
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, dispute)
+		case errors.Is(err, disputes.ErrNotFound):
+			httperr.Write(c, httperr.CodeNotFound, "dispute not found")
+		case errors.Is(err, disputes.ErrInvalidState):
+			httperr.InvalidParameter(c, "state", err.Error())
+		default:
+			httperr.Write(c, httperr.CodeInternal, "failed to update dispute")
+		}
+	})
+
+	group.GET("/export", func(c *gin.Context) {
+		format := c.DefaultQuery("format", "jsonl")
+		contentType := "application/x-ndjson"
+		switch format {
+		case "jsonl":
+		case "csv":
+			contentType = "text/csv"
+		default:
+			httperr.InvalidParameter(c, "format", disputes.ErrInvalidFormat.Error())
+			return
+		}
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", `attachment; filename="disputes.`+format+`"`)
+		c.Status(http.StatusOK)
+		if _, err := service.Export(c.Request.Context(), c.Writer, format); err != nil {
+			// The status is already sent; a truncated body is all the client can see.
+			_ = c.Error(err)
+		}
+	})
+}
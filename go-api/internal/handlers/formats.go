@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/render"
+	"github.com/example/ai-check/internal/repository"
+)
+
+// resultFormat negotiates the format of GET /result/:id and GET /history from the
+// format query parameter and the Accept header. It answers 400 itself for unknown
+// formats.
+func resultFormat(c *gin.Context) (render.Format, bool) {
+	format, err := render.Negotiate(c.Query("format"), c.GetHeader("Accept"))
+	if err != nil {
+		httperr.InvalidParameter(c, "format", err.Error())
+		return "", false
+	}
+	c.Header("Vary", "Accept")
+	return format, true
+}
+
+// writeRendered answers with logs as CSV, or with the PDF written by pdf, as an
+// attachment named name plus the extension of format.
+func writeRendered(c *gin.Context, format render.Format, name string, logs []*repository.VerificationLog, pdf func(w io.Writer, issuedAt time.Time) error) {
+	c.Header("Content-Type", render.ContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="`+name+"."+string(format)+`"`)
+	c.Status(http.StatusOK)
+	var err error
+	if format == render.FormatPDF {
+		err = pdf(c.Writer, time.Now())
+	} else {
+		err = render.WriteCSV(c.Writer, logs)
+	}
+	if err != nil {
+		_ = c.Error(err)
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/graphql"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+// maxGraphQLBodySize bounds the size of GraphQL requests.
+const maxGraphQLBodySize = 1 << 20
+
+// defaultPageSize is the number of verifications or duplicates returned when a query
+// does not set "first".
+const defaultPageSize = 20
+
+// RegisterGraphQLRoutes serves the GraphQL query API at /graphql, accepting POST
+// requests with a JSON body and GET requests with query parameters, and prints the
+// schema at /graphql/schema. Mount it behind the authentication middleware.
+func RegisterGraphQLRoutes(router gin.IRoutes, uc *usecase.VerificationUseCase) {
+	schema := newGraphQLSchema(uc)
+	router.POST("/graphql", func(c *gin.Context) {
+		var req graphql.Request
+		if err := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, maxGraphQLBodySize)).Decode(&req); err != nil {
+			graphQLError(c, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		serveGraphQL(c, schema, req)
+	})
+	router.GET("/graphql", func(c *gin.Context) {
+		req := graphql.Request{Query: c.Query("query"), OperationName: c.Query("operationName")}
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				graphQLError(c, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+		serveGraphQL(c, schema, req)
+	})
+	router.GET("/graphql/schema", func(c *gin.Context) {
+		c.String(http.StatusOK, schema.String())
+	})
+}
+
+func serveGraphQL(c *gin.Context, schema *graphql.Schema, req graphql.Request) {
+	if req.Query == "" {
+		graphQLError(c, http.StatusBadRequest, "query is required")
+		return
+	}
+	response := schema.Execute(c.Request.Context(), req)
+	status := http.StatusOK
+	if !response.Executed() {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, response)
+}
+
+func graphQLError(c *gin.Context, status int, message string) {
+	c.JSON(status, gin.H{"errors": []gin.H{{"message": message}}})
+}
+
+// newGraphQLSchema declares the GraphQL schema. Fields are only resolved when they
+// are selected, so duplicates cost a query only for clients asking for them.
+func newGraphQLSchema(uc *usecase.VerificationUseCase) *graphql.Schema {
+	pageSize := func(p graphql.ResolveParams) (int, error) {
+		first, _ := p.Args["first"].(int)
+		if first < 1 || first > usecase.MaxPageSize {
+			return 0, fmt.Errorf("first must be between 1 and %d", usecase.MaxPageSize)
+		}
+		return first, nil
+	}
+	firstArg := &graphql.Argument{Name: "first", Type: graphql.Int, DefaultValue: defaultPageSize}
+
+	categoryType := &graphql.Object{Name: "CategoryOutcome", Fields: graphql.Fields{
+		"category":  {Type: graphql.NewNonNull(graphql.String), Resolve: categoryField(func(o usecase.CategoryOutcome) interface{} { return o.Category })},
+		"score":     {Type: graphql.NewNonNull(graphql.Float), Resolve: categoryField(func(o usecase.CategoryOutcome) interface{} { return o.Score })},
+		"threshold": {Type: graphql.NewNonNull(graphql.Float), Description: "The score the category is flagged at; 0 when it has none.", Resolve: categoryField(func(o usecase.CategoryOutcome) interface{} { return o.Threshold })},
+		"flagged":   {Type: graphql.NewNonNull(graphql.Boolean), Resolve: categoryField(func(o usecase.CategoryOutcome) interface{} { return o.Flagged })},
+	}}
+	duplicateType := &graphql.Object{Name: "Duplicate", Description: "Another verification of the same image.", Fields: graphql.Fields{
+		"requestId": {Type: graphql.NewNonNull(graphql.String), Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.RequestID })},
+		"score":     {Type: graphql.NewNonNull(graphql.Float), Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.Score })},
+		"success":   {Type: graphql.NewNonNull(graphql.Boolean), Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.Success })},
+		"details":   {Type: graphql.NewNonNull(graphql.String), Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.Details })},
+		"createdAt": {Type: graphql.NewNonNull(graphql.String), Resolve: logField(func(l *repository.VerificationLog) interface{} { return timestamp(l.CreatedAt) })},
+	}}
+	verificationType := &graphql.Object{Name: "Verification", Fields: graphql.Fields{
+		"requestId": {Type: graphql.NewNonNull(graphql.String), Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.RequestID })},
+		"region": {Type: graphql.String, Description: "The region that served the verification; null for single-region deployments.", Resolve: logField(func(l *repository.VerificationLog) interface{} {
+			if l.Region == "" {
+				return nil
+			}
+			return l.Region
+		})},
+		"userId":   {Type: graphql.NewNonNull(graphql.String), Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.UserID })},
+		"score":    {Type: graphql.NewNonNull(graphql.Float), Description: "Calibrated for the model version that produced it.", Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.Score })},
+		"rawScore": {Type: graphql.NewNonNull(graphql.Float), Description: "The score before calibration; 0 for verifications made before it was recorded.", Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.RawScore })},
+		"modelVersion": {Type: graphql.String, Description: "The model that scored the image; null when the processor did not report it.", Resolve: logField(func(l *repository.VerificationLog) interface{} {
+			if l.ModelVersion == "" {
+				return nil
+			}
+			return l.ModelVersion
+		})},
+		"success":             {Type: graphql.NewNonNull(graphql.Boolean), Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.Success })},
+		"details":             {Type: graphql.NewNonNull(graphql.String), Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.Details })},
+		"sha1Hash":            {Type: graphql.NewNonNull(graphql.String), Description: "Deprecated: use sha256Hash.", Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.SHA1Hash })},
+		"sha256Hash":          {Type: graphql.NewNonNull(graphql.String), Description: "Empty for verifications made before SHA-256 hashes were recorded and not backfilled.", Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.SHA256Hash })},
+		"processingLatencyMs": {Type: graphql.NewNonNull(graphql.Float), Resolve: logField(func(l *repository.VerificationLog) interface{} { return l.ProcessingLatencyMs })},
+		"createdAt":           {Type: graphql.NewNonNull(graphql.String), Description: "RFC 3339 timestamp in UTC.", Resolve: logField(func(l *repository.VerificationLog) interface{} { return timestamp(l.CreatedAt) })},
+		"categories": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryType))), Resolve: logField(func(l *repository.VerificationLog) interface{} {
+			return usecase.CategoryOutcomes(l.Categories)
+		})},
+		"duplicates": {
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(duplicateType))),
+			Description: "Other verifications of the same image, newest first.",
+			Args:        []*graphql.Argument{firstArg},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				first, err := pageSize(p)
+				if err != nil {
+					return nil, err
+				}
+				userID, _ := auth.GetUserID(p.Context)
+				duplicates, err := uc.FindDuplicates(p.Context, userID, p.Source.(*repository.VerificationLog), first)
+				if err != nil {
+					return nil, errors.New("failed to load duplicates")
+				}
+				return duplicates, nil
+			},
+		},
+	}}
+	pageInfoType := &graphql.Object{Name: "PageInfo", Fields: graphql.Fields{
+		"hasNextPage": {Type: graphql.NewNonNull(graphql.Boolean), Resolve: pageField(func(p *usecase.VerificationPage) interface{} { return p.NextCursor != "" })},
+		"endCursor": {Type: graphql.String, Description: "Pass as \"after\" to fetch the next page; null on the last page.", Resolve: pageField(func(p *usecase.VerificationPage) interface{} {
+			if p.NextCursor == "" {
+				return nil
+			}
+			return p.NextCursor
+		})},
+	}}
+	connectionType := &graphql.Object{Name: "VerificationConnection", Fields: graphql.Fields{
+		"nodes":    {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(verificationType))), Resolve: pageField(func(p *usecase.VerificationPage) interface{} { return p.Logs })},
+		"pageInfo": {Type: graphql.NewNonNull(pageInfoType), Resolve: pageField(func(p *usecase.VerificationPage) interface{} { return p })},
+	}}
+	metricsType := &graphql.Object{Name: "MetricsSummary", Fields: graphql.Fields{
+		"totalRequests":              {Type: graphql.NewNonNull(graphql.Int), Resolve: metricsField(func(m *usecase.MetricsSummary) interface{} { return m.TotalRequests })},
+		"successfulRequests":         {Type: graphql.NewNonNull(graphql.Int), Resolve: metricsField(func(m *usecase.MetricsSummary) interface{} { return m.SuccessfulRequests })},
+		"successRate":                {Type: graphql.NewNonNull(graphql.Float), Resolve: metricsField(func(m *usecase.MetricsSummary) interface{} { return m.SuccessRate })},
+		"averageScore":               {Type: graphql.NewNonNull(graphql.Float), Resolve: metricsField(func(m *usecase.MetricsSummary) interface{} { return m.AverageScore })},
+		"averageProcessingLatencyMs": {Type: graphql.NewNonNull(graphql.Float), Resolve: metricsField(func(m *usecase.MetricsSummary) interface{} { return m.AverageProcessingLatencyMs })},
+		"region": {Type: graphql.String, Description: "The region that answered; null for single-region deployments.", Resolve: metricsField(func(m *usecase.MetricsSummary) interface{} {
+			if m.Region == "" {
+				return nil
+			}
+			return m.Region
+		})},
+	}}
+
+	query := &graphql.Object{Name: "Query", Fields: graphql.Fields{
+		"verification": {
+			Type: verificationType,
+			Args: []*graphql.Argument{{Name: "requestId", Type: graphql.NewNonNull(graphql.String)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID, _ := auth.GetUserID(p.Context)
+				log, err := uc.GetResult(p.Context, userID, p.Args["requestId"].(string))
+				if err != nil {
+					return nil, errors.New("result not found")
+				}
+				return log, nil
+			},
+		},
+		"verifications": {
+			Type:        graphql.NewNonNull(connectionType),
+			Description: "Your verifications, newest first.",
+			Args:        []*graphql.Argument{firstArg, {Name: "after", Type: graphql.String}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				first, err := pageSize(p)
+				if err != nil {
+					return nil, err
+				}
+				userID, _ := auth.GetUserID(p.Context)
+				after, _ := p.Args["after"].(string)
+				page, err := uc.ListVerifications(p.Context, userID, after, first)
+				switch {
+				case errors.Is(err, usecase.ErrInvalidCursor):
+					return nil, err
+				case err != nil:
+					return nil, errors.New("failed to list verifications")
+				}
+				return page, nil
+			},
+		},
+		"metrics": {
+			Type: graphql.NewNonNull(metricsType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				summary, err := uc.GetMetricsSummary(p.Context)
+				if err != nil {
+					return nil, errors.New("failed to load metrics")
+				}
+				return summary, nil
+			},
+		},
+	}}
+
+	schema, err := graphql.NewSchema(query)
+	if err != nil {
+		// The schema is static, so this is a programming error caught by the tests.
+		panic(err)
+	}
+	return schema
+}
+
+func logField(get func(*repository.VerificationLog) interface{}) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(*repository.VerificationLog)), nil
+	}
+}
+
+func categoryField(get func(usecase.CategoryOutcome) interface{}) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(usecase.CategoryOutcome)), nil
+	}
+}
+
+func pageField(get func(*usecase.VerificationPage) interface{}) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(*usecase.VerificationPage)), nil
+	}
+}
+
+func metricsField(get func(*usecase.MetricsSummary) interface{}) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(*usecase.MetricsSummary)), nil
+	}
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -1,19 +1,51 @@
 package handlers
 
 import (
+	"bufio"
+	"errors"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 
 	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/buildinfo"
+	"github.com/example/ai-check/internal/disputes"
+	"github.com/example/ai-check/internal/health"
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/imagefetch"
+	"github.com/example/ai-check/internal/imagelimits"
+	"github.com/example/ai-check/internal/imageprocessor"
+	"github.com/example/ai-check/internal/livemetrics"
+	"github.com/example/ai-check/internal/metering"
+	"github.com/example/ai-check/internal/metrics"
+	"github.com/example/ai-check/internal/middleware"
+	"github.com/example/ai-check/internal/phash"
+	"github.com/example/ai-check/internal/ratelimit"
+	"github.com/example/ai-check/internal/render"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/resultstream"
+	"github.com/example/ai-check/internal/storage"
+	"github.com/example/ai-check/internal/tenants"
+	"github.com/example/ai-check/internal/tracing"
 	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/users"
+	"github.com/example/ai-check/internal/webhooks"
 )
 
 // MaxUploadSize defines the maximum supported upload size in bytes.
 const MaxUploadSize = 8 << 20 // 8 MiB
 
+const defaultSimilarLimit = 20
+
+// defaultHistoryPageSize is the page size of GET /history without a limit.
+const defaultHistoryPageSize = 20
+
 var allowedContentTypes = map[string]struct{}{
 	"image/jpeg": {},
 	"image/png":  {},
@@ -21,124 +53,262 @@ var allowedContentTypes = map[string]struct{}{
 	"image/webp": {},
 }
 
+// Options tunes request handling limits and how the routes are mounted.
+type Options struct {
+	MaxUploadSize int64
+	// BasePath mounts every route under a prefix such as "/api". Route-keyed
+	// middleware sees the prefixed pattern.
+	BasePath string
+	// Middleware runs before every route of a handler built by NewHandler.
+	Middleware []gin.HandlerFunc
+	// Logger, when set, logs every request handled by NewHandler and is handed to
+	// the layers below tagged with the request's X-Request-ID.
+	Logger *zap.Logger
+	// Readiness, when set, is served at /health/ready and /readyz.
+	Readiness *health.Checker
+	// ClientIP decides which forwarding headers NewHandler believes. The zero value
+	// trusts no proxy and uses the peer address.
+	ClientIP middleware.ClientIPConfig
+	// Webhooks, when set, enables the /webhooks routes.
+	Webhooks *webhooks.Service
+	// Usage, when set, enables GET /usage.
+	Usage *metering.Meter
+	// Users, when set, rejects authenticated requests of suspended users, rejects
+	// uploads beyond the user's monthly quota and reports the quota at GET /usage.
+	Users *users.Service
+	// Disputes, when set, enables the /result/:id/feedback routes.
+	Disputes *disputes.Service
+	// LiveMetrics, when set, is streamed at GET /metrics/stream, limited to the
+	// verifications of the caller's tenant.
+	LiveMetrics *livemetrics.Feed
+	// ResultStream, when set, streams results as they complete at
+	// GET /result/:id/stream.
+	ResultStream *resultstream.Hub
+	// Tenants, when set, enforces the accepted upload types and quotas of tenants on
+	// POST /verify.
+	Tenants *tenants.Store
+	// ImageFetcher, when set, lets POST /verify take a JSON body naming an
+	// image_url to download instead of a multipart upload.
+	ImageFetcher *imagefetch.Fetcher
+	// ImageLimits, when set, rejects uploads with a corrupt image header or larger
+	// dimensions with 422.
+	ImageLimits *imagelimits.Limits
+	// RetryAfter is sent with 503 answers to verifications the use case shed as
+	// overloaded. Zero sends one second.
+	RetryAfter time.Duration
+	// SpoolThreshold is how much of a multipart form NewHandler keeps in memory;
+	// larger files go to temporary files removed after the request. Zero keeps forms
+	// up to MaxUploadSize in memory.
+	SpoolThreshold int64
+	// Metrics, when set, counts and times requests by route. Serve its Handler on the
+	// admin listener; it is not served here.
+	Metrics *metrics.Metrics
+	// Tracer, when set, traces requests and continues the traces of callers.
+	Tracer *tracing.Tracer
+	// RateLimiter, when set, throttles authenticated routes per user and per client
+	// address with 429.
+	RateLimiter *ratelimit.Limiter
+	// TokenIssuer, when set, enables POST /auth/token, /auth/refresh and
+	// /auth/revoke.
+	TokenIssuer *auth.Issuer
+	// AdminRole, when set, enables the /admin routes for tokens granted that role in
+	// their roles claim.
+	AdminRole string
+	// LocalImages, when set, serves the signed URLs of images kept on local disk at
+	// GET /images/*key.
+	LocalImages *storage.Local
+	// Docs serves the OpenAPI document of the API at GET /openapi.json and Swagger
+	// UI at GET /docs.
+	Docs bool
+}
+
+// DefaultOptions returns the limits used by RegisterRoutes.
+func DefaultOptions() Options {
+	return Options{MaxUploadSize: MaxUploadSize}
+}
+
+// NewHandler builds the API as a self-contained http.Handler, so it can be served
+// directly, mounted inside another server's mux or wrapped with extra middleware.
+func NewHandler(uc *usecase.VerificationUseCase, authMiddleware gin.HandlerFunc, opts Options) http.Handler {
+	router := gin.New()
+	router.MaxMultipartMemory = opts.MaxUploadSize
+	if opts.SpoolThreshold > 0 {
+		router.MaxMultipartMemory = opts.SpoolThreshold
+	}
+	if err := opts.ClientIP.Configure(router); err != nil {
+		// The settings are validated when the configuration loads; never fall back to
+		// gin's default of trusting every peer.
+		_ = router.SetTrustedProxies(nil)
+	}
+	router.Use(middleware.RequestID())
+	if opts.Logger != nil {
+		router.Use(middleware.RequestLogger(opts.Logger))
+	}
+	if opts.Tracer != nil {
+		router.Use(opts.Tracer.Middleware())
+	}
+	router.Use(httperr.Recovery(), middleware.ClientIP())
+	if opts.Metrics != nil {
+		router.Use(opts.Metrics.Middleware())
+	}
+	router.Use(opts.Middleware...)
+	router.NoRoute(httperr.NotFound)
+	RegisterRoutesWithOptions(router, uc, authMiddleware, opts)
+	return router
+}
+
 // RegisterRoutes wires the HTTP handlers to the Gin router.
-func RegisterRoutes(router *gin.Engine, uc *usecase.VerificationUseCase, authMiddleware gin.HandlerFunc) {
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+func RegisterRoutes(router gin.IRouter, uc *usecase.VerificationUseCase, authMiddleware gin.HandlerFunc) {
+	RegisterRoutesWithOptions(router, uc, authMiddleware, DefaultOptions())
+}
+
+// RegisterRoutesWithOptions wires the HTTP handlers to a Gin router or route group
+// using explicit limits. Routes that change data need the auth.ScopeVerifyWrite
+// scope; tokens limited to other scopes can only read.
+func RegisterRoutesWithOptions(router gin.IRouter, uc *usecase.VerificationUseCase, authMiddleware gin.HandlerFunc, opts Options) {
+	if opts.BasePath != "" {
+		router = router.Group(opts.BasePath)
+	}
+
+	RegisterHealthRoutes(router)
+	RegisterVersionRoutes(router)
+	if opts.Readiness != nil {
+		RegisterReadinessRoutes(router, opts.Readiness)
+	}
+	if opts.TokenIssuer != nil {
+		RegisterTokenRoutes(router, opts.TokenIssuer)
+	}
+	if opts.LocalImages != nil {
+		RegisterLocalImageRoutes(router, opts.LocalImages)
+	}
+	if opts.Docs {
+		RegisterDocsRoutes(router, opts.BasePath)
+	}
 
 	protected := router.Group("")
 	protected.Use(authMiddleware)
+	if opts.Users != nil {
+		protected.Use(RequireActiveUser(opts.Users))
+	}
+	if opts.RateLimiter != nil {
+		protected.Use(opts.RateLimiter.Middleware())
+	}
+	if opts.Webhooks != nil {
+		RegisterWebhookRoutes(protected, opts.Webhooks)
+	}
+	if opts.Usage != nil || opts.Users != nil {
+		RegisterUsageRoutes(protected, opts.Usage, opts.Users)
+	}
+	if opts.Disputes != nil {
+		RegisterDisputeRoutes(protected, opts.Disputes)
+	}
+	if opts.AdminRole != "" {
+		registerRoleAdminRoutes(protected, uc, opts.AdminRole)
+	}
+	RegisterGraphQLRoutes(protected, uc)
 
 	protected.GET("/metrics/summary", func(c *gin.Context) {
 		if _, ok := auth.GetUserID(c.Request.Context()); !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
 			return
 		}
 
-		summary, err := uc.GetMetricsSummary(c.Request.Context())
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load metrics"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"total_requests":                summary.TotalRequests,
-			"successful_requests":           summary.SuccessfulRequests,
-			"success_rate":                  summary.SuccessRate,
-			"average_score":                 summary.AverageScore,
-			"average_processing_latency_ms": summary.AverageProcessingLatencyMs,
-		})
+		serveMetricsSummary(c, uc)
 	})
+	if opts.LiveMetrics != nil {
+		protected.GET("/metrics/stream", func(c *gin.Context) {
+			if _, ok := auth.GetUserID(c.Request.Context()); !ok {
+				httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+				return
+			}
+			// Users only see the verifications of their tenant, like the summary.
+			tenantID, _ := auth.GetTenantID(c.Request.Context())
+			serveMetricsStream(c, func() (<-chan livemetrics.Update, func()) {
+				return opts.LiveMetrics.SubscribeTenant(tenantID)
+			})
+		})
+	}
 
-	protected.POST("/verify", func(c *gin.Context) {
+	protected.POST("/verify", auth.RequireScope(auth.ScopeVerifyWrite), func(c *gin.Context) {
 		userID, ok := auth.GetUserID(c.Request.Context())
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
 			return
 		}
 
-		file, err := c.FormFile("image")
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "image file is required"})
-			return
+		var image io.Reader
+		var contentType string
+		if opts.ImageFetcher != nil && mediaType(c.ContentType()) == "application/json" {
+			image, contentType, ok = fetchImage(c, opts.ImageFetcher, opts.ImageLimits)
+		} else {
+			image, contentType, ok = openUpload(c, opts.MaxUploadSize, opts.ImageLimits)
 		}
-
-		if file.Size <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "image file is empty"})
+		if !ok {
 			return
 		}
-
-		if file.Size > MaxUploadSize {
-			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image file is too large"})
+		if image, ok = verifyChecksum(c, image); !ok {
 			return
 		}
-
-		if !isAllowedContentType(file.Header.Get("Content-Type")) {
-			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported content type"})
+		if opts.Tenants != nil && !checkTenantUpload(c, opts.Tenants, contentType) {
 			return
 		}
-
-		src, err := file.Open()
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "unable to open image"})
+		if opts.Users != nil && !checkUserQuota(c, opts.Users, userID) {
 			return
 		}
-		defer src.Close()
 
-		limited := io.LimitReader(src, MaxUploadSize+1)
-		data, err := io.ReadAll(limited)
+		requestID, result, metadata, err := uc.VerifyImageStream(c.Request.Context(), userID, image)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read image"})
-			return
-		}
-
-		if len(data) > MaxUploadSize {
-			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image file is too large"})
-			return
-		}
-
-		requestID, result, metadata, err := uc.VerifyImage(c.Request.Context(), userID, data)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		response := gin.H{
-			"request_id": requestID,
-			"verified":   result.Success,
-			"score":      result.Score,
-			"message":    result.Message,
-		}
-
-		if metadata != nil {
-			response["metadata"] = gin.H{
-				"timestamp": metadata.Timestamp,
-				"success":   metadata.Success,
-				"score":     metadata.Score,
+			var readErr *imageprocessor.ReadError
+			var maxBytesErr *http.MaxBytesError
+			var duplicateErr *usecase.DuplicateImageError
+			var malwareErr *usecase.MalwareError
+			var queuedErr *usecase.QueuedError
+			var checksumErr *checksumMismatchError
+			switch {
+			case errors.As(err, &queuedErr):
+				c.JSON(http.StatusAccepted, queuedResponse{RequestID: queuedErr.RequestID, Status: repository.StatusQueued})
+			case errors.Is(err, errUploadTooLarge), errors.As(err, &maxBytesErr):
+				httperr.Write(c, httperr.CodePayloadTooLarge, "image file is too large")
+			case errors.As(err, &checksumErr):
+				writeChecksumMismatch(c, checksumErr)
+			case errors.As(err, &readErr):
+				httperr.Write(c, httperr.CodeInvalidRequest, "unable to read image")
+			case errors.As(err, &duplicateErr):
+				httperr.WriteWithDetails(c, httperr.CodeDuplicateImage, "image was already verified", map[string]interface{}{"existing_request_id": duplicateErr.RequestID})
+			case errors.As(err, &malwareErr):
+				writeMalwareError(c, malwareErr)
+			case errors.Is(err, usecase.ErrOverloaded):
+				c.Header("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(opts.RetryAfter.Seconds())))))
+				httperr.Write(c, httperr.CodeOverloaded, "server is overloaded, retry later")
+			default:
+				writeProcessorError(c, err)
 			}
-			response["created_at"] = metadata.Timestamp
+			return
 		}
 
-		c.JSON(http.StatusOK, response)
+		c.JSON(http.StatusOK, newVerifyResponse(requestID, result, metadata))
 	})
 
 	protected.GET("/result/:id", func(c *gin.Context) {
 		userID, ok := auth.GetUserID(c.Request.Context())
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
 			return
 		}
 
 		requestID := c.Param("id")
 		if requestID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+			httperr.Write(c, httperr.CodeInvalidRequest, "id is required")
+			return
+		}
+		format, ok := resultFormat(c)
+		if !ok {
 			return
 		}
 
 		log, err := uc.GetResult(c.Request.Context(), userID, requestID)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "result not found"})
+			httperr.Write(c, httperr.CodeResultNotFound, "result not found")
 			return
 		}
 
@@ -149,33 +319,83 @@ func RegisterRoutes(router *gin.Engine, uc *usecase.VerificationUseCase, authMid
 			log.RequestID = requestID
 		}
 
+		if format != render.FormatJSON {
+			writeRendered(c, format, "verification-"+log.RequestID, []*repository.VerificationLog{log}, func(w io.Writer, issuedAt time.Time) error {
+				return render.WriteCertificate(w, log, issuedAt)
+			})
+			return
+		}
+		c.JSON(http.StatusOK, newResultResponse(log))
+	})
+
+	protected.GET("/result/:id/image", func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+
+		url, expiresAt, err := uc.ImageURL(c.Request.Context(), userID, c.Param("id"))
+		switch {
+		case errors.Is(err, usecase.ErrResultNotFound):
+			httperr.Write(c, httperr.CodeResultNotFound, "result not found")
+			return
+		case errors.Is(err, usecase.ErrImageNotStored):
+			httperr.Write(c, httperr.CodeImageNotStored, "image not stored")
+			return
+		case err != nil:
+			httperr.Write(c, httperr.CodeInternal, "failed to sign image url")
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
 		c.JSON(http.StatusOK, gin.H{
-			"request_id": log.RequestID,
-			"user_id":    log.UserID,
-			"score":      log.Score,
-			"success":    log.Success,
-			"details":    log.Details,
-			"sha1_hash":  log.SHA1Hash,
-			"created_at": log.CreatedAt,
+			"url":        url,
+			"expires_at": expiresAt,
 		})
 	})
 
+	if opts.ResultStream != nil {
+		protected.GET("/result/:id/stream", func(c *gin.Context) {
+			userID, ok := auth.GetUserID(c.Request.Context())
+			if !ok {
+				httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+				return
+			}
+			serveResultStream(c, uc, opts.ResultStream, userID, c.Param("id"))
+		})
+	}
+
 	protected.GET("/duplicates/:id", func(c *gin.Context) {
 		userID, ok := auth.GetUserID(c.Request.Context())
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
 			return
 		}
 
 		requestID := c.Param("id")
 		if requestID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+			httperr.Write(c, httperr.CodeInvalidRequest, "id is required")
 			return
 		}
 
-		report, err := uc.GetDuplicateReport(c.Request.Context(), userID, requestID)
+		limit := 0
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 {
+				httperr.InvalidParameter(c, "limit", "limit must be a positive integer")
+				return
+			}
+			limit = parsed
+		}
+
+		report, err := uc.GetDuplicateReport(c.Request.Context(), userID, requestID, c.Query("cursor"), limit)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "result not found"})
+			if errors.Is(err, usecase.ErrInvalidCursor) {
+				httperr.InvalidParameter(c, "cursor", err.Error())
+				return
+			}
+			httperr.Write(c, httperr.CodeResultNotFound, "result not found")
 			return
 		}
 
@@ -190,24 +410,639 @@ func RegisterRoutes(router *gin.Engine, uc *usecase.VerificationUseCase, authMid
 			})
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		response := gin.H{
 			"request_id":      report.Request.RequestID,
 			"user_id":         report.Request.UserID,
+			"sha256_hash":     report.Request.SHA256Hash,
 			"sha1_hash":       report.Request.SHA1Hash,
-			"duplicate_count": len(report.Duplicates),
+			"duplicate_count": report.Total,
 			"duplicates":      duplicates,
+		}
+		if report.NextCursor != "" {
+			response["next_cursor"] = report.NextCursor
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	protected.POST("/search/similar", func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+
+		maxDistance := usecase.DefaultSimilarDistance
+		if raw := c.Query("max_distance"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 0 || parsed > phash.Bits {
+				httperr.InvalidParameter(c, "max_distance", "max_distance must be between 0 and "+strconv.Itoa(phash.Bits))
+				return
+			}
+			maxDistance = parsed
+		}
+		limit, ok := limitQuery(c, defaultSimilarLimit, usecase.MaxSimilarResults)
+		if !ok {
+			return
+		}
+		data, ok := readUpload(c, opts.MaxUploadSize, opts.ImageLimits)
+		if !ok {
+			return
+		}
+
+		if c.Query("format") == streamNDJSON {
+			// Streamed matches arrive as the history is scanned, unranked and uncapped.
+			stream, _ := newStream(c, streamNDJSON, "")
+			err := uc.StreamSimilar(c.Request.Context(), userID, data, maxDistance, func(matches []usecase.SimilarVerification) error {
+				for _, match := range matches {
+					if err := stream.write(similarResult(match)); err != nil {
+						return err
+					}
+				}
+				stream.flush()
+				return nil
+			})
+			if !stream.finish(err) {
+				writeSimilarError(c, err)
+			}
+			return
+		}
+
+		matches, err := uc.FindSimilar(c.Request.Context(), userID, data, maxDistance, limit)
+		if err != nil {
+			writeSimilarError(c, err)
+			return
+		}
+
+		results := make([]gin.H, 0, len(matches))
+		for _, match := range matches {
+			results = append(results, similarResult(match))
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results})
+	})
+
+	protected.GET("/history", func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+		limit, ok := limitQuery(c, defaultHistoryPageSize, usecase.MaxPageSize)
+		if !ok {
+			return
+		}
+		filter, ok := historyFilter(c)
+		if !ok {
+			return
+		}
+		format, ok := resultFormat(c)
+		if !ok {
+			return
+		}
+
+		page, err := uc.History(c.Request.Context(), userID, filter, c.Query("cursor"), limit)
+		if err != nil {
+			if errors.Is(err, usecase.ErrInvalidCursor) {
+				httperr.InvalidParameter(c, "cursor", err.Error())
+				return
+			}
+			httperr.Write(c, httperr.CodeInternal, "failed to load verifications")
+			return
+		}
+
+		if format != render.FormatJSON {
+			// The body has no room for the cursor of the next page.
+			if page.NextCursor != "" {
+				c.Header("X-Next-Cursor", page.NextCursor)
+			}
+			writeRendered(c, format, "verifications", page.Logs, func(w io.Writer, issuedAt time.Time) error {
+				return render.WriteReport(w, "Verification history of "+userID, page.Logs, issuedAt)
+			})
+			return
+		}
+
+		verifications := make([]gin.H, 0, len(page.Logs))
+		for _, log := range page.Logs {
+			verifications = append(verifications, historyEntry(log))
+		}
+		response := gin.H{"verifications": verifications}
+		if page.NextCursor != "" {
+			response["next_cursor"] = page.NextCursor
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	protected.GET("/history/export", func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+		stream, ok := newStream(c, streamNDJSON, "verifications")
+		if !ok {
+			return
+		}
+
+		err := uc.ExportVerifications(c.Request.Context(), userID, func(logs []*repository.VerificationLog) error {
+			for _, log := range logs {
+				if err := stream.write(historyEntry(log)); err != nil {
+					return err
+				}
+			}
+			stream.flush()
+			return nil
 		})
+		if !stream.finish(err) {
+			httperr.Write(c, httperr.CodeInternal, "failed to export verifications")
+		}
+	})
+
+	protected.DELETE("/me/data", auth.RequireScope(auth.ScopeVerifyWrite), func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+
+		// A failure part way leaves the batches erased so far erased; retrying
+		// erases the rest.
+		erased, err := uc.EraseUserData(c.Request.Context(), userID)
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to erase data")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"erased": erased})
 	})
 }
 
-func isAllowedContentType(contentType string) bool {
-	contentType = strings.ToLower(strings.TrimSpace(contentType))
-	if idx := strings.Index(contentType, ";"); idx != -1 {
-		contentType = strings.TrimSpace(contentType[:idx])
+func writeSimilarError(c *gin.Context, err error) {
+	if errors.Is(err, usecase.ErrUnsupportedImage) {
+		httperr.Write(c, httperr.CodeUnsupportedMediaType, err.Error())
+		return
 	}
+	httperr.Write(c, httperr.CodeInternal, "similarity search failed")
+}
+
+func similarResult(match usecase.SimilarVerification) gin.H {
+	return gin.H{
+		"request_id":  match.Log.RequestID,
+		"score":       match.Log.Score,
+		"success":     match.Log.Success,
+		"sha256_hash": match.Log.SHA256Hash,
+		"sha1_hash":   match.Log.SHA1Hash,
+		"created_at":  match.Log.CreatedAt,
+		"distance":    match.Distance,
+		"similarity":  1 - float64(match.Distance)/phash.Bits,
+	}
+}
+
+// timeRange reads the from and to query parameters, each zero when absent. Dates
+// are RFC 3339 timestamps or YYYY-MM-DD days; a to day includes that day.
+func timeRange(c *gin.Context) (from, to time.Time, ok bool) {
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+			*param.dst = parsed
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			httperr.InvalidParameter(c, param.name, param.name+" must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+			return from, to, false
+		}
+		if param.name == "to" {
+			day = day.AddDate(0, 0, 1)
+		}
+		*param.dst = day
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		httperr.InvalidParameter(c, "to", "to must be after from")
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// historyFilter reads the from, to and success query parameters of GET /history.
+func historyFilter(c *gin.Context) (repository.LogFilter, bool) {
+	var filter repository.LogFilter
+	var ok bool
+	if filter.From, filter.To, ok = timeRange(c); !ok {
+		return filter, false
+	}
+	if raw := c.Query("success"); raw != "" {
+		success, err := strconv.ParseBool(raw)
+		if err != nil {
+			httperr.InvalidParameter(c, "success", "success must be true or false")
+			return filter, false
+		}
+		filter.Success = &success
+	}
+	return filter, true
+}
+
+func historyEntry(log *repository.VerificationLog) gin.H {
+	categories := make([]gin.H, 0, len(log.Categories))
+	for _, category := range log.Categories {
+		categories = append(categories, gin.H{
+			"category":  category.Category,
+			"score":     category.Score,
+			"threshold": category.Threshold,
+			"flagged":   category.Flagged,
+		})
+	}
+	return gin.H{
+		"request_id":            log.RequestID,
+		"score":                 log.Score,
+		"success":               log.Success,
+		"model_version":         log.ModelVersion,
+		"sha256_hash":           log.SHA256Hash,
+		"sha1_hash":             log.SHA1Hash,
+		"processing_latency_ms": log.ProcessingLatencyMs,
+		"status":                logStatus(log),
+		"categories":            categories,
+		"created_at":            log.CreatedAt,
+	}
+}
+
+// RegisterAdminRoutes exposes operational APIs without bearer authentication. Mount
+// them only on the admin listener, which is expected to be network-restricted.
+func RegisterAdminRoutes(router gin.IRouter, uc *usecase.VerificationUseCase) {
+	api := router.Group("/admin/api")
+	api.GET("/metrics/summary", func(c *gin.Context) {
+		serveMetricsSummary(c, uc)
+	})
+	api.GET("/metrics/variants", func(c *gin.Context) {
+		variants, err := uc.GetVariantMetrics(c.Request.Context())
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to load variant metrics")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"variants": variants})
+	})
+}
+
+func serveMetricsSummary(c *gin.Context, uc *usecase.VerificationUseCase) {
+	if c.Query("from") != "" || c.Query("to") != "" || c.Query("interval") != "" {
+		serveMetricsSeries(c, uc)
+		return
+	}
+	summary, err := uc.GetMetricsSummary(c.Request.Context())
+	if err != nil {
+		httperr.Write(c, httperr.CodeInternal, "failed to load metrics")
+		return
+	}
+
+	response := gin.H{
+		"total_requests":                summary.TotalRequests,
+		"successful_requests":           summary.SuccessfulRequests,
+		"success_rate":                  summary.SuccessRate,
+		"average_score":                 summary.AverageScore,
+		"average_processing_latency_ms": summary.AverageProcessingLatencyMs,
+	}
+	if summary.Region != "" {
+		response["region"] = summary.Region
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// defaultMetricsWindow is the window of a metrics series without ?from=.
+const defaultMetricsWindow = 24 * time.Hour
+
+// serveMetricsSeries answers /metrics/summary for the window of ?from= and ?to=, in
+// periods of ?interval=.
+func serveMetricsSeries(c *gin.Context, uc *usecase.VerificationUseCase) {
+	from, to, ok := timeRange(c)
+	if !ok {
+		return
+	}
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultMetricsWindow)
+	}
+	if !from.Before(to) {
+		httperr.InvalidParameter(c, "from", "from must be before to")
+		return
+	}
+	rawInterval := c.DefaultQuery("interval", "1h")
+	interval, err := time.ParseDuration(rawInterval)
+	if err != nil || interval <= 0 || interval%time.Hour != 0 {
+		httperr.InvalidParameter(c, "interval", "interval must be a whole number of hours, such as 1h or 24h")
+		return
+	}
+	if to.Sub(from.Truncate(time.Hour)) > time.Duration(usecase.MaxMetricsPeriods)*interval {
+		httperr.InvalidParameter(c, "interval", "the window must not span more than "+strconv.Itoa(usecase.MaxMetricsPeriods)+" intervals")
+		return
+	}
+
+	series, err := uc.GetMetricsSeries(c.Request.Context(), from, to, interval)
+	if err != nil {
+		httperr.Write(c, httperr.CodeInternal, "failed to load metrics")
+		return
+	}
+	periods := make([]gin.H, 0, len(series.Periods))
+	for _, period := range series.Periods {
+		periods = append(periods, gin.H{
+			"start":                         period.Start,
+			"total_requests":                period.TotalRequests,
+			"successful_requests":           period.SuccessfulRequests,
+			"success_rate":                  period.SuccessRate,
+			"average_score":                 period.AverageScore,
+			"average_processing_latency_ms": period.AverageProcessingLatencyMs,
+		})
+	}
+	response := gin.H{
+		"total_requests":                series.TotalRequests,
+		"successful_requests":           series.SuccessfulRequests,
+		"success_rate":                  series.SuccessRate,
+		"average_score":                 series.AverageScore,
+		"average_processing_latency_ms": series.AverageProcessingLatencyMs,
+		"interval":                      rawInterval,
+		"series":                        periods,
+	}
+	if len(series.Periods) > 0 {
+		response["from"] = series.Periods[0].Start
+		response["to"] = series.Periods[len(series.Periods)-1].Start.Add(interval)
+	}
+	if series.Region != "" {
+		response["region"] = series.Region
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RegisterVersionRoutes exposes the build description of the running binary.
+func RegisterVersionRoutes(router gin.IRoutes) {
+	router.GET("/version", func(c *gin.Context) {
+		c.JSON(http.StatusOK, buildinfo.Get())
+	})
+}
+
+// RegisterHealthRoutes exposes the liveness endpoint at /health/live and its older
+// path /health. It is mounted on both the public and the admin listener.
+func RegisterHealthRoutes(router gin.IRoutes) {
+	live := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+	router.GET("/health/live", live)
+	router.GET("/health", live)
+}
+
+// RegisterReadinessRoutes exposes /health/ready and its older path /readyz, which
+// answer 503 while any dependency check fails so load balancers and orchestrators
+// stop routing traffic here.
+func RegisterReadinessRoutes(router gin.IRoutes, checker *health.Checker) {
+	ready := func(c *gin.Context) {
+		report := checker.Check(c.Request.Context())
+		status := http.StatusOK
+		if !report.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+	router.GET("/health/ready", ready)
+	router.GET("/readyz", ready)
+}
+
+// IsAllowedContentType reports whether images of contentType, which may carry
+// parameters, are accepted for verification.
+func IsAllowedContentType(contentType string) bool {
+	contentType = mediaType(contentType)
 	if contentType == "" {
 		return false
 	}
 	_, ok := allowedContentTypes[contentType]
 	return ok
 }
+
+// DetectImageType identifies an image from its first bytes, head, rather than the
+// type its sender declared. It returns the detected media type, and whether that is
+// an accepted type agreeing with declared; an empty declared type agrees with any.
+func DetectImageType(head []byte, declared string) (string, bool) {
+	detected := mediaType(http.DetectContentType(head))
+	if !IsAllowedContentType(detected) {
+		return detected, false
+	}
+	if declared != "" && mediaType(declared) != detected {
+		return detected, false
+	}
+	return detected, true
+}
+
+// checkImageLimits answers 422 when limits is set and rejects the image starting
+// with head.
+func checkImageLimits(c *gin.Context, limits *imagelimits.Limits, head []byte) bool {
+	if limits == nil {
+		return true
+	}
+	_, err := limits.Check(head)
+	var rejected *imagelimits.Error
+	if !errors.As(err, &rejected) {
+		return true
+	}
+	details := map[string]interface{}{"reason": rejected.Reason}
+	if rejected.Width > 0 && rejected.Height > 0 {
+		details["width"] = rejected.Width
+		details["height"] = rejected.Height
+	}
+	httperr.WriteWithDetails(c, httperr.CodeUnprocessableImage, rejected.Error(), details)
+	return false
+}
+
+// writeContentMismatch answers 415 for an image whose content is not an accepted
+// type matching declared.
+func writeContentMismatch(c *gin.Context, detected string) {
+	if IsAllowedContentType(detected) {
+		httperr.Write(c, httperr.CodeUnsupportedMediaType, "image content does not match its content type")
+		return
+	}
+	httperr.Write(c, httperr.CodeUnsupportedMediaType, "unsupported image content")
+}
+
+// reasonRejectedByProcessor is the details.reason of images the processor refused.
+const reasonRejectedByProcessor = "rejected_by_processor"
+
+// reasonMalwareDetected is the details.reason of images the malware scanner found
+// infected.
+const reasonMalwareDetected = "malware_detected"
+
+// writeMalwareError answers an upload the malware scanner found infected, naming the
+// request the rejection was recorded as when it was.
+func writeMalwareError(c *gin.Context, err *usecase.MalwareError) {
+	details := map[string]interface{}{"reason": reasonMalwareDetected, "signature": err.Signature}
+	if err.RequestID != "" {
+		details["request_id"] = err.RequestID
+	}
+	httperr.WriteWithDetails(c, httperr.CodeUnprocessableImage, "the image contains malware", details)
+}
+
+// writeProcessorError answers a failed verification by the gRPC status the image
+// processor failed with. Other failures, and the processor's messages, are not
+// exposed to the caller.
+func writeProcessorError(c *gin.Context, err error) {
+	switch code := httperr.FromError(err); code {
+	case httperr.CodeUnprocessableImage:
+		httperr.WriteWithDetails(c, code, "the image processor rejected the image", map[string]interface{}{"reason": reasonRejectedByProcessor})
+	case httperr.CodeProcessorBusy:
+		httperr.Write(c, code, "the image processor is busy, retry later")
+	case httperr.CodeProcessorUnavailable:
+		httperr.Write(c, code, "the image processor is unavailable")
+	case httperr.CodeProcessorTimeout:
+		httperr.Write(c, code, "the image processor did not answer in time")
+	default:
+		httperr.Write(c, httperr.CodeInternal, "verification failed")
+	}
+}
+
+func mediaType(contentType string) string {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if idx := strings.Index(contentType, ";"); idx != -1 {
+		contentType = strings.TrimSpace(contentType[:idx])
+	}
+	return contentType
+}
+
+// errUploadTooLarge is returned by the image reader of openUpload once the image
+// exceeds the upload limit.
+var errUploadTooLarge = errors.New("image file is too large")
+
+// uploadOverhead leaves room for the multipart framing and small fields next to the
+// largest image.
+const uploadOverhead = 64 << 10
+
+// openUpload opens the "image" file of a multipart form without buffering it,
+// answering 400, 413, 415 or 422 when it is missing, empty, too large as declared,
+// not an image of its declared, supported type, or rejected by limits. It returns the detected type with the
+// image. The returned reader fails with errUploadTooLarge once more
+// than maxSize bytes are read, as uploads need not declare their length.
+func openUpload(c *gin.Context, maxSize int64, limits *imagelimits.Limits) (io.Reader, string, bool) {
+	if c.Request.ContentLength > maxSize+uploadOverhead {
+		httperr.Write(c, httperr.CodePayloadTooLarge, "image file is too large")
+		return nil, "", false
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+uploadOverhead)
+	form, err := c.Request.MultipartReader()
+	if err != nil {
+		httperr.InvalidParameter(c, "image", "image file is required")
+		return nil, "", false
+	}
+	for {
+		part, err := form.NextPart()
+		if err != nil {
+			httperr.InvalidParameter(c, "image", "image file is required")
+			return nil, "", false
+		}
+		if part.FormName() != "image" || part.FileName() == "" {
+			continue
+		}
+
+		contentType := part.Header.Get("Content-Type")
+		if !IsAllowedContentType(contentType) {
+			httperr.Write(c, httperr.CodeUnsupportedMediaType, "unsupported content type")
+			return nil, "", false
+		}
+		// The header is read ahead, so it can be checked before the image is sent on.
+		image := bufio.NewReaderSize(&uploadReader{reader: part, remaining: maxSize}, imagelimits.HeaderLen)
+		head, err := image.Peek(imagelimits.HeaderLen)
+		if err != nil && !(errors.Is(err, io.EOF) && len(head) > 0) {
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case errors.Is(err, io.EOF):
+				httperr.InvalidParameter(c, "image", "image file is empty")
+			case errors.Is(err, errUploadTooLarge), errors.As(err, &maxBytesErr):
+				httperr.Write(c, httperr.CodePayloadTooLarge, "image file is too large")
+			default:
+				httperr.Write(c, httperr.CodeInvalidRequest, "unable to read image")
+			}
+			return nil, "", false
+		}
+		detected, ok := DetectImageType(head, contentType)
+		if !ok {
+			writeContentMismatch(c, detected)
+			return nil, "", false
+		}
+		if !checkImageLimits(c, limits, head) {
+			return nil, "", false
+		}
+		return image, detected, true
+	}
+}
+
+// uploadReader fails with errUploadTooLarge once more than remaining bytes are read.
+type uploadReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, errUploadTooLarge
+	}
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, errUploadTooLarge
+	}
+	return n, err
+}
+
+// readUpload reads the "image" file of a multipart form, answering 400, 413 or 415
+// when it is missing, too large or not an image of its declared, supported type, and
+// 422 when it is rejected by limits.
+func readUpload(c *gin.Context, maxSize int64, limits *imagelimits.Limits) ([]byte, bool) {
+	file, err := c.FormFile("image")
+	if err != nil {
+		httperr.InvalidParameter(c, "image", "image file is required")
+		return nil, false
+	}
+
+	if file.Size <= 0 {
+		httperr.InvalidParameter(c, "image", "image file is empty")
+		return nil, false
+	}
+
+	if file.Size > maxSize {
+		httperr.Write(c, httperr.CodePayloadTooLarge, "image file is too large")
+		return nil, false
+	}
+
+	if !IsAllowedContentType(file.Header.Get("Content-Type")) {
+		httperr.Write(c, httperr.CodeUnsupportedMediaType, "unsupported content type")
+		return nil, false
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		httperr.Write(c, httperr.CodeInvalidRequest, "unable to open image")
+		return nil, false
+	}
+	defer src.Close()
+
+	limited := io.LimitReader(src, maxSize+1)
+	data, err := io.ReadAll(limited)
+	if err != nil {
+		httperr.Write(c, httperr.CodeInternal, "failed to read image")
+		return nil, false
+	}
+
+	if int64(len(data)) > maxSize {
+		httperr.Write(c, httperr.CodePayloadTooLarge, "image file is too large")
+		return nil, false
+	}
+	if detected, ok := DetectImageType(data, file.Header.Get("Content-Type")); !ok {
+		writeContentMismatch(c, detected)
+		return nil, false
+	}
+	if !checkImageLimits(c, limits, data) {
+		return nil, false
+	}
+	return data, true
+}
@@ -1,14 +1,27 @@
 package handlers
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,11 +29,33 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/dbpool"
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/disputes"
+	"github.com/example/ai-check/internal/health"
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/imagefetch"
+	"github.com/example/ai-check/internal/imagelimits"
 	"github.com/example/ai-check/internal/imageprocessor"
+	"github.com/example/ai-check/internal/livemetrics"
+	"github.com/example/ai-check/internal/logging"
+	"github.com/example/ai-check/internal/malwarescan"
+	"github.com/example/ai-check/internal/metering"
+	"github.com/example/ai-check/internal/metrics"
 	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/resultstream"
+	"github.com/example/ai-check/internal/storage"
+	"github.com/example/ai-check/internal/tenants"
 	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/users"
+	"github.com/example/ai-check/internal/webhooks"
+	"github.com/example/ai-check/internal/worker"
 )
 
 const testJWTSecret = "test-secret"
@@ -31,21 +66,58 @@ func TestVerifyRejectsLargeUpload(t *testing.T) {
 	router := gin.New()
 	router.MaxMultipartMemory = MaxUploadSize
 
-	uc := &usecase.VerificationUseCase{}
+	processor := &streamStubProcessor{}
+	uc := usecase.NewVerificationUseCase(verifyStubRepository{}, verifyStubCache{}, processor, zap.NewNop())
 	RegisterRoutes(router, uc, auth.JWTMiddleware(testJWTSecret, ""))
 
 	token := buildTestToken(t, "user-123")
-	body, contentType := buildMultipartBody(t, "image/png", bytes.Repeat([]byte("a"), MaxUploadSize+1))
+	for _, size := range []int{MaxUploadSize + 1, MaxUploadSize + uploadOverhead} {
+		for _, declareLength := range []bool{true, false} {
+			body, contentType := buildMultipartBody(t, "image/png", fakeImage("image/png", bytes.Repeat([]byte("a"), size-8)))
 
-	req := httptest.NewRequest(http.MethodPost, "/verify", body)
+			req := httptest.NewRequest(http.MethodPost, "/verify", body)
+			if !declareLength {
+				req = httptest.NewRequest(http.MethodPost, "/verify", io.MultiReader(body))
+			}
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			processor.received = 0
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			if resp.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("expected status %d for %d bytes, got %d", http.StatusRequestEntityTooLarge, size, resp.Code)
+			}
+			if processor.received > MaxUploadSize+1 {
+				t.Fatalf("expected the upload to be cut off at the limit, the processor read %d bytes", processor.received)
+			}
+		}
+	}
+}
+
+func TestVerifyStreamsUploadsToTheProcessor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	processor := &streamStubProcessor{}
+	uc := usecase.NewVerificationUseCase(verifyStubRepository{}, verifyStubCache{}, processor, zap.NewNop())
+	RegisterRoutes(router, uc, auth.JWTMiddleware(testJWTSecret, ""))
+
+	payload := fakeImage("image/png", bytes.Repeat([]byte("streamed image "), 1<<16))
+	body, contentType := buildMultipartBody(t, "image/png", payload)
+	req := httptest.NewRequest(http.MethodPost, "/verify", io.MultiReader(body))
 	req.Header.Set("Content-Type", contentType)
-	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
 
 	resp := httptest.NewRecorder()
 	router.ServeHTTP(resp, req)
 
-	if resp.Code != http.StatusRequestEntityTooLarge {
-		t.Fatalf("expected status %d, got %d", http.StatusRequestEntityTooLarge, resp.Code)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, resp.Code, resp.Body.String())
+	}
+	if processor.received != len(payload) || processor.calls != 1 {
+		t.Fatalf("expected one streamed call with the whole image, got %d calls and %d bytes", processor.calls, processor.received)
 	}
 }
 
@@ -120,6 +192,192 @@ func TestMetricsSummaryReturnsAggregates(t *testing.T) {
 	}
 }
 
+func TestMetricsSummaryBreaksWindowsIntoPeriods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	uc := usecase.NewVerificationUseCase(&metricsStubRepository{}, &metricsStubCache{}, &metricsStubProcessor{}, zap.NewNop())
+	RegisterRoutes(router, uc, auth.JWTMiddleware(testJWTSecret, ""))
+	token := buildTestToken(t, "metrics-user")
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics/summary?from=2026-10-16T00:00:00Z&to=2026-10-16T12:00:00Z&interval=6h", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var payload struct {
+		TotalRequests int64     `json:"total_requests"`
+		SuccessRate   float64   `json:"success_rate"`
+		From          time.Time `json:"from"`
+		To            time.Time `json:"to"`
+		Interval      string    `json:"interval"`
+		Series        []struct {
+			Start         time.Time `json:"start"`
+			TotalRequests int64     `json:"total_requests"`
+			AverageScore  float64   `json:"average_score"`
+		} `json:"series"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(payload.Series) != 2 || payload.TotalRequests != 4 || payload.SuccessRate != 0.5 || payload.Interval != "6h" || payload.Series[1].Start.Hour() != 6 || payload.Series[1].AverageScore != 0.5 || payload.To.Hour() != 12 {
+		t.Fatalf("unexpected series %+v", payload)
+	}
+
+	for query, parameter := range map[string]string{
+		"?interval=30m": "interval",
+		"?interval=1h&from=2026-01-01&to=2026-03-01": "interval",
+		"?from=yesterday":                "from",
+		"?from=2026-10-16&to=2026-10-15": "to",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics/summary"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		if resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), `"parameter":"`+parameter+`"`) {
+			t.Fatalf("%s: expected 400 for %s, got %d: %s", query, parameter, resp.Code, resp.Body.String())
+		}
+	}
+}
+
+func TestReadOnlyTokensCannotVerify(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, &verifyStubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.91}}, zap.NewNop())
+	router := gin.New()
+	RegisterRoutes(router, uc, auth.JWTMiddleware(testJWTSecret, ""))
+	claims := jwt.MapClaims{"sub": "user-1", "scope": "verify:read", "exp": time.Now().Add(time.Hour).Unix()}
+	readOnly, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	verify := func(token string) *httptest.ResponseRecorder {
+		body, contentType := buildMultipartBody(t, "image/png", fakeImage("image/png", []byte("payload")))
+		req := httptest.NewRequest(http.MethodPost, "/verify", body)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := verify(readOnly); resp.Code != http.StatusForbidden || !strings.Contains(resp.Body.String(), "verify:write") {
+		t.Fatalf("expected a read-only token to be forbidden from verifying, got %d: %s", resp.Code, resp.Body.String())
+	}
+	// Tokens without a scope claim may do everything.
+	resp := verify(buildTestToken(t, "user-1"))
+	var verified verifyResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &verified); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("expected an unscoped token to verify, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	for _, path := range []string{"/result/" + verified.RequestID, "/metrics/summary"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+readOnly)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected a read-only token to read %s, got %d: %s", path, resp.Code, resp.Body.String())
+		}
+	}
+}
+
+func TestAdminRoutesRequireTheAdminRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	uc := usecase.NewVerificationUseCase(&metricsStubRepository{}, &metricsStubCache{}, &metricsStubProcessor{}, zap.NewNop())
+	opts := DefaultOptions()
+	opts.AdminRole = "admin"
+	RegisterRoutesWithOptions(router, uc, auth.JWTMiddleware(testJWTSecret, ""), opts)
+
+	claims := jwt.MapClaims{"sub": "admin-1", "roles": []string{"admin"}, "exp": time.Now().Add(time.Hour).Unix()}
+	adminToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := get("/admin/logs", buildTestToken(t, "user-1")); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected a token without the role to be forbidden, got %d", resp.Code)
+	}
+
+	resp := get("/admin/logs?min_score=0.5&limit=1", adminToken)
+	var logs struct {
+		Logs []struct {
+			RequestID string `json:"request_id"`
+			UserID    string `json:"user_id"`
+		} `json:"logs"`
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &logs); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", resp.Code, resp.Body.String())
+	}
+	if len(logs.Logs) != 1 || logs.Logs[0].RequestID != "req-1" || logs.Logs[0].UserID != "user-1" || logs.NextCursor != "" {
+		t.Fatalf("expected the log of user-1 above the minimum score, got %s", resp.Body.String())
+	}
+	if resp := get("/admin/logs?min_score=0.9&max_score=0.1", adminToken); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected an inverted score range to be rejected, got %d", resp.Code)
+	}
+
+	resp = get("/admin/metrics", adminToken)
+	var metrics struct {
+		Users []struct {
+			UserID        string  `json:"user_id"`
+			TotalRequests int64   `json:"total_requests"`
+			SuccessRate   float64 `json:"success_rate"`
+		} `json:"users"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &metrics); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", resp.Code, resp.Body.String())
+	}
+	if len(metrics.Users) != 1 || metrics.Users[0].UserID != "user-1" || metrics.Users[0].TotalRequests != 4 || metrics.Users[0].SuccessRate != 0.75 {
+		t.Fatalf("unexpected per-user metrics %s", resp.Body.String())
+	}
+}
+
+func TestVariantMetricsCompareExperimentVariants(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	uc := usecase.NewVerificationUseCase(&metricsStubRepository{}, &metricsStubCache{}, &metricsStubProcessor{}, zap.NewNop())
+	RegisterAdminRoutes(router, uc)
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/admin/api/metrics/variants", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.Code)
+	}
+	var payload struct {
+		Variants []struct {
+			Variant       string  `json:"variant"`
+			TotalRequests int64   `json:"total_requests"`
+			SuccessRate   float64 `json:"success_rate"`
+		} `json:"variants"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(payload.Variants) != 2 || payload.Variants[0].Variant != "candidate" || payload.Variants[1].TotalRequests != 3 || payload.Variants[1].SuccessRate != 2.0/3 {
+		t.Fatalf("unexpected variants %s", resp.Body.String())
+	}
+}
+
 func TestVerifyEndpointReturnsMetadata(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -133,7 +391,7 @@ func TestVerifyEndpointReturnsMetadata(t *testing.T) {
 	RegisterRoutes(router, uc, auth.JWTMiddleware(testJWTSecret, ""))
 
 	token := buildTestToken(t, "metadata-user")
-	body, contentType := buildMultipartBody(t, "image/png", []byte("payload"))
+	body, contentType := buildMultipartBody(t, "image/png", fakeImage("image/png", []byte("payload")))
 
 	req := httptest.NewRequest(http.MethodPost, "/verify", body)
 	req.Header.Set("Content-Type", contentType)
@@ -182,109 +440,1980 @@ func TestVerifyEndpointReturnsMetadata(t *testing.T) {
 	}
 }
 
-func buildMultipartBody(t *testing.T, contentType string, payload []byte) (*bytes.Buffer, string) {
-	t.Helper()
+func TestVersionEndpointReportsBuildInfo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+	router := gin.New()
+	RegisterRoutes(router, &usecase.VerificationUseCase{}, auth.JWTMiddleware(testJWTSecret, ""))
 
-	header := make(textproto.MIMEHeader)
-	header.Set("Content-Disposition", `form-data; name="image"; filename="upload"`)
-	header.Set("Content-Type", contentType)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.Code)
+	}
 
-	part, err := writer.CreatePart(header)
-	if err != nil {
-		t.Fatalf("failed to create multipart part: %v", err)
+	var payload map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
 	}
-	if _, err := part.Write(payload); err != nil {
-		t.Fatalf("failed to write payload: %v", err)
+	for _, key := range []string{"version", "commit", "build_time", "go_version"} {
+		if _, ok := payload[key]; !ok {
+			t.Fatalf("expected %q in response, got %v", key, payload)
+		}
 	}
+}
 
-	if err := writer.Close(); err != nil {
-		t.Fatalf("failed to close writer: %v", err)
-	}
+func TestNewHandlerMountsUnderBasePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-	return body, writer.FormDataContentType()
-}
+	var sawRequest bool
+	handler := NewHandler(&usecase.VerificationUseCase{}, auth.JWTMiddleware(testJWTSecret, ""), Options{
+		MaxUploadSize: MaxUploadSize,
+		BasePath:      "/api",
+		Middleware: []gin.HandlerFunc{func(c *gin.Context) {
+			sawRequest = true
+			c.Next()
+		}},
+	})
 
-func buildTestToken(t *testing.T, subject string) string {
-	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("/api/", handler)
 
-	claims := jwt.RegisteredClaims{
-		Subject:   subject,
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.Code)
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString([]byte(testJWTSecret))
-	if err != nil {
-		t.Fatalf("failed to sign token: %v", err)
+	if !sawRequest {
+		t.Fatal("expected custom middleware to run")
+	}
+
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/api/verify", nil))
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected protected route under the prefix to require auth, got %d", resp.Code)
 	}
-	return signed
 }
 
-type metricsStubRepository struct{}
+func TestErrorsUseTheSchemaWithCodes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-func (metricsStubRepository) SaveLog(ctx context.Context, log *repository.VerificationLog) error {
-	return nil
-}
-func (metricsStubRepository) FindByRequestIDAndUser(ctx context.Context, requestID, userID string) (*repository.VerificationLog, error) {
-	return nil, errors.New("not implemented")
-}
-func (metricsStubRepository) FindDuplicatesByHash(ctx context.Context, userID, hash, excludeRequestID string) ([]*repository.VerificationLog, error) {
-	return nil, errors.New("not implemented")
-}
-func (metricsStubRepository) AggregateMetrics(ctx context.Context) (*repository.MetricsAggregation, error) {
-	return &repository.MetricsAggregation{
-		TotalCount:                 4,
-		SuccessCount:               3,
-		AverageScore:               0.82,
-		AverageProcessingLatencyMs: 87.5,
-	}, nil
+	handler := NewHandler(&usecase.VerificationUseCase{}, auth.JWTMiddleware(testJWTSecret, ""), DefaultOptions())
+	for _, tc := range []struct {
+		name    string
+		request func() *http.Request
+		status  int
+		code    httperr.Code
+		details map[string]interface{}
+	}{
+		{"unauthorized", func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/verify", nil)
+		}, http.StatusUnauthorized, httperr.CodeUnauthorized, nil},
+		{"unsupported media type", func() *http.Request {
+			body, contentType := buildMultipartBody(t, "text/plain", []byte("hello"))
+			req := httptest.NewRequest(http.MethodPost, "/verify", body)
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+			return req
+		}, http.StatusUnsupportedMediaType, httperr.CodeUnsupportedMediaType, nil},
+		{"missing image", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/verify", nil)
+			req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+			return req
+		}, http.StatusBadRequest, httperr.CodeInvalidRequest, map[string]interface{}{"parameter": "image"}},
+		{"unknown route", func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/nope", nil)
+		}, http.StatusNotFound, httperr.CodeNotFound, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := tc.request()
+			req.Header.Set("X-Request-ID", "trace-42")
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			if resp.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, resp.Code, resp.Body.String())
+			}
+			var body httperr.Response
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode error: %v", err)
+			}
+			if body.Code != tc.code || body.Message == "" || body.RequestID != "trace-42" {
+				t.Fatalf("unexpected error body %s", resp.Body.String())
+			}
+			if fmt.Sprint(body.Details) != fmt.Sprint(tc.details) {
+				t.Fatalf("expected details %v, got %v", tc.details, body.Details)
+			}
+			if got := resp.Header().Get("X-Request-ID"); got != "trace-42" {
+				t.Fatalf("expected the request ID to be echoed, got %q", got)
+			}
+		})
+	}
 }
 
-type verifyStubRepository struct{}
+func TestReadinessEndpointReflectsDependencyChecks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-func (verifyStubRepository) SaveLog(ctx context.Context, log *repository.VerificationLog) error {
-	return nil
-}
+	redisErr := errors.New("connection refused")
+	checker := health.NewChecker(time.Second)
+	checker.Register("redis", func(context.Context) error { return redisErr })
 
-func (verifyStubRepository) FindByRequestIDAndUser(ctx context.Context, requestID, userID string) (*repository.VerificationLog, error) {
-	return nil, errors.New("not implemented")
-}
+	router := gin.New()
+	RegisterReadinessRoutes(router, checker)
 
-func (verifyStubRepository) FindDuplicatesByHash(ctx context.Context, userID, hash, excludeRequestID string) ([]*repository.VerificationLog, error) {
-	return nil, errors.New("not implemented")
-}
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, resp.Code)
+	}
+	var report health.Report
+	if err := json.Unmarshal(resp.Body.Bytes(), &report); err != nil || report.Components["redis"].Status != health.StatusDown || report.Components["redis"].Error != "connection refused" {
+		t.Fatalf("expected redis to be reported down, got %s (%v)", resp.Body.String(), err)
+	}
 
-func (verifyStubRepository) AggregateMetrics(ctx context.Context) (*repository.MetricsAggregation, error) {
-	return &repository.MetricsAggregation{}, nil
+	redisErr = nil
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status %d once dependencies recover, got %d", http.StatusOK, resp.Code)
+	}
 }
 
-type verifyStubCache struct{}
+func TestResultImageReturnsSignedURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-func (verifyStubCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	return nil
+	repo := imageStubRepository{logs: map[string]*repository.VerificationLog{
+		"with-image":    {RequestID: "with-image", UserID: "user-123", ImageKey: "user-123/with-image"},
+		"without-image": {RequestID: "without-image", UserID: "user-123"},
+	}}
+	uc := usecase.NewVerificationUseCase(repo, verifyStubCache{}, verifyStubProcessor{}, zap.NewNop())
+	uc.SetImageStore(imageStubStore{})
+	router := gin.New()
+	RegisterRoutes(router, uc, auth.JWTMiddleware(testJWTSecret, ""))
+	token := buildTestToken(t, "user-123")
+
+	for _, tc := range []struct {
+		id   string
+		code int
+	}{{"with-image", http.StatusOK}, {"without-image", http.StatusNotFound}, {"unknown", http.StatusNotFound}} {
+		req := httptest.NewRequest(http.MethodGet, "/result/"+tc.id+"/image", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		if resp.Code != tc.code {
+			t.Fatalf("%s: expected status %d, got %d: %s", tc.id, tc.code, resp.Code, resp.Body.String())
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var body struct {
+			URL       string    `json:"url"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if body.URL != "https://images.example/user-123/with-image" || body.ExpiresAt.IsZero() {
+			t.Fatalf("unexpected response %+v", body)
+		}
+		if resp.Header().Get("Cache-Control") != "no-store" {
+			t.Fatal("expected signed URLs not to be cached")
+		}
+	}
 }
 
-func (verifyStubCache) Get(ctx context.Context, key string) (string, error) { return "", redis.Nil }
+func TestLocalImageURLsServeTheStoredImage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-type verifyStubProcessor struct {
-	result *imageprocessor.Result
+	images, err := storage.NewLocal(storage.LocalOptions{
+		Dir:        t.TempDir(),
+		BaseURL:    "https://api.example.com/images",
+		SigningKey: []byte("0123456789abcdef0123456789abcdef"),
+	})
+	if err != nil {
+		t.Fatalf("NewLocal returned error: %v", err)
+	}
+	png := []byte("\x89PNG\r\n\x1a\nimage")
+	if err := images.Put(context.Background(), "user-123/req-1", "image/png", bytes.NewReader(png), int64(len(png))); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	signed, err := images.SignedURL(context.Background(), "user-123/req-1", time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL returned error: %v", err)
+	}
+	uc := usecase.NewVerificationUseCase(verifyStubRepository{}, verifyStubCache{}, verifyStubProcessor{}, zap.NewNop())
+	opts := DefaultOptions()
+	opts.LocalImages = images
+	router := gin.New()
+	RegisterRoutesWithOptions(router, uc, auth.JWTMiddleware(testJWTSecret, ""), opts)
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(signed, "https://api.example.com"), nil))
+	if resp.Code != http.StatusOK || !bytes.Equal(resp.Body.Bytes(), png) || resp.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("expected the stored png, got %d %q: %q", resp.Code, resp.Header().Get("Content-Type"), resp.Body.String())
+	}
+
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/images/user-123/req-1?expires=9999999999&signature=forged", nil))
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("expected a forged signature to be refused with 403, got %d", resp.Code)
+	}
 }
 
-func (v verifyStubProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
-	return v.result, nil
+func TestWebhookRoutesManageEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	webhookRepo := repository.NewWebhookRepository(db, zap.NewNop())
+	if err := webhookRepo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	redisServer, redisClient, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer redisServer.Close()
+	defer redisClient.Close()
+	service := webhooks.NewService(webhookRepo, worker.NewQueue(redisClient), zap.NewNop())
+
+	handler := NewHandler(&usecase.VerificationUseCase{}, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize, Webhooks: service})
+	do := func(method, path, subject, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, subject))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := do(http.MethodPost, "/webhooks", "user-123", `{"url":"http://example.com/hook"}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected plain http to be rejected, got %d", resp.Code)
+	}
+	resp := do(http.MethodPost, "/webhooks", "user-123", `{"url":"https://example.com/hook","events":["verification.needs_review"]}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var created struct {
+		ID     string   `json:"id"`
+		Secret string   `json:"secret"`
+		Events []string `json:"events"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if created.Secret == "" || len(created.Events) != 1 {
+		t.Fatalf("unexpected response %s", resp.Body.String())
+	}
+
+	resp = do(http.MethodGet, "/webhooks", "user-123", "")
+	if resp.Code != http.StatusOK || !bytes.Contains(resp.Body.Bytes(), []byte(created.ID)) || bytes.Contains(resp.Body.Bytes(), []byte(created.Secret)) {
+		t.Fatalf("expected the endpoint to be listed without its secret, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := do(http.MethodGet, "/webhooks/"+created.ID+"/deliveries", "user-123", ""); resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.Code)
+	}
+	if resp := do(http.MethodDelete, "/webhooks/"+created.ID, "user-456", ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected other users to get 404, got %d", resp.Code)
+	}
+	if resp := do(http.MethodDelete, "/webhooks/"+created.ID, "user-123", ""); resp.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", resp.Code)
+	}
 }
 
-type metricsStubCache struct{}
+func TestUsageRoutesReportOwnUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-func (metricsStubCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	return nil
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	usageRepo := repository.NewUsageRepository(db, zap.NewNop())
+	if err := usageRepo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	meter := metering.NewMeter(usageRepo, nil, zap.NewNop())
+	for _, userID := range []string{"user-123", "user-123", "user-456"} {
+		event := usecase.Event{Type: usecase.EventVerificationCompleted, UserID: userID, CreatedAt: time.Now()}
+		if err := meter.Publish(context.Background(), event); err != nil {
+			t.Fatalf("Publish returned error: %v", err)
+		}
+	}
+
+	handler := NewHandler(&usecase.VerificationUseCase{}, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize, Usage: meter})
+	do := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := do("/usage")
+	var body struct {
+		Usage []struct {
+			UserID string `json:"user_id"`
+			Units  int64  `json:"units"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Code != http.StatusOK || len(body.Usage) != 1 || body.Usage[0].UserID != "user-123" || body.Usage[0].Units != 2 {
+		t.Fatalf("unexpected usage %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = do("/usage?format=csv")
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "text/csv; charset=utf-8" || !bytes.HasPrefix(resp.Body.Bytes(), []byte("user_id,period,units")) {
+		t.Fatalf("unexpected csv %d: %s", resp.Code, resp.Body.String())
+	}
+	for _, path := range []string{"/usage?from=2024-13", "/usage?from=2024-05&to=2024-01", "/usage?format=xml"} {
+		if resp := do(path); resp.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", path, resp.Code)
+		}
+	}
 }
-func (metricsStubCache) Get(ctx context.Context, key string) (string, error) { return "", redis.Nil }
 
-type metricsStubProcessor struct{}
+func TestGraphQLSelectsFieldsAndPages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-func (metricsStubProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
-	return &imageprocessor.Result{}, nil
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	ctx := context.Background()
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	for i, userID := range []string{"user-123", "user-123", "user-123", "user-456"} {
+		log := &repository.VerificationLog{RequestID: fmt.Sprintf("r%d", i+1), UserID: userID, SHA1Hash: fmt.Sprintf("hash-%d", i), Score: 0.5, CreatedAt: time.Now()}
+		if i == 2 {
+			log.Categories = []repository.VerificationCategory{{Category: "nsfw", Score: 0.9, Threshold: 0.5, Flagged: true}}
+		}
+		if err := repo.SaveLog(ctx, log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+	redisServer, redisClient, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer redisServer.Close()
+	defer redisClient.Close()
+	uc := usecase.NewVerificationUseCase(repo, usecase.NewRedisCache(redisClient), nil, zap.NewNop())
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), DefaultOptions())
+	do := func(req *http.Request) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		var body map[string]interface{}
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response %s: %v", resp.Body.String(), err)
+		}
+		return resp, body
+	}
+	post := func(query string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		payload, _ := json.Marshal(map[string]string{"query": query})
+		return do(httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(payload)))
+	}
+
+	resp, body := post(`{
+		verifications(first: 2) {
+			nodes { requestId categories { category flagged } duplicates { requestId } }
+			pageInfo { hasNextPage endCursor }
+		}
+		metrics { totalRequests }
+	}`)
+	data, _ := body["data"].(map[string]interface{})
+	page, _ := data["verifications"].(map[string]interface{})
+	nodes, _ := page["nodes"].([]interface{})
+	pageInfo, _ := page["pageInfo"].(map[string]interface{})
+	if resp.Code != http.StatusOK || body["errors"] != nil || len(nodes) != 2 || pageInfo["hasNextPage"] != true {
+		t.Fatalf("unexpected first page %d: %s", resp.Code, resp.Body.String())
+	}
+	newest := nodes[0].(map[string]interface{})
+	if newest["requestId"] != "r3" || len(newest["categories"].([]interface{})) != 1 || len(newest["duplicates"].([]interface{})) != 0 || len(newest) != 3 {
+		t.Fatalf("unexpected node %+v", newest)
+	}
+	if data["metrics"].(map[string]interface{})["totalRequests"] != float64(4) {
+		t.Fatalf("unexpected metrics %+v", data["metrics"])
+	}
+
+	query := url.Values{
+		"query":     {`query Next($after: String) { verifications(after: $after) { nodes { requestId } pageInfo { hasNextPage endCursor } } }`},
+		"variables": {fmt.Sprintf(`{"after": %q}`, pageInfo["endCursor"])},
+	}
+	resp, _ = do(httptest.NewRequest(http.MethodGet, "/graphql?"+query.Encode(), nil))
+	if want := `{"data":{"verifications":{"nodes":[{"requestId":"r1"}],"pageInfo":{"hasNextPage":false,"endCursor":null}}}}`; resp.Code != http.StatusOK || resp.Body.String() != want {
+		t.Fatalf("unexpected last page %d: %s", resp.Code, resp.Body.String())
+	}
+
+	// Other users' verifications are not found, without failing the rest of the query.
+	resp, _ = post(`{ verification(requestId: "r4") { requestId } own: verification(requestId: "r1") { userId } }`)
+	if want := `{"errors":[{"message":"result not found","locations":[{"line":1,"column":3}],"path":["verification"]}],"data":{"verification":null,"own":{"userId":"user-123"}}}`; resp.Code != http.StatusOK || resp.Body.String() != want {
+		t.Fatalf("unexpected response %d: %s", resp.Code, resp.Body.String())
+	}
+
+	for _, invalid := range []string{`{ verifications { nodes { imageKey } } }`, `{ verifications(first: 1000) { nodes { requestId } } }`, `{ verifications(after: "bogus") { nodes { requestId } } }`} {
+		resp, body = post(invalid)
+		if body["errors"] == nil || body["data"] != nil && body["data"].(map[string]interface{})["verifications"] != nil {
+			t.Fatalf("expected %s to fail, got %d: %s", invalid, resp.Code, resp.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/graphql/schema", nil)
+	req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+	schema := httptest.NewRecorder()
+	handler.ServeHTTP(schema, req)
+	if !strings.Contains(schema.Body.String(), "verifications(first: Int = 20, after: String): VerificationConnection!") {
+		t.Fatalf("unexpected schema %s", schema.Body.String())
+	}
+}
+
+func TestVerifyEnforcesUserQuotas(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	userRepo := repository.NewUserRepository(db, zap.NewNop())
+	if err := userRepo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	accounts := users.NewServiceWithOptions(userRepo, zap.NewNop(), users.Options{DefaultMonthlyQuota: 1})
+	processor := &verifyStubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.91}}
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, processor, zap.NewNop())
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize, Users: accounts})
+	token := buildTestToken(t, "user-123")
+
+	upload := func(payload string) *httptest.ResponseRecorder {
+		body, formType := buildMultipartBody(t, "image/png", fakeImage("image/png", []byte(payload)))
+		req := httptest.NewRequest(http.MethodPost, "/verify", body)
+		req.Header.Set("Content-Type", formType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+	if resp := upload("first"); resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	resp := upload("second")
+	var rejected httperr.Response
+	if err := json.Unmarshal(resp.Body.Bytes(), &rejected); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Code != http.StatusTooManyRequests || rejected.Code != httperr.CodeQuotaExceeded {
+		t.Fatalf("expected a 429 quota_exceeded, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/usage", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	var usage struct {
+		Quota users.Quota `json:"quota"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &usage); err != nil {
+		t.Fatalf("failed to decode usage: %v", err)
+	}
+	if resp.Code != http.StatusOK || usage.Quota.Limit != 1 || usage.Quota.Used != 1 || usage.Quota.Remaining == nil || *usage.Quota.Remaining != 0 {
+		t.Fatalf("expected the used up quota, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestSuspendedUsersAreRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	if err := repository.NewVerificationRepository(db, zap.NewNop()).AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	userRepo := repository.NewUserRepository(db, zap.NewNop())
+	if err := userRepo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	accounts := users.NewService(userRepo, zap.NewNop())
+	handler := NewHandler(&usecase.VerificationUseCase{}, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize, Users: accounts})
+	admin := gin.New()
+	RegisterUserAdminRoutes(admin, accounts)
+
+	adminDo := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		admin.ServeHTTP(resp, req)
+		return resp
+	}
+	fetchResult := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/result/missing", nil)
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	if resp := adminDo(http.MethodPut, "/admin/api/users/user-123/plan", `{"tier":"Gold Plus"}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid tier to be rejected, got %d", resp.Code)
+	}
+	if resp := adminDo(http.MethodPost, "/admin/api/users/user-123/suspend", `{"reason":"abuse"}`); resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if code := fetchResult(); code != http.StatusForbidden {
+		t.Fatalf("expected a suspended user to get 403, got %d", code)
+	}
+	resp := adminDo(http.MethodGet, "/admin/api/users", "")
+	if resp.Code != http.StatusOK || !bytes.Contains(resp.Body.Bytes(), []byte(`"id":"user-123"`)) || !bytes.Contains(resp.Body.Bytes(), []byte(`"suspended":true`)) {
+		t.Fatalf("expected the suspended user to be listed, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if resp := adminDo(http.MethodPost, "/admin/api/users/user-123/unsuspend", ""); resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.Code)
+	}
+	if code := fetchResult(); code == http.StatusForbidden {
+		t.Fatal("expected the user to be allowed again after unsuspending")
+	}
+}
+
+func TestFeedbackRoutesDisputeResultsForReview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	logs := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := logs.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	disputeRepo := repository.NewDisputeRepository(db, zap.NewNop())
+	if err := disputeRepo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	if err := logs.SaveLog(context.Background(), &repository.VerificationLog{RequestID: "req-1", UserID: "user-123", SHA1Hash: "hash-1", Score: 0.2}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	feedback := disputes.NewService(disputeRepo, zap.NewNop())
+	handler := NewHandler(&usecase.VerificationUseCase{}, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize, Disputes: feedback})
+	admin := gin.New()
+	RegisterDisputeAdminRoutes(admin, feedback)
+
+	do := func(router http.Handler, method, path, subject, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if subject != "" {
+			req.Header.Set("Authorization", "Bearer "+buildTestToken(t, subject))
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := do(handler, http.MethodPost, "/result/req-1/feedback", "user-123", `{"reason":""}`); resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), `"parameter":"reason"`) {
+		t.Fatalf("expected a missing reason to be rejected, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := do(handler, http.MethodPost, "/result/req-1/feedback", "someone-else", `{"reason":"wrong"}`); resp.Code != http.StatusNotFound || !strings.Contains(resp.Body.String(), `"result_not_found"`) {
+		t.Fatalf("expected another user's result to be hidden, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := do(handler, http.MethodPost, "/result/req-1/feedback", "user-123", `{"reason":"it is a real photo"}`); resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := do(handler, http.MethodPost, "/result/req-1/feedback", "user-123", `{"reason":"again"}`); resp.Code != http.StatusConflict {
+		t.Fatalf("expected a second dispute to conflict, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp := do(admin, http.MethodGet, "/admin/api/review-queue", "", "")
+	var queue disputes.Page
+	if err := json.Unmarshal(resp.Body.Bytes(), &queue); err != nil || len(queue.Items) != 1 || queue.Items[0].Reason != "it is a real photo" {
+		t.Fatalf("unexpected review queue %d: %s", resp.Code, resp.Body.String())
+	}
+	path := fmt.Sprintf("/admin/api/disputes/%d/state", queue.Items[0].ID)
+	if resp := do(admin, http.MethodPost, path, "", `{"state":"closed"}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown state to be rejected, got %d", resp.Code)
+	}
+	if resp := do(admin, http.MethodPost, path, "", `{"state":"accepted","note":"false positive"}`); resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := do(handler, http.MethodGet, "/result/req-1/feedback", "user-123", ""); resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `"state":"accepted"`) {
+		t.Fatalf("expected the user to see the resolution, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = do(admin, http.MethodGet, "/admin/api/disputes/export?format=csv", "", "")
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "text/csv" || !strings.Contains(resp.Body.String(), "req-1,hash-1") {
+		t.Fatalf("unexpected export %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := do(admin, http.MethodGet, "/admin/api/disputes/export?format=xml", "", ""); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown format to be rejected, got %d", resp.Code)
+	}
+}
+
+func TestMetricsStreamPushesLiveUpdates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := livemetrics.NewRecorder()
+	feed := livemetrics.NewFeed(recorder, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go feed.Run(ctx)
+
+	handler := NewHandler(&usecase.VerificationUseCase{}, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize, LiveMetrics: feed})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics/stream", nil)
+	req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected a plain request to be rejected, got %d", resp.Code)
+	}
+
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/metrics/stream", server.URL)
+	if err != nil {
+		t.Fatalf("NewConfig returned error: %v", err)
+	}
+	if _, err := websocket.DialConfig(config); err == nil {
+		t.Fatal("expected the stream to require a token")
+	}
+	config.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatalf("DialConfig returned error: %v", err)
+	}
+	defer conn.Close()
+
+	recorder.ObserveVerification("", true, 25*time.Millisecond)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		var update livemetrics.Update
+		if err := websocket.JSON.Receive(conn, &update); err != nil {
+			t.Fatalf("Receive returned error: %v", err)
+		}
+		if update.TotalRequests == 1 {
+			break
+		}
+	}
+
+	// Stopping the feed ends the stream.
+	cancel()
+	var update livemetrics.Update
+	for websocket.JSON.Receive(conn, &update) == nil {
+	}
+}
+
+func TestSimilarSearchFindsEarlierVerificationsOfTheImage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	processor := &verifyStubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.91}}
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, processor, zap.NewNop())
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize})
+
+	img := image.NewGray(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8((x*x + y*3) % 256)})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatalf("png.Encode returned error: %v", err)
+	}
+	upload := func(path, contentType string, payload []byte) *httptest.ResponseRecorder {
+		body, formType := buildMultipartBody(t, contentType, payload)
+		req := httptest.NewRequest(http.MethodPost, path, body)
+		req.Header.Set("Content-Type", formType)
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	verified := upload("/verify", "image/png", encoded.Bytes())
+	if verified.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", verified.Code, verified.Body.String())
+	}
+	var verification struct {
+		RequestID string `json:"request_id"`
+	}
+	_ = json.Unmarshal(verified.Body.Bytes(), &verification)
+
+	resp := upload("/search/similar", "image/png", encoded.Bytes())
+	var payload struct {
+		Results []struct {
+			RequestID  string  `json:"request_id"`
+			Distance   int     `json:"distance"`
+			Similarity float64 `json:"similarity"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", resp.Code, resp.Body.String())
+	}
+	if len(payload.Results) != 1 || payload.Results[0].RequestID != verification.RequestID || payload.Results[0].Distance != 0 || payload.Results[0].Similarity != 1 {
+		t.Fatalf("expected the earlier verification to be found, got %+v", payload.Results)
+	}
+
+	if resp := upload("/search/similar?max_distance=65", "image/png", encoded.Bytes()); resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), `"parameter":"max_distance"`) {
+		t.Fatalf("expected an out-of-range distance to be rejected, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := upload("/search/similar", "image/webp", []byte("RIFF....WEBPVP8 ")); resp.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected an unhashable image to get 415, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = upload("/search/similar?format=ndjson", "image/png", encoded.Bytes())
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "application/x-ndjson" ||
+		strings.Count(resp.Body.String(), "\n") != 1 || !strings.Contains(resp.Body.String(), verification.RequestID) {
+		t.Fatalf("expected one streamed match, got %d %q: %s", resp.Code, resp.Header().Get("Content-Type"), resp.Body.String())
+	}
+	if resp := upload("/search/similar?format=ndjson", "image/webp", []byte("RIFF....WEBPVP8 ")); resp.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected a streamed search of an unhashable image to get 415, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestResultsRenderAsCSVAndPDF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		log := &repository.VerificationLog{RequestID: fmt.Sprintf("req-%d", i), UserID: "user-123", SHA1Hash: fmt.Sprintf("hash-%d", i), Success: true, Score: 0.9}
+		if err := repo.SaveLog(context.Background(), log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, nil, zap.NewNop())
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize})
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+		req.Header.Set("Accept", accept)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := get("/history?format=csv&limit=2", "application/json")
+	rows, err := csv.NewReader(resp.Body).ReadAll()
+	if resp.Code != http.StatusOK || err != nil || len(rows) != 3 || rows[1][0] != "req-3" {
+		t.Fatalf("expected a CSV page of two verifications, got %d: %v (%v)", resp.Code, rows, err)
+	}
+	if resp.Header().Get("X-Next-Cursor") == "" || !strings.Contains(resp.Header().Get("Content-Disposition"), `filename="verifications.csv"`) {
+		t.Fatalf("expected an attachment with the next cursor, got headers %v", resp.Header())
+	}
+
+	resp = get("/result/req-1", "application/pdf")
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "application/pdf" || !bytes.HasPrefix(resp.Body.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("expected a PDF certificate, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if !bytes.Contains(resp.Body.Bytes(), []byte("(Request ID: req-1)")) {
+		t.Fatal("expected the certificate to name the request")
+	}
+	if resp := get("/result/req-1", "*/*"); resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `"request_id":"req-1"`) {
+		t.Fatalf("expected JSON by default, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := get("/result/req-1?format=xml", ""); resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), `"parameter":"format"`) {
+		t.Fatalf("expected an unknown format to be rejected, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestResultStreamSendsQueuedResultsOnceCompleted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	queued := &repository.VerificationLog{RequestID: "req-1", UserID: "user-123", SHA1Hash: "hash-1", Status: repository.StatusQueued}
+	if err := repo.SaveLog(context.Background(), queued); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	redisServer, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer redisServer.Close()
+	defer client.Close()
+	hub := resultstream.New(client)
+
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, nil, zap.NewNop())
+	server := httptest.NewServer(NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize, ResultStream: hub}))
+	defer server.Close()
+	stream := func(requestID string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/result/"+requestID+"/stream", nil)
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET returned error: %v", err)
+		}
+		return resp
+	}
+
+	if resp := stream("unknown"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected an unknown result to be not found, got %d", resp.StatusCode)
+	}
+	resp := stream("req-1")
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected server-sent events, got %s", resp.Header.Get("Content-Type"))
+	}
+	events := bufio.NewScanner(resp.Body)
+	next := func() resultResponse {
+		t.Helper()
+		for events.Scan() {
+			if data, ok := strings.CutPrefix(events.Text(), "data:"); ok {
+				var result resultResponse
+				if err := json.Unmarshal([]byte(data), &result); err != nil {
+					t.Fatalf("decode event: %v", err)
+				}
+				return result
+			}
+		}
+		t.Fatalf("expected another event: %v", events.Err())
+		return resultResponse{}
+	}
+
+	if result := next(); result.RequestID != "req-1" || result.Status != repository.StatusQueued {
+		t.Fatalf("expected the queued result first, got %+v", result)
+	}
+	queued.Success, queued.Score = true, 0.9
+	if err := repo.CompleteQueued(context.Background(), queued); err != nil {
+		t.Fatalf("CompleteQueued returned error: %v", err)
+	}
+	if err := hub.Publish(context.Background(), usecase.Event{Type: usecase.EventVerificationCompleted, Data: usecase.VerificationEvent{RequestID: "req-1"}}); err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+	if result := next(); result.Status != repository.StatusCompleted || result.Score != 0.9 {
+		t.Fatalf("expected the completed result, got %+v", result)
+	}
+	for events.Scan() {
+		if strings.HasPrefix(events.Text(), "data:") {
+			t.Fatalf("expected the stream to end after the final result, got %s", events.Text())
+		}
+	}
+}
+
+func TestHistoryExportStreamsEveryVerification(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		log := &repository.VerificationLog{RequestID: fmt.Sprintf("req-%d", i), UserID: "user-123", SHA1Hash: fmt.Sprintf("hash-%d", i)}
+		if i == 3 {
+			log.Categories = []repository.VerificationCategory{{Category: "nsfw", Score: 0.9, Threshold: 0.5, Flagged: true}}
+		}
+		if err := repo.SaveLog(context.Background(), log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+	if err := repo.SaveLog(context.Background(), &repository.VerificationLog{RequestID: "other", UserID: "user-456", SHA1Hash: "hash-other"}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, nil, zap.NewNop())
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize})
+	export := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := export("/history/export")
+	lines := strings.Split(strings.TrimSpace(resp.Body.String()), "\n")
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "application/x-ndjson" || len(lines) != 3 {
+		t.Fatalf("expected three NDJSON lines, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var newest struct {
+		RequestID  string `json:"request_id"`
+		Categories []struct {
+			Category string `json:"category"`
+			Flagged  bool   `json:"flagged"`
+		} `json:"categories"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &newest); err != nil || newest.RequestID != "req-3" || len(newest.Categories) != 1 || !newest.Categories[0].Flagged {
+		t.Fatalf("expected the newest verification first with its categories, got %s (%v)", lines[0], err)
+	}
+
+	resp = export("/history/export?format=json")
+	var payload struct {
+		Verifications []struct {
+			RequestID string `json:"request_id"`
+		} `json:"verifications"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil || len(payload.Verifications) != 3 || payload.Verifications[2].RequestID != "req-1" {
+		t.Fatalf("expected a JSON array of three verifications, got %s (%v)", resp.Body.String(), err)
+	}
+
+	if resp := export("/history/export?format=xml"); resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), `"parameter":"format"`) {
+		t.Fatalf("expected an unknown format to be rejected, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestHistoryPagesAndFiltersVerifications(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= 4; i++ {
+		log := &repository.VerificationLog{
+			RequestID: fmt.Sprintf("req-%d", i),
+			UserID:    "user-123",
+			SHA1Hash:  fmt.Sprintf("hash-%d", i),
+			Success:   i%2 == 0,
+			CreatedAt: day.AddDate(0, 0, i),
+		}
+		if err := repo.SaveLog(context.Background(), log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+	if err := repo.SaveLog(context.Background(), &repository.VerificationLog{RequestID: "other", UserID: "user-456", SHA1Hash: "hash-other"}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, nil, zap.NewNop())
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize})
+	type historyPage struct {
+		Verifications []struct {
+			RequestID string `json:"request_id"`
+		} `json:"verifications"`
+		NextCursor string `json:"next_cursor"`
+	}
+	history := func(query string) (*httptest.ResponseRecorder, historyPage) {
+		req := httptest.NewRequest(http.MethodGet, "/history"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		var page historyPage
+		if resp.Code == http.StatusOK {
+			if err := json.Unmarshal(resp.Body.Bytes(), &page); err != nil {
+				t.Fatalf("failed to decode %s: %v", resp.Body.String(), err)
+			}
+		}
+		return resp, page
+	}
+	requestIDs := func(page historyPage) string {
+		ids := make([]string, 0, len(page.Verifications))
+		for _, verification := range page.Verifications {
+			ids = append(ids, verification.RequestID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	_, page := history("?limit=3")
+	if requestIDs(page) != "req-4,req-3,req-2" || page.NextCursor == "" {
+		t.Fatalf("expected the three newest verifications and a cursor, got %+v", page)
+	}
+	_, page = history("?limit=3&cursor=" + page.NextCursor)
+	if requestIDs(page) != "req-1" || page.NextCursor != "" {
+		t.Fatalf("expected the last verification without a cursor, got %+v", page)
+	}
+
+	// A to day includes the whole day.
+	_, page = history("?from=2024-05-03&to=2024-05-05&success=true")
+	if requestIDs(page) != "req-4,req-2" {
+		t.Fatalf("expected the successful verifications of the range, got %+v", page)
+	}
+	_, page = history("?from=2024-05-04T12:00:00Z&success=false")
+	if requestIDs(page) != "req-3" {
+		t.Fatalf("expected the failed verifications since the timestamp, got %+v", page)
+	}
+
+	for query, parameter := range map[string]string{
+		"?cursor=bogus":                  "cursor",
+		"?limit=0":                       "limit",
+		"?from=yesterday":                "from",
+		"?from=2024-05-03&to=2024-05-02": "to",
+		"?success=maybe":                 "success",
+	} {
+		if resp, _ := history(query); resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), `"parameter":"`+parameter+`"`) {
+			t.Fatalf("expected %s to be rejected for %s, got %d: %s", query, parameter, resp.Code, resp.Body.String())
+		}
+	}
+}
+
+func TestTenantSettingsApplyToUploads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	tenantRepo := repository.NewTenantRepository(db, zap.NewNop())
+	if err := tenantRepo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	server, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer server.Close()
+	defer client.Close()
+	store := tenants.NewStore(tenantRepo, client, zap.NewNop())
+
+	processor := &verifyStubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.91}}
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, processor, zap.NewNop())
+	uc.SetTenantPolicies(store)
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize, Tenants: store})
+	admin := gin.New()
+	RegisterTenantAdminRoutes(admin, store, nil)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/admin/api/tenants/acme", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		admin.ServeHTTP(resp, req)
+		return resp
+	}
+	if resp := put(`{"review_threshold":2}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected out-of-range settings to be rejected, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := put(`{"allowed_content_types":["image/png"],"monthly_quota":1}`); resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	upload := func(token, contentType, payload string) *httptest.ResponseRecorder {
+		body, formType := buildMultipartBody(t, contentType, fakeImage(contentType, []byte(payload)))
+		req := httptest.NewRequest(http.MethodPost, "/verify", body)
+		req.Header.Set("Content-Type", formType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+	claims := jwt.MapClaims{"sub": "user-123", "tenant": "acme", "exp": time.Now().Add(time.Hour).Unix()}
+	tenantToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	if resp := upload(tenantToken, "image/jpeg", "image-1"); resp.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected a type the tenant does not accept to get 415, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := upload(tenantToken, "image/png", "image-1"); resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := upload(tenantToken, "image/png", "image-2"); resp.Code != http.StatusTooManyRequests || !strings.Contains(resp.Body.String(), `"quota_exceeded"`) {
+		t.Fatalf("expected the exhausted quota to get 429, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := upload(buildTestToken(t, "user-123"), "image/jpeg", "image-2"); resp.Code != http.StatusOK {
+		t.Fatalf("expected users without a tenant to be unaffected, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestMetricsAreLimitedToTheCallersTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	for i, tenantID := range []string{"acme", "acme", "globex"} {
+		log := &repository.VerificationLog{
+			RequestID: fmt.Sprintf("req-%d", i),
+			UserID:    fmt.Sprintf("user-%d", i),
+			TenantID:  tenantID,
+			SHA1Hash:  fmt.Sprintf("hash-%d", i),
+			Success:   true,
+			CreatedAt: time.Now(),
+		}
+		if err := repo.SaveLog(context.Background(), log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, nil, zap.NewNop())
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize})
+	admin := gin.New()
+	RegisterAdminRoutes(admin, uc)
+
+	summary := func(router http.Handler, path, tenantID string) int64 {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if tenantID != "" {
+			claims := jwt.MapClaims{"sub": "user-0", "tenant": tenantID, "exp": time.Now().Add(time.Hour).Unix()}
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+			if err != nil {
+				t.Fatalf("failed to sign token: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+		}
+		var body struct {
+			TotalRequests int64 `json:"total_requests"`
+		}
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return body.TotalRequests
+	}
+	from := url.QueryEscape(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	for _, path := range []string{"/metrics/summary", "/metrics/summary?from=" + from} {
+		if total := summary(handler, path, "acme"); total != 2 {
+			t.Fatalf("expected acme to see its 2 verifications at %s, got %d", path, total)
+		}
+		if total := summary(handler, path, "globex"); total != 1 {
+			t.Fatalf("expected globex to see its verification at %s, got %d", path, total)
+		}
+		if total := summary(handler, path, "initech"); total != 0 {
+			t.Fatalf("expected initech to see no verifications at %s, got %d", path, total)
+		}
+		if total := summary(admin, "/admin/api"+path, ""); total != 3 {
+			t.Fatalf("expected the admin listener to see every tenant at %s, got %d", path, total)
+		}
+	}
+}
+
+// fakeImage prefixes payload with the signature of contentType, so it passes for an
+// image of that type.
+func fakeImage(contentType string, payload []byte) []byte {
+	signatures := map[string]string{
+		"image/png":  "\x89PNG\r\n\x1a\n",
+		"image/jpeg": "\xff\xd8\xff",
+		"image/gif":  "GIF89a",
+		"image/webp": "RIFF\x00\x00\x00\x00WEBPVP",
+	}
+	return append([]byte(signatures[contentType]), payload...)
+}
+
+func TestVerifyRejectsContentNotMatchingItsType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	processor := &streamStubProcessor{}
+	uc := usecase.NewVerificationUseCase(verifyStubRepository{}, verifyStubCache{}, processor, zap.NewNop())
+	RegisterRoutes(router, uc, auth.JWTMiddleware(testJWTSecret, ""))
+
+	for _, upload := range []struct {
+		contentType string
+		payload     []byte
+		message     string
+	}{
+		{"image/png", []byte("MZ\x90\x00 renamed executable"), "unsupported image content"},
+		{"image/png", fakeImage("image/jpeg", []byte("jpeg")), "image content does not match its content type"},
+		{"image/png; charset=binary", []byte("\x89PNG"), "unsupported image content"},
+	} {
+		body, formType := buildMultipartBody(t, upload.contentType, upload.payload)
+		req := httptest.NewRequest(http.MethodPost, "/verify", body)
+		req.Header.Set("Content-Type", formType)
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusUnsupportedMediaType || !strings.Contains(resp.Body.String(), upload.message) {
+			t.Fatalf("expected 415 %q for %q, got %d: %s", upload.message, upload.payload, resp.Code, resp.Body.String())
+		}
+	}
+	if processor.calls != 0 {
+		t.Fatalf("expected mismatched uploads not to reach the processor, got %d calls", processor.calls)
+	}
+
+	body, formType := buildMultipartBody(t, "image/webp", fakeImage("image/webp", []byte("webp")))
+	req := httptest.NewRequest(http.MethodPost, "/verify", body)
+	req.Header.Set("Content-Type", formType)
+	req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected a matching upload to be verified, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestVerifyRejectsImagesBeyondTheLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	processor := &streamStubProcessor{}
+	uc := usecase.NewVerificationUseCase(verifyStubRepository{}, verifyStubCache{}, processor, zap.NewNop())
+	limits := &imagelimits.Limits{MaxWidth: 64, MaxHeight: 64, MaxPixels: 2048}
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize, ImageLimits: limits})
+
+	encode := func(width, height int) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+			t.Fatalf("png.Encode returned error: %v", err)
+		}
+		return buf.Bytes()
+	}
+	upload := func(payload []byte) *httptest.ResponseRecorder {
+		body, formType := buildMultipartBody(t, "image/png", payload)
+		req := httptest.NewRequest(http.MethodPost, "/verify", body)
+		req.Header.Set("Content-Type", formType)
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	for _, tc := range []struct {
+		payload []byte
+		reason  string
+	}{
+		{encode(65, 10), imagelimits.ReasonWidth},
+		{encode(64, 64), imagelimits.ReasonPixelCount},
+		{fakeImage("image/png", []byte("truncated")), imagelimits.ReasonCorrupt},
+	} {
+		resp := upload(tc.payload)
+		var body httperr.Response
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		if resp.Code != http.StatusUnprocessableEntity || body.Code != httperr.CodeUnprocessableImage || body.Details["reason"] != tc.reason {
+			t.Fatalf("expected 422 %s, got %d: %s", tc.reason, resp.Code, resp.Body.String())
+		}
+	}
+	if processor.calls != 0 {
+		t.Fatalf("expected rejected images not to reach the processor, got %d calls", processor.calls)
+	}
+	if resp := upload(encode(32, 32)); resp.Code != http.StatusOK {
+		t.Fatalf("expected an image within the limits to be verified, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func buildMultipartBody(t *testing.T, contentType string, payload []byte) (*bytes.Buffer, string) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="image"; filename="upload"`)
+	header.Set("Content-Type", contentType)
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("failed to create multipart part: %v", err)
+	}
+	if _, err := part.Write(payload); err != nil {
+		t.Fatalf("failed to write payload: %v", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+
+	return body, writer.FormDataContentType()
+}
+
+func buildTestToken(t *testing.T, subject string) string {
+	t.Helper()
+
+	claims := jwt.RegisteredClaims{
+		Subject:   subject,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+type metricsStubRepository struct{}
+
+func (metricsStubRepository) SaveLog(ctx context.Context, log *repository.VerificationLog) error {
+	return nil
+}
+func (metricsStubRepository) FindByRequestIDAndUser(ctx context.Context, requestID, userID string) (*repository.VerificationLog, error) {
+	return nil, errors.New("not implemented")
+}
+func (metricsStubRepository) FindDuplicatesByHash(ctx context.Context, userID, sha256Hash, sha1Hash, excludeRequestID string, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+	return nil, errors.New("not implemented")
+}
+func (metricsStubRepository) CountDuplicatesByHash(ctx context.Context, userID, sha256Hash, sha1Hash, excludeRequestID string) (int64, error) {
+	return 0, errors.New("not implemented")
+}
+func (metricsStubRepository) ListByUser(ctx context.Context, userID string, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+	return nil, errors.New("not implemented")
+}
+func (metricsStubRepository) FindByUser(ctx context.Context, userID string, filter repository.LogFilter, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+	return nil, errors.New("not implemented")
+}
+func (metricsStubRepository) SearchLogs(ctx context.Context, filter repository.LogFilter, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+	logs := []*repository.VerificationLog{
+		{ID: 2, RequestID: "req-2", UserID: "user-2", Score: 0.4},
+		{ID: 1, RequestID: "req-1", UserID: "user-1", Score: 0.9, Success: true},
+	}
+	var matched []*repository.VerificationLog
+	for _, log := range logs {
+		if filter.UserID != "" && log.UserID != filter.UserID || filter.MinScore != nil && log.Score < *filter.MinScore {
+			continue
+		}
+		if (beforeID == 0 || log.ID < beforeID) && len(matched) < limit {
+			matched = append(matched, log)
+		}
+	}
+	return matched, nil
+}
+func (metricsStubRepository) ListHashedByUser(ctx context.Context, userID string, afterID uint, limit int) ([]*repository.VerificationLog, error) {
+	return nil, errors.New("not implemented")
+}
+func (metricsStubRepository) AggregateMetrics(ctx context.Context) (*repository.MetricsAggregation, error) {
+	return &repository.MetricsAggregation{
+		TotalCount:                 4,
+		SuccessCount:               3,
+		AverageScore:               0.82,
+		AverageProcessingLatencyMs: 87.5,
+	}, nil
+}
+func (metricsStubRepository) AggregateMetricsSeries(ctx context.Context, from, to time.Time, interval time.Duration) ([]*repository.PeriodAggregation, error) {
+	var periods []*repository.PeriodAggregation
+	for start := from.Truncate(time.Hour); start.Before(to); start = start.Add(interval) {
+		periods = append(periods, &repository.PeriodAggregation{Start: start, MetricsAggregation: repository.MetricsAggregation{TotalCount: 2, SuccessCount: 1, AverageScore: 0.5, AverageProcessingLatencyMs: 10}})
+	}
+	return periods, nil
+}
+func (metricsStubRepository) AggregateMetricsByVariant(ctx context.Context) ([]*repository.VariantAggregation, error) {
+	return []*repository.VariantAggregation{
+		{Variant: "candidate", MetricsAggregation: repository.MetricsAggregation{TotalCount: 1, SuccessCount: 1, AverageScore: 0.9, AverageProcessingLatencyMs: 40}},
+		{Variant: "control", MetricsAggregation: repository.MetricsAggregation{TotalCount: 3, SuccessCount: 2, AverageScore: 0.8, AverageProcessingLatencyMs: 103.3}},
+	}, nil
+}
+func (metricsStubRepository) AggregateMetricsByUser(ctx context.Context, filter repository.LogFilter, afterUserID string, limit int) ([]*repository.UserAggregation, error) {
+	return []*repository.UserAggregation{
+		{UserID: "user-1", MetricsAggregation: repository.MetricsAggregation{TotalCount: 4, SuccessCount: 3, AverageScore: 0.82, AverageProcessingLatencyMs: 87.5}},
+	}, nil
+}
+func (metricsStubRepository) CompleteQueued(ctx context.Context, log *repository.VerificationLog) error {
+	return errors.New("not implemented")
+}
+func (metricsStubRepository) FailQueued(ctx context.Context, id uint, details string) error {
+	return errors.New("not implemented")
+}
+
+func (metricsStubRepository) SoftDeleteUserLogs(ctx context.Context, userID string, limit int, release func([]*repository.VerificationLog) error) (int, error) {
+	return 0, errors.New("not implemented")
+}
+
+type verifyStubRepository struct{}
+
+func (verifyStubRepository) SaveLog(ctx context.Context, log *repository.VerificationLog) error {
+	return nil
+}
+
+func (verifyStubRepository) FindByRequestIDAndUser(ctx context.Context, requestID, userID string) (*repository.VerificationLog, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (verifyStubRepository) FindDuplicatesByHash(ctx context.Context, userID, sha256Hash, sha1Hash, excludeRequestID string, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (verifyStubRepository) CountDuplicatesByHash(ctx context.Context, userID, sha256Hash, sha1Hash, excludeRequestID string) (int64, error) {
+	return 0, errors.New("not implemented")
+}
+
+func (verifyStubRepository) ListByUser(ctx context.Context, userID string, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (verifyStubRepository) FindByUser(ctx context.Context, userID string, filter repository.LogFilter, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (verifyStubRepository) SearchLogs(ctx context.Context, filter repository.LogFilter, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (verifyStubRepository) ListHashedByUser(ctx context.Context, userID string, afterID uint, limit int) ([]*repository.VerificationLog, error) {
+	return nil, nil
+}
+
+func (verifyStubRepository) AggregateMetrics(ctx context.Context) (*repository.MetricsAggregation, error) {
+	return &repository.MetricsAggregation{}, nil
+}
+
+func (verifyStubRepository) AggregateMetricsByVariant(ctx context.Context) ([]*repository.VariantAggregation, error) {
+	return nil, nil
+}
+
+func (verifyStubRepository) AggregateMetricsSeries(ctx context.Context, from, to time.Time, interval time.Duration) ([]*repository.PeriodAggregation, error) {
+	return nil, nil
+}
+
+func (verifyStubRepository) AggregateMetricsByUser(ctx context.Context, filter repository.LogFilter, afterUserID string, limit int) ([]*repository.UserAggregation, error) {
+	return nil, nil
+}
+
+func (verifyStubRepository) CompleteQueued(ctx context.Context, log *repository.VerificationLog) error {
+	return errors.New("not implemented")
+}
+
+func (verifyStubRepository) FailQueued(ctx context.Context, id uint, details string) error {
+	return errors.New("not implemented")
+}
+
+func (verifyStubRepository) SoftDeleteUserLogs(ctx context.Context, userID string, limit int, release func([]*repository.VerificationLog) error) (int, error) {
+	return 0, errors.New("not implemented")
+}
+
+type verifyStubCache struct{}
+
+func (verifyStubCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return nil
+}
+
+func (verifyStubCache) Get(ctx context.Context, key string) (string, error) { return "", redis.Nil }
+
+func (verifyStubCache) Delete(ctx context.Context, keys ...string) error { return nil }
+
+type verifyStubProcessor struct {
+	result *imageprocessor.Result
+	err    error
+}
+
+func (v verifyStubProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
+	if v.err != nil {
+		return nil, logging.NewOperationError("grpcclient.process_image", userID, v.err)
+	}
+	return v.result, nil
+}
+
+type metricsStubCache struct{}
+
+func (metricsStubCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return nil
+}
+func (metricsStubCache) Get(ctx context.Context, key string) (string, error) { return "", redis.Nil }
+func (metricsStubCache) Delete(ctx context.Context, keys ...string) error    { return nil }
+
+type metricsStubProcessor struct{}
+
+func (metricsStubProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
+	return &imageprocessor.Result{}, nil
+}
+
+type imageStubRepository struct {
+	verifyStubRepository
+	logs map[string]*repository.VerificationLog
+}
+
+func (r imageStubRepository) FindByRequestIDAndUser(ctx context.Context, requestID, userID string) (*repository.VerificationLog, error) {
+	if log, ok := r.logs[requestID]; ok && log.UserID == userID {
+		return log, nil
+	}
+	return nil, errors.New("record not found")
+}
+
+type imageStubStore struct{}
+
+func (imageStubStore) Put(ctx context.Context, key, contentType string, body io.ReadSeeker, size int64) error {
+	return nil
+}
+
+func (imageStubStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "https://images.example/" + key, nil
+}
+
+// streamStubProcessor implements imageprocessor.StreamClient, counting the bytes it
+// reads.
+type streamStubProcessor struct {
+	calls    int
+	received int
+}
+
+func (p *streamStubProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
+	return p.ProcessStream(ctx, userID, bytes.NewReader(imageBytes))
+}
+
+func (p *streamStubProcessor) ProcessStream(ctx context.Context, userID string, image io.Reader) (*imageprocessor.Result, error) {
+	p.calls++
+	n, err := io.Copy(io.Discard, image)
+	p.received += int(n)
+	if err != nil {
+		return nil, &imageprocessor.ReadError{Err: err}
+	}
+	return &imageprocessor.Result{Success: true, Score: 0.9}, nil
+}
+
+func TestDatabasePoolAdminRoutes(t *testing.T) {
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("DB returned error: %v", err)
+	}
+	tuner, err := dbpool.NewTuner(sqlDB, dbpool.Settings{MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewTuner returned error: %v", err)
+	}
+	admin := gin.New()
+	RegisterDatabasePoolAdminRoutes(admin, tuner)
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/admin/api/database/pool", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		admin.ServeHTTP(resp, req)
+		return resp
+	}
+	if resp := patch(`{"max_idle_conns":20}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected more idle than open connections to be rejected, got %d: %s", resp.Code, resp.Body.String())
+	}
+	resp := patch(`{"max_open_conns":40,"adaptive":true,"min_open_conns":10,"max_open_conns_limit":30}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		MaxOpenConns    int   `json:"max_open_conns"`
+		MaxIdleConns    int   `json:"max_idle_conns"`
+		ConnMaxLifetime int64 `json:"conn_max_lifetime_seconds"`
+		Adaptive        bool  `json:"adaptive"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.MaxOpenConns != 30 || body.MaxIdleConns != 5 || body.ConnMaxLifetime != 3600 || !body.Adaptive {
+		t.Fatalf("expected the limit to cap the pool and other fields to be kept, got %+v", body)
+	}
+	if stats := sqlDB.Stats(); stats.MaxOpenConnections != 30 {
+		t.Fatalf("expected the pool to allow 30 connections, got %d", stats.MaxOpenConnections)
+	}
+}
+
+func TestVerifyReportsImagesTheUserAlreadyVerified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	processor := &verifyStubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.91}}
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, processor, zap.NewNop())
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize})
+
+	upload := func(userID string) *httptest.ResponseRecorder {
+		body, formType := buildMultipartBody(t, "image/png", fakeImage("image/png", []byte("same-image")))
+		req := httptest.NewRequest(http.MethodPost, "/verify", body)
+		req.Header.Set("Content-Type", formType)
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, userID))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+	first := upload("user-123")
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", first.Code, first.Body.String())
+	}
+	var verified struct {
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(first.Body.Bytes(), &verified); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	resp := upload("user-123")
+	var conflict httperr.Response
+	if err := json.Unmarshal(resp.Body.Bytes(), &conflict); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Code != http.StatusConflict || conflict.Code != httperr.CodeDuplicateImage || conflict.Details["existing_request_id"] != verified.RequestID {
+		t.Fatalf("expected a 409 naming %s, got %d: %s", verified.RequestID, resp.Code, resp.Body.String())
+	}
+	if resp := upload("user-456"); resp.Code != http.StatusOK {
+		t.Fatalf("expected another user to verify the same image, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestVerifyRejectsInfectedUploads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	processor := &verifyStubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.91}}
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, processor, zap.NewNop())
+	uc.SetMalwareScanner(infectedScanner{})
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize})
+	token := buildTestToken(t, "user-123")
+
+	body, formType := buildMultipartBody(t, "image/png", fakeImage("image/png", []byte("EICAR")))
+	req := httptest.NewRequest(http.MethodPost, "/verify", body)
+	req.Header.Set("Content-Type", formType)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	var rejected httperr.Response
+	if err := json.Unmarshal(resp.Body.Bytes(), &rejected); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	requestID, _ := rejected.Details["request_id"].(string)
+	if resp.Code != http.StatusUnprocessableEntity || rejected.Code != httperr.CodeUnprocessableImage ||
+		rejected.Details["reason"] != "malware_detected" || rejected.Details["signature"] != "Eicar-Test-Signature" || requestID == "" {
+		t.Fatalf("expected a 422 malware_detected, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/result/"+requestID, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	var result resultResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if resp.Code != http.StatusOK || result.Status != repository.StatusFailed || result.MalwareScan != repository.MalwareScanInfected || result.MalwareSignature != "Eicar-Test-Signature" {
+		t.Fatalf("expected the rejection to be recorded, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+// infectedScanner finds images containing "EICAR" infected.
+type infectedScanner struct{}
+
+func (infectedScanner) Scan(_ context.Context, image io.Reader) (malwarescan.Verdict, error) {
+	data, err := io.ReadAll(image)
+	if err != nil || !bytes.Contains(data, []byte("EICAR")) {
+		return malwarescan.Verdict{}, err
+	}
+	return malwarescan.Verdict{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+}
+
+func TestVerifyChecksUploadsAgainstTheDeclaredSHA256(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	processor := &verifyStubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.91}}
+	uc := usecase.NewVerificationUseCase(repo, &verifyStubCache{}, processor, zap.NewNop())
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize})
+	token := buildTestToken(t, "user-123")
+
+	verify := func(image []byte, checksum string) *httptest.ResponseRecorder {
+		body, formType := buildMultipartBody(t, "image/png", image)
+		req := httptest.NewRequest(http.MethodPost, "/verify", body)
+		req.Header.Set("Content-Type", formType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Content-SHA256", checksum)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	image := fakeImage("image/png", []byte("payload"))
+	digest := sha256.Sum256(image)
+	resp := verify(image, hex.EncodeToString(digest[:]))
+	var verified verifyResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &verified); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("expected a matching upload to be verified, got %d: %s", resp.Code, resp.Body.String())
+	}
+	log, err := repo.FindByRequestIDAndUser(context.Background(), verified.RequestID, "user-123")
+	if err != nil || log.SHA256Hash != hex.EncodeToString(digest[:]) {
+		t.Fatalf("expected the verified digest to be stored, got %+v, %v", log, err)
+	}
+
+	other := fakeImage("image/png", []byte("other payload"))
+	otherDigest := sha256.Sum256(other)
+	if resp := verify(other, base64.StdEncoding.EncodeToString(otherDigest[:])); resp.Code != http.StatusOK {
+		t.Fatalf("expected a base64 digest to be accepted, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	truncated := fakeImage("image/png", []byte("payl"))
+	resp = verify(truncated, hex.EncodeToString(digest[:]))
+	var rejected httperr.Response
+	if err := json.Unmarshal(resp.Body.Bytes(), &rejected); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	actual := sha256.Sum256(truncated)
+	if resp.Code != http.StatusUnprocessableEntity || rejected.Code != httperr.CodeUnprocessableImage || rejected.Details["reason"] != "checksum_mismatch" ||
+		rejected.Details["expected_sha256"] != hex.EncodeToString(digest[:]) || rejected.Details["actual_sha256"] != hex.EncodeToString(actual[:]) {
+		t.Fatalf("expected a 422 checksum_mismatch, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var saved int64
+	db.Model(&repository.VerificationLog{}).Count(&saved)
+	if saved != 2 {
+		t.Fatalf("expected the mismatched upload not to be recorded, %d logs saved", saved)
+	}
+
+	if resp := verify(image, "not-a-digest"); resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), `"parameter":"X-Content-SHA256"`) {
+		t.Fatalf("expected 400 for a malformed digest, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestVerifyMapsProcessorFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		code   codes.Code
+		status int
+		error  httperr.Code
+	}{
+		{codes.InvalidArgument, http.StatusUnprocessableEntity, httperr.CodeUnprocessableImage},
+		{codes.ResourceExhausted, http.StatusTooManyRequests, httperr.CodeProcessorBusy},
+		{codes.Unavailable, http.StatusBadGateway, httperr.CodeProcessorUnavailable},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout, httperr.CodeProcessorTimeout},
+		{codes.Internal, http.StatusInternalServerError, httperr.CodeInternal},
+	} {
+		processor := verifyStubProcessor{err: status.Error(tc.code, "model worker 10.0.3.7 crashed")}
+		uc := usecase.NewVerificationUseCase(verifyStubRepository{}, verifyStubCache{}, processor, zap.NewNop())
+		handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize})
+
+		body, formType := buildMultipartBody(t, "image/png", fakeImage("image/png", []byte("image")))
+		req := httptest.NewRequest(http.MethodPost, "/verify", body)
+		req.Header.Set("Content-Type", formType)
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		var response httperr.Response
+		if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Code != tc.status || response.Code != tc.error {
+			t.Fatalf("%s: expected %d %s, got %d: %s", tc.code, tc.status, tc.error, resp.Code, resp.Body.String())
+		}
+		if strings.Contains(resp.Body.String(), "10.0.3.7") {
+			t.Fatalf("%s: expected the processor's message not to be exposed, got %s", tc.code, resp.Body.String())
+		}
+	}
+}
+
+func TestVerifyFetchesImagesByURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	payload := fakeImage("image/png", []byte("hosted image"))
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			// The declared type is ignored in favour of the content.
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(payload)
+		case "/page.html":
+			w.Write([]byte("<html><body>not an image</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	processor := &streamStubProcessor{}
+	uc := usecase.NewVerificationUseCase(verifyStubRepository{}, verifyStubCache{}, processor, zap.NewNop())
+	fetcher := imagefetch.New(imagefetch.Options{Timeout: time.Second, MaxSize: MaxUploadSize, AllowHTTP: true, AllowPrivateNetworks: true})
+	handler := NewHandler(uc, auth.JWTMiddleware(testJWTSecret, ""), Options{MaxUploadSize: MaxUploadSize, ImageFetcher: fetcher})
+	verify := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/verify", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+buildTestToken(t, "user-123"))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := verify(`{"image_url": "` + origin.URL + `/image.png"}`)
+	if resp.Code != http.StatusOK || processor.received != len(payload) {
+		t.Fatalf("expected the downloaded image to be verified, got %d (%d bytes processed): %s", resp.Code, processor.received, resp.Body.String())
+	}
+
+	for _, tc := range []struct {
+		body   string
+		status int
+		code   httperr.Code
+	}{
+		{`{}`, http.StatusBadRequest, httperr.CodeInvalidRequest},
+		{`{"image_url": "ftp://example.com/image.png"}`, http.StatusBadRequest, httperr.CodeInvalidRequest},
+		{`{"image_url": "` + origin.URL + `/missing.png"}`, http.StatusUnprocessableEntity, httperr.CodeImageFetchFailed},
+		{`{"image_url": "` + origin.URL + `/page.html"}`, http.StatusUnsupportedMediaType, httperr.CodeUnsupportedMediaType},
+	} {
+		resp := verify(tc.body)
+		var body httperr.Response
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		if resp.Code != tc.status || body.Code != tc.code {
+			t.Fatalf("expected %d %s for %s, got %d: %s", tc.status, tc.code, tc.body, resp.Code, resp.Body.String())
+		}
+	}
+	if processor.calls != 1 {
+		t.Fatalf("expected refused URLs not to reach the processor, got %d calls", processor.calls)
+	}
+}
+
+func TestTokenRoutesIssueTokensTheAPIAccepts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	redisServer, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer redisServer.Close()
+	defer client.Close()
+	hash, err := bcrypt.GenerateFromPassword([]byte("client-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash secret: %v", err)
+	}
+	creds := auth.NewCredentials("", testJWTSecret)
+	revocations := auth.NewRevocations(client)
+	creds.SetRevocations(revocations)
+	issuer := auth.NewIssuer(creds, revocations, auth.IssuerOptions{AccessTTL: time.Minute, RefreshTTL: time.Hour}, []auth.Client{{ID: "reporting", SecretHash: string(hash)}})
+	uc := usecase.NewVerificationUseCase(metricsStubRepository{}, verifyStubCache{}, &streamStubProcessor{}, zap.NewNop())
+	handler := NewHandler(uc, auth.JWTMiddlewareWithCredentials(creds), Options{MaxUploadSize: MaxUploadSize, TokenIssuer: issuer})
+	post := func(path string, form url.Values, username, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+	summary := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/metrics/summary", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	if resp := post("/auth/token", nil, "reporting", "wrong"); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a wrong secret to be refused, got %d: %s", resp.Code, resp.Body.String())
+	}
+	resp := post("/auth/token", nil, "reporting", "client-secret")
+	var issued tokenResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &issued); err != nil || resp.Code != http.StatusOK || issued.TokenType != "Bearer" || issued.ExpiresIn != 60 {
+		t.Fatalf("expected a token pair, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if code := summary(issued.AccessToken); code != http.StatusOK {
+		t.Fatalf("expected the issued token to be accepted, got %d", code)
+	}
+
+	resp = post("/auth/refresh", url.Values{"refresh_token": {issued.RefreshToken}}, "", "")
+	var refreshed tokenResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &refreshed); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("expected a refreshed pair, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := post("/auth/refresh", url.Values{"refresh_token": {issued.RefreshToken}}, "", ""); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a used refresh token to be refused, got %d", resp.Code)
+	}
+
+	if resp := post("/auth/revoke", url.Values{"token": {refreshed.AccessToken}}, "", ""); resp.Code != http.StatusNoContent {
+		t.Fatalf("expected the token to be revoked, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if code := summary(refreshed.AccessToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected the revoked token to be refused, got %d", code)
+	}
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	uc := usecase.NewVerificationUseCase(verifyStubRepository{}, verifyStubCache{}, verifyStubProcessor{}, zap.NewNop())
+	opts := DefaultOptions()
+	opts.Readiness = &health.Checker{}
+	opts.Metrics = metrics.New()
+	opts.TokenIssuer = &auth.Issuer{}
+	opts.LocalImages = &storage.Local{}
+	opts.Webhooks = &webhooks.Service{}
+	opts.Usage = &metering.Meter{}
+	opts.Disputes = &disputes.Service{}
+	opts.LiveMetrics = &livemetrics.Feed{}
+	opts.ResultStream = &resultstream.Hub{}
+	opts.AdminRole = "admin"
+	opts.Docs = true
+	router := gin.New()
+	RegisterRoutesWithOptions(router, uc, auth.JWTMiddleware(testJWTSecret, ""), opts)
+
+	doc := OpenAPI()
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		registered[route.Method+" "+route.Path] = true
+		if !doc.Has(route.Method, route.Path) {
+			t.Errorf("%s %s is not described by OpenAPI", route.Method, route.Path)
+		}
+	}
+	for path, item := range doc.Paths {
+		for method := range item {
+			ginPath := regexp.MustCompile(`\{([^}]+)\}`).ReplaceAllStringFunc(path, func(param string) string {
+				if param == "{key}" {
+					return "*key"
+				}
+				return ":" + strings.Trim(param, "{}")
+			})
+			if !registered[strings.ToUpper(method)+" "+ginPath] {
+				t.Errorf("OpenAPI describes %s %s, which is not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var served struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	if resp.Code != http.StatusOK || json.Unmarshal(resp.Body.Bytes(), &served) != nil || served.OpenAPI == "" || len(served.Paths) != len(doc.Paths) {
+		t.Fatalf("expected the document at /openapi.json, got %d: %.200s", resp.Code, resp.Body.String())
+	}
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `"openapi.json"`) || resp.Header().Get("Content-Security-Policy") == "" {
+		t.Fatalf("expected Swagger UI at /docs, got %d: %.200s", resp.Code, resp.Body.String())
+	}
 }
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/storage"
+)
+
+// RegisterLocalImageRoutes serves the signed image URLs of a local image store at
+// GET /images/*key. The signature stands in for authentication, so the routes must
+// be mounted outside the authenticated group.
+func RegisterLocalImageRoutes(router gin.IRoutes, images *storage.Local) {
+	router.GET("/images/*key", func(c *gin.Context) {
+		key := strings.TrimPrefix(c.Param("key"), "/")
+		if err := images.Verify(key, c.Query("expires"), c.Query("signature")); err != nil {
+			httperr.Write(c, httperr.CodeForbidden, "invalid or expired image url")
+			return
+		}
+
+		object, err := images.Open(c.Request.Context(), key)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			httperr.Write(c, httperr.CodeImageNotStored, "image not stored")
+			return
+		case err != nil:
+			httperr.Write(c, httperr.CodeInternal, "failed to read image")
+			return
+		}
+		defer object.Close()
+
+		c.Header("Cache-Control", "private, no-store")
+		// The objects carry no extension, so ServeContent sniffs the type from the
+		// image itself.
+		http.ServeContent(c.Writer, c.Request, "", time.Time{}, object.(io.ReadSeeker))
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/imagefetch"
+	"github.com/example/ai-check/internal/imagelimits"
+)
+
+// maxImageURLBody bounds the JSON body naming an image to download.
+const maxImageURLBody = 16 << 10
+
+type imageURLRequest struct {
+	ImageURL string `json:"image_url"`
+}
+
+// fetchImage downloads the image named by the image_url of a JSON body, answering
+// 400 when the URL is missing or refused, 413 when the image is too large, 422 when
+// it cannot be downloaded or is rejected by limits, and 415 when it is not a
+// supported image. What the remote server declares the image to be is ignored; only
+// its content counts. It returns the image with its detected type.
+func fetchImage(c *gin.Context, fetcher *imagefetch.Fetcher, limits *imagelimits.Limits) (io.Reader, string, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageURLBody)
+	var req imageURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ImageURL == "" {
+		httperr.InvalidParameter(c, "image_url", "image_url is required")
+		return nil, "", false
+	}
+
+	image, err := fetcher.Fetch(c.Request.Context(), req.ImageURL)
+	switch {
+	case errors.Is(err, imagefetch.ErrInvalidURL):
+		httperr.InvalidParameter(c, "image_url", err.Error())
+		return nil, "", false
+	case errors.Is(err, imagefetch.ErrTooLarge):
+		httperr.Write(c, httperr.CodePayloadTooLarge, "image file is too large")
+		return nil, "", false
+	case err != nil:
+		httperr.Write(c, httperr.CodeImageFetchFailed, "image could not be downloaded from image_url")
+		return nil, "", false
+	case len(image) == 0:
+		httperr.InvalidParameter(c, "image_url", "image file is empty")
+		return nil, "", false
+	}
+
+	head := image
+	if len(head) > imagelimits.HeaderLen {
+		head = head[:imagelimits.HeaderLen]
+	}
+	detected, ok := DetectImageType(head, "")
+	if !ok {
+		writeContentMismatch(c, detected)
+		return nil, "", false
+	}
+	if !checkImageLimits(c, limits, head) {
+		return nil, "", false
+	}
+	return bytes.NewReader(image), detected, true
+}
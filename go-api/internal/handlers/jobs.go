@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/cron"
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/worker"
+)
+
+const jobDeadLetterLimit = 20
+
+// RegisterJobAdminRoutes exposes scheduled tasks and the job queue. scheduler may
+// be nil when cron is disabled. Mount them only on the admin listener.
+func RegisterJobAdminRoutes(router gin.IRouter, queue *worker.Queue, scheduler *cron.Scheduler) {
+	router.GET("/admin/api/jobs", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		response := gin.H{"schedules": []cron.TaskStatus{}}
+		if scheduler != nil {
+			statuses, leader, err := scheduler.Status(ctx)
+			if err != nil {
+				httperr.Write(c, httperr.CodeInternal, "failed to load schedules")
+				return
+			}
+			response["instance"] = scheduler.InstanceID()
+			response["leader"] = leader
+			response["schedules"] = statuses
+		}
+
+		stats, err := queue.Stats(ctx)
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to load queue stats")
+			return
+		}
+		dead, err := queue.DeadLetters(ctx, jobDeadLetterLimit)
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to load dead letters")
+			return
+		}
+		items := make([]gin.H, 0, len(dead))
+		for _, job := range dead {
+			items = append(items, gin.H{
+				"id":          job.ID,
+				"type":        job.Type,
+				"attempts":    job.Attempts,
+				"last_error":  job.LastError,
+				"enqueued_at": job.EnqueuedAt,
+			})
+		}
+		response["queue"] = stats
+		response["dead_letters"] = items
+		c.JSON(http.StatusOK, response)
+	})
+}
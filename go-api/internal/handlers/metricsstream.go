@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/livemetrics"
+)
+
+// streamWriteTimeout bounds how long a client may take to accept an update before
+// its stream is closed.
+const streamWriteTimeout = 10 * time.Second
+
+// RegisterMetricsStreamAdminRoutes serves the live metrics feed to wallboards at
+// /admin/api/metrics/stream. Mount it only on the admin listener.
+func RegisterMetricsStreamAdminRoutes(router gin.IRouter, feed *livemetrics.Feed) {
+	router.GET("/admin/api/metrics/stream", func(c *gin.Context) {
+		serveMetricsStream(c, feed.Subscribe)
+	})
+}
+
+// serveMetricsStream upgrades the request to a WebSocket and sends every update of
+// the feed subscribed to as a JSON text message until the client disconnects or the
+// feed stops.
+func serveMetricsStream(c *gin.Context, subscribe func() (<-chan livemetrics.Update, func())) {
+	if !c.IsWebsocket() {
+		httperr.Write(c, httperr.CodeInvalidRequest, "expected a WebSocket upgrade")
+		return
+	}
+	// The origin is not checked: the public route needs a bearer token, which browsers
+	// cannot attach to a cross-site WebSocket, and the admin listener is internal.
+	server := websocket.Server{Handler: func(conn *websocket.Conn) {
+		defer conn.Close()
+		updates, cancel := subscribe()
+		defer cancel()
+
+		// Clients do not send anything; a failed read means they went away.
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			var discard []byte
+			for websocket.Message.Receive(conn, &discard) == nil {
+			}
+		}()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case update, ok := <-updates:
+				if !ok {
+					return
+				}
+				_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+				if err := websocket.JSON.Send(conn, update); err != nil {
+					return
+				}
+			}
+		}
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
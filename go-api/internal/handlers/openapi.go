@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/buildinfo"
+	"github.com/example/ai-check/internal/disputes"
+	"github.com/example/ai-check/internal/graphql"
+	"github.com/example/ai-check/internal/health"
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/openapi"
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/users"
+)
+
+// RegisterDocsRoutes serves the OpenAPI document of the API at GET /openapi.json and
+// Swagger UI rendering it at GET /docs, without authentication. basePath is the
+// prefix the API is mounted under, if any.
+func RegisterDocsRoutes(router gin.IRoutes, basePath string) {
+	doc := OpenAPI()
+	if basePath != "" {
+		doc.Servers = []openapi.Server{{URL: basePath}}
+	}
+	spec, err := doc.JSON()
+	if err != nil {
+		panic(err) // the document is built from fixed types
+	}
+	page := openapi.UI(doc.Info.Title, "openapi.json")
+
+	router.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", spec)
+	})
+	router.GET("/docs", func(c *gin.Context) {
+		c.Header("Content-Security-Policy", openapi.UIContentSecurityPolicy)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+	})
+}
+
+// OpenAPI describes the API served by NewHandler with every optional route
+// enabled. Routes added to RegisterRoutesWithOptions must be documented here too;
+// TestOpenAPIDocumentsEveryRoute fails otherwise. Run go generate in go-api after
+// changing it to update openapi.json.
+func OpenAPI() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:   "AI Check API",
+		Version: "1.0.0",
+		Description: "Verifies images with the AI image processor and keeps their results. " +
+			"Errors answer with a JSON body carrying a stable code; see the error codes of the README.",
+	})
+
+	errorBody := doc.Component("Error", openapi.SchemaOf(httperr.Response{}))
+	withErrors := func(responses map[int]openapi.Response, statuses ...int) map[int]openapi.Response {
+		for _, status := range statuses {
+			responses[status] = openapi.JSONResponse(http.StatusText(status), errorBody)
+		}
+		return responses
+	}
+	// Every authenticated route may answer these.
+	authErrors := []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests}
+	const needsWriteScope = "Needs the " + auth.ScopeVerifyWrite + " scope."
+	protected := func(responses map[int]openapi.Response, statuses ...int) map[int]openapi.Response {
+		return withErrors(responses, append(statuses, authErrors...)...)
+	}
+
+	result := doc.Component("Result", openapi.SchemaOf(resultResponse{}))
+	verification := doc.Component("VerificationSummary", openapi.Object(map[string]*openapi.Schema{
+		"request_id":            openapi.String(),
+		"score":                 openapi.Number(),
+		"success":               openapi.Boolean(),
+		"model_version":         openapi.String(),
+		"sha256_hash":           openapi.String(),
+		"sha1_hash":             openapi.String(),
+		"processing_latency_ms": openapi.Integer(),
+		"status":                openapi.String(),
+		"categories":            openapi.ArrayOf(openapi.SchemaOf(usecase.CategoryOutcome{})),
+		"created_at":            openapi.DateTime(),
+	}, "request_id", "score", "success", "model_version", "sha256_hash", "sha1_hash", "processing_latency_ms", "status", "categories", "created_at"))
+	metricsTotals := map[string]*openapi.Schema{
+		"total_requests":                openapi.Integer(),
+		"successful_requests":           openapi.Integer(),
+		"success_rate":                  openapi.Number(),
+		"average_score":                 openapi.Number(),
+		"average_processing_latency_ms": openapi.Number(),
+	}
+	metricsSummary := openapi.Object(map[string]*openapi.Schema{
+		"region":   openapi.String(),
+		"interval": openapi.String(),
+		"from":     openapi.DateTime(),
+		"to":       openapi.DateTime(),
+		"series":   openapi.ArrayOf(openapi.Object(withProperty(metricsTotals, "start", openapi.DateTime()))),
+	})
+	for name, schema := range metricsTotals {
+		metricsSummary.Properties[name] = schema
+	}
+	webhookEndpoint := doc.Component("WebhookEndpoint", openapi.Object(map[string]*openapi.Schema{
+		"id":         openapi.Integer(),
+		"url":        openapi.String(),
+		"events":     openapi.ArrayOf(openapi.String()),
+		"created_at": openapi.DateTime(),
+	}, "id", "url", "events", "created_at"))
+	webhookDelivery := doc.Component("WebhookDelivery", openapi.Object(map[string]*openapi.Schema{
+		"id":            openapi.Integer(),
+		"event":         openapi.String(),
+		"event_id":      openapi.String(),
+		"status":        openapi.String(),
+		"attempts":      openapi.Integer(),
+		"response_code": openapi.Integer(),
+		"response_body": openapi.String(),
+		"last_error":    openapi.String(),
+		"payload":       openapi.Any(),
+		"created_at":    openapi.DateTime(),
+		"updated_at":    openapi.DateTime(),
+		"delivered_at":  &openapi.Schema{Type: "string", Format: "date-time", Nullable: true},
+	}))
+	dispute := doc.Component("Dispute", openapi.SchemaOf(disputes.Dispute{}))
+	token := doc.Component("Token", openapi.SchemaOf(tokenResponse{}))
+	quota := doc.Component("Quota", openapi.SchemaOf(users.Quota{}))
+	graphQLResponse := openapi.Object(map[string]*openapi.Schema{
+		"data":   openapi.Any(),
+		"errors": openapi.ArrayOf(openapi.Object(map[string]*openapi.Schema{"message": openapi.String()}, "message")),
+	})
+
+	query := func(name, description string, schema *openapi.Schema) openapi.Parameter {
+		return openapi.Parameter{Name: name, Description: description, Schema: schema}
+	}
+	limit := query("limit", "Page size.", openapi.Integer())
+	cursor := query("cursor", "The next_cursor of the previous page.", openapi.String())
+	from := query("from", "Start of the window, an RFC 3339 timestamp or a YYYY-MM-DD day.", openapi.String())
+	to := query("to", "End of the window, an RFC 3339 timestamp or a YYYY-MM-DD day, which it includes.", openapi.String())
+	success := query("success", "Keep only successful or unsuccessful verifications.", openapi.Boolean())
+	format := query("format", "Response format; the Accept header is used without it.", openapi.Enum("json", "csv", "pdf"))
+	adminFilters := []openapi.Parameter{limit, cursor, from, to, success,
+		query("user_id", "Keep the verifications of one user.", openapi.String()),
+		query("min_score", "Lowest score kept, inclusive.", openapi.Number()),
+		query("max_score", "Highest score kept, inclusive.", openapi.Number()),
+	}
+	rendered := func(description string, json *openapi.Schema) openapi.Response {
+		return openapi.ContentResponse(description, map[string]*openapi.Schema{
+			"application/json": json,
+			"text/csv":         openapi.String(),
+			"application/pdf":  openapi.Binary(),
+		})
+	}
+	imageUpload := &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+		"multipart/form-data": {Schema: openapi.Object(map[string]*openapi.Schema{"image": openapi.Binary()}, "image")},
+	}}
+	verifyBody := &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+		"multipart/form-data": imageUpload.Content["multipart/form-data"],
+		"application/json":    {Schema: openapi.SchemaOf(imageURLRequest{})},
+	}}
+	tokenBody := func(schema *openapi.Schema) *openapi.RequestBody {
+		return &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			"application/json":                  {Schema: schema},
+			"application/x-www-form-urlencoded": {Schema: schema},
+		}}
+	}
+	ok := func(description string, schema *openapi.Schema) map[int]openapi.Response {
+		return map[int]openapi.Response{http.StatusOK: openapi.JSONResponse(description, schema)}
+	}
+	live := ok("The process is running.", openapi.Object(map[string]*openapi.Schema{"status": openapi.String()}, "status"))
+	ready := map[int]openapi.Response{
+		http.StatusOK:                 openapi.JSONResponse("Every dependency is ready.", openapi.SchemaOf(health.Report{})),
+		http.StatusServiceUnavailable: openapi.JSONResponse("A dependency check failed or the instance is draining.", openapi.SchemaOf(health.Report{})),
+	}
+
+	routes := []openapi.Route{
+		{Method: http.MethodGet, Path: "/health/live", Tag: "operations", Public: true, Summary: "Liveness probe", Responses: live},
+		{Method: http.MethodGet, Path: "/health", Tag: "operations", Public: true, Summary: "Liveness probe, older path", Responses: live},
+		{Method: http.MethodGet, Path: "/health/ready", Tag: "operations", Public: true, Summary: "Readiness probe", Responses: ready},
+		{Method: http.MethodGet, Path: "/readyz", Tag: "operations", Public: true, Summary: "Readiness probe, older path", Responses: ready},
+		{Method: http.MethodGet, Path: "/version", Tag: "operations", Public: true, Summary: "Build of the running binary",
+			Responses: ok("The build description.", openapi.SchemaOf(buildinfo.Info{}))},
+		{Method: http.MethodGet, Path: "/openapi.json", Tag: "operations", Public: true, Summary: "This document",
+			Responses: ok("The OpenAPI document.", openapi.Any())},
+		{Method: http.MethodGet, Path: "/docs", Tag: "operations", Public: true, Summary: "Swagger UI rendering this document",
+			Responses: map[int]openapi.Response{http.StatusOK: openapi.ContentResponse("An HTML page.", map[string]*openapi.Schema{"text/html": openapi.String()})}},
+
+		{Method: http.MethodPost, Path: "/auth/token", Tag: "auth", Public: true, Summary: "Issue tokens for a client",
+			Description: "Clients may also authenticate with HTTP Basic.", Body: tokenBody(openapi.SchemaOf(tokenRequest{})),
+			Responses: withErrors(ok("A token pair.", token), http.StatusBadRequest, http.StatusUnauthorized)},
+		{Method: http.MethodPost, Path: "/auth/refresh", Tag: "auth", Public: true, Summary: "Exchange a refresh token for new tokens",
+			Body: tokenBody(openapi.SchemaOf(refreshRequest{})), Responses: withErrors(ok("A token pair.", token), http.StatusBadRequest, http.StatusUnauthorized)},
+		{Method: http.MethodPost, Path: "/auth/revoke", Tag: "auth", Public: true, Summary: "Revoke a token",
+			Body: tokenBody(openapi.SchemaOf(revokeRequest{})), Responses: withErrors(map[int]openapi.Response{http.StatusNoContent: openapi.StatusText(http.StatusNoContent)}, http.StatusBadRequest)},
+		{Method: http.MethodGet, Path: "/images/*key", Tag: "verifications", Public: true, Summary: "Download an image kept on local disk",
+			Description: "The signed URLs of GET /result/{id}/image point here when images are kept on local disk.",
+			Query: []openapi.Parameter{
+				{Name: "expires", Required: true, Schema: openapi.Integer()},
+				{Name: "signature", Required: true, Schema: openapi.String()},
+			},
+			Responses: withErrors(map[int]openapi.Response{http.StatusOK: openapi.ContentResponse("The image.", map[string]*openapi.Schema{"image/*": openapi.Binary()})}, http.StatusForbidden, http.StatusNotFound)},
+
+		{Method: http.MethodPost, Path: "/verify", Tag: "verifications", Summary: "Verify an image",
+			Description: "Takes an image upload or, when enabled, a JSON body naming an image_url to download. " + needsWriteScope,
+			Headers: []openapi.Parameter{{Name: checksumHeader, Schema: openapi.String(),
+				Description: "SHA-256 of the image in hex or base64. Images that do not match are rejected with 422."}},
+			Body: verifyBody,
+			Responses: protected(map[int]openapi.Response{
+				http.StatusOK:       openapi.JSONResponse("The verification result.", openapi.SchemaOf(verifyResponse{})),
+				http.StatusAccepted: openapi.JSONResponse("The image processor is unavailable and the verification was queued.", openapi.SchemaOf(queuedResponse{})),
+			}, http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity, http.StatusServiceUnavailable)},
+		{Method: http.MethodGet, Path: "/result/:id", Tag: "verifications", Summary: "Get a verification result",
+			Query: []openapi.Parameter{format}, Responses: protected(map[int]openapi.Response{http.StatusOK: rendered("The result, or its certificate.", result)}, http.StatusBadRequest, http.StatusNotFound)},
+		{Method: http.MethodGet, Path: "/result/:id/stream", Tag: "verifications", Summary: "Stream a result until it leaves the queue",
+			Description: "Sends result objects as server-sent events, or as WebSocket messages when the request upgrades.",
+			Responses:   protected(map[int]openapi.Response{http.StatusOK: openapi.ContentResponse("Server-sent events carrying results.", map[string]*openapi.Schema{"text/event-stream": result})}, http.StatusNotFound)},
+		{Method: http.MethodGet, Path: "/result/:id/image", Tag: "verifications", Summary: "Get a signed URL of the uploaded image",
+			Responses: protected(ok("A time-limited download URL.", openapi.Object(map[string]*openapi.Schema{"url": openapi.String(), "expires_at": openapi.DateTime()}, "url", "expires_at")), http.StatusNotFound)},
+		{Method: http.MethodPost, Path: "/result/:id/feedback", Tag: "disputes", Summary: "Dispute the verdict of a verification", Description: needsWriteScope,
+			Body:      openapi.JSONBody(openapi.Object(map[string]*openapi.Schema{"reason": openapi.String()}, "reason")),
+			Responses: protected(map[int]openapi.Response{http.StatusCreated: openapi.JSONResponse("The dispute.", dispute)}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict)},
+		{Method: http.MethodGet, Path: "/result/:id/feedback", Tag: "disputes", Summary: "Get the state of your dispute",
+			Responses: protected(ok("The dispute.", dispute), http.StatusNotFound)},
+		{Method: http.MethodGet, Path: "/duplicates/:id", Tag: "verifications", Summary: "List verifications of the same image",
+			Query: []openapi.Parameter{limit, cursor},
+			Responses: protected(ok("One page of duplicates, newest first.", openapi.Object(map[string]*openapi.Schema{
+				"request_id":      openapi.String(),
+				"user_id":         openapi.String(),
+				"sha256_hash":     openapi.String(),
+				"sha1_hash":       openapi.String(),
+				"duplicate_count": openapi.Integer(),
+				"next_cursor":     openapi.String(),
+				"duplicates": openapi.ArrayOf(openapi.Object(map[string]*openapi.Schema{
+					"request_id": openapi.String(),
+					"score":      openapi.Number(),
+					"success":    openapi.Boolean(),
+					"details":    openapi.String(),
+					"created_at": openapi.DateTime(),
+				})),
+			}, "request_id", "user_id", "sha256_hash", "sha1_hash", "duplicate_count", "duplicates")), http.StatusBadRequest, http.StatusNotFound)},
+		{Method: http.MethodPost, Path: "/search/similar", Tag: "verifications", Summary: "Find your verifications of similar images",
+			Query: []openapi.Parameter{
+				query("max_distance", "Largest perceptual hash distance of a match.", openapi.Integer()),
+				limit,
+				query("format", "ndjson streams unranked matches as they are found.", openapi.Enum("json", "ndjson")),
+			},
+			Body: imageUpload,
+			Responses: protected(ok("The closest matches.", openapi.Object(map[string]*openapi.Schema{"results": openapi.ArrayOf(openapi.Object(map[string]*openapi.Schema{
+				"request_id":  openapi.String(),
+				"score":       openapi.Number(),
+				"success":     openapi.Boolean(),
+				"sha256_hash": openapi.String(),
+				"sha1_hash":   openapi.String(),
+				"created_at":  openapi.DateTime(),
+				"distance":    openapi.Integer(),
+				"similarity":  openapi.Number(),
+			}))}, "results")), http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity)},
+		{Method: http.MethodGet, Path: "/history", Tag: "verifications", Summary: "List your verifications",
+			Query: []openapi.Parameter{limit, cursor, from, to, success, format},
+			Responses: protected(map[int]openapi.Response{http.StatusOK: rendered("One page of verifications, newest first.", openapi.Object(map[string]*openapi.Schema{
+				"verifications": openapi.ArrayOf(verification),
+				"next_cursor":   openapi.String(),
+			}, "verifications"))}, http.StatusBadRequest)},
+		{Method: http.MethodGet, Path: "/history/export", Tag: "verifications", Summary: "Export all your verifications",
+			Query: []openapi.Parameter{query("format", "One object per line, or a single object.", openapi.Enum("ndjson", "json"))},
+			Responses: protected(map[int]openapi.Response{http.StatusOK: openapi.ContentResponse("Every verification, newest first.", map[string]*openapi.Schema{
+				"application/x-ndjson": verification,
+				"application/json":     openapi.Object(map[string]*openapi.Schema{"verifications": openapi.ArrayOf(verification)}, "verifications"),
+			})}, http.StatusBadRequest)},
+		{Method: http.MethodDelete, Path: "/me/data", Tag: "verifications", Summary: "Erase all your verifications", Description: needsWriteScope,
+			Responses: protected(ok("How many verifications were erased.", openapi.Object(map[string]*openapi.Schema{"erased": openapi.Integer()}, "erased")))},
+
+		{Method: http.MethodPost, Path: "/webhooks", Tag: "webhooks", Summary: "Register a webhook endpoint", Description: needsWriteScope,
+			Body: openapi.JSONBody(openapi.Object(map[string]*openapi.Schema{
+				"url":    openapi.String(),
+				"events": openapi.Describe(openapi.ArrayOf(openapi.String()), "Events to deliver; all of them when omitted."),
+			}, "url")),
+			Responses: protected(map[int]openapi.Response{http.StatusCreated: openapi.JSONResponse("The endpoint with its signing secret.", webhookEndpoint)}, http.StatusBadRequest)},
+		{Method: http.MethodGet, Path: "/webhooks", Tag: "webhooks", Summary: "List your webhook endpoints",
+			Responses: protected(ok("Your endpoints.", openapi.Object(map[string]*openapi.Schema{"webhooks": openapi.ArrayOf(webhookEndpoint)}, "webhooks")))},
+		{Method: http.MethodDelete, Path: "/webhooks/:id", Tag: "webhooks", Summary: "Remove a webhook endpoint", Description: needsWriteScope,
+			Responses: protected(map[int]openapi.Response{http.StatusNoContent: openapi.StatusText(http.StatusNoContent)}, http.StatusNotFound)},
+		{Method: http.MethodGet, Path: "/webhooks/:id/deliveries", Tag: "webhooks", Summary: "List the deliveries of an endpoint",
+			Query:     []openapi.Parameter{limit},
+			Responses: protected(ok("Deliveries, newest first.", openapi.Object(map[string]*openapi.Schema{"deliveries": openapi.ArrayOf(webhookDelivery)}, "deliveries")), http.StatusBadRequest, http.StatusNotFound)},
+		{Method: http.MethodPost, Path: "/webhooks/:id/deliveries/:delivery_id/replay", Tag: "webhooks", Summary: "Send a delivery again", Description: needsWriteScope,
+			Responses: protected(map[int]openapi.Response{http.StatusAccepted: openapi.JSONResponse("The delivery, queued again.", webhookDelivery)}, http.StatusNotFound, http.StatusConflict)},
+
+		{Method: http.MethodGet, Path: "/usage", Tag: "usage", Summary: "Get your quota and billable usage",
+			Query: []openapi.Parameter{
+				query("from", "First month, YYYY-MM.", openapi.String()),
+				query("to", "Last month, YYYY-MM.", openapi.String()),
+				query("format", "csv returns the usage alone.", openapi.Enum("json", "csv")),
+			},
+			Responses: protected(map[int]openapi.Response{http.StatusOK: openapi.ContentResponse("Your quota and usage per month.", map[string]*openapi.Schema{
+				"application/json": openapi.Object(map[string]*openapi.Schema{
+					"quota": quota,
+					"usage": openapi.ArrayOf(openapi.Object(map[string]*openapi.Schema{
+						"user_id":        openapi.String(),
+						"period":         openapi.String(),
+						"units":          openapi.Integer(),
+						"verifications":  openapi.Integer(),
+						"reported_units": openapi.Integer(),
+						"updated_at":     openapi.DateTime(),
+					})),
+				}),
+				"text/csv": openapi.String(),
+			})}, http.StatusBadRequest)},
+
+		{Method: http.MethodPost, Path: "/graphql", Tag: "graphql", Summary: "Run a GraphQL query",
+			Body:      openapi.JSONBody(openapi.SchemaOf(graphql.Request{})),
+			Responses: protected(map[int]openapi.Response{http.StatusOK: openapi.JSONResponse("The query result.", graphQLResponse), http.StatusBadRequest: openapi.JSONResponse("The query is invalid.", graphQLResponse)})},
+		{Method: http.MethodGet, Path: "/graphql", Tag: "graphql", Summary: "Run a GraphQL query from query parameters",
+			Query: []openapi.Parameter{
+				{Name: "query", Required: true, Schema: openapi.String()},
+				query("operationName", "", openapi.String()),
+				query("variables", "A JSON object.", openapi.String()),
+			},
+			Responses: protected(map[int]openapi.Response{http.StatusOK: openapi.JSONResponse("The query result.", graphQLResponse), http.StatusBadRequest: openapi.JSONResponse("The query is invalid.", graphQLResponse)})},
+		{Method: http.MethodGet, Path: "/graphql/schema", Tag: "graphql", Summary: "Get the GraphQL schema",
+			Responses: protected(map[int]openapi.Response{http.StatusOK: openapi.ContentResponse("The schema in SDL.", map[string]*openapi.Schema{"text/plain": openapi.String()})})},
+
+		{Method: http.MethodGet, Path: "/metrics/summary", Tag: "metrics", Summary: "Get aggregated verification metrics",
+			Description: "Counts the verifications of the token's tenant. With from, to or interval, the totals cover that window and series breaks them down into periods.",
+			Query:       []openapi.Parameter{from, to, query("interval", "Period length, a whole number of hours such as 1h.", openapi.String())},
+			Responses:   protected(ok("The metrics.", metricsSummary), http.StatusBadRequest)},
+		{Method: http.MethodGet, Path: "/metrics/stream", Tag: "metrics", Summary: "Stream live metrics over a WebSocket",
+			Description: "Counts the verifications of the token's tenant.",
+			Responses:   protected(map[int]openapi.Response{http.StatusSwitchingProtocols: openapi.StatusText(http.StatusSwitchingProtocols)}, http.StatusBadRequest)},
+
+		{Method: http.MethodGet, Path: "/admin/logs", Tag: "admin", Summary: "Search the verifications of every user",
+			Description: "Needs a token granted the admin role.", Query: adminFilters,
+			Responses: protected(ok("One page of verifications, newest first.", openapi.Object(map[string]*openapi.Schema{
+				"logs":        openapi.ArrayOf(verification),
+				"next_cursor": openapi.String(),
+			}, "logs")), http.StatusBadRequest)},
+		{Method: http.MethodGet, Path: "/admin/metrics", Tag: "admin", Summary: "Get the metrics of each user",
+			Description: "Needs a token granted the admin role.", Query: adminFilters,
+			Responses: protected(ok("One page of users, ordered by user ID.", openapi.Object(map[string]*openapi.Schema{
+				"users":       openapi.ArrayOf(openapi.SchemaOf(usecase.UserMetrics{})),
+				"next_cursor": openapi.String(),
+			}, "users")), http.StatusBadRequest)},
+	}
+	for _, route := range routes {
+		doc.Add(route)
+	}
+	return doc
+}
+
+// withProperty returns a copy of properties with one more.
+func withProperty(properties map[string]*openapi.Schema, name string, schema *openapi.Schema) map[string]*openapi.Schema {
+	copied := map[string]*openapi.Schema{name: schema}
+	for key, value := range properties {
+		copied[key] = value
+	}
+	return copied
+}
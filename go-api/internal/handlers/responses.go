@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/example/ai-check/internal/imagemeta"
+	"github.com/example/ai-check/internal/imageprocessor"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+// The hot endpoints answer with fixed structs rather than gin.H maps: encoding a
+// struct reuses the field encoders cached for its type, while a map is walked by
+// reflection and has its keys sorted on every response. c.JSON encodes them with
+// gin's JSON package, so building with -tags go_json, jsoniter or sonic swaps in a
+// faster encoder without touching the handlers.
+
+// verifyResponse is the body of a successful POST /verify.
+type verifyResponse struct {
+	RequestID  string                    `json:"request_id"`
+	Verified   bool                      `json:"verified"`
+	Score      float32                   `json:"score"`
+	Message    string                    `json:"message"`
+	Metadata   *verifyMetadata           `json:"metadata,omitempty"`
+	CreatedAt  *time.Time                `json:"created_at,omitempty"`
+	Categories []usecase.CategoryOutcome `json:"categories,omitempty"`
+	// Verdict is the outcome the score policy gave the image.
+	Verdict string `json:"verdict,omitempty"`
+	// DuplicateOf names the earlier verification whose result answered the upload.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+type verifyMetadata struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Success      bool      `json:"success"`
+	Score        float32   `json:"score"`
+	RawScore     float32   `json:"raw_score"`
+	ModelVersion string    `json:"model_version"`
+}
+
+func newVerifyResponse(requestID string, result *imageprocessor.Result, metadata *usecase.VerificationMetadata) verifyResponse {
+	response := verifyResponse{
+		RequestID: requestID,
+		Verified:  result.Success,
+		Score:     result.Score,
+		Message:   result.Message,
+	}
+	if metadata != nil {
+		response.Metadata = &verifyMetadata{
+			Timestamp:    metadata.Timestamp,
+			Success:      metadata.Success,
+			Score:        metadata.Score,
+			RawScore:     metadata.RawScore,
+			ModelVersion: metadata.ModelVersion,
+		}
+		response.CreatedAt = &metadata.Timestamp
+		response.Categories = metadata.Categories
+		response.Verdict = metadata.Verdict
+		response.DuplicateOf = metadata.DuplicateOf
+	}
+	return response
+}
+
+// queuedResponse is the body of a POST /verify queued while the image processor is
+// unavailable.
+type queuedResponse struct {
+	RequestID string `json:"request_id"`
+	Status    string `json:"status"`
+}
+
+// logStatus is the status of log, completed for logs saved before statuses were.
+func logStatus(log *repository.VerificationLog) string {
+	if log.Status == "" {
+		return repository.StatusCompleted
+	}
+	return log.Status
+}
+
+// resultResponse is the body of GET /result/:id.
+type resultResponse struct {
+	RequestID    string                    `json:"request_id"`
+	UserID       string                    `json:"user_id"`
+	Score        float32                   `json:"score"`
+	RawScore     float32                   `json:"raw_score"`
+	ModelVersion string                    `json:"model_version"`
+	Success      bool                      `json:"success"`
+	Details      string                    `json:"details"`
+	Status       string                    `json:"status"`
+	SHA256Hash   string                    `json:"sha256_hash"`
+	SHA1Hash     string                    `json:"sha1_hash"`
+	CreatedAt    time.Time                 `json:"created_at"`
+	Categories   []usecase.CategoryOutcome `json:"categories"`
+	// Verdict is empty while the verification is queued and for verifications made
+	// before verdicts were recorded.
+	Verdict string `json:"verdict,omitempty"`
+	// MalwareScan is clean or infected when a malware scanner checked the image.
+	MalwareScan      string `json:"malware_scan,omitempty"`
+	MalwareSignature string `json:"malware_signature,omitempty"`
+	// ImageMetadata is the capture metadata read from the image's EXIF block, when
+	// it had one.
+	ImageMetadata *imagemeta.Metadata `json:"image_metadata,omitempty"`
+}
+
+func newResultResponse(log *repository.VerificationLog) resultResponse {
+	return resultResponse{
+		RequestID:        log.RequestID,
+		UserID:           log.UserID,
+		Score:            log.Score,
+		RawScore:         log.RawScore,
+		ModelVersion:     log.ModelVersion,
+		Success:          log.Success,
+		Details:          log.Details,
+		Status:           logStatus(log),
+		SHA256Hash:       log.SHA256Hash,
+		SHA1Hash:         log.SHA1Hash,
+		CreatedAt:        log.CreatedAt,
+		Categories:       usecase.CategoryOutcomes(log.Categories),
+		Verdict:          log.Verdict,
+		MalwareScan:      log.MalwareScan,
+		MalwareSignature: log.MalwareSignature,
+		ImageMetadata:    log.ImageMetadata,
+	}
+}
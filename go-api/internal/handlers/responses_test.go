@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/imageprocessor"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+func sampleVerification() (*imageprocessor.Result, *usecase.VerificationMetadata) {
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	categories := []usecase.CategoryOutcome{
+		{Category: imageprocessor.CategoryAIGenerated, Score: 0.91, Threshold: 0.5, Flagged: true},
+		{Category: imageprocessor.CategoryNSFW, Score: 0.02, Threshold: 0.5},
+	}
+	result := &imageprocessor.Result{Success: true, Score: 0.87, Message: "ok", ModelVersion: "v3"}
+	metadata := &usecase.VerificationMetadata{Timestamp: timestamp, Success: true, Score: 0.87, RawScore: 0.8, ModelVersion: "v3", Categories: categories}
+	return result, metadata
+}
+
+func sampleLog() *repository.VerificationLog {
+	return &repository.VerificationLog{
+		RequestID:    "req-1",
+		UserID:       "user-1",
+		Score:        0.87,
+		RawScore:     0.8,
+		ModelVersion: "v3",
+		Success:      true,
+		Details:      "status:true score:0.870000",
+		SHA256Hash:   "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		SHA1Hash:     "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+		CreatedAt:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Categories: []repository.VerificationCategory{
+			{Category: imageprocessor.CategoryAIGenerated, Score: 0.91, Threshold: 0.5, Flagged: true},
+		},
+	}
+}
+
+// verifyMap is the gin.H the /verify handler answered with before its response
+// struct; the struct must encode to the same document.
+func verifyMap(requestID string, result *imageprocessor.Result, metadata *usecase.VerificationMetadata) gin.H {
+	return gin.H{
+		"request_id": requestID,
+		"verified":   result.Success,
+		"score":      result.Score,
+		"message":    result.Message,
+		"metadata": gin.H{
+			"timestamp":     metadata.Timestamp,
+			"success":       metadata.Success,
+			"score":         metadata.Score,
+			"raw_score":     metadata.RawScore,
+			"model_version": metadata.ModelVersion,
+		},
+		"created_at": metadata.Timestamp,
+		"categories": metadata.Categories,
+	}
+}
+
+func resultMap(log *repository.VerificationLog) gin.H {
+	return gin.H{
+		"request_id":    log.RequestID,
+		"user_id":       log.UserID,
+		"score":         log.Score,
+		"raw_score":     log.RawScore,
+		"model_version": log.ModelVersion,
+		"success":       log.Success,
+		"details":       log.Details,
+		"status":        logStatus(log),
+		"sha256_hash":   log.SHA256Hash,
+		"sha1_hash":     log.SHA1Hash,
+		"created_at":    log.CreatedAt,
+		"categories":    usecase.CategoryOutcomes(log.Categories),
+	}
+}
+
+func decodeJSON(t *testing.T, v interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	return decoded
+}
+
+func TestResponseStructsEncodeLikeTheirMaps(t *testing.T) {
+	result, metadata := sampleVerification()
+	if got, want := decodeJSON(t, newVerifyResponse("req-1", result, metadata)), decodeJSON(t, verifyMap("req-1", result, metadata)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("verify response changed:\n got %v\nwant %v", got, want)
+	}
+	log := sampleLog()
+	if got, want := decodeJSON(t, newResultResponse(log)), decodeJSON(t, resultMap(log)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("result response changed:\n got %v\nwant %v", got, want)
+	}
+}
+
+// benchmarkRender measures c.JSON end to end, as the handlers call it.
+func benchmarkRender(b *testing.B, body func() interface{}) {
+	gin.SetMode(gin.ReleaseMode)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		for pb.Next() {
+			recorder.Body.Reset()
+			c.JSON(http.StatusOK, body())
+		}
+	})
+}
+
+func BenchmarkVerifyResponse(b *testing.B) {
+	result, metadata := sampleVerification()
+	b.Run("map", func(b *testing.B) {
+		benchmarkRender(b, func() interface{} { return verifyMap("req-1", result, metadata) })
+	})
+	b.Run("struct", func(b *testing.B) {
+		benchmarkRender(b, func() interface{} { return newVerifyResponse("req-1", result, metadata) })
+	})
+}
+
+func BenchmarkResultResponse(b *testing.B) {
+	log := sampleLog()
+	b.Run("map", func(b *testing.B) {
+		benchmarkRender(b, func() interface{} { return resultMap(log) })
+	})
+	b.Run("struct", func(b *testing.B) {
+		benchmarkRender(b, func() interface{} { return newResultResponse(log) })
+	})
+}
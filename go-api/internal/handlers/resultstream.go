@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/resultstream"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+// resultStreamHeartbeat is how often an idle event stream gets a comment, so proxies
+// do not close it while a verification waits in the queue.
+const resultStreamHeartbeat = 15 * time.Second
+
+// serveResultStream sends the result of requestID as it is now, and again once it
+// leaves the queue. It streams over a WebSocket when the request asks to upgrade,
+// and as server-sent events otherwise. The stream ends after the final result.
+func serveResultStream(c *gin.Context, uc *usecase.VerificationUseCase, hub *resultstream.Hub, userID, requestID string) {
+	ctx := c.Request.Context()
+	// Subscribing before reading the result means a completion in between is not
+	// missed.
+	updates, cancel, err := hub.Subscribe(ctx, requestID)
+	if err != nil {
+		httperr.Write(c, httperr.CodeInternal, "failed to watch result")
+		return
+	}
+	defer cancel()
+	log, err := uc.GetResult(ctx, userID, requestID)
+	if err != nil {
+		httperr.Write(c, httperr.CodeResultNotFound, "result not found")
+		return
+	}
+	reload := func() (*repository.VerificationLog, error) {
+		return uc.GetResult(ctx, userID, requestID)
+	}
+
+	if c.IsWebsocket() {
+		server := websocket.Server{Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+			// Clients do not send anything; a failed read means they went away.
+			streamCtx, stop := context.WithCancel(ctx)
+			defer stop()
+			go func() {
+				defer stop()
+				var discard []byte
+				for websocket.Message.Receive(conn, &discard) == nil {
+				}
+			}()
+			streamResult(streamCtx, log, updates, reload, func(result resultResponse) error {
+				_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+				return websocket.JSON.Send(conn, result)
+			}, nil)
+		}}
+		server.ServeHTTP(c.Writer, c.Request)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Accel-Buffering", "no")
+	streamResult(ctx, log, updates, reload, func(result resultResponse) error {
+		c.SSEvent("result", result)
+		c.Writer.Flush()
+		return nil
+	}, func() error {
+		if _, err := c.Writer.WriteString(": keepalive\n\n"); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+}
+
+// streamResult sends log, and while it is queued waits for an update to send it
+// again as reload returns it. heartbeat, when set, is called while waiting.
+func streamResult(ctx context.Context, log *repository.VerificationLog, updates <-chan resultstream.Update, reload func() (*repository.VerificationLog, error), send func(resultResponse) error, heartbeat func() error) {
+	ticker := time.NewTicker(resultStreamHeartbeat)
+	defer ticker.Stop()
+	for {
+		if err := send(newResultResponse(log)); err != nil || logStatus(log) != repository.StatusQueued {
+			return
+		}
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-updates:
+				if !ok {
+					return
+				}
+				break wait
+			case <-ticker.C:
+				if heartbeat != nil && heartbeat() != nil {
+					return
+				}
+			}
+		}
+		next, err := reload()
+		if err != nil {
+			return
+		}
+		log = next
+	}
+}
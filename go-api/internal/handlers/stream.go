@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/httperr"
+)
+
+// Stream formats of the endpoints that send large result sets in batches.
+const (
+	// streamNDJSON writes one JSON object per line.
+	streamNDJSON = "ndjson"
+	// streamJSON writes one JSON object holding every item in an array.
+	streamJSON = "json"
+)
+
+// jsonStream writes items to the response as they are loaded instead of building
+// the whole payload first. The status is sent with the first item, so a failure
+// part way through can only truncate the body; a JSON array is then left open.
+type jsonStream struct {
+	c       *gin.Context
+	format  string
+	encoder *json.Encoder
+	// key names the array of a streamJSON response.
+	key     string
+	started bool
+	written int
+}
+
+// newStream reads the format query parameter, falling back to fallback. It answers
+// the request itself and returns false for unknown formats.
+func newStream(c *gin.Context, fallback, key string) (*jsonStream, bool) {
+	format := c.DefaultQuery("format", fallback)
+	if format != streamNDJSON && format != streamJSON {
+		httperr.InvalidParameter(c, "format", "format must be ndjson or json")
+		return nil, false
+	}
+	return &jsonStream{c: c, format: format, encoder: json.NewEncoder(c.Writer), key: key}, true
+}
+
+// start sends the headers and, for streamJSON, opens the array.
+func (s *jsonStream) start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	if s.format == streamNDJSON {
+		s.c.Header("Content-Type", "application/x-ndjson")
+		s.c.Status(http.StatusOK)
+		return nil
+	}
+	s.c.Header("Content-Type", "application/json; charset=utf-8")
+	s.c.Status(http.StatusOK)
+	_, err := s.c.Writer.WriteString(`{"` + s.key + `":[`)
+	return err
+}
+
+// write encodes one item.
+func (s *jsonStream) write(item any) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	if s.format == streamJSON && s.written > 0 {
+		if _, err := s.c.Writer.WriteString(","); err != nil {
+			return err
+		}
+	}
+	s.written++
+	return s.encoder.Encode(item)
+}
+
+// flush sends the items written so far; call it after every batch.
+func (s *jsonStream) flush() {
+	s.c.Writer.Flush()
+}
+
+// finish completes the response. When the stream failed before anything was sent it
+// returns false so the caller can still answer with an error; after that, err is
+// only recorded on the context.
+func (s *jsonStream) finish(err error) bool {
+	if err != nil {
+		if !s.started {
+			return false
+		}
+		_ = s.c.Error(err)
+		return true
+	}
+	if err := s.start(); err != nil {
+		return true
+	}
+	if s.format == streamJSON {
+		_, _ = s.c.Writer.WriteString("]}")
+	}
+	s.flush()
+	return true
+}
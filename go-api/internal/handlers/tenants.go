@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/tenants"
+	"github.com/example/ai-check/internal/webhooks"
+)
+
+const (
+	defaultTenantPageSize = 50
+	maxTenantPageSize     = 500
+)
+
+// checkTenantUpload enforces the accepted upload types and the monthly quota of the
+// caller's tenant for an upload of contentType, answering 415 or 429.
+func checkTenantUpload(c *gin.Context, store *tenants.Store, contentType string) bool {
+	tenantID, ok := auth.GetTenantID(c.Request.Context())
+	if !ok {
+		return true
+	}
+	err := store.CheckUpload(c.Request.Context(), tenantID, contentType)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, tenants.ErrContentTypeNotAllowed):
+		httperr.Write(c, httperr.CodeUnsupportedMediaType, "content type not allowed for tenant")
+	case errors.Is(err, tenants.ErrQuotaExceeded):
+		httperr.Write(c, httperr.CodeQuotaExceeded, "monthly verification quota exceeded")
+	case errors.Is(err, tenants.ErrInvalidTenant):
+		httperr.Write(c, httperr.CodeUnauthorized, "invalid tenant claim")
+	default:
+		httperr.Write(c, httperr.CodeInternal, "failed to check tenant settings")
+	}
+	return false
+}
+
+// RegisterTenantAdminRoutes exposes tenant settings, and the tenants' webhook
+// endpoints when hooks is set. Mount them only on the admin listener.
+func RegisterTenantAdminRoutes(router gin.IRouter, store *tenants.Store, hooks *webhooks.Service) {
+	group := router.Group("/admin/api/tenants")
+
+	group.GET("", func(c *gin.Context) {
+		limit, ok := limitQuery(c, defaultTenantPageSize, maxTenantPageSize)
+		if !ok {
+			return
+		}
+		page, err := store.List(c.Request.Context(), c.Query("cursor"), limit)
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to list tenants")
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	})
+
+	group.GET("/:id", func(c *gin.Context) {
+		tenant, err := store.Get(c.Request.Context(), c.Param("id"))
+		writeTenant(c, tenant, err)
+	})
+
+	group.PUT("/:id", func(c *gin.Context) {
+		var settings tenants.Settings
+		if err := c.ShouldBindJSON(&settings); err != nil {
+			httperr.InvalidBody(c, err)
+			return
+		}
+		tenant, err := store.Put(c.Request.Context(), c.Param("id"), settings)
+		writeTenant(c, tenant, err)
+	})
+
+	group.DELETE("/:id", func(c *gin.Context) {
+		deleted, err := store.Delete(c.Request.Context(), c.Param("id"))
+		switch {
+		case errors.Is(err, tenants.ErrInvalidTenant):
+			httperr.InvalidParameter(c, "id", err.Error())
+		case err != nil:
+			httperr.Write(c, httperr.CodeInternal, "failed to delete tenant settings")
+		case !deleted:
+			httperr.Write(c, httperr.CodeNotFound, "tenant has no settings")
+		default:
+			c.Status(http.StatusNoContent)
+		}
+	})
+
+	if hooks == nil {
+		return
+	}
+
+	webhooksGroup := group.Group("/:id/webhooks", func(c *gin.Context) {
+		if err := tenants.ValidateID(c.Param("id")); err != nil {
+			httperr.InvalidParameter(c, "id", err.Error())
+		}
+	})
+
+	webhooksGroup.GET("", func(c *gin.Context) {
+		endpoints, err := hooks.ListEndpoints(c.Request.Context(), webhooks.TenantOwner(c.Param("id")))
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to list webhooks")
+			return
+		}
+		items := make([]gin.H, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			items = append(items, webhookEndpointResponse(endpoint))
+		}
+		c.JSON(http.StatusOK, gin.H{"webhooks": items})
+	})
+
+	webhooksGroup.POST("", func(c *gin.Context) {
+		var request struct {
+			URL    string   `json:"url"`
+			Events []string `json:"events"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.URL == "" {
+			httperr.InvalidParameter(c, "url", "url is required")
+			return
+		}
+		endpoint, err := hooks.CreateEndpoint(c.Request.Context(), webhooks.TenantOwner(c.Param("id")), request.URL, request.Events)
+		if err != nil {
+			writeWebhookError(c, err)
+			return
+		}
+		response := webhookEndpointResponse(endpoint)
+		response["secret"] = endpoint.Secret
+		c.JSON(http.StatusCreated, response)
+	})
+
+	webhooksGroup.DELETE("/:webhook_id", func(c *gin.Context) {
+		if err := hooks.DeleteEndpoint(c.Request.Context(), webhooks.TenantOwner(c.Param("id")), c.Param("webhook_id")); err != nil {
+			writeWebhookError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
+
+func writeTenant(c *gin.Context, tenant *tenants.Tenant, err error) {
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, tenant)
+	case errors.Is(err, tenants.ErrInvalidTenant):
+		httperr.InvalidParameter(c, "id", err.Error())
+	case errors.Is(err, tenants.ErrInvalidSettings):
+		httperr.Write(c, httperr.CodeInvalidRequest, err.Error())
+	default:
+		httperr.Write(c, httperr.CodeInternal, "failed to load tenant settings")
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/httperr"
+)
+
+type tokenRequest struct {
+	ClientID     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
+
+type revokeRequest struct {
+	Token string `json:"token" form:"token"`
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+}
+
+func newTokenResponse(pair *auth.TokenPair) tokenResponse {
+	now := time.Now()
+	return tokenResponse{
+		AccessToken:      pair.AccessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(pair.AccessExpiresAt.Sub(now).Round(time.Second).Seconds()),
+		RefreshToken:     pair.RefreshToken,
+		RefreshExpiresIn: int64(pair.RefreshExpiresAt.Sub(now).Round(time.Second).Seconds()),
+	}
+}
+
+// RegisterTokenRoutes issues, refreshes and revokes the tokens of issuer's clients.
+// The routes authenticate their callers themselves. Bodies are JSON or form encoded.
+func RegisterTokenRoutes(router gin.IRouter, issuer *auth.Issuer) {
+	router.POST("/auth/token", func(c *gin.Context) {
+		var req tokenRequest
+		if err := c.ShouldBind(&req); err != nil {
+			httperr.InvalidBody(c, err)
+			return
+		}
+		// Clients may also authenticate with HTTP Basic, as in OAuth 2.0.
+		if id, secret, ok := c.Request.BasicAuth(); ok && req.ClientID == "" {
+			req.ClientID, req.ClientSecret = id, secret
+		}
+		if req.ClientID == "" {
+			httperr.InvalidParameter(c, "client_id", "client_id is required")
+			return
+		}
+
+		pair, err := issuer.IssueForClient(c.Request.Context(), req.ClientID, req.ClientSecret)
+		if errors.Is(err, auth.ErrInvalidClient) {
+			httperr.Write(c, httperr.CodeUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to issue token")
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, newTokenResponse(pair))
+	})
+
+	router.POST("/auth/refresh", func(c *gin.Context) {
+		var req refreshRequest
+		if err := c.ShouldBind(&req); err != nil || req.RefreshToken == "" {
+			httperr.InvalidParameter(c, "refresh_token", "refresh_token is required")
+			return
+		}
+
+		pair, err := issuer.Refresh(c.Request.Context(), req.RefreshToken)
+		if errors.Is(err, auth.ErrInvalidGrant) {
+			httperr.Write(c, httperr.CodeUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to refresh token")
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, newTokenResponse(pair))
+	})
+
+	router.POST("/auth/revoke", func(c *gin.Context) {
+		var req revokeRequest
+		if err := c.ShouldBind(&req); err != nil || req.Token == "" {
+			httperr.InvalidParameter(c, "token", "token is required")
+			return
+		}
+		if err := issuer.Revoke(c.Request.Context(), req.Token); err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to revoke token")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
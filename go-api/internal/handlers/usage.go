@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/metering"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/users"
+)
+
+// defaultUsageMonths is how many months GET /usage returns without ?from.
+const defaultUsageMonths = 12
+
+// RegisterUsageRoutes exposes the caller's billable usage when meter is set, and
+// their monthly quota when accounts is set. The router must already authenticate
+// requests.
+func RegisterUsageRoutes(router gin.IRouter, meter *metering.Meter, accounts *users.Service) {
+	router.GET("/usage", func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+
+		var quota *users.Quota
+		if accounts != nil {
+			var err error
+			if quota, err = accounts.Quota(c.Request.Context(), userID); err != nil {
+				httperr.Write(c, httperr.CodeInternal, "failed to load quota")
+				return
+			}
+		}
+		if meter == nil {
+			c.JSON(http.StatusOK, gin.H{"quota": quota})
+			return
+		}
+
+		now := time.Now().UTC()
+		to, err := periodQuery(c, "to", metering.Period(now))
+		if err != nil {
+			return
+		}
+		from, err := periodQuery(c, "from", metering.Period(now.AddDate(0, 1-defaultUsageMonths, 0)))
+		if err != nil {
+			return
+		}
+		if from > to {
+			httperr.InvalidParameter(c, "from", "from must not be after to")
+			return
+		}
+
+		records, err := meter.Usage(c.Request.Context(), userID, from, to)
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to load usage")
+			return
+		}
+		writeUsage(c, records, "usage-"+from+"-"+to+".csv", quota)
+	})
+}
+
+// RegisterUsageAdminRoutes exposes usage of all users and billing account links.
+// Mount them only on the admin listener.
+func RegisterUsageAdminRoutes(router gin.IRouter, meter *metering.Meter) {
+	api := router.Group("/admin/api")
+
+	api.GET("/usage", func(c *gin.Context) {
+		period, err := periodQuery(c, "period", metering.Period(time.Now()))
+		if err != nil {
+			return
+		}
+		records, err := meter.PeriodUsage(c.Request.Context(), period)
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to load usage")
+			return
+		}
+		writeUsage(c, records, "usage-"+period+".csv", nil)
+	})
+
+	api.PUT("/billing-accounts/:user_id", func(c *gin.Context) {
+		var request struct {
+			StripeCustomerID string `json:"stripe_customer_id"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			httperr.InvalidBody(c, err)
+			return
+		}
+		if err := meter.SetBillingAccount(c.Request.Context(), c.Param("user_id"), request.StripeCustomerID); err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to save billing account")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user_id": c.Param("user_id"), "stripe_customer_id": request.StripeCustomerID})
+	})
+}
+
+// periodQuery reads a YYYY-MM query parameter, answering 400 when it is malformed.
+func periodQuery(c *gin.Context, name, fallback string) (string, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	period, err := metering.ParsePeriod(raw)
+	if err != nil {
+		httperr.InvalidParameter(c, name, name+" must be a month formatted YYYY-MM")
+		return "", err
+	}
+	return period, nil
+}
+
+// writeUsage answers with JSON, or CSV when ?format=csv. quota, when set, is added to
+// the JSON.
+func writeUsage(c *gin.Context, records []*repository.UsageRecord, filename string, quota *users.Quota) {
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Status(http.StatusOK)
+		if err := metering.WriteCSV(c.Writer, records); err != nil {
+			_ = c.Error(err)
+		}
+	case "json":
+		items := make([]gin.H, 0, len(records))
+		for _, record := range records {
+			items = append(items, gin.H{
+				"user_id":        record.UserID,
+				"period":         record.Period,
+				"units":          record.Units,
+				"verifications":  record.Verifications,
+				"reported_units": record.ReportedUnits,
+				"updated_at":     record.UpdatedAt,
+			})
+		}
+		body := gin.H{"usage": items}
+		if quota != nil {
+			body["quota"] = quota
+		}
+		c.JSON(http.StatusOK, body)
+	default:
+		httperr.InvalidParameter(c, "format", "format must be json or csv")
+	}
+}
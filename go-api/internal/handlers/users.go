@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/users"
+)
+
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 500
+	defaultFailureLimit = 20
+	maxFailureLimit     = 200
+)
+
+// RequireActiveUser rejects requests of suspended users with 403. It must run after
+// the authentication middleware.
+func RequireActiveUser(service *users.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			c.Next()
+			return
+		}
+		if err := service.CheckActive(c.Request.Context(), userID); err != nil {
+			if errors.Is(err, users.ErrSuspended) {
+				httperr.Write(c, httperr.CodeAccountSuspended, "account suspended")
+				return
+			}
+			httperr.Write(c, httperr.CodeInternal, "failed to check account status")
+			return
+		}
+		c.Next()
+	}
+}
+
+// checkUserQuota answers 429 once the user used up their monthly quota.
+func checkUserQuota(c *gin.Context, service *users.Service, userID string) bool {
+	err := service.CheckQuota(c.Request.Context(), userID)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, users.ErrQuotaExceeded):
+		httperr.Write(c, httperr.CodeQuotaExceeded, "monthly verification quota exceeded")
+	default:
+		httperr.Write(c, httperr.CodeInternal, "failed to check quota")
+	}
+	return false
+}
+
+// RegisterUserAdminRoutes exposes user management. Mount them only on the admin
+// listener.
+func RegisterUserAdminRoutes(router gin.IRouter, service *users.Service) {
+	group := router.Group("/admin/api/users")
+
+	group.GET("", func(c *gin.Context) {
+		limit, ok := limitQuery(c, defaultUserPageSize, maxUserPageSize)
+		if !ok {
+			return
+		}
+		page, err := service.List(c.Request.Context(), c.Query("cursor"), c.Query("prefix"), limit)
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to list users")
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	})
+
+	group.GET("/:id", func(c *gin.Context) {
+		user, err := service.Get(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to load user")
+			return
+		}
+		c.JSON(http.StatusOK, user)
+	})
+
+	group.POST("/:id/suspend", func(c *gin.Context) {
+		var request struct {
+			Reason string `json:"reason"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&request); err != nil {
+				httperr.InvalidBody(c, err)
+				return
+			}
+		}
+		user, err := service.Suspend(c.Request.Context(), c.Param("id"), request.Reason)
+		writeUser(c, user, err)
+	})
+
+	group.POST("/:id/unsuspend", func(c *gin.Context) {
+		user, err := service.Unsuspend(c.Request.Context(), c.Param("id"))
+		writeUser(c, user, err)
+	})
+
+	group.PUT("/:id/plan", func(c *gin.Context) {
+		var request struct {
+			Tier         string `json:"tier"`
+			MonthlyQuota int64  `json:"monthly_quota"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			httperr.InvalidBody(c, err)
+			return
+		}
+		user, err := service.SetPlan(c.Request.Context(), c.Param("id"), request.Tier, request.MonthlyQuota)
+		writeUser(c, user, err)
+	})
+
+	group.GET("/:id/failures", func(c *gin.Context) {
+		limit, ok := limitQuery(c, defaultFailureLimit, maxFailureLimit)
+		if !ok {
+			return
+		}
+		logs, err := service.RecentFailures(c.Request.Context(), c.Param("id"), limit)
+		if err != nil {
+			httperr.Write(c, httperr.CodeInternal, "failed to load failures")
+			return
+		}
+		items := make([]gin.H, 0, len(logs))
+		for _, log := range logs {
+			items = append(items, gin.H{
+				"request_id":  log.RequestID,
+				"score":       log.Score,
+				"details":     log.Details,
+				"sha256_hash": log.SHA256Hash,
+				"sha1_hash":   log.SHA1Hash,
+				"created_at":  log.CreatedAt,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"failures": items})
+	})
+}
+
+func writeUser(c *gin.Context, user *users.User, err error) {
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, user)
+	case errors.Is(err, users.ErrInvalidTier), errors.Is(err, users.ErrInvalidQuota):
+		httperr.Write(c, httperr.CodeInvalidRequest, err.Error())
+	default:
+		httperr.Write(c, httperr.CodeInternal, "failed to update user")
+	}
+}
+
+// limitQuery reads ?limit, answering 400 when it is outside 1..max.
+func limitQuery(c *gin.Context, fallback, max int) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return fallback, true
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > max {
+		httperr.InvalidParameter(c, "limit", "limit must be between 1 and "+strconv.Itoa(max))
+		return 0, false
+	}
+	return limit, true
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/httperr"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/webhooks"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// RegisterWebhookRoutes exposes webhook endpoint management and delivery logs. The
+// router must already authenticate requests; changes need the auth.ScopeVerifyWrite
+// scope.
+func RegisterWebhookRoutes(router gin.IRouter, service *webhooks.Service) {
+	group := router.Group("/webhooks")
+
+	group.POST("", auth.RequireScope(auth.ScopeVerifyWrite), func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+
+		var request struct {
+			URL    string   `json:"url"`
+			Events []string `json:"events"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.URL == "" {
+			httperr.InvalidParameter(c, "url", "url is required")
+			return
+		}
+
+		endpoint, err := service.CreateEndpoint(c.Request.Context(), userID, request.URL, request.Events)
+		if err != nil {
+			writeWebhookError(c, err)
+			return
+		}
+		response := webhookEndpointResponse(endpoint)
+		response["secret"] = endpoint.Secret
+		c.JSON(http.StatusCreated, response)
+	})
+
+	group.GET("", func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+
+		endpoints, err := service.ListEndpoints(c.Request.Context(), userID)
+		if err != nil {
+			writeWebhookError(c, err)
+			return
+		}
+		items := make([]gin.H, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			items = append(items, webhookEndpointResponse(endpoint))
+		}
+		c.JSON(http.StatusOK, gin.H{"webhooks": items})
+	})
+
+	group.DELETE("/:id", auth.RequireScope(auth.ScopeVerifyWrite), func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+
+		if err := service.DeleteEndpoint(c.Request.Context(), userID, c.Param("id")); err != nil {
+			writeWebhookError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	group.GET("/:id/deliveries", func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+
+		limit := defaultDeliveryLimit
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > maxDeliveryLimit {
+				httperr.InvalidParameter(c, "limit", "limit must be between 1 and "+strconv.Itoa(maxDeliveryLimit))
+				return
+			}
+			limit = parsed
+		}
+
+		deliveries, err := service.Deliveries(c.Request.Context(), userID, c.Param("id"), limit)
+		if err != nil {
+			writeWebhookError(c, err)
+			return
+		}
+		items := make([]gin.H, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			items = append(items, webhookDeliveryResponse(delivery))
+		}
+		c.JSON(http.StatusOK, gin.H{"deliveries": items})
+	})
+
+	group.POST("/:id/deliveries/:delivery_id/replay", auth.RequireScope(auth.ScopeVerifyWrite), func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c.Request.Context())
+		if !ok {
+			httperr.Write(c, httperr.CodeUnauthorized, "unauthorized")
+			return
+		}
+
+		delivery, err := service.Replay(c.Request.Context(), userID, c.Param("id"), c.Param("delivery_id"))
+		if err != nil {
+			writeWebhookError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, webhookDeliveryResponse(delivery))
+	})
+}
+
+func writeWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, webhooks.ErrInvalidURL), errors.Is(err, webhooks.ErrUnknownEvent):
+		httperr.Write(c, httperr.CodeInvalidRequest, err.Error())
+	case errors.Is(err, webhooks.ErrTooManyEndpoints), errors.Is(err, webhooks.ErrDeliveryInProgress):
+		httperr.Write(c, httperr.CodeConflict, err.Error())
+	case errors.Is(err, webhooks.ErrNotFound):
+		httperr.Write(c, httperr.CodeNotFound, "webhook not found")
+	default:
+		httperr.Write(c, httperr.CodeInternal, "webhook request failed")
+	}
+}
+
+func webhookEndpointResponse(endpoint *repository.WebhookEndpoint) gin.H {
+	return gin.H{
+		"id":         endpoint.ID,
+		"url":        endpoint.URL,
+		"events":     endpoint.EventList(),
+		"created_at": endpoint.CreatedAt,
+	}
+}
+
+func webhookDeliveryResponse(delivery *repository.WebhookDelivery) gin.H {
+	return gin.H{
+		"id":            delivery.ID,
+		"event":         delivery.Event,
+		"event_id":      delivery.EventID,
+		"status":        delivery.Status,
+		"attempts":      delivery.Attempts,
+		"response_code": delivery.ResponseCode,
+		"response_body": delivery.ResponseBody,
+		"last_error":    delivery.LastError,
+		"payload":       json.RawMessage(delivery.Payload),
+		"created_at":    delivery.CreatedAt,
+		"updated_at":    delivery.UpdatedAt,
+		"delivered_at":  delivery.DeliveredAt,
+	}
+}
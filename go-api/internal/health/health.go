@@ -0,0 +1,128 @@
+// Package health aggregates dependency checks into a readiness report.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports whether a dependency is currently usable.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name    string
+	check   Check
+	timeout time.Duration
+}
+
+// Checker runs the registered checks concurrently, each bounded by a timeout.
+type Checker struct {
+	timeout  time.Duration
+	draining atomic.Bool
+
+	mu     sync.RWMutex
+	checks []namedCheck
+}
+
+// Report is the outcome of a readiness evaluation. Checks maps each dependency to
+// "ok" or the error it returned; Components details the same outcomes.
+type Report struct {
+	Ready      bool                 `json:"ready"`
+	Draining   bool                 `json:"draining,omitempty"`
+	Checks     map[string]string    `json:"checks"`
+	Components map[string]Component `json:"components"`
+}
+
+// Component statuses.
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Component is the outcome of the check of one dependency.
+type Component struct {
+	Status string `json:"status"`
+	// Error is the error of a failed check.
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+	// TimeoutMs is how long the check may take.
+	TimeoutMs int64 `json:"timeout_ms"`
+}
+
+// NewChecker creates a Checker whose checks time out after timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a named dependency check bounded by the checker's timeout.
+func (c *Checker) Register(name string, check Check) {
+	c.RegisterWithTimeout(name, 0, check)
+}
+
+// RegisterWithTimeout adds a named dependency check bounded by its own timeout, so a
+// dependency that is slow to answer does not need to hold up the others. A zero
+// timeout uses the checker's.
+func (c *Checker) RegisterWithTimeout(name string, timeout time.Duration, check Check) {
+	if timeout <= 0 {
+		timeout = c.timeout
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check, timeout: timeout})
+}
+
+// SetDraining marks the instance as shutting down. A draining instance reports not
+// ready regardless of its dependencies, so traffic moves elsewhere before it stops.
+func (c *Checker) SetDraining(draining bool) {
+	c.draining.Store(draining)
+}
+
+// Check evaluates every registered check.
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{Ready: false, Draining: true, Checks: map[string]string{}, Components: map[string]Component{}}
+	}
+
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make([]error, len(checks))
+	latencies := make([]time.Duration, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, nc namedCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, nc.timeout)
+			defer cancel()
+			started := time.Now()
+			results[i] = nc.check(ctx)
+			latencies[i] = time.Since(started)
+		}(i, nc)
+	}
+	wg.Wait()
+
+	report := Report{
+		Ready:      true,
+		Checks:     make(map[string]string, len(checks)),
+		Components: make(map[string]Component, len(checks)),
+	}
+	for i, nc := range checks {
+		component := Component{
+			Status:    StatusUp,
+			LatencyMs: float64(latencies[i].Microseconds()) / 1000,
+			TimeoutMs: nc.timeout.Milliseconds(),
+		}
+		report.Checks[nc.name] = "ok"
+		if results[i] != nil {
+			report.Ready = false
+			report.Checks[nc.name] = results[i].Error()
+			component.Status, component.Error = StatusDown, results[i].Error()
+		}
+		report.Components[nc.name] = component
+	}
+	return report
+}
@@ -0,0 +1,74 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckerReportsEveryDependency(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Register("postgres", func(context.Context) error { return nil })
+	checker.Register("redis", func(context.Context) error { return errors.New("connection refused") })
+
+	report := checker.Check(context.Background())
+	if report.Ready {
+		t.Fatal("expected a failing check to make the report not ready")
+	}
+	if report.Checks["postgres"] != "ok" || report.Checks["redis"] != "connection refused" {
+		t.Fatalf("unexpected checks: %v", report.Checks)
+	}
+}
+
+func TestCheckerBoundsSlowChecks(t *testing.T) {
+	checker := NewChecker(10 * time.Millisecond)
+	checker.Register("processor", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	started := time.Now()
+	report := checker.Check(context.Background())
+	if report.Ready {
+		t.Fatal("expected a timed out check to fail")
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("expected the check to be cut off by the timeout, took %s", elapsed)
+	}
+}
+
+func TestCheckerBoundsEachCheckByItsOwnTimeout(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.RegisterWithTimeout("processor", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	checker.Register("postgres", func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		}
+	})
+
+	report := checker.Check(context.Background())
+	processor, postgres := report.Components["processor"], report.Components["postgres"]
+	if processor.Status != StatusDown || processor.Error == "" || processor.TimeoutMs != 10 {
+		t.Fatalf("expected the processor to time out after 10ms, got %+v", processor)
+	}
+	if postgres.Status != StatusUp || postgres.LatencyMs < 50 || postgres.TimeoutMs != 1000 {
+		t.Fatalf("expected postgres to outlast the processor's timeout, got %+v", postgres)
+	}
+}
+
+func TestCheckerReportsNotReadyWhileDraining(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Register("postgres", func(context.Context) error { return nil })
+
+	checker.SetDraining(true)
+	if report := checker.Check(context.Background()); report.Ready || !report.Draining {
+		t.Fatalf("expected a draining instance to report not ready, got %+v", report)
+	}
+}
@@ -0,0 +1,195 @@
+// Package httperr defines the error responses of the HTTP API. Every error is a JSON
+// object with a stable code from the registry below, a human-readable message,
+// optional details and the correlation ID of the request:
+//
+//	{"code": "result_not_found", "message": "result not found", "request_id": "..."}
+//
+// Clients branch on the code; messages may change. New codes may be added, but a
+// code never changes its meaning or status.
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+// Code identifies the kind of error.
+type Code string
+
+// The code registry. Each code always comes with the same HTTP status.
+const (
+	// CodeInvalidRequest: the body, a parameter or a header is malformed or out of
+	// range. details.parameter names the offending parameter when there is one.
+	CodeInvalidRequest Code = "invalid_request"
+	// CodeUnauthorized: the bearer token is missing, invalid or expired.
+	CodeUnauthorized Code = "unauthorized"
+	// CodeAccountSuspended: the account of the token's subject is suspended.
+	CodeAccountSuspended Code = "account_suspended"
+	// CodeForbidden: the token does not grant the role the route requires, or a
+	// signed image URL is invalid or expired.
+	CodeForbidden Code = "forbidden"
+	// CodeNotFound: the route or the addressed resource does not exist.
+	CodeNotFound Code = "not_found"
+	// CodeResultNotFound: no verification with this ID exists for the caller.
+	CodeResultNotFound Code = "result_not_found"
+	// CodeImageNotStored: the verification exists, but its image was not kept.
+	CodeImageNotStored Code = "image_not_stored"
+	// CodeConflict: the request conflicts with the current state, e.g. a delivery
+	// that is still queued or a limit on the number of resources.
+	CodeConflict Code = "conflict"
+	// CodeDuplicateImage: the caller already verified the same image.
+	// details.existing_request_id names that verification.
+	CodeDuplicateImage Code = "duplicate_image"
+	// CodePayloadTooLarge: the upload exceeds the size limit.
+	CodePayloadTooLarge Code = "payload_too_large"
+	// CodeUnsupportedMediaType: the upload is not a supported image type.
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	// CodeUnprocessableImage: the upload is corrupt or does not match its declared
+	// SHA-256, its dimensions exceed the limits or the image processor rejected it.
+	// details.reason says which.
+	CodeUnprocessableImage Code = "unprocessable_image"
+	// CodeImageFetchFailed: the image at image_url could not be downloaded.
+	CodeImageFetchFailed Code = "image_fetch_failed"
+	// CodeQuotaExceeded: the caller's tenant used up its monthly verification quota.
+	CodeQuotaExceeded Code = "quota_exceeded"
+	// CodeRateLimited: the caller sent too many requests; retry after the
+	// Retry-After header. details.scope is "user" or "ip".
+	CodeRateLimited Code = "rate_limited"
+	// CodeProcessorBusy: the image processor is out of capacity; retry later.
+	CodeProcessorBusy Code = "processor_busy"
+	// CodeProcessorUnavailable: the image processor could not be reached.
+	CodeProcessorUnavailable Code = "processor_unavailable"
+	// CodeProcessorTimeout: the image processor did not answer in time.
+	CodeProcessorTimeout Code = "processor_timeout"
+	// CodeInternal: the server failed; retrying may help. Details are only logged.
+	CodeInternal Code = "internal"
+	// CodeOverloaded: too many requests are in flight; retry after the Retry-After
+	// header.
+	CodeOverloaded Code = "overloaded"
+	// CodeUnavailable: a service the request depends on, such as the token
+	// revocation list, is unreachable; retry after the Retry-After header.
+	CodeUnavailable Code = "unavailable"
+)
+
+var statuses = map[Code]int{
+	CodeInvalidRequest:       http.StatusBadRequest,
+	CodeUnauthorized:         http.StatusUnauthorized,
+	CodeAccountSuspended:     http.StatusForbidden,
+	CodeForbidden:            http.StatusForbidden,
+	CodeNotFound:             http.StatusNotFound,
+	CodeResultNotFound:       http.StatusNotFound,
+	CodeImageNotStored:       http.StatusNotFound,
+	CodeConflict:             http.StatusConflict,
+	CodeDuplicateImage:       http.StatusConflict,
+	CodePayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	CodeUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	CodeUnprocessableImage:   http.StatusUnprocessableEntity,
+	CodeImageFetchFailed:     http.StatusUnprocessableEntity,
+	CodeQuotaExceeded:        http.StatusTooManyRequests,
+	CodeRateLimited:          http.StatusTooManyRequests,
+	CodeProcessorBusy:        http.StatusTooManyRequests,
+	CodeProcessorUnavailable: http.StatusBadGateway,
+	CodeProcessorTimeout:     http.StatusGatewayTimeout,
+	CodeInternal:             http.StatusInternalServerError,
+	CodeOverloaded:           http.StatusServiceUnavailable,
+	CodeUnavailable:          http.StatusServiceUnavailable,
+}
+
+// Status returns the HTTP status of code, or 500 for codes not in the registry.
+func Status(code Code) int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Response is the body of every error response.
+type Response struct {
+	Code      Code                   `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+// Write responds with the error and aborts the remaining handlers.
+func Write(c *gin.Context, code Code, message string) {
+	WriteWithDetails(c, code, message, nil)
+}
+
+// WriteWithDetails responds with the error and machine-readable details, and aborts
+// the remaining handlers.
+func WriteWithDetails(c *gin.Context, code Code, message string, details map[string]interface{}) {
+	c.AbortWithStatusJSON(Status(code), Response{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: logging.RequestIDFromContext(c.Request.Context()),
+	})
+}
+
+// InvalidParameter responds with CodeInvalidRequest naming the offending parameter.
+func InvalidParameter(c *gin.Context, parameter, message string) {
+	WriteWithDetails(c, CodeInvalidRequest, message, map[string]interface{}{"parameter": parameter})
+}
+
+// InvalidBody responds with CodeInvalidRequest for a JSON body that could not be
+// decoded. details.reason is "empty_body", "malformed_json" (with the byte offset)
+// or "invalid_type" (with the parameter holding a value of the wrong type).
+func InvalidBody(c *gin.Context, err error) {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		WriteWithDetails(c, CodeInvalidRequest, fmt.Sprintf("%s must not be a JSON %s", typeErr.Field, typeErr.Value),
+			map[string]interface{}{"reason": "invalid_type", "parameter": typeErr.Field})
+	case errors.As(err, &syntaxErr):
+		WriteWithDetails(c, CodeInvalidRequest, "request body is not valid JSON",
+			map[string]interface{}{"reason": "malformed_json", "offset": syntaxErr.Offset})
+	case errors.Is(err, io.EOF):
+		WriteWithDetails(c, CodeInvalidRequest, "request body is empty", map[string]interface{}{"reason": "empty_body"})
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		WriteWithDetails(c, CodeInvalidRequest, "request body is not valid JSON", map[string]interface{}{"reason": "malformed_json"})
+	default:
+		Write(c, CodeInvalidRequest, "invalid request body")
+	}
+}
+
+// FromError returns the code of a failed call to the image processor by the gRPC
+// status it carries, also when wrapped in a *logging.OperationError. Failures
+// without such a status are CodeInternal.
+func FromError(err error) Code {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return CodeUnprocessableImage
+	case codes.ResourceExhausted:
+		return CodeProcessorBusy
+	case codes.Unavailable:
+		return CodeProcessorUnavailable
+	case codes.DeadlineExceeded:
+		return CodeProcessorTimeout
+	default:
+		return CodeInternal
+	}
+}
+
+// NotFound answers requests for unknown routes; register it with gin's NoRoute.
+func NotFound(c *gin.Context) {
+	Write(c, CodeNotFound, "route not found")
+}
+
+// Recovery turns panics into CodeInternal responses, like gin.Recovery does for
+// plain 500s.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, _ interface{}) {
+		Write(c, CodeInternal, "internal error")
+	})
+}
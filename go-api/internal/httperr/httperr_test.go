@@ -0,0 +1,68 @@
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+func TestInvalidBodyDescribesDecodingFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", func(c *gin.Context) {
+		var request struct {
+			Quota int64 `json:"monthly_quota"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			InvalidBody(c, err)
+		}
+	})
+
+	for body, want := range map[string]map[string]interface{}{
+		`{"monthly_quota": "ten"}`: {"reason": "invalid_type", "parameter": "monthly_quota"},
+		`{"monthly_quota": 10,}`:   {"reason": "malformed_json", "offset": float64(22)},
+		`{"monthly_quota": 1`:      {"reason": "malformed_json"},
+		``:                         {"reason": "empty_body"},
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		var response Response
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%q: decode response: %v", body, err)
+		}
+		if recorder.Code != http.StatusBadRequest || response.Code != CodeInvalidRequest {
+			t.Fatalf("%q: expected 400 invalid_request, got %d %+v", body, recorder.Code, response)
+		}
+		for key, value := range want {
+			if response.Details[key] != value {
+				t.Fatalf("%q: expected details.%s = %v, got %+v", body, key, value, response.Details)
+			}
+		}
+	}
+}
+
+func TestFromErrorMapsProcessorStatuses(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want Code
+	}{
+		{logging.NewOperationError("usecase.grpc_process_image", "req", status.Error(codes.InvalidArgument, "bad image")), CodeUnprocessableImage},
+		{status.Error(codes.ResourceExhausted, "busy"), CodeProcessorBusy},
+		{status.Error(codes.Unavailable, "down"), CodeProcessorUnavailable},
+		{status.Error(codes.DeadlineExceeded, "slow"), CodeProcessorTimeout},
+		{errors.New("database down"), CodeInternal},
+	} {
+		if got := FromError(tc.err); got != tc.want {
+			t.Errorf("FromError(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
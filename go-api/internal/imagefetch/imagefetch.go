@@ -0,0 +1,114 @@
+// Package imagefetch downloads the images users ask to verify by URL, such as
+// images they already host in object storage.
+package imagefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/example/ai-check/internal/safehttp"
+)
+
+var (
+	// ErrInvalidURL is returned for URLs that are malformed, carry credentials, use
+	// a scheme other than https or name a non-public host.
+	ErrInvalidURL = errors.New("invalid image url")
+	// ErrTooLarge is returned for images above the maximum size.
+	ErrTooLarge = errors.New("image is too large")
+	// ErrFetchFailed is returned when the image could not be downloaded: the host
+	// was unreachable, resolved to a non-public address, answered with another
+	// status than 200 or was too slow.
+	ErrFetchFailed = errors.New("image could not be downloaded")
+)
+
+// Options configures a Fetcher.
+type Options struct {
+	// Timeout bounds the whole download.
+	Timeout time.Duration
+	// MaxSize is the largest image downloaded, in bytes.
+	MaxSize int64
+	// AllowHTTP accepts plain http:// URLs besides https://.
+	AllowHTTP bool
+	// AllowPrivateNetworks permits loopback, private and link-local hosts.
+	AllowPrivateNetworks bool
+	// Client replaces the client downloads are made with, e.g. in tests. It should
+	// not follow redirects.
+	Client *http.Client
+}
+
+// Fetcher downloads images.
+type Fetcher struct {
+	opts   Options
+	client *http.Client
+}
+
+// New returns a fetcher with opts.
+func New(opts Options) *Fetcher {
+	client := opts.Client
+	if client == nil {
+		client = safehttp.NewClient(opts.Timeout, opts.AllowPrivateNetworks)
+	}
+	return &Fetcher{opts: opts, client: client}
+}
+
+// Fetch downloads the image at rawURL. The whole image is read before Fetch
+// returns, so the download time does not count against the verification.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	target, err := f.validate(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if f.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.opts.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	req.Header.Set("Accept", "image/*")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrFetchFailed, resp.StatusCode)
+	}
+	if resp.ContentLength > f.opts.MaxSize {
+		return nil, ErrTooLarge
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.opts.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+	if int64(len(body)) > f.opts.MaxSize {
+		return nil, ErrTooLarge
+	}
+	return body, nil
+}
+
+func (f *Fetcher) validate(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || u.User != nil {
+		return nil, fmt.Errorf("%w: must be an absolute URL without credentials", ErrInvalidURL)
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && f.opts.AllowHTTP:
+	default:
+		return nil, fmt.Errorf("%w: scheme must be https", ErrInvalidURL)
+	}
+	if !f.opts.AllowPrivateNetworks {
+		if err := safehttp.CheckHost(u.Hostname()); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidURL, err)
+		}
+	}
+	return u, nil
+}
@@ -0,0 +1,157 @@
+// Package imagelimits checks image headers before images are verified. It rejects
+// images whose header is corrupt, and images whose dimensions would make decoding
+// them expensive, such as decompression bombs, without decoding their pixels.
+package imagelimits
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // registers the GIF decoder
+	_ "image/jpeg" // registers the JPEG decoder
+	_ "image/png"  // registers the PNG decoder
+	"io"
+)
+
+// HeaderLen is how much of the start of an image Check needs to find its header.
+const HeaderLen = 64 << 10
+
+// Reasons an image is rejected for.
+const (
+	ReasonCorrupt      = "corrupt_image"
+	ReasonWidth        = "width_exceeded"
+	ReasonHeight       = "height_exceeded"
+	ReasonPixelCount   = "pixel_count_exceeded"
+	ReasonHeaderTooBig = "header_too_large"
+	ReasonTooSmall     = "dimensions_too_small"
+	ReasonAspectRatio  = "aspect_ratio_exceeded"
+)
+
+// Limits bounds the dimensions of images. Zero disables a limit.
+type Limits struct {
+	MaxWidth  int
+	MaxHeight int
+	MaxPixels int64
+	// MinWidth and MinHeight reject images too small to classify, such as icons
+	// and tracking pixels.
+	MinWidth  int
+	MinHeight int
+	// MaxAspectRatio bounds the longer side divided by the shorter one, rejecting
+	// banners and strips.
+	MaxAspectRatio float64
+}
+
+// DefaultLimits returns limits that admit photos from current cameras.
+func DefaultLimits() Limits {
+	return Limits{MaxWidth: 16384, MaxHeight: 16384, MaxPixels: 100_000_000}
+}
+
+// Error reports why an image was rejected.
+type Error struct {
+	// Reason is one of the Reason constants.
+	Reason string
+	// Width and Height are the image's dimensions, when its header could be read.
+	Width  int
+	Height int
+	msg    string
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Check reads the header of the image starting with head, which is either the whole
+// image or at least its first HeaderLen bytes, and returns its configuration. It
+// returns an *Error when the header is corrupt or the dimensions exceed l.
+func (l Limits) Check(head []byte) (image.Config, error) {
+	config, err := decodeConfig(head)
+	if err != nil {
+		if len(head) >= HeaderLen && errors.Is(err, errTruncated) {
+			return image.Config{}, &Error{Reason: ReasonHeaderTooBig, msg: fmt.Sprintf("image header does not fit in the first %d bytes", HeaderLen)}
+		}
+		return image.Config{}, &Error{Reason: ReasonCorrupt, msg: "image header is corrupt: " + err.Error()}
+	}
+	width, height := config.Width, config.Height
+	switch {
+	case width <= 0 || height <= 0:
+		return config, &Error{Reason: ReasonCorrupt, Width: width, Height: height, msg: fmt.Sprintf("image has invalid dimensions %dx%d", width, height)}
+	case l.MaxWidth > 0 && width > l.MaxWidth:
+		return config, &Error{Reason: ReasonWidth, Width: width, Height: height, msg: fmt.Sprintf("image is %d pixels wide, more than %d", width, l.MaxWidth)}
+	case l.MaxHeight > 0 && height > l.MaxHeight:
+		return config, &Error{Reason: ReasonHeight, Width: width, Height: height, msg: fmt.Sprintf("image is %d pixels high, more than %d", height, l.MaxHeight)}
+	case l.MaxPixels > 0 && int64(width)*int64(height) > l.MaxPixels:
+		return config, &Error{Reason: ReasonPixelCount, Width: width, Height: height, msg: fmt.Sprintf("image has %d pixels, more than %d", int64(width)*int64(height), l.MaxPixels)}
+	case width < l.MinWidth || height < l.MinHeight:
+		return config, &Error{Reason: ReasonTooSmall, Width: width, Height: height, msg: fmt.Sprintf("image is %dx%d pixels, smaller than %dx%d", width, height, l.MinWidth, l.MinHeight)}
+	case l.MaxAspectRatio > 0 && aspectRatio(width, height) > l.MaxAspectRatio:
+		return config, &Error{Reason: ReasonAspectRatio, Width: width, Height: height, msg: fmt.Sprintf("image aspect ratio %.2f exceeds %.2f", aspectRatio(width, height), l.MaxAspectRatio)}
+	}
+	return config, nil
+}
+
+// aspectRatio is the longer side of an image divided by the shorter one.
+func aspectRatio(width, height int) float64 {
+	if width < height {
+		width, height = height, width
+	}
+	return float64(width) / float64(height)
+}
+
+// errTruncated is returned for headers cut off by the end of head.
+var errTruncated = errors.New("header is truncated")
+
+func decodeConfig(head []byte) (image.Config, error) {
+	if len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP" {
+		return webpConfig(head)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(head))
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return config, fmt.Errorf("%w: %v", errTruncated, err)
+	}
+	return config, err
+}
+
+// webpConfig reads the canvas size of a WebP image from its first chunk. The
+// standard library has no WebP decoder, and registering one would change which
+// images other packages can decode.
+func webpConfig(head []byte) (image.Config, error) {
+	if len(head) < 20 {
+		return image.Config{}, errTruncated
+	}
+	chunk, data := string(head[12:16]), head[20:]
+	switch chunk {
+	case "VP8 ":
+		// A 3-byte frame tag, the start code, then 14-bit width and height.
+		if len(data) < 10 {
+			return image.Config{}, errTruncated
+		}
+		if data[3] != 0x9d || data[4] != 0x01 || data[5] != 0x2a {
+			return image.Config{}, errors.New("webp: invalid VP8 start code")
+		}
+		width := int(binary.LittleEndian.Uint16(data[6:]) & 0x3fff)
+		height := int(binary.LittleEndian.Uint16(data[8:]) & 0x3fff)
+		return image.Config{Width: width, Height: height}, nil
+	case "VP8L":
+		// A signature byte, then 14-bit width-1 and height-1.
+		if len(data) < 5 {
+			return image.Config{}, errTruncated
+		}
+		if data[0] != 0x2f {
+			return image.Config{}, errors.New("webp: invalid VP8L signature")
+		}
+		bits := binary.LittleEndian.Uint32(data[1:])
+		return image.Config{Width: int(bits&0x3fff) + 1, Height: int(bits>>14&0x3fff) + 1}, nil
+	case "VP8X":
+		// Flags and reserved bytes, then 24-bit canvas width-1 and height-1.
+		if len(data) < 10 {
+			return image.Config{}, errTruncated
+		}
+		width := int(uint32(data[4])|uint32(data[5])<<8|uint32(data[6])<<16) + 1
+		height := int(uint32(data[7])|uint32(data[8])<<8|uint32(data[9])<<16) + 1
+		return image.Config{Width: width, Height: height}, nil
+	default:
+		return image.Config{}, fmt.Errorf("webp: unknown chunk %q", chunk)
+	}
+}
@@ -0,0 +1,103 @@
+package imagelimits
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("png.Encode returned error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func reason(err error) string {
+	var rejected *Error
+	if !errors.As(err, &rejected) {
+		return ""
+	}
+	return rejected.Reason
+}
+
+func TestCheckEnforcesDimensions(t *testing.T) {
+	limits := Limits{MaxWidth: 100, MaxHeight: 80, MaxPixels: 5000}
+
+	config, err := limits.Check(encodePNG(t, 100, 50))
+	if err != nil || config.Width != 100 || config.Height != 50 {
+		t.Fatalf("expected a 100x50 image to pass, got %+v (%v)", config, err)
+	}
+	for _, tc := range []struct {
+		width, height int
+		reason        string
+	}{
+		{101, 10, ReasonWidth},
+		{10, 81, ReasonHeight},
+		{100, 51, ReasonPixelCount},
+	} {
+		if _, err := limits.Check(encodePNG(t, tc.width, tc.height)); reason(err) != tc.reason {
+			t.Fatalf("expected %s for %dx%d, got %v", tc.reason, tc.width, tc.height, err)
+		}
+	}
+	if _, err := (Limits{MinWidth: 32, MinHeight: 32}).Check(encodePNG(t, 64, 31)); reason(err) != ReasonTooSmall {
+		t.Fatalf("expected %s for 64x31, got %v", ReasonTooSmall, err)
+	}
+	ratio := Limits{MaxAspectRatio: 4}
+	if _, err := ratio.Check(encodePNG(t, 10, 40)); err != nil {
+		t.Fatalf("expected a 1:4 image to pass, got %v", err)
+	}
+	if _, err := ratio.Check(encodePNG(t, 41, 10)); reason(err) != ReasonAspectRatio {
+		t.Fatalf("expected %s for 41x10, got %v", ReasonAspectRatio, err)
+	}
+	if _, err := (Limits{}).Check(encodePNG(t, 4000, 3000)); err != nil {
+		t.Fatalf("expected zero limits to admit any size, got %v", err)
+	}
+}
+
+func TestCheckRejectsCorruptHeaders(t *testing.T) {
+	limits := DefaultLimits()
+	header := encodePNG(t, 10, 10)[:20]
+	for _, head := range [][]byte{
+		[]byte("MZ\x90\x00"),
+		header,
+		[]byte("RIFF\x00\x00\x00\x00WEBPVP8 \x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"),
+	} {
+		if _, err := limits.Check(head); reason(err) != ReasonCorrupt {
+			t.Fatalf("expected %q to be corrupt, got %v", head, err)
+		}
+	}
+
+	// A JPEG whose first segment runs past the bytes read ahead.
+	long := append([]byte{0xff, 0xd8, 0xff, 0xe1, 0xff, 0xff}, make([]byte, HeaderLen)...)
+	if _, err := limits.Check(long[:HeaderLen]); reason(err) != ReasonHeaderTooBig {
+		t.Fatalf("expected %s, got %v", ReasonHeaderTooBig, err)
+	}
+}
+
+func TestCheckReadsWebPCanvasSizes(t *testing.T) {
+	riff := func(chunk string, data ...byte) []byte {
+		return append([]byte("RIFF\x00\x00\x00\x00WEBP"+chunk+"\x00\x00\x00\x00"), data...)
+	}
+	for _, tc := range []struct {
+		name          string
+		head          []byte
+		width, height int
+	}{
+		{"lossy", riff("VP8 ", 0, 0, 0, 0x9d, 0x01, 0x2a, 0x40, 0x01, 0xf0, 0x00), 320, 240},
+		{"lossless", riff("VP8L", 0x2f, 0x3f, 0xc1, 0x3b, 0x00), 320, 240},
+		{"extended", riff("VP8X", 0, 0, 0, 0, 0x3f, 0x9c, 0x00, 0x1f, 0x4e, 0x00), 40000, 20000},
+	} {
+		config, err := Limits{}.Check(tc.head)
+		if err != nil || config.Width != tc.width || config.Height != tc.height {
+			t.Fatalf("%s: expected %dx%d, got %+v (%v)", tc.name, tc.width, tc.height, config, err)
+		}
+	}
+	if _, err := DefaultLimits().Check(riff("VP8X", 0, 0, 0, 0, 0x3f, 0x9c, 0x00, 0x1f, 0x4e, 0x00)); reason(err) != ReasonWidth {
+		t.Fatalf("expected a 40000 pixel wide canvas to be rejected, got %v", err)
+	}
+}
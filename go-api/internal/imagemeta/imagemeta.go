@@ -0,0 +1,437 @@
+// Package imagemeta reads the capture metadata of uploaded images from their EXIF
+// block: when the picture was taken, with which camera and where. JPEG, PNG, WebP and
+// TIFF images are supported. Only the header of the image is needed, so uploads can
+// be read once and their metadata collected on the way with a Stream.
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// MaxHeaderSize is how much of an image a Stream keeps. JPEG images carry their EXIF
+// block in the first 64 KiB; EXIF blocks of other formats further in are missed.
+const MaxHeaderSize = 256 << 10
+
+var (
+	// ErrNoMetadata is returned for images without an EXIF block, or whose block
+	// has none of the fields of Metadata.
+	ErrNoMetadata = errors.New("image has no capture metadata")
+	// ErrInvalidMetadata is returned for EXIF blocks that cannot be parsed.
+	ErrInvalidMetadata = errors.New("invalid image metadata")
+)
+
+// Metadata is the capture metadata of an image. Fields the image does not carry are
+// empty.
+type Metadata struct {
+	// CapturedAt is when the picture was taken, in RFC 3339 when the camera recorded
+	// its UTC offset, and otherwise as 2006-01-02T15:04:05 in the camera's clock.
+	CapturedAt  string `json:"captured_at,omitempty"`
+	CameraMake  string `json:"camera_make,omitempty"`
+	CameraModel string `json:"camera_model,omitempty"`
+	LensModel   string `json:"lens_model,omitempty"`
+	Software    string `json:"software,omitempty"`
+	GPS         *GPS   `json:"gps,omitempty"`
+}
+
+// GPS is where a picture was taken, in decimal degrees north and east.
+type GPS struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Altitude is in metres above sea level, when recorded.
+	Altitude *float64 `json:"altitude,omitempty"`
+}
+
+// Stream collects the header of an image as it is written, so callers that read the
+// image once for another purpose need not keep it. Create one with NewStream, write
+// the image, then call Metadata.
+type Stream struct {
+	head []byte
+}
+
+// NewStream returns an empty stream.
+func NewStream() *Stream {
+	return &Stream{}
+}
+
+// Write implements io.Writer. It keeps the first MaxHeaderSize bytes and never fails.
+func (s *Stream) Write(p []byte) (int, error) {
+	if room := MaxHeaderSize - len(s.head); room > 0 {
+		s.head = append(s.head, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// Metadata parses the metadata of the image written so far.
+func (s *Stream) Metadata() (*Metadata, error) {
+	return Parse(s.head)
+}
+
+// Parse reads the metadata of an image, or of its leading bytes.
+func Parse(image []byte) (*Metadata, error) {
+	block, err := exifBlock(image)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := parseTIFF(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMetadata, err)
+	}
+	if *metadata == (Metadata{}) {
+		return nil, ErrNoMetadata
+	}
+	return metadata, nil
+}
+
+// Orientation returns the EXIF orientation of an image, or of its leading bytes: 1
+// for upright images, up to 8, as defined by the TIFF specification. Images without
+// a valid orientation are reported upright.
+func Orientation(image []byte) int {
+	block, err := exifBlock(image)
+	if err != nil {
+		return 1
+	}
+	t, ifd0, err := openTIFF(block)
+	if err != nil {
+		return 1
+	}
+	if orientation, ok := t.long(ifd0[tagOrientation]); ok && orientation >= 1 && orientation <= 8 {
+		return int(orientation)
+	}
+	return 1
+}
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// exifBlock finds the TIFF structure holding the EXIF block of image.
+func exifBlock(image []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(image, []byte{0xFF, 0xD8}):
+		return jpegExif(image)
+	case bytes.HasPrefix(image, []byte("\x89PNG\r\n\x1a\n")):
+		return pngExif(image)
+	case len(image) >= 12 && string(image[:4]) == "RIFF" && string(image[8:12]) == "WEBP":
+		return webpExif(image)
+	case bytes.HasPrefix(image, []byte("II*\x00")), bytes.HasPrefix(image, []byte("MM\x00*")):
+		return image, nil
+	default:
+		return nil, ErrNoMetadata
+	}
+}
+
+// jpegExif returns the EXIF block of the APP1 segment of a JPEG image.
+func jpegExif(image []byte) ([]byte, error) {
+	for offset := 2; offset+4 <= len(image); {
+		if image[offset] != 0xFF {
+			return nil, ErrNoMetadata
+		}
+		marker := image[offset+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte before a marker.
+			offset++
+			continue
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7:
+			offset += 2
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// The image data starts; metadata segments come before it.
+			return nil, ErrNoMetadata
+		}
+		length := int(binary.BigEndian.Uint16(image[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(image) {
+			return nil, ErrNoMetadata
+		}
+		segment := image[offset+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, exifHeader) {
+			return segment[len(exifHeader):], nil
+		}
+		offset = end
+	}
+	return nil, ErrNoMetadata
+}
+
+// pngExif returns the eXIf chunk of a PNG image.
+func pngExif(image []byte) ([]byte, error) {
+	for offset := 8; offset+8 <= len(image); {
+		length := int(binary.BigEndian.Uint32(image[offset:]))
+		kind := string(image[offset+4 : offset+8])
+		end := offset + 8 + length
+		if length < 0 || end > len(image) {
+			return nil, ErrNoMetadata
+		}
+		switch kind {
+		case "eXIf":
+			return image[offset+8 : end], nil
+		case "IEND":
+			return nil, ErrNoMetadata
+		}
+		offset = end + 4 // CRC
+	}
+	return nil, ErrNoMetadata
+}
+
+// webpExif returns the EXIF chunk of a WebP image.
+func webpExif(image []byte) ([]byte, error) {
+	for offset := 12; offset+8 <= len(image); {
+		kind := string(image[offset : offset+4])
+		length := int(binary.LittleEndian.Uint32(image[offset+4:]))
+		end := offset + 8 + length
+		if length < 0 || end > len(image) {
+			return nil, ErrNoMetadata
+		}
+		if kind == "EXIF" {
+			// Some writers keep the JPEG APP1 header.
+			return bytes.TrimPrefix(image[offset+8:end], exifHeader), nil
+		}
+		offset = end + length%2 // chunks are padded to an even size
+	}
+	return nil, ErrNoMetadata
+}
+
+// EXIF tags read by parseTIFF and Orientation.
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagSoftware           = 0x0131
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagLensModel          = 0xA434
+
+	tagGPSLatitudeRef  = 1
+	tagGPSLatitude     = 2
+	tagGPSLongitudeRef = 3
+	tagGPSLongitude    = 4
+	tagGPSAltitudeRef  = 5
+	tagGPSAltitude     = 6
+)
+
+// maxEntries bounds the entries read from one IFD, so a corrupt count is not trusted.
+const maxEntries = 512
+
+// maxText bounds the length of the text fields kept.
+const maxText = 128
+
+// tiff reads the IFDs of a TIFF structure.
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// entry is one field of an IFD.
+type entry struct {
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// openTIFF reads the header of a TIFF structure and its first IFD.
+func openTIFF(data []byte) (*tiff, map[uint16]entry, error) {
+	if len(data) < 8 {
+		return nil, nil, errors.New("short TIFF header")
+	}
+	t := &tiff{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, nil, errors.New("unknown byte order")
+	}
+	ifd0, err := t.ifd(t.order.Uint32(data[4:]))
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, ifd0, nil
+}
+
+func parseTIFF(data []byte) (*Metadata, error) {
+	t, ifd0, err := openTIFF(data)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &Metadata{
+		CameraMake:  t.text(ifd0[tagMake]),
+		CameraModel: t.text(ifd0[tagModel]),
+		Software:    t.text(ifd0[tagSoftware]),
+	}
+	captured, offset := t.text(ifd0[tagDateTime]), ""
+	if pointer, ok := t.long(ifd0[tagExifIFD]); ok {
+		exif, err := t.ifd(pointer)
+		if err != nil {
+			return nil, err
+		}
+		if original := t.text(exif[tagDateTimeOriginal]); original != "" {
+			captured = original
+			offset = t.text(exif[tagOffsetTimeOriginal])
+		}
+		metadata.LensModel = t.text(exif[tagLensModel])
+	}
+	metadata.CapturedAt = captureTime(captured, offset)
+	if pointer, ok := t.long(ifd0[tagGPSIFD]); ok {
+		gps, err := t.ifd(pointer)
+		if err != nil {
+			return nil, err
+		}
+		metadata.GPS = t.gps(gps)
+	}
+	return metadata, nil
+}
+
+// ifd reads the entries of the IFD at offset by tag.
+func (t *tiff) ifd(offset uint32) (map[uint16]entry, error) {
+	if uint64(offset)+2 > uint64(len(t.data)) {
+		return nil, fmt.Errorf("IFD offset %d out of range", offset)
+	}
+	count := int(t.order.Uint16(t.data[offset:]))
+	if count > maxEntries {
+		return nil, fmt.Errorf("IFD of %d entries", count)
+	}
+	entries := make(map[uint16]entry, count)
+	for i := 0; i < count; i++ {
+		start := int(offset) + 2 + i*12
+		if start+12 > len(t.data) {
+			return nil, errors.New("truncated IFD")
+		}
+		raw := t.data[start : start+12]
+		e := entry{kind: t.order.Uint16(raw[2:]), count: t.order.Uint32(raw[4:])}
+		size := uint64(typeSize(e.kind)) * uint64(e.count)
+		if size <= 4 {
+			e.value = raw[8 : 8+size]
+		} else {
+			valueOffset := uint64(t.order.Uint32(raw[8:]))
+			if valueOffset+size > uint64(len(t.data)) {
+				// Skip fields pointing outside the block rather than failing the rest.
+				continue
+			}
+			e.value = t.data[valueOffset : valueOffset+size]
+		}
+		entries[t.order.Uint16(raw)] = e
+	}
+	return entries, nil
+}
+
+// typeSize is the size in bytes of one value of an EXIF field type; 0 for unknown
+// types, whose values are not read.
+func typeSize(kind uint16) int {
+	switch kind {
+	case 1, 2, 6, 7: // BYTE, ASCII, SBYTE, UNDEFINED
+		return 1
+	case 3, 8: // SHORT, SSHORT
+		return 2
+	case 4, 9: // LONG, SLONG
+		return 4
+	case 5, 10: // RATIONAL, SRATIONAL
+		return 8
+	default:
+		return 0
+	}
+}
+
+// text returns an ASCII field, trimmed; empty when e is not one.
+func (t *tiff) text(e entry) string {
+	if e.kind != 2 {
+		return ""
+	}
+	value, _, _ := strings.Cut(string(e.value), "\x00")
+	value = strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E {
+			return -1
+		}
+		return r
+	}, value)
+	value = strings.TrimSpace(value)
+	if len(value) > maxText {
+		value = value[:maxText]
+	}
+	return value
+}
+
+// long returns a LONG or SHORT field.
+func (t *tiff) long(e entry) (uint32, bool) {
+	switch {
+	case e.kind == 4 && len(e.value) >= 4:
+		return t.order.Uint32(e.value), true
+	case e.kind == 3 && len(e.value) >= 2:
+		return uint32(t.order.Uint16(e.value)), true
+	default:
+		return 0, false
+	}
+}
+
+// rationals returns the values of a RATIONAL field.
+func (t *tiff) rationals(e entry) []float64 {
+	if e.kind != 5 {
+		return nil
+	}
+	values := make([]float64, 0, len(e.value)/8)
+	for i := 0; i+8 <= len(e.value); i += 8 {
+		denominator := t.order.Uint32(e.value[i+4:])
+		if denominator == 0 {
+			return nil
+		}
+		values = append(values, float64(t.order.Uint32(e.value[i:]))/float64(denominator))
+	}
+	return values
+}
+
+// gps reads the position of the GPS IFD; nil when it holds none or an impossible one.
+func (t *tiff) gps(ifd map[uint16]entry) *GPS {
+	latitude, ok := t.degrees(ifd[tagGPSLatitude], t.text(ifd[tagGPSLatitudeRef]), "S")
+	if !ok || math.Abs(latitude) > 90 {
+		return nil
+	}
+	longitude, ok := t.degrees(ifd[tagGPSLongitude], t.text(ifd[tagGPSLongitudeRef]), "W")
+	if !ok || math.Abs(longitude) > 180 {
+		return nil
+	}
+	position := &GPS{Latitude: latitude, Longitude: longitude}
+	if altitude := t.rationals(ifd[tagGPSAltitude]); len(altitude) == 1 {
+		if ref := ifd[tagGPSAltitudeRef]; len(ref.value) == 1 && ref.value[0] == 1 {
+			altitude[0] = -altitude[0] // below sea level
+		}
+		position.Altitude = &altitude[0]
+	}
+	return position
+}
+
+// degrees converts a degrees, minutes and seconds field to decimal degrees, negated
+// when ref is negative.
+func (t *tiff) degrees(e entry, ref, negative string) (float64, bool) {
+	parts := t.rationals(e)
+	if len(parts) != 3 {
+		return 0, false
+	}
+	value := parts[0] + parts[1]/60 + parts[2]/3600
+	if strings.EqualFold(ref, negative) {
+		value = -value
+	}
+	return math.Round(value*1e7) / 1e7, true
+}
+
+// captureTime formats an EXIF timestamp and its optional UTC offset; empty when the
+// timestamp is missing or malformed.
+func captureTime(value, offset string) string {
+	captured, err := time.Parse("2006:01:02 15:04:05", value)
+	if err != nil {
+		return ""
+	}
+	if zone, err := time.Parse("-07:00", offset); err == nil {
+		_, seconds := zone.Zone()
+		return time.Date(captured.Year(), captured.Month(), captured.Day(), captured.Hour(), captured.Minute(), captured.Second(), 0,
+			time.FixedZone("", seconds)).Format(time.RFC3339)
+	}
+	return captured.Format("2006-01-02T15:04:05")
+}
@@ -0,0 +1,216 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"testing"
+)
+
+// field is one IFD entry written by buildTIFF.
+type field struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+func ascii(tag uint16, value string) field {
+	return field{tag: tag, kind: 2, count: uint32(len(value) + 1), value: append([]byte(value), 0)}
+}
+
+func long(tag uint16, value uint32) field {
+	return field{tag: tag, kind: 4, count: 1, value: binary.BigEndian.AppendUint32(nil, value)}
+}
+
+func rationals(tag uint16, values ...[2]uint32) field {
+	var raw []byte
+	for _, value := range values {
+		raw = binary.BigEndian.AppendUint32(raw, value[0])
+		raw = binary.BigEndian.AppendUint32(raw, value[1])
+	}
+	return field{tag: tag, kind: 5, count: uint32(len(values)), value: raw}
+}
+
+// buildTIFF writes a big-endian TIFF structure with IFD0 and, when given, an Exif and
+// a GPS IFD pointed to from it.
+func buildTIFF(ifd0, exif, gps []field) []byte {
+	data := []byte("MM\x00*\x00\x00\x00\x08")
+	// IFDs are laid out one after the other, each followed by its long values.
+	ifdSize := func(fields []field) int {
+		size := 2 + 12*len(fields) + 4
+		for _, f := range fields {
+			if len(f.value) > 4 {
+				size += len(f.value)
+			}
+		}
+		return size
+	}
+	exifOffset := 8 + ifdSize(ifd0) + 24 // room for the two pointers
+	gpsOffset := exifOffset + ifdSize(exif)
+	if exif != nil {
+		ifd0 = append(ifd0, long(tagExifIFD, uint32(exifOffset)))
+	}
+	if gps != nil {
+		ifd0 = append(ifd0, long(tagGPSIFD, uint32(gpsOffset)))
+	}
+	for _, fields := range [][]field{ifd0, exif, gps} {
+		if fields == nil {
+			continue
+		}
+		start := len(data)
+		extra := start + 2 + 12*len(fields) + 4
+		data = binary.BigEndian.AppendUint16(data, uint16(len(fields)))
+		var values []byte
+		for _, f := range fields {
+			data = binary.BigEndian.AppendUint16(data, f.tag)
+			data = binary.BigEndian.AppendUint16(data, f.kind)
+			data = binary.BigEndian.AppendUint32(data, f.count)
+			if len(f.value) <= 4 {
+				data = append(data, append(f.value, make([]byte, 4-len(f.value))...)...)
+			} else {
+				data = binary.BigEndian.AppendUint32(data, uint32(extra+len(values)))
+				values = append(values, f.value...)
+			}
+		}
+		data = append(data, 0, 0, 0, 0)
+		data = append(data, values...)
+		if len(data) < exifOffset && exif != nil {
+			data = append(data, make([]byte, exifOffset-len(data))...)
+		}
+	}
+	return data
+}
+
+func sampleTIFF() []byte {
+	return buildTIFF(
+		[]field{ascii(tagMake, "Canon"), ascii(tagModel, "Canon EOS R5"), ascii(tagDateTime, "2026:05:01 09:00:00")},
+		[]field{ascii(tagDateTimeOriginal, "2026:04:30 18:15:42"), ascii(tagOffsetTimeOriginal, "+02:00"), ascii(tagLensModel, "RF24-105mm F4 L IS USM")},
+		[]field{
+			ascii(tagGPSLatitudeRef, "N"), rationals(tagGPSLatitude, [2]uint32{52, 1}, [2]uint32{31, 1}, [2]uint32{1230, 100}),
+			ascii(tagGPSLongitudeRef, "W"), rationals(tagGPSLongitude, [2]uint32{13, 1}, [2]uint32{24, 1}, [2]uint32{0, 1}),
+			rationals(tagGPSAltitude, [2]uint32{345, 10}),
+		},
+	)
+}
+
+func sampleJPEG(t *testing.T, exif []byte) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	img.Set(1, 1, color.White)
+	if err := jpeg.Encode(&encoded, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if exif == nil {
+		return encoded.Bytes()
+	}
+	segment := append([]byte("Exif\x00\x00"), exif...)
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, encoded.Bytes()[2:]...)
+}
+
+func checkSample(t *testing.T, metadata *Metadata, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if metadata.CameraMake != "Canon" || metadata.CameraModel != "Canon EOS R5" || metadata.LensModel != "RF24-105mm F4 L IS USM" {
+		t.Fatalf("unexpected camera %+v", metadata)
+	}
+	if metadata.CapturedAt != "2026-04-30T18:15:42+02:00" {
+		t.Fatalf("expected the original capture time with its offset, got %q", metadata.CapturedAt)
+	}
+	if metadata.GPS == nil || metadata.GPS.Latitude != 52.5200833 || metadata.GPS.Longitude != -13.4 ||
+		metadata.GPS.Altitude == nil || *metadata.GPS.Altitude != 34.5 {
+		t.Fatalf("unexpected position %+v", metadata.GPS)
+	}
+}
+
+func TestStreamReadsJPEGMetadata(t *testing.T) {
+	stream := NewStream()
+	if _, err := io.Copy(stream, bytes.NewReader(sampleJPEG(t, sampleTIFF()))); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := stream.Metadata()
+	checkSample(t, metadata, err)
+}
+
+func TestParseReadsPNGAndWebPMetadata(t *testing.T) {
+	exif := sampleTIFF()
+
+	png := []byte("\x89PNG\r\n\x1a\n")
+	for _, chunk := range []struct {
+		kind string
+		data []byte
+	}{{"IHDR", make([]byte, 13)}, {"eXIf", exif}, {"IEND", nil}} {
+		png = binary.BigEndian.AppendUint32(png, uint32(len(chunk.data)))
+		png = append(png, chunk.kind...)
+		png = append(png, chunk.data...)
+		png = binary.BigEndian.AppendUint32(png, crc32.ChecksumIEEE(append([]byte(chunk.kind), chunk.data...)))
+	}
+	metadata, err := Parse(png)
+	checkSample(t, metadata, err)
+
+	payload := append([]byte("Exif\x00\x00"), exif...)
+	webp := []byte("RIFF\x00\x00\x00\x00WEBPVP8X")
+	webp = binary.LittleEndian.AppendUint32(webp, 10)
+	webp = append(webp, make([]byte, 10)...)
+	webp = append(webp, "EXIF"...)
+	webp = binary.LittleEndian.AppendUint32(webp, uint32(len(payload)))
+	webp = append(webp, payload...)
+	metadata, err = Parse(webp)
+	checkSample(t, metadata, err)
+}
+
+func TestParseWithoutMetadata(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"plain jpeg":     sampleJPEG(t, nil),
+		"unknown format": []byte("GIF89a"),
+		"empty block":    sampleJPEG(t, buildTIFF([]field{ascii(tagDateTime, "not a date")}, nil, nil)),
+	} {
+		if _, err := Parse(data); !errors.Is(err, ErrNoMetadata) {
+			t.Fatalf("%s: expected ErrNoMetadata, got %v", name, err)
+		}
+	}
+
+	corrupt := sampleTIFF()
+	binary.BigEndian.PutUint32(corrupt[4:], 1<<30)
+	if _, err := Parse(sampleJPEG(t, corrupt)); !errors.Is(err, ErrInvalidMetadata) {
+		t.Fatalf("expected ErrInvalidMetadata for an IFD outside the block, got %v", err)
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	rotated := field{tag: tagOrientation, kind: 3, count: 1, value: []byte{0, 6}}
+	if got := Orientation(sampleJPEG(t, buildTIFF([]field{ascii(tagModel, "Pixel 9"), rotated}, nil, nil))); got != 6 {
+		t.Fatalf("expected orientation 6, got %d", got)
+	}
+	invalid := field{tag: tagOrientation, kind: 3, count: 1, value: []byte{0, 9}}
+	for name, data := range map[string][]byte{
+		"no exif":      sampleJPEG(t, nil),
+		"no tag":       sampleJPEG(t, sampleTIFF()),
+		"out of range": sampleJPEG(t, buildTIFF([]field{invalid}, nil, nil)),
+	} {
+		if got := Orientation(data); got != 1 {
+			t.Fatalf("%s: expected upright, got %d", name, got)
+		}
+	}
+}
+
+func TestCaptureTimeWithoutOffsetKeepsTheCameraClock(t *testing.T) {
+	metadata, err := Parse(buildTIFF([]field{ascii(tagModel, "Pixel 9"), ascii(tagDateTime, "2026:01:02 03:04:05")}, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.CapturedAt != "2026-01-02T03:04:05" || metadata.GPS != nil {
+		t.Fatalf("unexpected metadata %+v", metadata)
+	}
+}
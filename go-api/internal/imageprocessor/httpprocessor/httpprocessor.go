@@ -0,0 +1,192 @@
+// Package httpprocessor calls an image processor serving a REST endpoint, for models
+// deployed behind HTTP instead of the Rust gRPC service.
+//
+// The image is POSTed as the request body, with the user in the X-User-ID header,
+// and the processor answers 200 with a JSON result:
+//
+//	{"success": true, "score": 0.93, "message": "", "model_version": "7",
+//	 "categories": [{"category": "nsfw", "score": 0.01}]}
+//
+// Other statuses fail the call with the gRPC code the rest of the service expects
+// from a processor: 400 and 422 are InvalidArgument, 429 ResourceExhausted, 502 and
+// 503 Unavailable and 504 DeadlineExceeded.
+package httpprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/example/ai-check/internal/imageprocessor"
+	"github.com/example/ai-check/internal/logging"
+)
+
+// Name is the backend name the client is registered under.
+const Name = "http"
+
+// maxResponseSize bounds the JSON result read from the processor.
+const maxResponseSize = 1 << 20
+
+// Client sends images to a REST processor. It implements imageprocessor.StreamClient.
+type Client struct {
+	url    string
+	http   *http.Client
+	logger *zap.Logger
+}
+
+// New returns a client POSTing images to endpoint. A timeout of 0 leaves calls
+// bounded by their context only.
+func New(endpoint string, timeout time.Duration, logger *zap.Logger) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid processor url %q", endpoint)
+	}
+	return &Client{url: endpoint, http: &http.Client{Timeout: timeout}, logger: logger}, nil
+}
+
+// Factory builds clients for an imageprocessor.Registry from the URL in opts.Addr.
+func Factory(logger *zap.Logger) imageprocessor.Factory {
+	return func(ctx context.Context, opts imageprocessor.Options) (*imageprocessor.Backend, error) {
+		client, err := New(opts.Addr, opts.Timeout, logger)
+		if err != nil {
+			return nil, err
+		}
+		return &imageprocessor.Backend{Client: client}, nil
+	}
+}
+
+type categoryScore struct {
+	Category string  `json:"category"`
+	Score    float32 `json:"score"`
+}
+
+type response struct {
+	Success      bool            `json:"success"`
+	Score        float32         `json:"score"`
+	Message      string          `json:"message"`
+	Categories   []categoryScore `json:"categories"`
+	ModelVersion string          `json:"model_version"`
+}
+
+// Process implements imageprocessor.Client.
+func (c *Client) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
+	result, err := c.call(ctx, userID, bytes.NewReader(imageBytes))
+	if err != nil {
+		return nil, c.fail(ctx, userID, err)
+	}
+	return result, nil
+}
+
+// ProcessStream implements imageprocessor.StreamClient, sending the image as it is
+// read. Failures to read image are returned as *imageprocessor.ReadError.
+func (c *Client) ProcessStream(ctx context.Context, userID string, image io.Reader) (*imageprocessor.Result, error) {
+	body := &trackingReader{r: image}
+	result, err := c.call(ctx, userID, body)
+	if err != nil {
+		if body.err != nil {
+			return nil, logging.NewOperationError("httpprocessor.process_image", userID, &imageprocessor.ReadError{Err: body.err})
+		}
+		return nil, c.fail(ctx, userID, err)
+	}
+	return result, nil
+}
+
+func (c *Client) fail(ctx context.Context, userID string, err error) error {
+	wrapped := logging.NewOperationError("httpprocessor.process_image", userID, err)
+	logging.FromContext(ctx, c.logger).Error("image processor call failed", zap.Error(wrapped), zap.String("user_id", userID))
+	return wrapped
+}
+
+func (c *Client) call(ctx context.Context, userID string, body io.Reader) (*imageprocessor.Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, body)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-User-ID", userID)
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+		return nil, status.Errorf(statusCode(resp.StatusCode), "processor answered %s", resp.Status)
+	}
+	var decoded response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&decoded); err != nil {
+		return nil, status.Errorf(codes.Internal, "decode processor response: %v", err)
+	}
+	categories := make([]imageprocessor.CategoryScore, 0, len(decoded.Categories))
+	for _, category := range decoded.Categories {
+		categories = append(categories, imageprocessor.CategoryScore{Category: category.Category, Score: category.Score})
+	}
+	return &imageprocessor.Result{
+		Success:      decoded.Success,
+		Score:        decoded.Score,
+		Message:      decoded.Message,
+		Categories:   categories,
+		ModelVersion: decoded.ModelVersion,
+	}, nil
+}
+
+// statusCode maps the HTTP status of a failed call to the gRPC code of the same
+// failure.
+func statusCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType:
+		return codes.InvalidArgument
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
+
+// transportError maps a call that got no response to Unavailable, or to
+// DeadlineExceeded when it ran out of time.
+func transportError(ctx context.Context, err error) error {
+	var netErr interface{ Timeout() bool }
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+// trackingReader remembers the error of the image reader, so a cut-short upload is
+// told apart from a processor failure.
+type trackingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (t *trackingReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if err != nil && err != io.EOF {
+		t.err = err
+	}
+	return n, err
+}
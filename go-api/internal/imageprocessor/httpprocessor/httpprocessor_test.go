@@ -0,0 +1,108 @@
+package httpprocessor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/example/ai-check/internal/imageprocessor"
+)
+
+func TestProcessPostsTheImageAndDecodesTheResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != "image" || r.Header.Get("X-User-ID") != "user-1" {
+			http.Error(w, "unexpected request", http.StatusTeapot)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"success":true,"score":0.75,"message":"ok","model_version":"7","categories":[{"category":"nsfw","score":0.5}]}`)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, process := range map[string]func() (*imageprocessor.Result, error){
+		"bytes": func() (*imageprocessor.Result, error) {
+			return client.Process(context.Background(), "user-1", []byte("image"))
+		},
+		"stream": func() (*imageprocessor.Result, error) {
+			return imageprocessor.ProcessReader(context.Background(), client, "user-1", strings.NewReader("image"))
+		},
+	} {
+		result, err := process()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !result.Success || result.Score != 0.75 || result.ModelVersion != "7" || len(result.Categories) != 1 || result.Categories[0].Category != imageprocessor.CategoryNSFW {
+			t.Fatalf("%s: unexpected result %+v", name, result)
+		}
+	}
+}
+
+func TestProcessMapsFailuresToGRPCCodes(t *testing.T) {
+	for httpStatus, want := range map[int]codes.Code{
+		http.StatusUnprocessableEntity: codes.InvalidArgument,
+		http.StatusTooManyRequests:     codes.ResourceExhausted,
+		http.StatusServiceUnavailable:  codes.Unavailable,
+		http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+		http.StatusInternalServerError: codes.Internal,
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(httpStatus)
+		}))
+		client, _ := New(server.URL, 0, zap.NewNop())
+		_, err := client.Process(context.Background(), "user-1", []byte("image"))
+		server.Close()
+		if got := status.Code(err); got != want {
+			t.Errorf("status %d: got code %s, want %s", httpStatus, got, want)
+		}
+	}
+
+	client, _ := New("http://127.0.0.1:1", 0, zap.NewNop())
+	if _, err := client.Process(context.Background(), "user-1", []byte("image")); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable for an unreachable processor, got %v", err)
+	}
+}
+
+func TestProcessStreamReportsReadErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, `{"success":true}`)
+	}))
+	defer server.Close()
+	client, _ := New(server.URL, 0, zap.NewNop())
+
+	cut := errors.New("upload cut short")
+	_, err := client.ProcessStream(context.Background(), "user-1", io.MultiReader(strings.NewReader("ima"), &failingReader{err: cut}))
+	var readErr *imageprocessor.ReadError
+	if !errors.As(err, &readErr) || !errors.Is(err, cut) {
+		t.Fatalf("expected a read error, got %v", err)
+	}
+}
+
+func TestNewRejectsInvalidURLs(t *testing.T) {
+	for _, endpoint := range []string{"", "processor:8080", "ftp://processor/verify"} {
+		if _, err := New(endpoint, 0, zap.NewNop()); err == nil {
+			t.Errorf("expected %q to be rejected", endpoint)
+		}
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (f *failingReader) Read([]byte) (int, error) {
+	return 0, f.err
+}
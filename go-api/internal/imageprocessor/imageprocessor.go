@@ -1,15 +1,72 @@
 package imageprocessor
 
-import "context"
+import (
+	"context"
+	"io"
+)
+
+// Moderation categories the processor may score.
+const (
+	CategoryAIGenerated = "ai_generated"
+	CategoryManipulated = "manipulated"
+	CategoryNSFW        = "nsfw"
+	CategoryWatermarked = "watermarked"
+)
+
+// CategoryScore is the likelihood, from 0 to 1, that an image belongs to a category.
+type CategoryScore struct {
+	Category string
+	Score    float32
+}
 
 // Result contains the outcome returned by the image processor service.
 type Result struct {
 	Success bool
 	Score   float32
 	Message string
+	// Categories holds the scores of the categories the processor evaluated, which
+	// may be none.
+	Categories []CategoryScore
+	// ModelVersion identifies the model that produced the scores; empty when the
+	// processor does not report it.
+	ModelVersion string
 }
 
 // Client exposes the subset of functionality used by the verification flow.
 type Client interface {
 	Process(ctx context.Context, userID string, imageBytes []byte) (*Result, error)
 }
+
+// StreamClient is implemented by clients that send the image as it is read, so it
+// never has to be held in memory whole.
+type StreamClient interface {
+	Client
+	ProcessStream(ctx context.Context, userID string, image io.Reader) (*Result, error)
+}
+
+// ReadError reports that the image could not be read from the caller's reader, e.g.
+// because the upload was cut short, as opposed to a processor failure.
+type ReadError struct {
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	return "read image: " + e.Err.Error()
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// ProcessReader sends the image read from image to client, streaming it when the
+// client supports it and reading it whole otherwise.
+func ProcessReader(ctx context.Context, client Client, userID string, image io.Reader) (*Result, error) {
+	if stream, ok := client.(StreamClient); ok {
+		return stream.ProcessStream(ctx, userID, image)
+	}
+	imageBytes, err := io.ReadAll(image)
+	if err != nil {
+		return nil, &ReadError{Err: err}
+	}
+	return client.Process(ctx, userID, imageBytes)
+}
@@ -0,0 +1,79 @@
+package imageprocessor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Backend is a processor built by a Factory.
+type Backend struct {
+	Client Client
+	// Ready reports whether the backend can take calls, for readiness checks; nil
+	// when the backend cannot tell before a call.
+	Ready func(ctx context.Context) error
+	// Close releases the connections of the backend; nil when it holds none.
+	Close func() error
+}
+
+// Options configures the backend a Factory builds.
+type Options struct {
+	// Addr locates the processor, in the form the backend expects: a gRPC target, a
+	// URL, and so on. Backends running in-process ignore it.
+	Addr string
+	// Timeout bounds each call when set, besides the deadline of its context.
+	Timeout time.Duration
+}
+
+// Factory builds a backend. It may wait for the processor to be reachable until ctx
+// is done.
+type Factory func(ctx context.Context, opts Options) (*Backend, error)
+
+// Registry maps backend names to their factories, so the processor verifications use
+// is chosen by configuration. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+}
+
+// NewRegistry returns a registry without backends.
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// Register makes factory available as name, replacing any factory registered under
+// that name before.
+func (r *Registry) Register(name string, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[name] = factory
+}
+
+// Names returns the names of the registered backends, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the backend registered as name.
+func (r *Registry) New(ctx context.Context, name string, opts Options) (*Backend, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown processor backend %q, expected one of %v", name, r.Names())
+	}
+	backend, err := factory(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("processor backend %s: %w", name, err)
+	}
+	return backend, nil
+}
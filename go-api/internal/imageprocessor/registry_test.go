@@ -0,0 +1,40 @@
+package imageprocessor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type staticClient struct {
+	result *Result
+}
+
+func (s staticClient) Process(ctx context.Context, userID string, imageBytes []byte) (*Result, error) {
+	return s.result, nil
+}
+
+func TestRegistryBuildsTheNamedBackend(t *testing.T) {
+	registry := NewRegistry()
+	var got Options
+	registry.Register("static", func(ctx context.Context, opts Options) (*Backend, error) {
+		got = opts
+		return &Backend{Client: staticClient{result: &Result{Success: true, Score: 0.5}}}, nil
+	})
+
+	backend, err := registry.New(context.Background(), "static", Options{Addr: "processor:8080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Addr != "processor:8080" {
+		t.Fatalf("expected the options to reach the factory, got %+v", got)
+	}
+	result, _ := backend.Client.Process(context.Background(), "user", nil)
+	if result.Score != 0.5 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	if _, err := registry.New(context.Background(), "onnx", Options{}); err == nil || !strings.Contains(err.Error(), "static") {
+		t.Fatalf("expected an unknown backend to list the registered ones, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+// Package lifecycle runs the background components of a process, such as the
+// outbox relay, the cron scheduler and queue consumers, and drains them on
+// shutdown: every component's context is cancelled, then each is given its own
+// timeout to return.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type component struct {
+	name    string
+	timeout time.Duration
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// Manager starts background components and stops them together.
+type Manager struct {
+	logger  *zap.Logger
+	timeout time.Duration
+
+	mu         sync.Mutex
+	components []*component
+	stopped    bool
+}
+
+// New returns a manager allowing components timeout to return once stopped,
+// unless they were started with GoWithTimeout.
+func New(logger *zap.Logger, timeout time.Duration) *Manager {
+	return &Manager{logger: logger, timeout: timeout}
+}
+
+// Go runs run in a goroutine until Stop cancels its context.
+func (m *Manager) Go(name string, run func(ctx context.Context)) {
+	m.GoWithTimeout(name, m.timeout, run)
+}
+
+// GoWithTimeout is Go with the time the component may take to return once
+// stopped. Components started after Stop are not run.
+func (m *Manager) GoWithTimeout(name string, timeout time.Duration, run func(ctx context.Context)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		m.logger.Warn("not starting background component after shutdown", zap.String("component", name))
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &component{name: name, timeout: timeout, cancel: cancel, done: make(chan struct{})}
+	m.components = append(m.components, c)
+	go func() {
+		defer close(c.done)
+		run(ctx)
+	}()
+}
+
+// Timeout returns the longest time Stop may take, which is the largest timeout
+// of the components started so far.
+func (m *Manager) Timeout() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	longest := time.Duration(0)
+	for _, c := range m.components {
+		longest = max(longest, c.timeout)
+	}
+	return longest
+}
+
+// Stop cancels every component and waits for them to return. The components drain
+// concurrently, each within its own timeout and all within ctx. Components that
+// do not return in time are reported and left running.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopped = true
+	components := m.components
+	m.mu.Unlock()
+
+	for _, c := range components {
+		c.cancel()
+	}
+	errs := make([]error, len(components))
+	var wg sync.WaitGroup
+	for i, c := range components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.wait(ctx, c)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (m *Manager) wait(ctx context.Context, c *component) error {
+	started := time.Now()
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case <-c.done:
+		m.logger.Info("background component stopped", zap.String("component", c.name), zap.Duration("elapsed", time.Since(started)))
+		return nil
+	case <-timer.C:
+		err := fmt.Errorf("%s: timed out after %s", c.name, c.timeout)
+		m.logger.Error("background component did not stop", zap.String("component", c.name), zap.Error(err))
+		return err
+	case <-ctx.Done():
+		m.logger.Error("background component did not stop", zap.String("component", c.name), zap.Error(ctx.Err()))
+		return fmt.Errorf("%s: %w", c.name, ctx.Err())
+	}
+}
@@ -0,0 +1,67 @@
+package lifecycle
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestStopCancelsComponentsAndWaitsForThem(t *testing.T) {
+	manager := New(zap.NewNop(), time.Second)
+
+	var drained atomic.Int32
+	for _, name := range []string{"outbox-relay", "worker"} {
+		manager.Go(name, func(ctx context.Context) {
+			<-ctx.Done()
+			// Finishing in-flight work after cancellation is part of the drain.
+			time.Sleep(10 * time.Millisecond)
+			drained.Add(1)
+		})
+	}
+
+	if err := manager.Stop(context.Background()); err != nil {
+		t.Fatalf("expected a clean stop, got %v", err)
+	}
+	if drained.Load() != 2 {
+		t.Fatalf("expected Stop to wait for both components, %d returned", drained.Load())
+	}
+
+	started := false
+	manager.Go("late", func(context.Context) { started = true })
+	time.Sleep(10 * time.Millisecond)
+	if started {
+		t.Fatal("expected components started after Stop not to run")
+	}
+}
+
+func TestStopReportsComponentsExceedingTheirTimeout(t *testing.T) {
+	manager := New(zap.NewNop(), time.Second)
+
+	release := make(chan struct{})
+	defer close(release)
+	manager.GoWithTimeout("stuck", 20*time.Millisecond, func(context.Context) { <-release })
+	var stopped atomic.Bool
+	manager.Go("cron", func(ctx context.Context) {
+		<-ctx.Done()
+		stopped.Store(true)
+	})
+	if timeout := manager.Timeout(); timeout != time.Second {
+		t.Fatalf("expected the longest component timeout, got %s", timeout)
+	}
+
+	started := time.Now()
+	err := manager.Stop(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "stuck: timed out after 20ms") {
+		t.Fatalf("expected the stuck component to be reported, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected Stop to give up on the stuck component after its own timeout, took %s", elapsed)
+	}
+	if !stopped.Load() {
+		t.Fatal("expected the other component to be stopped")
+	}
+}
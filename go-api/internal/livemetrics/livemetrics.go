@@ -0,0 +1,270 @@
+// Package livemetrics keeps in-process counters of verifications and turns them into
+// a periodic feed of rates for wallboards, of every tenant or of one. Unlike the
+// metrics summary it never reads the database, and it only covers the verifications
+// handled by this process since it started.
+package livemetrics
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultInterval is how often the feed publishes an update.
+const DefaultInterval = 5 * time.Second
+
+// Recorder counts verifications, in total and per tenant. It is safe for concurrent
+// use.
+type Recorder struct {
+	all tally
+	// tenants maps tenant IDs, "" for verifications of no tenant, to their *tally.
+	tenants sync.Map
+}
+
+type tally struct {
+	completed    atomic.Int64
+	verified     atomic.Int64
+	failed       atomic.Int64
+	latencyNanos atomic.Int64
+}
+
+// NewRecorder returns a recorder with zeroed counters.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) tenant(tenantID string) *tally {
+	if t, ok := r.tenants.Load(tenantID); ok {
+		return t.(*tally)
+	}
+	t, _ := r.tenants.LoadOrStore(tenantID, &tally{})
+	return t.(*tally)
+}
+
+// ObserveVerification counts a completed verification of tenantID, its verdict and
+// how long the processor took.
+func (r *Recorder) ObserveVerification(tenantID string, verified bool, latency time.Duration) {
+	for _, t := range []*tally{&r.all, r.tenant(tenantID)} {
+		t.completed.Add(1)
+		if verified {
+			t.verified.Add(1)
+		}
+		t.latencyNanos.Add(int64(latency))
+	}
+}
+
+// ObserveFailure counts a verification of tenantID that could not be completed.
+func (r *Recorder) ObserveFailure(tenantID string) {
+	r.all.failed.Add(1)
+	r.tenant(tenantID).failed.Add(1)
+}
+
+type counters struct {
+	completed, verified, failed, latencyNanos int64
+}
+
+func (t *tally) load() counters {
+	return counters{
+		completed:    t.completed.Load(),
+		verified:     t.verified.Load(),
+		failed:       t.failed.Load(),
+		latencyNanos: t.latencyNanos.Load(),
+	}
+}
+
+func (r *Recorder) load() counters {
+	return r.all.load()
+}
+
+// loadTenants returns the counters of every tenant seen so far.
+func (r *Recorder) loadTenants() map[string]counters {
+	tenants := make(map[string]counters)
+	r.tenants.Range(func(key, value any) bool {
+		tenants[key.(string)] = value.(*tally).load()
+		return true
+	})
+	return tenants
+}
+
+// Update describes the verifications of one interval. The rates are zero when the
+// interval saw no verifications.
+type Update struct {
+	Time            time.Time `json:"time"`
+	IntervalSeconds float64   `json:"interval_seconds"`
+	// Requests counts completed and failed verifications.
+	Requests          int64   `json:"requests"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Failures          int64   `json:"failures"`
+	// SuccessRate is the share of completed verifications that were verified, like
+	// success_rate in the metrics summary.
+	SuccessRate      float64 `json:"success_rate"`
+	AverageLatencyMs float64 `json:"average_latency_ms"`
+	// TotalRequests and TotalFailures count since the process started.
+	TotalRequests int64 `json:"total_requests"`
+	TotalFailures int64 `json:"total_failures"`
+}
+
+// Feed publishes an Update of a recorder every interval to its subscribers, of every
+// tenant or of the tenant they subscribed to.
+type Feed struct {
+	recorder *Recorder
+	interval time.Duration
+
+	mu          sync.Mutex
+	subscribers map[chan Update]subscription
+	latest      *Update
+	// latestByTenant holds the latest update of every tenant seen so far.
+	latestByTenant map[string]Update
+	stopped        bool
+}
+
+// subscription is what a subscriber receives: every tenant, or tenant alone.
+type subscription struct {
+	all    bool
+	tenant string
+}
+
+// NewFeed returns a feed of recorder's counters. A non-positive interval uses
+// DefaultInterval.
+func NewFeed(recorder *Recorder, interval time.Duration) *Feed {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Feed{
+		recorder:       recorder,
+		interval:       interval,
+		subscribers:    make(map[chan Update]subscription),
+		latestByTenant: make(map[string]Update),
+	}
+}
+
+// Interval returns how often updates are published.
+func (f *Feed) Interval() time.Duration {
+	return f.interval
+}
+
+// Run publishes updates until ctx is done, then closes every subscription.
+func (f *Feed) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	previous, previousTenants, since := f.recorder.load(), f.recorder.loadTenants(), time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			f.stop()
+			return
+		case now := <-ticker.C:
+			current, currentTenants := f.recorder.load(), f.recorder.loadTenants()
+			tenants := make(map[string]Update, len(currentTenants))
+			for tenant, counters := range currentTenants {
+				tenants[tenant] = diff(previousTenants[tenant], counters, now.Sub(since), now)
+			}
+			f.publish(diff(previous, current, now.Sub(since), now), tenants)
+			previous, previousTenants, since = current, currentTenants, now
+		}
+	}
+}
+
+func diff(previous, current counters, elapsed time.Duration, now time.Time) Update {
+	completed := current.completed - previous.completed
+	failed := current.failed - previous.failed
+	update := Update{
+		Time:            now.UTC(),
+		IntervalSeconds: elapsed.Seconds(),
+		Requests:        completed + failed,
+		Failures:        failed,
+		TotalRequests:   current.completed + current.failed,
+		TotalFailures:   current.failed,
+	}
+	if elapsed > 0 {
+		update.RequestsPerSecond = float64(update.Requests) / elapsed.Seconds()
+	}
+	if completed > 0 {
+		update.SuccessRate = float64(current.verified-previous.verified) / float64(completed)
+		update.AverageLatencyMs = float64(current.latencyNanos-previous.latencyNanos) / float64(completed) / float64(time.Millisecond)
+	}
+	return update
+}
+
+func (f *Feed) publish(update Update, tenants map[string]Update) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latest = &update
+	for tenant, tenantUpdate := range tenants {
+		f.latestByTenant[tenant] = tenantUpdate
+	}
+	for ch, sub := range f.subscribers {
+		if sub.all {
+			offer(ch, update)
+		} else if tenantUpdate, ok := f.latestByTenant[sub.tenant]; ok {
+			offer(ch, tenantUpdate)
+		} else {
+			offer(ch, idle(update))
+		}
+	}
+}
+
+// idle is update for a tenant without verifications yet.
+func idle(update Update) Update {
+	return Update{Time: update.Time, IntervalSeconds: update.IntervalSeconds}
+}
+
+// offer replaces an update the subscriber has not read yet, so a slow client skips
+// stale updates instead of holding up the others.
+func offer(ch chan Update, update Update) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- update
+}
+
+func (f *Feed) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+	for ch := range f.subscribers {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Subscribe returns a channel of the updates of every tenant, starting with the
+// latest one when there is one, and a function that cancels the subscription. The
+// channel is closed when the feed stops.
+func (f *Feed) Subscribe() (<-chan Update, func()) {
+	return f.subscribe(subscription{all: true})
+}
+
+// SubscribeTenant is Subscribe for the verifications of tenantID alone, "" for
+// those of no tenant.
+func (f *Feed) SubscribeTenant(tenantID string) (<-chan Update, func()) {
+	return f.subscribe(subscription{tenant: tenantID})
+}
+
+func (f *Feed) subscribe(sub subscription) (<-chan Update, func()) {
+	ch := make(chan Update, 1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		close(ch)
+		return ch, func() {}
+	}
+	if sub.all {
+		if f.latest != nil {
+			ch <- *f.latest
+		}
+	} else if update, ok := f.latestByTenant[sub.tenant]; ok {
+		ch <- update
+	}
+	f.subscribers[ch] = sub
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subscribers[ch]; ok {
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
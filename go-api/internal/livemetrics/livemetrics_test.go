@@ -0,0 +1,114 @@
+package livemetrics
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDiffReportsTheIntervalRates(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.ObserveVerification("", true, 100*time.Millisecond)
+	previous := recorder.load()
+
+	recorder.ObserveVerification("", true, 20*time.Millisecond)
+	recorder.ObserveVerification("", false, 40*time.Millisecond)
+	recorder.ObserveFailure("")
+	update := diff(previous, recorder.load(), 2*time.Second, time.Now())
+
+	if update.Requests != 3 || update.Failures != 1 || update.TotalRequests != 4 || update.TotalFailures != 1 {
+		t.Fatalf("unexpected counts %+v", update)
+	}
+	if update.RequestsPerSecond != 1.5 || update.SuccessRate != 0.5 || math.Abs(update.AverageLatencyMs-30) > 1e-9 {
+		t.Fatalf("unexpected rates %+v", update)
+	}
+
+	idle := diff(recorder.load(), recorder.load(), time.Second, time.Now())
+	if idle.Requests != 0 || idle.SuccessRate != 0 || idle.AverageLatencyMs != 0 {
+		t.Fatalf("expected an idle interval to report zero rates, got %+v", idle)
+	}
+}
+
+func TestFeedPublishesLatestUpdatesAndClosesOnStop(t *testing.T) {
+	recorder := NewRecorder()
+	feed := NewFeed(recorder, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		feed.Run(ctx)
+	}()
+
+	updates, unsubscribe := feed.Subscribe()
+	recorder.ObserveVerification("", true, time.Millisecond)
+	deadline := time.After(time.Second)
+	for seen := false; !seen; {
+		select {
+		case update := <-updates:
+			seen = update.TotalRequests == 1
+		case <-deadline:
+			t.Fatal("expected an update counting the verification")
+		}
+	}
+	unsubscribe()
+	unsubscribe()
+
+	// A late subscriber starts with the latest update instead of waiting a tick.
+	late, _ := feed.Subscribe()
+	if update := <-late; update.TotalRequests != 1 {
+		t.Fatalf("unexpected first update %+v", update)
+	}
+
+	cancel()
+	<-done
+	for range late {
+	}
+	stopped, _ := feed.Subscribe()
+	if _, ok := <-stopped; ok {
+		t.Fatal("expected subscriptions to a stopped feed to be closed")
+	}
+}
+
+func TestFeedLimitsTenantSubscriptionsToTheirTenant(t *testing.T) {
+	recorder := NewRecorder()
+	feed := NewFeed(recorder, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go feed.Run(ctx)
+
+	acme, _ := feed.SubscribeTenant("acme")
+	all, _ := feed.Subscribe()
+	recorder.ObserveVerification("acme", true, time.Millisecond)
+	recorder.ObserveVerification("globex", true, time.Millisecond)
+	recorder.ObserveFailure("globex")
+
+	deadline := time.After(time.Second)
+	for seen := false; !seen; {
+		select {
+		case update := <-all:
+			seen = update.TotalRequests == 3
+		case <-deadline:
+			t.Fatal("expected an update counting every tenant")
+		}
+	}
+	select {
+	case update := <-acme:
+		if update.TotalRequests != 1 || update.TotalFailures != 0 {
+			t.Fatalf("expected only the verification of acme, got %+v", update)
+		}
+	case <-deadline:
+		t.Fatal("expected an update for acme")
+	}
+
+	// Tenants without verifications get idle updates.
+	initech, _ := feed.SubscribeTenant("initech")
+	select {
+	case update := <-initech:
+		if update.TotalRequests != 0 {
+			t.Fatalf("expected an idle update, got %+v", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an update for a tenant without verifications")
+	}
+}
@@ -0,0 +1,99 @@
+// Package logbuffer keeps completed verification logs in the Redis job queue while
+// the database is unavailable and saves them once it recovers, so a short database
+// incident does not fail verifications whose result is already known.
+package logbuffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/worker"
+)
+
+// ReplayJob is the worker job type that saves one buffered log.
+const ReplayJob = "verification.save_log"
+
+// Repository saves the buffered logs.
+type Repository interface {
+	SaveLog(ctx context.Context, log *repository.VerificationLog) error
+	FindByRequestIDAndUser(ctx context.Context, requestID, userID string) (*repository.VerificationLog, error)
+}
+
+// Options tunes a Buffer.
+type Options struct {
+	// MaxAttempts is how often a buffered log is tried before it is dead-lettered.
+	// With the worker's backoff it bounds the outage a log survives.
+	MaxAttempts int
+}
+
+// DefaultOptions returns the options used by New.
+func DefaultOptions() Options {
+	return Options{MaxAttempts: 100}
+}
+
+// Buffer queues logs and replays them.
+type Buffer struct {
+	queue  *worker.Queue
+	repo   Repository
+	logger *zap.Logger
+	opts   Options
+}
+
+// New returns a buffer with DefaultOptions.
+func New(queue *worker.Queue, repo Repository, logger *zap.Logger) *Buffer {
+	return NewWithOptions(queue, repo, logger, DefaultOptions())
+}
+
+// NewWithOptions returns a buffer with explicit options.
+func NewWithOptions(queue *worker.Queue, repo Repository, logger *zap.Logger, opts Options) *Buffer {
+	return &Buffer{queue: queue, repo: repo, logger: logger.Named("log_buffer"), opts: opts}
+}
+
+// Add queues log to be saved by a worker. Adding the same request twice queues it
+// once.
+func (b *Buffer) Add(ctx context.Context, log *repository.VerificationLog) error {
+	job, err := worker.NewJob(ReplayJob, log)
+	if err != nil {
+		return err
+	}
+	job.ID = ReplayJob + ":" + log.RequestID
+	job.MaxAttempts = b.opts.MaxAttempts
+	if _, err := b.queue.Enqueue(ctx, job); err != nil {
+		return fmt.Errorf("buffer verification log: %w", err)
+	}
+	return nil
+}
+
+// Replay is the worker.Handler for ReplayJob. It saves the buffered log unless an
+// earlier attempt already did; a returned error retries it with backoff.
+func (b *Buffer) Replay(ctx context.Context, job *worker.Job) error {
+	var log repository.VerificationLog
+	if err := job.Decode(&log); err != nil {
+		return worker.Permanent(fmt.Errorf("decode payload: %w", err))
+	}
+	logger := b.logger.With(zap.String("request_id", log.RequestID), zap.Int("attempt", job.Attempts))
+	// The reader may lag behind the save of an earlier attempt, so the local
+	// database is asked.
+	if _, err := b.repo.FindByRequestIDAndUser(repository.WithPrimary(ctx), log.RequestID, log.UserID); err == nil {
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	err := b.repo.SaveLog(ctx, &log)
+	if errors.Is(err, repository.ErrDuplicateImage) {
+		// The user verified the same image again while this log waited. As for
+		// verifications that both reach the database, the first one saved is kept.
+		logger.Warn("dropped buffered verification log of an image verified since")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info("saved buffered verification log")
+	return nil
+}
@@ -0,0 +1,120 @@
+package logbuffer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/worker"
+)
+
+func TestBufferedLogsAreSavedOnce(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	queue := worker.NewQueue(client)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	buffer := NewWithOptions(queue, repo, zap.NewNop(), Options{MaxAttempts: 7})
+
+	log := &repository.VerificationLog{
+		RequestID:  "req-1",
+		UserID:     "user-1",
+		SHA1Hash:   "hash-1",
+		Success:    true,
+		Score:      0.8,
+		CreatedAt:  time.Now().UTC(),
+		Categories: []repository.VerificationCategory{{Category: "nsfw", Score: 0.1, Threshold: 0.5}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := buffer.Add(ctx, log); err != nil {
+			t.Fatalf("Add returned error: %v", err)
+		}
+	}
+	if stats, err := queue.Stats(ctx); err != nil || stats.Ready != 1 {
+		t.Fatalf("expected the log to be queued once, got %+v (%v)", stats, err)
+	}
+
+	job, err := queue.Claim(ctx, time.Minute)
+	if err != nil || job == nil || job.Type != ReplayJob || job.MaxAttempts != 7 {
+		t.Fatalf("expected a replay job, got %+v (%v)", job, err)
+	}
+	// A retried job finds the log saved by its earlier attempt.
+	for i := 0; i < 2; i++ {
+		if err := buffer.Replay(ctx, job); err != nil {
+			t.Fatalf("Replay returned error: %v", err)
+		}
+	}
+	saved, err := repo.FindByRequestIDAndUser(ctx, "req-1", "user-1")
+	if err != nil || saved.Score != 0.8 || len(saved.Categories) != 1 {
+		t.Fatalf("expected the buffered log with its categories, got %+v (%v)", saved, err)
+	}
+	if summary, err := repo.AggregateMetrics(ctx); err != nil || summary.TotalCount != 1 {
+		t.Fatalf("expected the log to be counted once, got %+v (%v)", summary, err)
+	}
+
+	// A log of an image verified again meanwhile is dropped rather than retried.
+	if err := buffer.Add(ctx, &repository.VerificationLog{RequestID: "req-2", UserID: "user-1", SHA1Hash: "hash-1"}); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+	job, err = queue.Claim(ctx, time.Minute)
+	if err != nil || job == nil {
+		t.Fatalf("expected a replay job, got %+v (%v)", job, err)
+	}
+	if err := buffer.Replay(ctx, job); err != nil {
+		t.Fatalf("expected a duplicate to be dropped, got %v", err)
+	}
+}
+
+func TestReplayFindsLogsTheReaderHasNotReceived(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	queue := worker.NewQueue(client)
+
+	openDatabase := func() *gorm.DB {
+		db, err := devmode.OpenDatabase(":memory:")
+		if err != nil {
+			t.Fatalf("OpenDatabase returned error: %v", err)
+		}
+		if err := repository.NewVerificationRepository(db, zap.NewNop()).AutoMigrate(ctx); err != nil {
+			t.Fatalf("AutoMigrate returned error: %v", err)
+		}
+		return db
+	}
+	repo := repository.NewVerificationRepository(openDatabase(), zap.NewNop())
+	log := &repository.VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash-1", CreatedAt: time.Now().UTC()}
+	if err := repo.SaveLog(ctx, log); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	// The reader lacks the log, as a replica does until it catches up.
+	repo.SetReader(openDatabase())
+
+	buffer := New(queue, repo, zap.NewNop())
+	if err := buffer.Add(ctx, log); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+	job, err := queue.Claim(ctx, time.Minute)
+	if err != nil || job == nil {
+		t.Fatalf("expected a replay job, got %+v (%v)", job, err)
+	}
+	if err := buffer.Replay(ctx, job); err != nil {
+		t.Fatalf("expected the saved log to be found, got %v", err)
+	}
+}
@@ -6,8 +6,15 @@ import (
 
 // NewLogger builds a production ready structured logger.
 func NewLogger() (*zap.Logger, error) {
+	return NewLoggerWithLevel(zap.NewAtomicLevelAt(zap.InfoLevel))
+}
+
+// NewLoggerWithLevel builds a production logger whose minimum level follows the given
+// atomic level, so it can be changed at runtime.
+func NewLoggerWithLevel(level zap.AtomicLevel) (*zap.Logger, error) {
 	cfg := zap.NewProductionConfig()
 	cfg.EncoderConfig.TimeKey = "timestamp"
+	cfg.Level = level
 	return cfg.Build()
 }
 
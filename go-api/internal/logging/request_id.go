@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// CorrelationField names the log field holding the X-Request-ID of a request. It is
+// kept apart from request_id, which names a verification.
+const CorrelationField = "correlation_id"
+
+type requestIDKey struct{}
+
+type loggerKey struct{}
+
+// WithRequestID returns a context carrying the correlation ID of an incoming request.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the correlation ID stored by WithRequestID, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// WithLogger returns a context carrying the logger of the request it serves, which
+// FromContext hands to every layer the request goes through.
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger stored by WithLogger. Without one it returns
+// fallback, tagged with the correlation ID of ctx when there is one, so lines
+// logged for a request can be found by its X-Request-ID either way.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if ctx == nil {
+		return fallback
+	}
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return fallback.With(zap.String(CorrelationField, requestID))
+	}
+	return fallback
+}
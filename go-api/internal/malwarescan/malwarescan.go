@@ -0,0 +1,156 @@
+// Package malwarescan checks uploads for malware before they are verified, with a
+// ClamAV daemon or an external HTTP scanning service.
+package malwarescan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Verdict is the outcome of scanning one image.
+type Verdict struct {
+	Infected bool
+	// Signature names the malware found; empty for clean images.
+	Signature string
+}
+
+// Scanner scans images for malware.
+type Scanner interface {
+	Scan(ctx context.Context, image io.Reader) (Verdict, error)
+}
+
+// chunkSize is how much of the image is sent to clamd per INSTREAM chunk.
+const chunkSize = 64 << 10
+
+// ClamAV scans images with clamd over TCP, streaming them with the INSTREAM
+// command. clamd must allow streams as large as the largest upload through its
+// StreamMaxLength setting.
+type ClamAV struct {
+	addr    string
+	timeout time.Duration
+}
+
+// NewClamAV returns a scanner using the clamd listening at addr, e.g.
+// "clamav:3310". timeout bounds each scan, including the connection; zero leaves
+// scans bounded by the caller's context only.
+func NewClamAV(addr string, timeout time.Duration) *ClamAV {
+	return &ClamAV{addr: addr, timeout: timeout}
+}
+
+// Scan streams image to clamd and reads its verdict.
+func (c *ClamAV) Scan(ctx context.Context, image io.Reader) (Verdict, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("clamav: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return Verdict{}, fmt.Errorf("clamav: %w", err)
+	}
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, readErr := io.ReadFull(image, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return Verdict{}, fmt.Errorf("clamav: %w", err)
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return Verdict{}, fmt.Errorf("clamav: read image: %w", readErr)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return Verdict{}, fmt.Errorf("clamav: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return Verdict{}, fmt.Errorf("clamav: read reply: %w", err)
+	}
+	return parseClamReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamReply reads a reply such as "stream: OK" or
+// "stream: Eicar-Test-Signature FOUND".
+func parseClamReply(reply string) (Verdict, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return Verdict{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return Verdict{Infected: true, Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	default:
+		return Verdict{}, fmt.Errorf("clamav: %s", reply)
+	}
+}
+
+// HTTP scans images with an external service. The image is POSTed as the request
+// body, and the service answers 200 with {"infected": bool, "signature": "..."}.
+type HTTP struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewHTTP returns a scanner posting images to url, sending token as a bearer
+// token when it is set. timeout bounds each scan; zero leaves scans bounded by the
+// caller's context only.
+func NewHTTP(url, token string, timeout time.Duration) *HTTP {
+	return &HTTP{url: url, token: token, client: &http.Client{Timeout: timeout}}
+}
+
+// Scan posts image to the service and reads its verdict.
+func (h *HTTP) Scan(ctx context.Context, image io.Reader) (Verdict, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, image)
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("scan image: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return Verdict{}, fmt.Errorf("scan image: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("scan image: status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	var verdict struct {
+		Infected  bool   `json:"infected"`
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(body, &verdict); err != nil {
+		return Verdict{}, fmt.Errorf("scan image: decode verdict: %w", err)
+	}
+	return Verdict{Infected: verdict.Infected, Signature: verdict.Signature}, nil
+}
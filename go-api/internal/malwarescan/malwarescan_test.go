@@ -0,0 +1,109 @@
+package malwarescan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClamAVStreamsImagesAndReadsVerdicts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(t, conn)
+		}
+	}()
+
+	scanner := NewClamAV(listener.Addr().String(), time.Second)
+	// The image spans several chunks.
+	clean := bytes.Repeat([]byte("x"), 3*chunkSize+1)
+	if verdict, err := scanner.Scan(context.Background(), bytes.NewReader(clean)); err != nil || verdict.Infected {
+		t.Fatalf("expected a clean verdict, got %+v, %v", verdict, err)
+	}
+	infected := append(bytes.Repeat([]byte("x"), chunkSize), "EICAR"...)
+	verdict, err := scanner.Scan(context.Background(), bytes.NewReader(infected))
+	if err != nil || !verdict.Infected || verdict.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("expected an infected verdict, got %+v, %v", verdict, err)
+	}
+	if _, err := scanner.Scan(context.Background(), strings.NewReader("ERROR")); err == nil || !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Fatalf("expected clamd errors to be returned, got %v", err)
+	}
+}
+
+// serveClamd answers one INSTREAM command like clamd, finding streams containing
+// "EICAR" infected and failing those containing "ERROR".
+func serveClamd(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	if command, err := r.ReadString(0); err != nil || command != "zINSTREAM\x00" {
+		t.Errorf("unexpected command %q, %v", command, err)
+		return
+	}
+	var stream []byte
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			t.Errorf("read chunk size: %v", err)
+			return
+		}
+		if size == 0 {
+			break
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			t.Errorf("read chunk: %v", err)
+			return
+		}
+		stream = append(stream, chunk...)
+	}
+	switch {
+	case bytes.Contains(stream, []byte("EICAR")):
+		io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+	case bytes.Contains(stream, []byte("ERROR")):
+		io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+	default:
+		io.WriteString(conn, "stream: OK\x00")
+	}
+}
+
+func TestHTTPScannerPostsImages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer scan-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		infected := bytes.Contains(body, []byte("EICAR"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"infected": infected, "signature": map[bool]string{true: "Eicar-Test-Signature"}[infected]})
+	}))
+	defer server.Close()
+
+	scanner := NewHTTP(server.URL, "scan-token", time.Second)
+	if verdict, err := scanner.Scan(context.Background(), strings.NewReader("image")); err != nil || verdict.Infected {
+		t.Fatalf("expected a clean verdict, got %+v, %v", verdict, err)
+	}
+	verdict, err := scanner.Scan(context.Background(), strings.NewReader("EICAR"))
+	if err != nil || !verdict.Infected || verdict.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("expected an infected verdict, got %+v, %v", verdict, err)
+	}
+	if _, err := NewHTTP(server.URL, "", time.Second).Scan(context.Background(), strings.NewReader("image")); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Fatalf("expected the status of the service to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Package metering counts billable verification units per user and calendar month
+// and reports them to a metered billing provider.
+package metering
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/worker"
+)
+
+// ReportJob is the worker job type that reports unreported usage.
+const ReportJob = "usage.report"
+
+// PeriodLayout formats the calendar month a usage record covers.
+const PeriodLayout = "2006-01"
+
+// ErrInvalidPeriod is returned for periods not formatted as PeriodLayout.
+var ErrInvalidPeriod = errors.New("invalid period; use YYYY-MM")
+
+// Period returns the billing period containing t, in UTC.
+func Period(t time.Time) string {
+	return t.UTC().Format(PeriodLayout)
+}
+
+// ParsePeriod validates a period and returns it in canonical form.
+func ParsePeriod(value string) (string, error) {
+	t, err := time.Parse(PeriodLayout, value)
+	if err != nil {
+		return "", ErrInvalidPeriod
+	}
+	return Period(t), nil
+}
+
+// Usage is a user's billable usage reported to a billing provider.
+type Usage struct {
+	CustomerID string
+	Units      int64
+	// Identifier is unique per report, so providers can drop retried duplicates.
+	Identifier string
+	Timestamp  time.Time
+}
+
+// Reporter sends usage to a metered billing provider.
+type Reporter interface {
+	Report(ctx context.Context, usage Usage) error
+}
+
+// Options tunes metering.
+type Options struct {
+	// UnitsPerVerification is added to the rollup for every completed verification.
+	UnitsPerVerification int64
+	// ReportBatchSize bounds the rollups reported by one report job.
+	ReportBatchSize int
+}
+
+// DefaultOptions returns the tunables used by NewMeter.
+func DefaultOptions() Options {
+	return Options{UnitsPerVerification: 1, ReportBatchSize: 500}
+}
+
+// Meter records usage from verification events and reports it to the configured
+// provider. Usage is tracked per user ID.
+type Meter struct {
+	repo     *repository.UsageRepository
+	reporter Reporter
+	logger   *zap.Logger
+	opts     Options
+	now      func() time.Time
+}
+
+// NewMeter returns a meter with DefaultOptions. reporter may be nil when usage is
+// only tracked.
+func NewMeter(repo *repository.UsageRepository, reporter Reporter, logger *zap.Logger) *Meter {
+	return NewMeterWithOptions(repo, reporter, logger, DefaultOptions())
+}
+
+// NewMeterWithOptions returns a meter with explicit tunables.
+func NewMeterWithOptions(repo *repository.UsageRepository, reporter Reporter, logger *zap.Logger, opts Options) *Meter {
+	return &Meter{
+		repo:     repo,
+		reporter: reporter,
+		logger:   logger.Named("metering"),
+		opts:     opts,
+		now:      time.Now,
+	}
+}
+
+// Reporting reports whether usage is sent to a billing provider.
+func (m *Meter) Reporting() bool {
+	return m.reporter != nil
+}
+
+// Publish implements usecase.EventPublisher, adding the units of every completed
+// verification to the user's rollup for the month it was made in.
+func (m *Meter) Publish(ctx context.Context, event usecase.Event) error {
+	if event.Type != usecase.EventVerificationCompleted || event.UserID == "" {
+		return nil
+	}
+	return m.repo.AddUsage(ctx, event.UserID, Period(event.CreatedAt), m.opts.UnitsPerVerification)
+}
+
+// Usage returns the rollups of userID between the periods from and to inclusive.
+func (m *Meter) Usage(ctx context.Context, userID, from, to string) ([]*repository.UsageRecord, error) {
+	return m.repo.ListUsage(ctx, userID, from, to)
+}
+
+// PeriodUsage returns the rollups of every user for period.
+func (m *Meter) PeriodUsage(ctx context.Context, period string) ([]*repository.UsageRecord, error) {
+	return m.repo.UsageForPeriod(ctx, period)
+}
+
+// SetBillingAccount links userID to a Stripe customer; usage of users without one is
+// tracked but not reported.
+func (m *Meter) SetBillingAccount(ctx context.Context, userID, stripeCustomerID string) error {
+	return m.repo.SetBillingAccount(ctx, userID, stripeCustomerID)
+}
+
+// Report is the worker handler for ReportJob. Each rollup's unreported units are
+// sent as one usage report identified by the rollup total, so a retry after a
+// partial failure never reports the same units twice. A failing customer does not
+// hold up the others.
+func (m *Meter) Report(ctx context.Context, _ *worker.Job) error {
+	if m.reporter == nil {
+		return nil
+	}
+	pending, err := m.repo.PendingUsage(ctx, m.opts.ReportBatchSize)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	reported := 0
+	for _, usage := range pending {
+		err := m.reporter.Report(ctx, Usage{
+			CustomerID: usage.StripeCustomerID,
+			Units:      usage.Units - usage.ReportedUnits,
+			Identifier: fmt.Sprintf("%s:%s:%d", usage.UserID, usage.Period, usage.Units),
+			Timestamp:  m.now(),
+		})
+		if err == nil {
+			err = m.repo.MarkReported(ctx, usage.ID, usage.Units)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("report usage of %s for %s: %w", usage.UserID, usage.Period, err))
+			continue
+		}
+		reported++
+	}
+	if reported > 0 {
+		m.logger.Info("reported usage", zap.Int("records", reported))
+	}
+	return errors.Join(errs...)
+}
+
+// WriteCSV writes records as CSV with a header row.
+func WriteCSV(w io.Writer, records []*repository.UsageRecord) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"user_id", "period", "units", "verifications", "reported_units", "updated_at"})
+	for _, record := range records {
+		out.Write([]string{
+			record.UserID,
+			record.Period,
+			strconv.FormatInt(record.Units, 10),
+			strconv.FormatInt(record.Verifications, 10),
+			strconv.FormatInt(record.ReportedUnits, 10),
+			record.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,166 @@
+package metering
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+type recordingReporter struct {
+	reports []Usage
+	fail    map[string]bool
+}
+
+func (r *recordingReporter) Report(ctx context.Context, usage Usage) error {
+	if r.fail[usage.CustomerID] {
+		return errors.New("customer not found")
+	}
+	r.reports = append(r.reports, usage)
+	return nil
+}
+
+func newTestMeter(t *testing.T, reporter Reporter) *Meter {
+	t.Helper()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewUsageRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	opts := DefaultOptions()
+	opts.UnitsPerVerification = 2
+	return NewMeterWithOptions(repo, reporter, zap.NewNop(), opts)
+}
+
+func completed(userID string, at time.Time) usecase.Event {
+	return usecase.Event{Type: usecase.EventVerificationCompleted, UserID: userID, CreatedAt: at}
+}
+
+func TestMeterRollsUpUsagePerMonth(t *testing.T) {
+	meter := newTestMeter(t, nil)
+	ctx := context.Background()
+	jan := time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 1, 1, 0, 0, 0, time.UTC)
+
+	for _, event := range []usecase.Event{
+		completed("user-1", jan),
+		completed("user-1", jan),
+		completed("user-1", feb),
+		completed("user-2", feb),
+		{Type: usecase.EventVerificationNeedsReview, UserID: "user-1", CreatedAt: feb},
+	} {
+		if err := meter.Publish(ctx, event); err != nil {
+			t.Fatalf("Publish returned error: %v", err)
+		}
+	}
+
+	records, err := meter.Usage(ctx, "user-1", "2024-01", "2024-12")
+	if err != nil {
+		t.Fatalf("Usage returned error: %v", err)
+	}
+	if len(records) != 2 || records[0].Period != "2024-01" || records[0].Units != 4 || records[0].Verifications != 2 || records[1].Units != 2 {
+		t.Fatalf("unexpected rollups %+v", records)
+	}
+
+	records, _ = meter.PeriodUsage(ctx, "2024-02")
+	var csv bytes.Buffer
+	if err := WriteCSV(&csv, records); err != nil {
+		t.Fatalf("WriteCSV returned error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "user-1,2024-02,2,1,0,") || !strings.HasPrefix(lines[2], "user-2,2024-02,2,1,0,") {
+		t.Fatalf("unexpected csv:\n%s", csv.String())
+	}
+}
+
+func TestReportSendsOnlyUnreportedUnits(t *testing.T) {
+	reporter := &recordingReporter{fail: map[string]bool{}}
+	meter := newTestMeter(t, reporter)
+	ctx := context.Background()
+	now := time.Now()
+
+	meter.SetBillingAccount(ctx, "user-1", "cus_1")
+	meter.SetBillingAccount(ctx, "user-2", "cus_2")
+	for _, userID := range []string{"user-1", "user-2", "user-3"} {
+		meter.Publish(ctx, completed(userID, now))
+	}
+
+	reporter.fail["cus_2"] = true
+	if err := meter.Report(ctx, nil); err == nil || !strings.Contains(err.Error(), "user-2") {
+		t.Fatalf("expected the failing customer to be reported, got %v", err)
+	}
+	if len(reporter.reports) != 1 || reporter.reports[0].CustomerID != "cus_1" || reporter.reports[0].Units != 2 {
+		t.Fatalf("expected user-1 to be reported despite user-2 failing, got %+v", reporter.reports)
+	}
+
+	reporter.fail["cus_2"] = false
+	meter.Publish(ctx, completed("user-1", now))
+	if err := meter.Report(ctx, nil); err != nil {
+		t.Fatalf("Report returned error: %v", err)
+	}
+	// user-3 has no billing account and is never reported.
+	if len(reporter.reports) != 3 {
+		t.Fatalf("expected two more reports, got %+v", reporter.reports)
+	}
+	for _, usage := range reporter.reports[1:] {
+		if usage.Units != 2 {
+			t.Fatalf("expected only new units to be reported, got %+v", usage)
+		}
+	}
+	if reporter.reports[1].Identifier == reporter.reports[0].Identifier {
+		t.Fatalf("expected distinct identifiers, got %q twice", reporter.reports[0].Identifier)
+	}
+}
+
+func TestStripePostsMeterEvent(t *testing.T) {
+	var got http.Header
+	var form map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		r.ParseForm()
+		form = r.PostForm
+		if r.PostForm.Get("payload[stripe_customer_id]") == "cus_missing" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"No such customer"}}`))
+		}
+	}))
+	defer server.Close()
+
+	stripe := &Stripe{APIKey: "sk_test", EventName: "ai_check_verifications", URL: server.URL}
+	usage := Usage{CustomerID: "cus_1", Units: 3, Identifier: "user-1:2024-01:3", Timestamp: time.Unix(1704067200, 0)}
+	if err := stripe.Report(context.Background(), usage); err != nil {
+		t.Fatalf("Report returned error: %v", err)
+	}
+	if got.Get("Authorization") != "Bearer sk_test" {
+		t.Fatalf("unexpected authorization %q", got.Get("Authorization"))
+	}
+	for key, want := range map[string]string{
+		"event_name":                  "ai_check_verifications",
+		"identifier":                  "user-1:2024-01:3",
+		"timestamp":                   "1704067200",
+		"payload[stripe_customer_id]": "cus_1",
+		"payload[value]":              "3",
+	} {
+		if len(form[key]) != 1 || form[key][0] != want {
+			t.Fatalf("expected %s=%s, got %v", key, want, form[key])
+		}
+	}
+
+	usage.CustomerID = "cus_missing"
+	if err := stripe.Report(context.Background(), usage); err == nil || !strings.Contains(err.Error(), "No such customer") {
+		t.Fatalf("expected the stripe error to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package metering
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// StripeMeterEventsURL is the Stripe API endpoint that records billing meter events.
+const StripeMeterEventsURL = "https://api.stripe.com/v1/billing/meter_events"
+
+// Stripe reports usage as events of a Stripe billing meter. The meter must sum the
+// "value" payload key and identify customers by "stripe_customer_id", which are
+// Stripe's defaults.
+type Stripe struct {
+	APIKey    string
+	EventName string
+	// URL overrides StripeMeterEventsURL.
+	URL    string
+	Client *http.Client
+}
+
+// Report implements Reporter. Stripe drops events whose identifier it has already
+// seen, so retrying a report is safe.
+func (s *Stripe) Report(ctx context.Context, usage Usage) error {
+	form := url.Values{}
+	form.Set("event_name", s.EventName)
+	form.Set("identifier", usage.Identifier)
+	form.Set("timestamp", strconv.FormatInt(usage.Timestamp.Unix(), 10))
+	form.Set("payload[stripe_customer_id]", usage.CustomerID)
+	form.Set("payload[value]", strconv.FormatInt(usage.Units, 10))
+
+	endpoint := s.URL
+	if endpoint == "" {
+		endpoint = StripeMeterEventsURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post meter event to stripe: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("post meter event to stripe: status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
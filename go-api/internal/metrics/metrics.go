@@ -0,0 +1,148 @@
+// Package metrics keeps Prometheus counters and histograms of the HTTP API, the
+// verifications, their Redis retries and the image processor calls, and serves them
+// in the Prometheus text format. Like the live metrics, the figures cover only the
+// requests handled by this process since it started.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultBuckets are the latency buckets in seconds, from 5ms to 30s.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// unmatchedRoute labels requests that matched no route, so arbitrary paths do not
+// create series.
+const unmatchedRoute = "unmatched"
+
+// Metrics are the instruments of the service. It is safe for concurrent use.
+type Metrics struct {
+	registry      *Registry
+	httpRequests  *Counter
+	httpDuration  *Histogram
+	verifications *Histogram
+	redisRetries  *Counter
+	grpcDuration  *Histogram
+	logsPurged    *Counter
+}
+
+// New returns the instruments registered in a new registry.
+func New() *Metrics {
+	registry := NewRegistry()
+	return &Metrics{
+		registry: registry,
+		httpRequests: registry.NewCounter("ai_check_http_requests_total",
+			"HTTP requests by method, route pattern and status code.", "method", "route", "status"),
+		httpDuration: registry.NewHistogram("ai_check_http_request_duration_seconds",
+			"Time to answer HTTP requests by method and route pattern.", DefaultBuckets, "method", "route"),
+		verifications: registry.NewHistogram("ai_check_verify_image_duration_seconds",
+			"Time VerifyImage took by outcome: verified, not_verified, duplicate, rejected, overloaded, queued or failed.", DefaultBuckets, "outcome"),
+		redisRetries: registry.NewCounter("ai_check_redis_retries_total",
+			"Redis operations retried after a transient error, by operation.", "operation"),
+		grpcDuration: registry.NewHistogram("ai_check_grpc_client_duration_seconds",
+			"Duration of image processor calls by gRPC method and status code.", DefaultBuckets, "method", "code"),
+		logsPurged: registry.NewCounter("ai_check_logs_purged_total",
+			"Verification logs deleted by purges, by reason: retention or erasure.", "reason"),
+	}
+}
+
+// Handler serves the metrics to Prometheus scrapes.
+func (m *Metrics) Handler() http.Handler {
+	return m.registry.Handler()
+}
+
+// Write writes the metrics in the Prometheus text format.
+func (m *Metrics) Write(w io.Writer) error {
+	return m.registry.Write(w)
+}
+
+// Middleware counts and times the requests of a gin router by route pattern.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		started := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		m.httpRequests.Inc(method, route, strconv.Itoa(c.Writer.Status()))
+		m.httpDuration.Observe(time.Since(started).Seconds(), method, route)
+	}
+}
+
+// ObserveVerifyImage records how long a verification took and how it ended.
+func (m *Metrics) ObserveVerifyImage(outcome string, took time.Duration) {
+	m.verifications.Observe(took.Seconds(), outcome)
+}
+
+// ObserveRedisRetry counts a retry of a Redis operation.
+func (m *Metrics) ObserveRedisRetry(operation string) {
+	m.redisRetries.Inc(operation)
+}
+
+// ObserveLogsPurged counts verification logs a purge deleted for reason.
+func (m *Metrics) ObserveLogsPurged(reason string, rows int64) {
+	m.logsPurged.Add(float64(rows), reason)
+}
+
+// UnaryClientInterceptor times unary gRPC calls.
+func (m *Metrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		started := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.observeCall(method, started, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor times streaming gRPC calls, until their final status is
+// received.
+func (m *Metrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		started := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			m.observeCall(method, started, err)
+			return nil, err
+		}
+		return &timedStream{ClientStream: stream, serverStreams: desc.ServerStreams, finish: func(err error) {
+			m.observeCall(method, started, err)
+		}}, nil
+	}
+}
+
+func (m *Metrics) observeCall(method string, started time.Time, err error) {
+	m.grpcDuration.Observe(time.Since(started).Seconds(), method, status.Code(err).String())
+}
+
+// timedStream reports the end of a stream once: when receiving fails or ends, or
+// after the single response of a stream the server does not stream.
+type timedStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	once          sync.Once
+	finish        func(err error)
+}
+
+func (s *timedStream) RecvMsg(msg interface{}) error {
+	err := s.ClientStream.RecvMsg(msg)
+	if err != nil || !s.serverStreams {
+		final := err
+		if errors.Is(final, io.EOF) {
+			final = nil
+		}
+		s.once.Do(func() { s.finish(final) })
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := recorder.Header().Get("Content-Type"); got != contentType {
+		t.Fatalf("unexpected content type %q", got)
+	}
+	return recorder.Body.String()
+}
+
+func expectLines(t *testing.T, body string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("expected %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestMiddlewareCountsRequestsByRoutePattern(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/result/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	for _, path := range []string{"/result/a", "/result/b", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	expectLines(t, scrape(t, m),
+		"# TYPE ai_check_http_requests_total counter",
+		`ai_check_http_requests_total{method="GET",route="/result/:id",status="200"} 2`,
+		`ai_check_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`ai_check_http_request_duration_seconds_count{method="GET",route="/result/:id"} 2`,
+	)
+}
+
+func TestHistogramsAreCumulative(t *testing.T) {
+	registry := NewRegistry()
+	histogram := registry.NewHistogram("test_seconds", "Test\nhistogram.", []float64{0.1, 1}, "name")
+	histogram.Observe(0.05, `a"b`)
+	histogram.Observe(0.1, `a"b`)
+	histogram.Observe(0.5, `a"b`)
+	histogram.Observe(3, `a"b`)
+
+	var out strings.Builder
+	if err := registry.Write(&out); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	expectLines(t, out.String(),
+		`# HELP test_seconds Test\nhistogram.`,
+		`test_seconds_bucket{name="a\"b",le="0.1"} 2`,
+		`test_seconds_bucket{name="a\"b",le="1"} 3`,
+		`test_seconds_bucket{name="a\"b",le="+Inf"} 4`,
+		`test_seconds_sum{name="a\"b"} 3.65`,
+		`test_seconds_count{name="a\"b"} 4`,
+	)
+}
+
+type stubStream struct {
+	grpc.ClientStream
+	err error
+}
+
+func (s *stubStream) RecvMsg(interface{}) error {
+	return s.err
+}
+
+func TestClientInterceptorsTimeCallsByStatus(t *testing.T) {
+	m := New()
+	unary := m.UnaryClientInterceptor()
+	unary(context.Background(), "/proto.ImageProcessor/Verify", nil, nil, nil, func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "down")
+	})
+
+	stream := m.StreamClientInterceptor()
+	desc := &grpc.StreamDesc{ClientStreams: true}
+	cs, err := stream(context.Background(), desc, nil, "/proto.ImageProcessor/VerifyStream", func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &stubStream{}, nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor returned error: %v", err)
+	}
+	// The single response of a client stream ends the call.
+	if err := cs.RecvMsg(nil); err != nil {
+		t.Fatalf("RecvMsg returned error: %v", err)
+	}
+
+	serverStream, _ := stream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/proto.ImageProcessor/Watch", func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &stubStream{err: io.EOF}, nil
+	})
+	if err := serverStream.RecvMsg(nil); err != io.EOF {
+		t.Fatalf("expected io.EOF to be passed through, got %v", err)
+	}
+
+	expectLines(t, scrape(t, m),
+		`ai_check_grpc_client_duration_seconds_count{method="/proto.ImageProcessor/Verify",code="Unavailable"} 1`,
+		`ai_check_grpc_client_duration_seconds_count{method="/proto.ImageProcessor/VerifyStream",code="OK"} 1`,
+		`ai_check_grpc_client_duration_seconds_count{method="/proto.ImageProcessor/Watch",code="OK"} 1`,
+	)
+}
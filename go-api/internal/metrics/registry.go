@@ -0,0 +1,261 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// contentType is the Prometheus text exposition format served by Registry.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// collector is a metric family the registry writes.
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families and writes them in the Prometheus text format. It
+// is safe for concurrent use.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewCounter registers a counter family partitioned by labels.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	counter := &Counter{family: newFamily(name, help, "counter", labels)}
+	r.register(counter)
+	return counter
+}
+
+// NewHistogram registers a histogram family partitioned by labels, counting
+// observations into buckets given as increasing upper bounds.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	histogram := &Histogram{family: newFamily(name, help, "histogram", labels), buckets: buckets}
+	r.register(histogram)
+	return histogram
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Write writes every family to w in registration order.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	buffered := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+// Handler serves the registry to Prometheus scrapes.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_ = r.Write(w)
+	})
+}
+
+// family is the state shared by the series of one metric.
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.RWMutex
+	series map[string]interface{}
+}
+
+func newFamily(name, help, kind string, labels []string) family {
+	return family{name: name, help: help, kind: kind, labels: labels, series: make(map[string]interface{})}
+}
+
+// seriesFor returns the series of labelValues, creating it with create when it is
+// new. Missing label values are empty and extra ones are ignored.
+func (f *family) seriesFor(labelValues []string, create func(labels string) interface{}) interface{} {
+	key := strings.Join(labelValues, "\xff")
+	f.mu.RLock()
+	series, ok := f.series[key]
+	f.mu.RUnlock()
+	if ok {
+		return series
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if series, ok := f.series[key]; ok {
+		return series
+	}
+	series = create(formatLabels(f.labels, labelValues))
+	f.series[key] = series
+	return series
+}
+
+// sorted returns the series ordered by their labels, so scrapes are stable.
+func (f *family) sorted() []interface{} {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	series := make([]interface{}, len(keys))
+	for i, key := range keys {
+		series[i] = f.series[key]
+	}
+	return series
+}
+
+func (f *family) writeHeader(w *bufio.Writer) {
+	w.WriteString("# HELP " + f.name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help) + "\n")
+	w.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+}
+
+// formatLabels renders labels as the inside of a label set, e.g. `route="/verify"`.
+func formatLabels(names, values []string) string {
+	var b strings.Builder
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name + `="` + escape.Replace(value) + `"`)
+	}
+	return b.String()
+}
+
+// withLabel appends one more label to a rendered label set.
+func withLabel(labels, name, value string) string {
+	if labels == "" {
+		return name + `="` + value + `"`
+	}
+	return labels + "," + name + `="` + value + `"`
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatValue(value) + "\n")
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// atomicFloat is a float64 updated without locks.
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) add(delta float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+// Counter is a family of counters that only go up.
+type Counter struct {
+	family
+}
+
+type counterSeries struct {
+	labels string
+	value  atomicFloat
+}
+
+// Inc adds one to the series of labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative, to the series of labelValues.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	series := c.seriesFor(labelValues, func(labels string) interface{} {
+		return &counterSeries{labels: labels}
+	}).(*counterSeries)
+	series.value.add(delta)
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	for _, s := range c.sorted() {
+		series := s.(*counterSeries)
+		writeSample(w, c.name, series.labels, series.value.load())
+	}
+}
+
+// Histogram is a family of histograms with the same buckets.
+type Histogram struct {
+	family
+	buckets []float64
+}
+
+type histogramSeries struct {
+	labels string
+	// counts holds the observations per bucket, not cumulated; the last one counts
+	// those above every bucket.
+	counts []atomic.Uint64
+	sum    atomicFloat
+}
+
+// Observe records value in the series of labelValues.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	series := h.seriesFor(labelValues, func(labels string) interface{} {
+		return &histogramSeries{labels: labels, counts: make([]atomic.Uint64, len(h.buckets)+1)}
+	}).(*histogramSeries)
+	series.counts[sort.SearchFloat64s(h.buckets, value)].Add(1)
+	series.sum.add(value)
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.writeHeader(w)
+	for _, s := range h.sorted() {
+		series := s.(*histogramSeries)
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i].Load()
+			writeSample(w, h.name+"_bucket", withLabel(series.labels, "le", formatValue(bound)), float64(cumulative))
+		}
+		cumulative += series.counts[len(h.buckets)].Load()
+		writeSample(w, h.name+"_bucket", withLabel(series.labels, "le", "+Inf"), float64(cumulative))
+		writeSample(w, h.name+"_sum", series.labels, series.sum.load())
+		writeSample(w, h.name+"_count", series.labels, float64(cumulative))
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+type contextKey string
+
+const clientIPKey contextKey = "clientIP"
+
+// ClientIPConfig controls how the client address is derived from requests that may
+// have passed through load balancers or reverse proxies.
+type ClientIPConfig struct {
+	// TrustedProxies lists the CIDRs or addresses of proxies whose forwarding headers
+	// are believed. Headers from any other peer are ignored.
+	TrustedProxies []string
+	// Headers are consulted in order, walking X-Forwarded-For style lists from the
+	// right and skipping trusted proxies.
+	Headers []string
+	// Platform is "cloudflare", "google-app-engine" or a header set by the edge that
+	// always holds the client address. It takes precedence over Headers.
+	Platform string
+}
+
+// Configure applies the settings to engine, so gin's ClientIP, its request logger and
+// the ClientIP middleware all agree on the client address.
+func (c ClientIPConfig) Configure(engine *gin.Engine) error {
+	engine.ForwardedByClientIP = len(c.Headers) > 0
+	engine.RemoteIPHeaders = c.Headers
+	switch c.Platform {
+	case "cloudflare":
+		engine.TrustedPlatform = gin.PlatformCloudflare
+	case "google-app-engine":
+		engine.TrustedPlatform = gin.PlatformGoogleAppEngine
+	default:
+		engine.TrustedPlatform = c.Platform
+	}
+	return engine.SetTrustedProxies(c.TrustedProxies)
+}
+
+// ClientIP records the resolved client address in the request context for code that
+// does not see the gin context, such as rate limiters and audit logging.
+func ClientIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), clientIPKey, c.ClientIP())
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// ClientIPFromContext returns the address stored by ClientIP.
+func ClientIPFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	if value, ok := ctx.Value(clientIPKey).(string); ok && value != "" {
+		return value, true
+	}
+	return "", false
+}
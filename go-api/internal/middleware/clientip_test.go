@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIPOnlyBelievesTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	cfg := ClientIPConfig{TrustedProxies: []string{"10.0.0.0/8"}, Headers: []string{"X-Forwarded-For"}}
+	if err := cfg.Configure(router); err != nil {
+		t.Fatalf("Configure returned error: %v", err)
+	}
+	router.Use(ClientIP())
+	router.GET("/ip", func(c *gin.Context) {
+		ip, _ := ClientIPFromContext(c.Request.Context())
+		c.String(http.StatusOK, ip)
+	})
+
+	for _, tc := range []struct {
+		name, remoteAddr, forwarded, want string
+	}{
+		{"trusted proxy chain", "10.1.2.3:4567", "198.51.100.1, 203.0.113.9, 10.0.0.2", "203.0.113.9"},
+		{"untrusted peer spoofing the header", "192.0.2.44:4567", "203.0.113.9", "192.0.2.44"},
+		{"no header", "10.1.2.3:4567", "", "10.1.2.3"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		if got := resp.Body.String(); got != tc.want {
+			t.Fatalf("%s: expected client IP %s, got %s", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestClientIPUsesTrustedPlatformHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	if err := (ClientIPConfig{Platform: "cloudflare"}).Configure(router); err != nil {
+		t.Fatalf("Configure returned error: %v", err)
+	}
+	router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+	req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	req.RemoteAddr = "172.64.0.1:443"
+	req.Header.Set("CF-Connecting-IP", "203.0.113.50")
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if got := resp.Body.String(); got != "203.0.113.50" {
+		t.Fatalf("expected the platform header to win, got %s", got)
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/ai-check/internal/httperr"
+)
+
+// ConcurrencyLimiter sheds requests once too many are in flight, globally or for a
+// single route, rather than letting them queue until they time out downstream.
+type ConcurrencyLimiter struct {
+	// slots holds the limits; SetLimits replaces it.
+	slots      atomic.Pointer[concurrencySlots]
+	retryAfter string
+}
+
+// concurrencySlots holds a slot for every request in flight under a limit.
+type concurrencySlots struct {
+	global chan struct{}
+	routes map[string]chan struct{}
+}
+
+// NewConcurrencyLimiter builds a limiter. A global limit of zero disables the global
+// bound; routes are keyed by method and route pattern, e.g. "POST /verify".
+func NewConcurrencyLimiter(global int, routes map[string]int, retryAfter time.Duration) *ConcurrencyLimiter {
+	l := &ConcurrencyLimiter{
+		retryAfter: strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds())))),
+	}
+	l.SetLimits(global, routes)
+	return l
+}
+
+// SetLimits replaces the global and route limits, e.g. after a configuration
+// reload. Requests already in flight are not counted against the new limits.
+func (l *ConcurrencyLimiter) SetLimits(global int, routes map[string]int) {
+	slots := &concurrencySlots{routes: make(map[string]chan struct{}, len(routes))}
+	if global > 0 {
+		slots.global = make(chan struct{}, global)
+	}
+	for route, limit := range routes {
+		if limit > 0 {
+			slots.routes[route] = make(chan struct{}, limit)
+		}
+	}
+	l.slots.Store(slots)
+}
+
+// Middleware rejects requests over the limit with 503 and a Retry-After header.
+// WebSocket upgrades are exempt from the global limit, since a stream would hold its
+// slot for as long as it stays open; a route limit still bounds them.
+func (l *ConcurrencyLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// A request releases its slots to the limits it took them from.
+		slots := l.slots.Load()
+		if route, ok := slots.routes[c.Request.Method+" "+c.FullPath()]; ok {
+			if !tryAcquire(route) {
+				l.shed(c)
+				return
+			}
+			defer release(route)
+		}
+		if slots.global != nil && !c.IsWebsocket() {
+			if !tryAcquire(slots.global) {
+				l.shed(c)
+				return
+			}
+			defer release(slots.global)
+		}
+		c.Next()
+	}
+}
+
+func (l *ConcurrencyLimiter) shed(c *gin.Context) {
+	c.Header("Retry-After", l.retryAfter)
+	httperr.Write(c, httperr.CodeOverloaded, "server is overloaded, retry later")
+}
+
+func tryAcquire(slots chan struct{}) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func release(slots chan struct{}) {
+	<-slots
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConcurrencyLimiterShedsRequestsOverRouteLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	router := gin.New()
+	router.Use(NewConcurrencyLimiter(0, map[string]int{"POST /verify": 1}, 1500*time.Millisecond).Middleware())
+	router.POST("/verify", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/verify", nil))
+	}()
+	<-entered
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/verify", nil))
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while the route is saturated, got %d", resp.Code)
+	}
+	if got := resp.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("expected Retry-After to round up to 2 seconds, got %q", got)
+	}
+
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected other routes to be unaffected, got %d", resp.Code)
+	}
+
+	close(release)
+	wg.Wait()
+
+	go func() { <-entered }()
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/verify", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected the slot to be released, got %d", resp.Code)
+	}
+}
+
+func TestConcurrencyLimiterAppliesGlobalLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	limiter := NewConcurrencyLimiter(1, nil, time.Second)
+	router := gin.New()
+	router.Use(limiter.Middleware())
+	router.GET("/slow", func(c *gin.Context) {
+		close(entered)
+		<-release
+	})
+	router.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+		close(done)
+	}()
+	<-entered
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 once the global limit is reached, got %d", resp.Code)
+	}
+
+	// Raised limits apply to the next request.
+	limiter.SetLimits(2, nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected the raised limit to let the request through, got %d", resp.Code)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestConcurrencyLimiterExemptsWebSocketsFromTheGlobalLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	router := gin.New()
+	router.Use(NewConcurrencyLimiter(1, nil, time.Second).Middleware())
+	router.GET("/stream", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-entered
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected an open stream not to take the global slot, got %d", resp.Code)
+	}
+
+	close(release)
+	wg.Wait()
+}
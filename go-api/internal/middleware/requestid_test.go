@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+func TestRequestIDPropagatesValidIDsAndReplacesOthers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var seen string
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		seen = logging.RequestIDFromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	for _, tc := range []struct {
+		header string
+		keep   bool
+	}{
+		{"req-1.a:b_c", true},
+		{"", false},
+		{"has space", false},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			req.Header.Set(RequestIDHeader, tc.header)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		got := resp.Header().Get(RequestIDHeader)
+		if got == "" || got != seen {
+			t.Fatalf("expected the response header %q to match the context %q", got, seen)
+		}
+		if (got == tc.header) != tc.keep {
+			t.Fatalf("header %q: got request ID %q", tc.header, got)
+		}
+	}
+}
+
+func TestRequestLoggerTagsLinesWithTheRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zap.InfoLevel)
+	router := gin.New()
+	router.Use(RequestID(), RequestLogger(zap.New(core)))
+	router.GET("/result/:id", func(c *gin.Context) {
+		logging.FromContext(c.Request.Context(), zap.NewNop()).Info("handled")
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/result/abc", nil)
+	req.Header.Set(RequestIDHeader, "corr-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.All()
+	if len(entries) != 2 || entries[0].Message != "handled" || entries[1].Message != "http request" {
+		t.Fatalf("expected the handler's line and the request line, got %+v", entries)
+	}
+	for _, entry := range entries {
+		if entry.ContextMap()[logging.CorrelationField] != "corr-1" {
+			t.Fatalf("expected %q to carry the request ID, got %v", entry.Message, entry.ContextMap())
+		}
+	}
+	if fields := entries[1].ContextMap(); fields["route"] != "/result/:id" || fields["status"] != int64(http.StatusNoContent) {
+		t.Fatalf("unexpected request line %v", fields)
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+// RequestLogger stores a logger tagged with the correlation ID of the request in its
+// context, for logging.FromContext, and logs every request once it is answered.
+// Mount it after RequestID.
+func RequestLogger(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		started := time.Now()
+		requestLogger := logging.FromContext(c.Request.Context(), logger)
+		c.Request = c.Request.WithContext(logging.WithLogger(c.Request.Context(), requestLogger))
+		c.Next()
+
+		requestLogger.Info("http request",
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("route", c.FullPath()),
+			zap.Int("status", c.Writer.Status()),
+			zap.Duration("latency", time.Since(started)),
+			zap.String("client_ip", c.ClientIP()))
+	}
+}
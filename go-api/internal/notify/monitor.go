@@ -0,0 +1,313 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/imageprocessor"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+// Rule names, also used as template names.
+const (
+	RuleConsecutiveFailures = "consecutive_failures"
+	RuleErrorRate           = "error_rate"
+)
+
+// DefaultTemplates are the message templates used when none is configured. The
+// first rendered line becomes the email subject.
+var DefaultTemplates = map[string]string{
+	RuleConsecutiveFailures: `ai-check: {{.Failures}} failed verifications in a row for user {{.UserID}}
+The last {{.Failures}} verifications of user {{.UserID}} were not verified (latest at {{.Time.Format "2006-01-02 15:04:05 MST"}}).`,
+	RuleErrorRate: `ai-check: image processor error rate at {{percent .ErrorRate}}
+{{.Errors}} of {{.Requests}} image processor calls failed in the last {{.Window}} (threshold {{percent .Threshold}}).`,
+}
+
+// Alert is the data a rule passes to its template.
+type Alert struct {
+	Rule   string
+	UserID string
+	// Failures is the length of the user's failure streak.
+	Failures int64
+	// Errors, Requests and ErrorRate describe processor calls within Window.
+	Errors    int
+	Requests  int
+	ErrorRate float64
+	Threshold float64
+	Window    time.Duration
+	Time      time.Time
+}
+
+var templateFuncs = template.FuncMap{
+	"percent": func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) },
+}
+
+// ParseTemplate parses a message template with the helper functions available to it.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+// Options selects the conditions that raise alerts.
+type Options struct {
+	// ConsecutiveFailures alerts when a user's last N verifications all failed; 0
+	// disables the rule.
+	ConsecutiveFailures int
+	// ErrorRateThreshold alerts when at least this fraction of processor calls in
+	// ErrorRateWindow failed, once MinRequests calls were made; 0 disables the rule.
+	ErrorRateThreshold float64
+	ErrorRateWindow    time.Duration
+	MinRequests        int
+	// Cooldown is the minimum time between two alerts of a rule for the same user,
+	// or between two error rate alerts, across all replicas.
+	Cooldown time.Duration
+	// Timeout bounds sending one alert to all notifiers.
+	Timeout time.Duration
+	// Templates overrides DefaultTemplates per rule.
+	Templates map[string]string
+}
+
+// DefaultOptions returns the conditions used by NewMonitor.
+func DefaultOptions() Options {
+	return Options{
+		ConsecutiveFailures: 5,
+		ErrorRateThreshold:  0.5,
+		ErrorRateWindow:     5 * time.Minute,
+		MinRequests:         20,
+		Cooldown:            15 * time.Minute,
+		Timeout:             10 * time.Second,
+	}
+}
+
+// failureStreakTTL forgets streaks of users who stopped sending images.
+const failureStreakTTL = 24 * time.Hour
+
+const keyPrefix = "ai-check:notify:"
+
+// Monitor evaluates the alert rules and sends rendered alerts to every notifier.
+// Failure streaks and throttling live in Redis so replicas share them; the processor
+// error rate is measured per replica.
+type Monitor struct {
+	redis     *redis.Client
+	notifiers []Notifier
+	templates map[string]*template.Template
+	window    *rateWindow
+	logger    *zap.Logger
+	opts      Options
+	now       func() time.Time
+}
+
+// NewMonitor returns a monitor with DefaultOptions.
+func NewMonitor(client *redis.Client, notifiers []Notifier, logger *zap.Logger) (*Monitor, error) {
+	return NewMonitorWithOptions(client, notifiers, logger, DefaultOptions())
+}
+
+// NewMonitorWithOptions returns a monitor with explicit conditions. It fails when a
+// template does not parse.
+func NewMonitorWithOptions(client *redis.Client, notifiers []Notifier, logger *zap.Logger, opts Options) (*Monitor, error) {
+	templates := make(map[string]*template.Template, len(DefaultTemplates))
+	for rule, text := range DefaultTemplates {
+		if custom := opts.Templates[rule]; custom != "" {
+			text = custom
+		}
+		tmpl, err := ParseTemplate(rule, text)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s template: %w", rule, err)
+		}
+		templates[rule] = tmpl
+	}
+	return &Monitor{
+		redis:     client,
+		notifiers: notifiers,
+		templates: templates,
+		window:    newRateWindow(opts.ErrorRateWindow),
+		logger:    logger.Named("notify"),
+		opts:      opts,
+		now:       time.Now,
+	}, nil
+}
+
+// Publish implements usecase.EventPublisher, tracking each user's streak of
+// failed verifications.
+func (m *Monitor) Publish(ctx context.Context, event usecase.Event) error {
+	if m.opts.ConsecutiveFailures <= 0 || event.Type != usecase.EventVerificationCompleted {
+		return nil
+	}
+	data, ok := event.Data.(usecase.VerificationEvent)
+	if !ok {
+		return nil
+	}
+	key := keyPrefix + "failures:" + event.UserID
+	if data.Verified {
+		return m.redis.Del(ctx, key).Err()
+	}
+
+	pipe := m.redis.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, failureStreakTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("count failed verifications: %w", err)
+	}
+	if incr.Val() < int64(m.opts.ConsecutiveFailures) {
+		return nil
+	}
+	return m.raise(ctx, event.UserID, Alert{
+		Rule:     RuleConsecutiveFailures,
+		UserID:   event.UserID,
+		Failures: incr.Val(),
+		Time:     event.CreatedAt,
+	})
+}
+
+// ObserveProcessor wraps client so its errors count towards the error rate rule.
+func (m *Monitor) ObserveProcessor(client imageprocessor.Client) imageprocessor.Client {
+	if m.opts.ErrorRateThreshold <= 0 {
+		return client
+	}
+	return &observedProcessor{Client: client, monitor: m}
+}
+
+type observedProcessor struct {
+	imageprocessor.Client
+	monitor *Monitor
+}
+
+func (p *observedProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
+	result, err := p.Client.Process(ctx, userID, imageBytes)
+	// Callers giving up are not a processor problem.
+	if !errors.Is(err, context.Canceled) {
+		p.monitor.recordProcessorCall(ctx, err != nil)
+	}
+	return result, err
+}
+
+// ProcessStream implements imageprocessor.StreamClient, streaming when the wrapped
+// client does.
+func (p *observedProcessor) ProcessStream(ctx context.Context, userID string, image io.Reader) (*imageprocessor.Result, error) {
+	result, err := imageprocessor.ProcessReader(ctx, p.Client, userID, image)
+	// Neither are uploads that could not be read.
+	var readErr *imageprocessor.ReadError
+	if !errors.Is(err, context.Canceled) && !errors.As(err, &readErr) {
+		p.monitor.recordProcessorCall(ctx, err != nil)
+	}
+	return result, err
+}
+
+func (m *Monitor) recordProcessorCall(ctx context.Context, failed bool) {
+	now := m.now()
+	requests, errs := m.window.add(now, failed)
+	if !failed || requests < m.opts.MinRequests {
+		return
+	}
+	rate := float64(errs) / float64(requests)
+	if rate < m.opts.ErrorRateThreshold {
+		return
+	}
+	// The request's context may be about to end; the check must not depend on it.
+	err := m.raise(context.WithoutCancel(ctx), "", Alert{
+		Rule:      RuleErrorRate,
+		Errors:    errs,
+		Requests:  requests,
+		ErrorRate: rate,
+		Threshold: m.opts.ErrorRateThreshold,
+		Window:    m.opts.ErrorRateWindow,
+		Time:      now,
+	})
+	if err != nil {
+		m.logger.Error("failed to raise error rate alert", zap.Error(err))
+	}
+}
+
+// raise sends alert unless the rule fired for key within the cooldown. Sending
+// happens in the background so requests are not held up by slow notifiers.
+func (m *Monitor) raise(ctx context.Context, key string, alert Alert) error {
+	if m.opts.Cooldown > 0 {
+		acquired, err := m.redis.SetNX(ctx, keyPrefix+"throttle:"+alert.Rule+":"+key, m.now().Unix(), m.opts.Cooldown).Result()
+		if err != nil {
+			return fmt.Errorf("check alert throttle: %w", err)
+		}
+		if !acquired {
+			return nil
+		}
+	}
+	msg, err := m.render(alert)
+	if err != nil {
+		return err
+	}
+	go m.send(alert, msg)
+	return nil
+}
+
+func (m *Monitor) render(alert Alert) (Message, error) {
+	var b bytes.Buffer
+	if err := m.templates[alert.Rule].Execute(&b, alert); err != nil {
+		return Message{}, fmt.Errorf("render %s alert: %w", alert.Rule, err)
+	}
+	text := strings.TrimSpace(b.String())
+	subject, _, _ := strings.Cut(text, "\n")
+	return Message{Subject: strings.TrimSpace(subject), Text: text}, nil
+}
+
+func (m *Monitor) send(alert Alert, msg Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.opts.Timeout)
+	defer cancel()
+	for _, notifier := range m.notifiers {
+		if err := notifier.Notify(ctx, msg); err != nil {
+			m.logger.Error("failed to send alert", zap.String("rule", alert.Rule), zap.String("notifier", fmt.Sprintf("%T", notifier)), zap.Error(err))
+		}
+	}
+}
+
+const rateBuckets = 10
+
+// rateWindow counts calls and failures in rateBuckets slots covering a sliding window.
+type rateWindow struct {
+	mu      sync.Mutex
+	width   time.Duration
+	buckets [rateBuckets]rateBucket
+}
+
+type rateBucket struct {
+	slot     int64
+	requests int
+	errors   int
+}
+
+func newRateWindow(window time.Duration) *rateWindow {
+	width := window / rateBuckets
+	if width <= 0 {
+		width = time.Second
+	}
+	return &rateWindow{width: width}
+}
+
+// add records one call at now and returns the totals within the window.
+func (w *rateWindow) add(now time.Time, failed bool) (requests, failures int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	slot := now.UnixNano() / int64(w.width)
+	bucket := &w.buckets[slot%rateBuckets]
+	if bucket.slot != slot {
+		*bucket = rateBucket{slot: slot}
+	}
+	bucket.requests++
+	if failed {
+		bucket.errors++
+	}
+	for _, b := range w.buckets {
+		if slot-b.slot < rateBuckets {
+			requests += b.requests
+			failures += b.errors
+		}
+	}
+	return requests, failures
+}
@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/imageprocessor"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+type recordingNotifier struct {
+	messages chan Message
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, msg Message) error {
+	r.messages <- msg
+	return nil
+}
+
+func (r *recordingNotifier) next(t *testing.T) Message {
+	t.Helper()
+	select {
+	case msg := <-r.messages:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("expected an alert")
+		return Message{}
+	}
+}
+
+func (r *recordingNotifier) none(t *testing.T) {
+	t.Helper()
+	select {
+	case msg := <-r.messages:
+		t.Fatalf("expected no alert, got %q", msg.Subject)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func newTestMonitor(t *testing.T, opts Options) (*Monitor, *recordingNotifier, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	notifier := &recordingNotifier{messages: make(chan Message, 10)}
+	monitor, err := NewMonitorWithOptions(client, []Notifier{notifier}, zap.NewNop(), opts)
+	if err != nil {
+		t.Fatalf("NewMonitorWithOptions returned error: %v", err)
+	}
+	return monitor, notifier, server
+}
+
+func verification(userID string, verified bool) usecase.Event {
+	return usecase.Event{
+		Type:      usecase.EventVerificationCompleted,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+		Data:      usecase.VerificationEvent{Verified: verified},
+	}
+}
+
+func TestMonitorAlertsOnConsecutiveFailuresWithCooldown(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ConsecutiveFailures = 3
+	opts.Templates = map[string]string{RuleConsecutiveFailures: "{{.UserID}} failed {{.Failures}} times\nplease check"}
+	monitor, notifier, server := newTestMonitor(t, opts)
+	ctx := context.Background()
+
+	// A success resets the streak.
+	for _, verified := range []bool{false, false, true, false, false} {
+		if err := monitor.Publish(ctx, verification("user-1", verified)); err != nil {
+			t.Fatalf("Publish returned error: %v", err)
+		}
+	}
+	notifier.none(t)
+
+	monitor.Publish(ctx, verification("user-1", false))
+	msg := notifier.next(t)
+	if msg.Subject != "user-1 failed 3 times" || msg.Text != "user-1 failed 3 times\nplease check" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	monitor.Publish(ctx, verification("user-1", false))
+	notifier.none(t)
+	server.FastForward(opts.Cooldown)
+	monitor.Publish(ctx, verification("user-1", false))
+	if msg := notifier.next(t); !strings.Contains(msg.Subject, "5 times") {
+		t.Fatalf("expected an alert after the cooldown, got %q", msg.Subject)
+	}
+}
+
+type flakyProcessor struct {
+	err error
+}
+
+func (p *flakyProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &imageprocessor.Result{Success: true}, nil
+}
+
+func TestMonitorAlertsOnProcessorErrorRate(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ErrorRateThreshold = 0.5
+	opts.MinRequests = 4
+	monitor, notifier, _ := newTestMonitor(t, opts)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	monitor.now = func() time.Time { return now }
+
+	backend := &flakyProcessor{}
+	processor := monitor.ObserveProcessor(backend)
+	call := func(err error) {
+		backend.err = err
+		processor.Process(context.Background(), "user-1", nil)
+	}
+
+	call(nil)
+	call(nil)
+	call(errors.New("unavailable"))
+	call(context.Canceled)
+	notifier.none(t)
+	call(errors.New("unavailable"))
+	if msg := notifier.next(t); !strings.Contains(msg.Text, "2 of 4 image processor calls failed in the last 5m0s") {
+		t.Fatalf("unexpected message %q", msg.Text)
+	}
+
+	// Calls that left the window no longer count.
+	now = now.Add(opts.ErrorRateWindow + opts.Cooldown)
+	call(errors.New("unavailable"))
+	notifier.none(t)
+}
+
+func TestSlackNotifierPostsText(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		if got["text"] == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid_payload"))
+		}
+	}))
+	defer server.Close()
+
+	slack := &Slack{WebhookURL: server.URL}
+	if err := slack.Notify(context.Background(), Message{Subject: "subject", Text: "subject\nbody"}); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	if got["text"] != "subject\nbody" {
+		t.Fatalf("unexpected payload %v", got)
+	}
+	if err := slack.Notify(context.Background(), Message{Text: "fail"}); err == nil || !strings.Contains(err.Error(), "invalid_payload") {
+		t.Fatalf("expected the slack error to be reported, got %v", err)
+	}
+}
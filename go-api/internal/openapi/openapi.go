@@ -0,0 +1,228 @@
+// Package openapi builds OpenAPI 3 documents from a registry of routes. Schemas are
+// derived from the Go types the handlers encode, so the published description of
+// the API follows the code.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is the OpenAPI version of the documents built by New.
+const Version = "3.0.3"
+
+// bearerScheme names the security scheme of routes that need a bearer token.
+const bearerScheme = "bearerAuth"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a base URL of the API.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem holds the operations of one path by lower-case method.
+type PathItem map[string]*Operation
+
+// Components holds the schemas and security schemes operations refer to.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests authenticate.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Operation is one method of a path.
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation takes, by media type.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is one answer of an operation, by media type.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Route documents one operation. Path uses gin's syntax, such as /result/:id; its
+// parameters are documented as required path parameters.
+type Route struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Tag         string
+	// Public routes need no bearer token.
+	Public    bool
+	Query     []Parameter
+	Headers   []Parameter
+	Body      *RequestBody
+	Responses map[int]Response
+}
+
+// New returns an empty document describing info. Operations that are not public
+// authenticate with a bearer JWT.
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+}
+
+// Component registers schema under name and returns a reference to it.
+func (d *Document) Component(name string, schema *Schema) *Schema {
+	d.Components.Schemas[name] = schema
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// pathParam matches the :name and *name segments of gin paths.
+var pathParam = regexp.MustCompile(`[:*]([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Add documents route.
+func (d *Document) Add(route Route) {
+	operation := &Operation{
+		OperationID: operationID(route.Method, route.Path),
+		Summary:     route.Summary,
+		Description: route.Description,
+		Responses:   map[string]Response{},
+		RequestBody: route.Body,
+	}
+	if route.Tag != "" {
+		operation.Tags = []string{route.Tag}
+	}
+	for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+		operation.Parameters = append(operation.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: String()})
+	}
+	for _, param := range route.Query {
+		param.In = "query"
+		operation.Parameters = append(operation.Parameters, param)
+	}
+	for _, param := range route.Headers {
+		param.In = "header"
+		operation.Parameters = append(operation.Parameters, param)
+	}
+	for status, response := range route.Responses {
+		operation.Responses[strconv.Itoa(status)] = response
+	}
+	if !route.Public {
+		operation.Security = []map[string][]string{{bearerScheme: {}}}
+	}
+
+	path := pathParam.ReplaceAllString(route.Path, "{$1}")
+	item := d.Paths[path]
+	if item == nil {
+		item = PathItem{}
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(route.Method)] = operation
+}
+
+// Has reports whether the document describes method on the gin path.
+func (d *Document) Has(method, path string) bool {
+	_, ok := d.Paths[pathParam.ReplaceAllString(path, "{$1}")][strings.ToLower(method)]
+	return ok
+}
+
+// JSON encodes the document, indented, with a trailing newline.
+func (d *Document) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// operationID names an operation after its method and path, e.g. getResultById
+// for GET /result/:id.
+func operationID(method, path string) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '_' || r == '.' }) {
+		if segment[0] == ':' || segment[0] == '*' {
+			id.WriteString("By")
+			segment = segment[1:]
+		}
+		id.WriteString(strings.ToUpper(segment[:1]) + segment[1:])
+	}
+	return id.String()
+}
+
+// JSONBody is a required JSON request body of schema.
+func JSONBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+// JSONResponse is a JSON response of schema.
+func JSONResponse(description string, schema *Schema) Response {
+	return Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+// ContentResponse is a response of one of several media types, each with its own
+// schema; a nil schema leaves the body undescribed.
+func ContentResponse(description string, content map[string]*Schema) Response {
+	response := Response{Description: description, Content: map[string]MediaType{}}
+	for mediaType, schema := range content {
+		response.Content[mediaType] = MediaType{Schema: schema}
+	}
+	return response
+}
+
+// StatusText is a response without a body described by its status.
+func StatusText(status int) Response {
+	return Response{Description: http.StatusText(status)}
+}
@@ -0,0 +1,63 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAddDocumentsGinPathsAndSecurity(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+	doc.Add(Route{Method: http.MethodGet, Path: "/result/:id", Responses: map[int]Response{http.StatusOK: StatusText(http.StatusOK)}})
+	doc.Add(Route{Method: http.MethodGet, Path: "/images/*key", Public: true})
+
+	operation := doc.Paths["/result/{id}"]["get"]
+	if operation == nil || operation.OperationID != "getResultById" {
+		t.Fatalf("expected getResultById at /result/{id}, got %+v", doc.Paths)
+	}
+	if len(operation.Parameters) != 1 || operation.Parameters[0].Name != "id" || operation.Parameters[0].In != "path" || !operation.Parameters[0].Required {
+		t.Fatalf("expected a required id path parameter, got %+v", operation.Parameters)
+	}
+	if len(operation.Security) != 1 || operation.Responses["200"].Description != "OK" {
+		t.Fatalf("expected a bearer token and a 200 response, got %+v", operation)
+	}
+	if public := doc.Paths["/images/{key}"]["get"]; public == nil || public.Security != nil {
+		t.Fatalf("expected /images/{key} without security, got %+v", public)
+	}
+	if !doc.Has(http.MethodGet, "/images/*key") || doc.Has(http.MethodPost, "/result/:id") {
+		t.Fatal("expected Has to match documented methods of gin paths only")
+	}
+}
+
+func TestSchemaOfFollowsJSONTags(t *testing.T) {
+	type base struct {
+		ID string `json:"id"`
+	}
+	type value struct {
+		base
+		Score    float64   `json:"score"`
+		Note     *string   `json:"note,omitempty"`
+		Tags     []string  `json:"tags"`
+		Created  time.Time `json:"created_at"`
+		internal string
+		Skipped  string `json:"-"`
+	}
+
+	schema := SchemaOf(value{})
+	if !reflect.DeepEqual(schema.Required, []string{"id", "score", "tags", "created_at"}) {
+		t.Fatalf("unexpected required fields %v", schema.Required)
+	}
+	if len(schema.Properties) != 5 {
+		t.Fatalf("expected 5 properties, got %v", schema.Properties)
+	}
+	if note := schema.Properties["note"]; note.Type != "string" || !note.Nullable {
+		t.Fatalf("expected a nullable string note, got %+v", note)
+	}
+	if created := schema.Properties["created_at"]; created.Format != "date-time" {
+		t.Fatalf("expected a date-time, got %+v", created)
+	}
+	if tags := schema.Properties["tags"]; tags.Type != "array" || tags.Items.Type != "string" {
+		t.Fatalf("expected an array of strings, got %+v", tags)
+	}
+}
@@ -0,0 +1,147 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of the OpenAPI schema object the documents use.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// String is a string schema.
+func String() *Schema { return &Schema{Type: "string"} }
+
+// Enum is a string schema taking one of values.
+func Enum(values ...string) *Schema { return &Schema{Type: "string", Enum: values} }
+
+// Integer is a 64-bit integer schema.
+func Integer() *Schema { return &Schema{Type: "integer", Format: "int64"} }
+
+// Number is a floating-point schema.
+func Number() *Schema { return &Schema{Type: "number", Format: "double"} }
+
+// Boolean is a boolean schema.
+func Boolean() *Schema { return &Schema{Type: "boolean"} }
+
+// DateTime is an RFC 3339 timestamp schema.
+func DateTime() *Schema { return &Schema{Type: "string", Format: "date-time"} }
+
+// Binary is a schema of raw bytes, such as an uploaded file.
+func Binary() *Schema { return &Schema{Type: "string", Format: "binary"} }
+
+// Any is a schema accepting any value.
+func Any() *Schema { return &Schema{} }
+
+// ArrayOf is an array schema of items.
+func ArrayOf(items *Schema) *Schema { return &Schema{Type: "array", Items: items} }
+
+// MapOf is an object schema whose values follow values.
+func MapOf(values *Schema) *Schema { return &Schema{Type: "object", AdditionalProperties: values} }
+
+// Object is an object schema of properties, of which required are always present.
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+// Describe returns a copy of s carrying description.
+func Describe(s *Schema, description string) *Schema {
+	described := *s
+	described.Description = description
+	return &described
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// SchemaOf derives the schema of the JSON encoding of v's type from its json tags.
+// Fields without omitempty are required; pointers are nullable.
+func SchemaOf(v any) *Schema {
+	return schemaOf(reflect.TypeOf(v))
+}
+
+func schemaOf(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return DateTime()
+	case rawMessageType:
+		return Any()
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaOf(t.Elem())
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return Integer()
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return Number()
+	case reflect.String:
+		return String()
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return ArrayOf(schemaOf(t.Elem()))
+	case reflect.Map:
+		return MapOf(schemaOf(t.Elem()))
+	case reflect.Struct:
+		schema := Object(map[string]*Schema{})
+		addFields(schema, t)
+		return schema
+	default:
+		return Any()
+	}
+}
+
+// addFields adds the encoded fields of the struct type t to schema, flattening
+// embedded structs like encoding/json does.
+func addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && options == "" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = schemaOf(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package openapi
+
+import (
+	"bytes"
+	"html/template"
+)
+
+// swaggerUIVersion pins the Swagger UI release the page loads.
+const swaggerUIVersion = "5.17.14"
+
+// UIContentSecurityPolicy allows the page of UI to load Swagger UI from its CDN and
+// nothing else than the document from the API itself.
+const UIContentSecurityPolicy = "default-src 'none'; script-src 'unsafe-inline' https://cdn.jsdelivr.net; " +
+	"style-src https://cdn.jsdelivr.net; img-src 'self' data: https://cdn.jsdelivr.net; connect-src 'self'; frame-ancestors 'none'"
+
+var uiPage = template.Must(template.New("ui").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@{{.Version}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@{{.Version}}/swagger-ui-bundle.js" crossorigin="anonymous"></script>
+<script>
+window.ui = SwaggerUIBundle({url: {{.SpecURL}}, dom_id: "#swagger-ui", deepLinking: true, persistAuthorization: false});
+</script>
+</body>
+</html>
+`))
+
+// UI returns a Swagger UI page rendering the document served at specURL, which may
+// be relative to the page. Swagger UI itself is loaded from the jsDelivr CDN.
+func UI(title, specURL string) []byte {
+	var page bytes.Buffer
+	if err := uiPage.Execute(&page, struct{ Title, Version, SpecURL string }{title, swaggerUIVersion, specURL}); err != nil {
+		panic(err) // the template and its data are fixed
+	}
+	return page.Bytes()
+}
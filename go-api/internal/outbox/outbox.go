@@ -0,0 +1,136 @@
+// Package outbox relays the events saved in the transactional outbox to the event
+// publishers. Events are saved in the database transaction of the verification they
+// announce, so a Redis or broker outage delays them but never loses them.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+// Options tunes the relay.
+type Options struct {
+	// Interval is how often the outbox is polled while it is empty.
+	Interval time.Duration
+	// BatchSize is how many events one poll claims at most.
+	BatchSize int
+	// Lease hides claimed events from other relays while they are published.
+	Lease time.Duration
+	// MaxBackoff caps the wait before an event that failed to publish is retried.
+	MaxBackoff time.Duration
+	// Retention is how long published events are kept.
+	Retention time.Duration
+}
+
+// DefaultOptions returns the tunables used by NewRelay.
+func DefaultOptions() Options {
+	return Options{
+		Interval:   time.Second,
+		BatchSize:  100,
+		Lease:      30 * time.Second,
+		MaxBackoff: 5 * time.Minute,
+		Retention:  24 * time.Hour,
+	}
+}
+
+// purgeInterval is how often published events past the retention are deleted.
+const purgeInterval = time.Minute
+
+// Relay publishes the events of the outbox. Every instance may run one: claimed
+// events are hidden from the others. An event is marked published only once every
+// publisher took it, and retried otherwise, so publishers may see it more than once.
+type Relay struct {
+	repo      *repository.OutboxRepository
+	publisher usecase.EventPublisher
+	logger    *zap.Logger
+	opts      Options
+}
+
+// NewRelay returns a relay with DefaultOptions.
+func NewRelay(repo *repository.OutboxRepository, publisher usecase.EventPublisher, logger *zap.Logger) *Relay {
+	return NewRelayWithOptions(repo, publisher, logger, DefaultOptions())
+}
+
+// NewRelayWithOptions returns a relay with explicit tunables.
+func NewRelayWithOptions(repo *repository.OutboxRepository, publisher usecase.EventPublisher, logger *zap.Logger, opts Options) *Relay {
+	return &Relay{repo: repo, publisher: publisher, logger: logger.Named("outbox"), opts: opts}
+}
+
+// Run relays events until ctx is done. A full batch is followed by the next one
+// right away.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+	for {
+		relayed, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("failed to relay events", zap.Error(err))
+		}
+		if time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			if purged, err := r.repo.PurgePublished(ctx, time.Now().Add(-r.opts.Retention)); err != nil && ctx.Err() == nil {
+				r.logger.Error("failed to purge published events", zap.Error(err))
+			} else if purged > 0 {
+				r.logger.Debug("purged published events", zap.Int64("events", purged))
+			}
+		}
+		if relayed == r.opts.BatchSize && err == nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce claims a batch of events and publishes them, returning how many were
+// claimed. Events that fail to publish are retried later with a backoff.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := r.repo.Claim(ctx, r.opts.BatchSize, r.opts.Lease)
+	if err != nil {
+		return 0, err
+	}
+	for _, record := range events {
+		logger := r.logger.With(zap.String("event", record.Type), zap.String("id", record.ID))
+		event, err := usecase.DecodeOutboxEvent(record)
+		if err == nil {
+			err = r.publisher.Publish(ctx, event)
+		}
+		if err != nil {
+			retryAt := time.Now().Add(r.backoff(record.Attempts))
+			logger.Warn("failed to publish event", zap.Int("attempt", record.Attempts+1), zap.Time("retry_at", retryAt), zap.Error(err))
+			if err := r.repo.MarkFailed(ctx, record.ID, err.Error(), retryAt); err != nil {
+				return len(events), err
+			}
+			continue
+		}
+		if err := r.repo.MarkPublished(ctx, record.ID); err != nil {
+			return len(events), err
+		}
+		logger.Debug("published event")
+	}
+	return len(events), nil
+}
+
+// backoff doubles from one second with each failed attempt, up to MaxBackoff.
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := time.Second
+	for i := 0; i < attempts && delay < r.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.opts.MaxBackoff {
+		delay = r.opts.MaxBackoff
+	}
+	return delay
+}
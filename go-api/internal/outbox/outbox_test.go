@@ -0,0 +1,102 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+type flakyPublisher struct {
+	events []usecase.Event
+	fail   bool
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event usecase.Event) error {
+	if p.fail {
+		return errors.New("broker unavailable")
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func TestRelayPublishesEventsSavedWithTheirLog(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	logs := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := logs.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	repo := repository.NewOutboxRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+
+	record, err := usecase.NewOutboxEvent(usecase.Event{
+		ID:        "event-1",
+		Type:      usecase.EventVerificationCompleted,
+		UserID:    "user-1",
+		CreatedAt: time.Now().UTC(),
+		Data:      usecase.VerificationEvent{RequestID: "req-1", Verified: true, Score: 0.9},
+	})
+	if err != nil {
+		t.Fatalf("NewOutboxEvent returned error: %v", err)
+	}
+	log := &repository.VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash-1", Outbox: []*repository.OutboxEvent{record}}
+	if err := logs.SaveLog(ctx, log); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	// A log that is not saved does not leave its events behind.
+	duplicate := &repository.VerificationLog{RequestID: "req-2", UserID: "user-1", SHA1Hash: "hash-1", Outbox: []*repository.OutboxEvent{{ID: "event-2", Type: usecase.EventVerificationCompleted}}}
+	if err := logs.SaveLog(ctx, duplicate); err == nil {
+		t.Fatal("expected the duplicate log to be refused")
+	}
+
+	publisher := &flakyPublisher{fail: true}
+	opts := DefaultOptions()
+	opts.MaxBackoff = time.Millisecond
+	relay := NewRelayWithOptions(repo, publisher, zap.NewNop(), opts)
+	if claimed, err := relay.RelayOnce(ctx); err != nil || claimed != 1 {
+		t.Fatalf("expected one event to be claimed, got %d (%v)", claimed, err)
+	}
+
+	// The failed event is retried after the backoff, and another relay cannot claim
+	// it while it is leased.
+	time.Sleep(5 * time.Millisecond)
+	publisher.fail = false
+	if claimed, err := repo.Claim(ctx, 10, time.Minute); err != nil || len(claimed) != 1 || claimed[0].Attempts != 1 {
+		t.Fatalf("expected the failed event to be claimable again, got %+v (%v)", claimed, err)
+	}
+	if claimed, err := relay.RelayOnce(ctx); err != nil || claimed != 0 {
+		t.Fatalf("expected a leased event not to be claimed twice, got %d (%v)", claimed, err)
+	}
+	if err := db.Model(&repository.OutboxEvent{}).Where("id = ?", "event-1").Update("available_at", time.Now().UTC()).Error; err != nil {
+		t.Fatalf("failed to end the lease: %v", err)
+	}
+	if claimed, err := relay.RelayOnce(ctx); err != nil || claimed != 1 {
+		t.Fatalf("expected the event to be relayed, got %d (%v)", claimed, err)
+	}
+	if len(publisher.events) != 1 {
+		t.Fatalf("expected one published event, got %+v", publisher.events)
+	}
+	data, ok := publisher.events[0].Data.(usecase.VerificationEvent)
+	if event := publisher.events[0]; event.ID != "event-1" || event.UserID != "user-1" || !ok || data.RequestID != "req-1" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if claimed, err := relay.RelayOnce(ctx); err != nil || claimed != 0 {
+		t.Fatalf("expected a published event not to be relayed again, got %d (%v)", claimed, err)
+	}
+
+	if purged, err := repo.PurgePublished(ctx, time.Now().Add(time.Second)); err != nil || purged != 1 {
+		t.Fatalf("expected the published event to be purged, got %d (%v)", purged, err)
+	}
+}
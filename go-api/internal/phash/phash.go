@@ -0,0 +1,158 @@
+// Package phash computes perceptual hashes of images: 64-bit fingerprints that stay
+// close when an image is resized, recompressed or slightly edited, unlike the SHA-1
+// of its bytes. The hash is a difference hash (dHash): the image is shrunk to 9x8
+// grayscale pixels and each bit records whether a pixel is brighter than its right
+// neighbour.
+package phash
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+	"strconv"
+
+	// Register the decoders of the upload types the standard library supports.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Bits is the length of a hash, and so the largest possible distance.
+const Bits = 64
+
+const (
+	width  = 9
+	height = 8
+)
+
+// ErrUnsupportedImage is returned for images that cannot be decoded, such as WebP.
+var ErrUnsupportedImage = errors.New("phash: unsupported image format")
+
+// Compute returns the hash of an encoded JPEG, PNG or GIF image.
+func Compute(data []byte) (uint64, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	return Hash(img), nil
+}
+
+// Stream hashes an image as it is written, so callers that read the image once for
+// another purpose need not keep it. Create one with NewStream, write the image, then
+// call Sum; Close releases a stream whose image is abandoned.
+type Stream struct {
+	writer *io.PipeWriter
+	done   chan struct{}
+	hash   uint64
+	err    error
+}
+
+// NewStream starts decoding the image written to the returned stream.
+func NewStream() *Stream {
+	reader, writer := io.Pipe()
+	s := &Stream{writer: writer, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		img, _, err := image.Decode(reader)
+		if err != nil {
+			s.err = fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+		} else {
+			s.hash = Hash(img)
+		}
+		// Keep accepting writes after the decoder stopped, so writers never block.
+		_, _ = io.Copy(io.Discard, reader)
+	}()
+	return s
+}
+
+// Write implements io.Writer. It never fails.
+func (s *Stream) Write(p []byte) (int, error) {
+	// Writes only fail once the stream is closed, when the image is no longer wanted.
+	_, _ = s.writer.Write(p)
+	return len(p), nil
+}
+
+// Sum ends the image and returns its hash, or ErrUnsupportedImage when it could not
+// be decoded.
+func (s *Stream) Sum() (uint64, error) {
+	s.Close()
+	return s.hash, s.err
+}
+
+// Close ends the image and waits for the decoder. It is safe to call more than once.
+func (s *Stream) Close() error {
+	_ = s.writer.Close()
+	<-s.done
+	return nil
+}
+
+// Hash returns the hash of a decoded image.
+func Hash(img image.Image) uint64 {
+	gray := shrink(img)
+	var hash uint64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// shrink averages the luminance of the image over a width x height grid.
+func shrink(img image.Image) [height][width]float64 {
+	var grid [height][width]float64
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return grid
+	}
+	for gy := 0; gy < height; gy++ {
+		y0, y1 := bounds.Min.Y+gy*h/height, bounds.Min.Y+(gy+1)*h/height
+		if y1 == y0 {
+			y1++
+		}
+		for gx := 0; gx < width; gx++ {
+			x0, x1 := bounds.Min.X+gx*w/width, bounds.Min.X+(gx+1)*w/width
+			if x1 == x0 {
+				x1++
+			}
+			var sum, n float64
+			for y := y0; y < y1 && y < bounds.Max.Y; y++ {
+				for x := x0; x < x1 && x < bounds.Max.X; x++ {
+					r, g, b, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					n++
+				}
+			}
+			if n > 0 {
+				grid[gy][gx] = sum / n
+			}
+		}
+	}
+	return grid
+}
+
+// Distance returns the number of differing bits of two hashes, from 0 for
+// near-identical images to Bits.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Format encodes a hash as 16 hexadecimal digits.
+func Format(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// Parse decodes a hash encoded by Format.
+func Parse(s string) (uint64, error) {
+	if len(s) != 16 {
+		return 0, fmt.Errorf("phash: invalid hash %q", s)
+	}
+	return strconv.ParseUint(s, 16, 64)
+}
@@ -0,0 +1,106 @@
+package phash
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// pattern draws a picture whose brightness depends on the position, scaled to
+// width x height, so resized copies look alike.
+func pattern(width, height int, invert bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx, fy := float64(x)/float64(width), float64(y)/float64(height)
+			v := uint8(255 * (fx*fx + fy) / 2)
+			if (x*8/width+y*8/height)%3 == 0 {
+				v /= 2
+			}
+			if invert {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode returned error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSimilarImagesHaveCloseHashes(t *testing.T) {
+	original, err := Compute(encodePNG(t, pattern(320, 240, false)))
+	if err != nil {
+		t.Fatalf("Compute returned error: %v", err)
+	}
+
+	var recompressed bytes.Buffer
+	if err := jpeg.Encode(&recompressed, pattern(160, 120, false), &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatalf("jpeg.Encode returned error: %v", err)
+	}
+	resized, err := Compute(recompressed.Bytes())
+	if err != nil {
+		t.Fatalf("Compute returned error: %v", err)
+	}
+	if distance := Distance(original, resized); distance > 6 {
+		t.Fatalf("expected a resized JPEG copy to stay close, got distance %d", distance)
+	}
+
+	different := Hash(pattern(320, 240, true))
+	if distance := Distance(original, different); distance < 20 {
+		t.Fatalf("expected a different image to be far, got distance %d", distance)
+	}
+
+	// Images smaller than the hash grid still hash.
+	Hash(pattern(3, 2, false))
+}
+
+func TestFormatRoundTripsAndRejectsUnsupportedImages(t *testing.T) {
+	for _, hash := range []uint64{0, 1, 0xdeadbeefcafef00d, ^uint64(0)} {
+		parsed, err := Parse(Format(hash))
+		if err != nil || parsed != hash {
+			t.Fatalf("Parse(Format(%x)) = %x, %v", hash, parsed, err)
+		}
+	}
+	if _, err := Parse("abc"); err == nil {
+		t.Fatal("expected a short hash to be rejected")
+	}
+	if _, err := Compute([]byte("RIFF....WEBPVP8 ")); !errors.Is(err, ErrUnsupportedImage) {
+		t.Fatalf("expected ErrUnsupportedImage, got %v", err)
+	}
+}
+
+func TestStreamMatchesCompute(t *testing.T) {
+	data := encodePNG(t, pattern(320, 240, false))
+	want, err := Compute(data)
+	if err != nil {
+		t.Fatalf("Compute returned error: %v", err)
+	}
+	stream := NewStream()
+	for offset := 0; offset < len(data); offset += 1000 {
+		stream.Write(data[offset:min(offset+1000, len(data))])
+	}
+	if got, err := stream.Sum(); err != nil || got != want {
+		t.Fatalf("expected %x from the stream, got %x (%v)", want, got, err)
+	}
+
+	// Writers are never blocked by an image the decoder gave up on.
+	stream = NewStream()
+	stream.Write([]byte("not an image"))
+	stream.Write(make([]byte, 1<<20))
+	if _, err := stream.Sum(); !errors.Is(err, ErrUnsupportedImage) {
+		t.Fatalf("expected ErrUnsupportedImage, got %v", err)
+	}
+	stream.Close()
+}
@@ -0,0 +1,158 @@
+// Package preprocess prepares images before they are sent to the image processor. It
+// turns images upright according to their EXIF orientation, scales down those larger
+// than the processor accepts, strips their metadata and re-encodes them in the
+// format the processor expects. JPEG, PNG and GIF images are supported; other
+// images, such as WebP, are passed on unchanged.
+package preprocess
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+
+	"github.com/example/ai-check/internal/imagemeta"
+)
+
+// Formats images can be re-encoded in.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// Options configures a Pipeline.
+type Options struct {
+	// MaxWidth and MaxHeight scale down larger images, keeping their aspect ratio.
+	// Zero leaves that dimension unbounded.
+	MaxWidth  int
+	MaxHeight int
+	// AutoRotate turns images upright according to their EXIF orientation.
+	AutoRotate bool
+	// StripMetadata removes EXIF, XMP and IPTC blocks, comments and text chunks.
+	// Images that are not otherwise changed keep their pixels as they were.
+	StripMetadata bool
+	// Format re-encodes images in FormatJPEG or FormatPNG. Empty keeps the format of
+	// JPEG and PNG images, and encodes GIF images that must be changed as PNG.
+	Format string
+	// JPEGQuality is the quality of re-encoded JPEG images, from 1 to 100.
+	JPEGQuality int
+}
+
+// DefaultOptions returns options that bound images to 4096 pixels on each side, turn
+// them upright and strip their metadata, keeping their format.
+func DefaultOptions() Options {
+	return Options{MaxWidth: 4096, MaxHeight: 4096, AutoRotate: true, StripMetadata: true, JPEGQuality: 90}
+}
+
+// Pipeline applies Options to images. It is safe for concurrent use.
+type Pipeline struct {
+	opts Options
+}
+
+// New returns a pipeline applying opts.
+func New(opts Options) (*Pipeline, error) {
+	if opts.Format != "" && opts.Format != FormatJPEG && opts.Format != FormatPNG {
+		return nil, fmt.Errorf("unsupported image format %q", opts.Format)
+	}
+	if opts.MaxWidth < 0 || opts.MaxHeight < 0 {
+		return nil, errors.New("maximum dimensions must not be negative")
+	}
+	if opts.JPEGQuality == 0 {
+		opts.JPEGQuality = jpeg.DefaultQuality
+	}
+	if opts.JPEGQuality < 1 || opts.JPEGQuality > 100 {
+		return nil, fmt.Errorf("JPEG quality %d is not between 1 and 100", opts.JPEGQuality)
+	}
+	return &Pipeline{opts: opts}, nil
+}
+
+// Apply returns data prepared for the processor, or data itself when there is
+// nothing to change or it is not an image the pipeline can decode. Images are only
+// decoded when they must be turned, scaled or converted; re-encoded images never
+// keep their metadata.
+func (p *Pipeline) Apply(data []byte) ([]byte, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return data, nil
+	}
+	orientation := 1
+	if p.opts.AutoRotate {
+		orientation = imagemeta.Orientation(data)
+	}
+	width, height := config.Width, config.Height
+	if orientation >= 5 {
+		// These orientations swap the sides of the stored image.
+		width, height = height, width
+	}
+	targetWidth, targetHeight := fit(width, height, p.opts.MaxWidth, p.opts.MaxHeight)
+	target := p.opts.Format
+	if target == "" {
+		target = format
+		if format != FormatJPEG && format != FormatPNG {
+			target = FormatPNG
+		}
+	}
+	if orientation == 1 && targetWidth == width && targetHeight == height && (p.opts.Format == "" || target == format) {
+		if p.opts.StripMetadata {
+			return stripMetadata(data), nil
+		}
+		return data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		// The processor rejects images that cannot be decoded with a clearer reason.
+		return data, nil
+	}
+	if orientation >= 5 {
+		img = scale(img, targetHeight, targetWidth)
+	} else {
+		img = scale(img, targetWidth, targetHeight)
+	}
+	img = orient(img, orientation)
+
+	var out bytes.Buffer
+	switch target {
+	case FormatJPEG:
+		err = jpeg.Encode(&out, flatten(img), &jpeg.Options{Quality: p.opts.JPEGQuality})
+	default:
+		err = png.Encode(&out, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode %s image: %w", target, err)
+	}
+	return out.Bytes(), nil
+}
+
+// fit returns the largest dimensions within maxWidth and maxHeight with the aspect
+// ratio of width and height, or width and height when they fit already.
+func fit(width, height, maxWidth, maxHeight int) (int, int) {
+	ratio := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		ratio = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight {
+		ratio = min(ratio, float64(maxHeight)/float64(height))
+	}
+	if ratio == 1 {
+		return width, height
+	}
+	return max(1, int(float64(width)*ratio+0.5)), max(1, int(float64(height)*ratio+0.5))
+}
+
+// flatten draws img over white when it has transparent pixels, which JPEG cannot
+// store and would otherwise turn black.
+func flatten(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return flat
+}
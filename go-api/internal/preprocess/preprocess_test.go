@@ -0,0 +1,205 @@
+package preprocess
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/example/ai-check/internal/imagemeta"
+)
+
+// halves returns an image whose left half is red and right half blue.
+func halves(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+// withSegments inserts JPEG segments after the SOI marker of img.
+func withSegments(t *testing.T, img image.Image, segments ...[]byte) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	out := []byte{0xFF, 0xD8}
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, encoded.Bytes()[2:]...)
+}
+
+func segment(marker byte, payload []byte) []byte {
+	out := []byte{0xFF, marker}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(payload)+2))
+	return append(out, payload...)
+}
+
+// exifOrientation is an APP1 segment recording orientation.
+func exifOrientation(orientation uint16) []byte {
+	tiff := []byte("MM\x00*\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	return segment(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func decode(t *testing.T, data []byte) (image.Image, string) {
+	t.Helper()
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decode the prepared image: %v", err)
+	}
+	return img, format
+}
+
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xC000 && g < 0x4000 && b < 0x4000
+}
+
+func newPipeline(t *testing.T, opts Options) *Pipeline {
+	t.Helper()
+	pipeline, err := New(opts)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	return pipeline
+}
+
+func TestApplyTurnsImagesUpright(t *testing.T) {
+	pipeline := newPipeline(t, DefaultOptions())
+	// Stored 40x20 and shown turned clockwise, so the red left half ends up on top.
+	out, err := pipeline.Apply(withSegments(t, halves(40, 20), exifOrientation(6)))
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	img, format := decode(t, out)
+	if format != FormatJPEG || img.Bounds().Dx() != 20 || img.Bounds().Dy() != 40 {
+		t.Fatalf("expected an upright 20x40 JPEG, got a %dx%d %s", img.Bounds().Dx(), img.Bounds().Dy(), format)
+	}
+	if !isRed(img.At(10, 5)) || isRed(img.At(10, 35)) {
+		t.Fatal("expected the image turned clockwise")
+	}
+	if imagemeta.Orientation(out) != 1 {
+		t.Fatal("expected the re-encoded image to carry no orientation")
+	}
+}
+
+func TestApplyScalesDownLargeImages(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxWidth, opts.MaxHeight = 40, 40
+	pipeline := newPipeline(t, opts)
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, halves(100, 50)); err != nil {
+		t.Fatal(err)
+	}
+	out, err := pipeline.Apply(encoded.Bytes())
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	img, format := decode(t, out)
+	if format != FormatPNG || img.Bounds().Dx() != 40 || img.Bounds().Dy() != 20 {
+		t.Fatalf("expected a 40x20 PNG, got a %dx%d %s", img.Bounds().Dx(), img.Bounds().Dy(), format)
+	}
+	if !isRed(img.At(5, 10)) || isRed(img.At(35, 10)) {
+		t.Fatal("expected the scaled image to keep its halves")
+	}
+
+	small := withSegments(t, halves(20, 10))
+	if out, err := pipeline.Apply(small); err != nil || !bytes.Equal(out, small) {
+		t.Fatalf("expected an image within bounds to be passed on unchanged, got %v", err)
+	}
+}
+
+func TestApplyStripsMetadataWithoutReencoding(t *testing.T) {
+	pipeline := newPipeline(t, DefaultOptions())
+	comment := segment(0xFE, []byte("taken by Alice"))
+	original := withSegments(t, halves(20, 10), exifOrientation(1), comment)
+	out, err := pipeline.Apply(original)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if bytes.Contains(out, []byte("Exif")) || bytes.Contains(out, []byte("Alice")) {
+		t.Fatal("expected the EXIF block and the comment to be removed")
+	}
+	if !bytes.HasSuffix(original, out[len(out)-64:]) || len(original)-len(out) != len(exifOrientation(1))+len(comment) {
+		t.Fatal("expected only the metadata segments to be removed")
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, halves(4, 4)); err != nil {
+		t.Fatal(err)
+	}
+	// Insert a tEXt chunk after IHDR, which ends 33 bytes in.
+	text := []byte("tEXtAuthor\x00Alice")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)-4))
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(text))
+	withText := append(append(append([]byte{}, encoded.Bytes()[:33]...), chunk...), encoded.Bytes()[33:]...)
+	if out, err := pipeline.Apply(withText); err != nil || !bytes.Equal(out, encoded.Bytes()) {
+		t.Fatalf("expected the text chunk to be removed, got %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.StripMetadata = false
+	if out, err := newPipeline(t, opts).Apply(original); err != nil || !bytes.Equal(out, original) {
+		t.Fatalf("expected metadata to be kept, got %v", err)
+	}
+}
+
+func TestApplyConvertsFormats(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Format = FormatJPEG
+	pipeline := newPipeline(t, opts)
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, transparent); err != nil {
+		t.Fatal(err)
+	}
+	out, err := pipeline.Apply(encoded.Bytes())
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	img, format := decode(t, out)
+	if format != FormatJPEG {
+		t.Fatalf("expected a JPEG, got %s", format)
+	}
+	if r, g, b, _ := img.At(4, 4).RGBA(); r < 0xF000 || g < 0xF000 || b < 0xF000 {
+		t.Fatal("expected transparent pixels to turn white")
+	}
+
+	webp := []byte("RIFF\x10\x00\x00\x00WEBPVP8 \x04\x00\x00\x00abcd")
+	if out, err := pipeline.Apply(webp); err != nil || !bytes.Equal(out, webp) {
+		t.Fatalf("expected an image the pipeline cannot decode to be passed on, got %v", err)
+	}
+}
+
+func TestNewValidatesOptions(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"format":  {Format: "webp"},
+		"width":   {MaxWidth: -1},
+		"quality": {JPEGQuality: 101},
+	} {
+		if _, err := New(opts); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+	if _, err := New(Options{}); err != nil {
+		t.Fatalf("expected empty options to be accepted, got %v", err)
+	}
+}
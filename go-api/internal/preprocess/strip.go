@@ -0,0 +1,91 @@
+package preprocess
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// stripMetadata removes the metadata of a JPEG or PNG image without decoding it.
+// Other images, and images whose structure cannot be followed, are returned as is.
+func stripMetadata(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		if stripped, ok := stripJPEG(data); ok {
+			return stripped
+		}
+	case bytes.HasPrefix(data, pngSignature):
+		if stripped, ok := stripPNG(data); ok {
+			return stripped
+		}
+	}
+	return data
+}
+
+// JPEG markers dropped by stripJPEG: APP1 holds EXIF and XMP, APP13 IPTC. APP2 is
+// kept, as it holds the ICC profile the colours depend on.
+const (
+	markerAPP1  = 0xE1
+	markerAPP13 = 0xED
+	markerCOM   = 0xFE
+	markerSOS   = 0xDA
+)
+
+// stripJPEG copies the segments of a JPEG image but its metadata, up to the start of
+// the scan, and the entropy-coded data after it unchanged.
+func stripJPEG(data []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return nil, false
+		}
+		marker := data[offset+1]
+		if marker == 0xFF {
+			// Fill byte before a marker.
+			offset++
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, false
+		}
+		if marker == markerSOS {
+			return append(out, data[offset:]...), true
+		}
+		if marker != markerAPP1 && marker != markerAPP13 && marker != markerCOM {
+			out = append(out, data[offset:end]...)
+		}
+		offset = end
+	}
+	return nil, false
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadata lists the PNG chunks stripPNG drops.
+var pngMetadata = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "eXIf": true, "tIME": true}
+
+// stripPNG copies the chunks of a PNG image but its metadata, up to IEND.
+func stripPNG(data []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	offset := len(pngSignature)
+	for offset+12 <= len(data) {
+		length := uint64(binary.BigEndian.Uint32(data[offset:]))
+		end := uint64(offset) + 12 + length
+		if end > uint64(len(data)) {
+			return nil, false
+		}
+		kind := string(data[offset+4 : offset+8])
+		if !pngMetadata[kind] {
+			out = append(out, data[offset:end]...)
+		}
+		if kind == "IEND" {
+			return out, true
+		}
+		offset = int(end)
+	}
+	return nil, false
+}
@@ -0,0 +1,197 @@
+// Package ratelimit throttles routes per authenticated user and per client address.
+// Limits are token buckets kept in Redis, so every replica draws from the same
+// buckets, and routes are grouped so that, say, every upload route shares one limit.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/httperr"
+)
+
+const keyPrefix = "ai-check:ratelimit:"
+
+// Scopes name the bucket a request was limited by, in the details of 429 answers.
+const (
+	ScopeUser = "user"
+	ScopeIP   = "ip"
+)
+
+// Rate allows Requests per Per on average, and bursts of up to Burst requests. A
+// zero Requests disables the limit.
+type Rate struct {
+	Requests int
+	Per      time.Duration
+	// Burst is the size of the bucket; zero makes it Requests.
+	Burst int
+}
+
+func (r Rate) enabled() bool {
+	return r.Requests > 0 && r.Per > 0
+}
+
+func (r Rate) burst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Requests
+}
+
+// Group is a set of routes sharing the same buckets.
+type Group struct {
+	// Name keys the buckets of the group in Redis.
+	Name string
+	// Routes are keyed by method and route pattern, e.g. "POST /verify".
+	Routes  []string
+	PerUser Rate
+	PerIP   Rate
+}
+
+// Limiter enforces the limits of route groups. It is safe for concurrent use.
+type Limiter struct {
+	redis  *redis.Client
+	logger *zap.Logger
+	// routes maps each limited route to its group. SetGroups replaces it.
+	routes atomic.Pointer[map[string]*Group]
+}
+
+// New returns a limiter of groups keeping its buckets in client.
+func New(client *redis.Client, logger *zap.Logger, groups ...Group) *Limiter {
+	l := &Limiter{
+		redis:  client,
+		logger: logger.Named("ratelimit"),
+	}
+	l.SetGroups(groups...)
+	return l
+}
+
+// SetGroups replaces the groups, e.g. after a configuration reload; no groups
+// limit nothing. Buckets are kept, so the buckets of a group whose rate changed
+// refill at the new rate from the tokens they hold.
+func (l *Limiter) SetGroups(groups ...Group) {
+	routes := make(map[string]*Group)
+	for i := range groups {
+		group := &groups[i]
+		for _, route := range group.Routes {
+			routes[route] = group
+		}
+	}
+	l.routes.Store(&routes)
+}
+
+// takeScript takes a token from each bucket in KEYS, or from none when one of them
+// is empty. Bucket i is refilled at ARGV[2i-1] tokens per millisecond up to ARGV[2i]
+// tokens. Time is read from Redis, so replicas with skewed clocks refill the buckets
+// alike. It returns whether the tokens were taken and otherwise the number of the
+// first empty bucket and how many milliseconds until it has a token.
+var takeScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local tokens = {}
+for i = 1, #KEYS do
+	local rate = tonumber(ARGV[2 * i - 1])
+	local burst = tonumber(ARGV[2 * i])
+	local bucket = redis.call('HMGET', KEYS[i], 'tokens', 'at')
+	local held = tonumber(bucket[1])
+	local at = tonumber(bucket[2])
+	if held == nil or at == nil then
+		held = burst
+		at = now
+	end
+	held = math.min(burst, held + math.max(0, now - at) * rate)
+	if held < 1 then
+		return {0, i, math.ceil((1 - held) / rate)}
+	end
+	tokens[i] = held
+end
+for i = 1, #KEYS do
+	local rate = tonumber(ARGV[2 * i - 1])
+	local burst = tonumber(ARGV[2 * i])
+	redis.call('HSET', KEYS[i], 'tokens', tostring(tokens[i] - 1), 'at', tostring(now))
+	redis.call('PEXPIRE', KEYS[i], math.ceil(burst / rate))
+end
+return {1, 0, 0}
+`)
+
+// Bucket is the token bucket of Key, refilled at Rate.
+type Bucket struct {
+	Key  string
+	Rate Rate
+}
+
+// Take takes a token from every bucket, or from none when one of them is empty. It
+// then returns false, the index of the first empty bucket and how long until that
+// bucket has a token.
+func (l *Limiter) Take(ctx context.Context, buckets ...Bucket) (bool, int, time.Duration, error) {
+	keys := make([]string, len(buckets))
+	args := make([]interface{}, 0, 2*len(buckets))
+	for i, bucket := range buckets {
+		keys[i] = keyPrefix + bucket.Key
+		// The rate is written without an exponent, e.g. for 3 requests a minute,
+		// which not every Lua implementation reads.
+		perMillisecond := float64(bucket.Rate.Requests) / float64(bucket.Rate.Per.Milliseconds())
+		args = append(args, strconv.FormatFloat(perMillisecond, 'f', -1, 64), bucket.Rate.burst())
+	}
+	result, err := takeScript.Run(ctx, l.redis, keys, args...).Int64Slice()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	if result[0] == 1 {
+		return true, 0, 0, nil
+	}
+	return false, int(result[1]) - 1, time.Duration(result[2]) * time.Millisecond, nil
+}
+
+// Middleware answers 429 with a Retry-After header to requests over the limits of
+// their route's group. Mount it after authentication so per-user limits see the
+// user; users are told apart per tenant. A request takes a token from its user's
+// and its address's bucket, or from neither when it is limited. Requests are let
+// through while Redis is unreachable.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, ok := (*l.routes.Load())[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		var buckets []Bucket
+		var scopes []string
+		if userID, ok := auth.GetUserID(ctx); ok && group.PerUser.enabled() {
+			tenantID, _ := auth.GetTenantID(ctx)
+			buckets = append(buckets, Bucket{Key: group.Name + ":" + ScopeUser + ":" + tenantID + ":" + userID, Rate: group.PerUser})
+			scopes = append(scopes, ScopeUser)
+		}
+		if group.PerIP.enabled() {
+			buckets = append(buckets, Bucket{Key: group.Name + ":" + ScopeIP + ":" + c.ClientIP(), Rate: group.PerIP})
+			scopes = append(scopes, ScopeIP)
+		}
+		if len(buckets) > 0 && !l.allow(c, buckets, scopes) {
+			return
+		}
+		c.Next()
+	}
+}
+
+func (l *Limiter) allow(c *gin.Context, buckets []Bucket, scopes []string) bool {
+	allowed, empty, wait, err := l.Take(c.Request.Context(), buckets...)
+	if err != nil {
+		l.logger.Warn("rate limit unavailable, letting the request through", zap.String("key", buckets[0].Key), zap.Error(err))
+		return true
+	}
+	if allowed {
+		return true
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds())))))
+	httperr.WriteWithDetails(c, httperr.CodeRateLimited, "too many requests, retry later", map[string]interface{}{"scope": scopes[empty]})
+	return false
+}
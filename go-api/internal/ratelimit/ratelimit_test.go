@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/auth"
+)
+
+func TestTakeRefillsTheBucketOverTime(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	// The buckets refill by the clock of Redis.
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	server.SetTime(now)
+	limiter := New(client, zap.NewNop())
+	ctx := context.Background()
+	bucket := Bucket{Key: "bucket", Rate: Rate{Requests: 2, Per: time.Second, Burst: 3}}
+
+	for i := 0; i < 3; i++ {
+		if allowed, _, _, err := limiter.Take(ctx, bucket); err != nil || !allowed {
+			t.Fatalf("expected request %d of the burst to be allowed, got %v (%v)", i+1, allowed, err)
+		}
+	}
+	allowed, _, wait, err := limiter.Take(ctx, bucket)
+	if err != nil || allowed || wait != 500*time.Millisecond {
+		t.Fatalf("expected an empty bucket to wait 500ms, got %v, %s (%v)", allowed, wait, err)
+	}
+
+	server.SetTime(now.Add(500 * time.Millisecond))
+	if allowed, _, _, err := limiter.Take(ctx, bucket); err != nil || !allowed {
+		t.Fatalf("expected the refilled token to be allowed, got %v (%v)", allowed, err)
+	}
+	other := Bucket{Key: "other", Rate: bucket.Rate}
+	if allowed, _, _, _ := limiter.Take(ctx, other); !allowed {
+		t.Fatal("expected buckets to be independent")
+	}
+
+	// A token is taken from every bucket or from none.
+	allowed, empty, _, err := limiter.Take(ctx, other, bucket)
+	if err != nil || allowed || empty != 1 {
+		t.Fatalf("expected the second bucket to be empty, got %v, %d (%v)", allowed, empty, err)
+	}
+	for i := 0; i < 2; i++ {
+		if allowed, _, _, _ := limiter.Take(ctx, other); !allowed {
+			t.Fatalf("expected request %d to find the token left in the first bucket", i+1)
+		}
+	}
+}
+
+func TestMiddlewareLimitsUsersAndAddresses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	limiter := New(client, zap.NewNop(), Group{
+		Name:    "verify",
+		Routes:  []string{"POST /verify"},
+		PerUser: Rate{Requests: 1, Per: time.Minute},
+		PerIP:   Rate{Requests: 2, Per: time.Minute},
+	})
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if userID := c.GetHeader("X-User"); userID != "" {
+			c.Request = c.Request.WithContext(auth.WithUserID(c.Request.Context(), userID))
+		}
+		if tenantID := c.GetHeader("X-Tenant"); tenantID != "" {
+			c.Request = c.Request.WithContext(auth.WithTenantID(c.Request.Context(), tenantID))
+		}
+	}, limiter.Middleware())
+	router.POST("/verify", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/result", func(c *gin.Context) { c.Status(http.StatusOK) })
+	send := func(method, path, userID, addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		tenantID, userID, _ := strings.Cut(userID, "/")
+		if userID == "" {
+			tenantID, userID = "", tenantID
+		}
+		req.Header.Set("X-User", userID)
+		req.Header.Set("X-Tenant", tenantID)
+		req.RemoteAddr = addr + ":1234"
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := send(http.MethodPost, "/verify", "user-1", "10.0.0.1"); resp.Code != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d", resp.Code)
+	}
+	resp := send(http.MethodPost, "/verify", "user-1", "10.0.0.2")
+	var body struct {
+		Code    string `json:"code"`
+		Details struct {
+			Scope string `json:"scope"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil || resp.Code != http.StatusTooManyRequests || body.Code != "rate_limited" || body.Details.Scope != ScopeUser {
+		t.Fatalf("expected the user to be limited, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if got := resp.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("expected Retry-After 60, got %q", got)
+	}
+
+	// The address is limited across users.
+	if resp := send(http.MethodPost, "/verify", "user-2", "10.0.0.1"); resp.Code != http.StatusOK {
+		t.Fatalf("expected another user to pass, got %d", resp.Code)
+	}
+	if resp := send(http.MethodPost, "/verify", "user-3", "10.0.0.1"); resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the address to be limited, got %d", resp.Code)
+	}
+	// The limited request took no token from the user.
+	if resp := send(http.MethodPost, "/verify", "user-3", "10.0.0.3"); resp.Code != http.StatusOK {
+		t.Fatalf("expected the user to keep their token, got %d", resp.Code)
+	}
+	// A user of another tenant with the same ID has buckets of their own.
+	if resp := send(http.MethodPost, "/verify", "globex/user-1", "10.0.0.4"); resp.Code != http.StatusOK {
+		t.Fatalf("expected the user of another tenant to pass, got %d", resp.Code)
+	}
+
+	for i := 0; i < 3; i++ {
+		if resp := send(http.MethodGet, "/result", "user-1", "10.0.0.1"); resp.Code != http.StatusOK {
+			t.Fatalf("expected routes outside the groups to pass, got %d", resp.Code)
+		}
+	}
+
+	// Requests pass while Redis is down.
+	server.Close()
+	if resp := send(http.MethodPost, "/verify", "user-1", "10.0.0.1"); resp.Code != http.StatusOK {
+		t.Fatalf("expected requests to pass without Redis, got %d", resp.Code)
+	}
+}
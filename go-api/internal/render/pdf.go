@@ -0,0 +1,222 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/example/ai-check/internal/repository"
+)
+
+// Page geometry of the PDF documents, in points: A4 with 2cm margins.
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 56
+)
+
+// WriteCertificate writes a one-page PDF certificate of the outcome of log, issued
+// at issuedAt.
+func WriteCertificate(w io.Writer, log *repository.VerificationLog, issuedAt time.Time) error {
+	doc := newDocument()
+	doc.heading("Verification certificate")
+	doc.text(fontRegular, 10, "Issued "+issuedAt.UTC().Format(time.RFC3339)+" by ai-check")
+	doc.space()
+
+	verdict := "Not verified"
+	if log.Success {
+		verdict = "Verified"
+	}
+	if status(log) != repository.StatusCompleted {
+		verdict = "Pending (" + status(log) + ")"
+	}
+	doc.field("Verdict", verdict)
+	doc.field("Request ID", log.RequestID)
+	doc.field("User", log.UserID)
+	doc.field("Verified at", log.CreatedAt.UTC().Format(time.RFC3339))
+	if status(log) == repository.StatusCompleted {
+		doc.field("Score", formatScore(log.Score))
+		if log.ModelVersion != "" {
+			doc.field("Model version", log.ModelVersion)
+		}
+	}
+	if log.SHA256Hash != "" {
+		doc.field("Image SHA-256", log.SHA256Hash)
+	} else {
+		doc.field("Image SHA-1", log.SHA1Hash)
+	}
+	if log.Details != "" {
+		doc.field("Details", log.Details)
+	}
+
+	if len(log.Categories) > 0 {
+		doc.space()
+		doc.text(fontBold, 12, "Moderation categories")
+		for _, category := range log.Categories {
+			line := category.Category + ": score " + formatScore(category.Score)
+			if category.Threshold > 0 {
+				line += ", threshold " + formatScore(category.Threshold)
+			}
+			if category.Flagged {
+				line += ", flagged"
+			}
+			doc.text(fontRegular, 10, line)
+		}
+	}
+	return doc.write(w)
+}
+
+// WriteReport writes a PDF listing logs, one line per verification, under title.
+// It runs onto as many pages as the logs need.
+func WriteReport(w io.Writer, title string, logs []*repository.VerificationLog, issuedAt time.Time) error {
+	doc := newDocument()
+	doc.heading(title)
+	doc.text(fontRegular, 10, fmt.Sprintf("%d verifications, issued %s by ai-check", len(logs), issuedAt.UTC().Format(time.RFC3339)))
+	doc.space()
+	for _, log := range logs {
+		outcome := "not verified"
+		if log.Success {
+			outcome = "verified"
+		}
+		if status(log) != repository.StatusCompleted {
+			outcome = status(log)
+		}
+		line := log.CreatedAt.UTC().Format(time.RFC3339) + "  " + log.RequestID + "  " + outcome
+		if status(log) == repository.StatusCompleted {
+			line += "  score " + formatScore(log.Score)
+		}
+		if flagged := flaggedCategories(log); len(flagged) > 0 {
+			line += "  flagged " + strings.Join(flagged, ", ")
+		}
+		doc.text(fontRegular, 9, line)
+	}
+	return doc.write(w)
+}
+
+// The fonts of a document, which are the standard Helvetica faces every PDF reader
+// ships, so nothing is embedded.
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+)
+
+// document lays text out top to bottom, starting a page when one is full.
+type document struct {
+	pages []*bytes.Buffer
+	// y is the baseline of the next line on the last page.
+	y float64
+}
+
+func newDocument() *document {
+	d := &document{}
+	d.newPage()
+	return d
+}
+
+func (d *document) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pageHeight - margin
+}
+
+func (d *document) heading(text string) {
+	d.text(fontBold, 18, text)
+	d.y -= 6
+}
+
+func (d *document) space() {
+	d.y -= 10
+}
+
+// field writes a label and its value, wrapping long values such as hashes.
+func (d *document) field(label, value string) {
+	d.text(fontRegular, 11, label+": "+value)
+}
+
+// text writes a line, wrapped to the width of the page.
+func (d *document) text(font string, size float64, text string) {
+	// Helvetica averages about half an em per character.
+	width := int((pageWidth - 2*margin) / (size * 0.5))
+	for _, line := range wrap(text, width) {
+		leading := size * 1.4
+		if d.y-leading < margin {
+			d.newPage()
+		}
+		d.y -= leading
+		fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %g Tf %d %.1f Td (%s) Tj ET\n", font, size, margin, d.y, escape(line))
+	}
+}
+
+// wrap breaks text into lines of at most width characters, at spaces where it can.
+func wrap(text string, width int) []string {
+	runes := []rune(text)
+	var lines []string
+	for len(runes) > width {
+		cut := width
+		for i := width; i > 0; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		lines = append(lines, strings.TrimRight(string(runes[:cut]), " "))
+		runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+	}
+	return append(lines, string(runes))
+}
+
+// escape encodes text as the body of a PDF literal string in WinAnsiEncoding.
+// Characters outside Latin-1 are replaced with '?'.
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// write serializes the document: the catalog, the page tree, the two fonts, then a
+// page object and its content stream per page, and the cross-reference table.
+func (d *document) write(w io.Writer) error {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, fontRegular, fontBold, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(out.Bytes())
+	return err
+}
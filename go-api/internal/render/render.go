@@ -0,0 +1,145 @@
+// Package render presents verification results in the formats clients download
+// them in: JSON for programs, CSV for spreadsheets and a PDF certificate or report
+// that compliance teams can file as a human-readable proof.
+package render
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/ai-check/internal/repository"
+)
+
+// Format is a representation of verification results.
+type Format string
+
+// The supported formats.
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+	FormatPDF  Format = "pdf"
+)
+
+// ErrUnsupportedFormat is returned by Negotiate for a format parameter naming none
+// of the formats.
+var ErrUnsupportedFormat = errors.New("format must be json, csv or pdf")
+
+var mediaTypes = map[string]Format{
+	"application/json": FormatJSON,
+	"text/csv":         FormatCSV,
+	"application/pdf":  FormatPDF,
+}
+
+// ContentType is the Content-Type header of responses in format.
+func ContentType(format Format) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatPDF:
+		return "application/pdf"
+	}
+	return "application/json; charset=utf-8"
+}
+
+// Negotiate picks the format of a response. A format parameter wins; otherwise the
+// supported media type the Accept header prefers is used, and JSON when it names
+// none, so clients sending */* or nothing keep getting JSON.
+func Negotiate(format, accept string) (Format, error) {
+	if format != "" {
+		switch Format(strings.ToLower(format)) {
+		case FormatJSON, FormatCSV, FormatPDF:
+			return Format(strings.ToLower(format)), nil
+		}
+		return "", ErrUnsupportedFormat
+	}
+
+	best, bestQuality := FormatJSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		candidate, ok := mediaTypes[mediaType]
+		if !ok {
+			continue
+		}
+		quality := 1.0
+		if raw, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		// Ties keep the type listed first.
+		if quality > bestQuality {
+			best, bestQuality = candidate, quality
+		}
+	}
+	return best, nil
+}
+
+// csvHeader names the columns written by WriteCSV.
+var csvHeader = []string{
+	"request_id", "user_id", "status", "verified", "score", "raw_score", "model_version",
+	"flagged_categories", "sha256_hash", "sha1_hash", "details", "created_at",
+}
+
+// WriteCSV writes logs as CSV with a header row, one verification per row. Flagged
+// categories are joined with semicolons.
+func WriteCSV(w io.Writer, logs []*repository.VerificationLog) error {
+	out := csv.NewWriter(w)
+	out.Write(csvHeader)
+	for _, log := range logs {
+		out.Write([]string{
+			cell(log.RequestID),
+			cell(log.UserID),
+			status(log),
+			strconv.FormatBool(log.Success),
+			formatScore(log.Score),
+			formatScore(log.RawScore),
+			cell(log.ModelVersion),
+			cell(strings.Join(flaggedCategories(log), ";")),
+			log.SHA256Hash,
+			log.SHA1Hash,
+			cell(log.Details),
+			log.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// cell keeps spreadsheets from evaluating values that start like a formula.
+func cell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func status(log *repository.VerificationLog) string {
+	if log.Status == "" {
+		return repository.StatusCompleted
+	}
+	return log.Status
+}
+
+func formatScore(score float32) string {
+	return strconv.FormatFloat(float64(score), 'f', 4, 32)
+}
+
+func flaggedCategories(log *repository.VerificationLog) []string {
+	var flagged []string
+	for _, category := range log.Categories {
+		if category.Flagged {
+			flagged = append(flagged, category.Category)
+		}
+	}
+	sort.Strings(flagged)
+	return flagged
+}
@@ -0,0 +1,106 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/ai-check/internal/repository"
+)
+
+func TestNegotiatePrefersTheFormatParameter(t *testing.T) {
+	for _, tc := range []struct {
+		format, accept string
+		want           Format
+	}{
+		{"", "", FormatJSON},
+		{"", "*/*", FormatJSON},
+		{"", "text/csv", FormatCSV},
+		{"", "application/pdf;q=0.9, text/csv;q=0.5", FormatPDF},
+		{"", "text/csv;q=0.2, application/json", FormatJSON},
+		{"", "image/png", FormatJSON},
+		{"PDF", "text/csv", FormatPDF},
+	} {
+		got, err := Negotiate(tc.format, tc.accept)
+		if err != nil || got != tc.want {
+			t.Errorf("Negotiate(%q, %q) = %q, %v; want %q", tc.format, tc.accept, got, err, tc.want)
+		}
+	}
+	if _, err := Negotiate("xml", ""); err != ErrUnsupportedFormat {
+		t.Fatalf("expected an unknown format to be rejected, got %v", err)
+	}
+}
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	log := &repository.VerificationLog{
+		RequestID: "req-1", UserID: "user-1", Success: true, Score: 0.9,
+		Details:    "=HYPERLINK(\"x\")",
+		Categories: []repository.VerificationCategory{{Category: "violence", Flagged: true}, {Category: "adult", Flagged: true}, {Category: "spam"}},
+		CreatedAt:  time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+	var out bytes.Buffer
+	if err := WriteCSV(&out, []*repository.VerificationLog{log}); err != nil {
+		t.Fatalf("WriteCSV returned error: %v", err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("expected a header and a row, got %v (%v)", rows, err)
+	}
+	want := []string{"req-1", "user-1", "completed", "true", "0.9000", "0.0000", "", "adult;violence", "", "", `'=HYPERLINK("x")`, "2024-05-01T10:00:00Z"}
+	if strings.Join(rows[1], "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected row %q", rows[1])
+	}
+}
+
+func TestPDFDocumentsAreWellFormed(t *testing.T) {
+	log := &repository.VerificationLog{RequestID: "req-(1)", UserID: "usér", Success: true, Score: 0.9, SHA256Hash: strings.Repeat("ab", 32)}
+	var certificate bytes.Buffer
+	if err := WriteCertificate(&certificate, log, time.Now()); err != nil {
+		t.Fatalf("WriteCertificate returned error: %v", err)
+	}
+	if pages := checkPDF(t, certificate.Bytes()); pages != 1 {
+		t.Fatalf("expected a one-page certificate, got %d pages", pages)
+	}
+	if !bytes.Contains(certificate.Bytes(), []byte(`(Request ID: req-\(1\))`)) || !bytes.Contains(certificate.Bytes(), []byte(`(User: us\351r)`)) {
+		t.Fatalf("expected escaped text in the certificate:\n%s", certificate.String())
+	}
+
+	logs := make([]*repository.VerificationLog, 200)
+	for i := range logs {
+		logs[i] = log
+	}
+	var report bytes.Buffer
+	if err := WriteReport(&report, "History", logs, time.Now()); err != nil {
+		t.Fatalf("WriteReport returned error: %v", err)
+	}
+	if pages := checkPDF(t, report.Bytes()); pages < 2 {
+		t.Fatalf("expected the report to run onto several pages, got %d", pages)
+	}
+}
+
+// checkPDF verifies that the cross-reference table points at the objects and
+// returns the page count.
+func checkPDF(t *testing.T, pdf []byte) int {
+	t.Helper()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%EOF\n")) {
+		t.Fatal("expected a PDF header and trailer")
+	}
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	for i, entry := range regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1) {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := strconv.Itoa(i+1) + " 0 obj"; !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Fatalf("xref entry %d points at %q", i+1, pdf[offset:offset+10])
+		}
+	}
+	count := regexp.MustCompile(`/Count (\d+)`).FindSubmatch(pdf)
+	pages, _ := strconv.Atoi(string(count[1]))
+	return pages
+}
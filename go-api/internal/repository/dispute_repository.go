@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+// Dispute is a user's objection to the verdict of one of their verifications. It
+// references the verification by request ID without a foreign key, so purging old
+// logs does not depend on their disputes.
+type Dispute struct {
+	ID        uint   `gorm:"primaryKey"`
+	RequestID string `gorm:"column:request_id;size:64;uniqueIndex"`
+	UserID    string `gorm:"column:user_id;size:64;index"`
+	Reason    string `gorm:"column:reason;type:text"`
+	State     string `gorm:"column:state;size:16;index"`
+	// ReviewerNote is the operator's explanation of the resolution.
+	ReviewerNote string     `gorm:"column:reviewer_note;type:text"`
+	CreatedAt    time.Time  `gorm:"column:created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at"`
+	ResolvedAt   *time.Time `gorm:"column:resolved_at"`
+}
+
+// TableName overrides the default table name.
+func (Dispute) TableName() string {
+	return "disputes"
+}
+
+// DisputeRepository persists disputes.
+type DisputeRepository struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+// NewDisputeRepository creates a new repository instance.
+func NewDisputeRepository(db *gorm.DB, logger *zap.Logger) *DisputeRepository {
+	return &DisputeRepository{db: db, logger: logger.Named("dispute_repository")}
+}
+
+// AutoMigrate ensures the schema is available.
+func (r *DisputeRepository) AutoMigrate(ctx context.Context) error {
+	err := r.db.WithContext(ctx).AutoMigrate(&Dispute{})
+	return logging.NewOperationError("repository.disputes.automigrate", "", err)
+}
+
+// Create inserts a dispute.
+func (r *DisputeRepository) Create(ctx context.Context, dispute *Dispute) error {
+	err := r.db.WithContext(ctx).Create(dispute).Error
+	return logging.NewOperationError("repository.disputes.create", dispute.RequestID, err)
+}
+
+// Save updates the state, note and resolution time of a dispute.
+func (r *DisputeRepository) Save(ctx context.Context, dispute *Dispute) error {
+	dispute.UpdatedAt = time.Now().UTC()
+	err := r.db.WithContext(ctx).Model(dispute).Select("state", "reviewer_note", "resolved_at", "updated_at").Updates(dispute).Error
+	return logging.NewOperationError("repository.disputes.save", dispute.RequestID, err)
+}
+
+// FindByID returns a dispute, or gorm.ErrRecordNotFound.
+func (r *DisputeRepository) FindByID(ctx context.Context, id uint) (*Dispute, error) {
+	var dispute Dispute
+	if err := r.db.WithContext(ctx).First(&dispute, id).Error; err != nil {
+		return nil, logging.NewOperationError("repository.disputes.find_by_id", "", err)
+	}
+	return &dispute, nil
+}
+
+// FindByRequestID returns the dispute of a verification, or gorm.ErrRecordNotFound.
+func (r *DisputeRepository) FindByRequestID(ctx context.Context, requestID string) (*Dispute, error) {
+	var dispute Dispute
+	if err := r.db.WithContext(ctx).Where("request_id = ?", requestID).Take(&dispute).Error; err != nil {
+		return nil, logging.NewOperationError("repository.disputes.find_by_request_id", requestID, err)
+	}
+	return &dispute, nil
+}
+
+// List returns up to limit disputes in one of states with an ID above afterID, in ID
+// order, so the oldest disputes come first.
+func (r *DisputeRepository) List(ctx context.Context, states []string, afterID uint, limit int) ([]*Dispute, error) {
+	var disputes []*Dispute
+	err := r.db.WithContext(ctx).Where("state IN ? AND id > ?", states, afterID).Order("id").Limit(limit).Find(&disputes).Error
+	if err != nil {
+		return nil, logging.NewOperationError("repository.disputes.list", "", err)
+	}
+	return disputes, nil
+}
+
+// FindLogs returns the verification logs of requestIDs with their categories, keyed
+// by request ID. Purged logs are absent.
+func (r *DisputeRepository) FindLogs(ctx context.Context, requestIDs []string) (map[string]*VerificationLog, error) {
+	var logs []*VerificationLog
+	if len(requestIDs) > 0 {
+		err := r.db.WithContext(ctx).Preload("Categories", func(db *gorm.DB) *gorm.DB {
+			return db.Order("category")
+		}).Where("request_id IN ?", requestIDs).Find(&logs).Error
+		if err != nil {
+			return nil, logging.NewOperationError("repository.disputes.find_logs", "", err)
+		}
+	}
+	byRequestID := make(map[string]*VerificationLog, len(logs))
+	for _, log := range logs {
+		byRequestID[log.RequestID] = log
+	}
+	return byRequestID, nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SoftDeleteUserLogs erases up to limit logs of userID in the caller's tenant and
+// returns how many it erased. The batch is passed to release first, e.g. to drop
+// cached results; when it fails, the batch is kept and the error returned. Erased
+// logs leave the metrics counters and every query. Their hashes are cleared, and
+// the SHA-1 replaced by a placeholder unique to the log, so they stop matching
+// duplicates and the user can verify the same images again. PurgeUser deletes them
+// for good.
+func (r *VerificationRepository) SoftDeleteUserLogs(ctx context.Context, userID string, limit int, release func([]*VerificationLog) error) (int, error) {
+	var erased int
+	err := r.executeWithRetry(ctx, "repository.soft_delete_user_logs", "", func() error {
+		return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var logs []*VerificationLog
+			erased = 0
+			if err := tx.Scopes(tenantScope(ctx)).Where("user_id = ?", userID).Order("id").Limit(limit).Find(&logs).Error; err != nil || len(logs) == 0 {
+				return err
+			}
+			if release != nil {
+				if err := release(logs); err != nil {
+					return err
+				}
+			}
+			ids := logIDs(logs)
+			if err := subtractLogs(tx, ids); err != nil {
+				return err
+			}
+			result := tx.Model(&VerificationLog{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+				"deleted_at":      time.Now().UTC(),
+				"sha1_hash":       gorm.Expr("'erased:' || id"),
+				"sha256_hash":     "",
+				"perceptual_hash": "",
+			})
+			erased = int(result.RowsAffected)
+			return result.Error
+		})
+	})
+	return erased, err
+}
+
+// ErasedUsers returns up to limit users, in order, with logs erased before cutoff.
+func (r *VerificationRepository) ErasedUsers(ctx context.Context, cutoff time.Time, limit int) ([]string, error) {
+	var userIDs []string
+	err := r.executeWithRetry(ctx, "repository.erased_users", "", func() error {
+		return r.db.WithContext(ctx).Unscoped().Model(&VerificationLog{}).
+			Where("deleted_at < ?", cutoff).
+			Distinct("user_id").
+			Order("user_id").
+			Limit(limit).
+			Pluck("user_id", &userIDs).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+// PurgeUser deletes the logs of userID erased before cutoff, with their category
+// outcomes, in batches and returns the number of logs deleted. Each batch is passed
+// to release first, e.g. to delete the stored images; when it fails, the batch is
+// kept and the error returned. release may be nil.
+func (r *VerificationRepository) PurgeUser(ctx context.Context, userID string, cutoff time.Time, batchSize int, release func([]*VerificationLog) error) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	var total int64
+	for {
+		var purged int64
+		err := r.executeWithRetry(ctx, "repository.purge_user", "", func() error {
+			var logs []*VerificationLog
+			err := r.db.WithContext(ctx).Unscoped().
+				Where("user_id = ? AND deleted_at < ?", userID, cutoff).
+				Order("id").
+				Limit(batchSize).
+				Find(&logs).Error
+			if err != nil || len(logs) == 0 {
+				purged = 0
+				return err
+			}
+			if release != nil {
+				if err := release(logs); err != nil {
+					return err
+				}
+			}
+			ids := logIDs(logs)
+			return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if err := tx.Where("verification_log_id IN ?", ids).Delete(&VerificationCategory{}).Error; err != nil {
+					return err
+				}
+				result := tx.Unscoped().Where("id IN ?", ids).Delete(&VerificationLog{})
+				purged = result.RowsAffected
+				return result.Error
+			})
+		})
+		if err != nil {
+			return total, err
+		}
+		total += purged
+		if purged < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+func logIDs(logs []*VerificationLog) []uint {
+	ids := make([]uint, len(logs))
+	for i, log := range logs {
+		ids[i] = log.ID
+	}
+	return ids
+}
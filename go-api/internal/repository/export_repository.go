@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+// ExportCheckpoint records how far verification logs were copied to a destination.
+type ExportCheckpoint struct {
+	Name string `gorm:"primaryKey;column:name;size:128"`
+	// LastID is the ID of the last exported verification log.
+	LastID    uint      `gorm:"column:last_id"`
+	Exported  int64     `gorm:"column:exported"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+// TableName overrides the default table name.
+func (ExportCheckpoint) TableName() string {
+	return "export_checkpoints"
+}
+
+// ExportRepository persists export checkpoints.
+type ExportRepository struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+// NewExportRepository creates a new repository instance.
+func NewExportRepository(db *gorm.DB, logger *zap.Logger) *ExportRepository {
+	return &ExportRepository{db: db, logger: logger.Named("export_repository")}
+}
+
+// AutoMigrate ensures the schema is available.
+func (r *ExportRepository) AutoMigrate(ctx context.Context) error {
+	err := r.db.WithContext(ctx).AutoMigrate(&ExportCheckpoint{})
+	return logging.NewOperationError("repository.exports.automigrate", "", err)
+}
+
+// Checkpoint returns the checkpoint of name, or an empty one when nothing was
+// exported yet.
+func (r *ExportRepository) Checkpoint(ctx context.Context, name string) (*ExportCheckpoint, error) {
+	var checkpoints []ExportCheckpoint
+	if err := r.db.WithContext(ctx).Where("name = ?", name).Limit(1).Find(&checkpoints).Error; err != nil {
+		return nil, logging.NewOperationError("repository.exports.checkpoint", "", err)
+	}
+	if len(checkpoints) == 0 {
+		return &ExportCheckpoint{Name: name}, nil
+	}
+	return &checkpoints[0], nil
+}
+
+// SaveCheckpoint stores checkpoint.
+func (r *ExportRepository) SaveCheckpoint(ctx context.Context, checkpoint *ExportCheckpoint) error {
+	checkpoint.UpdatedAt = time.Now().UTC()
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_id", "exported", "updated_at"}),
+	}).Create(checkpoint).Error
+	return logging.NewOperationError("repository.exports.save_checkpoint", "", err)
+}
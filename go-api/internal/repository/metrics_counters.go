@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/ai-check/internal/auth"
+)
+
+// MetricsCounter holds running totals of the verification logs of one scope. The
+// totals are updated in the transaction that saves or deletes logs, so the metrics
+// are read from a few rows instead of scanning verification_logs.
+type MetricsCounter struct {
+	// Scope is allScope for every log, variantScope(name) for the logs of an
+	// experiment variant, or hourScope(start) for the logs created in an hour.
+	// tenantScopeOf(id, allScope) and tenantScopeOf(id, hourScope(start)) count
+	// the logs of one tenant; its size fits a tenant ID of 64 characters.
+	Scope string `gorm:"column:scope;primaryKey;size:128"`
+	// Shard spreads the writes of one scope over counterShards rows, so concurrent
+	// verifications do not queue behind the lock of a single row.
+	Shard        int     `gorm:"column:shard;primaryKey;autoIncrement:false"`
+	TotalCount   int64   `gorm:"column:total_count"`
+	SuccessCount int64   `gorm:"column:success_count"`
+	ScoreSum     float64 `gorm:"column:score_sum"`
+	LatencySumMs float64 `gorm:"column:latency_sum_ms"`
+}
+
+// TableName overrides the default table name.
+func (MetricsCounter) TableName() string {
+	return "verification_metrics_counters"
+}
+
+const (
+	counterShards = 16
+	allScope      = "all"
+)
+
+func variantScope(variant string) string {
+	return "variant:" + variant
+}
+
+// hourScopePrefix starts the scopes of hourly counters. The hours follow in a
+// format that sorts like the time, so a range of hours is a range of scopes.
+const (
+	hourScopePrefix = "hour:"
+	hourScopeLayout = "2006-01-02T15"
+)
+
+func hourScope(t time.Time) string {
+	return hourScopePrefix + t.UTC().Format(hourScopeLayout)
+}
+
+// tenantScopePrefix starts the scopes of the counters of one tenant, which follow
+// its ID, e.g. "tenant:acme:all". Logs of no tenant are counted under "tenant::".
+const tenantScopePrefix = "tenant:"
+
+func tenantScopeOf(tenantID, scope string) string {
+	return tenantScopePrefix + tenantID + ":" + scope
+}
+
+// counterScope returns scope as the caller may read it. Authenticated callers read
+// the counters of their tenant, or of no tenant, like tenantScope; only callers
+// without a user, such as the admin listener, read the totals of every tenant.
+func counterScope(ctx context.Context, scope string) string {
+	if _, ok := auth.GetUserID(ctx); !ok {
+		return scope
+	}
+	tenantID, _ := auth.GetTenantID(ctx)
+	return tenantScopeOf(tenantID, scope)
+}
+
+// counterDeltas returns the changes saving log makes to the counters. Logs that are
+// not completed are not counted.
+func counterDeltas(log *VerificationLog) []MetricsCounter {
+	if log.Status != "" && log.Status != StatusCompleted {
+		return nil
+	}
+	delta := MetricsCounter{
+		Scope:        allScope,
+		Shard:        int(log.ID % counterShards),
+		TotalCount:   1,
+		ScoreSum:     float64(log.Score),
+		LatencySumMs: log.ProcessingLatencyMs,
+	}
+	if log.Success {
+		delta.SuccessCount = 1
+	}
+	deltas := []MetricsCounter{delta}
+	delta.Scope = tenantScopeOf(log.TenantID, allScope)
+	deltas = append(deltas, delta)
+	if log.Variant != "" {
+		delta.Scope = variantScope(log.Variant)
+		deltas = append(deltas, delta)
+	}
+	if !log.CreatedAt.IsZero() {
+		delta.Scope = hourScope(log.CreatedAt)
+		deltas = append(deltas, delta)
+		delta.Scope = tenantScopeOf(log.TenantID, hourScope(log.CreatedAt))
+		deltas = append(deltas, delta)
+	}
+	return deltas
+}
+
+// addCounters adds deltas to the counters, creating missing rows.
+func addCounters(tx *gorm.DB, deltas []MetricsCounter) error {
+	add := func(column string) clause.Expr {
+		return gorm.Expr(fmt.Sprintf("verification_metrics_counters.%s + excluded.%s", column, column))
+	}
+	for i := range deltas {
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "scope"}, {Name: "shard"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"total_count":    add("total_count"),
+				"success_count":  add("success_count"),
+				"score_sum":      add("score_sum"),
+				"latency_sum_ms": add("latency_sum_ms"),
+			}),
+		}).Create(&deltas[i]).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countersColumns total a group of logs into a MetricsCounter.
+var countersColumns = []string{
+	"COUNT(*) AS total_count",
+	"COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0) AS success_count",
+	"COALESCE(SUM(score), 0) AS score_sum",
+	"COALESCE(SUM(processing_latency_ms), 0) AS latency_sum_ms",
+}
+
+// logCounters totals the completed logs matched by query per scope, each in the
+// given shard.
+func logCounters(query *gorm.DB, shard int) ([]MetricsCounter, error) {
+	query = query.Where("status = ?", StatusCompleted)
+	var all MetricsCounter
+	if err := query.Session(&gorm.Session{}).Select(countersColumns).Scan(&all).Error; err != nil {
+		return nil, err
+	}
+	var variants []struct {
+		Variant string
+		MetricsCounter
+	}
+	err := query.Session(&gorm.Session{}).
+		Select(append([]string{"variant"}, countersColumns...)).
+		Where("variant <> ''").
+		Group("variant").
+		Scan(&variants).Error
+	if err != nil {
+		return nil, err
+	}
+	var tenants []struct {
+		TenantID string
+		MetricsCounter
+	}
+	err = query.Session(&gorm.Session{}).
+		Select(append([]string{"COALESCE(tenant_id, '') AS tenant_id"}, countersColumns...)).
+		Group("COALESCE(tenant_id, '')").
+		Scan(&tenants).Error
+	if err != nil {
+		return nil, err
+	}
+	all.Scope, all.Shard = allScope, shard
+	counters := []MetricsCounter{all}
+	for _, tenant := range tenants {
+		counter := tenant.MetricsCounter
+		counter.Scope, counter.Shard = tenantScopeOf(tenant.TenantID, allScope), shard
+		counters = append(counters, counter)
+	}
+	for _, variant := range variants {
+		counter := variant.MetricsCounter
+		counter.Scope, counter.Shard = variantScope(variant.Variant), shard
+		counters = append(counters, counter)
+	}
+	hours, err := hourCounters(query.Session(&gorm.Session{}), shard)
+	if err != nil {
+		return nil, err
+	}
+	return append(counters, hours...), nil
+}
+
+// hourCounters totals the logs matched by query per hour of creation, and per hour
+// and tenant. The hours are told apart here rather than in SQL, which has no
+// portable way to truncate times.
+func hourCounters(query *gorm.DB, shard int) ([]MetricsCounter, error) {
+	rows, err := query.Select(
+		"COALESCE(tenant_id, '')",
+		"created_at",
+		"CASE WHEN success THEN 1 ELSE 0 END",
+		"COALESCE(score, 0)",
+		"COALESCE(processing_latency_ms, 0)",
+	).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var counters []MetricsCounter
+	index := map[string]int{}
+	for rows.Next() {
+		var tenantID string
+		var createdAt sql.NullTime
+		var success int64
+		var score, latencyMs float64
+		if err := rows.Scan(&tenantID, &createdAt, &success, &score, &latencyMs); err != nil {
+			return nil, err
+		}
+		if !createdAt.Valid || createdAt.Time.IsZero() {
+			continue
+		}
+		hour := hourScope(createdAt.Time)
+		for _, scope := range []string{hour, tenantScopeOf(tenantID, hour)} {
+			i, ok := index[scope]
+			if !ok {
+				i = len(counters)
+				index[scope] = i
+				counters = append(counters, MetricsCounter{Scope: scope, Shard: shard})
+			}
+			counters[i].TotalCount++
+			counters[i].SuccessCount += success
+			counters[i].ScoreSum += score
+			counters[i].LatencySumMs += latencyMs
+		}
+	}
+	return counters, rows.Err()
+}
+
+// subtractLogs removes the logs with ids from the counters. Call it in the
+// transaction that deletes them, before the delete.
+func subtractLogs(tx *gorm.DB, ids []uint) error {
+	counters, err := logCounters(tx.Model(&VerificationLog{}).Where("id IN ?", ids), 0)
+	if err != nil {
+		return err
+	}
+	for i := range counters {
+		counters[i].TotalCount = -counters[i].TotalCount
+		counters[i].SuccessCount = -counters[i].SuccessCount
+		counters[i].ScoreSum = -counters[i].ScoreSum
+		counters[i].LatencySumMs = -counters[i].LatencySumMs
+	}
+	return addCounters(tx, counters)
+}
+
+// RebuildMetricsCounters recomputes the counters from verification_logs, e.g. after
+// logs were changed outside the repository. It scans every log once.
+func (r *VerificationRepository) RebuildMetricsCounters(ctx context.Context) error {
+	return r.executeWithRetry(ctx, "repository.rebuild_metrics_counters", "", func() error {
+		return r.db.WithContext(ctx).Transaction(RebuildMetricsCountersIn)
+	})
+}
+
+// RebuildMetricsCountersIn is RebuildMetricsCounters within the transaction tx, such
+// as a migration's. It is not retried, as a failed statement aborts tx.
+func RebuildMetricsCountersIn(tx *gorm.DB) error {
+	// Deleting first locks the rows that concurrent saves update, so they wait for
+	// the rebuild and then add their log on top of it.
+	if err := tx.Where("1 = 1").Delete(&MetricsCounter{}).Error; err != nil {
+		return err
+	}
+	counters, err := logCounters(tx.Model(&VerificationLog{}), 0)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&counters).Error
+}
+
+// hasHourCounters reports whether the hourly and tenant counters were seeded, or
+// whether there are no logs to seed them from.
+func hasHourCounters(db *gorm.DB) (bool, error) {
+	for _, prefix := range []string{hourScopePrefix, tenantScopePrefix} {
+		var counters []MetricsCounter
+		err := db.Model(&MetricsCounter{}).
+			Where("scope LIKE ? OR (scope = ? AND total_count <> 0)", prefix+"%", allScope).
+			Order("scope DESC").Limit(1).Find(&counters).Error
+		if err != nil {
+			return false, err
+		}
+		if len(counters) > 0 && counters[0].Scope == allScope {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// sumCounters totals the shards of each scope matching the query.
+func sumCounters(db *gorm.DB) *gorm.DB {
+	return db.Model(&MetricsCounter{}).Select(
+		"scope",
+		"COALESCE(SUM(total_count), 0) AS total_count",
+		"COALESCE(SUM(success_count), 0) AS success_count",
+		"COALESCE(SUM(score_sum), 0) AS score_sum",
+		"COALESCE(SUM(latency_sum_ms), 0) AS latency_sum_ms",
+	).Group("scope")
+}
+
+func (c MetricsCounter) aggregation() MetricsAggregation {
+	aggregation := MetricsAggregation{TotalCount: c.TotalCount, SuccessCount: c.SuccessCount}
+	if c.TotalCount > 0 {
+		aggregation.AverageScore = c.ScoreSum / float64(c.TotalCount)
+		aggregation.AverageProcessingLatencyMs = c.LatencySumMs / float64(c.TotalCount)
+	}
+	return aggregation
+}
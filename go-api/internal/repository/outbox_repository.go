@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+// OutboxEvent is an event saved in the transaction of the change it announces, and
+// relayed to the event publishers from there. Until it is published it is retried,
+// so every event is delivered at least once.
+type OutboxEvent struct {
+	// ID is the event ID; saving the same event twice keeps the first.
+	ID       string `gorm:"primaryKey;size:64"`
+	Type     string `gorm:"column:type;size:64"`
+	UserID   string `gorm:"column:user_id;size:64"`
+	TenantID string `gorm:"column:tenant_id;size:64"`
+	// Payload is the JSON encoded event data.
+	Payload   string    `gorm:"column:payload;type:text"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	// AvailableAt is when the event may next be claimed: after it was saved, once
+	// the lease of the relay that claimed it ran out, or after a failed attempt.
+	AvailableAt time.Time  `gorm:"column:available_at;index:idx_event_outbox_pending,priority:2"`
+	PublishedAt *time.Time `gorm:"column:published_at;index:idx_event_outbox_pending,priority:1"`
+	Attempts    int        `gorm:"column:attempts"`
+	LastError   string     `gorm:"column:last_error;type:text"`
+}
+
+// TableName overrides the default table name.
+func (OutboxEvent) TableName() string {
+	return "event_outbox"
+}
+
+// saveOutbox saves events in tx, keeping events saved before.
+func saveOutbox(tx *gorm.DB, events []*OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	for _, event := range events {
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = now
+		}
+		if event.AvailableAt.IsZero() {
+			event.AvailableAt = now
+		}
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&events).Error
+}
+
+// OutboxRepository relays the events of the transactional outbox.
+type OutboxRepository struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+// NewOutboxRepository creates a new repository instance.
+func NewOutboxRepository(db *gorm.DB, logger *zap.Logger) *OutboxRepository {
+	return &OutboxRepository{db: db, logger: logger.Named("outbox_repository")}
+}
+
+// AutoMigrate ensures the schema is available.
+func (r *OutboxRepository) AutoMigrate(ctx context.Context) error {
+	err := r.db.WithContext(ctx).AutoMigrate(&OutboxEvent{})
+	return logging.NewOperationError("repository.outbox.automigrate", "", err)
+}
+
+// Add saves events that do not accompany another change.
+func (r *OutboxRepository) Add(ctx context.Context, events ...*OutboxEvent) error {
+	err := saveOutbox(r.db.WithContext(ctx), events)
+	return logging.NewOperationError("repository.outbox.add", "", err)
+}
+
+// Claim returns up to limit unpublished events, oldest first, and hides them from
+// other relays for lease. Events whose relay stopped before publishing them become
+// available again once the lease runs out.
+func (r *OutboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEvent, error) {
+	now := time.Now().UTC()
+	var candidates []*OutboxEvent
+	err := r.db.WithContext(ctx).Where("published_at IS NULL AND available_at <= ?", now).
+		Order("available_at, id").Limit(limit).Find(&candidates).Error
+	if err != nil {
+		return nil, logging.NewOperationError("repository.outbox.claim", "", err)
+	}
+	claimed := candidates[:0]
+	for _, event := range candidates {
+		// The condition is checked again, so of two relays claiming an event only one
+		// updates it.
+		result := r.db.WithContext(ctx).Model(&OutboxEvent{}).
+			Where("id = ? AND published_at IS NULL AND available_at <= ?", event.ID, now).
+			Update("available_at", now.Add(lease))
+		if result.Error != nil {
+			return nil, logging.NewOperationError("repository.outbox.claim", "", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			claimed = append(claimed, event)
+		}
+	}
+	return claimed, nil
+}
+
+// MarkPublished records that the event was published.
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id string) error {
+	err := r.db.WithContext(ctx).Model(&OutboxEvent{}).Where("id = ?", id).
+		Update("published_at", time.Now().UTC()).Error
+	return logging.NewOperationError("repository.outbox.mark_published", "", err)
+}
+
+// MarkFailed records a failed attempt to publish the event, which is tried again
+// at retryAt.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id, lastError string, retryAt time.Time) error {
+	err := r.db.WithContext(ctx).Model(&OutboxEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":     gorm.Expr("attempts + 1"),
+		"last_error":   lastError,
+		"available_at": retryAt.UTC(),
+	}).Error
+	return logging.NewOperationError("repository.outbox.mark_failed", "", err)
+}
+
+// PurgePublished deletes the events published before cutoff and returns how many
+// were deleted.
+func (r *OutboxRepository) PurgePublished(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("published_at < ?", cutoff.UTC()).Delete(&OutboxEvent{})
+	return result.RowsAffected, logging.NewOperationError("repository.outbox.purge_published", "", result.Error)
+}
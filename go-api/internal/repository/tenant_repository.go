@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+// TenantSettings holds the settings a tenant overrides, encoded as a JSON document so
+// new settings need no schema change.
+type TenantSettings struct {
+	TenantID  string    `gorm:"column:tenant_id;primaryKey;size:64"`
+	Settings  string    `gorm:"column:settings;type:text;not null"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+// TableName overrides the default table name.
+func (TenantSettings) TableName() string {
+	return "tenant_settings"
+}
+
+// TenantRepository persists tenant settings.
+type TenantRepository struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+// NewTenantRepository creates a new repository instance.
+func NewTenantRepository(db *gorm.DB, logger *zap.Logger) *TenantRepository {
+	return &TenantRepository{db: db, logger: logger.Named("tenant_repository")}
+}
+
+// AutoMigrate ensures the schema is available.
+func (r *TenantRepository) AutoMigrate(ctx context.Context) error {
+	err := r.db.WithContext(ctx).AutoMigrate(&TenantSettings{})
+	return logging.NewOperationError("repository.tenants.automigrate", "", err)
+}
+
+// Find returns the settings of a tenant, or gorm.ErrRecordNotFound.
+func (r *TenantRepository) Find(ctx context.Context, tenantID string) (*TenantSettings, error) {
+	var settings TenantSettings
+	if err := r.db.WithContext(ctx).Where("tenant_id = ?", tenantID).Take(&settings).Error; err != nil {
+		return nil, logging.NewOperationError("repository.tenants.find", "", err)
+	}
+	return &settings, nil
+}
+
+// Save inserts or replaces the settings of a tenant.
+func (r *TenantRepository) Save(ctx context.Context, settings *TenantSettings) error {
+	settings.UpdatedAt = time.Now().UTC()
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"settings", "updated_at"}),
+	}).Create(settings).Error
+	return logging.NewOperationError("repository.tenants.save", "", err)
+}
+
+// Delete removes the settings of a tenant and reports whether there were any.
+func (r *TenantRepository) Delete(ctx context.Context, tenantID string) (bool, error) {
+	result := r.db.WithContext(ctx).Where("tenant_id = ?", tenantID).Delete(&TenantSettings{})
+	if result.Error != nil {
+		return false, logging.NewOperationError("repository.tenants.delete", "", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// List returns up to limit tenants with IDs after cursor, in ID order.
+func (r *TenantRepository) List(ctx context.Context, cursor string, limit int) ([]*TenantSettings, error) {
+	var settings []*TenantSettings
+	err := r.db.WithContext(ctx).Where("tenant_id > ?", cursor).Order("tenant_id").Limit(limit).Find(&settings).Error
+	if err != nil {
+		return nil, logging.NewOperationError("repository.tenants.list", "", err)
+	}
+	return settings, nil
+}
+
+// CountVerificationsSince counts the verifications of a tenant made at or after since.
+func (r *TenantRepository) CountVerificationsSince(ctx context.Context, tenantID string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&VerificationLog{}).
+		Where("tenant_id = ? AND created_at >= ?", tenantID, since).
+		Count(&count).Error
+	if err != nil {
+		return 0, logging.NewOperationError("repository.tenants.count_verifications", "", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+// UsageRecord is the monthly rollup of a user's billable usage.
+type UsageRecord struct {
+	ID     uint   `gorm:"primaryKey"`
+	UserID string `gorm:"column:user_id;size:64;uniqueIndex:idx_usage_records_user_period"`
+	// Period is the calendar month in UTC, formatted "2006-01".
+	Period        string `gorm:"column:period;size:7;uniqueIndex:idx_usage_records_user_period;index"`
+	Units         int64  `gorm:"column:units"`
+	Verifications int64  `gorm:"column:verifications"`
+	// ReportedUnits is how many of Units were already sent to the billing provider.
+	ReportedUnits int64     `gorm:"column:reported_units"`
+	UpdatedAt     time.Time `gorm:"column:updated_at"`
+}
+
+// TableName overrides the default table name.
+func (UsageRecord) TableName() string {
+	return "usage_records"
+}
+
+// BillingAccount links a user to a customer of the billing provider.
+type BillingAccount struct {
+	UserID           string    `gorm:"primaryKey;column:user_id;size:64"`
+	StripeCustomerID string    `gorm:"column:stripe_customer_id;size:255"`
+	UpdatedAt        time.Time `gorm:"column:updated_at"`
+}
+
+// TableName overrides the default table name.
+func (BillingAccount) TableName() string {
+	return "billing_accounts"
+}
+
+// PendingUsage is a usage record with units not yet reported for a billed user.
+type PendingUsage struct {
+	UsageRecord
+	StripeCustomerID string
+}
+
+// UsageRepository persists usage rollups and billing accounts.
+type UsageRepository struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+// NewUsageRepository creates a new repository instance.
+func NewUsageRepository(db *gorm.DB, logger *zap.Logger) *UsageRepository {
+	return &UsageRepository{db: db, logger: logger.Named("usage_repository")}
+}
+
+// AutoMigrate ensures the schema is available.
+func (r *UsageRepository) AutoMigrate(ctx context.Context) error {
+	err := r.db.WithContext(ctx).AutoMigrate(&UsageRecord{}, &BillingAccount{})
+	return logging.NewOperationError("repository.usage.automigrate", "", err)
+}
+
+// AddUsage adds units and one verification to the user's rollup for period.
+func (r *UsageRepository) AddUsage(ctx context.Context, userID, period string, units int64) error {
+	now := time.Now().UTC()
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "period"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"units":         gorm.Expr("usage_records.units + ?", units),
+			"verifications": gorm.Expr("usage_records.verifications + 1"),
+			"updated_at":    now,
+		}),
+	}).Create(&UsageRecord{UserID: userID, Period: period, Units: units, Verifications: 1, UpdatedAt: now}).Error
+	return logging.NewOperationError("repository.usage.add", "", err)
+}
+
+// ListUsage returns the rollups of userID for periods between from and to
+// inclusive, oldest first.
+func (r *UsageRepository) ListUsage(ctx context.Context, userID, from, to string) ([]*UsageRecord, error) {
+	var records []*UsageRecord
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND period >= ? AND period <= ?", userID, from, to).
+		Order("period ASC").Find(&records).Error
+	if err != nil {
+		return nil, logging.NewOperationError("repository.usage.list", "", err)
+	}
+	return records, nil
+}
+
+// UsageForPeriod returns every user's rollup for period, ordered by user.
+func (r *UsageRepository) UsageForPeriod(ctx context.Context, period string) ([]*UsageRecord, error) {
+	var records []*UsageRecord
+	err := r.db.WithContext(ctx).Where("period = ?", period).Order("user_id ASC").Find(&records).Error
+	if err != nil {
+		return nil, logging.NewOperationError("repository.usage.for_period", "", err)
+	}
+	return records, nil
+}
+
+// PendingUsage returns up to limit rollups of users with a billing account whose
+// units have not all been reported.
+func (r *UsageRepository) PendingUsage(ctx context.Context, limit int) ([]*PendingUsage, error) {
+	var pending []*PendingUsage
+	err := r.db.WithContext(ctx).Model(&UsageRecord{}).
+		Select("usage_records.*, billing_accounts.stripe_customer_id").
+		Joins("JOIN billing_accounts ON billing_accounts.user_id = usage_records.user_id").
+		Where("usage_records.units > usage_records.reported_units AND billing_accounts.stripe_customer_id <> ''").
+		Order("usage_records.period ASC, usage_records.user_id ASC").
+		Limit(limit).Scan(&pending).Error
+	if err != nil {
+		return nil, logging.NewOperationError("repository.usage.pending", "", err)
+	}
+	return pending, nil
+}
+
+// MarkReported records that units of a rollup were reported.
+func (r *UsageRepository) MarkReported(ctx context.Context, id uint, units int64) error {
+	err := r.db.WithContext(ctx).Model(&UsageRecord{}).Where("id = ? AND reported_units < ?", id, units).
+		Update("reported_units", units).Error
+	return logging.NewOperationError("repository.usage.mark_reported", "", err)
+}
+
+// SetBillingAccount links userID to a Stripe customer; an empty ID unlinks it.
+func (r *UsageRepository) SetBillingAccount(ctx context.Context, userID, stripeCustomerID string) error {
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"stripe_customer_id", "updated_at"}),
+	}).Create(&BillingAccount{UserID: userID, StripeCustomerID: stripeCustomerID, UpdatedAt: time.Now().UTC()}).Error
+	return logging.NewOperationError("repository.usage.set_billing_account", "", err)
+}
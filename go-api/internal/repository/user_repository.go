@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+// UserProfile holds the account settings operators manage for a user. Users without
+// a profile have the defaults: active, no tier and no quota.
+type UserProfile struct {
+	UserID string `gorm:"primaryKey;column:user_id;size:64"`
+	Tier   string `gorm:"column:tier;size:32"`
+	// MonthlyQuota caps verifications per calendar month; 0 leaves the quota of the
+	// user's tier or the default one.
+	MonthlyQuota    int64      `gorm:"column:monthly_quota"`
+	Suspended       bool       `gorm:"column:suspended;index"`
+	SuspendedReason string     `gorm:"column:suspended_reason;size:512"`
+	SuspendedAt     *time.Time `gorm:"column:suspended_at"`
+	CreatedAt       time.Time  `gorm:"column:created_at"`
+	UpdatedAt       time.Time  `gorm:"column:updated_at"`
+}
+
+// TableName overrides the default table name.
+func (UserProfile) TableName() string {
+	return "user_profiles"
+}
+
+// UserActivity summarizes a user's verification logs.
+type UserActivity struct {
+	UserID        string
+	Verifications int64
+	Failures      int64
+	LastSeenAt    *time.Time
+}
+
+// UserRepository persists user profiles and reads per-user activity from the
+// verification logs.
+type UserRepository struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+// NewUserRepository creates a new repository instance.
+func NewUserRepository(db *gorm.DB, logger *zap.Logger) *UserRepository {
+	return &UserRepository{db: db, logger: logger.Named("user_repository")}
+}
+
+// AutoMigrate ensures the schema is available.
+func (r *UserRepository) AutoMigrate(ctx context.Context) error {
+	err := r.db.WithContext(ctx).AutoMigrate(&UserProfile{})
+	return logging.NewOperationError("repository.users.automigrate", "", err)
+}
+
+// ListUserIDs returns up to limit user IDs known from verification logs or profiles,
+// in order, starting after the cursor and optionally restricted to a prefix.
+func (r *UserRepository) ListUserIDs(ctx context.Context, after, prefix string, limit int) ([]string, error) {
+	pattern := escapeLike(prefix) + "%"
+	var ids []string
+	err := r.db.WithContext(ctx).Raw(`SELECT user_id FROM (
+		SELECT user_id FROM verification_logs WHERE deleted_at IS NULL UNION SELECT user_id FROM user_profiles
+	) known_users WHERE user_id <> '' AND user_id > ? AND user_id LIKE ? ESCAPE '\' ORDER BY user_id LIMIT ?`,
+		after, pattern, limit).Scan(&ids).Error
+	if err != nil {
+		return nil, logging.NewOperationError("repository.users.list_ids", "", err)
+	}
+	return ids, nil
+}
+
+// FindProfiles returns the profiles of userIDs keyed by user ID; users without one
+// are absent.
+func (r *UserRepository) FindProfiles(ctx context.Context, userIDs []string) (map[string]*UserProfile, error) {
+	var profiles []*UserProfile
+	if len(userIDs) > 0 {
+		if err := r.db.WithContext(ctx).Where("user_id IN ?", userIDs).Find(&profiles).Error; err != nil {
+			return nil, logging.NewOperationError("repository.users.find_profiles", "", err)
+		}
+	}
+	byID := make(map[string]*UserProfile, len(profiles))
+	for _, profile := range profiles {
+		byID[profile.UserID] = profile
+	}
+	return byID, nil
+}
+
+// FindProfile returns the profile of userID, or gorm.ErrRecordNotFound when none was
+// saved. Most users have no profile, so a miss is not logged as an error.
+func (r *UserRepository) FindProfile(ctx context.Context, userID string) (*UserProfile, error) {
+	var profiles []*UserProfile
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Limit(1).Find(&profiles).Error
+	if err == nil && len(profiles) == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, logging.NewOperationError("repository.users.find_profile", "", err)
+	}
+	return profiles[0], nil
+}
+
+// SaveProfile creates or replaces a profile.
+func (r *UserRepository) SaveProfile(ctx context.Context, profile *UserProfile) error {
+	now := time.Now().UTC()
+	if profile.CreatedAt.IsZero() {
+		profile.CreatedAt = now
+	}
+	profile.UpdatedAt = now
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"tier", "monthly_quota", "suspended", "suspended_reason", "suspended_at", "updated_at"}),
+	}).Create(profile).Error
+	return logging.NewOperationError("repository.users.save_profile", "", err)
+}
+
+// Activity returns verification counts of userIDs keyed by user ID; users without
+// logs are absent.
+func (r *UserRepository) Activity(ctx context.Context, userIDs []string) (map[string]*UserActivity, error) {
+	type row struct {
+		UserID        string
+		Verifications int64
+		Failures      int64
+		// SQLite returns MAX over a timestamp column as text, so read the latest log
+		// through its ID instead.
+		LastID sql.NullInt64
+	}
+	byID := make(map[string]*UserActivity, len(userIDs))
+	if len(userIDs) == 0 {
+		return byID, nil
+	}
+	var rows []row
+	err := r.db.WithContext(ctx).Model(&VerificationLog{}).
+		Select("user_id",
+			"COUNT(*) AS verifications",
+			"COALESCE(SUM(CASE WHEN success THEN 0 ELSE 1 END), 0) AS failures",
+			"MAX(id) AS last_id").
+		Where("user_id IN ?", userIDs).
+		Group("user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, logging.NewOperationError("repository.users.activity", "", err)
+	}
+
+	lastIDs := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		byID[row.UserID] = &UserActivity{UserID: row.UserID, Verifications: row.Verifications, Failures: row.Failures}
+		if row.LastID.Valid {
+			lastIDs = append(lastIDs, row.LastID.Int64)
+		}
+	}
+	if len(lastIDs) > 0 {
+		var latest []*VerificationLog
+		if err := r.db.WithContext(ctx).Select("id", "user_id", "created_at").Where("id IN ?", lastIDs).Find(&latest).Error; err != nil {
+			return nil, logging.NewOperationError("repository.users.activity", "", err)
+		}
+		for _, log := range latest {
+			if activity := byID[log.UserID]; activity != nil {
+				createdAt := log.CreatedAt
+				activity.LastSeenAt = &createdAt
+			}
+		}
+	}
+	return byID, nil
+}
+
+// CountVerificationsSince counts the verifications userID made since since, in
+// every tenant.
+func (r *UserRepository) CountVerificationsSince(ctx context.Context, userID string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&VerificationLog{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&count).Error
+	if err != nil {
+		return 0, logging.NewOperationError("repository.users.count_verifications", userID, err)
+	}
+	return count, nil
+}
+
+// RecentFailures returns up to limit of the user's unverified logs, newest first.
+func (r *UserRepository) RecentFailures(ctx context.Context, userID string, limit int) ([]*VerificationLog, error) {
+	var logs []*VerificationLog
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND success = ?", userID, false).
+		Order("created_at DESC").Limit(limit).Find(&logs).Error
+	if err != nil {
+		return nil, logging.NewOperationError("repository.users.recent_failures", "", err)
+	}
+	return logs, nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
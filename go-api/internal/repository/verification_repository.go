@@ -2,28 +2,87 @@ package repository
 
 import (
 	"context"
-	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/imagemeta"
 	"github.com/example/ai-check/internal/logging"
 )
 
 // VerificationLog represents a persisted verification request.
 type VerificationLog struct {
-	ID                  uint      `gorm:"primaryKey"`
-	RequestID           string    `gorm:"column:request_id;uniqueIndex;size:64"`
-	UserID              string    `gorm:"column:user_id;size:64"`
-	SHA1Hash            string    `gorm:"column:sha1_hash;size:40;not null;index;uniqueIndex:idx_verification_logs_user_hash"`
-	Score               float32   `gorm:"column:score"`
-	Success             bool      `gorm:"column:success"`
-	Details             string    `gorm:"column:details;type:text"`
-	ProcessingLatencyMs float64   `gorm:"column:processing_latency_ms"`
-	CreatedAt           time.Time `gorm:"column:created_at"`
+	ID        uint   `gorm:"primaryKey;index:idx_verification_logs_user_id,priority:3;index:idx_verification_logs_user_sha256,priority:4"`
+	RequestID string `gorm:"column:request_id;uniqueIndex;size:64"`
+	// The user indexes lead with user_id and end with id, so a user's logs are found,
+	// paged and counted from the index in ID order.
+	UserID string `gorm:"column:user_id;size:64;uniqueIndex:idx_verification_logs_user_hash,priority:1;index:idx_verification_logs_user_id,priority:1;index:idx_verification_logs_user_sha256,priority:1"`
+	// TenantID names the tenant of the user; empty for tokens without a tenant. The
+	// user indexes include it, as queries made for a user are scoped to the tenant.
+	TenantID string `gorm:"column:tenant_id;size:64;index;uniqueIndex:idx_verification_logs_user_hash,priority:3;index:idx_verification_logs_user_id,priority:2;index:idx_verification_logs_user_sha256,priority:3"`
+	// SHA1Hash is still written while logs are migrated to SHA256Hash; it only
+	// matches duplicates of logs without a SHA-256 yet.
+	SHA1Hash string `gorm:"column:sha1_hash;size:40;not null;index;uniqueIndex:idx_verification_logs_user_hash,priority:2"`
+	// SHA256Hash is the hex-encoded SHA-256 of the image; empty for logs made before
+	// it was recorded and not yet backfilled.
+	SHA256Hash string `gorm:"column:sha256_hash;size:64;index;index:idx_verification_logs_user_sha256,priority:2"`
+	// Score is calibrated for the model version that produced it, so scores of
+	// different models compare. Logs made before calibration hold the raw score.
+	Score float32 `gorm:"column:score"`
+	// RawScore is the score as the processor returned it; 0 for logs made before it
+	// was recorded.
+	RawScore float32 `gorm:"column:raw_score"`
+	// ModelVersion names the model that scored the image; empty when the processor
+	// did not report it.
+	ModelVersion        string  `gorm:"column:model_version;size:64;index"`
+	Success             bool    `gorm:"column:success"`
+	Details             string  `gorm:"column:details;type:text"`
+	ProcessingLatencyMs float64 `gorm:"column:processing_latency_ms"`
+	// Verdict is the outcome the score policy gave the verification: verified,
+	// needs_review or rejected. It is empty for queued logs and logs made before
+	// verdicts were recorded.
+	Verdict string `gorm:"column:verdict;size:16"`
+	// ImageKey locates the uploaded image in object storage; empty when it was not kept.
+	ImageKey string `gorm:"column:image_key;size:512"`
+	// Region names the deployment region that served the verification; empty for
+	// single-region deployments.
+	Region string `gorm:"column:region;size:32;index"`
+	// Variant names the experiment variant whose processor scored the image; empty
+	// when no experiment ran.
+	Variant string `gorm:"column:variant;size:64;index"`
+	// PerceptualHash is the hex-encoded phash of the image, used to find visually
+	// similar verifications; empty when the image format could not be decoded.
+	PerceptualHash string `gorm:"column:perceptual_hash;size:16"`
+	// MalwareScan is MalwareScanClean or MalwareScanInfected; empty when no scanner
+	// checked the image. MalwareSignature names the malware found.
+	MalwareScan      string `gorm:"column:malware_scan;size:16"`
+	MalwareSignature string `gorm:"column:malware_signature;size:128"`
+	// ImageMetadata is the capture metadata of the image's EXIF block, kept as JSON;
+	// nil when the image carries none.
+	ImageMetadata *imagemeta.Metadata `gorm:"column:image_metadata;type:jsonb;serializer:json"`
+	// Status is StatusCompleted, or StatusQueued while the verification waits for
+	// the image processor, which leaves the outcome fields empty.
+	Status    string    `gorm:"column:status;size:16;not null;default:'completed';index"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	// DeletedAt is set when the user erased their data. Erased logs are left out of
+	// every query until PurgeUser deletes them.
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index"`
+	// Categories holds the per-category moderation outcomes. It is saved with the log
+	// and loaded by FindByRequestIDAndUser.
+	Categories []VerificationCategory `gorm:"foreignKey:VerificationLogID;constraint:OnDelete:CASCADE"`
+	// Outbox holds the events announcing the log. SaveLog and CompleteQueued save
+	// them in the same transaction as the log.
+	Outbox []*OutboxEvent `gorm:"-" json:",omitempty"`
 }
 
 // TableName overrides the default table name.
@@ -31,6 +90,40 @@ func (VerificationLog) TableName() string {
 	return "verification_logs"
 }
 
+// Verification statuses. Only completed verifications count in the metrics.
+const (
+	StatusCompleted = "completed"
+	// StatusQueued marks verifications accepted while the image processor was
+	// unavailable, to be completed from their stored image.
+	StatusQueued = "queued"
+	// StatusFailed marks queued verifications whose image the processor rejected,
+	// and images rejected as infected.
+	StatusFailed = "failed"
+)
+
+// Outcomes of the malware scan of an image.
+const (
+	MalwareScanClean    = "clean"
+	MalwareScanInfected = "infected"
+)
+
+// VerificationCategory is the outcome of one moderation category of a verification.
+type VerificationCategory struct {
+	ID                uint    `gorm:"primaryKey"`
+	VerificationLogID uint    `gorm:"column:verification_log_id;not null;uniqueIndex:idx_verification_categories_log_category"`
+	Category          string  `gorm:"column:category;size:64;not null;uniqueIndex:idx_verification_categories_log_category"`
+	Score             float32 `gorm:"column:score"`
+	// Threshold is the score the category was flagged at, or 0 when the category has
+	// no threshold and was only recorded.
+	Threshold float32 `gorm:"column:threshold"`
+	Flagged   bool    `gorm:"column:flagged"`
+}
+
+// TableName overrides the default table name.
+func (VerificationCategory) TableName() string {
+	return "verification_categories"
+}
+
 // VerificationRepository provides persistence APIs for verification logs.
 type VerificationRepository struct {
 	db             *gorm.DB
@@ -38,6 +131,15 @@ type VerificationRepository struct {
 	retryAttempts  int
 	initialBackoff time.Duration
 	maxBackoff     time.Duration
+
+	// reader serves the lookups and aggregations of readFromReader, off db; nil
+	// without one.
+	reader *gorm.DB
+	// replica serves reads while db fails; nil without a read replica.
+	replica          *gorm.DB
+	failoverCooldown time.Duration
+	// degradedUntil is the UnixNano time until which reads go to the replica.
+	degradedUntil atomic.Int64
 }
 
 // MetricsAggregation represents aggregated statistics for verification logs.
@@ -48,37 +150,173 @@ type MetricsAggregation struct {
 	AverageProcessingLatencyMs float64
 }
 
+// VariantAggregation is the MetricsAggregation of the logs of one experiment variant.
+type VariantAggregation struct {
+	Variant string
+	MetricsAggregation
+}
+
+// RetryPolicy controls how transient database errors are retried.
+type RetryPolicy struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used by NewVerificationRepository.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:       3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+	}
+}
+
 // NewVerificationRepository creates a new repository instance.
 func NewVerificationRepository(db *gorm.DB, logger *zap.Logger) *VerificationRepository {
+	return NewVerificationRepositoryWithRetry(db, logger, DefaultRetryPolicy())
+}
+
+// NewVerificationRepositoryWithRetry creates a repository with an explicit retry policy.
+func NewVerificationRepositoryWithRetry(db *gorm.DB, logger *zap.Logger, policy RetryPolicy) *VerificationRepository {
 	return &VerificationRepository{
 		db:             db,
 		logger:         logger.Named("verification_repository"),
-		retryAttempts:  3,
-		initialBackoff: 100 * time.Millisecond,
-		maxBackoff:     2 * time.Second,
+		retryAttempts:  policy.Attempts,
+		initialBackoff: policy.InitialBackoff,
+		maxBackoff:     policy.MaxBackoff,
 	}
 }
 
+// SetReadReplica makes reads fall back to replica, typically a read replica in
+// another region, when the local database is unavailable. After a failure, reads
+// stay on the replica for cooldown before the local database is tried again. Writes
+// always go to the local database. Call it before serving requests.
+func (r *VerificationRepository) SetReadReplica(replica *gorm.DB, cooldown time.Duration) {
+	r.replica = replica
+	r.failoverCooldown = cooldown
+}
+
+// SetReader sends the request lookups, duplicate searches and metrics aggregations
+// to reader, typically a streaming replica of the local database, so they do not
+// compete with writes. The replica may lag behind, so a log saved moments ago can be
+// missing there until it catches up. Reads go back to the local database while the
+// reader is unavailable. Lookups that must find such a log pass a context from
+// WithPrimary. Call it before serving requests.
+func (r *VerificationRepository) SetReader(reader *gorm.DB) {
+	r.reader = reader
+}
+
+// primaryKey marks the contexts of reads that skip the reader.
+type primaryKey struct{}
+
+// WithPrimary returns ctx with the lookups of the repository read from the local
+// database rather than the reader, so they find the logs saved moments ago, e.g. to
+// tell whether a log was already saved.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// userHashIndex makes a user's verifications of the same image unique.
+const userHashIndex = "idx_verification_logs_user_hash"
+
+// userIndexes find a user's verifications. They include tenant_id since tenants
+// were recorded.
+var userIndexes = []string{userHashIndex, "idx_verification_logs_user_id", "idx_verification_logs_user_sha256"}
+
+// ErrNotQueued is returned by CompleteQueued and FailQueued for logs that are no
+// longer queued, e.g. because an earlier attempt completed them.
+var ErrNotQueued = errors.New("verification log is not queued")
+
+// ErrDuplicateImage is returned by SaveLog when the user already has a log of the
+// same image.
+var ErrDuplicateImage = errors.New("verification log of the same image already exists")
+
 // AutoMigrate ensures the schema is available.
 func (r *VerificationRepository) AutoMigrate(ctx context.Context) error {
 	return r.executeWithRetry(ctx, "repository.automigrate", "", func() error {
-		return r.db.WithContext(ctx).AutoMigrate(&VerificationLog{})
+		db := r.db.WithContext(ctx)
+		// Earlier schemas indexed the hash alone, so no two users could verify the
+		// same image, and later ones per user but not per tenant. Drop those indexes
+		// for AutoMigrate to recreate them per user and tenant.
+		if indexes, err := db.Migrator().GetIndexes(&VerificationLog{}); err == nil {
+			for _, index := range indexes {
+				if slices.Contains(userIndexes, index.Name()) && !slices.Contains(index.Columns(), "tenant_id") {
+					if err := db.Migrator().DropIndex(&VerificationLog{}, index.Name()); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		seedCounters := !db.Migrator().HasTable(&MetricsCounter{})
+		if err := db.AutoMigrate(&VerificationLog{}, &VerificationCategory{}, &MetricsCounter{}); err != nil {
+			return err
+		}
+		// Logs written before tenants were recorded have no tenant_id. They belong to
+		// no tenant, which tenantScope matches as ''.
+		if err := db.Model(&VerificationLog{}).Unscoped().Where("tenant_id IS NULL").Update("tenant_id", "").Error; err != nil {
+			return err
+		}
+		if !seedCounters {
+			// Counters made before hourly and tenant counters existed are rebuilt to add
+			// them.
+			seeded, err := hasHourCounters(db)
+			if err != nil {
+				return err
+			}
+			seedCounters = !seeded
+		}
+		if seedCounters {
+			return r.RebuildMetricsCounters(ctx)
+		}
+		return nil
 	})
 }
 
-// SaveLog persists a verification log entry.
+// SaveLog persists a verification log entry and adds it to the metrics counters. It
+// returns an error wrapping ErrDuplicateImage when the user already has a log with
+// the same SHA-1.
 func (r *VerificationRepository) SaveLog(ctx context.Context, log *VerificationLog) error {
 	requestID := log.RequestID
 	return r.executeWithRetry(ctx, "repository.save_log", requestID, func() error {
-		return r.db.WithContext(ctx).Create(log).Error
+		err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(log).Error; err != nil {
+				return err
+			}
+			if err := saveOutbox(tx, log.Outbox); err != nil {
+				return err
+			}
+			return addCounters(tx, counterDeltas(log))
+		})
+		if isUniqueViolation(err, userHashIndex, "verification_logs.sha1_hash") {
+			return fmt.Errorf("%w: %v", ErrDuplicateImage, err)
+		}
+		return err
 	})
 }
 
-// FindByRequestIDAndUser retrieves a verification log matching the request and owner.
+// isUniqueViolation reports whether err violates the unique index named index.
+// SQLite does not name the index, so its message is matched against column.
+func isUniqueViolation(err error, index, column string) bool {
+	if err == nil {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505" && pgErr.ConstraintName == index
+	}
+	message := err.Error()
+	return strings.Contains(message, "UNIQUE constraint failed") && strings.Contains(message, column)
+}
+
+// FindByRequestIDAndUser retrieves a verification log matching the request and owner,
+// in the caller's tenant.
 func (r *VerificationRepository) FindByRequestIDAndUser(ctx context.Context, requestID, userID string) (*VerificationLog, error) {
 	var log VerificationLog
-	err := r.executeWithRetry(ctx, "repository.find_by_request_and_user", requestID, func() error {
-		return r.db.WithContext(ctx).First(&log, "request_id = ? AND user_id = ?", requestID, userID).Error
+	err := r.readFromReader(ctx, "repository.find_by_request_and_user", requestID, func(db *gorm.DB) error {
+		return db.WithContext(ctx).Preload("Categories", func(db *gorm.DB) *gorm.DB {
+			return db.Order("category")
+		}).Scopes(tenantScope(ctx)).Where("request_id = ? AND user_id = ?", requestID, userID).Take(&log).Error
 	})
 	if err != nil {
 		return nil, err
@@ -86,18 +324,153 @@ func (r *VerificationRepository) FindByRequestIDAndUser(ctx context.Context, req
 	return &log, nil
 }
 
-// FindDuplicatesByHash retrieves verification logs that share the same hash.
-func (r *VerificationRepository) FindDuplicatesByHash(ctx context.Context, userID, hash, excludeRequestID string) ([]*VerificationLog, error) {
+// FindDuplicatesByHash retrieves up to limit verification logs of the image with
+// sha256Hash, newest first, starting below beforeID; 0 starts at the newest. The logs
+// of a user are those in the caller's tenant. Until
+// every log has a SHA-256, logs without one match by sha1Hash instead. Either hash
+// may be empty.
+func (r *VerificationRepository) FindDuplicatesByHash(ctx context.Context, userID, sha256Hash, sha1Hash, excludeRequestID string, beforeID uint, limit int) ([]*VerificationLog, error) {
+	if sha256Hash == "" && sha1Hash == "" {
+		return nil, nil
+	}
 	var logs []*VerificationLog
-	err := r.executeWithRetry(ctx, "repository.find_duplicates_by_hash", excludeRequestID, func() error {
-		query := r.db.WithContext(ctx).Where("sha1_hash = ?", hash)
-		if userID != "" {
-			query = query.Where("user_id = ?", userID)
+	err := r.readFromReader(ctx, "repository.find_duplicates_by_hash", excludeRequestID, func(db *gorm.DB) error {
+		query := duplicatesQuery(ctx, db.WithContext(ctx), userID, sha256Hash, sha1Hash, excludeRequestID)
+		if beforeID > 0 {
+			query = query.Where("id < ?", beforeID)
 		}
-		if excludeRequestID != "" {
-			query = query.Where("request_id <> ?", excludeRequestID)
+		return query.Order("id DESC").Limit(limit).Find(&logs).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// CountDuplicatesByHash counts the logs FindDuplicatesByHash pages through.
+func (r *VerificationRepository) CountDuplicatesByHash(ctx context.Context, userID, sha256Hash, sha1Hash, excludeRequestID string) (int64, error) {
+	if sha256Hash == "" && sha1Hash == "" {
+		return 0, nil
+	}
+	var count int64
+	err := r.readFromReader(ctx, "repository.count_duplicates_by_hash", excludeRequestID, func(db *gorm.DB) error {
+		return duplicatesQuery(ctx, db.WithContext(ctx).Model(&VerificationLog{}), userID, sha256Hash, sha1Hash, excludeRequestID).Count(&count).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func duplicatesQuery(ctx context.Context, query *gorm.DB, userID, sha256Hash, sha1Hash, excludeRequestID string) *gorm.DB {
+	// Erased logs have their hashes cleared, so they never match. Leaving deleted_at
+	// out keeps the count on the covering index.
+	query = query.Unscoped()
+	switch {
+	case sha256Hash == "":
+		query = query.Where("sha1_hash = ?", sha1Hash)
+	case sha1Hash == "":
+		query = query.Where("sha256_hash = ?", sha256Hash)
+	default:
+		query = query.Where("sha256_hash = ? OR (COALESCE(sha256_hash, '') = '' AND sha1_hash = ?)", sha256Hash, sha1Hash)
+	}
+	if userID != "" {
+		query = query.Where("user_id = ?", userID).Scopes(tenantScope(ctx))
+	}
+	if excludeRequestID != "" {
+		query = query.Where("request_id <> ?", excludeRequestID)
+	}
+	return query
+}
+
+// ListMissingSHA256 returns up to limit logs with an ID above afterID that have a
+// stored image but no SHA-256 yet, in ID order.
+func (r *VerificationRepository) ListMissingSHA256(ctx context.Context, afterID uint, limit int) ([]*VerificationLog, error) {
+	var logs []*VerificationLog
+	err := r.executeWithRetry(ctx, "repository.list_missing_sha256", "", func() error {
+		return r.db.WithContext(ctx).
+			Where("id > ? AND COALESCE(sha256_hash, '') = '' AND image_key <> ''", afterID).
+			Order("id").Limit(limit).Find(&logs).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// CompleteQueued records the outcome of the queued log with log.ID: its score,
+// model, success, verdict, details, latency, variant and categories. It marks the log
+// completed and adds it to the metrics counters, or returns ErrNotQueued.
+func (r *VerificationRepository) CompleteQueued(ctx context.Context, log *VerificationLog) error {
+	return r.executeWithRetry(ctx, "repository.complete_queued", log.RequestID, func() error {
+		return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&VerificationLog{}).Where("id = ? AND status = ?", log.ID, StatusQueued).Updates(map[string]interface{}{
+				"score":                 log.Score,
+				"raw_score":             log.RawScore,
+				"model_version":         log.ModelVersion,
+				"success":               log.Success,
+				"verdict":               log.Verdict,
+				"details":               log.Details,
+				"processing_latency_ms": log.ProcessingLatencyMs,
+				"variant":               log.Variant,
+				"status":                StatusCompleted,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrNotQueued
+			}
+			log.Status = StatusCompleted
+			for i := range log.Categories {
+				log.Categories[i].ID = 0
+				log.Categories[i].VerificationLogID = log.ID
+			}
+			if len(log.Categories) > 0 {
+				if err := tx.Create(&log.Categories).Error; err != nil {
+					return err
+				}
+			}
+			if err := saveOutbox(tx, log.Outbox); err != nil {
+				return err
+			}
+			return addCounters(tx, counterDeltas(log))
+		})
+	})
+}
+
+// FailQueued marks the queued log with id failed, with details saying why, or
+// returns ErrNotQueued.
+func (r *VerificationRepository) FailQueued(ctx context.Context, id uint, details string) error {
+	return r.executeWithRetry(ctx, "repository.fail_queued", "", func() error {
+		result := r.db.WithContext(ctx).Model(&VerificationLog{}).Where("id = ? AND status = ?", id, StatusQueued).
+			Updates(map[string]interface{}{"status": StatusFailed, "details": details})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotQueued
 		}
-		return query.Order("created_at DESC").Find(&logs).Error
+		return nil
+	})
+}
+
+// SetSHA256Hash records the SHA-256 of the log with id.
+func (r *VerificationRepository) SetSHA256Hash(ctx context.Context, id uint, hash string) error {
+	return r.executeWithRetry(ctx, "repository.set_sha256_hash", "", func() error {
+		return r.db.WithContext(ctx).Model(&VerificationLog{}).Where("id = ?", id).Update("sha256_hash", hash).Error
+	})
+}
+
+// ListAfterID returns up to limit logs with an ID above afterID created before
+// createdBefore, with their categories, in ID order.
+func (r *VerificationRepository) ListAfterID(ctx context.Context, afterID uint, createdBefore time.Time, limit int) ([]*VerificationLog, error) {
+	var logs []*VerificationLog
+	err := r.executeWithRetry(ctx, "repository.list_after_id", "", func() error {
+		return r.db.WithContext(ctx).Preload("Categories", func(db *gorm.DB) *gorm.DB {
+			return db.Order("category")
+		}).Where("id > ? AND created_at < ?", afterID, createdBefore).
+			Order("id").Limit(limit).Find(&logs).Error
 	})
 	if err != nil {
 		return nil, err
@@ -105,40 +478,339 @@ func (r *VerificationRepository) FindDuplicatesByHash(ctx context.Context, userI
 	return logs, nil
 }
 
-// AggregateMetrics returns aggregate statistics across verification logs.
+// ListByUser returns up to limit logs of a user in the caller's tenant with an ID
+// below beforeID, newest first, with their categories. A zero beforeID starts at the newest log.
+func (r *VerificationRepository) ListByUser(ctx context.Context, userID string, beforeID uint, limit int) ([]*VerificationLog, error) {
+	return r.FindByUser(ctx, userID, LogFilter{}, beforeID, limit)
+}
+
+// LogFilter narrows the logs returned by FindByUser and SearchLogs. Zero fields
+// match every log of the caller's tenant.
+type LogFilter struct {
+	// UserID keeps only the logs of that user; FindByUser sets it.
+	UserID string
+	// From and To bound the creation time; From is inclusive and To exclusive.
+	From time.Time
+	To   time.Time
+	// Success, when set, keeps only the logs with that outcome.
+	Success *bool
+	// MinScore and MaxScore, when set, bound the score inclusively.
+	MinScore *float32
+	MaxScore *float32
+}
+
+func (f LogFilter) apply(ctx context.Context, query *gorm.DB) *gorm.DB {
+	if f.UserID != "" {
+		query = query.Where("user_id = ?", f.UserID).Scopes(tenantScope(ctx))
+	} else {
+		query = query.Scopes(operatorScope(ctx))
+	}
+	if !f.From.IsZero() {
+		query = query.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		query = query.Where("created_at < ?", f.To)
+	}
+	if f.Success != nil {
+		query = query.Where("success = ?", *f.Success)
+	}
+	if f.MinScore != nil {
+		query = query.Where("score >= ?", *f.MinScore)
+	}
+	if f.MaxScore != nil {
+		query = query.Where("score <= ?", *f.MaxScore)
+	}
+	return query
+}
+
+// tenantScope keeps the logs of the tenant of the caller in ctx, or the logs without
+// a tenant for callers without one. Scoping every query made for a user keeps a
+// request ID or user ID of one tenant from reaching another tenant's logs.
+func tenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	tenantID, _ := auth.GetTenantID(ctx)
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("tenant_id = ?", tenantID)
+	}
+}
+
+// operatorScope is tenantScope for queries across users: callers with a tenant see
+// their tenant, and operators without one see every tenant.
+func operatorScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	tenantID, ok := auth.GetTenantID(ctx)
+	return func(db *gorm.DB) *gorm.DB {
+		if !ok {
+			return db
+		}
+		return db.Where("tenant_id = ?", tenantID)
+	}
+}
+
+// FindByUser returns up to limit logs of a user matching filter with an ID below
+// beforeID, newest first, with their categories. A zero beforeID starts at the
+// newest log. Paging by ID keeps pages stable while logs are written.
+func (r *VerificationRepository) FindByUser(ctx context.Context, userID string, filter LogFilter, beforeID uint, limit int) ([]*VerificationLog, error) {
+	filter.UserID = userID
+	return r.findLogs(ctx, "repository.find_by_user", filter, beforeID, limit)
+}
+
+// SearchLogs is FindByUser across users: it returns up to limit logs of any user
+// matching filter with an ID below beforeID, newest first, with their categories.
+// Callers with a tenant only find the logs of their tenant.
+func (r *VerificationRepository) SearchLogs(ctx context.Context, filter LogFilter, beforeID uint, limit int) ([]*VerificationLog, error) {
+	return r.findLogs(ctx, "repository.search_logs", filter, beforeID, limit)
+}
+
+func (r *VerificationRepository) findLogs(ctx context.Context, operation string, filter LogFilter, beforeID uint, limit int) ([]*VerificationLog, error) {
+	var logs []*VerificationLog
+	err := r.read(ctx, operation, "", func(db *gorm.DB) error {
+		query := db.WithContext(ctx).Preload("Categories", func(db *gorm.DB) *gorm.DB {
+			return db.Order("category")
+		})
+		if beforeID > 0 {
+			query = query.Where("id < ?", beforeID)
+		}
+		return filter.apply(ctx, query).Order("id DESC").Limit(limit).Find(&logs).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// ListHashedByUser returns up to limit logs of a user in the caller's tenant that
+// have a perceptual hash, with an ID above afterID, in ID order. Categories are not
+// loaded.
+func (r *VerificationRepository) ListHashedByUser(ctx context.Context, userID string, afterID uint, limit int) ([]*VerificationLog, error) {
+	var logs []*VerificationLog
+	err := r.read(ctx, "repository.list_hashed_by_user", "", func(db *gorm.DB) error {
+		return db.WithContext(ctx).
+			Scopes(tenantScope(ctx)).
+			Where("user_id = ? AND perceptual_hash <> '' AND id > ?", userID, afterID).
+			Order("id").Limit(limit).Find(&logs).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// AggregateMetrics returns aggregate statistics across the verification logs of the
+// caller's tenant, read from the metrics counters. Callers without a user, such as
+// the admin listener, get the statistics of every tenant.
 func (r *VerificationRepository) AggregateMetrics(ctx context.Context) (*MetricsAggregation, error) {
-	type scanResult struct {
-		TotalCount                 int64
-		SuccessCount               int64
-		AverageScore               sql.NullFloat64
-		AverageProcessingLatencyMs sql.NullFloat64
-	}
-
-	var result scanResult
-	err := r.executeWithRetry(ctx, "repository.aggregate_metrics", "", func() error {
-		return r.db.WithContext(ctx).Model(&VerificationLog{}).
-			Select("COUNT(*) AS total_count",
-				"COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0) AS success_count",
-				"AVG(score) AS average_score",
-				"AVG(processing_latency_ms) AS average_processing_latency_ms").
-			Scan(&result).Error
+	var counters []MetricsCounter
+	err := r.readFromReader(ctx, "repository.aggregate_metrics", "", func(db *gorm.DB) error {
+		return sumCounters(db.WithContext(ctx)).Where("scope = ?", counterScope(ctx, allScope)).Scan(&counters).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(counters) == 0 {
+		return &MetricsAggregation{}, nil
+	}
+	aggregation := counters[0].aggregation()
+	return &aggregation, nil
+}
+
+// PeriodAggregation is the MetricsAggregation of the logs created in one period.
+type PeriodAggregation struct {
+	// Start is when the period begins; it lasts until the next one begins.
+	Start time.Time
+	MetricsAggregation
+}
+
+// AggregateMetricsSeries returns the statistics of the logs created from from until
+// to, in consecutive periods of interval starting at from, read from the hourly
+// metrics counters of the caller's tenant like AggregateMetrics. from and to are rounded to whole hours, down and up, and
+// interval must be a whole number of hours. Periods without logs are included.
+func (r *VerificationRepository) AggregateMetricsSeries(ctx context.Context, from, to time.Time, interval time.Duration) ([]*PeriodAggregation, error) {
+	if interval <= 0 || interval%time.Hour != 0 {
+		return nil, fmt.Errorf("metrics interval %s is not a whole number of hours", interval)
+	}
+	from = from.UTC().Truncate(time.Hour)
+	if end := to.UTC().Truncate(time.Hour); end.Before(to) {
+		to = end.Add(time.Hour)
+	} else {
+		to = end
+	}
+	first, last := counterScope(ctx, hourScope(from)), counterScope(ctx, hourScope(to))
+	var counters []MetricsCounter
+	err := r.readFromReader(ctx, "repository.aggregate_metrics_series", "", func(db *gorm.DB) error {
+		return sumCounters(db.WithContext(ctx)).Where("scope >= ? AND scope < ?", first, last).Scan(&counters).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	totals := make([]MetricsCounter, 0, (to.Sub(from)+interval-1)/interval)
+	for start := from; start.Before(to); start = start.Add(interval) {
+		totals = append(totals, MetricsCounter{})
+	}
+	for _, counter := range counters {
+		hour, err := time.Parse(hourScopeLayout, strings.TrimPrefix(counter.Scope, counterScope(ctx, hourScopePrefix)))
+		if err != nil {
+			continue
+		}
+		total := &totals[hour.Sub(from)/interval]
+		total.TotalCount += counter.TotalCount
+		total.SuccessCount += counter.SuccessCount
+		total.ScoreSum += counter.ScoreSum
+		total.LatencySumMs += counter.LatencySumMs
+	}
+	periods := make([]*PeriodAggregation, 0, len(totals))
+	for i, total := range totals {
+		periods = append(periods, &PeriodAggregation{Start: from.Add(time.Duration(i) * interval), MetricsAggregation: total.aggregation()})
+	}
+	return periods, nil
+}
+
+// AggregateMetricsByVariant returns the statistics of every experiment variant that
+// has verifications, ordered by variant name. Logs without a variant are left out.
+func (r *VerificationRepository) AggregateMetricsByVariant(ctx context.Context) ([]*VariantAggregation, error) {
+	var counters []MetricsCounter
+	err := r.readFromReader(ctx, "repository.aggregate_metrics_by_variant", "", func(db *gorm.DB) error {
+		return sumCounters(db.WithContext(ctx)).
+			Where("scope LIKE ?", variantScope("%")).
+			Having("SUM(total_count) > 0").
+			Order("scope").
+			Scan(&counters).Error
 	})
 	if err != nil {
 		return nil, err
 	}
+	aggregations := make([]*VariantAggregation, 0, len(counters))
+	for _, counter := range counters {
+		variant := strings.TrimPrefix(counter.Scope, variantScope(""))
+		aggregations = append(aggregations, &VariantAggregation{Variant: variant, MetricsAggregation: counter.aggregation()})
+	}
+	return aggregations, nil
+}
+
+// UserAggregation is the MetricsAggregation of the logs of one user.
+type UserAggregation struct {
+	UserID string
+	MetricsAggregation
+}
+
+// AggregateMetricsByUser returns the statistics of up to limit users with completed
+// logs matching filter, ordered by user ID and starting after afterUserID. Unlike
+// AggregateMetrics it scans the logs, so narrow filter on large tables.
+func (r *VerificationRepository) AggregateMetricsByUser(ctx context.Context, filter LogFilter, afterUserID string, limit int) ([]*UserAggregation, error) {
+	var rows []struct {
+		UserID string
+		MetricsCounter
+	}
+	err := r.readFromReader(ctx, "repository.aggregate_metrics_by_user", "", func(db *gorm.DB) error {
+		query := db.WithContext(ctx).Model(&VerificationLog{}).
+			Select(append([]string{"user_id"}, countersColumns...)).
+			Where("status = ?", StatusCompleted)
+		if afterUserID != "" {
+			query = query.Where("user_id > ?", afterUserID)
+		}
+		return filter.apply(ctx, query).Group("user_id").Order("user_id").Limit(limit).Scan(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	aggregations := make([]*UserAggregation, 0, len(rows))
+	for _, row := range rows {
+		aggregations = append(aggregations, &UserAggregation{UserID: row.UserID, MetricsAggregation: row.MetricsCounter.aggregation()})
+	}
+	return aggregations, nil
+}
+
+// DeleteOlderThan removes verification logs created before the cutoff, with their
+// category outcomes, in batches and returns the number of logs deleted. Logs of the
+// excluded tenants are kept.
+func (r *VerificationRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time, batchSize int, excludeTenants ...string) (int64, error) {
+	return r.deleteOlderThan(ctx, "repository.delete_older_than", cutoff, batchSize, func(db *gorm.DB) *gorm.DB {
+		if len(excludeTenants) > 0 {
+			// Logs written before tenants were recorded have no tenant_id, which NOT IN
+			// alone would never match.
+			db = db.Where("tenant_id IS NULL OR tenant_id NOT IN ?", excludeTenants)
+		}
+		return db
+	})
+}
+
+// DeleteTenantOlderThan removes the logs of a tenant created before the cutoff, like
+// DeleteOlderThan.
+func (r *VerificationRepository) DeleteTenantOlderThan(ctx context.Context, tenantID string, cutoff time.Time, batchSize int) (int64, error) {
+	return r.deleteOlderThan(ctx, "repository.delete_tenant_older_than", cutoff, batchSize, func(db *gorm.DB) *gorm.DB {
+		return db.Where("tenant_id = ?", tenantID)
+	})
+}
 
-	aggregation := &MetricsAggregation{
-		TotalCount:   result.TotalCount,
-		SuccessCount: result.SuccessCount,
+func (r *VerificationRepository) deleteOlderThan(ctx context.Context, operation string, cutoff time.Time, batchSize int, scope func(*gorm.DB) *gorm.DB) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = 1000
 	}
-	if result.AverageScore.Valid {
-		aggregation.AverageScore = result.AverageScore.Float64
+
+	var total int64
+	for {
+		var deleted int64
+		err := r.executeWithRetry(ctx, operation, "", func() error {
+			var ids []uint
+			err := scope(r.db.WithContext(ctx).Model(&VerificationLog{})).
+				Where("created_at < ?", cutoff).
+				Order("id").
+				Limit(batchSize).
+				Pluck("id", &ids).Error
+			if err != nil || len(ids) == 0 {
+				deleted = 0
+				return err
+			}
+			return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if err := subtractLogs(tx, ids); err != nil {
+					return err
+				}
+				if err := tx.Where("verification_log_id IN ?", ids).Delete(&VerificationCategory{}).Error; err != nil {
+					return err
+				}
+				result := tx.Unscoped().Where("id IN ?", ids).Delete(&VerificationLog{})
+				deleted = result.RowsAffected
+				return result.Error
+			})
+		})
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+		if deleted < int64(batchSize) {
+			return total, nil
+		}
 	}
-	if result.AverageProcessingLatencyMs.Valid {
-		aggregation.AverageProcessingLatencyMs = result.AverageProcessingLatencyMs.Float64
+}
+
+// readFromReader runs a query against the reader, or as read does without one, for
+// contexts from WithPrimary, or while the reader is unavailable.
+func (r *VerificationRepository) readFromReader(ctx context.Context, operation, requestID string, query func(db *gorm.DB) error) error {
+	if primary, _ := ctx.Value(primaryKey{}).(bool); r.reader == nil || primary {
+		return r.read(ctx, operation, requestID, query)
+	}
+	err := r.executeWithRetry(ctx, operation, requestID, func() error { return query(r.reader) })
+	if err == nil || ctx.Err() != nil || !IsUnavailable(err) {
+		return err
 	}
+	logging.WithOperation(logging.FromContext(ctx, r.logger), operation, requestID).Warn("reader unavailable, reading from the local database", zap.Error(err))
+	return r.read(ctx, operation, requestID, query)
+}
 
-	return aggregation, nil
+// read runs a query against the local database, or against the replica while the
+// local database is degraded.
+func (r *VerificationRepository) read(ctx context.Context, operation, requestID string, query func(db *gorm.DB) error) error {
+	if r.replica == nil {
+		return r.executeWithRetry(ctx, operation, requestID, func() error { return query(r.db) })
+	}
+	if time.Now().UnixNano() >= r.degradedUntil.Load() {
+		err := r.executeWithRetry(ctx, operation, requestID, func() error { return query(r.db) })
+		if err == nil || ctx.Err() != nil || !IsUnavailable(err) {
+			return err
+		}
+		r.degradedUntil.Store(time.Now().Add(r.failoverCooldown).UnixNano())
+		logging.WithOperation(logging.FromContext(ctx, r.logger), operation, requestID).Warn("local database unavailable, reading from the replica",
+			zap.Error(err), zap.Duration("cooldown", r.failoverCooldown))
+	}
+	return r.executeWithRetry(ctx, operation, requestID, func() error { return query(r.replica) })
 }
 
 func (r *VerificationRepository) executeWithRetry(ctx context.Context, operation, requestID string, fn func() error) error {
@@ -147,7 +819,7 @@ func (r *VerificationRepository) executeWithRetry(ctx context.Context, operation
 	}
 
 	backoff := r.initialBackoff
-	opLogger := logging.WithOperation(r.logger, operation, requestID)
+	opLogger := logging.WithOperation(logging.FromContext(ctx, r.logger), operation, requestID)
 	var err error
 	for attempt := 0; attempt < r.retryAttempts; attempt++ {
 		if attempt > 0 {
@@ -179,6 +851,16 @@ func (r *VerificationRepository) executeWithRetry(ctx context.Context, operation
 	return logging.NewOperationError(operation, requestID, err)
 }
 
+// IsUnavailable reports whether err means the database could not be reached, as
+// opposed to a query that failed or found nothing.
+func IsUnavailable(err error) bool {
+	if isTransientError(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
 func isTransientError(err error) bool {
 	if err == nil {
 		return false
@@ -3,11 +3,21 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/imagemeta"
 	"github.com/example/ai-check/internal/logging"
 )
 
@@ -74,3 +84,743 @@ func TestExecuteWithRetryReturnsOperationError(t *testing.T) {
 		t.Fatalf("unexpected request id: %s", opErr.RequestID)
 	}
 }
+
+func TestCategoriesAreSavedLoadedAndPurged(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+
+	old := &VerificationLog{
+		RequestID: "req-1",
+		UserID:    "user-1",
+		SHA1Hash:  "hash-1",
+		CreatedAt: time.Now().Add(-48 * time.Hour),
+		Categories: []VerificationCategory{
+			{Category: "nsfw", Score: 0.9, Threshold: 0.7, Flagged: true},
+			{Category: "ai_generated", Score: 0.2, Threshold: 0.5},
+		},
+	}
+	if err := repo.SaveLog(ctx, old); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-2", UserID: "user-1", SHA1Hash: "hash-2", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+
+	log, err := repo.FindByRequestIDAndUser(ctx, "req-1", "user-1")
+	if err != nil {
+		t.Fatalf("FindByRequestIDAndUser returned error: %v", err)
+	}
+	if len(log.Categories) != 2 || log.Categories[0].Category != "ai_generated" || !log.Categories[1].Flagged || log.Categories[1].Threshold != 0.7 {
+		t.Fatalf("unexpected categories %+v", log.Categories)
+	}
+
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-3", UserID: "user-1", SHA1Hash: "hash-3", Variant: "candidate", Success: true, Score: 0.8, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	variants, err := repo.AggregateMetricsByVariant(ctx)
+	if err != nil || len(variants) != 1 || variants[0].Variant != "candidate" || variants[0].TotalCount != 1 || variants[0].SuccessCount != 1 {
+		t.Fatalf("AggregateMetricsByVariant returned %+v, %v", variants, err)
+	}
+
+	deleted, err := repo.DeleteOlderThan(ctx, time.Now().Add(-24*time.Hour), 10)
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteOlderThan returned %d, %v", deleted, err)
+	}
+	var remaining int64
+	db.Model(&VerificationCategory{}).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("expected the purged log's categories to be deleted, got %d", remaining)
+	}
+}
+
+func TestImageMetadataIsSavedAndLoaded(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+
+	altitude := 34.5
+	metadata := &imagemeta.Metadata{
+		CapturedAt:  "2026-04-30T18:15:42+02:00",
+		CameraModel: "Canon EOS R5",
+		GPS:         &imagemeta.GPS{Latitude: 52.52, Longitude: 13.4, Altitude: &altitude},
+	}
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash-1", ImageMetadata: metadata, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-2", UserID: "user-1", SHA1Hash: "hash-2", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+
+	log, err := repo.FindByRequestIDAndUser(ctx, "req-1", "user-1")
+	if err != nil {
+		t.Fatalf("FindByRequestIDAndUser returned error: %v", err)
+	}
+	if !reflect.DeepEqual(log.ImageMetadata, metadata) {
+		t.Fatalf("expected %+v, got %+v", metadata, log.ImageMetadata)
+	}
+	log, err = repo.FindByRequestIDAndUser(ctx, "req-2", "user-1")
+	if err != nil || log.ImageMetadata != nil {
+		t.Fatalf("expected no metadata, got %+v, %v", log, err)
+	}
+	var stored int64
+	db.Model(&VerificationLog{}).Where("image_metadata IS NULL").Count(&stored)
+	if stored != 1 {
+		t.Fatalf("expected logs without metadata to store NULL, %d do", stored)
+	}
+}
+
+func TestReadsFallBackToTheReplicaWhileTheDatabaseIsUnavailable(t *testing.T) {
+	ctx := context.Background()
+	openSQLite := func() *VerificationRepository {
+		db, err := devmode.OpenDatabase(":memory:")
+		if err != nil {
+			t.Fatalf("OpenDatabase returned error: %v", err)
+		}
+		repo := NewVerificationRepository(db, zap.NewNop())
+		if err := repo.AutoMigrate(ctx); err != nil {
+			t.Fatalf("AutoMigrate returned error: %v", err)
+		}
+		return repo
+	}
+	replica := openSQLite()
+	if err := replica.SaveLog(ctx, &VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash-1", Region: "eu-west-1", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+
+	// A healthy local database answers on its own, even when it finds nothing.
+	healthy := openSQLite()
+	healthy.SetReadReplica(replica.db, time.Minute)
+	if _, err := healthy.FindByRequestIDAndUser(ctx, "req-1", "user-1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected the local database to answer, got %v", err)
+	}
+
+	// Nothing listens on port 1, so every connection is refused.
+	unreachable, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1"), &gorm.Config{
+		Logger:               gormlogger.Discard,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open returned error: %v", err)
+	}
+	repo := NewVerificationRepositoryWithRetry(unreachable, zap.NewNop(), RetryPolicy{Attempts: 1})
+	repo.SetReadReplica(replica.db, time.Minute)
+
+	log, err := repo.FindByRequestIDAndUser(ctx, "req-1", "user-1")
+	if err != nil {
+		t.Fatalf("expected the read to fall back to the replica, got %v", err)
+	}
+	if log.Region != "eu-west-1" {
+		t.Fatalf("unexpected log %+v", log)
+	}
+	if time.Unix(0, repo.degradedUntil.Load()).Before(time.Now().Add(50 * time.Second)) {
+		t.Fatal("expected reads to stay on the replica for the cooldown")
+	}
+	summary, err := repo.AggregateMetrics(ctx)
+	if err != nil || summary.TotalCount != 1 {
+		t.Fatalf("AggregateMetrics returned %+v, %v", summary, err)
+	}
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-2", UserID: "user-1", SHA1Hash: "hash-2"}); err == nil {
+		t.Fatal("expected writes to stay on the unavailable local database")
+	}
+}
+
+func TestReaderServesLookupsAndAggregations(t *testing.T) {
+	ctx := context.Background()
+	openSQLite := func() *VerificationRepository {
+		db, err := devmode.OpenDatabase(":memory:")
+		if err != nil {
+			t.Fatalf("OpenDatabase returned error: %v", err)
+		}
+		repo := NewVerificationRepository(db, zap.NewNop())
+		if err := repo.AutoMigrate(ctx); err != nil {
+			t.Fatalf("AutoMigrate returned error: %v", err)
+		}
+		return repo
+	}
+	reader := openSQLite()
+	if err := reader.SaveLog(ctx, &VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash-1", SHA256Hash: "sha-1", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	repo := openSQLite()
+	repo.SetReader(reader.db)
+
+	if _, err := repo.FindByRequestIDAndUser(ctx, "req-1", "user-1"); err != nil {
+		t.Fatalf("expected the lookup to be served by the reader, got %v", err)
+	}
+	duplicates, err := repo.FindDuplicatesByHash(ctx, "user-1", "sha-1", "hash-1", "req-2", 0, 10)
+	if err != nil || len(duplicates) != 1 {
+		t.Fatalf("FindDuplicatesByHash returned %d logs, %v", len(duplicates), err)
+	}
+	summary, err := repo.AggregateMetrics(ctx)
+	if err != nil || summary.TotalCount != 1 {
+		t.Fatalf("AggregateMetrics returned %+v, %v", summary, err)
+	}
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-2", UserID: "user-1", SHA1Hash: "hash-2", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	if _, err := reader.FindByRequestIDAndUser(ctx, "req-2", "user-1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected writes to go to the local database, got %v", err)
+	}
+
+	// Nothing listens on port 1, so the reader refuses every connection.
+	unreachable, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1"), &gorm.Config{
+		Logger:               gormlogger.Discard,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open returned error: %v", err)
+	}
+	fallback := NewVerificationRepositoryWithRetry(repo.db, zap.NewNop(), RetryPolicy{Attempts: 1})
+	fallback.SetReader(unreachable)
+	if _, err := fallback.FindByRequestIDAndUser(ctx, "req-2", "user-1"); err != nil {
+		t.Fatalf("expected the read to fall back to the local database, got %v", err)
+	}
+}
+
+func TestListHashedByUserSkipsUnhashedLogs(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	for i, log := range []*VerificationLog{
+		{RequestID: "req-1", UserID: "user-1", PerceptualHash: "00000000000000ff"},
+		{RequestID: "req-2", UserID: "user-1"},
+		{RequestID: "req-3", UserID: "user-2", PerceptualHash: "00000000000000ff"},
+		{RequestID: "req-4", UserID: "user-1", PerceptualHash: "ff00000000000000"},
+	} {
+		log.SHA1Hash = fmt.Sprintf("hash-%d", i)
+		if err := repo.SaveLog(ctx, log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+
+	logs, err := repo.ListHashedByUser(ctx, "user-1", 0, 10)
+	if err != nil || len(logs) != 2 || logs[0].RequestID != "req-1" || logs[1].RequestID != "req-4" {
+		t.Fatalf("ListHashedByUser returned %+v, %v", logs, err)
+	}
+	logs, err = repo.ListHashedByUser(ctx, "user-1", logs[0].ID, 10)
+	if err != nil || len(logs) != 1 || logs[0].RequestID != "req-4" {
+		t.Fatalf("expected paging after the first log, got %+v, %v", logs, err)
+	}
+}
+
+func TestFindByUserFiltersByDateAndOutcome(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, log := range []*VerificationLog{
+		{RequestID: "req-1", UserID: "user-1", Success: true, CreatedAt: day.Add(-time.Hour)},
+		{RequestID: "req-2", UserID: "user-1", Success: true, CreatedAt: day},
+		{RequestID: "req-3", UserID: "user-1", Success: false, CreatedAt: day.Add(time.Hour)},
+		{RequestID: "req-4", UserID: "user-2", Success: true, CreatedAt: day.Add(time.Hour)},
+		{RequestID: "req-5", UserID: "user-1", Success: true, CreatedAt: day.Add(25 * time.Hour)},
+	} {
+		log.SHA1Hash = fmt.Sprintf("hash-%d", i)
+		if err := repo.SaveLog(ctx, log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+	requestIDs := func(logs []*VerificationLog) string {
+		ids := make([]string, 0, len(logs))
+		for _, log := range logs {
+			ids = append(ids, log.RequestID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	succeeded := true
+	for _, tc := range []struct {
+		filter LogFilter
+		want   string
+	}{
+		{LogFilter{}, "req-5,req-3,req-2,req-1"},
+		{LogFilter{From: day, To: day.AddDate(0, 0, 1)}, "req-3,req-2"},
+		{LogFilter{From: day, Success: &succeeded}, "req-5,req-2"},
+	} {
+		logs, err := repo.FindByUser(ctx, "user-1", tc.filter, 0, 10)
+		if err != nil || requestIDs(logs) != tc.want {
+			t.Fatalf("FindByUser(%+v) returned %s, %v; want %s", tc.filter, requestIDs(logs), err, tc.want)
+		}
+	}
+
+	first, err := repo.FindByUser(ctx, "user-1", LogFilter{From: day}, 0, 2)
+	if err != nil || requestIDs(first) != "req-5,req-3" {
+		t.Fatalf("unexpected first page %s, %v", requestIDs(first), err)
+	}
+	next, err := repo.FindByUser(ctx, "user-1", LogFilter{From: day}, first[1].ID, 2)
+	if err != nil || requestIDs(next) != "req-2" {
+		t.Fatalf("expected paging below the last log, got %s, %v", requestIDs(next), err)
+	}
+}
+
+func TestSearchLogsAndAggregateMetricsByUserSpanUsers(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	for i, log := range []*VerificationLog{
+		{RequestID: "req-1", UserID: "user-1", Success: true, Score: 0.9, ProcessingLatencyMs: 10},
+		{RequestID: "req-2", UserID: "user-2", Success: false, Score: 0.2, ProcessingLatencyMs: 30},
+		{RequestID: "req-3", UserID: "user-1", Success: false, Score: 0.5, ProcessingLatencyMs: 20},
+		{RequestID: "req-4", UserID: "user-3", Status: StatusQueued},
+	} {
+		log.SHA1Hash = fmt.Sprintf("hash-%d", i)
+		if err := repo.SaveLog(ctx, log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+
+	minScore, maxScore := float32(0.4), float32(0.95)
+	logs, err := repo.SearchLogs(ctx, LogFilter{MinScore: &minScore, MaxScore: &maxScore}, 0, 10)
+	if err != nil || len(logs) != 2 || logs[0].RequestID != "req-3" || logs[1].RequestID != "req-1" {
+		t.Fatalf("unexpected logs %+v (%v)", logs, err)
+	}
+	logs, err = repo.SearchLogs(ctx, LogFilter{UserID: "user-2"}, 0, 10)
+	if err != nil || len(logs) != 1 || logs[0].RequestID != "req-2" {
+		t.Fatalf("expected the logs of user-2, got %+v (%v)", logs, err)
+	}
+
+	users, err := repo.AggregateMetricsByUser(ctx, LogFilter{}, "", 10)
+	if err != nil || len(users) != 2 {
+		t.Fatalf("expected the two users with completed logs, got %+v (%v)", users, err)
+	}
+	if got := users[0]; got.UserID != "user-1" || got.TotalCount != 2 || got.SuccessCount != 1 || math.Abs(got.AverageScore-0.7) > 1e-6 || got.AverageProcessingLatencyMs != 15 {
+		t.Fatalf("unexpected metrics of user-1 %+v", got)
+	}
+	users, err = repo.AggregateMetricsByUser(ctx, LogFilter{}, "user-1", 10)
+	if err != nil || len(users) != 1 || users[0].UserID != "user-2" {
+		t.Fatalf("expected paging after user-1, got %+v (%v)", users, err)
+	}
+}
+
+func TestSaveLogReportsDuplicateImages(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash"}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-2", UserID: "user-1", SHA1Hash: "hash"}); !errors.Is(err, ErrDuplicateImage) {
+		t.Fatalf("expected ErrDuplicateImage, got %v", err)
+	}
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-3", UserID: "user-2", SHA1Hash: "hash"}); err != nil {
+		t.Fatalf("expected another user to verify the same image, got %v", err)
+	}
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-1", UserID: "user-3", SHA1Hash: "other"}); err == nil || errors.Is(err, ErrDuplicateImage) {
+		t.Fatalf("expected a request ID conflict not to be reported as a duplicate image, got %v", err)
+	}
+}
+
+func TestQueriesStayInTheCallersTenant(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	// A log written before tenants were recorded, under the per-user index of then.
+	if err := db.AutoMigrate(&VerificationLog{}, &VerificationCategory{}); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	for _, statement := range []string{
+		"DROP INDEX " + userHashIndex,
+		"CREATE UNIQUE INDEX " + userHashIndex + " ON verification_logs (user_id, sha1_hash)",
+		"INSERT INTO verification_logs (request_id, user_id, tenant_id, sha1_hash, status) VALUES ('req-0', 'user-1', NULL, 'hash-0', 'completed')",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+	repo := NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+
+	acme := auth.WithTenantID(ctx, "acme")
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash"}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	// The same subject in another tenant is another user.
+	if err := repo.SaveLog(acme, &VerificationLog{RequestID: "req-2", UserID: "user-1", TenantID: "acme", SHA1Hash: "hash"}); err != nil {
+		t.Fatalf("expected the tenant's user to verify the same image, got %v", err)
+	}
+
+	if _, err := repo.FindByRequestIDAndUser(acme, "req-1", "user-1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected another tenant's request not to be found, got %v", err)
+	}
+	if _, err := repo.FindByRequestIDAndUser(ctx, "req-2", "user-1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected a tenant's request not to be found without the tenant, got %v", err)
+	}
+	if logs, err := repo.ListByUser(ctx, "user-1", 0, 10); err != nil || len(logs) != 2 || logs[0].RequestID != "req-1" || logs[1].RequestID != "req-0" {
+		t.Fatalf("expected the logs without a tenant, got %+v (%v)", logs, err)
+	}
+	if logs, err := repo.ListByUser(acme, "user-1", 0, 10); err != nil || len(logs) != 1 || logs[0].RequestID != "req-2" {
+		t.Fatalf("expected the tenant's logs, got %+v (%v)", logs, err)
+	}
+	if count, err := repo.CountDuplicatesByHash(acme, "user-1", "", "hash", "req-2"); err != nil || count != 0 {
+		t.Fatalf("expected no duplicates across tenants, got %d (%v)", count, err)
+	}
+	if logs, err := repo.SearchLogs(acme, LogFilter{}, 0, 10); err != nil || len(logs) != 1 {
+		t.Fatalf("expected a tenant's search to stay in the tenant, got %+v (%v)", logs, err)
+	}
+	if logs, err := repo.SearchLogs(ctx, LogFilter{}, 0, 10); err != nil || len(logs) != 3 {
+		t.Fatalf("expected an operator's search to span tenants, got %+v (%v)", logs, err)
+	}
+}
+
+func TestSoftDeleteUserLogsHidesTheLogsUntilPurged(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	for i, userID := range []string{"user-1", "user-1", "user-1", "user-2"} {
+		log := &VerificationLog{RequestID: fmt.Sprintf("req-%d", i), UserID: userID, SHA1Hash: fmt.Sprintf("hash-%d", i), SHA256Hash: fmt.Sprintf("sha256-%d", i), Success: true}
+		if err := repo.SaveLog(ctx, log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+
+	failure := errors.New("cache unavailable")
+	if erased, err := repo.SoftDeleteUserLogs(ctx, "user-1", 2, func([]*VerificationLog) error { return failure }); !errors.Is(err, failure) || erased != 0 {
+		t.Fatalf("expected the release error to keep the logs, got %d, %v", erased, err)
+	}
+	var released []string
+	for {
+		erased, err := repo.SoftDeleteUserLogs(ctx, "user-1", 2, func(logs []*VerificationLog) error {
+			for _, log := range logs {
+				released = append(released, log.RequestID)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("SoftDeleteUserLogs returned error: %v", err)
+		}
+		if erased < 2 {
+			break
+		}
+	}
+	if strings.Join(released, ",") != "req-0,req-1,req-2" {
+		t.Fatalf("expected every log of user-1 to be released, got %v", released)
+	}
+
+	if _, err := repo.FindByRequestIDAndUser(ctx, "req-0", "user-1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected the erased log to be hidden, got %v", err)
+	}
+	if logs, err := repo.SearchLogs(ctx, LogFilter{}, 0, 10); err != nil || len(logs) != 1 || logs[0].UserID != "user-2" {
+		t.Fatalf("expected only the log of user-2 to be listed, got %v, %v", logs, err)
+	}
+	if summary, err := repo.AggregateMetrics(ctx); err != nil || summary.TotalCount != 1 {
+		t.Fatalf("expected the erased logs to leave the counters, got %+v, %v", summary, err)
+	}
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-again", UserID: "user-1", SHA1Hash: "hash-0", SHA256Hash: "sha256-0"}); err != nil {
+		t.Fatalf("expected the erased image to be verified again, got %v", err)
+	}
+
+	if users, err := repo.ErasedUsers(ctx, time.Now().Add(-time.Hour), 10); err != nil || len(users) != 0 {
+		t.Fatalf("expected no erasure before the cutoff, got %v, %v", users, err)
+	}
+	users, err := repo.ErasedUsers(ctx, time.Now().Add(time.Hour), 10)
+	if err != nil || len(users) != 1 || users[0] != "user-1" {
+		t.Fatalf("ErasedUsers returned %v, %v", users, err)
+	}
+	if purged, err := repo.PurgeUser(ctx, "user-1", time.Now().Add(time.Hour), 2, nil); err != nil || purged != 3 {
+		t.Fatalf("PurgeUser returned %d, %v", purged, err)
+	}
+	var remaining int64
+	db.Unscoped().Model(&VerificationLog{}).Count(&remaining)
+	if remaining != 2 {
+		t.Fatalf("expected the new log of user-1 and the log of user-2 to remain, got %d", remaining)
+	}
+}
+
+// planRecorder records the SQL of every statement, with its arguments inlined.
+type planRecorder struct {
+	gormlogger.Interface
+	statements []string
+}
+
+func (r *planRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+func TestHotQueriesUseIndexes(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	if err := NewVerificationRepository(db, zap.NewNop()).AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	recorder := &planRecorder{Interface: gormlogger.Discard}
+	repo := NewVerificationRepository(db.Session(&gorm.Session{Logger: recorder}), zap.NewNop())
+
+	plan := func(query func() error) string {
+		t.Helper()
+		recorder.statements = nil
+		if err := query(); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("query returned error: %v", err)
+		}
+		if len(recorder.statements) == 0 {
+			t.Fatal("expected the query to run a statement")
+		}
+		var rows []struct{ Detail string }
+		if err := db.Raw("EXPLAIN QUERY PLAN " + recorder.statements[0]).Scan(&rows).Error; err != nil {
+			t.Fatalf("EXPLAIN QUERY PLAN returned error: %v", err)
+		}
+		var details []string
+		for _, row := range rows {
+			details = append(details, row.Detail)
+		}
+		return fmt.Sprint(details)
+	}
+	for _, tc := range []struct {
+		name  string
+		query func() error
+		index string
+	}{
+		{"find by request", func() error {
+			_, err := repo.FindByRequestIDAndUser(ctx, "req-1", "user-1")
+			return err
+		}, "INDEX idx_verification_logs_request_id"},
+		{"duplicates by SHA-256", func() error {
+			_, err := repo.FindDuplicatesByHash(ctx, "user-1", "sha256", "", "req-1", 10, 20)
+			return err
+		}, "INDEX idx_verification_logs_user_sha256"},
+		{"count duplicates", func() error {
+			_, err := repo.CountDuplicatesByHash(ctx, "user-1", "sha256", "", "")
+			return err
+		}, "COVERING INDEX idx_verification_logs_user_sha256"},
+		{"list by user", func() error {
+			_, err := repo.ListByUser(ctx, "user-1", 10, 20)
+			return err
+		}, "INDEX idx_verification_logs_user_id"},
+	} {
+		if got := plan(tc.query); !strings.Contains(got, tc.index) || strings.Contains(got, "TEMP B-TREE") {
+			t.Errorf("%s: expected a plan using %s without sorting, got %s", tc.name, tc.index, got)
+		}
+	}
+}
+
+func TestMetricsCountersFollowSavesAndDeletes(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	// Logs written before the counters existed are counted when the table is created.
+	if err := db.AutoMigrate(&VerificationLog{}, &VerificationCategory{}); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	if err := db.Create(&VerificationLog{RequestID: "req-0", UserID: "user-1", SHA1Hash: "hash-0", Success: true, Score: 0.9, ProcessingLatencyMs: 30, CreatedAt: time.Now().Add(-48 * time.Hour)}).Error; err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	recorder := &planRecorder{Interface: gormlogger.Discard}
+	repo := NewVerificationRepository(db.Session(&gorm.Session{Logger: recorder}), zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+
+	for i, log := range []*VerificationLog{
+		{Success: true, Score: 0.5, ProcessingLatencyMs: 10, Variant: "candidate"},
+		{Success: false, Score: 0.1, ProcessingLatencyMs: 20, Variant: "candidate"},
+		{Success: true, Score: 0.7, ProcessingLatencyMs: 40},
+	} {
+		log.RequestID, log.UserID, log.SHA1Hash, log.CreatedAt = fmt.Sprintf("req-%d", i+1), "user-1", fmt.Sprintf("hash-%d", i+1), time.Now()
+		if err := repo.SaveLog(ctx, log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+	// A rejected duplicate is not counted.
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-9", UserID: "user-1", SHA1Hash: "hash-1", Success: true}); !errors.Is(err, ErrDuplicateImage) {
+		t.Fatalf("expected ErrDuplicateImage, got %v", err)
+	}
+
+	recorder.statements = nil
+	summary, err := repo.AggregateMetrics(ctx)
+	if err != nil {
+		t.Fatalf("AggregateMetrics returned error: %v", err)
+	}
+	if summary.TotalCount != 4 || summary.SuccessCount != 3 || math.Abs(summary.AverageScore-0.55) > 1e-6 || summary.AverageProcessingLatencyMs != 25 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	for _, statement := range recorder.statements {
+		if strings.Contains(statement, "verification_logs") {
+			t.Fatalf("expected the summary to be read from the counters, got %s", statement)
+		}
+	}
+	variants, err := repo.AggregateMetricsByVariant(ctx)
+	if err != nil || len(variants) != 1 || variants[0].Variant != "candidate" || variants[0].TotalCount != 2 || variants[0].SuccessCount != 1 {
+		t.Fatalf("AggregateMetricsByVariant returned %+v, %v", variants, err)
+	}
+
+	if deleted, err := repo.DeleteOlderThan(ctx, time.Now().Add(-24*time.Hour), 10); err != nil || deleted != 1 {
+		t.Fatalf("DeleteOlderThan returned %d, %v", deleted, err)
+	}
+	if summary, err := repo.AggregateMetrics(ctx); err != nil || summary.TotalCount != 3 || summary.SuccessCount != 2 || summary.AverageProcessingLatencyMs != 70.0/3 {
+		t.Fatalf("expected the purged log to be subtracted, got %+v, %v", summary, err)
+	}
+
+	// Rebuilding from the logs gives the same totals.
+	if err := repo.RebuildMetricsCounters(ctx); err != nil {
+		t.Fatalf("RebuildMetricsCounters returned error: %v", err)
+	}
+	if summary, err := repo.AggregateMetrics(ctx); err != nil || summary.TotalCount != 3 || summary.SuccessCount != 2 {
+		t.Fatalf("unexpected summary after rebuilding %+v, %v", summary, err)
+	}
+}
+
+func TestAggregateMetricsSeriesBucketsHourlyCounters(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	start := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	for i, log := range []*VerificationLog{
+		{Success: true, Score: 0.8, CreatedAt: start.Add(15 * time.Minute)},
+		{Success: false, Score: 0.2, CreatedAt: start.Add(45 * time.Minute)},
+		{Success: true, Score: 0.6, CreatedAt: start.Add(2*time.Hour + 5*time.Minute)},
+	} {
+		log.RequestID, log.UserID, log.SHA1Hash = fmt.Sprintf("req-%d", i), "user-1", fmt.Sprintf("hash-%d", i)
+		if err := repo.SaveLog(ctx, log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+
+	counts := func(from, to time.Time, interval time.Duration) []int64 {
+		t.Helper()
+		periods, err := repo.AggregateMetricsSeries(ctx, from, to, interval)
+		if err != nil {
+			t.Fatalf("AggregateMetricsSeries returned error: %v", err)
+		}
+		var counts []int64
+		for i, period := range periods {
+			if !period.Start.Equal(start.Add(time.Duration(i) * interval)) {
+				t.Fatalf("period %d starts at %s", i, period.Start)
+			}
+			counts = append(counts, period.TotalCount)
+		}
+		return counts
+	}
+	if got := counts(start.Add(10*time.Minute), start.Add(150*time.Minute), time.Hour); !reflect.DeepEqual(got, []int64{2, 0, 1}) {
+		t.Fatalf("expected hourly counts [2 0 1], got %v", got)
+	}
+	periods, err := repo.AggregateMetricsSeries(ctx, start, start.Add(3*time.Hour), 2*time.Hour)
+	if err != nil || len(periods) != 2 || periods[0].TotalCount != 2 || periods[0].SuccessCount != 1 || math.Abs(periods[0].AverageScore-0.5) > 1e-6 || periods[1].TotalCount != 1 {
+		t.Fatalf("unexpected two-hour periods %+v, %v", periods, err)
+	}
+	if _, err := repo.AggregateMetricsSeries(ctx, start, start.Add(time.Hour), 30*time.Minute); err == nil {
+		t.Fatal("expected an interval shorter than an hour to be refused")
+	}
+
+	// Purged logs leave their hour, and counters made before hourly counters existed
+	// get them on the next migration.
+	if _, err := repo.DeleteOlderThan(ctx, start.Add(30*time.Minute), 10); err != nil {
+		t.Fatalf("DeleteOlderThan returned error: %v", err)
+	}
+	if err := db.Where("scope LIKE ?", "hour:%").Delete(&MetricsCounter{}).Error; err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	if got := counts(start, start.Add(3*time.Hour), time.Hour); !reflect.DeepEqual(got, []int64{1, 0, 1}) {
+		t.Fatalf("expected hourly counts [1 0 1] after the purge, got %v", got)
+	}
+}
+
+func TestQueuedLogsAreCountedOnceCompleted(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+
+	queued := &VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash-1", Status: StatusQueued, CreatedAt: time.Now()}
+	if err := repo.SaveLog(ctx, queued); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	if summary, err := repo.AggregateMetrics(ctx); err != nil || summary.TotalCount != 0 {
+		t.Fatalf("expected a queued log not to be counted, got %+v, %v", summary, err)
+	}
+
+	queued.Success, queued.Score, queued.ProcessingLatencyMs = true, 0.8, 40
+	queued.Categories = []VerificationCategory{{Category: "nsfw", Score: 0.1, Threshold: 0.5}}
+	if err := repo.CompleteQueued(ctx, queued); err != nil {
+		t.Fatalf("CompleteQueued returned error: %v", err)
+	}
+	if err := repo.CompleteQueued(ctx, queued); !errors.Is(err, ErrNotQueued) {
+		t.Fatalf("expected a completed log not to be completed again, got %v", err)
+	}
+	loaded, err := repo.FindByRequestIDAndUser(ctx, "req-1", "user-1")
+	if err != nil || loaded.Status != StatusCompleted || loaded.Score != 0.8 || len(loaded.Categories) != 1 {
+		t.Fatalf("expected the completed log with its categories, got %+v, %v", loaded, err)
+	}
+	if summary, err := repo.AggregateMetrics(ctx); err != nil || summary.TotalCount != 1 || summary.SuccessCount != 1 {
+		t.Fatalf("expected the completed log to be counted once, got %+v, %v", summary, err)
+	}
+	if err := repo.FailQueued(ctx, loaded.ID, "failed"); !errors.Is(err, ErrNotQueued) {
+		t.Fatalf("expected a completed log not to fail, got %v", err)
+	}
+
+	// Rebuilding the counters leaves queued logs out too.
+	if err := repo.SaveLog(ctx, &VerificationLog{RequestID: "req-2", UserID: "user-1", SHA1Hash: "hash-2", Status: StatusQueued, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	if err := repo.RebuildMetricsCounters(ctx); err != nil {
+		t.Fatalf("RebuildMetricsCounters returned error: %v", err)
+	}
+	if summary, err := repo.AggregateMetrics(ctx); err != nil || summary.TotalCount != 1 {
+		t.Fatalf("expected only the completed log after rebuilding, got %+v, %v", summary, err)
+	}
+}
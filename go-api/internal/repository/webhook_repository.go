@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+// WebhookEndpoint is a URL a user registered to receive event notifications.
+type WebhookEndpoint struct {
+	ID     string `gorm:"primaryKey;size:36"`
+	UserID string `gorm:"column:user_id;size:64;index"`
+	URL    string `gorm:"column:url;size:2048"`
+	// Secret keys the HMAC signature of every delivery.
+	Secret string `gorm:"column:secret;size:128"`
+	// Events is the comma-separated list of subscribed event types.
+	Events    string    `gorm:"column:events;size:512"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+// TableName overrides the default table name.
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// EventList returns the subscribed event types.
+func (e *WebhookEndpoint) EventList() []string {
+	if e.Events == "" {
+		return nil
+	}
+	return strings.Split(e.Events, ",")
+}
+
+// Subscribes reports whether the endpoint wants event.
+func (e *WebhookEndpoint) Subscribes(event string) bool {
+	for _, subscribed := range e.EventList() {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records one event sent, or to be sent, to one endpoint. Retries and
+// replays update the same row, so its ID doubles as the receiver's idempotency key.
+type WebhookDelivery struct {
+	ID         string `gorm:"primaryKey;size:36"`
+	EndpointID string `gorm:"column:endpoint_id;size:36;uniqueIndex:idx_webhook_deliveries_endpoint_event;index:idx_webhook_deliveries_endpoint_created"`
+	EventID    string `gorm:"column:event_id;size:64;uniqueIndex:idx_webhook_deliveries_endpoint_event"`
+	Event      string `gorm:"column:event;size:64"`
+	// Payload is the exact JSON body that is signed and sent.
+	Payload      string `gorm:"column:payload;type:text"`
+	Status       string `gorm:"column:status;size:16"`
+	Attempts     int    `gorm:"column:attempts"`
+	ResponseCode int    `gorm:"column:response_code"`
+	// ResponseBody keeps the start of the receiver's last response.
+	ResponseBody string     `gorm:"column:response_body;type:text"`
+	LastError    string     `gorm:"column:last_error;type:text"`
+	CreatedAt    time.Time  `gorm:"column:created_at;index:idx_webhook_deliveries_endpoint_created"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at"`
+	DeliveredAt  *time.Time `gorm:"column:delivered_at"`
+}
+
+// TableName overrides the default table name.
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// WebhookRepository persists webhook endpoints and their delivery log.
+type WebhookRepository struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+// NewWebhookRepository creates a new repository instance.
+func NewWebhookRepository(db *gorm.DB, logger *zap.Logger) *WebhookRepository {
+	return &WebhookRepository{db: db, logger: logger.Named("webhook_repository")}
+}
+
+// AutoMigrate ensures the schema is available.
+func (r *WebhookRepository) AutoMigrate(ctx context.Context) error {
+	err := r.db.WithContext(ctx).AutoMigrate(&WebhookEndpoint{}, &WebhookDelivery{})
+	return logging.NewOperationError("repository.webhooks.automigrate", "", err)
+}
+
+// CreateEndpoint stores a new endpoint.
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error {
+	err := r.db.WithContext(ctx).Create(endpoint).Error
+	return logging.NewOperationError("repository.webhooks.create_endpoint", "", err)
+}
+
+// CountEndpoints returns how many endpoints userID has registered.
+func (r *WebhookRepository) CountEndpoints(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&WebhookEndpoint{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, logging.NewOperationError("repository.webhooks.count_endpoints", "", err)
+}
+
+// ListEndpoints returns the endpoints of userID, oldest first.
+func (r *WebhookRepository) ListEndpoints(ctx context.Context, userID string) ([]*WebhookEndpoint, error) {
+	var endpoints []*WebhookEndpoint
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&endpoints).Error
+	if err != nil {
+		return nil, logging.NewOperationError("repository.webhooks.list_endpoints", "", err)
+	}
+	return endpoints, nil
+}
+
+// FindEndpoint retrieves an endpoint by ID regardless of its owner.
+func (r *WebhookRepository) FindEndpoint(ctx context.Context, id string) (*WebhookEndpoint, error) {
+	var endpoint WebhookEndpoint
+	if err := r.db.WithContext(ctx).First(&endpoint, "id = ?", id).Error; err != nil {
+		return nil, logging.NewOperationError("repository.webhooks.find_endpoint", "", err)
+	}
+	return &endpoint, nil
+}
+
+// DeleteEndpoint removes an endpoint of userID together with its delivery log and
+// reports whether it existed.
+func (r *WebhookRepository) DeleteEndpoint(ctx context.Context, userID, id string) (bool, error) {
+	var deleted bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&WebhookEndpoint{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		deleted = true
+		return tx.Where("endpoint_id = ?", id).Delete(&WebhookDelivery{}).Error
+	})
+	return deleted, logging.NewOperationError("repository.webhooks.delete_endpoint", "", err)
+}
+
+// CreateDelivery stores a delivery unless the endpoint already has one for the same
+// event, and reports whether it was created.
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *WebhookDelivery) (bool, error) {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(delivery)
+	if result.Error != nil {
+		return false, logging.NewOperationError("repository.webhooks.create_delivery", "", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// FindDelivery retrieves a delivery by ID.
+func (r *WebhookRepository) FindDelivery(ctx context.Context, id string) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if err := r.db.WithContext(ctx).First(&delivery, "id = ?", id).Error; err != nil {
+		return nil, logging.NewOperationError("repository.webhooks.find_delivery", "", err)
+	}
+	return &delivery, nil
+}
+
+// SaveDelivery updates the status columns of a delivery.
+func (r *WebhookRepository) SaveDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	err := r.db.WithContext(ctx).Model(delivery).Select(
+		"status", "attempts", "response_code", "response_body", "last_error", "delivered_at", "updated_at",
+	).Updates(delivery).Error
+	return logging.NewOperationError("repository.webhooks.save_delivery", "", err)
+}
+
+// ListDeliveries returns up to limit deliveries to an endpoint, newest first.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, endpointID string, limit int) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	err := r.db.WithContext(ctx).Where("endpoint_id = ?", endpointID).Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, logging.NewOperationError("repository.webhooks.list_deliveries", "", err)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,67 @@
+// Package requestid generates the IDs of verifications. Besides random UUIDs it
+// offers time-ordered formats, whose IDs sort by creation time, so recent
+// verifications stay close together in the request_id index.
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Formats of generated IDs.
+const (
+	// FormatUUID is a random version 4 UUID.
+	FormatUUID = "uuid"
+	// FormatUUIDv7 is a version 7 UUID, which starts with the millisecond it was made.
+	FormatUUIDv7 = "uuidv7"
+	// FormatULID is a 26-character ULID, which starts with the millisecond it was made.
+	FormatULID = "ulid"
+)
+
+// Valid reports whether format is one of the Format constants.
+func Valid(format string) bool {
+	switch format {
+	case FormatUUID, FormatUUIDv7, FormatULID:
+		return true
+	}
+	return false
+}
+
+// New returns a new ID in format. Unknown formats, including "", get a FormatUUID.
+func New(format string) string {
+	switch format {
+	case FormatUUIDv7:
+		if id, err := uuid.NewV7(); err == nil {
+			return id.String()
+		}
+	case FormatULID:
+		return ULID(time.Now())
+	}
+	return uuid.NewString()
+}
+
+// crockford is the alphabet of ULIDs, Crockford's base32.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID returns a ULID for t: 48 bits of Unix milliseconds followed by 80 random
+// bits. IDs of the same millisecond are not ordered among themselves.
+func ULID(t time.Time) string {
+	var id [16]byte
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(t.UnixMilli()))
+	copy(id[:6], ms[2:])
+	_, _ = rand.Read(id[6:])
+
+	// 128 bits in 26 characters of 5 bits, the first holding the top 3 bits.
+	var out [26]byte
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
@@ -0,0 +1,44 @@
+package requestid
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestULIDEncodesTheTimeFirst(t *testing.T) {
+	// The specification's example timestamp encodes to 01ARYZ6S41.
+	id := ULID(time.UnixMilli(1469918176385))
+	if len(id) != 26 || id[:10] != "01ARYZ6S41" {
+		t.Fatalf("expected a ULID starting with 01ARYZ6S41, got %q", id)
+	}
+
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 50; i++ {
+		ids = append(ids, ULID(start.Add(time.Duration(i)*time.Millisecond)))
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Fatalf("expected ULIDs of later milliseconds to sort later, got %v", ids)
+	}
+}
+
+func TestNewGeneratesEachFormat(t *testing.T) {
+	if id, err := uuid.Parse(New(FormatUUID)); err != nil || id.Version() != 4 {
+		t.Fatalf("expected a version 4 UUID, got %v (%v)", id, err)
+	}
+	if id, err := uuid.Parse(New(FormatUUIDv7)); err != nil || id.Version() != 7 {
+		t.Fatalf("expected a version 7 UUID, got %v (%v)", id, err)
+	}
+	if id := New(FormatULID); len(id) != 26 {
+		t.Fatalf("expected a ULID, got %q", id)
+	}
+	if id, err := uuid.Parse(New("")); err != nil || id.Version() != 4 {
+		t.Fatalf("expected the default to be a version 4 UUID, got %v (%v)", id, err)
+	}
+	if Valid("snowflake") || !Valid(FormatULID) {
+		t.Fatal("expected Valid to accept only the known formats")
+	}
+}
@@ -0,0 +1,117 @@
+// Package resultstream tells clients waiting on a verification when it completes.
+// The use case publishes the verification events to a Redis channel per
+// verification, so a client streaming its result from one replica hears about a
+// verification completed by another.
+package resultstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+// channelPrefix starts the Redis channel of each verification.
+const channelPrefix = "verification-status:"
+
+// Update is a status transition of a verification.
+type Update struct {
+	RequestID string `json:"request_id"`
+	// Status is repository.StatusCompleted or repository.StatusFailed.
+	Status string `json:"status"`
+}
+
+// Hub publishes and subscribes to the updates of verifications.
+type Hub struct {
+	redis     *redis.Client
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns a hub publishing through client.
+func New(client *redis.Client) *Hub {
+	return &Hub{redis: client, done: make(chan struct{})}
+}
+
+// Close ends every subscription, e.g. so a shutting down server need not wait for
+// the open streams. Publishing still works.
+func (h *Hub) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
+func channel(requestID string) string {
+	return channelPrefix + requestID
+}
+
+// Publish implements usecase.EventPublisher, announcing the verifications that
+// completed or failed. Other events are ignored. Updates are not kept, so only the
+// clients subscribed at the time receive them.
+func (h *Hub) Publish(ctx context.Context, event usecase.Event) error {
+	var update Update
+	switch data := event.Data.(type) {
+	case usecase.VerificationEvent:
+		if event.Type != usecase.EventVerificationCompleted {
+			return nil
+		}
+		update = Update{RequestID: data.RequestID, Status: repository.StatusCompleted}
+	case usecase.VerificationFailedEvent:
+		update = Update{RequestID: data.RequestID, Status: repository.StatusFailed}
+	default:
+		return nil
+	}
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	if err := h.redis.Publish(ctx, channel(update.RequestID), payload).Err(); err != nil {
+		return fmt.Errorf("publish status of %s: %w", update.RequestID, err)
+	}
+	return nil
+}
+
+// Subscribe listens for the updates of requestID until ctx is done, the returned
+// function is called or the hub is closed, which closes the channel. The
+// subscription is active when Subscribe returns, so a status read afterwards cannot
+// miss an update.
+func (h *Hub) Subscribe(ctx context.Context, requestID string) (<-chan Update, func(), error) {
+	subscription := h.redis.Subscribe(ctx, channel(requestID))
+	if _, err := subscription.Receive(ctx); err != nil {
+		subscription.Close()
+		return nil, nil, fmt.Errorf("subscribe to %s: %w", requestID, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	updates := make(chan Update, 1)
+	go func() {
+		defer close(updates)
+		defer subscription.Close()
+		messages := subscription.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-h.done:
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				var update Update
+				if json.Unmarshal([]byte(message.Payload), &update) != nil {
+					continue
+				}
+				select {
+				case updates <- update:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return updates, cancel, nil
+}
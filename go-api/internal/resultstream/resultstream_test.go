@@ -0,0 +1,58 @@
+package resultstream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+func TestSubscribersReceiveCompletionsAndFailures(t *testing.T) {
+	server, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer server.Close()
+	defer client.Close()
+	hub := New(client)
+	ctx := context.Background()
+
+	updates, cancel, err := hub.Subscribe(ctx, "req-1")
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	defer cancel()
+	for _, event := range []usecase.Event{
+		{Type: usecase.EventVerificationNeedsReview, Data: usecase.VerificationEvent{RequestID: "req-1"}},
+		{Type: usecase.EventVerificationCompleted, Data: usecase.VerificationEvent{RequestID: "req-2"}},
+		{Type: usecase.EventVerificationCompleted, Data: usecase.VerificationEvent{RequestID: "req-1"}},
+		{Type: usecase.EventVerificationFailed, Data: usecase.VerificationFailedEvent{RequestID: "req-1"}},
+	} {
+		if err := hub.Publish(ctx, event); err != nil {
+			t.Fatalf("Publish returned error: %v", err)
+		}
+	}
+	for _, want := range []string{repository.StatusCompleted, repository.StatusFailed} {
+		select {
+		case update := <-updates:
+			if update.RequestID != "req-1" || update.Status != want {
+				t.Fatalf("expected req-1 to be %s, got %+v", want, update)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected req-1 to be %s", want)
+		}
+	}
+
+	hub.Close()
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Fatal("expected no further updates")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected closing the hub to end the subscription")
+	}
+}
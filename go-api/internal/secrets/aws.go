@@ -0,0 +1,115 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/ai-check/internal/sigv4"
+)
+
+// AWSOptions configures an AWS Secrets Manager provider. The secret must hold a JSON
+// object in SecretString.
+type AWSOptions struct {
+	Region   string
+	SecretID string
+	// Endpoint defaults to https://secretsmanager.<region>.amazonaws.com.
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Client          *http.Client
+	// Now is used to date request signatures; defaults to time.Now.
+	Now func() time.Time
+}
+
+// AWSSecretsManager reads one secret through the GetSecretValue API.
+type AWSSecretsManager struct {
+	opts AWSOptions
+}
+
+// NewAWSSecretsManager returns a provider reading opts.SecretID.
+func NewAWSSecretsManager(opts AWSOptions) (*AWSSecretsManager, error) {
+	if opts.Region == "" || opts.SecretID == "" {
+		return nil, errors.New("aws region and secret id are required")
+	}
+	if opts.AccessKeyID == "" || opts.SecretAccessKey == "" {
+		return nil, errors.New("aws credentials are required; set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", opts.Region)
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &AWSSecretsManager{opts: opts}, nil
+}
+
+// Fetch reads the current version of the secret. Rotation in Secrets Manager is
+// picked up on the next call.
+func (a *AWSSecretsManager) Fetch(ctx context.Context) (Values, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": a.opts.SecretID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.opts.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	creds := sigv4.Credentials{AccessKeyID: a.opts.AccessKeyID, SecretAccessKey: a.opts.SecretAccessKey, SessionToken: a.opts.SessionToken}
+	sigv4.Sign(req, sigv4.HashPayload(payload), creds, a.opts.Region, "secretsmanager", a.opts.Now())
+
+	resp, err := a.opts.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("read aws secret %s: %w", a.opts.SecretID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("read aws secret %s: %w", a.opts.SecretID, awsResponseError(resp))
+	}
+
+	var body struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("read aws secret %s: %w", a.opts.SecretID, err)
+	}
+	if body.SecretString == nil {
+		return nil, fmt.Errorf("read aws secret %s: binary secrets are not supported", a.opts.SecretID)
+	}
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(*body.SecretString), &data); err != nil {
+		return nil, fmt.Errorf("read aws secret %s: SecretString is not a JSON object", a.opts.SecretID)
+	}
+	return decodeValues(data)
+}
+
+func awsResponseError(resp *http.Response) error {
+	var body struct {
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &body) != nil || body.Type == "" {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	// __type may be prefixed with a namespace, e.g. "com.amazonaws...#ResourceNotFoundException".
+	errType := body.Type[strings.LastIndex(body.Type, "#")+1:]
+	message := body.Message
+	if message == "" {
+		message = body.MessageUpper
+	}
+	return fmt.Errorf("status %d: %s: %s", resp.StatusCode, errType, message)
+}
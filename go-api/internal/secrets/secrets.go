@@ -0,0 +1,90 @@
+// Package secrets reads service credentials from external secrets managers.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Keys looked up in the stored secret. Any other keys are ignored.
+const (
+	KeyJWTSecret          = "jwt_secret"
+	KeyJWTPreviousSecrets = "jwt_previous_secrets"
+	KeyDatabasePassword   = "database_password"
+	KeyRedisUsername      = "redis_username"
+	KeyRedisPassword      = "redis_password"
+)
+
+// Values maps secret keys to their current values. Lists such as
+// jwt_previous_secrets are comma separated.
+type Values map[string]string
+
+// Equal reports whether both sets hold the same keys and values.
+func (v Values) Equal(other Values) bool {
+	if len(v) != len(other) {
+		return false
+	}
+	for key, value := range v {
+		if otherValue, ok := other[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// List splits a comma separated value, dropping empty items.
+func (v Values) List(key string) []string {
+	var items []string
+	for _, item := range strings.Split(v[key], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Provider fetches the current secret values.
+type Provider interface {
+	Fetch(ctx context.Context) (Values, error)
+}
+
+// Renewer is implemented by providers whose own credentials expire unless renewed.
+type Renewer interface {
+	Renew(ctx context.Context) error
+}
+
+// decodeValues converts a JSON object into Values. Strings are kept as is, arrays of
+// strings are joined with commas and other scalars use their JSON form.
+func decodeValues(data map[string]json.RawMessage) (Values, error) {
+	values := make(Values, len(data))
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		raw := data[key]
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil {
+			values[key] = text
+			continue
+		}
+		var list []string
+		if err := json.Unmarshal(raw, &list); err == nil {
+			values[key] = strings.Join(list, ",")
+			continue
+		}
+		var scalar interface{}
+		if err := json.Unmarshal(raw, &scalar); err != nil {
+			return nil, fmt.Errorf("decode secret key %q: %w", key, err)
+		}
+		if _, nested := scalar.(map[string]interface{}); nested {
+			return nil, fmt.Errorf("secret key %q holds an object; expected a string", key)
+		}
+		values[key] = strings.TrimSpace(string(raw))
+	}
+	return values, nil
+}
@@ -0,0 +1,136 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVaultFetchReadsKVv2Secret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "file-token" || r.Header.Get("X-Vault-Namespace") != "team" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"errors":["permission denied"]}`)
+			return
+		}
+		if r.URL.Path != "/v1/kv/data/ai-check/prod" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		io.WriteString(w, `{"data":{"data":{"jwt_secret":"s3cret","jwt_previous_secrets":["a","b"],"port":5432}}}`)
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+	vault, err := NewVault(VaultOptions{Addr: server.URL + "/", TokenFile: tokenFile, Namespace: "team", Mount: "kv", Path: "ai-check/prod"})
+	if err != nil {
+		t.Fatalf("NewVault returned error: %v", err)
+	}
+
+	values, err := vault.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if values[KeyJWTSecret] != "s3cret" || values["port"] != "5432" {
+		t.Fatalf("unexpected values: %v", values)
+	}
+	if got := values.List(KeyJWTPreviousSecrets); len(got) != 2 || got[1] != "b" {
+		t.Fatalf("expected previous secrets [a b], got %v", got)
+	}
+
+	vault.opts.Namespace = ""
+	if _, err := vault.Fetch(context.Background()); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected the Vault error to be reported, got %v", err)
+	}
+}
+
+func TestVaultRenewSkipsNonRenewableTokens(t *testing.T) {
+	renewals := 0
+	renewable := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]bool{"renewable": renewable}})
+		case "/v1/auth/token/renew-self":
+			renewals++
+			io.WriteString(w, `{"auth":{"lease_duration":3600}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		renewable bool
+		want      int
+	}{{false, 0}, {true, 2}} {
+		renewals, renewable = 0, tc.renewable
+		vault, _ := NewVault(VaultOptions{Addr: server.URL, Token: "token", Path: "app"})
+		for i := 0; i < 2; i++ {
+			if err := vault.Renew(context.Background()); err != nil {
+				t.Fatalf("Renew returned error: %v", err)
+			}
+		}
+		if renewals != tc.want {
+			t.Fatalf("renewable=%v: expected %d renewals, got %d", tc.renewable, tc.want, renewals)
+		}
+	}
+}
+
+func TestAWSSecretsManagerFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			t.Errorf("unexpected target %q", r.Header.Get("X-Amz-Target"))
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20240102/eu-west-1/secretsmanager/aws4_request") ||
+			!strings.Contains(auth, "x-amz-security-token") {
+			t.Errorf("unexpected authorization header %q", auth)
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["SecretId"] != "missing" {
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"database_password":"pg-pass"}`})
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`)
+	}))
+	defer server.Close()
+
+	opts := AWSOptions{
+		Region:          "eu-west-1",
+		SecretID:        "ai-check/prod",
+		Endpoint:        server.URL,
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+		Now:             func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+	manager, err := NewAWSSecretsManager(opts)
+	if err != nil {
+		t.Fatalf("NewAWSSecretsManager returned error: %v", err)
+	}
+	values, err := manager.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if values[KeyDatabasePassword] != "pg-pass" {
+		t.Fatalf("unexpected values: %v", values)
+	}
+
+	opts.SecretID = "missing"
+	manager, _ = NewAWSSecretsManager(opts)
+	if _, err := manager.Fetch(context.Background()); err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
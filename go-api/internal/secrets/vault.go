@@ -0,0 +1,163 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// VaultOptions configures a Vault KV version 2 provider using token authentication.
+type VaultOptions struct {
+	Addr string
+	// Token is used as is; TokenFile is re-read on every request so a sidecar such as
+	// the Vault agent can rotate it. Token wins when both are set.
+	Token     string
+	TokenFile string
+	Namespace string
+	Mount     string
+	Path      string
+	Client    *http.Client
+}
+
+// Vault reads one KV version 2 secret and keeps its token alive.
+type Vault struct {
+	opts VaultOptions
+
+	mu sync.Mutex
+	// renewable is nil until the token has been looked up.
+	renewable *bool
+}
+
+// NewVault returns a provider reading opts.Path under opts.Mount.
+func NewVault(opts VaultOptions) (*Vault, error) {
+	if opts.Addr == "" || opts.Path == "" {
+		return nil, errors.New("vault address and secret path are required")
+	}
+	if opts.Token == "" && opts.TokenFile == "" {
+		return nil, errors.New("vault token or token file is required")
+	}
+	if opts.Mount == "" {
+		opts.Mount = "secret"
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	opts.Addr = strings.TrimRight(opts.Addr, "/")
+	return &Vault{opts: opts}, nil
+}
+
+// Fetch reads the latest version of the secret.
+func (v *Vault) Fetch(ctx context.Context) (Values, error) {
+	endpoint := fmt.Sprintf("/v1/%s/data/%s", strings.Trim(v.opts.Mount, "/"), strings.Trim(v.opts.Path, "/"))
+	var body struct {
+		Data struct {
+			Data map[string]json.RawMessage `json:"data"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, endpoint, &body); err != nil {
+		return nil, fmt.Errorf("read vault secret %s: %w", v.opts.Path, err)
+	}
+	if body.Data.Data == nil {
+		return nil, fmt.Errorf("read vault secret %s: secret has no data", v.opts.Path)
+	}
+	return decodeValues(body.Data.Data)
+}
+
+// Renew extends the lease of the Vault token. Tokens that are not renewable, such as
+// root tokens, are left alone.
+func (v *Vault) Renew(ctx context.Context) error {
+	renewable, err := v.tokenRenewable(ctx)
+	if err != nil || !renewable {
+		return err
+	}
+	if err := v.do(ctx, http.MethodPost, "/v1/auth/token/renew-self", nil); err != nil {
+		return fmt.Errorf("renew vault token: %w", err)
+	}
+	return nil
+}
+
+func (v *Vault) tokenRenewable(ctx context.Context) (bool, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.renewable != nil {
+		return *v.renewable, nil
+	}
+	var body struct {
+		Data struct {
+			Renewable bool `json:"renewable"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, "/v1/auth/token/lookup-self", &body); err != nil {
+		return false, fmt.Errorf("look up vault token: %w", err)
+	}
+	v.renewable = &body.Data.Renewable
+	return body.Data.Renewable, nil
+}
+
+func (v *Vault) do(ctx context.Context, method, path string, out interface{}) error {
+	token, err := v.token()
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.JoinPath(v.opts.Addr, path)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if v.opts.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.opts.Namespace)
+	}
+
+	resp, err := v.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return responseError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (v *Vault) token() (string, error) {
+	if v.opts.Token != "" {
+		return v.opts.Token, nil
+	}
+	data, err := os.ReadFile(v.opts.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("read vault token file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// responseError summarises a failed API response without echoing secret material.
+func responseError(resp *http.Response) error {
+	var body struct {
+		Errors  []string `json:"errors"`
+		Message string   `json:"message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &body) == nil {
+		if len(body.Errors) > 0 {
+			return fmt.Errorf("status %d: %s", resp.StatusCode, strings.Join(body.Errors, "; "))
+		}
+		if body.Message != "" {
+			return fmt.Errorf("status %d: %s", resp.StatusCode, body.Message)
+		}
+	}
+	return fmt.Errorf("status %d", resp.StatusCode)
+}
@@ -0,0 +1,105 @@
+// Package spool buffers uploads that must be read more than once, such as images
+// that are verified and then stored. Small uploads stay in memory; larger ones move
+// to a temporary file, so a small container does not hold a full-size image per
+// request in flight.
+package spool
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// filePattern names the temporary files of a Buffer.
+const filePattern = "ai-check-upload-*"
+
+// Buffer is an io.Writer that keeps up to threshold bytes in memory and spills
+// everything to a temporary file once more are written. Close it to release the
+// file. A Buffer is not safe for concurrent use.
+type Buffer struct {
+	dir       string
+	threshold int64
+	memory    bytes.Buffer
+	file      *os.File
+	// removed is true once the file is unlinked; until then Close removes it.
+	removed bool
+	size    int64
+}
+
+// New returns a Buffer that spills to a file in dir, or in the default directory
+// for temporary files when dir is empty, once more than threshold bytes are
+// written. A threshold of zero or less spools every upload to disk.
+func New(dir string, threshold int64) *Buffer {
+	return &Buffer{dir: dir, threshold: threshold}
+}
+
+// Write appends p to the buffer.
+func (b *Buffer) Write(p []byte) (int, error) {
+	if b.file == nil && b.size+int64(len(p)) > b.threshold {
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	var err error
+	if b.file != nil {
+		n, err = b.file.Write(p)
+	} else {
+		n, err = b.memory.Write(p)
+	}
+	b.size += int64(n)
+	return n, err
+}
+
+// spill moves the bytes written so far to a new temporary file.
+func (b *Buffer) spill() error {
+	file, err := os.CreateTemp(b.dir, filePattern)
+	if err != nil {
+		return fmt.Errorf("spool upload: %w", err)
+	}
+	// Unlinking the open file lets the system reclaim it even if the process dies
+	// before Close. Systems that cannot unlink open files remove it on Close.
+	b.removed = os.Remove(file.Name()) == nil
+	b.file = file
+	if _, err := b.memory.WriteTo(file); err != nil {
+		return fmt.Errorf("spool upload: %w", err)
+	}
+	b.memory = bytes.Buffer{}
+	return nil
+}
+
+// Size returns the number of bytes written.
+func (b *Buffer) Size() int64 {
+	return b.size
+}
+
+// Spooled reports whether the buffer moved to a temporary file.
+func (b *Buffer) Spooled() bool {
+	return b.file != nil
+}
+
+// Reader returns a reader over everything written so far, from the start. Readers
+// are independent of each other and of later writes.
+func (b *Buffer) Reader() *io.SectionReader {
+	if b.file != nil {
+		return io.NewSectionReader(b.file, 0, b.size)
+	}
+	return io.NewSectionReader(bytes.NewReader(b.memory.Bytes()), 0, b.size)
+}
+
+// Close releases the memory or the temporary file. Readers fail afterwards.
+func (b *Buffer) Close() error {
+	b.memory = bytes.Buffer{}
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	if !b.removed {
+		if removeErr := os.Remove(b.file.Name()); removeErr != nil && err == nil {
+			err = removeErr
+		}
+	}
+	b.file = nil
+	return err
+}
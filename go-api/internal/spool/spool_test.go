@@ -0,0 +1,57 @@
+package spool
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestBufferKeepsSmallUploadsInMemory(t *testing.T) {
+	dir := t.TempDir()
+	buffer := New(dir, 16)
+	defer buffer.Close()
+
+	if _, err := buffer.Write([]byte("small")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if buffer.Spooled() {
+		t.Fatal("expected an upload below the threshold to stay in memory")
+	}
+	data, err := io.ReadAll(buffer.Reader())
+	if err != nil || string(data) != "small" {
+		t.Fatalf("expected to read back the upload, got %q (%v)", data, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected no temporary file, got %v", entries)
+	}
+}
+
+func TestBufferSpoolsLargeUploadsAndRemovesThem(t *testing.T) {
+	dir := t.TempDir()
+	buffer := New(dir, 16)
+
+	want := bytes.Repeat([]byte("0123456789"), 10)
+	for _, chunk := range [][]byte{want[:10], want[10:]} {
+		if _, err := buffer.Write(chunk); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	if !buffer.Spooled() || buffer.Size() != int64(len(want)) {
+		t.Fatalf("expected %d bytes spooled to disk, got %d (spooled %t)", len(want), buffer.Size(), buffer.Spooled())
+	}
+	// Readers are independent, so the upload can be hashed and then sent.
+	for i := 0; i < 2; i++ {
+		data, err := io.ReadAll(buffer.Reader())
+		if err != nil || !bytes.Equal(data, want) {
+			t.Fatalf("read %d: expected the whole upload, got %d bytes (%v)", i, len(data), err)
+		}
+	}
+
+	if err := buffer.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected the temporary file to be removed, got %v", entries)
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalOptions configures a store on the local file system.
+type LocalOptions struct {
+	// Dir holds the objects; it is created when missing.
+	Dir string
+	// Prefix is prepended to every object key, e.g. "images/".
+	Prefix string
+	// BaseURL is where the API serves the objects, e.g.
+	// "https://api.example.com/images"; signed URLs append the key to it.
+	BaseURL string
+	// SigningKey authenticates signed URLs. Every instance serving the same
+	// directory needs the same key.
+	SigningKey []byte
+	// Now is used to date signatures; defaults to time.Now.
+	Now func() time.Time
+}
+
+// Local stores objects as files below one directory, for single-host setups
+// without object storage. Its signed URLs are served by the API itself.
+type Local struct {
+	opts LocalOptions
+}
+
+// ErrInvalidSignature is returned by Verify for URLs that were not signed by the
+// store or have expired.
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// NewLocal returns a store writing below opts.Dir.
+func NewLocal(opts LocalOptions) (*Local, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("directory is required")
+	}
+	if len(opts.SigningKey) == 0 {
+		return nil, errors.New("signing key is required")
+	}
+	base, err := url.Parse(opts.BaseURL)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q", opts.BaseURL)
+	}
+	if err := os.MkdirAll(opts.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create %s: %w", opts.Dir, err)
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Local{opts: opts}, nil
+}
+
+// path maps key to a file below the directory, refusing keys that would leave the
+// prefix.
+func (l *Local) path(key string) (string, error) {
+	name := filepath.FromSlash(strings.TrimLeft(l.opts.Prefix+key, "/"))
+	if !filepath.IsLocal(filepath.FromSlash(key)) || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(l.opts.Dir, name), nil
+}
+
+// Put writes the size bytes of body under key. The object is written to a
+// temporary file first, so readers never see a partial image.
+func (l *Local) Put(_ context.Context, key, _ string, body io.ReadSeeker, size int64) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := io.Copy(file, io.LimitReader(body, size)); err != nil {
+		file.Close()
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	return nil
+}
+
+// Open opens the object under key. The caller must close it. The returned file
+// also implements io.Seeker.
+func (l *Local) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("get object %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get object %s: %w", key, err)
+	}
+	return file, nil
+}
+
+// Delete removes the object under key. Deleting a missing object succeeds.
+func (l *Local) Delete(_ context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+// SignedURL returns a URL below BaseURL that allows downloading key without
+// credentials for ttl.
+func (l *Local) SignedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
+	expires := strconv.FormatInt(l.opts.Now().Add(ttl).Unix(), 10)
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	query := url.Values{"expires": {expires}, "signature": {l.sign(key, expires)}}
+	return l.opts.BaseURL + "/" + strings.Join(segments, "/") + "?" + query.Encode(), nil
+}
+
+// Verify checks the expires and signature parameters of a URL handed out by
+// SignedURL for key.
+func (l *Local) Verify(key, expires, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(l.sign(key, expires))) {
+		return ErrInvalidSignature
+	}
+	if l.opts.Now().After(time.Unix(unix, 0)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (l *Local) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, l.opts.SigningKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalPutOpenAndSignedURL(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store, err := NewLocal(LocalOptions{
+		Dir:        t.TempDir(),
+		Prefix:     "images/",
+		BaseURL:    "https://api.example.com/images/",
+		SigningKey: []byte("0123456789abcdef0123456789abcdef"),
+		Now:        func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("NewLocal returned error: %v", err)
+	}
+	ctx := context.Background()
+	if err := store.Put(ctx, "user 1/req-1", "image/png", strings.NewReader("png-bytes"), int64(len("png-bytes"))); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	object, err := store.Open(ctx, "user 1/req-1")
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	body, _ := io.ReadAll(object)
+	object.Close()
+	if string(body) != "png-bytes" {
+		t.Fatalf("expected the stored image, got %q", body)
+	}
+	if err := store.Put(ctx, "../escape", "image/png", strings.NewReader("x"), 1); err == nil {
+		t.Fatal("expected keys leaving the directory to be refused")
+	}
+
+	signed, err := store.SignedURL(ctx, "user 1/req-1", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL returned error: %v", err)
+	}
+	parsed, err := url.Parse(signed)
+	if err != nil || parsed.Host != "api.example.com" || parsed.Path != "/images/user 1/req-1" {
+		t.Fatalf("unexpected signed url %q", signed)
+	}
+	expires, signature := parsed.Query().Get("expires"), parsed.Query().Get("signature")
+	if err := store.Verify("user 1/req-1", expires, signature); err != nil {
+		t.Fatalf("expected the signed url to verify, got %v", err)
+	}
+	if err := store.Verify("user 1/req-2", expires, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected a signature of another key to be refused, got %v", err)
+	}
+	now = now.Add(16 * time.Minute)
+	if err := store.Verify("user 1/req-1", expires, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected an expired url to be refused, got %v", err)
+	}
+
+	if err := store.Delete(ctx, "user 1/req-1"); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if _, err := store.Open(ctx, "user 1/req-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after Delete, got %v", err)
+	}
+	if err := store.Delete(ctx, "user 1/req-1"); err != nil {
+		t.Fatalf("expected deleting a missing object to succeed, got %v", err)
+	}
+}
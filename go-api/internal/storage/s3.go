@@ -0,0 +1,178 @@
+// Package storage keeps uploaded images in S3-compatible object storage or on local
+// disk.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/example/ai-check/internal/sigv4"
+)
+
+// S3Options configures an S3-compatible bucket. The same client serves AWS S3, MinIO
+// and Google Cloud Storage through its XML interoperability API with HMAC keys.
+type S3Options struct {
+	// Endpoint defaults to https://s3.<region>.amazonaws.com.
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to every object key, e.g. "images/".
+	Prefix string
+	// PathStyle addresses the bucket as <endpoint>/<bucket> instead of as a
+	// subdomain; MinIO and most self-hosted stores need it.
+	PathStyle   bool
+	Credentials sigv4.Credentials
+	Client      *http.Client
+	// Now is used to date signatures; defaults to time.Now.
+	Now func() time.Time
+}
+
+// S3 stores objects in one bucket.
+type S3 struct {
+	opts S3Options
+	base *url.URL
+}
+
+// NewS3 returns a store for opts.Bucket.
+func NewS3(opts S3Options) (*S3, error) {
+	if opts.Bucket == "" || opts.Region == "" {
+		return nil, errors.New("bucket and region are required")
+	}
+	if opts.Credentials.AccessKeyID == "" || opts.Credentials.SecretAccessKey == "" {
+		return nil, errors.New("access key id and secret access key are required")
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", opts.Region)
+	}
+	base, err := url.Parse(strings.TrimRight(opts.Endpoint, "/"))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", opts.Endpoint)
+	}
+	if opts.PathStyle {
+		base.Path += "/" + opts.Bucket
+	} else {
+		base.Host = opts.Bucket + "." + base.Host
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &S3{opts: opts, base: base}, nil
+}
+
+func (s *S3) objectURL(key string) string {
+	u := *s.base
+	u.Path += "/" + strings.TrimLeft(s.opts.Prefix+key, "/")
+	return u.String()
+}
+
+// Put uploads the size bytes of body under key. body is read twice, once to hash it
+// for the signature and once to send it, so large objects can stream from disk.
+func (s *S3) Put(ctx context.Context, key, contentType string, body io.ReadSeeker, size int64) error {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, io.LimitReader(body, size)); err != nil {
+		return fmt.Errorf("put object %s: hash body: %w", key, err)
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), io.NopCloser(io.LimitReader(body, size)))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	payloadHash := hex.EncodeToString(hasher.Sum(nil))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	sigv4.Sign(req, payloadHash, s.opts.Credentials, s.opts.Region, "s3", s.opts.Now())
+
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("put object %s: %w", key, responseError(resp))
+	}
+	return nil
+}
+
+// ErrNotFound is returned by Open for keys without an object.
+var ErrNotFound = errors.New("object not found")
+
+// Open downloads the object under key. The caller must close it.
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	payloadHash := sigv4.HashPayload(nil)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	sigv4.Sign(req, payloadHash, s.opts.Credentials, s.opts.Region, "s3", s.opts.Now())
+
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get object %s: %w", key, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("get object %s: %w", key, ErrNotFound)
+	default:
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("get object %s: %w", key, responseError(resp))
+	}
+}
+
+// Delete removes the object under key. Deleting a missing object succeeds.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	payloadHash := sigv4.HashPayload(nil)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	sigv4.Sign(req, payloadHash, s.opts.Credentials, s.opts.Region, "s3", s.opts.Now())
+
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete object %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("delete object %s: %w", key, responseError(resp))
+	}
+}
+
+// SignedURL returns a URL that allows downloading key without credentials for ttl.
+func (s *S3) SignedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
+	return sigv4.Presign(http.MethodGet, s.objectURL(key), s.opts.Credentials, s.opts.Region, "s3", s.opts.Now(), ttl)
+}
+
+// responseError extracts the code from an S3 XML error body.
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if start := bytes.Index(body, []byte("<Code>")); start >= 0 {
+		rest := body[start+len("<Code>"):]
+		if end := bytes.Index(rest, []byte("</Code>")); end >= 0 {
+			return fmt.Errorf("status %d: %s", resp.StatusCode, rest[:end])
+		}
+	}
+	return fmt.Errorf("status %d", resp.StatusCode)
+}
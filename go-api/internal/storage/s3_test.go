@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/ai-check/internal/sigv4"
+)
+
+func TestS3PutAndSignedURL(t *testing.T) {
+	var stored []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/uploads/images/user-1/req-1" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<?xml version="1.0"?><Error><Code>AccessDenied</Code></Error>`)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") ||
+			r.Header.Get("X-Amz-Content-Sha256") != sigv4.HashPayload([]byte("png-bytes")) {
+			t.Errorf("unexpected signature headers %v", r.Header)
+		}
+		if r.ContentLength != int64(len("png-bytes")) {
+			t.Errorf("expected the object length to be declared, got %d", r.ContentLength)
+		}
+		stored, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	store, err := NewS3(S3Options{
+		Endpoint:    server.URL,
+		Region:      "us-east-1",
+		Bucket:      "uploads",
+		Prefix:      "images/",
+		PathStyle:   true,
+		Credentials: sigv4.Credentials{AccessKeyID: "minio", SecretAccessKey: "minio-secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewS3 returned error: %v", err)
+	}
+	ctx := context.Background()
+	if err := store.Put(ctx, "user-1/req-1", "image/png", strings.NewReader("png-bytes"), int64(len("png-bytes"))); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	if string(stored) != "png-bytes" {
+		t.Fatalf("expected the object body to be uploaded, got %q", stored)
+	}
+	if err := store.Put(ctx, "other", "image/png", strings.NewReader("x"), 1); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Fatalf("expected the S3 error code to be reported, got %v", err)
+	}
+
+	signed, err := store.SignedURL(ctx, "user-1/req-1", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL returned error: %v", err)
+	}
+	if !strings.HasPrefix(signed, server.URL+"/uploads/images/user-1/req-1?") || !strings.Contains(signed, "X-Amz-Expires=900") {
+		t.Fatalf("unexpected signed URL %s", signed)
+	}
+}
+
+func TestS3Open(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("X-Amz-Content-Sha256") != sigv4.HashPayload(nil) {
+			t.Errorf("unexpected request %s %v", r.Method, r.Header)
+		}
+		if r.URL.Path != "/uploads/user-1/req-1" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<?xml version="1.0"?><Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		io.WriteString(w, "png-bytes")
+	}))
+	defer server.Close()
+
+	store, err := NewS3(S3Options{
+		Endpoint:    server.URL,
+		Region:      "us-east-1",
+		Bucket:      "uploads",
+		PathStyle:   true,
+		Credentials: sigv4.Credentials{AccessKeyID: "minio", SecretAccessKey: "minio-secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewS3 returned error: %v", err)
+	}
+	body, err := store.Open(context.Background(), "user-1/req-1")
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer body.Close()
+	if data, _ := io.ReadAll(body); string(data) != "png-bytes" {
+		t.Fatalf("expected the object body, got %q", data)
+	}
+	if _, err := store.Open(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestS3Delete(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.Header.Get("Authorization") == "" {
+			t.Errorf("unexpected request %s %v", r.Method, r.Header)
+		}
+		switch r.URL.Path {
+		case "/uploads/user-1/req-1":
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		case "/uploads/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<?xml version="1.0"?><Error><Code>AccessDenied</Code></Error>`)
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewS3(S3Options{
+		Endpoint:    server.URL,
+		Region:      "us-east-1",
+		Bucket:      "uploads",
+		PathStyle:   true,
+		Credentials: sigv4.Credentials{AccessKeyID: "minio", SecretAccessKey: "minio-secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewS3 returned error: %v", err)
+	}
+	if err := store.Delete(context.Background(), "user-1/req-1"); err != nil || len(deleted) != 1 {
+		t.Fatalf("Delete returned %v, deleted %v", err, deleted)
+	}
+	if err := store.Delete(context.Background(), "missing"); err != nil {
+		t.Fatalf("expected deleting a missing object to succeed, got %v", err)
+	}
+	if err := store.Delete(context.Background(), "locked"); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Fatalf("expected the S3 error code, got %v", err)
+	}
+}
+
+func TestS3VirtualHostedAddressing(t *testing.T) {
+	store, err := NewS3(S3Options{
+		Region:      "eu-west-1",
+		Bucket:      "ai-check-images",
+		Credentials: sigv4.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewS3 returned error: %v", err)
+	}
+	if got := store.objectURL("a/b"); got != "https://ai-check-images.s3.eu-west-1.amazonaws.com/a/b" {
+		t.Fatalf("unexpected object URL %s", got)
+	}
+}
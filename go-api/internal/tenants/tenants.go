@@ -0,0 +1,373 @@
+// Package tenants stores the settings a multi-tenant deployment applies per customer
+// instead of the global configuration: verdict thresholds, log retention, accepted
+// upload types and a monthly quota. A user belongs to the tenant named by the
+// "tenant" claim of their token. Settings live in Postgres and are cached in Redis,
+// so every replica sees an operator's change within Options.CacheTTL.
+package tenants
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/imageprocessor"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/usecase"
+)
+
+const listBatchSize = 500
+
+var (
+	// ErrInvalidTenant is returned for tenant IDs that are not 1-64 letters, digits,
+	// '.', '-' or '_'.
+	ErrInvalidTenant = errors.New("tenant id must be 1-64 letters, digits, '.', '-' or '_'")
+	// ErrInvalidSettings is returned for settings outside their allowed ranges.
+	ErrInvalidSettings = errors.New("invalid tenant settings")
+	// ErrContentTypeNotAllowed is returned by CheckUpload for upload types the tenant
+	// does not accept.
+	ErrContentTypeNotAllowed = errors.New("content type not allowed for tenant")
+	// ErrQuotaExceeded is returned by CheckUpload once the tenant used up its monthly
+	// quota.
+	ErrQuotaExceeded = errors.New("monthly verification quota exceeded")
+)
+
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+var categories = []string{
+	imageprocessor.CategoryAIGenerated,
+	imageprocessor.CategoryManipulated,
+	imageprocessor.CategoryNSFW,
+	imageprocessor.CategoryWatermarked,
+}
+
+// Settings are the overrides of one tenant. Zero values keep the global behavior.
+type Settings struct {
+	// ReviewThreshold replaces verification.review_threshold when set.
+	ReviewThreshold *float32 `json:"review_threshold,omitempty"`
+	// RejectThreshold replaces verification.reject_threshold when set.
+	RejectThreshold *float32 `json:"reject_threshold,omitempty"`
+	// CategoryThresholds replace the thresholds of the categories they name.
+	CategoryThresholds map[string]float32 `json:"category_thresholds,omitempty"`
+	// RetentionDays keeps the tenant's verification logs for this many days instead
+	// of cron.purge_logs.retention. It needs the purge job to be scheduled.
+	RetentionDays int `json:"retention_days,omitempty"`
+	// AllowedContentTypes narrows the accepted upload types; empty accepts every
+	// supported type.
+	AllowedContentTypes []string `json:"allowed_content_types,omitempty"`
+	// MonthlyQuota caps the tenant's verifications per calendar month (UTC); 0 is
+	// unlimited.
+	MonthlyQuota int64 `json:"monthly_quota,omitempty"`
+}
+
+// Tenant is a tenant and its settings. UpdatedAt is absent for tenants without
+// saved settings.
+type Tenant struct {
+	ID        string     `json:"id"`
+	Settings  Settings   `json:"settings"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Page is one page of tenants ordered by ID. NextCursor is empty on the last page.
+type Page struct {
+	Tenants    []*Tenant `json:"tenants"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// Options tunes the store.
+type Options struct {
+	// CacheTTL bounds how long a replica serves settings from Redis after another
+	// replica changed them.
+	CacheTTL time.Duration
+}
+
+// DefaultOptions returns the tunables used by NewStore.
+func DefaultOptions() Options {
+	return Options{CacheTTL: time.Minute}
+}
+
+// Store reads and writes tenant settings. It implements usecase.TenantPolicies.
+type Store struct {
+	repo   *repository.TenantRepository
+	redis  *redis.Client
+	logger *zap.Logger
+	opts   Options
+	now    func() time.Time
+}
+
+var _ usecase.TenantPolicies = (*Store)(nil)
+
+// NewStore returns a store with DefaultOptions.
+func NewStore(repo *repository.TenantRepository, client *redis.Client, logger *zap.Logger) *Store {
+	return NewStoreWithOptions(repo, client, logger, DefaultOptions())
+}
+
+// NewStoreWithOptions returns a store with explicit tunables.
+func NewStoreWithOptions(repo *repository.TenantRepository, client *redis.Client, logger *zap.Logger, opts Options) *Store {
+	return &Store{repo: repo, redis: client, logger: logger.Named("tenants"), opts: opts, now: time.Now}
+}
+
+// ValidateID returns ErrInvalidTenant for IDs no tenant can have.
+func ValidateID(tenantID string) error {
+	if !tenantPattern.MatchString(tenantID) {
+		return ErrInvalidTenant
+	}
+	return nil
+}
+
+// Get returns a tenant. Unknown tenants are returned with empty settings.
+func (s *Store) Get(ctx context.Context, tenantID string) (*Tenant, error) {
+	if err := ValidateID(tenantID); err != nil {
+		return nil, err
+	}
+	row, err := s.repo.Find(ctx, tenantID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &Tenant{ID: tenantID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(row)
+}
+
+// Settings returns the settings of a tenant from the cache, loading them on a miss.
+// Redis failures fall back to the database.
+func (s *Store) Settings(ctx context.Context, tenantID string) (*Settings, error) {
+	key := cacheKey(tenantID)
+	if cached, err := s.redis.Get(ctx, key).Result(); err == nil {
+		var settings Settings
+		if err := json.Unmarshal([]byte(cached), &settings); err == nil {
+			return &settings, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		s.logger.Warn("failed to read cached tenant settings", zap.String("tenant_id", tenantID), zap.Error(err))
+	}
+
+	tenant, err := s.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	// Tenants without settings are cached too, so their requests do not reach the
+	// database either.
+	if encoded, err := json.Marshal(tenant.Settings); err == nil {
+		if err := s.redis.Set(ctx, key, encoded, s.opts.CacheTTL).Err(); err != nil {
+			s.logger.Warn("failed to cache tenant settings", zap.String("tenant_id", tenantID), zap.Error(err))
+		}
+	}
+	return &tenant.Settings, nil
+}
+
+// Put validates and replaces the settings of a tenant.
+func (s *Store) Put(ctx context.Context, tenantID string, settings Settings) (*Tenant, error) {
+	if err := ValidateID(tenantID); err != nil {
+		return nil, err
+	}
+	if err := settings.normalize(); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	row := &repository.TenantSettings{TenantID: tenantID, Settings: string(encoded)}
+	if err := s.repo.Save(ctx, row); err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, tenantID)
+	updatedAt := row.UpdatedAt
+	return &Tenant{ID: tenantID, Settings: settings, UpdatedAt: &updatedAt}, nil
+}
+
+// Delete drops the settings of a tenant, returning it to the global behavior. It
+// reports whether the tenant had settings.
+func (s *Store) Delete(ctx context.Context, tenantID string) (bool, error) {
+	if err := ValidateID(tenantID); err != nil {
+		return false, err
+	}
+	deleted, err := s.repo.Delete(ctx, tenantID)
+	if err != nil {
+		return false, err
+	}
+	s.invalidate(ctx, tenantID)
+	return deleted, nil
+}
+
+func (s *Store) invalidate(ctx context.Context, tenantID string) {
+	if err := s.redis.Del(ctx, cacheKey(tenantID)).Err(); err != nil {
+		s.logger.Warn("failed to invalidate cached tenant settings; replicas serve the old settings until they expire",
+			zap.String("tenant_id", tenantID), zap.Error(err))
+	}
+}
+
+// List returns up to limit tenants with saved settings and IDs after cursor.
+func (s *Store) List(ctx context.Context, cursor string, limit int) (*Page, error) {
+	// One extra row tells whether another page follows.
+	rows, err := s.repo.List(ctx, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page{Tenants: make([]*Tenant, 0, len(rows))}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		page.NextCursor = rows[limit-1].TenantID
+	}
+	for _, row := range rows {
+		tenant, err := decode(row)
+		if err != nil {
+			return nil, err
+		}
+		page.Tenants = append(page.Tenants, tenant)
+	}
+	return page, nil
+}
+
+// Retentions returns the retention of every tenant that overrides it.
+func (s *Store) Retentions(ctx context.Context) (map[string]time.Duration, error) {
+	retentions := make(map[string]time.Duration)
+	cursor := ""
+	for {
+		rows, err := s.repo.List(ctx, cursor, listBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			tenant, err := decode(row)
+			if err != nil {
+				return nil, err
+			}
+			if days := tenant.Settings.RetentionDays; days > 0 {
+				retentions[tenant.ID] = time.Duration(days) * 24 * time.Hour
+			}
+		}
+		if len(rows) < listBatchSize {
+			return retentions, nil
+		}
+		cursor = rows[len(rows)-1].TenantID
+	}
+}
+
+// Policy implements usecase.TenantPolicies for the tenant of the authenticated
+// caller. Callers without a tenant get no policy.
+func (s *Store) Policy(ctx context.Context) (*usecase.TenantPolicy, error) {
+	tenantID, ok := auth.GetTenantID(ctx)
+	if !ok {
+		return nil, nil
+	}
+	settings, err := s.Settings(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return &usecase.TenantPolicy{
+		TenantID:           tenantID,
+		ReviewThreshold:    settings.ReviewThreshold,
+		RejectThreshold:    settings.RejectThreshold,
+		CategoryThresholds: settings.CategoryThresholds,
+	}, nil
+}
+
+// CheckUpload returns ErrContentTypeNotAllowed when the tenant does not accept
+// uploads of contentType, which may carry parameters, and ErrQuotaExceeded when it
+// used up its monthly quota.
+func (s *Store) CheckUpload(ctx context.Context, tenantID, contentType string) error {
+	settings, err := s.Settings(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if len(settings.AllowedContentTypes) > 0 && !contains(settings.AllowedContentTypes, mediaType(contentType)) {
+		return ErrContentTypeNotAllowed
+	}
+	if settings.MonthlyQuota > 0 {
+		now := s.now().UTC()
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		used, err := s.repo.CountVerificationsSince(ctx, tenantID, monthStart)
+		if err != nil {
+			return err
+		}
+		if used >= settings.MonthlyQuota {
+			return ErrQuotaExceeded
+		}
+	}
+	return nil
+}
+
+// normalize checks the ranges of the settings and canonicalizes content types.
+func (s *Settings) normalize() error {
+	if s.ReviewThreshold != nil && (*s.ReviewThreshold < 0 || *s.ReviewThreshold > 1) {
+		return fmt.Errorf("%w: review_threshold must be between 0 and 1", ErrInvalidSettings)
+	}
+	if s.RejectThreshold != nil && (*s.RejectThreshold < 0 || *s.RejectThreshold > 1) {
+		return fmt.Errorf("%w: reject_threshold must be between 0 and 1", ErrInvalidSettings)
+	}
+	for category, threshold := range s.CategoryThresholds {
+		if !contains(categories, category) {
+			return fmt.Errorf("%w: unknown category %q, expected one of %s", ErrInvalidSettings, category, strings.Join(categories, ", "))
+		}
+		if threshold < 0 || threshold > 1 {
+			return fmt.Errorf("%w: category_thresholds.%s must be between 0 and 1", ErrInvalidSettings, category)
+		}
+	}
+	if s.RetentionDays < 0 {
+		return fmt.Errorf("%w: retention_days must not be negative", ErrInvalidSettings)
+	}
+	if s.MonthlyQuota < 0 {
+		return fmt.Errorf("%w: monthly_quota must not be negative", ErrInvalidSettings)
+	}
+	types := make([]string, 0, len(s.AllowedContentTypes))
+	for _, contentType := range s.AllowedContentTypes {
+		normalized := mediaType(contentType)
+		if !strings.HasPrefix(normalized, "image/") {
+			return fmt.Errorf("%w: allowed_content_types must be image types, got %q", ErrInvalidSettings, contentType)
+		}
+		if !contains(types, normalized) {
+			types = append(types, normalized)
+		}
+	}
+	s.AllowedContentTypes = types
+	if len(s.AllowedContentTypes) == 0 {
+		s.AllowedContentTypes = nil
+	}
+	if len(s.CategoryThresholds) == 0 {
+		s.CategoryThresholds = nil
+	}
+	return nil
+}
+
+func decode(row *repository.TenantSettings) (*Tenant, error) {
+	tenant := &Tenant{ID: row.TenantID}
+	if err := json.Unmarshal([]byte(row.Settings), &tenant.Settings); err != nil {
+		return nil, fmt.Errorf("decode settings of tenant %s: %w", row.TenantID, err)
+	}
+	updatedAt := row.UpdatedAt
+	tenant.UpdatedAt = &updatedAt
+	return tenant, nil
+}
+
+func cacheKey(tenantID string) string {
+	return "tenant:" + tenantID + ":settings"
+}
+
+// mediaType lowercases a content type and strips its parameters.
+func mediaType(contentType string) string {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if idx := strings.Index(contentType, ";"); idx != -1 {
+		contentType = strings.TrimSpace(contentType[:idx])
+	}
+	return contentType
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
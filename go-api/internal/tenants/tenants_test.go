@@ -0,0 +1,207 @@
+package tenants
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/repository"
+)
+
+func newTestStore(t *testing.T) (*Store, *repository.VerificationRepository) {
+	t.Helper()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	logs := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := logs.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	repo := repository.NewTenantRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	server, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return NewStore(repo, client, zap.NewNop()), logs
+}
+
+func float32Ptr(v float32) *float32 {
+	return &v
+}
+
+func TestPutValidatesAndNormalizesSettings(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	invalid := []Settings{
+		{ReviewThreshold: float32Ptr(1.5)},
+		{CategoryThresholds: map[string]float32{"violence": 0.5}},
+		{CategoryThresholds: map[string]float32{"nsfw": -0.1}},
+		{RetentionDays: -1},
+		{MonthlyQuota: -1},
+		{AllowedContentTypes: []string{"application/pdf"}},
+	}
+	for _, settings := range invalid {
+		if _, err := store.Put(ctx, "acme", settings); !errors.Is(err, ErrInvalidSettings) {
+			t.Fatalf("expected ErrInvalidSettings for %+v, got %v", settings, err)
+		}
+	}
+	if _, err := store.Put(ctx, "acme corp", Settings{}); !errors.Is(err, ErrInvalidTenant) {
+		t.Fatalf("expected ErrInvalidTenant, got %v", err)
+	}
+
+	tenant, err := store.Put(ctx, "acme", Settings{
+		ReviewThreshold:     float32Ptr(0.9),
+		AllowedContentTypes: []string{"Image/PNG", "image/png; charset=binary", "image/jpeg"},
+	})
+	if err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	if got := tenant.Settings.AllowedContentTypes; len(got) != 2 || got[0] != "image/png" || got[1] != "image/jpeg" {
+		t.Fatalf("expected normalized content types, got %v", got)
+	}
+	loaded, err := store.Get(ctx, "acme")
+	if err != nil || loaded.UpdatedAt == nil || *loaded.Settings.ReviewThreshold != 0.9 {
+		t.Fatalf("expected the saved settings, got %+v (%v)", loaded, err)
+	}
+}
+
+func TestSettingsAreCachedAndInvalidatedOnChange(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	settings, err := store.Settings(ctx, "acme")
+	if err != nil || settings.MonthlyQuota != 0 {
+		t.Fatalf("expected empty settings for an unknown tenant, got %+v (%v)", settings, err)
+	}
+	if exists, _ := store.redis.Exists(ctx, cacheKey("acme")).Result(); exists != 1 {
+		t.Fatal("expected the settings of unknown tenants to be cached")
+	}
+
+	if _, err := store.Put(ctx, "acme", Settings{MonthlyQuota: 10}); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	if settings, _ := store.Settings(ctx, "acme"); settings.MonthlyQuota != 10 {
+		t.Fatalf("expected Put to invalidate the cache, got %+v", settings)
+	}
+
+	// Changes made by another replica apply once the cached copy expires.
+	if err := store.repo.Save(ctx, &repository.TenantSettings{TenantID: "acme", Settings: `{"monthly_quota":20}`}); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if settings, _ := store.Settings(ctx, "acme"); settings.MonthlyQuota != 10 {
+		t.Fatalf("expected the cached settings, got %+v", settings)
+	}
+	store.redis.Del(ctx, cacheKey("acme"))
+	if settings, _ := store.Settings(ctx, "acme"); settings.MonthlyQuota != 20 {
+		t.Fatalf("expected the stored settings after expiry, got %+v", settings)
+	}
+
+	if deleted, err := store.Delete(ctx, "acme"); err != nil || !deleted {
+		t.Fatalf("expected the settings to be deleted, got %v (%v)", deleted, err)
+	}
+	if settings, _ := store.Settings(ctx, "acme"); settings.MonthlyQuota != 0 {
+		t.Fatalf("expected Delete to invalidate the cache, got %+v", settings)
+	}
+	if deleted, err := store.Delete(ctx, "acme"); err != nil || deleted {
+		t.Fatalf("expected nothing left to delete, got %v (%v)", deleted, err)
+	}
+}
+
+func TestCheckUploadEnforcesContentTypesAndQuota(t *testing.T) {
+	store, logs := newTestStore(t)
+	ctx := context.Background()
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	if err := store.CheckUpload(ctx, "acme", "image/webp"); err != nil {
+		t.Fatalf("expected tenants without settings to accept uploads, got %v", err)
+	}
+	if _, err := store.Put(ctx, "acme", Settings{AllowedContentTypes: []string{"image/png"}, MonthlyQuota: 2}); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	if err := store.CheckUpload(ctx, "acme", "image/webp"); !errors.Is(err, ErrContentTypeNotAllowed) {
+		t.Fatalf("expected ErrContentTypeNotAllowed, got %v", err)
+	}
+
+	for i, createdAt := range []time.Time{now.AddDate(0, -1, 0), now.Add(-time.Hour), now.Add(-2 * time.Hour)} {
+		err := logs.SaveLog(ctx, &repository.VerificationLog{
+			RequestID: string(rune('a' + i)),
+			UserID:    "alice",
+			TenantID:  "acme",
+			SHA1Hash:  string(rune('a' + i)),
+			CreatedAt: createdAt,
+		})
+		if err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+		if i == 1 {
+			if err := store.CheckUpload(ctx, "acme", "image/png; charset=binary"); err != nil {
+				t.Fatalf("expected last month's verifications not to count, got %v", err)
+			}
+		}
+	}
+	if err := store.CheckUpload(ctx, "acme", "image/png"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+}
+
+func TestPolicyFollowsTheCallersTenant(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+	if _, err := store.Put(ctx, "acme", Settings{ReviewThreshold: float32Ptr(0.8), CategoryThresholds: map[string]float32{"nsfw": 0.3}}); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+
+	if policy, err := store.Policy(auth.WithUserID(ctx, "alice")); err != nil || policy != nil {
+		t.Fatalf("expected no policy without a tenant, got %+v (%v)", policy, err)
+	}
+	policy, err := store.Policy(auth.WithTenantID(ctx, "acme"))
+	if err != nil {
+		t.Fatalf("Policy returned error: %v", err)
+	}
+	if policy.TenantID != "acme" || *policy.ReviewThreshold != 0.8 || policy.CategoryThresholds["nsfw"] != 0.3 {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+}
+
+func TestListAndRetentions(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+	for _, id := range []string{"c", "a", "b"} {
+		settings := Settings{RetentionDays: map[string]int{"b": 1, "c": 2}[id]}
+		if _, err := store.Put(ctx, id, settings); err != nil {
+			t.Fatalf("Put returned error: %v", err)
+		}
+	}
+
+	page, err := store.List(ctx, "", 2)
+	if err != nil || len(page.Tenants) != 2 || page.Tenants[0].ID != "a" || page.NextCursor != "b" {
+		t.Fatalf("unexpected first page %+v (%v)", page, err)
+	}
+	page, err = store.List(ctx, page.NextCursor, 2)
+	if err != nil || len(page.Tenants) != 1 || page.Tenants[0].ID != "c" || page.NextCursor != "" {
+		t.Fatalf("unexpected last page %+v (%v)", page, err)
+	}
+
+	retentions, err := store.Retentions(ctx)
+	if err != nil {
+		t.Fatalf("Retentions returned error: %v", err)
+	}
+	if len(retentions) != 2 || retentions["b"] != 24*time.Hour || retentions["c"] != 48*time.Hour {
+		t.Fatalf("expected the retentions of b and c only, got %v", retentions)
+	}
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// spanInstanceKey keeps the span of a statement between its gorm callbacks.
+const spanInstanceKey = "tracing:span"
+
+// GormPlugin returns a gorm plugin tracing every statement of the database it is
+// used on with a span holding its SQL, with the parameters left out.
+func (t *Tracer) GormPlugin() gorm.Plugin {
+	return gormPlugin{tracer: t}
+}
+
+type gormPlugin struct {
+	tracer *Tracer
+}
+
+func (gormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p gormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("tracing:before_create", p.before("db.create")),
+		callbacks.Create().After("gorm:create").Register("tracing:after_create", p.after),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_query", p.before("db.query")),
+		callbacks.Query().After("gorm:query").Register("tracing:after_query", p.after),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", p.before("db.update")),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", p.after),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", p.before("db.delete")),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", p.after),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", p.before("db.row")),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", p.after),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", p.before("db.raw")),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", p.after),
+	)
+}
+
+func (p gormPlugin) before(name string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		if tx.Statement.Context == nil {
+			return
+		}
+		_, span := p.tracer.Start(tx.Statement.Context, name, KindClient)
+		tx.InstanceSet(spanInstanceKey, span)
+	}
+}
+
+func (gormPlugin) after(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(spanInstanceKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(*Span)
+	if !ok {
+		return
+	}
+	span.SetAttribute("db.system", tx.Dialector.Name())
+	span.SetAttribute("db.statement", tx.Statement.SQL.String())
+	if tx.Statement.Table != "" {
+		span.SetAttribute("db.sql.table", tx.Statement.Table)
+	}
+	span.SetAttribute("db.rows_affected", tx.Statement.RowsAffected)
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(tx.Error)
+	}
+	span.End()
+}
@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryClientInterceptor traces unary gRPC calls and propagates their trace
+// context to the server.
+func (t *Tracer) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := t.startCall(ctx, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		endCall(span, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor traces streaming gRPC calls, until their final status is
+// received, and propagates their trace context to the server.
+func (t *Tracer) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := t.startCall(ctx, method)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			endCall(span, err)
+			return nil, err
+		}
+		return &tracedStream{ClientStream: stream, serverStreams: desc.ServerStreams, span: span}, nil
+	}
+}
+
+func (t *Tracer) startCall(ctx context.Context, method string) (context.Context, *Span) {
+	ctx, span := t.Start(ctx, strings.TrimPrefix(method, "/"), KindClient)
+	span.SetAttribute("rpc.system", "grpc")
+	span.SetAttribute("rpc.method", method)
+	return metadata.AppendToOutgoingContext(ctx, TraceparentHeader, span.Context().Traceparent()), span
+}
+
+func endCall(span *Span, err error) {
+	code := status.Code(err)
+	span.SetAttribute("rpc.grpc.status_code", int64(code))
+	if err != nil {
+		span.SetError(code.String())
+	}
+	span.End()
+}
+
+// tracedStream ends the span of a stream once: when receiving fails or ends, or
+// after the single response of a stream the server does not stream.
+type tracedStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	once          sync.Once
+	span          *Span
+}
+
+func (s *tracedStream) RecvMsg(msg interface{}) error {
+	err := s.ClientStream.RecvMsg(msg)
+	if err != nil || !s.serverStreams {
+		final := err
+		if errors.Is(final, io.EOF) {
+			final = nil
+		}
+		s.once.Do(func() { endCall(s.span, final) })
+	}
+	return err
+}
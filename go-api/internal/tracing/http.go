@@ -0,0 +1,36 @@
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware traces the requests of a gin router, continuing the trace of callers
+// that send a traceparent header. Spans are named by method and route pattern.
+// Mount it after middleware.RequestID, so spans hold the request ID, and before
+// recovery, so requests that panic are traced as failed.
+func (t *Tracer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if parent, ok := ParseTraceparent(c.GetHeader(TraceparentHeader)); ok {
+			ctx = ContextWithRemoteParent(ctx, parent)
+		}
+		ctx, span := t.Start(ctx, c.Request.Method, KindServer)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		if route := c.FullPath(); route != "" {
+			span.SetName(c.Request.Method + " " + route)
+			span.SetAttribute("http.route", route)
+		}
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetError(strconv.Itoa(status) + " " + http.StatusText(status))
+		}
+	}
+}
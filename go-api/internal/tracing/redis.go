@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisHook returns a go-redis hook tracing every command and pipeline of the
+// client it is added to.
+func (t *Tracer) RedisHook() redis.Hook {
+	return redisHook{tracer: t}
+}
+
+type redisHook struct {
+	tracer *Tracer
+}
+
+func (h redisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, span := h.tracer.Start(ctx, "redis."+cmd.Name(), KindClient)
+	span.SetAttribute("db.system", "redis")
+	span.SetAttribute("db.operation", cmd.Name())
+	return ctx, nil
+}
+
+func (redisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endRedisSpan(ctx, cmd.Err())
+	return nil
+}
+
+func (h redisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx, span := h.tracer.Start(ctx, "redis.pipeline", KindClient)
+	span.SetAttribute("db.system", "redis")
+	span.SetAttribute("db.redis.commands", len(cmds))
+	return ctx, nil
+}
+
+func (redisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil && !errors.Is(cmdErr, redis.Nil) {
+			err = cmdErr
+			break
+		}
+	}
+	endRedisSpan(ctx, err)
+	return nil
+}
+
+// endRedisSpan ends the span of the hook's Before call. Missing keys are answers,
+// not failures.
+func endRedisSpan(ctx context.Context, err error) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,291 @@
+// Package tracing records distributed traces of requests as they cross the HTTP
+// API, the database, Redis and the image processor, and exports them to an
+// OpenTelemetry collector over OTLP/HTTP. Trace context is propagated in the W3C
+// traceparent header, so the processor's spans join the API's.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/logging"
+)
+
+// Kind is the role of a span in a call, as numbered by OTLP.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// AttributeRequestID is the span attribute holding the X-Request-ID of the request
+// a span belongs to.
+const AttributeRequestID = "request_id"
+
+// TraceID and SpanID identify traces and spans.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+// SpanContext is the part of a span that is propagated to other services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// Valid reports whether the trace and span IDs are set.
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Options tunes a Tracer.
+type Options struct {
+	// ServiceName is the service.name of the exported spans.
+	ServiceName string
+	// Endpoint is the base URL of the collector's OTLP/HTTP receiver; spans are
+	// posted to its /v1/traces.
+	Endpoint string
+	// Headers are sent with every export, e.g. to authenticate with the collector.
+	Headers map[string]string
+	// SampleRatio is the share of traces started here that are recorded, from 0 to
+	// 1. Traces continued from a caller follow the caller's decision.
+	SampleRatio float64
+	// BatchSize is the most spans sent in one export.
+	BatchSize int
+	// ExportInterval is how long ended spans wait for a batch to fill.
+	ExportInterval time.Duration
+	// QueueSize bounds the ended spans waiting to be exported; spans ending while
+	// it is full are dropped.
+	QueueSize int
+	// Timeout bounds one export.
+	Timeout time.Duration
+}
+
+// DefaultOptions returns the options used by New.
+func DefaultOptions() Options {
+	return Options{
+		ServiceName:    "ai-check",
+		Endpoint:       "http://localhost:4318",
+		SampleRatio:    1,
+		BatchSize:      512,
+		ExportInterval: 5 * time.Second,
+		QueueSize:      4096,
+		Timeout:        10 * time.Second,
+	}
+}
+
+// Tracer starts spans and exports the sampled ones in the background. It is safe
+// for concurrent use.
+type Tracer struct {
+	opts     Options
+	exporter *exporter
+	logger   *zap.Logger
+	now      func() time.Time
+}
+
+// New returns a tracer exporting with DefaultOptions to endpoint. Call Shutdown to
+// export the spans still queued.
+func New(endpoint string, logger *zap.Logger) *Tracer {
+	opts := DefaultOptions()
+	opts.Endpoint = endpoint
+	return NewWithOptions(opts, logger)
+}
+
+// NewWithOptions returns a tracer with explicit options.
+func NewWithOptions(opts Options, logger *zap.Logger) *Tracer {
+	logger = logger.Named("tracing")
+	return &Tracer{opts: opts, exporter: newExporter(opts, logger), logger: logger, now: time.Now}
+}
+
+// Shutdown exports the queued spans and stops exporting, waiting until ctx is done
+// at most. Spans ending afterwards are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.exporter.shutdown(ctx)
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// ContextWithRemoteParent returns ctx continuing the trace of a caller, whose span
+// becomes the parent of the spans started from it.
+func ContextWithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, parent)
+}
+
+// SpanFromContext returns the span started last in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// spanContextFromContext returns the context of the span to parent new spans on.
+func spanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.context
+	}
+	parent, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return parent
+}
+
+// Start starts a span named name, a child of the span in ctx or of the remote
+// parent it continues, and returns it with a context carrying it. The span holds
+// the request ID of ctx, if any. End it when the work it measures is done.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	parent := spanContextFromContext(ctx)
+	span := &Span{tracer: t, name: name, kind: kind, start: t.now()}
+	if parent.Valid() {
+		span.context.TraceID = parent.TraceID
+		span.context.Sampled = parent.Sampled
+		span.parent = parent.SpanID
+	} else {
+		span.context.TraceID = newTraceID()
+		span.context.Sampled = t.sample(span.context.TraceID)
+	}
+	span.context.SpanID = newSpanID()
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		span.SetAttribute(AttributeRequestID, requestID)
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// sample decides from the trace ID, so every service sampling the same ratio keeps
+// the same traces.
+func (t *Tracer) sample(id TraceID) bool {
+	switch {
+	case t.opts.SampleRatio >= 1:
+		return true
+	case t.opts.SampleRatio <= 0:
+		return false
+	}
+	return binary.BigEndian.Uint64(id[8:]) < uint64(t.opts.SampleRatio*math.MaxUint64)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// statusError is the OTLP status code of failed spans.
+const statusError = 2
+
+// attribute is a span attribute; value is a string, bool, int64 or float64.
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// Span is one timed operation of a trace. Spans that are not sampled are still
+// propagated but never exported. Its methods are safe for concurrent use.
+type Span struct {
+	tracer  *Tracer
+	context SpanContext
+	parent  SpanID
+	kind    Kind
+	start   time.Time
+
+	mu            sync.Mutex
+	name          string
+	end           time.Time
+	attributes    []attribute
+	status        int
+	statusMessage string
+	ended         bool
+}
+
+// Context returns the span's propagated context.
+func (s *Span) Context() SpanContext {
+	return s.context
+}
+
+// SetName renames the span, e.g. once the route of a request is known.
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttribute records key on the span. Values other than strings, bools, integers
+// and floats are dropped.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string, bool, int64, float64:
+	case int:
+		value = int64(v)
+	case uint:
+		value = int64(v)
+	case int32:
+		value = int64(v)
+	case uint32:
+		value = int64(v)
+	case float32:
+		value = float64(v)
+	default:
+		return
+	}
+	if !s.context.Sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.attributes {
+		if s.attributes[i].key == key {
+			s.attributes[i].value = value
+			return
+		}
+	}
+	s.attributes = append(s.attributes, attribute{key: key, value: value})
+}
+
+// RecordError marks the span failed with err.
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.SetError(err.Error())
+}
+
+// SetError marks the span failed with message.
+func (s *Span) SetError(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = statusError
+	s.statusMessage = message
+}
+
+// End ends the span and queues it for export if it is sampled. Later calls do
+// nothing.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = s.tracer.now()
+	s.mu.Unlock()
+	if s.context.Sampled {
+		s.tracer.exporter.enqueue(s)
+	}
+}
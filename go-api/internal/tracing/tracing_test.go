@@ -0,0 +1,192 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/logging"
+)
+
+// collector receives OTLP/HTTP JSON exports.
+type collector struct {
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer token" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, resource := range req.ResourceSpans {
+		for _, scope := range resource.ScopeSpans {
+			c.spans = append(c.spans, scope.Spans...)
+		}
+	}
+}
+
+func (c *collector) byName() map[string]otlpSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	spans := make(map[string]otlpSpan)
+	for _, span := range c.spans {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func attributeValue(span otlpSpan, key string) string {
+	for _, attr := range span.Attributes {
+		if attr.Key != key {
+			continue
+		}
+		switch {
+		case attr.Value.StringValue != nil:
+			return *attr.Value.StringValue
+		case attr.Value.IntValue != nil:
+			return *attr.Value.IntValue
+		}
+	}
+	return ""
+}
+
+func newTestTracer(t *testing.T, endpoint string) *Tracer {
+	t.Helper()
+	opts := DefaultOptions()
+	opts.Endpoint = endpoint
+	opts.Headers = map[string]string{"Authorization": "Bearer token"}
+	opts.ExportInterval = time.Hour
+	return NewWithOptions(opts, zap.NewNop())
+}
+
+func TestRequestsAreTracedAcrossTheDatabaseAndRedis(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	received := &collector{}
+	server := httptest.NewServer(received)
+	defer server.Close()
+	tracer := newTestTracer(t, server.URL)
+
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	if err := db.Use(tracer.GormPlugin()); err != nil {
+		t.Fatalf("Use returned error: %v", err)
+	}
+	redisServer := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	defer client.Close()
+	client.AddHook(tracer.RedisHook())
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), "req-1"))
+	}, tracer.Middleware())
+	router.GET("/result/:id", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var one int
+		db.WithContext(ctx).Raw("SELECT 1").Scan(&one)
+		client.Get(ctx, "missing")
+		c.Status(http.StatusServiceUnavailable)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/result/42", nil)
+	req.Header.Set(TraceparentHeader, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned error: %v", err)
+	}
+	spans := received.byName()
+	request, ok := spans["GET /result/:id"]
+	if !ok {
+		t.Fatalf("expected a span of the request, got %+v", spans)
+	}
+	if request.TraceID != "0af7651916cd43dd8448eb211c80319c" || request.ParentSpanID != "b7ad6b7169203331" || request.Kind != KindServer {
+		t.Fatalf("expected the request to continue the caller's trace, got %+v", request)
+	}
+	if request.Status.Code != statusError || attributeValue(request, "http.status_code") != "503" || attributeValue(request, AttributeRequestID) != "req-1" {
+		t.Fatalf("expected a failed request span with its request ID, got %+v", request)
+	}
+	for _, name := range []string{"db.row", "redis.get"} {
+		span, ok := spans[name]
+		if !ok || span.TraceID != request.TraceID || span.ParentSpanID != request.SpanID || attributeValue(span, AttributeRequestID) != "req-1" {
+			t.Fatalf("expected a %s span under the request, got %+v", name, span)
+		}
+	}
+	if statement := attributeValue(spans["db.row"], "db.statement"); statement != "SELECT 1" {
+		t.Fatalf("expected the SQL on the database span, got %q", statement)
+	}
+	if spans["redis.get"].Status.Code != 0 {
+		t.Fatal("expected a missing key not to fail the Redis span")
+	}
+}
+
+func TestClientInterceptorPropagatesTheTraceContext(t *testing.T) {
+	tracer := newTestTracer(t, "http://127.0.0.1:1")
+	defer tracer.Shutdown(context.Background())
+	ctx, parent := tracer.Start(context.Background(), "parent", KindInternal)
+
+	var sent SpanContext
+	err := tracer.UnaryClientInterceptor()(ctx, "/verify.ImageProcessor/ProcessImage", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			if values := md.Get(TraceparentHeader); len(values) == 1 {
+				sent, _ = ParseTraceparent(values[0])
+			}
+			return status.Error(codes.Unavailable, "down")
+		})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected the call's error, got %v", err)
+	}
+	if sent.TraceID != parent.Context().TraceID || sent.SpanID == parent.Context().SpanID || !sent.Sampled {
+		t.Fatalf("expected the call's span in the same trace, got %+v", sent)
+	}
+}
+
+func TestSamplingFollowsTheRatioAndTheCaller(t *testing.T) {
+	opts := DefaultOptions()
+	opts.SampleRatio = 0
+	tracer := NewWithOptions(opts, zap.NewNop())
+	defer tracer.Shutdown(context.Background())
+
+	ctx, span := tracer.Start(context.Background(), "unsampled", KindServer)
+	if span.Context().Sampled {
+		t.Fatal("expected a new trace not to be sampled with a zero ratio")
+	}
+	if _, child := tracer.Start(ctx, "child", KindClient); child.Context().Sampled || child.Context().TraceID != span.Context().TraceID {
+		t.Fatalf("expected the child to follow its parent, got %+v", child.Context())
+	}
+	remote, ok := ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	if !ok {
+		t.Fatal("expected a valid traceparent")
+	}
+	if _, continued := tracer.Start(ContextWithRemoteParent(context.Background(), remote), "continued", KindServer); !continued.Context().Sampled {
+		t.Fatal("expected a trace sampled by the caller to be recorded")
+	}
+	for _, value := range []string{"", "00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01", "ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "00-xyz-b7ad6b7169203331-01"} {
+		if _, ok := ParseTraceparent(value); ok {
+			t.Fatalf("expected %q to be rejected", value)
+		}
+	}
+	if got := remote.Traceparent(); got != "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01" {
+		t.Fatalf("expected the traceparent to round-trip, got %s", got)
+	}
+}
@@ -11,18 +11,29 @@ import (
 type Cache interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
+	Delete(ctx context.Context, keys ...string) error
 }
 
 // RedisCache is a concrete implementation backed by go-redis.
 type RedisCache struct {
-	client *redis.Client
+	client redis.UniversalClient
+	// cluster deletes keys one by one, as a Redis Cluster refuses commands whose
+	// keys hash to different slots.
+	cluster bool
 }
 
-// NewRedisCache constructs a new Redis-backed cache adapter.
+// NewRedisCache constructs a new Redis-backed cache adapter. The client may be a
+// failover client following a Sentinel-managed master.
 func NewRedisCache(client *redis.Client) *RedisCache {
 	return &RedisCache{client: client}
 }
 
+// NewRedisClusterCache constructs a cache adapter spreading keys over the nodes of a
+// Redis Cluster.
+func NewRedisClusterCache(client *redis.ClusterClient) *RedisCache {
+	return &RedisCache{client: client, cluster: true}
+}
+
 // Set writes a value to Redis.
 func (c *RedisCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	return c.client.Set(ctx, key, value, expiration).Err()
@@ -32,3 +43,21 @@ func (c *RedisCache) Set(ctx context.Context, key string, value interface{}, exp
 func (c *RedisCache) Get(ctx context.Context, key string) (string, error) {
 	return c.client.Get(ctx, key).Result()
 }
+
+// Delete removes keys from Redis. Missing keys are ignored.
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if !c.cluster || len(keys) == 1 {
+		return c.client.Del(ctx, keys...).Err()
+	}
+	// The pipeline sends each node the deletions of its own keys.
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	return err
+}
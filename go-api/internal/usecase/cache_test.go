@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRedisClusterCacheDeletesKeysOfDifferentSlots(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{server.Addr()}})
+	defer client.Close()
+	cache := NewRedisClusterCache(client)
+	ctx := context.Background()
+
+	keys := []string{"verification:a", "verification:b", "tenant:acme:verification:c"}
+	for _, key := range keys {
+		if err := cache.Set(ctx, key, "value", time.Minute); err != nil {
+			t.Fatalf("Set returned error: %v", err)
+		}
+	}
+	if value, err := cache.Get(ctx, keys[0]); err != nil || value != "value" {
+		t.Fatalf("Get returned %q, %v", value, err)
+	}
+	if err := cache.Delete(ctx, keys...); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	for _, key := range keys {
+		if server.Exists(key) {
+			t.Fatalf("expected %s to be deleted", key)
+		}
+	}
+}
+
+func TestIsTransientErrorRetriesClusterAndFailoverReplies(t *testing.T) {
+	for _, reply := range []string{"MOVED 3999 127.0.0.1:6381", "ASK 3999 127.0.0.1:6381", "TRYAGAIN Multiple keys request during rehashing of slot", "CLUSTERDOWN The cluster is down", "LOADING Redis is loading the dataset in memory", "READONLY You can't write against a read only replica."} {
+		if !isTransientError(redisReply(reply)) {
+			t.Errorf("expected %q to be transient", reply)
+		}
+	}
+	for _, err := range []error{redis.Nil, redisReply("WRONGTYPE Operation against a key holding the wrong kind of value"), errors.New("MOVED elsewhere")} {
+		if isTransientError(err) {
+			t.Errorf("expected %v not to be transient", err)
+		}
+	}
+}
+
+// redisReply is an error reply of the Redis server.
+type redisReply string
+
+func (r redisReply) Error() string { return string(r) }
+
+func (redisReply) RedisError() {}
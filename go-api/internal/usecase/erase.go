@@ -0,0 +1,35 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/example/ai-check/internal/logging"
+	"github.com/example/ai-check/internal/repository"
+)
+
+// eraseBatchSize is how many verifications EraseUserData erases per transaction.
+const eraseBatchSize = 500
+
+// EraseUserData erases every verification of userID, for the right to erasure, and
+// returns how many it erased. The logs are soft-deleted, so they disappear at once
+// and are purged with their images once the erasure grace period passes. Their
+// cached results are dropped before, so none is served after the erasure.
+func (uc *VerificationUseCase) EraseUserData(ctx context.Context, userID string) (int, error) {
+	total := 0
+	for {
+		erased, err := uc.repo.SoftDeleteUserLogs(ctx, userID, eraseBatchSize, func(logs []*repository.VerificationLog) error {
+			keys := make([]string, len(logs))
+			for i, log := range logs {
+				keys[i] = uc.cacheKey(log.TenantID, log.RequestID)
+			}
+			return uc.cache.Delete(ctx, keys...)
+		})
+		if err != nil {
+			return total, logging.NewOperationError("usecase.erase_user_data", "", err)
+		}
+		total += erased
+		if erased < eraseBatchSize {
+			return total, nil
+		}
+	}
+}
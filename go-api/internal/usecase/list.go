@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+
+	"github.com/example/ai-check/internal/repository"
+)
+
+// MaxPageSize caps the verifications returned by one ListVerifications call.
+const MaxPageSize = 100
+
+// exportBatchSize is how many verifications ExportVerifications loads at a time.
+const exportBatchSize = 500
+
+// ErrInvalidCursor is returned for cursors not issued by ListVerifications,
+// GetDuplicateReport or GetUserMetrics.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// VerificationPage is one page of a user's verifications, newest first.
+type VerificationPage struct {
+	Logs []*repository.VerificationLog
+	// NextCursor continues after the last log; empty on the last page.
+	NextCursor string
+}
+
+// ListVerifications returns up to limit of the user's verifications, newest first,
+// starting after cursor. An empty cursor starts at the newest verification. Pages
+// are keyed by position, so verifications made while paging do not shift them.
+func (uc *VerificationUseCase) ListVerifications(ctx context.Context, userID, cursor string, limit int) (*VerificationPage, error) {
+	return uc.History(ctx, userID, repository.LogFilter{}, cursor, limit)
+}
+
+// History is ListVerifications narrowed to the verifications matching filter.
+// Cursors are only meaningful with the filter they were issued for.
+func (uc *VerificationUseCase) History(ctx context.Context, userID string, filter repository.LogFilter, cursor string, limit int) (*VerificationPage, error) {
+	return findPage(cursor, limit, func(beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+		return uc.repo.FindByUser(ctx, userID, filter, beforeID, limit)
+	})
+}
+
+// SearchVerifications is History across users, for administrators. filter.UserID
+// narrows it to one user.
+func (uc *VerificationUseCase) SearchVerifications(ctx context.Context, filter repository.LogFilter, cursor string, limit int) (*VerificationPage, error) {
+	return findPage(cursor, limit, func(beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+		return uc.repo.SearchLogs(ctx, filter, beforeID, limit)
+	})
+}
+
+// findPage loads the page of find starting after cursor.
+func findPage(cursor string, limit int, find func(beforeID uint, limit int) ([]*repository.VerificationLog, error)) (*VerificationPage, error) {
+	var beforeID uint
+	if cursor != "" {
+		id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		beforeID = id
+	}
+	if limit <= 0 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	// One extra log tells whether another page follows.
+	logs, err := find(beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &VerificationPage{Logs: logs}
+	if len(logs) > limit {
+		page.Logs = logs[:limit]
+		page.NextCursor = encodeCursor(page.Logs[limit-1].ID)
+	}
+	return page, nil
+}
+
+// ExportVerifications calls fn with every verification of the user, newest first,
+// one batch at a time, so callers can stream them without holding them all. It
+// stops at the first error, including one returned by fn.
+func (uc *VerificationUseCase) ExportVerifications(ctx context.Context, userID string, fn func([]*repository.VerificationLog) error) error {
+	var beforeID uint
+	for {
+		logs, err := uc.repo.ListByUser(ctx, userID, beforeID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(logs) > 0 {
+			if err := fn(logs); err != nil {
+				return err
+			}
+		}
+		if len(logs) < exportBatchSize {
+			return nil
+		}
+		beforeID = logs[len(logs)-1].ID
+	}
+}
+
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor string) (uint, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(string(decoded), 10, 0)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidCursor
+	}
+	return uint(id), nil
+}
@@ -1,6 +1,19 @@
 package usecase
 
-import "context"
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/logging"
+	"github.com/example/ai-check/internal/repository"
+)
 
 // MetricsSummary represents aggregated verification insights.
 type MetricsSummary struct {
@@ -9,25 +22,222 @@ type MetricsSummary struct {
 	SuccessRate                float64 `json:"success_rate"`
 	AverageScore               float64 `json:"average_score"`
 	AverageProcessingLatencyMs float64 `json:"average_processing_latency_ms"`
+	// Region names the region that computed the summary; empty for single-region
+	// deployments.
+	Region string `json:"region,omitempty"`
+}
+
+// cachedMetricsSummary is a MetricsSummary in the cache.
+type cachedMetricsSummary struct {
+	Summary    MetricsSummary `json:"summary"`
+	ComputedAt time.Time      `json:"computed_at"`
 }
 
-// GetMetricsSummary aggregates verification metrics from persisted logs.
+// GetMetricsSummary aggregates verification metrics from persisted logs. Summaries
+// are cached for Options.MetricsSummaryTTL. Older summaries are served for another
+// Options.MetricsSummaryStaleTTL while one background query recomputes them, and
+// concurrent callers without a cached summary share one query.
 func (uc *VerificationUseCase) GetMetricsSummary(ctx context.Context) (*MetricsSummary, error) {
+	opts := uc.currentOptions()
+	if opts.MetricsSummaryTTL <= 0 {
+		return uc.aggregateMetricsSummary(ctx)
+	}
+	ctx = uc.withRetryBudget(ctx)
+	// The key also names the shared query, so callers only share their tenant's.
+	key := uc.metricsSummaryKey(ctx)
+	if cached, err := uc.withRedisGet(ctx, "", "cache.get.metrics_summary", key); err == nil {
+		var entry cachedMetricsSummary
+		if err := json.Unmarshal([]byte(cached), &entry); err != nil {
+			logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.get_metrics_summary", "").Warn("failed to decode cached metrics summary", zap.Error(err))
+		} else {
+			if time.Since(entry.ComputedAt) >= opts.MetricsSummaryTTL {
+				// The result is not awaited, and the channel is buffered.
+				uc.summaries.DoChan(key, func() (interface{}, error) {
+					return uc.refreshMetricsSummary(context.WithoutCancel(ctx), key, opts)
+				})
+			}
+			return &entry.Summary, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.get_metrics_summary", "").Warn("failed to read cache", zap.Error(err))
+	}
+
+	// The query is shared, so it outlives a caller that goes away while others wait.
+	lookupCtx := context.WithoutCancel(ctx)
+	lookup := uc.summaries.DoChan(key, func() (interface{}, error) {
+		return uc.refreshMetricsSummary(lookupCtx, key, opts)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-lookup:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		summary := *result.Val.(*MetricsSummary)
+		return &summary, nil
+	}
+}
+
+// refreshMetricsSummary aggregates the metrics summary and caches it. Failing to
+// cache it only costs later calls a query.
+func (uc *VerificationUseCase) refreshMetricsSummary(ctx context.Context, key string, opts Options) (*MetricsSummary, error) {
+	summary, err := uc.aggregateMetricsSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	serialized, err := json.Marshal(cachedMetricsSummary{Summary: *summary, ComputedAt: time.Now().UTC()})
+	if err == nil {
+		err = uc.cache.Set(ctx, key, string(serialized), opts.MetricsSummaryTTL+opts.MetricsSummaryStaleTTL)
+	}
+	if err != nil {
+		logging.WithOperation(logging.FromContext(ctx, uc.logger), "cache.set.metrics_summary", "").Warn("failed to cache metrics summary", zap.Error(err))
+	}
+	return summary, nil
+}
+
+func (uc *VerificationUseCase) aggregateMetricsSummary(ctx context.Context) (*MetricsSummary, error) {
 	aggregation, err := uc.repo.AggregateMetrics(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	summary := &MetricsSummary{
+	summary := summarize(aggregation)
+	summary.Region = uc.region
+	return &summary, nil
+}
+
+// metricsSummaryKey names the cached metrics summary the caller reads, like
+// cacheKey: that of its tenant, or of every tenant for callers without a user, such
+// as the admin listener.
+func (uc *VerificationUseCase) metricsSummaryKey(ctx context.Context) string {
+	key := "metrics:summary"
+	if _, ok := auth.GetUserID(ctx); !ok {
+		key += ":all"
+	} else if tenantID, ok := auth.GetTenantID(ctx); ok {
+		key = "tenant:" + tenantID + ":" + key
+	}
+	if uc.region == "" {
+		return key
+	}
+	return uc.region + ":" + key
+}
+
+// MaxMetricsPeriods caps the periods of one metrics series.
+const MaxMetricsPeriods = 744
+
+// MetricsPeriod is the MetricsSummary of the verifications created in one period.
+type MetricsPeriod struct {
+	Start time.Time `json:"start"`
+	MetricsSummary
+}
+
+// MetricsSeries breaks the metrics of the verifications created in a window down
+// into periods, for dashboards.
+type MetricsSeries struct {
+	// MetricsSummary totals the whole window.
+	MetricsSummary
+	Periods []MetricsPeriod
+}
+
+// GetMetricsSeries aggregates the verifications created from from until to in
+// periods of interval, read from the hourly metrics counters. The window is widened
+// to whole hours and interval must be a whole number of hours.
+func (uc *VerificationUseCase) GetMetricsSeries(ctx context.Context, from, to time.Time, interval time.Duration) (*MetricsSeries, error) {
+	aggregations, err := uc.repo.AggregateMetricsSeries(ctx, from, to, interval)
+	if err != nil {
+		return nil, err
+	}
+	series := &MetricsSeries{Periods: make([]MetricsPeriod, 0, len(aggregations))}
+	var total repository.MetricsAggregation
+	var scoreSum, latencySumMs float64
+	for _, aggregation := range aggregations {
+		series.Periods = append(series.Periods, MetricsPeriod{Start: aggregation.Start, MetricsSummary: summarize(&aggregation.MetricsAggregation)})
+		total.TotalCount += aggregation.TotalCount
+		total.SuccessCount += aggregation.SuccessCount
+		scoreSum += aggregation.AverageScore * float64(aggregation.TotalCount)
+		latencySumMs += aggregation.AverageProcessingLatencyMs * float64(aggregation.TotalCount)
+	}
+	if total.TotalCount > 0 {
+		total.AverageScore = scoreSum / float64(total.TotalCount)
+		total.AverageProcessingLatencyMs = latencySumMs / float64(total.TotalCount)
+	}
+	series.MetricsSummary = summarize(&total)
+	series.Region = uc.region
+	return series, nil
+}
+
+// VariantMetrics is the MetricsSummary of one experiment variant.
+type VariantMetrics struct {
+	Variant string `json:"variant"`
+	MetricsSummary
+}
+
+// GetVariantMetrics compares the experiment variants that handled verifications.
+func (uc *VerificationUseCase) GetVariantMetrics(ctx context.Context) ([]VariantMetrics, error) {
+	aggregations, err := uc.repo.AggregateMetricsByVariant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metrics := make([]VariantMetrics, 0, len(aggregations))
+	for _, aggregation := range aggregations {
+		metrics = append(metrics, VariantMetrics{Variant: aggregation.Variant, MetricsSummary: summarize(&aggregation.MetricsAggregation)})
+	}
+	return metrics, nil
+}
+
+// UserMetrics is the MetricsSummary of one user.
+type UserMetrics struct {
+	UserID string `json:"user_id"`
+	MetricsSummary
+}
+
+// UserMetricsPage is one page of per-user metrics, ordered by user ID.
+type UserMetricsPage struct {
+	Users []UserMetrics
+	// NextCursor continues after the last user; empty on the last page.
+	NextCursor string
+}
+
+// GetUserMetrics breaks the metrics of the verifications matching filter down per
+// user, for administrators. It returns up to limit users starting after cursor.
+func (uc *VerificationUseCase) GetUserMetrics(ctx context.Context, filter repository.LogFilter, cursor string, limit int) (*UserMetricsPage, error) {
+	var afterUserID string
+	if cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || len(decoded) == 0 {
+			return nil, ErrInvalidCursor
+		}
+		afterUserID = string(decoded)
+	}
+	if limit <= 0 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	aggregations, err := uc.repo.AggregateMetricsByUser(ctx, filter, afterUserID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &UserMetricsPage{Users: make([]UserMetrics, 0, len(aggregations))}
+	for _, aggregation := range aggregations {
+		page.Users = append(page.Users, UserMetrics{UserID: aggregation.UserID, MetricsSummary: summarize(&aggregation.MetricsAggregation)})
+	}
+	if len(page.Users) > limit {
+		page.Users = page.Users[:limit]
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(page.Users[limit-1].UserID))
+	}
+	return page, nil
+}
+
+func summarize(aggregation *repository.MetricsAggregation) MetricsSummary {
+	summary := MetricsSummary{
 		TotalRequests:              aggregation.TotalCount,
 		SuccessfulRequests:         aggregation.SuccessCount,
 		AverageScore:               aggregation.AverageScore,
 		AverageProcessingLatencyMs: aggregation.AverageProcessingLatencyMs,
 	}
-
 	if aggregation.TotalCount > 0 {
 		summary.SuccessRate = float64(aggregation.SuccessCount) / float64(aggregation.TotalCount)
 	}
-
-	return summary, nil
+	return summary
 }
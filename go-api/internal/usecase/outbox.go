@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/example/ai-check/internal/repository"
+)
+
+// Outbox saves events that do not accompany a verification log, such as a
+// *repository.OutboxRepository. Events of a log are saved with it.
+type Outbox interface {
+	Add(ctx context.Context, events ...*repository.OutboxEvent) error
+}
+
+// NewOutboxEvent encodes event for the outbox.
+func NewOutboxEvent(event Event) (*repository.OutboxEvent, error) {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s event: %w", event.Type, err)
+	}
+	return &repository.OutboxEvent{
+		ID:        event.ID,
+		Type:      event.Type,
+		UserID:    event.UserID,
+		TenantID:  event.TenantID,
+		Payload:   string(payload),
+		CreatedAt: event.CreatedAt,
+	}, nil
+}
+
+// DecodeOutboxEvent rebuilds the event saved in record, with the data type its
+// publishers expect.
+func DecodeOutboxEvent(record *repository.OutboxEvent) (Event, error) {
+	event := Event{
+		ID:        record.ID,
+		Type:      record.Type,
+		UserID:    record.UserID,
+		TenantID:  record.TenantID,
+		CreatedAt: record.CreatedAt,
+	}
+	var err error
+	switch record.Type {
+	case EventVerificationCompleted, EventVerificationNeedsReview:
+		var data VerificationEvent
+		err = json.Unmarshal([]byte(record.Payload), &data)
+		event.Data = data
+	case EventVerificationFailed:
+		var data VerificationFailedEvent
+		err = json.Unmarshal([]byte(record.Payload), &data)
+		event.Data = data
+	default:
+		return Event{}, fmt.Errorf("unknown event type %q", record.Type)
+	}
+	if err != nil {
+		return Event{}, fmt.Errorf("decode %s event: %w", record.Type, err)
+	}
+	return event, nil
+}
@@ -0,0 +1,30 @@
+package usecase
+
+import "github.com/example/ai-check/internal/repository"
+
+// Verdicts the score policy gives completed verifications.
+const (
+	// VerdictVerified accepts the image: the processor verified it, its score
+	// reaches Options.ReviewThreshold and no category is flagged.
+	VerdictVerified = "verified"
+	// VerdictNeedsReview leaves the image to a human: the processor did not verify
+	// it, its score is below Options.ReviewThreshold or a category is flagged.
+	VerdictNeedsReview = "needs_review"
+	// VerdictRejected refuses the image outright: its score is below
+	// Options.RejectThreshold.
+	VerdictRejected = "rejected"
+)
+
+// decideVerdict applies the thresholds of opts to the calibrated score and the
+// category outcomes of log. A score below RejectThreshold rejects the image whatever
+// the processor said, so a tenant can turn away clear fakes without a review.
+func decideVerdict(log *repository.VerificationLog, opts Options) string {
+	switch {
+	case log.Score < opts.RejectThreshold:
+		return VerdictRejected
+	case !log.Success || log.Score < opts.ReviewThreshold || anyFlagged(log.Categories):
+		return VerdictNeedsReview
+	default:
+		return VerdictVerified
+	}
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// retryBudget counts the Redis retries a request has left.
+type retryBudget struct {
+	remaining atomic.Int64
+}
+
+type retryBudgetKey struct{}
+
+// withRetryBudget gives ctx the retry budget of one request, unless it already has
+// one or Options.RetryBudget is zero.
+func (uc *VerificationUseCase) withRetryBudget(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(retryBudgetKey{}).(*retryBudget); ok {
+		return ctx
+	}
+	limit := uc.currentOptions().RetryBudget
+	if limit <= 0 {
+		return ctx
+	}
+	budget := &retryBudget{}
+	budget.remaining.Store(int64(limit))
+	return context.WithValue(ctx, retryBudgetKey{}, budget)
+}
+
+// takeRetry spends one retry of the request's budget, reporting false when none is
+// left. Contexts without a budget may always retry.
+func takeRetry(ctx context.Context) bool {
+	budget, ok := ctx.Value(retryBudgetKey{}).(*retryBudget)
+	if !ok {
+		return true
+	}
+	return budget.remaining.Add(-1) >= 0
+}
+
+// jitter shortens backoff by a random share of up to fraction of it.
+func jitter(backoff time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || backoff <= 0 {
+		return backoff
+	}
+	return backoff - time.Duration(rand.Float64()*fraction*float64(backoff))
+}
@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/example/ai-check/internal/phash"
+	"github.com/example/ai-check/internal/repository"
+)
+
+const (
+	// DefaultSimilarDistance is the largest perceptual hash distance FindSimilar
+	// treats as the same picture when the caller does not choose one.
+	DefaultSimilarDistance = 10
+	// MaxSimilarResults caps the verifications returned by one FindSimilar call.
+	MaxSimilarResults = 100
+
+	similarScanBatch = 1000
+)
+
+// ErrUnsupportedImage is returned by FindSimilar for images whose format cannot be
+// hashed, such as WebP.
+var ErrUnsupportedImage = errors.New("image format does not support similarity search")
+
+// SimilarVerification is a prior verification of a visually similar image.
+type SimilarVerification struct {
+	Log *repository.VerificationLog
+	// Distance counts the differing bits of the perceptual hashes, from 0 for
+	// near-identical images to phash.Bits.
+	Distance int
+}
+
+// FindSimilar returns up to limit of the user's verifications whose images are
+// within maxDistance of imageBytes, closest first and newest first among equals.
+// Verifications made before perceptual hashes were recorded are not found.
+func (uc *VerificationUseCase) FindSimilar(ctx context.Context, userID string, imageBytes []byte, maxDistance, limit int) ([]SimilarVerification, error) {
+	if limit <= 0 || limit > MaxSimilarResults {
+		limit = MaxSimilarResults
+	}
+
+	var matches []SimilarVerification
+	err := uc.StreamSimilar(ctx, userID, imageBytes, maxDistance, func(batch []SimilarVerification) error {
+		matches = append(matches, batch...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].Log.ID > matches[j].Log.ID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// StreamSimilar calls fn with every verification of the user whose image is within
+// maxDistance of imageBytes, oldest first, as each batch of the history is scanned.
+// Unlike FindSimilar, matches are neither ranked nor capped. It stops at the first
+// error, including one returned by fn.
+func (uc *VerificationUseCase) StreamSimilar(ctx context.Context, userID string, imageBytes []byte, maxDistance int, fn func([]SimilarVerification) error) error {
+	hash, err := phash.Compute(imageBytes)
+	if err != nil {
+		return ErrUnsupportedImage
+	}
+
+	var afterID uint
+	for {
+		logs, err := uc.repo.ListHashedByUser(ctx, userID, afterID, similarScanBatch)
+		if err != nil {
+			return err
+		}
+		var matches []SimilarVerification
+		for _, log := range logs {
+			other, err := phash.Parse(log.PerceptualHash)
+			if err != nil {
+				continue
+			}
+			if distance := phash.Distance(hash, other); distance <= maxDistance {
+				matches = append(matches, SimilarVerification{Log: log, Distance: distance})
+			}
+		}
+		if len(matches) > 0 {
+			if err := fn(matches); err != nil {
+				return err
+			}
+		}
+		if len(logs) < similarScanBatch {
+			return nil
+		}
+		afterID = logs[len(logs)-1].ID
+	}
+}
@@ -1,40 +1,405 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
 
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/calibration"
+	"github.com/example/ai-check/internal/imagemeta"
 	"github.com/example/ai-check/internal/imageprocessor"
 	"github.com/example/ai-check/internal/logging"
+	"github.com/example/ai-check/internal/malwarescan"
+	"github.com/example/ai-check/internal/phash"
 	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/requestid"
+	"github.com/example/ai-check/internal/spool"
 )
 
 // VerificationRepository defines the persistence operations needed by the use case.
 type VerificationRepository interface {
 	SaveLog(ctx context.Context, log *repository.VerificationLog) error
 	FindByRequestIDAndUser(ctx context.Context, requestID, userID string) (*repository.VerificationLog, error)
-	FindDuplicatesByHash(ctx context.Context, userID, hash, excludeRequestID string) ([]*repository.VerificationLog, error)
+	FindDuplicatesByHash(ctx context.Context, userID, sha256Hash, sha1Hash, excludeRequestID string, beforeID uint, limit int) ([]*repository.VerificationLog, error)
+	CountDuplicatesByHash(ctx context.Context, userID, sha256Hash, sha1Hash, excludeRequestID string) (int64, error)
+	ListByUser(ctx context.Context, userID string, beforeID uint, limit int) ([]*repository.VerificationLog, error)
+	FindByUser(ctx context.Context, userID string, filter repository.LogFilter, beforeID uint, limit int) ([]*repository.VerificationLog, error)
+	SearchLogs(ctx context.Context, filter repository.LogFilter, beforeID uint, limit int) ([]*repository.VerificationLog, error)
+	ListHashedByUser(ctx context.Context, userID string, afterID uint, limit int) ([]*repository.VerificationLog, error)
 	AggregateMetrics(ctx context.Context) (*repository.MetricsAggregation, error)
+	AggregateMetricsByVariant(ctx context.Context) ([]*repository.VariantAggregation, error)
+	AggregateMetricsByUser(ctx context.Context, filter repository.LogFilter, afterUserID string, limit int) ([]*repository.UserAggregation, error)
+	AggregateMetricsSeries(ctx context.Context, from, to time.Time, interval time.Duration) ([]*repository.PeriodAggregation, error)
+	CompleteQueued(ctx context.Context, log *repository.VerificationLog) error
+	FailQueued(ctx context.Context, id uint, details string) error
+	SoftDeleteUserLogs(ctx context.Context, userID string, limit int, release func([]*repository.VerificationLog) error) (int, error)
+}
+
+// VariantAssigner splits verifications between processors, such as the models
+// compared by an *experiment.Experiment.
+type VariantAssigner interface {
+	Assign(userID string) (variant string, processor imageprocessor.Client)
+}
+
+// TenantPolicy is the tenant of a request and the options it overrides.
+type TenantPolicy struct {
+	TenantID string
+	// ReviewThreshold replaces Options.ReviewThreshold when set.
+	ReviewThreshold *float32
+	// RejectThreshold replaces Options.RejectThreshold when set.
+	RejectThreshold *float32
+	// CategoryThresholds replace the thresholds of the categories they name.
+	CategoryThresholds map[string]float32
+}
+
+// TenantPolicies resolves the policy of the tenant a request was made for, such as
+// a *tenants.Store.
+type TenantPolicies interface {
+	Policy(ctx context.Context) (*TenantPolicy, error)
+}
+
+// MetricsObserver counts verifications as they happen, such as a
+// *livemetrics.Recorder.
+type MetricsObserver interface {
+	ObserveVerification(tenantID string, verified bool, latency time.Duration)
+	ObserveFailure(tenantID string)
+}
+
+// Preprocessor prepares images before they are sent to the processor, such as a
+// *preprocess.Pipeline.
+type Preprocessor interface {
+	Apply(image []byte) ([]byte, error)
+}
+
+// Instrumentation records how long verifications take and how often Redis
+// operations are retried, such as a *metrics.Metrics.
+type Instrumentation interface {
+	ObserveVerifyImage(outcome string, took time.Duration)
+	ObserveRedisRetry(operation string)
+}
+
+// Outcomes of VerifyImage reported to the Instrumentation.
+const (
+	OutcomeVerified    = "verified"
+	OutcomeNotVerified = "not_verified"
+	// OutcomeRejected is an upload that could not be read or was already verified.
+	OutcomeRejected   = "rejected"
+	OutcomeOverloaded = "overloaded"
+	// OutcomeQueued is an upload accepted while the processor was unavailable.
+	OutcomeQueued = "queued"
+	// OutcomeDuplicate is an upload answered with the result of an earlier
+	// verification of the image, without calling the processor.
+	OutcomeDuplicate = "duplicate"
+	OutcomeFailed    = "failed"
+)
+
+// ConcurrencyLimiter bounds the verifications in flight from the latency of their
+// dependencies, such as an *adaptive.Limiter.
+type ConcurrencyLimiter interface {
+	Acquire() bool
+	Release()
+	Observe(dependency string, took time.Duration, err error)
+}
+
+// Dependencies whose latency is reported to the ConcurrencyLimiter.
+const (
+	DependencyProcessor = "processor"
+	DependencyDatabase  = "database"
+)
+
+// ErrOverloaded is returned by VerifyImage when the ConcurrencyLimiter sheds the
+// verification.
+var ErrOverloaded = errors.New("too many verifications in flight")
+
+// ImageStore keeps uploaded images so they can be re-verified, reviewed or audited.
+type ImageStore interface {
+	// Put stores the size bytes of body. body may be read more than once, seeking
+	// back to the start in between.
+	Put(ctx context.Context, key, contentType string, body io.ReadSeeker, size int64) error
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// LogBuffer keeps verification logs that could not be saved because the database
+// was unavailable, and saves them once it recovers.
+type LogBuffer interface {
+	Add(ctx context.Context, log *repository.VerificationLog) error
+}
+
+// DeferredQueue holds verifications accepted while the image processor was
+// unavailable until a worker completes them with ProcessQueued, such as a
+// *deferred.Queue.
+type DeferredQueue interface {
+	Defer(ctx context.Context, requestID, userID string) error
+}
+
+// ImageOpener reads stored images back. An ImageStore that implements it lets
+// verifications be queued while the image processor is unavailable.
+type ImageOpener interface {
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Event types published after a verification.
+const (
+	EventVerificationCompleted   = "verification.completed"
+	EventVerificationNeedsReview = "verification.needs_review"
+	// EventVerificationFailed is published when a verification could not be
+	// completed, e.g. because the processor was unavailable.
+	EventVerificationFailed = "verification.failed"
+)
+
+// Event is a notification about something that happened to a user's data.
+type Event struct {
+	// ID is stable for a given event, so publishing it twice is harmless.
+	ID     string
+	Type   string
+	UserID string
+	// TenantID names the tenant of the user; empty for users without one.
+	TenantID  string
+	CreatedAt time.Time
+	Data      interface{}
+}
+
+// EventPublisher receives events, e.g. to notify user webhooks.
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Publishers fans each event out to several publishers.
+type Publishers []EventPublisher
+
+// Publish implements EventPublisher, returning every publisher's error.
+func (p Publishers) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// VerificationEvent is the data of the verification events.
+type VerificationEvent struct {
+	RequestID  string  `json:"request_id"`
+	Verified   bool    `json:"verified"`
+	Score      float32 `json:"score"`
+	Message    string  `json:"message,omitempty"`
+	SHA256Hash string  `json:"sha256_hash"`
+	// SHA1Hash is deprecated in favour of SHA256Hash.
+	SHA1Hash  string    `json:"sha1_hash"`
+	CreatedAt time.Time `json:"created_at"`
+	// Categories holds the moderation outcome of every category the processor scored.
+	Categories []CategoryOutcome `json:"categories,omitempty"`
+	// Verdict is VerdictVerified, VerdictNeedsReview or VerdictRejected.
+	Verdict string `json:"verdict"`
+}
+
+// VerificationFailedEvent is the data of EventVerificationFailed.
+type VerificationFailedEvent struct {
+	RequestID string `json:"request_id"`
+	// Reason names the step that failed, e.g. "usecase.grpc_process_image". Error
+	// details stay in the service logs.
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CategoryOutcome is the moderation outcome of one category of a verification.
+type CategoryOutcome struct {
+	Category string  `json:"category"`
+	Score    float32 `json:"score"`
+	// Threshold is the score the category is flagged at; 0 when it has none.
+	Threshold float32 `json:"threshold"`
+	Flagged   bool    `json:"flagged"`
+}
+
+// CategoryOutcomes converts persisted category outcomes.
+func CategoryOutcomes(categories []repository.VerificationCategory) []CategoryOutcome {
+	outcomes := make([]CategoryOutcome, 0, len(categories))
+	for _, category := range categories {
+		outcomes = append(outcomes, CategoryOutcome{
+			Category:  category.Category,
+			Score:     category.Score,
+			Threshold: category.Threshold,
+			Flagged:   category.Flagged,
+		})
+	}
+	return outcomes
+}
+
+var (
+	// ErrResultNotFound is returned when a verification does not exist for the user.
+	ErrResultNotFound = errors.New("result not found")
+	// ErrImageNotStored is returned for verifications whose image was not kept.
+	ErrImageNotStored = errors.New("image not stored")
+)
+
+// DuplicateImageError is returned by VerifyImage when the user already verified the
+// same image. The new verification is not recorded.
+type DuplicateImageError struct {
+	// RequestID identifies the earlier verification of the image.
+	RequestID string
+}
+
+func (e *DuplicateImageError) Error() string {
+	return "image was already verified in request " + e.RequestID
+}
+
+// MalwareError is returned by VerifyImage when the malware scanner found the image
+// infected. The image is neither processed nor stored.
+type MalwareError struct {
+	// RequestID identifies the failed verification recording the scan; empty when
+	// it could not be saved, such as for an image rejected before.
+	RequestID string
+	Signature string
+}
+
+func (e *MalwareError) Error() string {
+	return "image contains malware: " + e.Signature
+}
+
+// QueuedError is returned by VerifyImage when the image processor was unavailable
+// and the verification was queued instead. Its result can be fetched once a worker
+// completed it.
+type QueuedError struct {
+	RequestID string
+}
+
+func (e *QueuedError) Error() string {
+	return "image processor unavailable, verification queued as request " + e.RequestID
 }
 
 // VerificationUseCase encapsulates business logic for the verification flow.
 type VerificationUseCase struct {
-	repo           VerificationRepository
-	cache          Cache
-	processor      imageprocessor.Client
-	logger         *zap.Logger
-	retryAttempts  int
-	initialBackoff time.Duration
-	maxBackoff     time.Duration
+	repo         VerificationRepository
+	cache        Cache
+	processor    imageprocessor.Client
+	images       ImageStore
+	events       EventPublisher
+	outbox       Outbox
+	experiment   VariantAssigner
+	observer     MetricsObserver
+	instruments  Instrumentation
+	limiter      ConcurrencyLimiter
+	buffer       LogBuffer
+	deferred     DeferredQueue
+	tenants      TenantPolicies
+	scanner      malwarescan.Scanner
+	preprocessor Preprocessor
+	region       string
+	logger       *zap.Logger
+	options      atomic.Pointer[Options]
+	// lookups coalesces concurrent database lookups of one uncached result.
+	lookups singleflight.Group
+	// summaries coalesces the queries of the metrics summary.
+	summaries singleflight.Group
+}
+
+// Options tunes retry and cache behaviour of the use case.
+type Options struct {
+	RetryAttempts  int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// RetryJitter randomly shortens each backoff by up to this fraction, from 0 to
+	// 1, so requests that failed together do not retry in lockstep.
+	RetryJitter float64
+	// RetryBudget caps the Redis retries of one request across all of its cache
+	// operations, on top of RetryAttempts per operation. Zero leaves only
+	// RetryAttempts.
+	RetryBudget   int
+	ProcessingTTL time.Duration
+	ResultTTL     time.Duration
+	// NotFoundTTL is how long GetResult remembers that a result does not exist, so
+	// clients polling an unknown request ID do not query the database each time. A
+	// verification completing in the meantime replaces the entry. Zero disables it.
+	NotFoundTTL time.Duration
+	// MetricsSummaryTTL is how long GetMetricsSummary serves a cached summary
+	// before recomputing it. Zero queries the database on every call.
+	MetricsSummaryTTL time.Duration
+	// MetricsSummaryStaleTTL is how long after MetricsSummaryTTL a cached summary is
+	// still served while it is recomputed in the background. Zero waits for the
+	// query instead.
+	MetricsSummaryStaleTTL time.Duration
+	// ImageURLTTL is how long signed image URLs stay valid.
+	ImageURLTTL time.Duration
+	// DetachedTimeout bounds a verification once it started. It then no longer ends
+	// with the caller's context, so a client that disconnects does not waste the
+	// processor's work and can fetch the result by request ID. Zero ends
+	// verifications with the caller's context.
+	DetachedTimeout time.Duration
+	// RequestIDFormat is the requestid format of new request IDs; empty generates
+	// random UUIDs.
+	RequestIDFormat string
+	// ReviewThreshold marks verifications scoring below it as needing review.
+	ReviewThreshold float32
+	// RejectThreshold rejects verifications scoring below it, without a review. Zero
+	// rejects none.
+	RejectThreshold float32
+	// CategoryThresholds flags a moderation category when its score reaches the
+	// category's threshold. Categories without a positive threshold are recorded but
+	// never flagged. A flagged category marks the verification as needing review.
+	CategoryThresholds map[string]float32
+	// Calibration maps the scores of each model version onto a common scale before
+	// they are stored and compared with ReviewThreshold. Category scores are kept as
+	// the processor returned them.
+	Calibration calibration.Curves
+	// MaxDuplicates caps the duplicates returned by one GetDuplicateReport call.
+	MaxDuplicates int
+	// DuplicatePrecheck reads and hashes the whole image before the processor is
+	// called, and answers an image the user already verified with the earlier result
+	// instead of processing it again.
+	DuplicatePrecheck bool
+	// SpoolThreshold is how much of an image that must be stored is kept in memory;
+	// larger images are spooled to a temporary file in SpoolDir, or in the default
+	// directory for temporary files when SpoolDir is empty.
+	SpoolThreshold int64
+	SpoolDir       string
+}
+
+// DefaultOptions returns the tunables used by NewVerificationUseCase.
+func DefaultOptions() Options {
+	return Options{
+		RetryAttempts:          3,
+		InitialBackoff:         50 * time.Millisecond,
+		MaxBackoff:             time.Second,
+		RetryJitter:            0.5,
+		RetryBudget:            3,
+		ProcessingTTL:          time.Minute,
+		ResultTTL:              5 * time.Minute,
+		NotFoundTTL:            2 * time.Second,
+		ImageURLTTL:            15 * time.Minute,
+		MetricsSummaryTTL:      5 * time.Second,
+		MetricsSummaryStaleTTL: time.Minute,
+		DetachedTimeout:        time.Minute,
+		ReviewThreshold:        0.5,
+		MaxDuplicates:          MaxPageSize,
+		SpoolThreshold:         1 << 20,
+		CategoryThresholds: map[string]float32{
+			imageprocessor.CategoryAIGenerated: 0.5,
+			imageprocessor.CategoryManipulated: 0.5,
+			imageprocessor.CategoryNSFW:        0.5,
+			imageprocessor.CategoryWatermarked: 0.5,
+		},
+	}
 }
 
 // VerificationMetadata captures persisted metadata for a verification request.
@@ -42,185 +407,1080 @@ type VerificationMetadata struct {
 	Timestamp time.Time
 	Success   bool
 	Score     float32
+	// RawScore is Score before calibration.
+	RawScore     float32
+	ModelVersion string
+	Categories   []CategoryOutcome
+	// Verdict is the outcome the score policy gave the verification.
+	Verdict string
+	// DuplicateOf is the request ID of the earlier verification whose result
+	// answered the upload, when Options.DuplicatePrecheck found one.
+	DuplicateOf string
 }
 
 type cachedVerification struct {
-	RequestID string    `json:"request_id"`
-	UserID    string    `json:"user_id"`
-	Score     float32   `json:"score"`
-	Success   bool      `json:"success"`
-	Details   string    `json:"details"`
-	Hash      string    `json:"sha1_hash"`
-	CreatedAt time.Time `json:"created_at"`
+	RequestID    string            `json:"request_id"`
+	UserID       string            `json:"user_id"`
+	Score        float32           `json:"score"`
+	RawScore     float32           `json:"raw_score"`
+	ModelVersion string            `json:"model_version,omitempty"`
+	Success      bool              `json:"success"`
+	Verdict      string            `json:"verdict,omitempty"`
+	Details      string            `json:"details"`
+	Hash         string            `json:"sha1_hash"`
+	SHA256Hash   string            `json:"sha256_hash,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	Categories   []CategoryOutcome `json:"categories,omitempty"`
+	MalwareScan  string            `json:"malware_scan,omitempty"`
+	// ImageMetadata is left out of entries cached before it was recorded.
+	ImageMetadata *imagemeta.Metadata `json:"image_metadata,omitempty"`
+	// Missing marks a request ID that has no result for UserID.
+	Missing bool `json:"missing,omitempty"`
 }
 
 // DuplicateReport represents duplicate verification entries for a request.
 type DuplicateReport struct {
-	Request    *repository.VerificationLog
+	Request *repository.VerificationLog
+	// Duplicates is one page of the duplicates, newest first.
 	Duplicates []*repository.VerificationLog
+	// NextCursor continues after the last duplicate; empty on the last page.
+	NextCursor string
+	// Total counts the duplicates of every page.
+	Total int64
 }
 
 // NewVerificationUseCase constructs a new use case instance.
 func NewVerificationUseCase(repo VerificationRepository, cache Cache, processor imageprocessor.Client, logger *zap.Logger) *VerificationUseCase {
-	return &VerificationUseCase{
-		repo:           repo,
-		cache:          cache,
-		processor:      processor,
-		logger:         logger.Named("verification_usecase"),
-		retryAttempts:  3,
-		initialBackoff: 50 * time.Millisecond,
-		maxBackoff:     time.Second,
+	return NewVerificationUseCaseWithOptions(repo, cache, processor, logger, DefaultOptions())
+}
+
+// NewVerificationUseCaseWithOptions constructs a use case with explicit tunables.
+func NewVerificationUseCaseWithOptions(repo VerificationRepository, cache Cache, processor imageprocessor.Client, logger *zap.Logger, opts Options) *VerificationUseCase {
+	uc := &VerificationUseCase{
+		repo:      repo,
+		cache:     cache,
+		processor: processor,
+		logger:    logger.Named("verification_usecase"),
 	}
+	uc.UpdateOptions(opts)
+	return uc
+}
+
+// UpdateOptions replaces the tunables used by subsequent operations. It is safe to call
+// while requests are in flight.
+func (uc *VerificationUseCase) UpdateOptions(opts Options) {
+	uc.options.Store(&opts)
+}
+
+// SetImageStore enables keeping uploaded images. Call it before serving requests.
+func (uc *VerificationUseCase) SetImageStore(store ImageStore) {
+	uc.images = store
+}
+
+// SetEventPublisher enables publishing verification events. Call it before serving
+// requests.
+func (uc *VerificationUseCase) SetEventPublisher(publisher EventPublisher) {
+	uc.events = publisher
+}
+
+// SetOutbox saves the events of the event publisher in outbox, with the log they
+// announce where there is one, instead of publishing them directly. A relay then
+// publishes them from there. Call it before serving requests.
+func (uc *VerificationUseCase) SetOutbox(outbox Outbox) {
+	uc.outbox = outbox
+}
+
+// SetExperiment routes each verification to the processor of the user's variant and
+// records the variant on the log. Call it before serving requests.
+func (uc *VerificationUseCase) SetExperiment(assigner VariantAssigner) {
+	uc.experiment = assigner
+}
+
+// SetMetricsObserver reports every verification to observer. Call it before serving
+// requests.
+func (uc *VerificationUseCase) SetMetricsObserver(observer MetricsObserver) {
+	uc.observer = observer
+}
+
+// SetInstrumentation reports the latency of every verification and each Redis retry
+// to instruments. Call it before serving requests.
+func (uc *VerificationUseCase) SetInstrumentation(instruments Instrumentation) {
+	uc.instruments = instruments
+}
+
+// SetConcurrencyLimiter sheds verifications with ErrOverloaded while limiter is
+// full, and reports the latency of the processor and database calls to it. Call it
+// before serving requests.
+func (uc *VerificationUseCase) SetConcurrencyLimiter(limiter ConcurrencyLimiter) {
+	uc.limiter = limiter
+}
+
+// SetLogBuffer makes verifications succeed while the database is unavailable: their
+// logs are handed to buffer instead and saved later. Until then the result is only
+// served from the cache and duplicates of the image are not detected. Call it before
+// serving requests.
+func (uc *VerificationUseCase) SetLogBuffer(buffer LogBuffer) {
+	uc.buffer = buffer
+}
+
+// SetDeferredQueue makes verifications that find the image processor unavailable
+// queue on queue instead of failing. It takes effect with an ImageStore that is an
+// ImageOpener, as queued verifications are completed from the stored image. Call
+// it before serving requests.
+func (uc *VerificationUseCase) SetDeferredQueue(queue DeferredQueue) {
+	uc.deferred = queue
+}
+
+// SetTenantPolicies applies the overrides of the caller's tenant to each
+// verification. Call it before serving requests.
+func (uc *VerificationUseCase) SetTenantPolicies(policies TenantPolicies) {
+	uc.tenants = policies
+}
+
+// SetMalwareScanner makes every image be scanned for malware before it is
+// processed. Infected images are rejected with *MalwareError; scanner failures fail
+// the verification.
+func (uc *VerificationUseCase) SetMalwareScanner(scanner malwarescan.Scanner) {
+	uc.scanner = scanner
+}
+
+// SetPreprocessor makes images be prepared by preprocessor before they are sent to
+// the processor. Images are then read whole first; hashes, metadata and stored
+// images still come from the image as uploaded. Should preprocessor fail, the image
+// is sent as uploaded.
+func (uc *VerificationUseCase) SetPreprocessor(preprocessor Preprocessor) {
+	uc.preprocessor = preprocessor
+}
+
+// SetRegion names the deployment region, which is recorded on verification logs and
+// prefixes cache keys, so regions sharing a Redis do not read each other's entries.
+// Call it before serving requests.
+func (uc *VerificationUseCase) SetRegion(region string) {
+	uc.region = region
+}
+
+// Region returns the region set by SetRegion, or "".
+func (uc *VerificationUseCase) Region() string {
+	return uc.region
+}
+
+// cacheKey names the cached result of requestID. Results of tenants are kept under
+// the tenant, so a request ID never reads another tenant's result.
+func (uc *VerificationUseCase) cacheKey(tenantID, requestID string) string {
+	key := "verification:" + requestID
+	if tenantID != "" {
+		key = "tenant:" + tenantID + ":" + key
+	}
+	if uc.region == "" {
+		return key
+	}
+	return uc.region + ":" + key
+}
+
+func (uc *VerificationUseCase) currentOptions() Options {
+	if opts := uc.options.Load(); opts != nil {
+		return *opts
+	}
+	return Options{}
+}
+
+// optionsFor returns the options with the overrides of the caller's tenant, and the
+// tenant's ID. The ID is recorded on the verification's log, events and cache key.
+func (uc *VerificationUseCase) optionsFor(ctx context.Context) (Options, string, error) {
+	opts := uc.currentOptions()
+	tenantID, _ := auth.GetTenantID(ctx)
+	if uc.tenants == nil {
+		return opts, tenantID, nil
+	}
+	policy, err := uc.tenants.Policy(ctx)
+	if err != nil || policy == nil {
+		return opts, tenantID, err
+	}
+	if policy.ReviewThreshold != nil {
+		opts.ReviewThreshold = *policy.ReviewThreshold
+	}
+	if policy.RejectThreshold != nil {
+		opts.RejectThreshold = *policy.RejectThreshold
+	}
+	if len(policy.CategoryThresholds) > 0 {
+		merged := make(map[string]float32, len(opts.CategoryThresholds)+len(policy.CategoryThresholds))
+		for category, threshold := range opts.CategoryThresholds {
+			merged[category] = threshold
+		}
+		for category, threshold := range policy.CategoryThresholds {
+			merged[category] = threshold
+		}
+		opts.CategoryThresholds = merged
+	}
+	return opts, policy.TenantID, nil
 }
 
 // VerifyImage orchestrates persistence, caching, and inference calls.
 func (uc *VerificationUseCase) VerifyImage(ctx context.Context, userID string, imageBytes []byte) (string, *imageprocessor.Result, *VerificationMetadata, error) {
-	requestID := uuid.NewString()
-	opLogger := logging.WithOperation(uc.logger, "usecase.verify_image", requestID)
+	return uc.VerifyImageStream(ctx, userID, bytes.NewReader(imageBytes))
+}
+
+// VerifyImageStream is VerifyImage for an image read from image. The image is hashed
+// as it is sent to the processor and only kept when it must be stored, in memory up
+// to Options.SpoolThreshold and in a temporary file beyond. Failures to read it are returned as *imageprocessor.ReadError and, being
+// the caller's, are not reported as failed verifications.
+func (uc *VerificationUseCase) VerifyImageStream(ctx context.Context, userID string, image io.Reader) (string, *imageprocessor.Result, *VerificationMetadata, error) {
+	if uc.instruments == nil {
+		return uc.verifyImageStream(ctx, userID, image)
+	}
+	started := time.Now()
+	requestID, result, metadata, err := uc.verifyImageStream(ctx, userID, image)
+	uc.instruments.ObserveVerifyImage(verifyOutcome(metadata, err), time.Since(started))
+	return requestID, result, metadata, err
+}
+
+// verifyOutcome names how a verification ended for the Instrumentation.
+func verifyOutcome(metadata *VerificationMetadata, err error) string {
+	var readErr *imageprocessor.ReadError
+	var duplicateErr *DuplicateImageError
+	var malwareErr *MalwareError
+	var queuedErr *QueuedError
+	switch {
+	case errors.Is(err, ErrOverloaded):
+		return OutcomeOverloaded
+	case errors.As(err, &queuedErr):
+		return OutcomeQueued
+	case errors.As(err, &readErr) || errors.As(err, &duplicateErr) || errors.As(err, &malwareErr):
+		return OutcomeRejected
+	case err != nil:
+		return OutcomeFailed
+	case metadata.DuplicateOf != "":
+		return OutcomeDuplicate
+	case metadata.Success:
+		return OutcomeVerified
+	}
+	return OutcomeNotVerified
+}
+
+func (uc *VerificationUseCase) verifyImageStream(ctx context.Context, userID string, image io.Reader) (string, *imageprocessor.Result, *VerificationMetadata, error) {
+	if uc.limiter != nil {
+		// Shed verifications are not failures: nothing was attempted.
+		if !uc.limiter.Acquire() {
+			return "", nil, nil, ErrOverloaded
+		}
+		defer uc.limiter.Release()
+	}
+	ctx = uc.withRetryBudget(ctx)
+	opts, tenantID, err := uc.optionsFor(ctx)
+	requestID := requestid.New(opts.RequestIDFormat)
+	caller := ctx
+	if opts.DetachedTimeout > 0 {
+		// Reading image still fails once the client is gone, so only verifications
+		// whose upload completed carry on.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), opts.DetachedTimeout)
+		defer cancel()
+	}
+	if err != nil {
+		err = logging.NewOperationError("usecase.tenant_policy", requestID, err)
+	} else {
+		var result *imageprocessor.Result
+		var metadata *VerificationMetadata
+		if result, metadata, err = uc.verifyImage(ctx, requestID, userID, tenantID, opts, image); err == nil {
+			if metadata.DuplicateOf != "" {
+				return metadata.DuplicateOf, result, metadata, nil
+			}
+			if caller.Err() != nil {
+				logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.verify_image", requestID).Info("verification completed after the caller went away", zap.Error(caller.Err()))
+			}
+			return requestID, result, metadata, nil
+		}
+	}
+	var readErr *imageprocessor.ReadError
+	var duplicateErr *DuplicateImageError
+	var malwareErr *MalwareError
+	var queuedErr *QueuedError
+	if errors.As(err, &readErr) || errors.As(err, &duplicateErr) || errors.As(err, &malwareErr) || errors.As(err, &queuedErr) {
+		return "", nil, nil, err
+	}
+	if uc.observer != nil {
+		uc.observer.ObserveFailure(tenantID)
+	}
+	uc.publishFailure(ctx, requestID, userID, tenantID, err)
+	return "", nil, nil, err
+}
+
+func (uc *VerificationUseCase) verifyImage(ctx context.Context, requestID, userID, tenantID string, opts Options, image io.Reader) (*imageprocessor.Result, *VerificationMetadata, error) {
+	opLogger := logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.verify_image", requestID)
+
+	// SHA-1 is still recorded for logs whose duplicates have no SHA-256 yet.
+	hasher, legacyHasher := sha256.New(), sha1.New()
+	perceptual := phash.NewStream()
+	defer perceptual.Close()
+	exif := imagemeta.NewStream()
+	sinks := []io.Writer{hasher, legacyHasher, perceptual, exif}
+	var stored *spoolSink
+	readAhead := opts.DuplicatePrecheck || uc.scanner != nil
+	if uc.images != nil || readAhead {
+		stored = &spoolSink{buffer: spool.New(opts.SpoolDir, opts.SpoolThreshold)}
+		defer stored.buffer.Close()
+		sinks = append(sinks, stored)
+	}
+	body := io.TeeReader(image, io.MultiWriter(sinks...))
+	if readAhead {
+		// The image is read before the processor is called, so it is only processed
+		// when its hashes match no earlier verification and the scanner found it clean.
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			wrapped := logging.NewOperationError("usecase.read_image", requestID, &imageprocessor.ReadError{Err: err})
+			opLogger.Error("failed to read image", zap.Error(wrapped))
+			return nil, nil, wrapped
+		}
+		if stored.err != nil {
+			wrapped := logging.NewOperationError("usecase.spool_image", requestID, stored.err)
+			opLogger.Error("failed to spool image", zap.Error(wrapped))
+			return nil, nil, wrapped
+		}
+		if opts.DuplicatePrecheck {
+			if prior := uc.findPriorVerification(ctx, requestID, userID, hex.EncodeToString(hasher.Sum(nil)), hex.EncodeToString(legacyHasher.Sum(nil))); prior != nil {
+				opLogger.Info("image already verified, answering with the earlier result", zap.String("duplicate_of", prior.RequestID))
+				result, metadata := priorResult(prior)
+				return result, metadata, nil
+			}
+		}
+		if uc.scanner != nil {
+			verdict, err := uc.scanner.Scan(ctx, stored.buffer.Reader())
+			if err != nil {
+				wrapped := logging.NewOperationError("usecase.scan_image", requestID, err)
+				opLogger.Error("failed to scan image for malware", zap.Error(wrapped))
+				return nil, nil, wrapped
+			}
+			if verdict.Infected {
+				opLogger.Warn("image contains malware, rejecting it", zap.String("signature", verdict.Signature))
+				return nil, nil, uc.rejectInfected(ctx, &repository.VerificationLog{
+					RequestID:  requestID,
+					UserID:     userID,
+					TenantID:   tenantID,
+					CreatedAt:  time.Now().UTC(),
+					SHA1Hash:   hex.EncodeToString(legacyHasher.Sum(nil)),
+					SHA256Hash: hex.EncodeToString(hasher.Sum(nil)),
+					Region:     uc.region,
+				}, verdict)
+			}
+		}
+		body = stored.buffer.Reader()
+	}
 
-	cacheKey := fmt.Sprintf("verification:%s", requestID)
+	cacheKey := uc.cacheKey(tenantID, requestID)
 	if err := uc.withRedisRetry(ctx, requestID, "cache.set.processing", func() error {
-		return uc.cache.Set(ctx, cacheKey, "processing", time.Minute)
+		return uc.cache.Set(ctx, cacheKey, "processing", opts.ProcessingTTL)
 	}); err != nil {
 		opLogger.Error("failed to set processing flag", zap.Error(err))
-		return "", nil, nil, err
+		return nil, nil, err
+	}
+
+	processor, variant := uc.processor, ""
+	if uc.experiment != nil {
+		variant, processor = uc.experiment.Assign(userID)
+	}
+
+	prepared, err := uc.preprocess(opLogger, body)
+	if err != nil {
+		wrapped := logging.NewOperationError("usecase.read_image", requestID, err)
+		opLogger.Error("failed to read image", zap.Error(wrapped))
+		return nil, nil, wrapped
 	}
 
 	started := time.Now()
-	result, err := uc.processor.Process(ctx, userID, imageBytes)
+	result, err := imageprocessor.ProcessReader(ctx, processor, userID, prepared)
+	if uc.limiter != nil {
+		uc.limiter.Observe(DependencyProcessor, time.Since(started), err)
+	}
+	var processErr error
 	if err != nil {
-		wrapped := logging.NewOperationError("usecase.grpc_process_image", requestID, err)
-		opLogger.Error("grpc processing failed", zap.Error(wrapped))
-		return "", nil, nil, wrapped
+		processErr = logging.NewOperationError("usecase.grpc_process_image", requestID, err)
+		if !uc.canDefer() || status.Code(err) != codes.Unavailable {
+			opLogger.Error("grpc processing failed", zap.Error(processErr))
+			return nil, nil, processErr
+		}
+		opLogger.Warn("image processor unavailable, queueing verification", zap.Error(processErr))
 	}
 	latency := time.Since(started)
+	// The hashes cover the whole image, whatever the processor chose to read.
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		wrapped := logging.NewOperationError("usecase.read_image", requestID, &imageprocessor.ReadError{Err: err})
+		opLogger.Error("failed to read image", zap.Error(wrapped))
+		return nil, nil, wrapped
+	}
 
-	hash := sha1.Sum(imageBytes)
-	hashHex := hex.EncodeToString(hash[:])
 	log := &repository.VerificationLog{
-		RequestID:           requestID,
-		UserID:              userID,
-		Score:               result.Score,
-		Success:             result.Success,
-		CreatedAt:           time.Now().UTC(),
-		SHA1Hash:            hashHex,
-		ProcessingLatencyMs: float64(latency) / float64(time.Millisecond),
-	}
-	details := fmt.Sprintf("status:%t score:%f hash:%s latency_ms:%d", result.Success, result.Score, hashHex, latency.Milliseconds())
-	log.Details = details
-	if err := uc.repo.SaveLog(ctx, log); err != nil {
+		RequestID:  requestID,
+		UserID:     userID,
+		TenantID:   tenantID,
+		CreatedAt:  time.Now().UTC(),
+		SHA1Hash:   hex.EncodeToString(legacyHasher.Sum(nil)),
+		SHA256Hash: hex.EncodeToString(hasher.Sum(nil)),
+		Region:     uc.region,
+		Variant:    variant,
+	}
+	if processErr == nil {
+		applyResult(log, result, opts, latency)
+		uc.stageVerificationEvents(ctx, log, result.Message)
+	} else {
+		log.Status = repository.StatusQueued
+		log.Details = "queued: image processor unavailable"
+	}
+	if perceptualHash, err := perceptual.Sum(); err == nil {
+		log.PerceptualHash = phash.Format(perceptualHash)
+	}
+	if metadata, err := exif.Metadata(); err == nil {
+		log.ImageMetadata = metadata
+	} else if !errors.Is(err, imagemeta.ErrNoMetadata) {
+		opLogger.Debug("ignoring unreadable image metadata", zap.Error(err))
+	}
+	if uc.scanner != nil {
+		log.MalwareScan = repository.MalwareScanClean
+	}
+	if uc.images != nil {
+		key := userID + "/" + requestID
+		if err := uc.storeImage(ctx, key, stored); err != nil {
+			wrapped := logging.NewOperationError("usecase.store_image", requestID, err)
+			opLogger.Error("failed to store image", zap.Error(wrapped))
+			return nil, nil, wrapped
+		}
+		log.ImageKey = key
+	}
+	saveStarted := time.Now()
+	err = uc.repo.SaveLog(ctx, log)
+	if uc.limiter != nil {
+		uc.limiter.Observe(DependencyDatabase, time.Since(saveStarted), err)
+	}
+	if err != nil && uc.buffer != nil && repository.IsUnavailable(err) {
+		if bufferErr := uc.buffer.Add(ctx, log); bufferErr != nil {
+			opLogger.Error("failed to buffer verification log", zap.Error(bufferErr))
+		} else {
+			opLogger.Warn("database unavailable, buffered verification log", zap.Error(err))
+			err = nil
+		}
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicateImage) {
+			if existing := uc.findSameImage(ctx, requestID, log); existing != "" {
+				opLogger.Info("image already verified", zap.String("existing_request_id", existing))
+				return nil, nil, logging.NewOperationError("usecase.save_log", requestID, &DuplicateImageError{RequestID: existing})
+			}
+		}
 		wrapped := logging.NewOperationError("usecase.save_log", requestID, err)
 		opLogger.Error("failed to persist verification log", zap.Error(wrapped))
-		return "", nil, nil, wrapped
+		return nil, nil, wrapped
+	}
+	if processErr != nil {
+		if err := uc.deferred.Defer(ctx, requestID, userID); err != nil {
+			opLogger.Error("failed to queue verification", zap.Error(err))
+			if err := uc.repo.FailQueued(ctx, log.ID, "failed: image processor unavailable"); err != nil {
+				opLogger.Error("failed to mark queued verification failed", zap.Error(err))
+			}
+			return nil, nil, processErr
+		}
+		return nil, nil, &QueuedError{RequestID: requestID}
 	}
 
-	metadata := &VerificationMetadata{
-		Timestamp: log.CreatedAt,
-		Success:   normalizeSuccessFlag(log.Success),
-		Score:     log.Score,
+	metadata := newMetadata(log)
+	if err := uc.cacheResult(ctx, log, metadata, opts.ResultTTL); err != nil {
+		opLogger.Error("failed to cache verification result", zap.Error(err))
+		return nil, nil, err
 	}
 
-	cached := cachedVerification{
-		RequestID: requestID,
-		UserID:    userID,
-		Score:     log.Score,
-		Success:   metadata.Success,
-		Details:   log.Details,
-		Hash:      log.SHA1Hash,
-		CreatedAt: log.CreatedAt,
+	if uc.observer != nil {
+		uc.observer.ObserveVerification(log.TenantID, metadata.Success, latency)
 	}
+	uc.publishVerification(ctx, log, result.Message)
+	return result, metadata, nil
+}
 
-	serialized, err := json.Marshal(cached)
+// rejectInfected records log as the failed verification of an infected image and
+// returns the *MalwareError rejecting it. The log is not counted in the metrics, and
+// the rejection stands even if it cannot be saved.
+func (uc *VerificationUseCase) rejectInfected(ctx context.Context, log *repository.VerificationLog, verdict malwarescan.Verdict) error {
+	log.Status = repository.StatusFailed
+	log.Details = "rejected: malware detected"
+	log.MalwareScan = repository.MalwareScanInfected
+	log.MalwareSignature = verdict.Signature
+	rejected := &MalwareError{RequestID: log.RequestID, Signature: verdict.Signature}
+	if err := uc.repo.SaveLog(ctx, log); err != nil {
+		// An earlier upload of the same image was rejected already, or the database
+		// is unavailable.
+		logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.scan_image", log.RequestID).Warn("failed to record infected image", zap.Error(err))
+		rejected.RequestID = ""
+	}
+	return logging.NewOperationError("usecase.scan_image", log.RequestID, rejected)
+}
+
+func (uc *VerificationUseCase) canDefer() bool {
+	_, ok := uc.images.(ImageOpener)
+	return uc.deferred != nil && ok
+}
+
+// preprocess returns the image of body prepared by the Preprocessor, or body itself
+// without one. It fails with *imageprocessor.ReadError when body cannot be read.
+func (uc *VerificationUseCase) preprocess(opLogger *zap.Logger, body io.Reader) (io.Reader, error) {
+	if uc.preprocessor == nil {
+		return body, nil
+	}
+	raw, err := io.ReadAll(body)
 	if err != nil {
-		opLogger.Error("failed to serialize verification result", zap.Error(err))
-		return "", nil, nil, err
+		return nil, &imageprocessor.ReadError{Err: err}
+	}
+	prepared, err := uc.preprocessor.Apply(raw)
+	if err != nil {
+		opLogger.Warn("failed to preprocess image, sending it as uploaded", zap.Error(err))
+		return bytes.NewReader(raw), nil
+	}
+	if len(prepared) != len(raw) {
+		opLogger.Debug("preprocessed image", zap.Int("uploaded_bytes", len(raw)), zap.Int("prepared_bytes", len(prepared)))
 	}
+	return bytes.NewReader(prepared), nil
+}
 
-	if err := uc.withRedisRetry(ctx, requestID, "cache.set.result", func() error {
-		return uc.cache.Set(ctx, cacheKey, string(serialized), 5*time.Minute)
-	}); err != nil {
-		opLogger.Error("failed to cache verification result", zap.Error(err))
-		return "", nil, nil, err
+// ProcessQueued completes the queued verification requestID of userID from its
+// stored image, as a worker does for the DeferredQueue. Verifications that are no
+// longer queued are left alone, and images the processor rejects fail the
+// verification; other errors should be retried.
+func (uc *VerificationUseCase) ProcessQueued(ctx context.Context, requestID, userID string) error {
+	opLogger := logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.process_queued", requestID)
+	// The verification is read from the local database, as the reader may not have
+	// it yet, or may still show it queued after an earlier attempt completed it.
+	log, err := uc.repo.FindByRequestIDAndUser(repository.WithPrimary(ctx), requestID, userID)
+	if err != nil {
+		return logging.NewOperationError("usecase.process_queued", requestID, err)
+	}
+	if log.Status != repository.StatusQueued {
+		return nil
+	}
+	if log.TenantID != "" {
+		ctx = auth.WithTenantID(ctx, log.TenantID)
+	}
+	opts, _, err := uc.optionsFor(ctx)
+	if err != nil {
+		return logging.NewOperationError("usecase.tenant_policy", requestID, err)
+	}
+	opener, ok := uc.images.(ImageOpener)
+	if !ok {
+		return errors.New("queued verifications need an image store that opens images")
+	}
+	image, err := opener.Open(ctx, log.ImageKey)
+	if err != nil {
+		return logging.NewOperationError("usecase.open_image", requestID, err)
+	}
+	defer image.Close()
+
+	processor, variant := uc.processor, ""
+	if uc.experiment != nil {
+		variant, processor = uc.experiment.Assign(userID)
+	}
+	prepared, err := uc.preprocess(opLogger, image)
+	if err != nil {
+		return logging.NewOperationError("usecase.read_image", requestID, err)
+	}
+	started := time.Now()
+	result, err := imageprocessor.ProcessReader(ctx, processor, userID, prepared)
+	if status.Code(err) == codes.InvalidArgument {
+		opLogger.Warn("image processor rejected the queued image", zap.Error(err))
+		if err := uc.repo.FailQueued(ctx, log.ID, "failed: image processor rejected the image"); err != nil && !errors.Is(err, repository.ErrNotQueued) {
+			return logging.NewOperationError("usecase.fail_queued", requestID, err)
+		}
+		uc.publishFailure(ctx, requestID, userID, log.TenantID, err)
+		return nil
+	}
+	if err != nil {
+		return logging.NewOperationError("usecase.grpc_process_image", requestID, err)
+	}
+	latency := time.Since(started)
+	log.Variant = variant
+	applyResult(log, result, opts, latency)
+	uc.stageVerificationEvents(ctx, log, result.Message)
+	if err := uc.repo.CompleteQueued(ctx, log); errors.Is(err, repository.ErrNotQueued) {
+		return nil
+	} else if err != nil {
+		return logging.NewOperationError("usecase.complete_queued", requestID, err)
+	}
+
+	metadata := newMetadata(log)
+	if err := uc.cacheResult(ctx, log, metadata, opts.ResultTTL); err != nil {
+		// The result is saved, so it is still served from the database.
+		opLogger.Warn("failed to cache verification result", zap.Error(err))
+	}
+	if uc.observer != nil {
+		uc.observer.ObserveVerification(log.TenantID, metadata.Success, latency)
+	}
+	uc.publishVerification(ctx, log, result.Message)
+	opLogger.Info("completed queued verification")
+	return nil
+}
+
+// applyResult records the processor's result on log, scored with opts. The
+// result's score is calibrated like the one stored, as callers see it.
+func applyResult(log *repository.VerificationLog, result *imageprocessor.Result, opts Options, latency time.Duration) {
+	rawScore := result.Score
+	result.Score = opts.Calibration.Apply(result.ModelVersion, rawScore)
+	log.Score = result.Score
+	log.RawScore = rawScore
+	log.ModelVersion = result.ModelVersion
+	log.Success = result.Success
+	log.ProcessingLatencyMs = float64(latency) / float64(time.Millisecond)
+	log.Categories = evaluateCategories(result.Categories, opts.CategoryThresholds)
+	log.Verdict = decideVerdict(log, opts)
+	log.Details = fmt.Sprintf("status:%t score:%f hash:%s latency_ms:%d", result.Success, result.Score, log.SHA256Hash, latency.Milliseconds())
+}
+
+func newMetadata(log *repository.VerificationLog) *VerificationMetadata {
+	return &VerificationMetadata{
+		Timestamp:    log.CreatedAt,
+		Success:      normalizeSuccessFlag(log.Success),
+		Verdict:      log.Verdict,
+		Score:        log.Score,
+		RawScore:     log.RawScore,
+		ModelVersion: log.ModelVersion,
+		Categories:   CategoryOutcomes(log.Categories),
+	}
+}
+
+// cacheResult caches the completed verification log for ttl.
+func (uc *VerificationUseCase) cacheResult(ctx context.Context, log *repository.VerificationLog, metadata *VerificationMetadata, ttl time.Duration) error {
+	serialized, err := json.Marshal(cachedVerification{
+		RequestID:     log.RequestID,
+		UserID:        log.UserID,
+		Score:         log.Score,
+		RawScore:      log.RawScore,
+		ModelVersion:  log.ModelVersion,
+		Success:       metadata.Success,
+		Verdict:       log.Verdict,
+		Details:       log.Details,
+		Hash:          log.SHA1Hash,
+		SHA256Hash:    log.SHA256Hash,
+		CreatedAt:     log.CreatedAt,
+		Categories:    metadata.Categories,
+		MalwareScan:   log.MalwareScan,
+		ImageMetadata: log.ImageMetadata,
+	})
+	if err != nil {
+		return fmt.Errorf("serialize verification result: %w", err)
+	}
+	return uc.withRedisRetry(ctx, log.RequestID, "cache.set.result", func() error {
+		return uc.cache.Set(ctx, uc.cacheKey(log.TenantID, log.RequestID), string(serialized), ttl)
+	})
+}
+
+// spoolSink keeps the image for storing while it is verified. Failing to spool it
+// is the server's fault, not a failure to read the upload, so the error is kept
+// aside rather than failing the read.
+type spoolSink struct {
+	buffer *spool.Buffer
+	err    error
+}
+
+func (s *spoolSink) Write(p []byte) (int, error) {
+	if s.err == nil {
+		_, s.err = s.buffer.Write(p)
+	}
+	return len(p), nil
+}
+
+// storeImage puts the spooled image under key, with the content type sniffed from
+// its first bytes.
+func (uc *VerificationUseCase) storeImage(ctx context.Context, key string, stored *spoolSink) error {
+	if stored.err != nil {
+		return stored.err
+	}
+	body := stored.buffer.Reader()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read spooled image: %w", err)
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("read spooled image: %w", err)
+	}
+	return uc.images.Put(ctx, key, http.DetectContentType(head[:n]), body, body.Size())
+}
+
+// verificationEvents builds the events announcing the verification of log.
+func verificationEvents(log *repository.VerificationLog, message string) []Event {
+	data := VerificationEvent{
+		RequestID:  log.RequestID,
+		Verified:   log.Success,
+		Verdict:    log.Verdict,
+		Score:      log.Score,
+		Message:    message,
+		SHA256Hash: log.SHA256Hash,
+		SHA1Hash:   log.SHA1Hash,
+		CreatedAt:  log.CreatedAt,
+		Categories: CategoryOutcomes(log.Categories),
+	}
+	types := []string{EventVerificationCompleted}
+	if log.Verdict == VerdictNeedsReview {
+		types = append(types, EventVerificationNeedsReview)
+	}
+	events := make([]Event, 0, len(types))
+	for _, eventType := range types {
+		events = append(events, Event{
+			ID:        uuid.NewSHA1(uuid.NameSpaceOID, []byte(eventType+"/"+log.RequestID)).String(),
+			Type:      eventType,
+			UserID:    log.UserID,
+			TenantID:  log.TenantID,
+			CreatedAt: log.CreatedAt,
+			Data:      data,
+		})
+	}
+	return events
+}
+
+// stageVerificationEvents attaches the events of a verification to its log when
+// events go through the outbox, so they are saved with it.
+func (uc *VerificationUseCase) stageVerificationEvents(ctx context.Context, log *repository.VerificationLog, message string) {
+	if uc.events == nil || uc.outbox == nil {
+		return
+	}
+	log.Outbox = nil
+	for _, event := range verificationEvents(log, message) {
+		record, err := NewOutboxEvent(event)
+		if err != nil {
+			logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.publish_event", log.RequestID).Error("failed to encode event",
+				zap.String("event", event.Type), zap.Error(err))
+			continue
+		}
+		log.Outbox = append(log.Outbox, record)
+	}
+}
+
+// publishVerification announces a stored verification. Failing to publish does not
+// fail the verification; the result is already saved and can be fetched. With an
+// outbox the events were saved with the log instead.
+func (uc *VerificationUseCase) publishVerification(ctx context.Context, log *repository.VerificationLog, message string) {
+	if uc.events == nil || uc.outbox != nil {
+		return
+	}
+	for _, event := range verificationEvents(log, message) {
+		if err := uc.events.Publish(ctx, event); err != nil {
+			logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.publish_event", log.RequestID).Error("failed to publish event",
+				zap.String("event", event.Type), zap.Error(err))
+		}
 	}
+}
 
-	return requestID, result, metadata, nil
+// publishFailure announces a verification that could not be completed.
+func (uc *VerificationUseCase) publishFailure(ctx context.Context, requestID, userID, tenantID string, cause error) {
+	if uc.events == nil {
+		return
+	}
+	reason := "internal"
+	var opErr *logging.OperationError
+	if errors.As(cause, &opErr) {
+		reason = opErr.Operation
+	}
+	now := time.Now().UTC()
+	event := Event{
+		ID:        uuid.NewSHA1(uuid.NameSpaceOID, []byte(EventVerificationFailed+"/"+requestID)).String(),
+		Type:      EventVerificationFailed,
+		UserID:    userID,
+		TenantID:  tenantID,
+		CreatedAt: now,
+		Data:      VerificationFailedEvent{RequestID: requestID, Reason: reason, CreatedAt: now},
+	}
+	if uc.outbox != nil {
+		record, err := NewOutboxEvent(event)
+		if err == nil {
+			err = uc.outbox.Add(ctx, record)
+		}
+		if err == nil {
+			return
+		}
+		// Publishing directly beats losing the event.
+		logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.publish_event", requestID).Warn("failed to save event in the outbox",
+			zap.String("event", EventVerificationFailed), zap.Error(err))
+	}
+	if err := uc.events.Publish(ctx, event); err != nil {
+		logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.publish_event", requestID).Error("failed to publish event",
+			zap.String("event", EventVerificationFailed), zap.Error(err))
+	}
 }
 
 func normalizeSuccessFlag(success bool) bool {
 	return success
 }
 
+// evaluateCategories applies thresholds to the category scores of a result, sorted
+// by category. A category reported more than once keeps its last score.
+func evaluateCategories(scores []imageprocessor.CategoryScore, thresholds map[string]float32) []repository.VerificationCategory {
+	byCategory := make(map[string]float32, len(scores))
+	for _, score := range scores {
+		if score.Category != "" {
+			byCategory[score.Category] = score.Score
+		}
+	}
+	categories := make([]repository.VerificationCategory, 0, len(byCategory))
+	for category, score := range byCategory {
+		threshold := thresholds[category]
+		if threshold < 0 {
+			threshold = 0
+		}
+		categories = append(categories, repository.VerificationCategory{
+			Category:  category,
+			Score:     score,
+			Threshold: threshold,
+			Flagged:   threshold > 0 && score >= threshold,
+		})
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Category < categories[j].Category })
+	return categories
+}
+
+func anyFlagged(categories []repository.VerificationCategory) bool {
+	for _, category := range categories {
+		if category.Flagged {
+			return true
+		}
+	}
+	return false
+}
+
 // GetResult retrieves a cached verification outcome or loads from persistence.
+// Outcomes cached for another user are never served; the caller gets the database's
+// answer, which only holds their own verifications.
 func (uc *VerificationUseCase) GetResult(ctx context.Context, userID, requestID string) (*repository.VerificationLog, error) {
-	cacheKey := fmt.Sprintf("verification:%s", requestID)
+	ctx = uc.withRetryBudget(ctx)
+	tenantID, _ := auth.GetTenantID(ctx)
+	cacheKey := uc.cacheKey(tenantID, requestID)
 	if cached, err := uc.withRedisGet(ctx, requestID, "cache.get.result", cacheKey); err == nil {
 		var payload cachedVerification
 		if err := json.Unmarshal([]byte(cached), &payload); err != nil {
-			logging.WithOperation(uc.logger, "usecase.get_result", requestID).Warn("failed to decode cached result", zap.Error(err))
-		} else {
+			logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.get_result", requestID).Warn("failed to decode cached result", zap.Error(err))
+		} else if payload.Missing && payload.UserID == userID {
+			return nil, ErrResultNotFound
+		} else if !payload.Missing && (payload.UserID == "" || payload.UserID == userID) {
 			log := &repository.VerificationLog{
-				RequestID: requestID,
-				UserID:    userID,
-				Score:     payload.Score,
-				Success:   payload.Success,
-				Details:   payload.Details,
-				SHA1Hash:  payload.Hash,
-				CreatedAt: payload.CreatedAt,
+				RequestID:     requestID,
+				UserID:        userID,
+				Score:         payload.Score,
+				RawScore:      payload.RawScore,
+				ModelVersion:  payload.ModelVersion,
+				Success:       payload.Success,
+				Verdict:       payload.Verdict,
+				Details:       payload.Details,
+				SHA1Hash:      payload.Hash,
+				SHA256Hash:    payload.SHA256Hash,
+				Status:        repository.StatusCompleted,
+				CreatedAt:     payload.CreatedAt,
+				MalwareScan:   payload.MalwareScan,
+				ImageMetadata: payload.ImageMetadata,
 			}
-			if payload.UserID != "" {
-				log.UserID = payload.UserID
+			for _, outcome := range payload.Categories {
+				log.Categories = append(log.Categories, repository.VerificationCategory{
+					Category:  outcome.Category,
+					Score:     outcome.Score,
+					Threshold: outcome.Threshold,
+					Flagged:   outcome.Flagged,
+				})
 			}
 			if payload.RequestID != "" {
 				log.RequestID = payload.RequestID
 			}
 			return log, nil
 		}
+		return uc.findResult(ctx, cacheKey, userID, requestID, false)
 	} else if !errors.Is(err, redis.Nil) {
-		logging.WithOperation(uc.logger, "usecase.get_result", requestID).Warn("failed to read cache", zap.Error(err))
+		logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.get_result", requestID).Warn("failed to read cache", zap.Error(err))
 	}
+	return uc.findResult(ctx, cacheKey, userID, requestID, true)
+}
 
+// findResult reads an uncached result from the database. Concurrent lookups of the
+// same result share one query, and with cacheMissing a result that does not exist
+// is cached as missing for Options.NotFoundTTL. Results cached for another user are
+// not replaced.
+func (uc *VerificationUseCase) findResult(ctx context.Context, cacheKey, userID, requestID string, cacheMissing bool) (*repository.VerificationLog, error) {
+	// The query is shared, so it outlives a caller that goes away while others wait.
+	lookupCtx := context.WithoutCancel(ctx)
+	lookup := uc.lookups.DoChan(cacheKey+"\x00"+userID, func() (interface{}, error) {
+		log, err := uc.repo.FindByRequestIDAndUser(lookupCtx, requestID, userID)
+		if ttl := uc.currentOptions().NotFoundTTL; cacheMissing && ttl > 0 && errors.Is(err, gorm.ErrRecordNotFound) {
+			// The lookup may have read a reader that lags behind, so the result is only
+			// cached as missing when the local database lacks it too.
+			log, err = uc.repo.FindByRequestIDAndUser(repository.WithPrimary(lookupCtx), requestID, userID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				uc.cacheMissing(lookupCtx, cacheKey, userID, requestID, ttl)
+			}
+		}
+		return log, err
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-lookup:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		log := result.Val.(*repository.VerificationLog)
+		if result.Shared {
+			// Callers fill in the log, so each gets its own.
+			copied := *log
+			log = &copied
+		}
+		return log, nil
+	}
+}
+
+// cacheMissing records that requestID has no result for userID. Failing to do so
+// only costs later lookups a query.
+func (uc *VerificationUseCase) cacheMissing(ctx context.Context, cacheKey, userID, requestID string, ttl time.Duration) {
+	serialized, err := json.Marshal(cachedVerification{RequestID: requestID, UserID: userID, Missing: true})
+	if err == nil {
+		err = uc.cache.Set(ctx, cacheKey, string(serialized), ttl)
+	}
+	if err != nil {
+		logging.WithOperation(logging.FromContext(ctx, uc.logger), "cache.set.missing_result", requestID).Warn("failed to cache missing result", zap.Error(err))
+	}
+}
+
+// ImageURL returns a time-limited URL for downloading the image of a verification
+// and the time it expires.
+func (uc *VerificationUseCase) ImageURL(ctx context.Context, userID, requestID string) (string, time.Time, error) {
 	log, err := uc.repo.FindByRequestIDAndUser(ctx, requestID, userID)
 	if err != nil {
-		return nil, err
+		return "", time.Time{}, fmt.Errorf("%w: %v", ErrResultNotFound, err)
 	}
-	return log, nil
+	if log.ImageKey == "" || uc.images == nil {
+		return "", time.Time{}, ErrImageNotStored
+	}
+
+	ttl := uc.currentOptions().ImageURLTTL
+	expiresAt := time.Now().UTC().Add(ttl)
+	url, err := uc.images.SignedURL(ctx, log.ImageKey, ttl)
+	if err != nil {
+		return "", time.Time{}, logging.NewOperationError("usecase.sign_image_url", requestID, err)
+	}
+	return url, expiresAt, nil
 }
 
-// GetDuplicateReport builds a duplicate detection report for a verification request.
-func (uc *VerificationUseCase) GetDuplicateReport(ctx context.Context, userID, requestID string) (*DuplicateReport, error) {
+// GetDuplicateReport builds a duplicate detection report for a verification request,
+// with up to limit duplicates starting after cursor. An empty cursor starts at the
+// newest duplicate, and limits outside 1 to Options.MaxDuplicates return the maximum.
+func (uc *VerificationUseCase) GetDuplicateReport(ctx context.Context, userID, requestID, cursor string, limit int) (*DuplicateReport, error) {
+	var beforeID uint
+	if cursor != "" {
+		id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		beforeID = id
+	}
+	if maxDuplicates := uc.currentOptions().MaxDuplicates; limit <= 0 || limit > maxDuplicates {
+		limit = maxDuplicates
+	}
+
 	log, err := uc.repo.FindByRequestIDAndUser(ctx, requestID, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	duplicates, err := uc.repo.FindDuplicatesByHash(ctx, userID, log.SHA1Hash, log.RequestID)
+	// One extra duplicate tells whether another page follows.
+	duplicates, err := uc.repo.FindDuplicatesByHash(ctx, userID, log.SHA256Hash, log.SHA1Hash, log.RequestID, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	total, err := uc.repo.CountDuplicatesByHash(ctx, userID, log.SHA256Hash, log.SHA1Hash, log.RequestID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &DuplicateReport{
+	report := &DuplicateReport{
 		Request:    log,
 		Duplicates: duplicates,
-	}, nil
+		Total:      total,
+	}
+	if len(duplicates) > limit {
+		report.Duplicates = duplicates[:limit]
+		report.NextCursor = encodeCursor(report.Duplicates[limit-1].ID)
+	}
+	return report, nil
+}
+
+// FindDuplicates returns up to limit of the user's other verifications of the same
+// image as log, newest first.
+func (uc *VerificationUseCase) FindDuplicates(ctx context.Context, userID string, log *repository.VerificationLog, limit int) ([]*repository.VerificationLog, error) {
+	return uc.repo.FindDuplicatesByHash(ctx, userID, log.SHA256Hash, log.SHA1Hash, log.RequestID, 0, limit)
+}
+
+// findPriorVerification returns the user's completed verification of the image with
+// the given hashes, or nil when there is none. The pre-check only saves processing,
+// so a failed search lets the image be processed as usual.
+func (uc *VerificationUseCase) findPriorVerification(ctx context.Context, requestID, userID, sha256Hash, sha1Hash string) *repository.VerificationLog {
+	opLogger := logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.find_prior_verification", requestID)
+	existing, err := uc.repo.FindDuplicatesByHash(ctx, userID, sha256Hash, sha1Hash, "", 0, 1)
+	if err != nil {
+		opLogger.Warn("failed to search earlier verifications of the image", zap.Error(err))
+		return nil
+	}
+	// Logs saved before statuses were recorded have none and are completed.
+	if len(existing) == 0 || existing[0].Status == repository.StatusQueued || existing[0].Status == repository.StatusFailed {
+		return nil
+	}
+	// The result is read like GET /result/:id, which also loads its categories.
+	prior, err := uc.GetResult(ctx, userID, existing[0].RequestID)
+	if err != nil {
+		opLogger.Warn("failed to load the earlier verification of the image", zap.Error(err))
+		return nil
+	}
+	return prior
+}
+
+// priorResult answers an upload with the result of its earlier verification prior.
+func priorResult(prior *repository.VerificationLog) (*imageprocessor.Result, *VerificationMetadata) {
+	result := &imageprocessor.Result{
+		Success:      prior.Success,
+		Score:        prior.Score,
+		Message:      "image was already verified",
+		ModelVersion: prior.ModelVersion,
+	}
+	for _, category := range prior.Categories {
+		result.Categories = append(result.Categories, imageprocessor.CategoryScore{Category: category.Category, Score: category.Score})
+	}
+	metadata := newMetadata(prior)
+	metadata.DuplicateOf = prior.RequestID
+	return result, metadata
+}
+
+// findSameImage returns the request ID of the user's log that log collided with on
+// the unique SHA-1 index, or "" when it cannot be found. That log was saved moments
+// ago, so it is searched in the local database rather than the reader.
+func (uc *VerificationUseCase) findSameImage(ctx context.Context, requestID string, log *repository.VerificationLog) string {
+	existing, err := uc.repo.FindDuplicatesByHash(repository.WithPrimary(ctx), log.UserID, "", log.SHA1Hash, requestID, 0, 1)
+	if err != nil || len(existing) == 0 {
+		logging.WithOperation(logging.FromContext(ctx, uc.logger), "usecase.find_same_image", requestID).Warn("failed to find the earlier verification of the image", zap.Error(err))
+		return ""
+	}
+	return existing[0].RequestID
 }
 
 func (uc *VerificationUseCase) withRedisRetry(ctx context.Context, requestID, operation string, fn func() error) error {
-	if uc.retryAttempts <= 1 {
+	opts := uc.currentOptions()
+	if opts.RetryAttempts <= 1 {
 		err := fn()
 		return logging.NewOperationError(operation, requestID, err)
 	}
 
-	backoff := uc.initialBackoff
-	opLogger := logging.WithOperation(uc.logger, operation, requestID)
+	backoff := opts.InitialBackoff
+	opLogger := logging.WithOperation(logging.FromContext(ctx, uc.logger), operation, requestID)
 	var err error
-	for attempt := 0; attempt < uc.retryAttempts; attempt++ {
+	for attempt := 0; attempt < opts.RetryAttempts; attempt++ {
 		if attempt > 0 {
+			if !takeRetry(ctx) {
+				opLogger.Error("redis retry budget of the request exhausted", zap.Error(err), zap.Int("attempt", attempt))
+				return logging.NewOperationError(operation, requestID, err)
+			}
+			if uc.instruments != nil {
+				uc.instruments.ObserveRedisRetry(operation)
+			}
 			select {
 			case <-ctx.Done():
 				return logging.NewOperationError(operation, requestID, ctx.Err())
-			case <-time.After(backoff):
+			case <-time.After(jitter(backoff, opts.RetryJitter)):
 			}
-			if next := backoff * 2; next <= uc.maxBackoff {
+			if next := backoff * 2; next <= opts.MaxBackoff {
 				backoff = next
 			}
 		}
@@ -233,7 +1493,7 @@ func (uc *VerificationUseCase) withRedisRetry(ctx context.Context, requestID, op
 			return nil
 		}
 
-		if !isTransientError(err) || attempt == uc.retryAttempts-1 {
+		if !isTransientError(err) || attempt == opts.RetryAttempts-1 {
 			opLogger.Error("redis operation failed", zap.Error(err), zap.Int("attempt", attempt+1))
 			return logging.NewOperationError(operation, requestID, err)
 		}
@@ -278,5 +1538,24 @@ func isTransientError(err error) bool {
 		return true
 	}
 
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		return isTransientRedisReply(redisErr.Error())
+	}
+
 	return false
 }
+
+// isTransientRedisReply reports whether a Redis error reply clears up on its own. A
+// Redis Cluster answers MOVED and ASK while slots migrate, which go-redis follows up
+// to a limit, and TRYAGAIN or CLUSTERDOWN while a shard fails over. During a Sentinel
+// failover the old master answers READONLY and a replica still syncing LOADING.
+func isTransientRedisReply(reply string) bool {
+	prefix, _, _ := strings.Cut(reply, " ")
+	switch prefix {
+	case "MOVED", "ASK", "TRYAGAIN", "CLUSTERDOWN", "LOADING", "READONLY", "MASTERDOWN":
+		return true
+	default:
+		return false
+	}
+}
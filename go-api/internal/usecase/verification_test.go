@@ -1,19 +1,43 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
 
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/calibration"
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/imagemeta"
 	"github.com/example/ai-check/internal/imageprocessor"
 	"github.com/example/ai-check/internal/logging"
+	"github.com/example/ai-check/internal/malwarescan"
+	"github.com/example/ai-check/internal/phash"
 	"github.com/example/ai-check/internal/repository"
 )
 
@@ -27,6 +51,12 @@ type stubRepository struct {
 	dupErr     error
 	metrics    *repository.MetricsAggregation
 	metricsErr error
+	variants   []*repository.VariantAggregation
+	periods    []*repository.PeriodAggregation
+	listed     []*repository.VerificationLog
+	listArgs   []uint
+	completed  []*repository.VerificationLog
+	failed     []uint
 }
 
 func (s *stubRepository) SaveLog(ctx context.Context, log *repository.VerificationLog) error {
@@ -45,11 +75,60 @@ func (s *stubRepository) FindByRequestIDAndUser(ctx context.Context, requestID,
 	return nil, errors.New("not found")
 }
 
-func (s *stubRepository) FindDuplicatesByHash(ctx context.Context, userID, hash, excludeRequestID string) ([]*repository.VerificationLog, error) {
+func (s *stubRepository) FindDuplicatesByHash(ctx context.Context, userID, sha256Hash, sha1Hash, excludeRequestID string, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
 	if s.dupErr != nil {
 		return nil, s.dupErr
 	}
-	return s.duplicates, nil
+	var logs []*repository.VerificationLog
+	for _, log := range s.duplicates {
+		if (beforeID == 0 || log.ID < beforeID) && len(logs) < limit {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func (s *stubRepository) CountDuplicatesByHash(ctx context.Context, userID, sha256Hash, sha1Hash, excludeRequestID string) (int64, error) {
+	return int64(len(s.duplicates)), s.dupErr
+}
+
+func (s *stubRepository) ListByUser(ctx context.Context, userID string, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+	s.listArgs = append(s.listArgs, beforeID, uint(limit))
+	var logs []*repository.VerificationLog
+	for _, log := range s.listed {
+		if (beforeID == 0 || log.ID < beforeID) && len(logs) < limit {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func (s *stubRepository) FindByUser(ctx context.Context, userID string, filter repository.LogFilter, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+	s.listArgs = append(s.listArgs, beforeID, uint(limit))
+	var logs []*repository.VerificationLog
+	for _, log := range s.listed {
+		if filter.Success != nil && log.Success != *filter.Success {
+			continue
+		}
+		if (beforeID == 0 || log.ID < beforeID) && len(logs) < limit {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func (s *stubRepository) SearchLogs(ctx context.Context, filter repository.LogFilter, beforeID uint, limit int) ([]*repository.VerificationLog, error) {
+	return s.FindByUser(ctx, filter.UserID, filter, beforeID, limit)
+}
+
+func (s *stubRepository) ListHashedByUser(ctx context.Context, userID string, afterID uint, limit int) ([]*repository.VerificationLog, error) {
+	var logs []*repository.VerificationLog
+	for _, log := range s.listed {
+		if log.PerceptualHash != "" && log.ID > afterID && len(logs) < limit {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
 }
 
 func (s *stubRepository) AggregateMetrics(ctx context.Context) (*repository.MetricsAggregation, error) {
@@ -62,12 +141,54 @@ func (s *stubRepository) AggregateMetrics(ctx context.Context) (*repository.Metr
 	return s.metrics, nil
 }
 
+func (s *stubRepository) AggregateMetricsByVariant(ctx context.Context) ([]*repository.VariantAggregation, error) {
+	return s.variants, s.metricsErr
+}
+
+func (s *stubRepository) AggregateMetricsSeries(ctx context.Context, from, to time.Time, interval time.Duration) ([]*repository.PeriodAggregation, error) {
+	return s.periods, s.metricsErr
+}
+
+func (s *stubRepository) AggregateMetricsByUser(ctx context.Context, filter repository.LogFilter, afterUserID string, limit int) ([]*repository.UserAggregation, error) {
+	return nil, s.metricsErr
+}
+
+func (s *stubRepository) CompleteQueued(ctx context.Context, log *repository.VerificationLog) error {
+	s.completed = append(s.completed, log)
+	return nil
+}
+
+func (s *stubRepository) FailQueued(ctx context.Context, id uint, details string) error {
+	s.failed = append(s.failed, id)
+	return nil
+}
+
+func (s *stubRepository) SoftDeleteUserLogs(ctx context.Context, userID string, limit int, release func([]*repository.VerificationLog) error) (int, error) {
+	var batch, kept []*repository.VerificationLog
+	for _, log := range s.savedLogs {
+		if log.UserID == userID && len(batch) < limit {
+			batch = append(batch, log)
+		} else {
+			kept = append(kept, log)
+		}
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := release(batch); err != nil {
+		return 0, err
+	}
+	s.savedLogs = kept
+	return len(batch), nil
+}
+
 type stubCache struct {
-	setErrs   []error
-	getErrs   []error
-	getValues []string
-	setKeys   []string
-	getKeys   []string
+	setErrs    []error
+	getErrs    []error
+	getValues  []string
+	setKeys    []string
+	getKeys    []string
+	deleteKeys []string
 }
 
 func (s *stubCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
@@ -95,6 +216,11 @@ func (s *stubCache) Get(ctx context.Context, key string) (string, error) {
 	return value, err
 }
 
+func (s *stubCache) Delete(ctx context.Context, keys ...string) error {
+	s.deleteKeys = append(s.deleteKeys, keys...)
+	return nil
+}
+
 type stubProcessor struct {
 	result *imageprocessor.Result
 	err    error
@@ -107,6 +233,36 @@ func (s *stubProcessor) Process(ctx context.Context, userID string, imageBytes [
 	return s.result, nil
 }
 
+type stubImageStore struct {
+	objects map[string]string
+	bodies  map[string][]byte
+	putErr  error
+}
+
+func (s *stubImageStore) Put(ctx context.Context, key, contentType string, body io.ReadSeeker, size int64) error {
+	if s.putErr != nil {
+		return s.putErr
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("read %d bytes of a %d byte body", len(data), size)
+	}
+	if s.objects == nil {
+		s.objects = make(map[string]string)
+		s.bodies = make(map[string][]byte)
+	}
+	s.objects[key] = contentType
+	s.bodies[key] = data
+	return nil
+}
+
+func (s *stubImageStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "https://bucket.example/" + key + "?expires=" + ttl.String(), nil
+}
+
 type transientRedisError struct{}
 
 func (transientRedisError) Error() string   { return "redis transient" }
@@ -175,6 +331,80 @@ func TestVerifyImageReturnsOperationErrorOnCacheFailure(t *testing.T) {
 	}
 }
 
+func TestRedisRetriesShareTheRequestBudget(t *testing.T) {
+	opts := DefaultOptions()
+	opts.RetryAttempts = 5
+	opts.InitialBackoff = time.Millisecond
+	opts.MaxBackoff = time.Millisecond
+	opts.RetryBudget = 2
+	// The processing flag takes one retry, which leaves one for the result.
+	cache := &stubCache{setErrs: []error{transientRedisError{}, nil, transientRedisError{}, transientRedisError{}, transientRedisError{}}}
+	uc := NewVerificationUseCaseWithOptions(&stubRepository{}, cache, &stubProcessor{result: &imageprocessor.Result{Success: true}}, zap.NewNop(), opts)
+
+	_, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+	var opErr *logging.OperationError
+	if !errors.As(err, &opErr) || opErr.Operation != "cache.set.result" {
+		t.Fatalf("expected the result write to fail once the budget is spent, got %v", err)
+	}
+	if len(cache.setKeys) != 4 {
+		t.Fatalf("expected 2 attempts per write, got %d writes", len(cache.setKeys))
+	}
+
+	// Each request gets a budget of its own.
+	cache.setErrs, cache.setKeys = []error{transientRedisError{}, transientRedisError{}}, nil
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err != nil {
+		t.Fatalf("expected a fresh budget to absorb two retries, got %v", err)
+	}
+}
+
+type stubInstrumentation struct {
+	outcomes []string
+	retries  []string
+}
+
+func (s *stubInstrumentation) ObserveVerifyImage(outcome string, _ time.Duration) {
+	s.outcomes = append(s.outcomes, outcome)
+}
+
+func (s *stubInstrumentation) ObserveRedisRetry(operation string) {
+	s.retries = append(s.retries, operation)
+}
+
+func TestInstrumentationRecordsOutcomesAndRetries(t *testing.T) {
+	opts := DefaultOptions()
+	opts.InitialBackoff = time.Millisecond
+	opts.MaxBackoff = time.Millisecond
+	cache := &stubCache{setErrs: []error{transientRedisError{}}}
+	processor := &stubProcessor{result: &imageprocessor.Result{Success: true}}
+	uc := NewVerificationUseCaseWithOptions(&stubRepository{}, cache, processor, zap.NewNop(), opts)
+	instruments := &stubInstrumentation{}
+	uc.SetInstrumentation(instruments)
+
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	processor.err = errors.New("processor down")
+	uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+
+	if want := []string{OutcomeVerified, OutcomeFailed}; !reflect.DeepEqual(instruments.outcomes, want) {
+		t.Fatalf("expected outcomes %v, got %v", want, instruments.outcomes)
+	}
+	if want := []string{"cache.set.processing"}; !reflect.DeepEqual(instruments.retries, want) {
+		t.Fatalf("expected retries %v, got %v", want, instruments.retries)
+	}
+}
+
+func TestJitterShortensBackoffWithinTheFraction(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if got := jitter(100*time.Millisecond, 0.5); got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("expected a backoff between 50ms and 100ms, got %s", got)
+		}
+	}
+	if got := jitter(100*time.Millisecond, 0); got != 100*time.Millisecond {
+		t.Fatalf("expected no jitter to keep the backoff, got %s", got)
+	}
+}
+
 func TestGetResultFallsBackToRepositoryWhenCacheMiss(t *testing.T) {
 	cache := &stubCache{getErrs: []error{redis.Nil}}
 	expected := &repository.VerificationLog{RequestID: "req", UserID: "user", Details: "from-db", SHA1Hash: "abc"}
@@ -196,13 +426,14 @@ func TestGetResultFallsBackToRepositoryWhenCacheMiss(t *testing.T) {
 func TestGetResultReturnsCachedPayload(t *testing.T) {
 	createdAt := time.Date(2024, time.January, 15, 10, 30, 0, 0, time.UTC)
 	payload := cachedVerification{
-		RequestID: "req-123",
-		UserID:    "user-42",
-		Score:     0.88,
-		Success:   true,
-		Details:   "cached-details",
-		Hash:      "abc123",
-		CreatedAt: createdAt,
+		RequestID:     "req-123",
+		UserID:        "user-42",
+		Score:         0.88,
+		Success:       true,
+		Details:       "cached-details",
+		Hash:          "abc123",
+		CreatedAt:     createdAt,
+		ImageMetadata: &imagemeta.Metadata{CameraModel: "Pixel 9"},
 	}
 	data, err := json.Marshal(payload)
 	if err != nil {
@@ -241,6 +472,213 @@ func TestGetResultReturnsCachedPayload(t *testing.T) {
 	if !log.CreatedAt.Equal(payload.CreatedAt) {
 		t.Fatalf("expected created_at %s, got %s", payload.CreatedAt, log.CreatedAt)
 	}
+	if log.ImageMetadata == nil || log.ImageMetadata.CameraModel != "Pixel 9" {
+		t.Fatalf("expected the cached image metadata, got %+v", log.ImageMetadata)
+	}
+}
+
+// slowRepository holds every result lookup until release is closed.
+type slowRepository struct {
+	stubRepository
+	release chan struct{}
+	lookups atomic.Int32
+}
+
+func (s *slowRepository) FindByRequestIDAndUser(ctx context.Context, requestID, userID string) (*repository.VerificationLog, error) {
+	s.lookups.Add(1)
+	<-s.release
+	if requestID == "missing" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &repository.VerificationLog{RequestID: requestID, UserID: userID, Score: 0.7}, nil
+}
+
+func TestGetResultCoalescesLookupsAndCachesMissingResults(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	repo := &slowRepository{release: make(chan struct{})}
+	uc := NewVerificationUseCase(repo, NewRedisCache(client), &stubProcessor{result: &imageprocessor.Result{}}, zap.NewNop())
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	logs := make([]*repository.VerificationLog, 5)
+	for i := range logs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log, err := uc.GetResult(ctx, "user-1", "req-1")
+			if err != nil {
+				t.Errorf("GetResult returned error: %v", err)
+			}
+			logs[i] = log
+		}()
+	}
+	// Let every caller join the first lookup before it returns.
+	time.Sleep(50 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+	if n := repo.lookups.Load(); n != 1 {
+		t.Fatalf("expected concurrent lookups to share one query, got %d", n)
+	}
+	if logs[0] == nil || logs[0] == logs[1] || logs[1].Score != 0.7 {
+		t.Fatalf("expected each caller to get its own copy of the result, got %+v and %+v", logs[0], logs[1])
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := uc.GetResult(ctx, "user-1", "missing"); err == nil {
+			t.Fatal("expected a missing result to be reported")
+		}
+	}
+	// The miss is confirmed on the local database before it is cached.
+	if n := repo.lookups.Load(); n != 3 {
+		t.Fatalf("expected the missing result to be cached after one lookup, got %d queries", n)
+	}
+	// Another user's lookup neither reads nor replaces the entry.
+	if _, err := uc.GetResult(ctx, "user-2", "missing"); err == nil || repo.lookups.Load() != 4 {
+		t.Fatalf("expected another user to query the database, got %v", err)
+	}
+	server.FastForward(3 * time.Second)
+	if _, err := uc.GetResult(ctx, "user-1", "missing"); err == nil || repo.lookups.Load() != 6 {
+		t.Fatalf("expected the missing entry to expire, got %v after %d queries", err, repo.lookups.Load())
+	}
+}
+
+func TestGetResultDoesNotCacheResultsMissingFromTheReader(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	openDatabase := func() *gorm.DB {
+		db, err := devmode.OpenDatabase(":memory:")
+		if err != nil {
+			t.Fatalf("OpenDatabase returned error: %v", err)
+		}
+		if err := repository.NewVerificationRepository(db, zap.NewNop()).AutoMigrate(ctx); err != nil {
+			t.Fatalf("AutoMigrate returned error: %v", err)
+		}
+		return db
+	}
+	repo := repository.NewVerificationRepository(openDatabase(), zap.NewNop())
+	if err := repo.SaveLog(ctx, &repository.VerificationLog{RequestID: "req-1", UserID: "user-1", SHA1Hash: "hash-1", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	// The reader lacks the log, as a replica does until it catches up.
+	repo.SetReader(openDatabase())
+	uc := NewVerificationUseCase(repo, NewRedisCache(client), &stubProcessor{result: &imageprocessor.Result{}}, zap.NewNop())
+
+	log, err := uc.GetResult(ctx, "user-1", "req-1")
+	if err != nil || log.RequestID != "req-1" {
+		t.Fatalf("expected the result to be read from the local database, got %+v, %v", log, err)
+	}
+	if cached, err := server.Get(uc.cacheKey("", "req-1")); err == nil && strings.Contains(cached, `"missing":true`) {
+		t.Fatalf("expected the result not to be cached as missing, got %s", cached)
+	}
+}
+
+// countingMetricsRepository counts the aggregations of the metrics summary.
+type countingMetricsRepository struct {
+	stubRepository
+	mu           sync.Mutex
+	aggregations int
+}
+
+func (s *countingMetricsRepository) AggregateMetrics(ctx context.Context) (*repository.MetricsAggregation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aggregations++
+	return &repository.MetricsAggregation{TotalCount: int64(s.aggregations)}, nil
+}
+
+func (s *countingMetricsRepository) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.aggregations
+}
+
+func TestGetMetricsSummaryServesCachedAndStaleSummaries(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	repo := &countingMetricsRepository{}
+	opts := DefaultOptions()
+	opts.MetricsSummaryTTL = 50 * time.Millisecond
+	opts.MetricsSummaryStaleTTL = time.Minute
+	uc := NewVerificationUseCaseWithOptions(repo, NewRedisCache(client), &stubProcessor{result: &imageprocessor.Result{}}, zap.NewNop(), opts)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		summary, err := uc.GetMetricsSummary(ctx)
+		if err != nil || summary.TotalRequests != 1 {
+			t.Fatalf("expected the first summary, got %+v, %v", summary, err)
+		}
+	}
+	if n := repo.count(); n != 1 {
+		t.Fatalf("expected one aggregation while the summary is fresh, got %d", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	summary, err := uc.GetMetricsSummary(ctx)
+	if err != nil || summary.TotalRequests != 1 {
+		t.Fatalf("expected the stale summary to be served, got %+v, %v", summary, err)
+	}
+	for deadline := time.Now().Add(time.Second); summary.TotalRequests != 2 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		if summary, err = uc.GetMetricsSummary(ctx); err != nil {
+			t.Fatalf("GetMetricsSummary returned error: %v", err)
+		}
+	}
+	if summary.TotalRequests != 2 || repo.count() != 2 {
+		t.Fatalf("expected the summary refreshed once in the background, got %+v after %d aggregations", summary, repo.count())
+	}
+
+	server.FastForward(2 * time.Minute)
+	if summary, err := uc.GetMetricsSummary(ctx); err != nil || summary.TotalRequests != 3 {
+		t.Fatalf("expected an expired summary to be recomputed, got %+v, %v", summary, err)
+	}
+}
+
+// tenantMetricsRepository totals as many verifications as the caller's tenant ID
+// is long, and 100 for callers without a user.
+type tenantMetricsRepository struct {
+	stubRepository
+}
+
+func (tenantMetricsRepository) AggregateMetrics(ctx context.Context) (*repository.MetricsAggregation, error) {
+	if _, ok := auth.GetUserID(ctx); !ok {
+		return &repository.MetricsAggregation{TotalCount: 100}, nil
+	}
+	tenantID, _ := auth.GetTenantID(ctx)
+	return &repository.MetricsAggregation{TotalCount: int64(len(tenantID))}, nil
+}
+
+func TestGetMetricsSummaryCachesEachTenantApart(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	uc := NewVerificationUseCaseWithOptions(&tenantMetricsRepository{}, NewRedisCache(client), &stubProcessor{result: &imageprocessor.Result{}}, zap.NewNop(), DefaultOptions())
+
+	user := auth.WithUserID(context.Background(), "user-1")
+	for _, caller := range []struct {
+		ctx   context.Context
+		total int64
+	}{
+		{auth.WithTenantID(user, "acme"), 4},
+		{auth.WithTenantID(user, "globex"), 6},
+		{user, 0},
+		{context.Background(), 100},
+	} {
+		// The second call is answered from the cache.
+		for i := 0; i < 2; i++ {
+			summary, err := uc.GetMetricsSummary(caller.ctx)
+			if err != nil || summary.TotalRequests != caller.total {
+				t.Fatalf("expected %d verifications, got %+v, %v", caller.total, summary, err)
+			}
+		}
+	}
+	if keys := server.Keys(); len(keys) != 4 {
+		t.Fatalf("expected a cached summary per tenant, got %v", keys)
+	}
 }
 
 func TestGetMetricsSummaryComputesSuccessRate(t *testing.T) {
@@ -283,3 +721,977 @@ func TestGetMetricsSummaryPropagatesRepositoryError(t *testing.T) {
 		t.Fatal("expected error, got nil")
 	}
 }
+
+func TestVerifyImageStoresImageAndSignsURL(t *testing.T) {
+	repo := &stubRepository{}
+	store := &stubImageStore{}
+	uc := NewVerificationUseCase(repo, &stubCache{}, &stubProcessor{result: &imageprocessor.Result{Success: true}}, zap.NewNop())
+	uc.SetImageStore(store)
+
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+	requestID, _, _, err := uc.VerifyImage(context.Background(), "user-1", png)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	key := "user-1/" + requestID
+	if repo.savedLogs[0].ImageKey != key || store.objects[key] != "image/png" {
+		t.Fatalf("expected the image to be stored under %s, got log key %q and objects %v", key, repo.savedLogs[0].ImageKey, store.objects)
+	}
+
+	repo.findLog = repo.savedLogs[0]
+	url, expiresAt, err := uc.ImageURL(context.Background(), "user-1", requestID)
+	if err != nil {
+		t.Fatalf("ImageURL returned error: %v", err)
+	}
+	if url != "https://bucket.example/"+key+"?expires=15m0s" || time.Until(expiresAt) <= 14*time.Minute {
+		t.Fatalf("unexpected signed URL %s expiring at %s", url, expiresAt)
+	}
+
+	repo.findLog = &repository.VerificationLog{RequestID: "old", UserID: "user-1"}
+	if _, _, err := uc.ImageURL(context.Background(), "user-1", "old"); !errors.Is(err, ErrImageNotStored) {
+		t.Fatalf("expected ErrImageNotStored for a log without an image, got %v", err)
+	}
+	repo.findLog = nil
+	if _, _, err := uc.ImageURL(context.Background(), "user-1", "missing"); !errors.Is(err, ErrResultNotFound) {
+		t.Fatalf("expected ErrResultNotFound, got %v", err)
+	}
+}
+
+func TestVerifyImageSpoolsLargeImagesToDisk(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.SpoolThreshold = 1 << 10
+	opts.SpoolDir = dir
+	repo := &stubRepository{}
+	store := &stubImageStore{}
+	uc := NewVerificationUseCaseWithOptions(repo, &stubCache{}, &stubProcessor{result: &imageprocessor.Result{Success: true}}, zap.NewNop(), opts)
+	uc.SetImageStore(store)
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{7}, 64<<10)...)
+	requestID, _, _, err := uc.VerifyImage(context.Background(), "user-1", png)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	key := "user-1/" + requestID
+	if !bytes.Equal(store.bodies[key], png) || store.objects[key] != "image/png" {
+		t.Fatalf("expected the whole image to be stored as image/png, got %d bytes as %q", len(store.bodies[key]), store.objects[key])
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("expected the spool file to be removed, got %v (%v)", entries, err)
+	}
+}
+
+func TestVerifyImageFailsWhenImageCannotBeStored(t *testing.T) {
+	repo := &stubRepository{}
+	uc := NewVerificationUseCase(repo, &stubCache{}, &stubProcessor{result: &imageprocessor.Result{Success: true}}, zap.NewNop())
+	uc.SetImageStore(&stubImageStore{putErr: errors.New("bucket unavailable")})
+
+	_, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+	var opErr *logging.OperationError
+	if !errors.As(err, &opErr) || opErr.Operation != "usecase.store_image" {
+		t.Fatalf("expected a store_image operation error, got %v", err)
+	}
+	if len(repo.savedLogs) != 0 {
+		t.Fatal("expected no log to be saved without its image")
+	}
+}
+
+// disconnectingProcessor cancels the caller's context while it processes.
+type disconnectingProcessor struct {
+	disconnect context.CancelFunc
+	ctxErr     error
+}
+
+func (d *disconnectingProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
+	d.disconnect()
+	d.ctxErr = ctx.Err()
+	return &imageprocessor.Result{Success: true, Score: 0.8}, nil
+}
+
+func TestVerifyImageCompletesWhenTheCallerGoesAway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := &stubRepository{}
+	processor := &disconnectingProcessor{disconnect: cancel}
+	uc := NewVerificationUseCase(repo, &stubCache{}, processor, zap.NewNop())
+
+	requestID, _, _, err := uc.VerifyImage(ctx, "user-1", []byte("image"))
+	if err != nil || processor.ctxErr != nil {
+		t.Fatalf("expected the verification to complete on a detached context, got %v (processor saw %v)", err, processor.ctxErr)
+	}
+	if len(repo.savedLogs) != 1 || repo.savedLogs[0].RequestID != requestID {
+		t.Fatalf("expected the result to be saved under %s, got %+v", requestID, repo.savedLogs)
+	}
+
+	opts := DefaultOptions()
+	opts.DetachedTimeout = 0
+	uc.UpdateOptions(opts)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	processor.disconnect = cancel
+	if _, _, _, err := uc.VerifyImage(ctx, "user-1", []byte("image")); !errors.Is(processor.ctxErr, context.Canceled) {
+		t.Fatalf("expected a zero DetachedTimeout to cancel with the caller, got %v (processor saw %v)", err, processor.ctxErr)
+	}
+}
+
+type stubPublisher struct {
+	events []Event
+	err    error
+}
+
+func (s *stubPublisher) Publish(ctx context.Context, event Event) error {
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func TestVerifyImagePublishesEvents(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		result *imageprocessor.Result
+		want   []string
+	}{
+		{"confident", &imageprocessor.Result{Success: true, Score: 0.9}, []string{EventVerificationCompleted}},
+		{"low score", &imageprocessor.Result{Success: true, Score: 0.3}, []string{EventVerificationCompleted, EventVerificationNeedsReview}},
+		{"rejected", &imageprocessor.Result{Success: false, Score: 0.9}, []string{EventVerificationCompleted, EventVerificationNeedsReview}},
+		{"flagged category", &imageprocessor.Result{Success: true, Score: 0.9, Categories: []imageprocessor.CategoryScore{{Category: imageprocessor.CategoryNSFW, Score: 0.8}}}, []string{EventVerificationCompleted, EventVerificationNeedsReview}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// A failing publisher must not fail the verification.
+			publisher := &stubPublisher{err: errors.New("queue unavailable")}
+			uc := NewVerificationUseCase(&stubRepository{}, &stubCache{}, &stubProcessor{result: tc.result}, zap.NewNop())
+			uc.SetEventPublisher(publisher)
+
+			requestID, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+			if err != nil {
+				t.Fatalf("VerifyImage returned error: %v", err)
+			}
+			if len(publisher.events) != len(tc.want) {
+				t.Fatalf("expected events %v, got %+v", tc.want, publisher.events)
+			}
+			for i, event := range publisher.events {
+				data, ok := event.Data.(VerificationEvent)
+				if event.Type != tc.want[i] || event.UserID != "user-1" || !ok || data.RequestID != requestID {
+					t.Fatalf("unexpected event %+v", event)
+				}
+			}
+			if len(publisher.events) == 2 && publisher.events[0].ID == publisher.events[1].ID {
+				t.Fatal("expected distinct event IDs per event type")
+			}
+		})
+	}
+}
+
+func TestVerifyImagePublishesFailures(t *testing.T) {
+	publisher := &stubPublisher{}
+	uc := NewVerificationUseCase(&stubRepository{}, &stubCache{}, &stubProcessor{err: errors.New("processor down")}, zap.NewNop())
+	uc.SetEventPublisher(publisher)
+
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err == nil {
+		t.Fatal("expected the processor failure to fail the verification")
+	}
+	if len(publisher.events) != 1 {
+		t.Fatalf("expected one event, got %+v", publisher.events)
+	}
+	event := publisher.events[0]
+	data, ok := event.Data.(VerificationFailedEvent)
+	if event.Type != EventVerificationFailed || event.UserID != "user-1" || !ok || data.RequestID == "" || data.Reason != "usecase.grpc_process_image" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+}
+
+type stubOutbox struct {
+	events []*repository.OutboxEvent
+}
+
+func (s *stubOutbox) Add(ctx context.Context, events ...*repository.OutboxEvent) error {
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func TestVerifyImageSavesEventsInTheOutbox(t *testing.T) {
+	publisher := &stubPublisher{}
+	outbox := &stubOutbox{}
+	repo := &stubRepository{}
+	uc := NewVerificationUseCase(repo, &stubCache{}, &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.3}}, zap.NewNop())
+	uc.SetEventPublisher(publisher)
+	uc.SetOutbox(outbox)
+
+	requestID, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+	if err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if len(publisher.events) != 0 {
+		t.Fatalf("expected no event to be published directly, got %+v", publisher.events)
+	}
+	staged := repo.savedLogs[0].Outbox
+	if len(staged) != 2 || staged[0].Type != EventVerificationCompleted || staged[1].Type != EventVerificationNeedsReview {
+		t.Fatalf("expected both events to be saved with the log, got %+v", staged)
+	}
+	event, err := DecodeOutboxEvent(staged[0])
+	if data, ok := event.Data.(VerificationEvent); err != nil || !ok || data.RequestID != requestID || event.UserID != "user-1" {
+		t.Fatalf("expected the saved event to decode, got %+v (%v)", event, err)
+	}
+
+	// Failures have no log to go with, so they are added on their own.
+	uc = NewVerificationUseCase(&stubRepository{}, &stubCache{}, &stubProcessor{err: errors.New("processor down")}, zap.NewNop())
+	uc.SetEventPublisher(publisher)
+	uc.SetOutbox(outbox)
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err == nil {
+		t.Fatal("expected the processor failure to fail the verification")
+	}
+	if len(publisher.events) != 0 || len(outbox.events) != 1 || outbox.events[0].Type != EventVerificationFailed {
+		t.Fatalf("expected the failure to be added to the outbox, got %+v and %+v", publisher.events, outbox.events)
+	}
+}
+
+type stubObserver struct {
+	verified, rejected, failed int
+}
+
+func (o *stubObserver) ObserveVerification(_ string, verified bool, _ time.Duration) {
+	if verified {
+		o.verified++
+	} else {
+		o.rejected++
+	}
+}
+
+func (o *stubObserver) ObserveFailure(string) {
+	o.failed++
+}
+
+func TestVerifyImageReportsToTheMetricsObserver(t *testing.T) {
+	observer := &stubObserver{}
+	processor := &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.9}}
+	uc := NewVerificationUseCase(&stubRepository{}, &stubCache{}, processor, zap.NewNop())
+	uc.SetMetricsObserver(observer)
+
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	processor.result, processor.err = nil, errors.New("processor down")
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err == nil {
+		t.Fatal("expected the processor failure to fail the verification")
+	}
+	if observer.verified != 1 || observer.rejected != 0 || observer.failed != 1 {
+		t.Fatalf("unexpected observations %+v", observer)
+	}
+}
+
+type stubLimiter struct {
+	full     bool
+	released int
+	observed []string
+}
+
+func (l *stubLimiter) Acquire() bool { return !l.full }
+func (l *stubLimiter) Release()      { l.released++ }
+func (l *stubLimiter) Observe(dependency string, _ time.Duration, _ error) {
+	l.observed = append(l.observed, dependency)
+}
+
+func TestVerifyImageShedsWhenTheLimiterIsFull(t *testing.T) {
+	observer := &stubObserver{}
+	limiter := &stubLimiter{}
+	processor := &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.9}}
+	repo := &stubRepository{}
+	uc := NewVerificationUseCase(repo, &stubCache{}, processor, zap.NewNop())
+	uc.SetMetricsObserver(observer)
+	uc.SetConcurrencyLimiter(limiter)
+
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if limiter.released != 1 || fmt.Sprint(limiter.observed) != "[processor database]" {
+		t.Fatalf("expected the slot to be released after reporting both dependencies, got %+v", limiter)
+	}
+
+	limiter.full = true
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expected ErrOverloaded, got %v", err)
+	}
+	if len(repo.savedLogs) != 1 || observer.failed != 0 || limiter.released != 1 {
+		t.Fatalf("expected the shed verification to do nothing, got %d logs, %+v, %+v", len(repo.savedLogs), observer, limiter)
+	}
+}
+
+type stubLogBuffer struct {
+	logs []*repository.VerificationLog
+	err  error
+}
+
+func (b *stubLogBuffer) Add(ctx context.Context, log *repository.VerificationLog) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.logs = append(b.logs, log)
+	return nil
+}
+
+func TestVerifyImageBuffersLogsWhileTheDatabaseIsUnavailable(t *testing.T) {
+	repo := &stubRepository{saveErr: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	cache := &stubCache{}
+	buffer := &stubLogBuffer{}
+	uc := NewVerificationUseCase(repo, cache, &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.9}}, zap.NewNop())
+	uc.SetLogBuffer(buffer)
+
+	requestID, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+	if err != nil {
+		t.Fatalf("expected the verification to succeed, got %v", err)
+	}
+	if len(buffer.logs) != 1 || buffer.logs[0].RequestID != requestID {
+		t.Fatalf("expected the log to be buffered, got %+v", buffer.logs)
+	}
+	if len(cache.setKeys) != 2 {
+		t.Fatalf("expected the result to be cached, got %v", cache.setKeys)
+	}
+
+	buffer.err = errors.New("redis down")
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err == nil {
+		t.Fatal("expected the verification to fail when the log cannot be buffered either")
+	}
+	// Other database errors are not buffered.
+	buffer.err, repo.saveErr = nil, errors.New("constraint violated")
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err == nil || len(buffer.logs) != 1 {
+		t.Fatalf("expected a failed query to fail the verification, got %v and %d buffered logs", err, len(buffer.logs))
+	}
+}
+
+// openingImageStore is a stubImageStore that reads its images back.
+type openingImageStore struct {
+	stubImageStore
+}
+
+func (s *openingImageStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, ok := s.bodies[key]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
+
+type stubDeferredQueue struct {
+	requests []string
+	err      error
+}
+
+func (q *stubDeferredQueue) Defer(ctx context.Context, requestID, userID string) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.requests = append(q.requests, requestID)
+	return nil
+}
+
+func TestVerifyImageQueuesWhileTheProcessorIsUnavailable(t *testing.T) {
+	ctx := context.Background()
+	repo := &stubRepository{}
+	cache := &stubCache{}
+	processor := &stubProcessor{err: status.Error(codes.Unavailable, "connection refused")}
+	queue := &stubDeferredQueue{}
+	uc := NewVerificationUseCase(repo, cache, processor, zap.NewNop())
+	uc.SetImageStore(&openingImageStore{})
+	uc.SetDeferredQueue(queue)
+
+	_, _, _, err := uc.VerifyImage(ctx, "user-1", []byte("image"))
+	var queuedErr *QueuedError
+	if !errors.As(err, &queuedErr) {
+		t.Fatalf("expected the verification to be queued, got %v", err)
+	}
+	queued := repo.savedLogs[0]
+	if queued.Status != repository.StatusQueued || queued.ImageKey != "user-1/"+queuedErr.RequestID || queued.SHA256Hash == "" {
+		t.Fatalf("expected a queued log with its image and hashes, got %+v", queued)
+	}
+	if len(queue.requests) != 1 || queue.requests[0] != queuedErr.RequestID {
+		t.Fatalf("expected the request to be deferred, got %v", queue.requests)
+	}
+
+	// The worker completes it once the processor is back.
+	processor.err = nil
+	processor.result = &imageprocessor.Result{Success: true, Score: 0.8, ModelVersion: "v2"}
+	repo.findLog = queued
+	if err := uc.ProcessQueued(ctx, queuedErr.RequestID, "user-1"); err != nil {
+		t.Fatalf("ProcessQueued returned error: %v", err)
+	}
+	if len(repo.completed) != 1 || repo.completed[0].Score != 0.8 || repo.completed[0].ModelVersion != "v2" {
+		t.Fatalf("expected the queued log to be completed, got %+v", repo.completed)
+	}
+	if last := cache.setKeys[len(cache.setKeys)-1]; last != uc.cacheKey("", queuedErr.RequestID) {
+		t.Fatalf("expected the result to be cached, got %v", cache.setKeys)
+	}
+
+	// Completed verifications are not processed again.
+	repo.findLog = &repository.VerificationLog{RequestID: "done", Status: repository.StatusCompleted}
+	if err := uc.ProcessQueued(ctx, "done", "user-1"); err != nil || len(repo.completed) != 1 {
+		t.Fatalf("expected a completed verification to be skipped, got %v", err)
+	}
+
+	// A verification that cannot be queued fails, as without a queue.
+	processor.err = status.Error(codes.Unavailable, "connection refused")
+	queue.err = errors.New("redis down")
+	repo.savedLogs = nil
+	if _, _, _, err := uc.VerifyImage(ctx, "user-1", []byte("other image")); status.Code(err) != codes.Unavailable || len(repo.failed) != 1 {
+		t.Fatalf("expected the processor error and the log marked failed, got %v and %v", err, repo.failed)
+	}
+}
+
+func TestVerifyImageAppliesCategoryThresholds(t *testing.T) {
+	repo := &stubRepository{}
+	processor := &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.9, Categories: []imageprocessor.CategoryScore{
+		{Category: imageprocessor.CategoryWatermarked, Score: 0.6},
+		{Category: imageprocessor.CategoryAIGenerated, Score: 0.95},
+		{Category: imageprocessor.CategoryNSFW, Score: 0.4},
+		{Category: "violence", Score: 0.99},
+	}}}
+	opts := DefaultOptions()
+	opts.CategoryThresholds = map[string]float32{
+		imageprocessor.CategoryAIGenerated: 0.9,
+		imageprocessor.CategoryNSFW:        0.3,
+		imageprocessor.CategoryWatermarked: 0.7,
+	}
+	cache := &stubCache{}
+	uc := NewVerificationUseCaseWithOptions(repo, cache, processor, zap.NewNop(), opts)
+
+	_, _, metadata, err := uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+	if err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	want := []CategoryOutcome{
+		{Category: imageprocessor.CategoryAIGenerated, Score: 0.95, Threshold: 0.9, Flagged: true},
+		{Category: imageprocessor.CategoryNSFW, Score: 0.4, Threshold: 0.3, Flagged: true},
+		{Category: "violence", Score: 0.99},
+		{Category: imageprocessor.CategoryWatermarked, Score: 0.6, Threshold: 0.7},
+	}
+	if len(metadata.Categories) != len(want) {
+		t.Fatalf("expected %+v, got %+v", want, metadata.Categories)
+	}
+	for i := range want {
+		if metadata.Categories[i] != want[i] {
+			t.Fatalf("expected %+v, got %+v", want, metadata.Categories)
+		}
+	}
+	if saved := repo.savedLogs[0].Categories; len(saved) != len(want) || !saved[0].Flagged || saved[3].Flagged {
+		t.Fatalf("unexpected persisted categories %+v", saved)
+	}
+}
+
+func TestVerifyImageCalibratesScoresPerModelVersion(t *testing.T) {
+	repo := &stubRepository{}
+	processor := &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.7, ModelVersion: "3", Categories: []imageprocessor.CategoryScore{
+		{Category: imageprocessor.CategoryNSFW, Score: 0.7},
+	}}}
+	opts := DefaultOptions()
+	opts.ReviewThreshold = 0.55
+	opts.Calibration = calibration.Curves{"3": {{Raw: 0.6, Calibrated: 0.4}, {Raw: 0.8, Calibrated: 0.6}}}
+	publisher := &stubPublisher{}
+	uc := NewVerificationUseCaseWithOptions(repo, &stubCache{}, processor, zap.NewNop(), opts)
+	uc.SetEventPublisher(publisher)
+
+	_, result, metadata, err := uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+	if err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if math.Abs(float64(result.Score-0.5)) > 1e-6 || metadata.Score != result.Score || metadata.RawScore != 0.7 || metadata.ModelVersion != "3" {
+		t.Fatalf("expected a calibrated score of 0.5 from a raw 0.7, got result %+v and metadata %+v", result, metadata)
+	}
+	saved := repo.savedLogs[0]
+	if saved.Score != result.Score || saved.RawScore != 0.7 || saved.ModelVersion != "3" {
+		t.Fatalf("unexpected persisted scores %+v", saved)
+	}
+	// Category scores are not calibrated.
+	if saved.Categories[0].Score != 0.7 {
+		t.Fatalf("expected the category score to stay raw, got %+v", saved.Categories)
+	}
+	// The raw score clears the review threshold, the calibrated one does not.
+	if len(publisher.events) != 2 || publisher.events[1].Type != EventVerificationNeedsReview {
+		t.Fatalf("expected the calibrated score to need review, got %+v", publisher.events)
+	}
+}
+
+type stubTenantPolicies struct {
+	policy *TenantPolicy
+	err    error
+}
+
+func (p *stubTenantPolicies) Policy(context.Context) (*TenantPolicy, error) {
+	return p.policy, p.err
+}
+
+func TestVerifyImageAppliesTenantPolicies(t *testing.T) {
+	repo := &stubRepository{}
+	processor := &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.8, Categories: []imageprocessor.CategoryScore{
+		{Category: imageprocessor.CategoryAIGenerated, Score: 0.95},
+		{Category: imageprocessor.CategoryNSFW, Score: 0.4},
+	}}}
+	opts := DefaultOptions()
+	opts.ReviewThreshold = 0.5
+	opts.CategoryThresholds = map[string]float32{imageprocessor.CategoryAIGenerated: 0.9}
+	uc := NewVerificationUseCaseWithOptions(repo, &stubCache{}, processor, zap.NewNop(), opts)
+	publisher := &stubPublisher{}
+	uc.SetEventPublisher(publisher)
+	reviewThreshold := float32(0.85)
+	policies := &stubTenantPolicies{policy: &TenantPolicy{
+		TenantID:           "acme",
+		ReviewThreshold:    &reviewThreshold,
+		CategoryThresholds: map[string]float32{imageprocessor.CategoryNSFW: 0.3},
+	}}
+	uc.SetTenantPolicies(policies)
+
+	_, _, metadata, err := uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+	if err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if len(metadata.Categories) != 2 || !metadata.Categories[0].Flagged || metadata.Categories[1].Threshold != 0.3 {
+		t.Fatalf("expected the tenant thresholds merged into the global ones, got %+v", metadata.Categories)
+	}
+	if repo.savedLogs[0].TenantID != "acme" {
+		t.Fatalf("expected the log to record the tenant, got %q", repo.savedLogs[0].TenantID)
+	}
+	if len(publisher.events) != 2 || publisher.events[1].Type != EventVerificationNeedsReview || publisher.events[1].TenantID != "acme" {
+		t.Fatalf("expected the tenant review threshold to apply, got %+v", publisher.events)
+	}
+	if opts := uc.currentOptions(); opts.ReviewThreshold != 0.5 || len(opts.CategoryThresholds) != 1 {
+		t.Fatalf("expected the global options to stay unchanged, got %+v", opts)
+	}
+
+	policies.policy, policies.err = nil, errors.New("redis down")
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err == nil {
+		t.Fatal("expected a policy failure to fail the verification")
+	}
+}
+
+func TestVerifyImageDecidesVerdictsFromThresholds(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ReviewThreshold = 0.6
+	opts.RejectThreshold = 0.2
+	opts.CategoryThresholds = map[string]float32{imageprocessor.CategoryNSFW: 0.5}
+	for _, tc := range []struct {
+		name    string
+		result  imageprocessor.Result
+		verdict string
+	}{
+		{"verified", imageprocessor.Result{Success: true, Score: 0.9}, VerdictVerified},
+		{"below review threshold", imageprocessor.Result{Success: true, Score: 0.4}, VerdictNeedsReview},
+		{"not verified by the processor", imageprocessor.Result{Success: false, Score: 0.9}, VerdictNeedsReview},
+		{"flagged category", imageprocessor.Result{Success: true, Score: 0.9, Categories: []imageprocessor.CategoryScore{{Category: imageprocessor.CategoryNSFW, Score: 0.7}}}, VerdictNeedsReview},
+		{"below reject threshold", imageprocessor.Result{Success: true, Score: 0.1}, VerdictRejected},
+	} {
+		repo := &stubRepository{}
+		result := tc.result
+		uc := NewVerificationUseCaseWithOptions(repo, &stubCache{}, &stubProcessor{result: &result}, zap.NewNop(), opts)
+		publisher := &stubPublisher{}
+		uc.SetEventPublisher(publisher)
+
+		_, _, metadata, err := uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+		if err != nil {
+			t.Fatalf("%s: VerifyImage returned error: %v", tc.name, err)
+		}
+		if metadata.Verdict != tc.verdict || repo.savedLogs[0].Verdict != tc.verdict {
+			t.Fatalf("%s: expected verdict %s, got %q (saved %q)", tc.name, tc.verdict, metadata.Verdict, repo.savedLogs[0].Verdict)
+		}
+		if needsReview := len(publisher.events) == 2; needsReview != (tc.verdict == VerdictNeedsReview) {
+			t.Fatalf("%s: expected needs_review only for that verdict, got %+v", tc.name, publisher.events)
+		}
+	}
+
+	rejectThreshold := float32(0.95)
+	repo := &stubRepository{}
+	uc := NewVerificationUseCaseWithOptions(repo, &stubCache{}, &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.9}}, zap.NewNop(), opts)
+	uc.SetTenantPolicies(&stubTenantPolicies{policy: &TenantPolicy{TenantID: "acme", RejectThreshold: &rejectThreshold}})
+	if _, _, metadata, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err != nil || metadata.Verdict != VerdictRejected {
+		t.Fatalf("expected the tenant reject threshold to apply, got %+v, %v", metadata, err)
+	}
+}
+
+func TestVerifyImagePrechecksDuplicates(t *testing.T) {
+	opts := DefaultOptions()
+	opts.DuplicatePrecheck = true
+	prior := &repository.VerificationLog{ID: 7, RequestID: "req-prior", UserID: "user-1", Score: 0.8, Success: true, Verdict: VerdictVerified, Status: repository.StatusCompleted}
+	repo := &stubRepository{duplicates: []*repository.VerificationLog{prior}, findLog: prior}
+	uc := NewVerificationUseCaseWithOptions(repo, &stubCache{}, &stubProcessor{err: errors.New("processor called")}, zap.NewNop(), opts)
+
+	requestID, result, metadata, err := uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+	if err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if requestID != "req-prior" || metadata.DuplicateOf != "req-prior" || result.Score != 0.8 || metadata.Verdict != VerdictVerified || len(repo.savedLogs) != 0 {
+		t.Fatalf("expected the earlier result, got %s %+v %+v", requestID, result, metadata)
+	}
+
+	// Queued verifications have no result yet, so the image is processed again.
+	prior.Status = repository.StatusQueued
+	uc = NewVerificationUseCaseWithOptions(repo, &stubCache{}, &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.6}}, zap.NewNop(), opts)
+	_, result, metadata, err = uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+	if err != nil || metadata.DuplicateOf != "" || result.Score != 0.6 {
+		t.Fatalf("expected the image to be processed, got %+v, %+v, %v", result, metadata, err)
+	}
+	digest := sha1.Sum([]byte("image"))
+	if len(repo.savedLogs) != 1 || repo.savedLogs[0].SHA1Hash != hex.EncodeToString(digest[:]) {
+		t.Fatalf("unexpected saved logs %+v", repo.savedLogs)
+	}
+}
+
+func TestVerifyImageRejectsInfectedImages(t *testing.T) {
+	repo := &stubRepository{}
+	images := &stubImageStore{}
+	uc := NewVerificationUseCase(repo, &stubCache{}, &stubProcessor{err: errors.New("processor called")}, zap.NewNop())
+	uc.SetImageStore(images)
+	uc.SetMalwareScanner(stubScanner{})
+
+	_, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("EICAR image"))
+	var malwareErr *MalwareError
+	if !errors.As(err, &malwareErr) || malwareErr.Signature != "Eicar-Test-Signature" || malwareErr.RequestID == "" {
+		t.Fatalf("expected a MalwareError, got %v", err)
+	}
+	if len(repo.savedLogs) != 1 || len(images.objects) != 0 {
+		t.Fatalf("expected the rejection to be recorded and the image not stored, got %+v", repo.savedLogs)
+	}
+	if log := repo.savedLogs[0]; log.RequestID != malwareErr.RequestID || log.Status != repository.StatusFailed || log.MalwareScan != repository.MalwareScanInfected || log.MalwareSignature != "Eicar-Test-Signature" {
+		t.Fatalf("unexpected rejection log %+v", log)
+	}
+
+	// An image rejected before is rejected again without a request to point at.
+	repo.saveErr = repository.ErrDuplicateImage
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("EICAR image")); !errors.As(err, &malwareErr) || malwareErr.RequestID != "" {
+		t.Fatalf("expected a MalwareError without request ID, got %v", err)
+	}
+
+	repo.savedLogs, repo.saveErr = nil, nil
+	uc = NewVerificationUseCase(repo, &stubCache{}, &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.9}}, zap.NewNop())
+	uc.SetMalwareScanner(stubScanner{})
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("clean image")); err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if len(repo.savedLogs) != 1 || repo.savedLogs[0].MalwareScan != repository.MalwareScanClean || repo.savedLogs[0].Status == repository.StatusFailed {
+		t.Fatalf("expected a clean verification, got %+v", repo.savedLogs)
+	}
+
+	uc.SetMalwareScanner(stubScanner{err: errors.New("clamd down")})
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("clean image")); err == nil || errors.As(err, &malwareErr) {
+		t.Fatalf("expected a scanner failure to fail the verification, got %v", err)
+	}
+}
+
+// stubScanner finds images containing "EICAR" infected.
+type stubScanner struct {
+	err error
+}
+
+func (s stubScanner) Scan(_ context.Context, image io.Reader) (malwarescan.Verdict, error) {
+	if s.err != nil {
+		return malwarescan.Verdict{}, s.err
+	}
+	data, err := io.ReadAll(image)
+	if err != nil {
+		return malwarescan.Verdict{}, err
+	}
+	if bytes.Contains(data, []byte("EICAR")) {
+		return malwarescan.Verdict{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return malwarescan.Verdict{}, nil
+}
+
+// recordingProcessor records the image it was sent.
+type recordingProcessor struct {
+	image []byte
+}
+
+func (r *recordingProcessor) Process(ctx context.Context, userID string, imageBytes []byte) (*imageprocessor.Result, error) {
+	r.image = imageBytes
+	return &imageprocessor.Result{Success: true, Score: 0.9}, nil
+}
+
+// stubPreprocessor upper-cases images, or fails with err.
+type stubPreprocessor struct {
+	err error
+}
+
+func (s stubPreprocessor) Apply(image []byte) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return bytes.ToUpper(image), nil
+}
+
+func TestVerifyImageSendsPreprocessedImages(t *testing.T) {
+	repo := &stubRepository{}
+	processor := &recordingProcessor{}
+	uc := NewVerificationUseCase(repo, &stubCache{}, processor, zap.NewNop())
+	uc.SetPreprocessor(stubPreprocessor{})
+
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if string(processor.image) != "IMAGE" {
+		t.Fatalf("expected the processor to be sent the prepared image, got %q", processor.image)
+	}
+	digest := sha256.Sum256([]byte("image"))
+	if len(repo.savedLogs) != 1 || repo.savedLogs[0].SHA256Hash != hex.EncodeToString(digest[:]) {
+		t.Fatalf("expected the hashes of the uploaded image, got %+v", repo.savedLogs)
+	}
+
+	// An image that cannot be prepared is sent as uploaded.
+	uc.SetPreprocessor(stubPreprocessor{err: errors.New("corrupt image")})
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("other image")); err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if string(processor.image) != "other image" {
+		t.Fatalf("expected the uploaded image to be sent, got %q", processor.image)
+	}
+}
+
+func TestListVerificationsPagesWithCursors(t *testing.T) {
+	repo := &stubRepository{}
+	for id := uint(5); id > 0; id-- {
+		repo.listed = append(repo.listed, &repository.VerificationLog{ID: id})
+	}
+	uc := NewVerificationUseCase(repo, &stubCache{}, nil, zap.NewNop())
+	ctx := context.Background()
+
+	page, err := uc.ListVerifications(ctx, "user", "", 2)
+	if err != nil {
+		t.Fatalf("ListVerifications returned error: %v", err)
+	}
+	if len(page.Logs) != 2 || page.Logs[1].ID != 4 || page.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	page, err = uc.ListVerifications(ctx, "user", page.NextCursor, 3)
+	if err != nil {
+		t.Fatalf("ListVerifications returned error: %v", err)
+	}
+	if len(page.Logs) != 3 || page.Logs[0].ID != 3 || page.NextCursor != "" {
+		t.Fatalf("unexpected last page %+v", page)
+	}
+	// Each call asks for one more log than the page holds, before the cursor's ID.
+	if got := fmt.Sprint(repo.listArgs); got != "[0 3 4 4]" {
+		t.Fatalf("unexpected repository calls %s", got)
+	}
+
+	if _, err := uc.ListVerifications(ctx, "user", "not a cursor", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := uc.ListVerifications(ctx, "user", "", 1000); err != nil || repo.listArgs[len(repo.listArgs)-1] != MaxPageSize+1 {
+		t.Fatalf("expected the limit to be capped, got %v %v", repo.listArgs, err)
+	}
+}
+
+func TestGetDuplicateReportPagesWithCursors(t *testing.T) {
+	repo := &stubRepository{findLog: &repository.VerificationLog{ID: 9, RequestID: "req", SHA1Hash: "sha1"}}
+	for id := uint(5); id > 0; id-- {
+		repo.duplicates = append(repo.duplicates, &repository.VerificationLog{ID: id})
+	}
+	opts := DefaultOptions()
+	opts.MaxDuplicates = 3
+	uc := NewVerificationUseCaseWithOptions(repo, &stubCache{}, nil, zap.NewNop(), opts)
+	ctx := context.Background()
+
+	report, err := uc.GetDuplicateReport(ctx, "user", "req", "", 0)
+	if err != nil {
+		t.Fatalf("GetDuplicateReport returned error: %v", err)
+	}
+	if len(report.Duplicates) != 3 || report.Duplicates[2].ID != 3 || report.NextCursor == "" || report.Total != 5 {
+		t.Fatalf("expected the first page to be capped at MaxDuplicates, got %+v", report)
+	}
+	report, err = uc.GetDuplicateReport(ctx, "user", "req", report.NextCursor, 10)
+	if err != nil {
+		t.Fatalf("GetDuplicateReport returned error: %v", err)
+	}
+	if len(report.Duplicates) != 2 || report.Duplicates[0].ID != 2 || report.NextCursor != "" || report.Total != 5 {
+		t.Fatalf("unexpected last page %+v", report)
+	}
+
+	if _, err := uc.GetDuplicateReport(ctx, "user", "req", "not a cursor", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestRegionTagsCacheKeysLogsAndMetrics(t *testing.T) {
+	cache := &stubCache{getErrs: []error{redis.Nil}}
+	repo := &stubRepository{findLog: &repository.VerificationLog{RequestID: "req-1", UserID: "user-1"}}
+	client := &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.9}}
+	uc := NewVerificationUseCase(repo, cache, client, zap.NewNop())
+	uc.SetRegion("eu-west-1")
+
+	requestID, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image"))
+	if err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if want := "eu-west-1:verification:" + requestID; cache.setKeys[0] != want {
+		t.Fatalf("expected cache key %q, got %q", want, cache.setKeys[0])
+	}
+	if repo.savedLogs[0].Region != "eu-west-1" {
+		t.Fatalf("expected the log to record the region, got %q", repo.savedLogs[0].Region)
+	}
+
+	if _, err := uc.GetResult(context.Background(), "user-1", "req-1"); err != nil {
+		t.Fatalf("GetResult returned error: %v", err)
+	}
+	if cache.getKeys[0] != "eu-west-1:verification:req-1" {
+		t.Fatalf("unexpected cache key %q", cache.getKeys[0])
+	}
+
+	summary, err := uc.GetMetricsSummary(context.Background())
+	if err != nil || summary.Region != "eu-west-1" {
+		t.Fatalf("GetMetricsSummary returned %+v, %v", summary, err)
+	}
+}
+
+func TestTenantScopesCacheKeysAndLogs(t *testing.T) {
+	cache := &stubCache{
+		getErrs:   []error{nil, nil},
+		getValues: []string{`{"request_id":"req-1","user_id":"user-2","score":0.1}`, `{"request_id":"req-1","user_id":"user-1","score":0.9}`},
+	}
+	repo := &stubRepository{}
+	client := &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.9}}
+	uc := NewVerificationUseCase(repo, cache, client, zap.NewNop())
+	ctx := auth.WithTenantID(context.Background(), "acme")
+
+	requestID, _, _, err := uc.VerifyImage(ctx, "user-1", []byte("image"))
+	if err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if want := "tenant:acme:verification:" + requestID; cache.setKeys[0] != want {
+		t.Fatalf("expected cache key %q, got %q", want, cache.setKeys[0])
+	}
+	if repo.savedLogs[0].TenantID != "acme" {
+		t.Fatalf("expected the log to record the tenant, got %q", repo.savedLogs[0].TenantID)
+	}
+
+	// A result cached for another user is not served.
+	if _, err := uc.GetResult(ctx, "user-1", "req-1"); err == nil || repo.findCalls != 1 {
+		t.Fatalf("expected another user's cached result to be ignored, got %v", err)
+	}
+	if log, err := uc.GetResult(ctx, "user-1", "req-1"); err != nil || log.Score != 0.9 {
+		t.Fatalf("expected the user's cached result, got %+v (%v)", log, err)
+	}
+	if cache.getKeys[0] != "tenant:acme:verification:req-1" {
+		t.Fatalf("unexpected cache key %q", cache.getKeys[0])
+	}
+}
+
+type stubAssigner struct {
+	processor imageprocessor.Client
+}
+
+func (s stubAssigner) Assign(userID string) (string, imageprocessor.Client) {
+	return "candidate", s.processor
+}
+
+func TestVerifyImageUsesTheAssignedVariant(t *testing.T) {
+	repo := &stubRepository{variants: []*repository.VariantAggregation{{Variant: "candidate", MetricsAggregation: repository.MetricsAggregation{TotalCount: 4, SuccessCount: 1}}}}
+	uc := NewVerificationUseCase(repo, &stubCache{}, &stubProcessor{err: errors.New("default processor used")}, zap.NewNop())
+	uc.SetExperiment(stubAssigner{processor: &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.7}}})
+
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", []byte("image")); err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if repo.savedLogs[0].Variant != "candidate" || repo.savedLogs[0].Score != 0.7 {
+		t.Fatalf("unexpected log %+v", repo.savedLogs[0])
+	}
+
+	metrics, err := uc.GetVariantMetrics(context.Background())
+	if err != nil || len(metrics) != 1 || metrics[0].Variant != "candidate" || metrics[0].SuccessRate != 0.25 {
+		t.Fatalf("GetVariantMetrics returned %+v, %v", metrics, err)
+	}
+}
+
+func gradientPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8((x*x + y*3) % 256)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode returned error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerifyImageRecordsImageMetadata(t *testing.T) {
+	// A JPEG whose APP1 segment holds a big-endian TIFF with one Model field.
+	tiff := []byte("MM\x00*\x00\x00\x00\x08" +
+		"\x00\x01" + "\x01\x10\x00\x02\x00\x00\x00\x08\x00\x00\x00\x1a" + "\x00\x00\x00\x00" +
+		"Pixel 9\x00")
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	upload := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, byte(len(segment) + 2)}, segment...)
+	upload = append(upload, 0xFF, 0xD9)
+
+	repo := &stubRepository{}
+	uc := NewVerificationUseCase(repo, &stubCache{}, &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.9}}, zap.NewNop())
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", upload); err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if metadata := repo.savedLogs[0].ImageMetadata; metadata == nil || metadata.CameraModel != "Pixel 9" {
+		t.Fatalf("expected the camera model to be recorded, got %+v", metadata)
+	}
+
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", gradientPNG(t)); err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	if metadata := repo.savedLogs[1].ImageMetadata; metadata != nil {
+		t.Fatalf("expected no metadata for an image without EXIF, got %+v", metadata)
+	}
+}
+
+func TestFindSimilarRanksVerificationsByHashDistance(t *testing.T) {
+	upload := gradientPNG(t)
+	repo := &stubRepository{}
+	uc := NewVerificationUseCase(repo, &stubCache{}, &stubProcessor{result: &imageprocessor.Result{Success: true, Score: 0.9}}, zap.NewNop())
+	if _, _, _, err := uc.VerifyImage(context.Background(), "user-1", upload); err != nil {
+		t.Fatalf("VerifyImage returned error: %v", err)
+	}
+	hash, err := phash.Parse(repo.savedLogs[0].PerceptualHash)
+	if err != nil {
+		t.Fatalf("expected the verification to record a perceptual hash, got %q", repo.savedLogs[0].PerceptualHash)
+	}
+
+	repo.listed = []*repository.VerificationLog{
+		{ID: 1, RequestID: "older-exact", PerceptualHash: phash.Format(hash)},
+		{ID: 2, RequestID: "close", PerceptualHash: phash.Format(hash ^ 0b111)},
+		{ID: 3, RequestID: "far", PerceptualHash: phash.Format(^hash)},
+		{ID: 4, RequestID: "newer-exact", PerceptualHash: phash.Format(hash)},
+		{ID: 5, RequestID: "unhashed"},
+	}
+	matches, err := uc.FindSimilar(context.Background(), "user-1", upload, DefaultSimilarDistance, 10)
+	if err != nil {
+		t.Fatalf("FindSimilar returned error: %v", err)
+	}
+	var got []string
+	for _, match := range matches {
+		got = append(got, fmt.Sprintf("%s:%d", match.Log.RequestID, match.Distance))
+	}
+	if fmt.Sprint(got) != "[newer-exact:0 older-exact:0 close:3]" {
+		t.Fatalf("unexpected matches %v", got)
+	}
+	if matches, _ := uc.FindSimilar(context.Background(), "user-1", upload, 0, 1); len(matches) != 1 || matches[0].Log.RequestID != "newer-exact" {
+		t.Fatalf("expected the limit to keep the closest match, got %+v", matches)
+	}
+	if _, err := uc.FindSimilar(context.Background(), "user-1", []byte("not an image"), DefaultSimilarDistance, 10); !errors.Is(err, ErrUnsupportedImage) {
+		t.Fatalf("expected ErrUnsupportedImage, got %v", err)
+	}
+}
+
+func TestEraseUserDataDropsTheCachedResults(t *testing.T) {
+	repo := &stubRepository{}
+	for i := 0; i < eraseBatchSize+1; i++ {
+		repo.savedLogs = append(repo.savedLogs, &repository.VerificationLog{RequestID: fmt.Sprintf("req-%d", i), UserID: "user-1"})
+	}
+	repo.savedLogs = append(repo.savedLogs, &repository.VerificationLog{RequestID: "other", UserID: "user-2"})
+	cache := &stubCache{}
+	uc := NewVerificationUseCase(repo, cache, &stubProcessor{}, zap.NewNop())
+	uc.SetRegion("eu")
+
+	erased, err := uc.EraseUserData(context.Background(), "user-1")
+	if err != nil || erased != eraseBatchSize+1 {
+		t.Fatalf("EraseUserData returned %d, %v", erased, err)
+	}
+	if len(cache.deleteKeys) != eraseBatchSize+1 || cache.deleteKeys[0] != "eu:verification:req-0" {
+		t.Fatalf("expected the cache key of every erased result to be deleted, got %d keys starting %v", len(cache.deleteKeys), cache.deleteKeys[:1])
+	}
+	if len(repo.savedLogs) != 1 || repo.savedLogs[0].UserID != "user-2" {
+		t.Fatalf("expected the logs of other users to be kept, got %d", len(repo.savedLogs))
+	}
+}
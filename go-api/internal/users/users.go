@@ -0,0 +1,280 @@
+// Package users lets operators list the users of the service, suspend them and
+// assign tiers and quotas. Users are not registered anywhere: a user exists once a
+// verification was made under its JWT subject or an operator saved a profile for it.
+package users
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/repository"
+)
+
+var (
+	// ErrSuspended is returned by CheckActive for suspended users.
+	ErrSuspended = errors.New("account suspended")
+	// ErrInvalidTier is returned for tiers that are not short lowercase identifiers.
+	ErrInvalidTier = errors.New("tier must be 1-32 lowercase letters, digits, '-' or '_'")
+	// ErrInvalidQuota is returned for negative quotas.
+	ErrInvalidQuota = errors.New("monthly_quota must not be negative")
+	// ErrQuotaExceeded is returned by CheckQuota once the user used up their monthly
+	// quota.
+	ErrQuotaExceeded = errors.New("monthly verification quota exceeded")
+)
+
+var tierPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// User is a user's profile together with its verification activity.
+type User struct {
+	ID              string     `json:"id"`
+	Tier            string     `json:"tier"`
+	MonthlyQuota    int64      `json:"monthly_quota"`
+	Suspended       bool       `json:"suspended"`
+	SuspendedReason string     `json:"suspended_reason,omitempty"`
+	SuspendedAt     *time.Time `json:"suspended_at,omitempty"`
+	Verifications   int64      `json:"verifications"`
+	Failures        int64      `json:"failures"`
+	LastSeenAt      *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// Page is one page of users ordered by ID. NextCursor is empty on the last page.
+type Page struct {
+	Users      []*User `json:"users"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// Quota is a user's monthly verification quota and how much of it the current
+// calendar month (UTC) used.
+type Quota struct {
+	// Limit is 0 for unlimited users, whose Remaining is nil.
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining *int64    `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// Options sets the quotas of users whose profile sets none.
+type Options struct {
+	// TierQuotas are the monthly quotas of users of a tier; 0 is unlimited.
+	TierQuotas map[string]int64
+	// DefaultMonthlyQuota is the monthly quota of users whose tier has none; 0 is
+	// unlimited.
+	DefaultMonthlyQuota int64
+}
+
+// Service manages user profiles.
+type Service struct {
+	repo   *repository.UserRepository
+	opts   Options
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+// NewService returns a user management service. Users without a quota of their own
+// are unlimited.
+func NewService(repo *repository.UserRepository, logger *zap.Logger) *Service {
+	return NewServiceWithOptions(repo, logger, Options{})
+}
+
+// NewServiceWithOptions returns a user management service with quotas for users
+// whose profile sets none.
+func NewServiceWithOptions(repo *repository.UserRepository, logger *zap.Logger, opts Options) *Service {
+	return &Service{repo: repo, opts: opts, logger: logger.Named("users"), now: time.Now}
+}
+
+// List returns up to limit users with IDs after cursor, optionally only those whose
+// ID starts with prefix.
+func (s *Service) List(ctx context.Context, cursor, prefix string, limit int) (*Page, error) {
+	// One extra ID tells whether another page follows.
+	ids, err := s.repo.ListUserIDs(ctx, cursor, prefix, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page{}
+	if len(ids) > limit {
+		ids = ids[:limit]
+		page.NextCursor = ids[limit-1]
+	}
+	page.Users, err = s.load(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Get returns one user. Unknown users are returned with default settings.
+func (s *Service) Get(ctx context.Context, userID string) (*User, error) {
+	users, err := s.load(ctx, []string{userID})
+	if err != nil {
+		return nil, err
+	}
+	return users[0], nil
+}
+
+func (s *Service) load(ctx context.Context, ids []string) ([]*User, error) {
+	profiles, err := s.repo.FindProfiles(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	activity, err := s.repo.Activity(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*User, 0, len(ids))
+	for _, id := range ids {
+		user := &User{ID: id}
+		if profile := profiles[id]; profile != nil {
+			user.Tier = profile.Tier
+			user.MonthlyQuota = profile.MonthlyQuota
+			user.Suspended = profile.Suspended
+			user.SuspendedReason = profile.SuspendedReason
+			user.SuspendedAt = profile.SuspendedAt
+		}
+		if stats := activity[id]; stats != nil {
+			user.Verifications = stats.Verifications
+			user.Failures = stats.Failures
+			user.LastSeenAt = stats.LastSeenAt
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// Suspend blocks the user from the API until Unsuspend is called.
+func (s *Service) Suspend(ctx context.Context, userID, reason string) (*User, error) {
+	return s.update(ctx, userID, func(profile *repository.UserProfile) error {
+		if !profile.Suspended {
+			now := time.Now().UTC()
+			profile.SuspendedAt = &now
+		}
+		profile.Suspended = true
+		profile.SuspendedReason = reason
+		return nil
+	})
+}
+
+// Unsuspend lifts a suspension.
+func (s *Service) Unsuspend(ctx context.Context, userID string) (*User, error) {
+	return s.update(ctx, userID, func(profile *repository.UserProfile) error {
+		profile.Suspended = false
+		profile.SuspendedReason = ""
+		profile.SuspendedAt = nil
+		return nil
+	})
+}
+
+// SetPlan assigns the user's tier and monthly quota; a quota of 0 leaves the user the
+// quota of their tier, or the default one.
+func (s *Service) SetPlan(ctx context.Context, userID, tier string, monthlyQuota int64) (*User, error) {
+	if !tierPattern.MatchString(tier) {
+		return nil, ErrInvalidTier
+	}
+	if monthlyQuota < 0 {
+		return nil, ErrInvalidQuota
+	}
+	return s.update(ctx, userID, func(profile *repository.UserProfile) error {
+		profile.Tier = tier
+		profile.MonthlyQuota = monthlyQuota
+		return nil
+	})
+}
+
+func (s *Service) update(ctx context.Context, userID string, change func(*repository.UserProfile) error) (*User, error) {
+	profile, err := s.profile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(profile); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveProfile(ctx, profile); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID)
+}
+
+// profile returns the saved profile of userID or a new default one.
+func (s *Service) profile(ctx context.Context, userID string) (*repository.UserProfile, error) {
+	profile, err := s.repo.FindProfile(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &repository.UserProfile{UserID: userID}, nil
+	}
+	return profile, err
+}
+
+// RecentFailures returns up to limit of the user's unverified logs, newest first.
+func (s *Service) RecentFailures(ctx context.Context, userID string, limit int) ([]*repository.VerificationLog, error) {
+	return s.repo.RecentFailures(ctx, userID, limit)
+}
+
+// Quota returns the user's monthly quota and their verifications this month.
+func (s *Service) Quota(ctx context.Context, userID string) (*Quota, error) {
+	profile, err := s.profile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	monthStart := s.monthStart()
+	quota := &Quota{Limit: s.monthlyQuota(profile), ResetsAt: monthStart.AddDate(0, 1, 0)}
+	if quota.Used, err = s.repo.CountVerificationsSince(ctx, userID, monthStart); err != nil {
+		return nil, err
+	}
+	if quota.Limit > 0 {
+		remaining := max(quota.Limit-quota.Used, 0)
+		quota.Remaining = &remaining
+	}
+	return quota, nil
+}
+
+// CheckQuota returns ErrQuotaExceeded once the user made as many verifications this
+// month as their quota allows.
+func (s *Service) CheckQuota(ctx context.Context, userID string) error {
+	profile, err := s.profile(ctx, userID)
+	if err != nil {
+		return err
+	}
+	limit := s.monthlyQuota(profile)
+	if limit == 0 {
+		return nil
+	}
+	used, err := s.repo.CountVerificationsSince(ctx, userID, s.monthStart())
+	if err != nil {
+		return err
+	}
+	if used >= limit {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// monthlyQuota is the quota of the profile, else of its tier, else the default.
+func (s *Service) monthlyQuota(profile *repository.UserProfile) int64 {
+	if profile.MonthlyQuota > 0 {
+		return profile.MonthlyQuota
+	}
+	if quota, ok := s.opts.TierQuotas[profile.Tier]; ok && profile.Tier != "" {
+		return quota
+	}
+	return s.opts.DefaultMonthlyQuota
+}
+
+func (s *Service) monthStart() time.Time {
+	now := s.now().UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// CheckActive returns ErrSuspended when the user is suspended.
+func (s *Service) CheckActive(ctx context.Context, userID string) error {
+	profile, err := s.profile(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if profile.Suspended {
+		return ErrSuspended
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/repository"
+)
+
+func newTestService(t *testing.T) (*Service, *repository.VerificationRepository) {
+	t.Helper()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	logs := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := logs.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	repo := repository.NewUserRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(context.Background()); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	return NewService(repo, zap.NewNop()), logs
+}
+
+func saveLog(t *testing.T, logs *repository.VerificationRepository, userID, hash string, success bool, createdAt time.Time) {
+	t.Helper()
+	err := logs.SaveLog(context.Background(), &repository.VerificationLog{
+		RequestID: userID + "-" + hash,
+		UserID:    userID,
+		SHA1Hash:  hash,
+		Success:   success,
+		CreatedAt: createdAt,
+	})
+	if err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+}
+
+func TestListCombinesLogsAndProfiles(t *testing.T) {
+	service, logs := newTestService(t)
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	saveLog(t, logs, "alice", "a1", true, start)
+	saveLog(t, logs, "alice", "a2", false, start.Add(time.Hour))
+	saveLog(t, logs, "bob", "b1", false, start)
+	// carol has a profile but no verifications yet.
+	if _, err := service.SetPlan(ctx, "carol", "pro", 1000); err != nil {
+		t.Fatalf("SetPlan returned error: %v", err)
+	}
+
+	page, err := service.List(ctx, "", "", 2)
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(page.Users) != 2 || page.NextCursor != "bob" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	alice := page.Users[0]
+	if alice.ID != "alice" || alice.Verifications != 2 || alice.Failures != 1 || alice.LastSeenAt == nil || !alice.LastSeenAt.Equal(start.Add(time.Hour)) {
+		t.Fatalf("unexpected activity %+v", alice)
+	}
+
+	page, _ = service.List(ctx, page.NextCursor, "", 2)
+	if len(page.Users) != 1 || page.NextCursor != "" {
+		t.Fatalf("unexpected last page %+v", page)
+	}
+	if carol := page.Users[0]; carol.ID != "carol" || carol.Tier != "pro" || carol.MonthlyQuota != 1000 || carol.Verifications != 0 {
+		t.Fatalf("unexpected profile %+v", carol)
+	}
+
+	page, _ = service.List(ctx, "", "b", 10)
+	if len(page.Users) != 1 || page.Users[0].ID != "bob" {
+		t.Fatalf("expected the prefix to select bob, got %+v", page.Users)
+	}
+}
+
+func TestSuspendBlocksUntilUnsuspended(t *testing.T) {
+	service, _ := newTestService(t)
+	ctx := context.Background()
+
+	if err := service.CheckActive(ctx, "alice"); err != nil {
+		t.Fatalf("expected unknown users to be active, got %v", err)
+	}
+	user, err := service.Suspend(ctx, "alice", "chargeback")
+	if err != nil {
+		t.Fatalf("Suspend returned error: %v", err)
+	}
+	if !user.Suspended || user.SuspendedReason != "chargeback" || user.SuspendedAt == nil {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if err := service.CheckActive(ctx, "alice"); !errors.Is(err, ErrSuspended) {
+		t.Fatalf("expected ErrSuspended, got %v", err)
+	}
+
+	// Changing the plan keeps the suspension.
+	if _, err := service.SetPlan(ctx, "alice", "free", 0); err != nil {
+		t.Fatalf("SetPlan returned error: %v", err)
+	}
+	if err := service.CheckActive(ctx, "alice"); !errors.Is(err, ErrSuspended) {
+		t.Fatalf("expected the suspension to survive a plan change, got %v", err)
+	}
+
+	user, err = service.Unsuspend(ctx, "alice")
+	if err != nil || user.Suspended || user.SuspendedAt != nil || user.Tier != "free" {
+		t.Fatalf("Unsuspend returned %+v, %v", user, err)
+	}
+	if err := service.CheckActive(ctx, "alice"); err != nil {
+		t.Fatalf("expected alice to be active again, got %v", err)
+	}
+}
+
+func TestSetPlanValidatesInput(t *testing.T) {
+	service, _ := newTestService(t)
+	ctx := context.Background()
+
+	for _, tier := range []string{"", "Pro", "enterprise plus"} {
+		if _, err := service.SetPlan(ctx, "alice", tier, 0); !errors.Is(err, ErrInvalidTier) {
+			t.Fatalf("%q: expected ErrInvalidTier, got %v", tier, err)
+		}
+	}
+	if _, err := service.SetPlan(ctx, "alice", "pro", -1); !errors.Is(err, ErrInvalidQuota) {
+		t.Fatalf("expected ErrInvalidQuota, got %v", err)
+	}
+}
+
+func TestQuotaFollowsProfileTierAndDefault(t *testing.T) {
+	service, logs := newTestService(t)
+	service.opts = Options{TierQuotas: map[string]int64{"free": 2, "enterprise": 0}, DefaultMonthlyQuota: 5}
+	service.now = func() time.Time { return time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+	saveLog(t, logs, "alice", "a1", true, time.Date(2024, 2, 28, 12, 0, 0, 0, time.UTC))
+	saveLog(t, logs, "alice", "a2", true, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	saveLog(t, logs, "alice", "a3", false, time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC))
+
+	quota, err := service.Quota(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Quota returned error: %v", err)
+	}
+	if quota.Limit != 5 || quota.Used != 2 || quota.Remaining == nil || *quota.Remaining != 3 || !quota.ResetsAt.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the default quota with last month left out, got %+v", quota)
+	}
+	if err := service.CheckQuota(ctx, "alice"); err != nil {
+		t.Fatalf("expected alice to be within the quota, got %v", err)
+	}
+
+	if _, err := service.SetPlan(ctx, "alice", "free", 0); err != nil {
+		t.Fatalf("SetPlan returned error: %v", err)
+	}
+	if err := service.CheckQuota(ctx, "alice"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected the tier quota to be used up, got %v", err)
+	}
+	if quota, err := service.Quota(ctx, "alice"); err != nil || quota.Limit != 2 || *quota.Remaining != 0 {
+		t.Fatalf("unexpected tier quota %+v, %v", quota, err)
+	}
+
+	// A quota of the user's own overrides the tier's.
+	if _, err := service.SetPlan(ctx, "alice", "free", 10); err != nil {
+		t.Fatalf("SetPlan returned error: %v", err)
+	}
+	if err := service.CheckQuota(ctx, "alice"); err != nil {
+		t.Fatalf("expected the user's quota to apply, got %v", err)
+	}
+
+	if _, err := service.SetPlan(ctx, "alice", "enterprise", 0); err != nil {
+		t.Fatalf("SetPlan returned error: %v", err)
+	}
+	if quota, err := service.Quota(ctx, "alice"); err != nil || quota.Limit != 0 || quota.Remaining != nil || quota.Used != 2 {
+		t.Fatalf("expected the enterprise tier to be unlimited, got %+v, %v", quota, err)
+	}
+}
@@ -0,0 +1,280 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// BigQueryEndpoint is the BigQuery REST API.
+const BigQueryEndpoint = "https://bigquery.googleapis.com"
+
+const (
+	bigQueryScope = "https://www.googleapis.com/auth/bigquery"
+	// bigQueryInsertRows is the largest batch BigQuery recommends per insertAll call.
+	bigQueryInsertRows = 500
+)
+
+// BigQueryOptions configures a BigQuery table.
+type BigQueryOptions struct {
+	Project string
+	Dataset string
+	Table   string
+	// Credentials is the JSON key of a service account allowed to create and write
+	// tables in the dataset.
+	Credentials []byte
+	// Endpoint overrides BigQueryEndpoint.
+	Endpoint string
+	Client   *http.Client
+	// Now is used to date token requests; defaults to time.Now.
+	Now func() time.Time
+}
+
+// BigQuery writes rows with streaming inserts, using request_id as the insert ID so
+// BigQuery drops rows sent twice within its deduplication window. The table is
+// partitioned by day of created_at.
+type BigQuery struct {
+	opts     BigQueryOptions
+	email    string
+	key      *rsa.PrivateKey
+	tokenURL string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewBigQuery returns a sink for opts.Table.
+func NewBigQuery(opts BigQueryOptions) (*BigQuery, error) {
+	if opts.Project == "" || opts.Dataset == "" || opts.Table == "" {
+		return nil, errors.New("project, dataset and table are required")
+	}
+	var account struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(opts.Credentials, &account); err != nil || account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, errors.New("credentials must be a service account JSON key")
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("parse service account key: %w", err)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = BigQueryEndpoint
+	}
+	opts.Endpoint = strings.TrimRight(opts.Endpoint, "/")
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &BigQuery{opts: opts, email: account.ClientEmail, key: key, tokenURL: account.TokenURI}, nil
+}
+
+var bigQueryTypes = map[Type]string{
+	TypeInt:       "INT64",
+	TypeFloat:     "FLOAT64",
+	TypeBool:      "BOOL",
+	TypeString:    "STRING",
+	TypeTimestamp: "TIMESTAMP",
+}
+
+func (b *BigQuery) tablesURL() string {
+	return fmt.Sprintf("%s/bigquery/v2/projects/%s/datasets/%s/tables",
+		b.opts.Endpoint, url.PathEscape(b.opts.Project), url.PathEscape(b.opts.Dataset))
+}
+
+// EnsureTable implements Sink.
+func (b *BigQuery) EnsureTable(ctx context.Context, columns []Column) error {
+	var table struct {
+		Schema struct {
+			Fields []map[string]interface{} `json:"fields"`
+		} `json:"schema"`
+	}
+	status, err := b.call(ctx, http.MethodGet, b.tablesURL()+"/"+url.PathEscape(b.opts.Table), nil, &table)
+	if status == http.StatusNotFound {
+		fields := make([]map[string]interface{}, 0, len(columns))
+		for _, column := range columns {
+			fields = append(fields, bigQueryField(column))
+		}
+		create := map[string]interface{}{
+			"tableReference": map[string]string{
+				"projectId": b.opts.Project,
+				"datasetId": b.opts.Dataset,
+				"tableId":   b.opts.Table,
+			},
+			"schema":           map[string]interface{}{"fields": fields},
+			"timePartitioning": map[string]string{"type": "DAY", "field": "created_at"},
+		}
+		_, err := b.call(ctx, http.MethodPost, b.tablesURL(), create, nil)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool, len(table.Schema.Fields))
+	for _, field := range table.Schema.Fields {
+		if name, ok := field["name"].(string); ok {
+			existing[name] = true
+		}
+	}
+	fields := table.Schema.Fields
+	for _, column := range columns {
+		if !existing[column.Name] {
+			fields = append(fields, bigQueryField(column))
+		}
+	}
+	if len(fields) == len(table.Schema.Fields) {
+		return nil
+	}
+	patch := map[string]interface{}{"schema": map[string]interface{}{"fields": fields}}
+	_, err = b.call(ctx, http.MethodPatch, b.tablesURL()+"/"+url.PathEscape(b.opts.Table), patch, nil)
+	return err
+}
+
+func bigQueryField(column Column) map[string]interface{} {
+	return map[string]interface{}{"name": column.Name, "type": bigQueryTypes[column.Type], "mode": "NULLABLE"}
+}
+
+// Insert implements Sink.
+func (b *BigQuery) Insert(ctx context.Context, rows []Row) error {
+	for start := 0; start < len(rows); start += bigQueryInsertRows {
+		end := start + bigQueryInsertRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+		type insertRow struct {
+			InsertID string `json:"insertId"`
+			JSON     Row    `json:"json"`
+		}
+		request := struct {
+			Rows []insertRow `json:"rows"`
+		}{}
+		for _, row := range rows[start:end] {
+			insertID, _ := row["request_id"].(string)
+			request.Rows = append(request.Rows, insertRow{InsertID: insertID, JSON: row})
+		}
+		var response struct {
+			InsertErrors []struct {
+				Index  int `json:"index"`
+				Errors []struct {
+					Reason  string `json:"reason"`
+					Message string `json:"message"`
+				} `json:"errors"`
+			} `json:"insertErrors"`
+		}
+		endpoint := b.tablesURL() + "/" + url.PathEscape(b.opts.Table) + "/insertAll"
+		if _, err := b.call(ctx, http.MethodPost, endpoint, request, &response); err != nil {
+			return err
+		}
+		if len(response.InsertErrors) > 0 {
+			first := response.InsertErrors[0]
+			detail := ""
+			if len(first.Errors) > 0 {
+				detail = first.Errors[0].Reason + ": " + first.Errors[0].Message
+			}
+			return fmt.Errorf("bigquery: %d rows rejected, first at index %d: %s", len(response.InsertErrors), start+first.Index, detail)
+		}
+	}
+	return nil
+}
+
+// call sends a JSON request and decodes the response into out, returning the status
+// code of the response.
+func (b *BigQuery) call(ctx context.Context, method, endpoint string, in, out interface{}) (int, error) {
+	token, err := b.accessToken(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return 0, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, &body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.opts.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("bigquery: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse("bigquery", resp); err != nil {
+		return resp.StatusCode, err
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("bigquery: decode response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// accessToken exchanges a signed assertion for an OAuth token, reusing it until
+// shortly before it expires.
+func (b *BigQuery) accessToken(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.opts.Now()
+	if b.token != "" && now.Before(b.expires) {
+		return b.token, nil
+	}
+
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   b.email,
+		"scope": bigQueryScope,
+		"aud":   b.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(b.key)
+	if err != nil {
+		return "", fmt.Errorf("sign token assertion: %w", err)
+	}
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := b.opts.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch google access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse("google oauth", resp); err != nil {
+		return "", err
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", errors.New("google oauth: response has no access token")
+	}
+	b.token = token.AccessToken
+	b.expires = now.Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return b.token, nil
+}
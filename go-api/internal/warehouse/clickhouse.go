@@ -0,0 +1,129 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ClickHouseOptions configures a ClickHouse table reached through the HTTP interface.
+type ClickHouseOptions struct {
+	// URL is the HTTP interface, e.g. https://clickhouse.internal:8443.
+	URL      string
+	Database string
+	Table    string
+	Username string
+	Password string
+	Client   *http.Client
+}
+
+// ClickHouse writes rows with INSERT ... FORMAT JSONEachRow. The table is a
+// ReplacingMergeTree ordered by id, so rows sent twice are merged away.
+type ClickHouse struct {
+	opts ClickHouseOptions
+}
+
+// NewClickHouse returns a sink for opts.Table.
+func NewClickHouse(opts ClickHouseOptions) (*ClickHouse, error) {
+	if opts.URL == "" || opts.Table == "" {
+		return nil, errors.New("url and table are required")
+	}
+	if opts.Database == "" {
+		opts.Database = "default"
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &ClickHouse{opts: opts}, nil
+}
+
+var clickHouseTypes = map[Type]string{
+	TypeInt:       "UInt64",
+	TypeFloat:     "Float64",
+	TypeBool:      "Bool",
+	TypeString:    "String",
+	TypeTimestamp: "DateTime64(6, 'UTC')",
+}
+
+func (c *ClickHouse) table() string {
+	return quoteClickHouse(c.opts.Database) + "." + quoteClickHouse(c.opts.Table)
+}
+
+func quoteClickHouse(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "\\`") + "`"
+}
+
+// EnsureTable implements Sink.
+func (c *ClickHouse) EnsureTable(ctx context.Context, columns []Column) error {
+	definitions := make([]string, 0, len(columns))
+	for _, column := range columns {
+		definitions = append(definitions, quoteClickHouse(column.Name)+" "+clickHouseTypes[column.Type])
+	}
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = ReplacingMergeTree ORDER BY id",
+		c.table(), strings.Join(definitions, ", "))
+	if err := c.exec(ctx, create, nil); err != nil {
+		return err
+	}
+	for _, definition := range definitions {
+		if err := c.exec(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s", c.table(), definition), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert implements Sink.
+func (c *ClickHouse) Insert(ctx context.Context, rows []Row) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+	}
+	return c.exec(ctx, fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", c.table()), &body)
+}
+
+func (c *ClickHouse) exec(ctx context.Context, query string, body io.Reader) error {
+	endpoint, err := url.Parse(c.opts.URL)
+	if err != nil {
+		return fmt.Errorf("invalid clickhouse url: %w", err)
+	}
+	params := endpoint.Query()
+	params.Set("query", query)
+	params.Set("date_time_input_format", "best_effort")
+	endpoint.RawQuery = params.Encode()
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), body)
+	if err != nil {
+		return err
+	}
+	if c.opts.Username != "" {
+		req.Header.Set("X-ClickHouse-User", c.opts.Username)
+		req.Header.Set("X-ClickHouse-Key", c.opts.Password)
+	}
+	resp, err := c.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("clickhouse: %w", err)
+	}
+	defer resp.Body.Close()
+	return checkResponse("clickhouse", resp)
+}
+
+// checkResponse turns a non-2xx response into an error carrying the start of its
+// body.
+func checkResponse(service string, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return fmt.Errorf("%s: status %d: %s", service, resp.StatusCode, bytes.TrimSpace(body))
+}
@@ -0,0 +1,214 @@
+// Package warehouse copies verification logs to an analytics warehouse in
+// incremental batches, so heavy analytical queries never touch the OLTP database.
+package warehouse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/worker"
+)
+
+// ExportJob is the worker job type that exports new verification logs.
+const ExportJob = "warehouse.export"
+
+// Type is the logical type of a column; every sink maps it to a native type.
+type Type string
+
+// Column types.
+const (
+	TypeInt       Type = "int"
+	TypeFloat     Type = "float"
+	TypeBool      Type = "bool"
+	TypeString    Type = "string"
+	TypeTimestamp Type = "timestamp"
+)
+
+// Column is a column of the exported table.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Columns is the schema of the exported table. Columns are only ever appended, so
+// sinks bring an existing table up to date by adding the columns it lacks.
+var Columns = []Column{
+	{"id", TypeInt},
+	{"request_id", TypeString},
+	{"user_id", TypeString},
+	{"sha1_hash", TypeString},
+	{"score", TypeFloat},
+	{"success", TypeBool},
+	{"details", TypeString},
+	{"processing_latency_ms", TypeFloat},
+	{"image_key", TypeString},
+	{"created_at", TypeTimestamp},
+	// categories is a JSON array of {"category", "score", "threshold", "flagged"}.
+	{"categories", TypeString},
+	{"exported_at", TypeTimestamp},
+	{"region", TypeString},
+	{"variant", TypeString},
+	{"sha256_hash", TypeString},
+	{"raw_score", TypeFloat},
+	{"model_version", TypeString},
+}
+
+// TimestampLayout formats timestamp values. It is accepted by every supported
+// warehouse.
+const TimestampLayout = "2006-01-02T15:04:05.000000Z"
+
+// Row is an exported verification log keyed by column name. Timestamps are strings
+// formatted with TimestampLayout.
+type Row map[string]interface{}
+
+// Sink writes rows to a warehouse table.
+type Sink interface {
+	// EnsureTable creates the table, or adds the columns an existing table lacks.
+	EnsureTable(ctx context.Context, columns []Column) error
+	// Insert appends rows. A batch may be sent again after a failure; sinks drop
+	// the duplicates by request_id where the warehouse supports it.
+	Insert(ctx context.Context, rows []Row) error
+}
+
+type categoryJSON struct {
+	Category  string  `json:"category"`
+	Score     float32 `json:"score"`
+	Threshold float32 `json:"threshold"`
+	Flagged   bool    `json:"flagged"`
+}
+
+// NewRow converts a verification log.
+func NewRow(log *repository.VerificationLog, exportedAt time.Time) Row {
+	categories := make([]categoryJSON, 0, len(log.Categories))
+	for _, category := range log.Categories {
+		categories = append(categories, categoryJSON{category.Category, category.Score, category.Threshold, category.Flagged})
+	}
+	encoded, _ := json.Marshal(categories)
+	return Row{
+		"id":                    uint64(log.ID),
+		"request_id":            log.RequestID,
+		"user_id":               log.UserID,
+		"sha1_hash":             log.SHA1Hash,
+		"sha256_hash":           log.SHA256Hash,
+		"score":                 float64(log.Score),
+		"success":               log.Success,
+		"details":               log.Details,
+		"processing_latency_ms": log.ProcessingLatencyMs,
+		"image_key":             log.ImageKey,
+		"created_at":            log.CreatedAt.UTC().Format(TimestampLayout),
+		"categories":            string(encoded),
+		"exported_at":           exportedAt.UTC().Format(TimestampLayout),
+		"region":                log.Region,
+		"variant":               log.Variant,
+		"raw_score":             float64(log.RawScore),
+		"model_version":         log.ModelVersion,
+	}
+}
+
+// Options tunes the exporter.
+type Options struct {
+	// Name identifies the checkpoint of the destination. Changing it exports every
+	// log again.
+	Name string
+	// BatchSize is the number of logs sent per insert.
+	BatchSize int
+	// MaxBatches bounds the batches one export job sends; the rest waits for the
+	// next run.
+	MaxBatches int
+	// Lag leaves logs younger than it for a later run, so a log committed after one
+	// with a higher ID is not skipped.
+	Lag time.Duration
+}
+
+// DefaultOptions returns the tunables used by NewExporter.
+func DefaultOptions() Options {
+	return Options{Name: "warehouse", BatchSize: 5000, MaxBatches: 20, Lag: time.Minute}
+}
+
+// Exporter copies verification logs to a sink in ID order, remembering the last
+// exported ID in a checkpoint.
+type Exporter struct {
+	logs        *repository.VerificationRepository
+	checkpoints *repository.ExportRepository
+	sink        Sink
+	logger      *zap.Logger
+	opts        Options
+	now         func() time.Time
+
+	mu          sync.Mutex
+	tableExists bool
+}
+
+// NewExporter returns an exporter with DefaultOptions.
+func NewExporter(logs *repository.VerificationRepository, checkpoints *repository.ExportRepository, sink Sink, logger *zap.Logger) *Exporter {
+	return NewExporterWithOptions(logs, checkpoints, sink, logger, DefaultOptions())
+}
+
+// NewExporterWithOptions returns an exporter with explicit tunables.
+func NewExporterWithOptions(logs *repository.VerificationRepository, checkpoints *repository.ExportRepository, sink Sink, logger *zap.Logger, opts Options) *Exporter {
+	return &Exporter{
+		logs:        logs,
+		checkpoints: checkpoints,
+		sink:        sink,
+		logger:      logger.Named("warehouse"),
+		opts:        opts,
+		now:         time.Now,
+	}
+}
+
+// Export is the worker handler for ExportJob. The table schema is checked on the
+// first run of the process. A batch is recorded in the checkpoint only after the
+// sink accepted it, so a failure sends it again on the next attempt.
+func (e *Exporter) Export(ctx context.Context, _ *worker.Job) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.tableExists {
+		if err := e.sink.EnsureTable(ctx, Columns); err != nil {
+			return fmt.Errorf("ensure warehouse table: %w", err)
+		}
+		e.tableExists = true
+	}
+
+	checkpoint, err := e.checkpoints.Checkpoint(ctx, e.opts.Name)
+	if err != nil {
+		return err
+	}
+	now := e.now()
+	before := now.Add(-e.opts.Lag)
+	exported := 0
+	for batch := 0; batch < e.opts.MaxBatches; batch++ {
+		logs, err := e.logs.ListAfterID(ctx, checkpoint.LastID, before, e.opts.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			break
+		}
+		rows := make([]Row, 0, len(logs))
+		for _, log := range logs {
+			rows = append(rows, NewRow(log, now))
+		}
+		if err := e.sink.Insert(ctx, rows); err != nil {
+			return fmt.Errorf("insert %d rows after id %d: %w", len(rows), checkpoint.LastID, err)
+		}
+		checkpoint.LastID = logs[len(logs)-1].ID
+		checkpoint.Exported += int64(len(logs))
+		if err := e.checkpoints.SaveCheckpoint(ctx, checkpoint); err != nil {
+			return err
+		}
+		exported += len(logs)
+		if len(logs) < e.opts.BatchSize {
+			break
+		}
+	}
+	if exported > 0 {
+		e.logger.Info("exported verification logs", zap.String("destination", e.opts.Name), zap.Int("rows", exported), zap.Uint("last_id", checkpoint.LastID))
+	}
+	return nil
+}
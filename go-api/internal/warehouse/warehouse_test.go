@@ -0,0 +1,278 @@
+package warehouse
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/repository"
+)
+
+type recordingSink struct {
+	tables  int
+	batches [][]Row
+	fail    error
+}
+
+func (s *recordingSink) EnsureTable(ctx context.Context, columns []Column) error {
+	s.tables++
+	return nil
+}
+
+func (s *recordingSink) Insert(ctx context.Context, rows []Row) error {
+	if s.fail != nil {
+		return s.fail
+	}
+	s.batches = append(s.batches, rows)
+	return nil
+}
+
+func TestExportResumesFromCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	logs := repository.NewVerificationRepository(db, zap.NewNop())
+	checkpoints := repository.NewExportRepository(db, zap.NewNop())
+	if err := logs.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	if err := checkpoints.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	save := func(requestID string, createdAt time.Time) {
+		err := logs.SaveLog(ctx, &repository.VerificationLog{
+			RequestID:  requestID,
+			UserID:     "user-1",
+			SHA1Hash:   requestID,
+			CreatedAt:  createdAt,
+			Categories: []repository.VerificationCategory{{Category: "nsfw", Score: 0.9, Threshold: 0.5, Flagged: true}},
+		})
+		if err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		save(id, now.Add(-time.Hour))
+	}
+	// Too recent to export yet.
+	save("d", now.Add(-10*time.Second))
+
+	sink := &recordingSink{}
+	exporter := NewExporterWithOptions(logs, checkpoints, sink, zap.NewNop(), Options{Name: "test", BatchSize: 2, MaxBatches: 10, Lag: time.Minute})
+	exporter.now = func() time.Time { return now }
+	if err := exporter.Export(ctx, nil); err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+	if sink.tables != 1 || len(sink.batches) != 2 || len(sink.batches[0]) != 2 || len(sink.batches[1]) != 1 {
+		t.Fatalf("expected batches of 2 and 1 rows, got %d tables and %+v", sink.tables, sink.batches)
+	}
+	row := sink.batches[0][0]
+	if row["request_id"] != "a" || row["created_at"] != "2024-01-01T11:00:00.000000Z" || !strings.Contains(row["categories"].(string), `"flagged":true`) {
+		t.Fatalf("unexpected row %+v", row)
+	}
+
+	// A failed insert leaves the checkpoint in place, so the batch is sent again.
+	now = now.Add(time.Minute)
+	sink.fail = errors.New("warehouse unavailable")
+	if err := exporter.Export(ctx, nil); err == nil {
+		t.Fatal("expected the insert failure to fail the export")
+	}
+	sink.fail = nil
+	if err := exporter.Export(ctx, nil); err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+	if sink.tables != 1 || len(sink.batches) != 3 || sink.batches[2][0]["request_id"] != "d" {
+		t.Fatalf("expected only the remaining log to be exported, got %+v", sink.batches)
+	}
+	checkpoint, _ := checkpoints.Checkpoint(ctx, "test")
+	if checkpoint.Exported != 4 {
+		t.Fatalf("unexpected checkpoint %+v", checkpoint)
+	}
+}
+
+func TestClickHouseCreatesTableAndInsertsRows(t *testing.T) {
+	var queries []string
+	var inserted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-ClickHouse-User") != "exporter" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		queries = append(queries, r.URL.Query().Get("query"))
+		body, _ := io.ReadAll(r.Body)
+		inserted += string(body)
+	}))
+	defer server.Close()
+
+	sink, err := NewClickHouse(ClickHouseOptions{URL: server.URL, Database: "analytics", Table: "verifications", Username: "exporter", Password: "secret"})
+	if err != nil {
+		t.Fatalf("NewClickHouse returned error: %v", err)
+	}
+	ctx := context.Background()
+	if err := sink.EnsureTable(ctx, Columns[:2]); err != nil {
+		t.Fatalf("EnsureTable returned error: %v", err)
+	}
+	if err := sink.Insert(ctx, []Row{{"id": uint64(1), "request_id": "a"}, {"id": uint64(2), "request_id": "b"}}); err != nil {
+		t.Fatalf("Insert returned error: %v", err)
+	}
+	want := []string{
+		"CREATE TABLE IF NOT EXISTS `analytics`.`verifications` (`id` UInt64, `request_id` String) ENGINE = ReplacingMergeTree ORDER BY id",
+		"ALTER TABLE `analytics`.`verifications` ADD COLUMN IF NOT EXISTS `id` UInt64",
+		"ALTER TABLE `analytics`.`verifications` ADD COLUMN IF NOT EXISTS `request_id` String",
+		"INSERT INTO `analytics`.`verifications` FORMAT JSONEachRow",
+	}
+	if strings.Join(queries, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected queries:\n%s", strings.Join(queries, "\n"))
+	}
+	if inserted != "{\"id\":1,\"request_id\":\"a\"}\n{\"id\":2,\"request_id\":\"b\"}\n" {
+		t.Fatalf("unexpected insert body %q", inserted)
+	}
+}
+
+func testPrivateKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey returned error: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestBigQueryAddsMissingColumnsAndInserts(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	var patched, inserted map[string]interface{}
+	tokenRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/token" {
+			tokenRequests++
+			if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.FormValue("assertion") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"access_token":"token-1","expires_in":3600}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`{"schema":{"fields":[{"name":"id","type":"INT64","mode":"NULLABLE"}]}}`))
+		case http.MethodPatch:
+			json.NewDecoder(r.Body).Decode(&patched)
+			w.Write([]byte(`{}`))
+		case http.MethodPost:
+			json.NewDecoder(r.Body).Decode(&inserted)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	credentials, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "exporter@project.iam.gserviceaccount.com",
+		"private_key":  string(testPrivateKey(t)),
+		"token_uri":    server.URL + "/token",
+	})
+	sink, err := NewBigQuery(BigQueryOptions{Project: "project", Dataset: "analytics", Table: "verifications", Credentials: credentials, Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("NewBigQuery returned error: %v", err)
+	}
+	ctx := context.Background()
+	if err := sink.EnsureTable(ctx, Columns[:2]); err != nil {
+		t.Fatalf("EnsureTable returned error: %v", err)
+	}
+	if err := sink.Insert(ctx, []Row{{"id": uint64(1), "request_id": "a"}}); err != nil {
+		t.Fatalf("Insert returned error: %v", err)
+	}
+
+	table := "/bigquery/v2/projects/project/datasets/analytics/tables/verifications"
+	if want := []string{"GET " + table, "PATCH " + table, "POST " + table + "/insertAll"}; strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	if tokenRequests != 1 {
+		t.Fatalf("expected the access token to be reused, got %d token requests", tokenRequests)
+	}
+	fields := patched["schema"].(map[string]interface{})["fields"].([]interface{})
+	if len(fields) != 2 || fields[1].(map[string]interface{})["name"] != "request_id" {
+		t.Fatalf("expected request_id to be added, got %+v", fields)
+	}
+	row := inserted["rows"].([]interface{})[0].(map[string]interface{})
+	if row["insertId"] != "a" {
+		t.Fatalf("expected request_id as insert ID, got %+v", row)
+	}
+}
+
+func TestSnowflakeBindsColumnsAndWaitsForStatements(t *testing.T) {
+	var statements []map[string]interface{}
+	polled := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Snowflake-Authorization-Token-Type") != "KEYPAIR_JWT" || !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodGet {
+			polled = r.URL.Path == "/api/v2/statements/handle-1"
+			w.Write([]byte(`{}`))
+			return
+		}
+		var statement map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&statement)
+		statements = append(statements, statement)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"statementHandle":"handle-1"}`))
+	}))
+	defer server.Close()
+
+	sink, err := NewSnowflake(SnowflakeOptions{
+		Account: "myorg-analytics", User: "exporter", PrivateKey: testPrivateKey(t),
+		Database: "ANALYTICS", Schema: "PUBLIC", Table: "verifications", Endpoint: server.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewSnowflake returned error: %v", err)
+	}
+	if !strings.HasPrefix(sink.issuer, "MYORG-ANALYTICS.EXPORTER.SHA256:") {
+		t.Fatalf("unexpected issuer %q", sink.issuer)
+	}
+	rows := []Row{NewRow(&repository.VerificationLog{ID: 1, RequestID: "a"}, time.Now()), NewRow(&repository.VerificationLog{ID: 2, RequestID: "b"}, time.Now())}
+	if err := sink.Insert(context.Background(), rows); err != nil {
+		t.Fatalf("Insert returned error: %v", err)
+	}
+	if !polled || len(statements) != 1 {
+		t.Fatalf("expected the running statement to be polled, got %+v", statements)
+	}
+	bindings := statements[0]["bindings"].(map[string]interface{})
+	requestIDs := bindings["2"].(map[string]interface{})
+	if len(bindings) != len(Columns) || requestIDs["type"] != "TEXT" || len(requestIDs["value"].([]interface{})) != 2 {
+		t.Fatalf("unexpected bindings %+v", bindings)
+	}
+	if _, err := NewSnowflake(SnowflakeOptions{Account: "a", User: "u", PrivateKey: testPrivateKey(t), Database: "d", Schema: "s", Table: "logs; DROP TABLE x"}); err == nil {
+		t.Fatal("expected an unsafe table name to be rejected")
+	}
+}
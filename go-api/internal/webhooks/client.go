@@ -0,0 +1,29 @@
+package webhooks
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/example/ai-check/internal/safehttp"
+)
+
+// validateURL checks an endpoint URL when it is registered. Hostnames are checked
+// again at delivery time, after resolution.
+func validateURL(raw string, allowHTTP, allowPrivate bool) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil {
+		return nil, fmt.Errorf("%w: must be an absolute URL without credentials", ErrInvalidURL)
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && allowHTTP:
+	default:
+		return nil, fmt.Errorf("%w: scheme must be https", ErrInvalidURL)
+	}
+	if !allowPrivate {
+		if err := safehttp.CheckHost(u.Hostname()); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidURL, err)
+		}
+	}
+	return u, nil
+}
@@ -0,0 +1,414 @@
+// Package webhooks lets users register HTTP endpoints and delivers signed event
+// notifications to them through the background worker, retrying failures.
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/safehttp"
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/worker"
+)
+
+// DeliverJob is the worker job type that sends one delivery.
+const DeliverJob = "webhook.deliver"
+
+// Headers sent with every delivery besides SignatureHeader.
+const (
+	EventHeader = "X-AICheck-Event"
+	// DeliveryHeader is the same for every retry and replay of a delivery, so
+	// receivers can use it to ignore duplicates.
+	DeliveryHeader = "X-AICheck-Delivery"
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusRetrying  = "retrying"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Events lists the event types endpoints can subscribe to.
+var Events = []string{usecase.EventVerificationCompleted, usecase.EventVerificationNeedsReview}
+
+var (
+	// ErrNotFound is returned for endpoints and deliveries the user does not own.
+	ErrNotFound = errors.New("webhook not found")
+	// ErrInvalidURL is returned when registering an unacceptable endpoint URL.
+	ErrInvalidURL = errors.New("invalid webhook url")
+	// ErrUnknownEvent is returned when subscribing to an event type that does not exist.
+	ErrUnknownEvent = errors.New("unknown event type")
+	// ErrTooManyEndpoints is returned when the user reached Options.MaxEndpoints.
+	ErrTooManyEndpoints = errors.New("too many webhook endpoints")
+	// ErrDeliveryInProgress is returned when replaying a delivery that is still queued.
+	ErrDeliveryInProgress = errors.New("delivery is already queued")
+)
+
+// maxResponseBody bounds the part of a receiver's response kept in the delivery log.
+const maxResponseBody = 1 << 10
+
+// Options tunes endpoint registration and delivery.
+type Options struct {
+	// Timeout bounds each delivery attempt.
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is tried before it is marked failed.
+	MaxAttempts  int
+	MaxEndpoints int
+	// AllowHTTP accepts plain http:// endpoint URLs.
+	AllowHTTP bool
+	// AllowPrivateNetworks permits loopback, private and link-local destinations.
+	AllowPrivateNetworks bool
+}
+
+// DefaultOptions returns the tunables used by NewService.
+func DefaultOptions() Options {
+	return Options{
+		Timeout:      10 * time.Second,
+		MaxAttempts:  8,
+		MaxEndpoints: 10,
+	}
+}
+
+// Service manages endpoints and their deliveries. It implements
+// usecase.EventPublisher.
+type Service struct {
+	repo   *repository.WebhookRepository
+	queue  *worker.Queue
+	client *http.Client
+	logger *zap.Logger
+	opts   Options
+	now    func() time.Time
+}
+
+// NewService constructs a service with DefaultOptions.
+func NewService(repo *repository.WebhookRepository, queue *worker.Queue, logger *zap.Logger) *Service {
+	return NewServiceWithOptions(repo, queue, logger, DefaultOptions())
+}
+
+// NewServiceWithOptions constructs a service with explicit tunables.
+func NewServiceWithOptions(repo *repository.WebhookRepository, queue *worker.Queue, logger *zap.Logger, opts Options) *Service {
+	return &Service{
+		repo:   repo,
+		queue:  queue,
+		client: safehttp.NewClient(opts.Timeout, opts.AllowPrivateNetworks),
+		logger: logger.Named("webhooks"),
+		opts:   opts,
+		now:    time.Now,
+	}
+}
+
+// CreateEndpoint registers url for events, or for every event when events is empty.
+// The returned endpoint carries the signing secret, which is not shown again.
+func (s *Service) CreateEndpoint(ctx context.Context, userID, url string, events []string) (*repository.WebhookEndpoint, error) {
+	u, err := validateURL(url, s.opts.AllowHTTP, s.opts.AllowPrivateNetworks)
+	if err != nil {
+		return nil, err
+	}
+	subscribed, err := normalizeEvents(events)
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.repo.CountEndpoints(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= int64(s.opts.MaxEndpoints) {
+		return nil, fmt.Errorf("%w: the limit is %d", ErrTooManyEndpoints, s.opts.MaxEndpoints)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	endpoint := &repository.WebhookEndpoint{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		URL:       u.String(),
+		Secret:    "whsec_" + hex.EncodeToString(secret),
+		Events:    strings.Join(subscribed, ","),
+		CreatedAt: s.now().UTC(),
+	}
+	if err := s.repo.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func normalizeEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return Events, nil
+	}
+	var subscribed []string
+	for _, known := range Events {
+		for _, event := range events {
+			if event == known {
+				subscribed = append(subscribed, known)
+				break
+			}
+		}
+	}
+	for _, event := range events {
+		if !contains(subscribed, event) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownEvent, event)
+		}
+	}
+	return subscribed, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ListEndpoints returns the endpoints of userID.
+func (s *Service) ListEndpoints(ctx context.Context, userID string) ([]*repository.WebhookEndpoint, error) {
+	return s.repo.ListEndpoints(ctx, userID)
+}
+
+// DeleteEndpoint removes an endpoint and its delivery log. Queued deliveries to it
+// are dropped when the worker picks them up.
+func (s *Service) DeleteEndpoint(ctx context.Context, userID, id string) error {
+	deleted, err := s.repo.DeleteEndpoint(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Deliveries returns up to limit deliveries to an endpoint of userID, newest first.
+func (s *Service) Deliveries(ctx context.Context, userID, endpointID string, limit int) ([]*repository.WebhookDelivery, error) {
+	if _, err := s.endpoint(ctx, userID, endpointID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(ctx, endpointID, limit)
+}
+
+// Replay sends a delivery again with its original payload and ID. Failed deliveries
+// get a fresh set of attempts.
+func (s *Service) Replay(ctx context.Context, userID, endpointID, deliveryID string) (*repository.WebhookDelivery, error) {
+	if _, err := s.endpoint(ctx, userID, endpointID); err != nil {
+		return nil, err
+	}
+	delivery, err := s.repo.FindDelivery(ctx, deliveryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && delivery.EndpointID != endpointID) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A delivery that ran out of attempts is parked in the dead-letter set under the
+	// same job ID; anything else still queued must not be sent twice at once.
+	queued, err := s.queue.Redrive(ctx, jobID(delivery.ID))
+	if err != nil {
+		return nil, err
+	}
+	if !queued {
+		if queued, err = s.enqueue(ctx, delivery.ID); err != nil {
+			return nil, err
+		}
+		if !queued {
+			return nil, ErrDeliveryInProgress
+		}
+	}
+
+	delivery.Status = StatusPending
+	delivery.UpdatedAt = s.now().UTC()
+	if err := s.repo.SaveDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *Service) endpoint(ctx context.Context, userID, id string) (*repository.WebhookEndpoint, error) {
+	endpoint, err := s.repo.FindEndpoint(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && endpoint.UserID != userID) {
+		return nil, ErrNotFound
+	}
+	return endpoint, err
+}
+
+// TenantOwner is the owner under which the endpoints of a tenant are registered.
+// They receive the events of every user of the tenant.
+func TenantOwner(tenantID string) string {
+	return "tenant:" + tenantID
+}
+
+// Publish records a delivery of event for every subscribed endpoint of its user and
+// its tenant, and queues it. An event already delivered to an endpoint is not queued
+// again.
+func (s *Service) Publish(ctx context.Context, event usecase.Event) error {
+	endpoints, err := s.repo.ListEndpoints(ctx, event.UserID)
+	if err != nil {
+		return err
+	}
+	if event.TenantID != "" {
+		tenantEndpoints, err := s.repo.ListEndpoints(ctx, TenantOwner(event.TenantID))
+		if err != nil {
+			return err
+		}
+		endpoints = append(endpoints, tenantEndpoints...)
+	}
+	var payload []byte
+	var errs []error
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(envelope{ID: event.ID, Type: event.Type, CreatedAt: event.CreatedAt, Data: event.Data}); err != nil {
+				return fmt.Errorf("encode %s event: %w", event.Type, err)
+			}
+		}
+		now := s.now().UTC()
+		delivery := &repository.WebhookDelivery{
+			ID:         uuid.NewString(),
+			EndpointID: endpoint.ID,
+			EventID:    event.ID,
+			Event:      event.Type,
+			Payload:    string(payload),
+			Status:     StatusPending,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		created, err := s.repo.CreateDelivery(ctx, delivery)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !created {
+			continue
+		}
+		if _, err := s.enqueue(ctx, delivery.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// envelope is the JSON body of every delivery.
+type envelope struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type deliverPayload struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+func jobID(deliveryID string) string {
+	return DeliverJob + ":" + deliveryID
+}
+
+func (s *Service) enqueue(ctx context.Context, deliveryID string) (bool, error) {
+	job, err := worker.NewJob(DeliverJob, deliverPayload{DeliveryID: deliveryID})
+	if err != nil {
+		return false, err
+	}
+	job.ID = jobID(deliveryID)
+	job.MaxAttempts = s.opts.MaxAttempts
+	return s.queue.Enqueue(ctx, job)
+}
+
+// Deliver is the worker.Handler for DeliverJob. It sends the delivery once and
+// records the outcome; a returned error makes the worker retry with backoff.
+func (s *Service) Deliver(ctx context.Context, job *worker.Job) error {
+	var payload deliverPayload
+	if err := job.Decode(&payload); err != nil {
+		return worker.Permanent(fmt.Errorf("decode payload: %w", err))
+	}
+	delivery, err := s.repo.FindDelivery(ctx, payload.DeliveryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The endpoint was deleted together with its deliveries.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	endpoint, err := s.repo.FindEndpoint(ctx, delivery.EndpointID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	code, body, sendErr := s.send(ctx, endpoint, delivery)
+	now := s.now().UTC()
+	delivery.Attempts++
+	delivery.ResponseCode = code
+	delivery.ResponseBody = body
+	delivery.UpdatedAt = now
+	switch {
+	case sendErr == nil:
+		delivery.Status = StatusSucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case job.MaxAttempts > 0 && job.Attempts >= job.MaxAttempts:
+		delivery.Status = StatusFailed
+		delivery.LastError = sendErr.Error()
+	default:
+		delivery.Status = StatusRetrying
+		delivery.LastError = sendErr.Error()
+	}
+
+	logger := s.logger.With(zap.String("delivery_id", delivery.ID), zap.String("endpoint_id", endpoint.ID), zap.String("event", delivery.Event))
+	if err := s.repo.SaveDelivery(context.WithoutCancel(ctx), delivery); err != nil {
+		// Resending a delivered event only because its log row was not updated would
+		// be worse than a stale log.
+		logger.Error("failed to record webhook delivery", zap.Error(err))
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	logger.Debug("webhook delivered", zap.Int("status", code))
+	return nil
+}
+
+func (s *Service) send(ctx context.Context, endpoint *repository.WebhookEndpoint, delivery *repository.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ai-check-webhooks")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, s.now(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(excerpt), fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(excerpt), nil
+}
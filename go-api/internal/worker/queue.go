@@ -0,0 +1,255 @@
+// Package worker runs background jobs from a Redis-backed queue with retries,
+// visibility timeouts and a dead-letter set.
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// DefaultPrefix namespaces the queue keys. The hash tag keeps every key in one
+// cluster slot so the Lua scripts stay valid on Redis Cluster.
+const DefaultPrefix = "ai-check:{jobs}"
+
+// Job is a unit of background work.
+type Job struct {
+	// ID identifies the job. Enqueueing an ID that is still queued, running or
+	// dead-lettered is a no-op, which makes it usable as a deduplication key.
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// MaxAttempts overrides the runner's default when positive.
+	MaxAttempts int       `json:"max_attempts,omitempty"`
+	EnqueuedAt  time.Time `json:"enqueued_at"`
+	// RunAt delays the first attempt; the zero value runs as soon as possible.
+	RunAt time.Time `json:"-"`
+
+	// Attempts counts claims so far, including the current one.
+	Attempts  int    `json:"-"`
+	LastError string `json:"-"`
+}
+
+// NewJob builds a job with a random ID and a JSON-encoded payload.
+func NewJob(jobType string, payload interface{}) (Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, fmt.Errorf("encode %s payload: %w", jobType, err)
+	}
+	return Job{ID: uuid.NewString(), Type: jobType, Payload: data}, nil
+}
+
+// Decode unmarshals the job payload into v.
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Queue stores jobs in Redis. Ready jobs live in a sorted set scored by the time
+// they may run; claimed jobs move to an in-flight set scored by their visibility
+// deadline and return to the ready set if that deadline passes without an ack.
+type Queue struct {
+	client *redis.Client
+	prefix string
+	now    func() time.Time
+}
+
+// NewQueue returns a queue using DefaultPrefix.
+func NewQueue(client *redis.Client) *Queue {
+	return NewQueueWithPrefix(client, DefaultPrefix)
+}
+
+// NewQueueWithPrefix returns a queue whose keys start with prefix.
+func NewQueueWithPrefix(client *redis.Client, prefix string) *Queue {
+	return &Queue{client: client, prefix: prefix, now: time.Now}
+}
+
+func (q *Queue) key(name string) string { return q.prefix + ":" + name }
+
+func (q *Queue) jobKey(id string) string { return q.key("job:" + id) }
+
+var enqueueScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[2], 'data', ARGV[2], 'attempts', 0)
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+return 1
+`)
+
+// Enqueue adds job to the queue and reports whether it was added; false means a job
+// with the same ID already exists.
+func (q *Queue) Enqueue(ctx context.Context, job Job) (bool, error) {
+	if job.Type == "" {
+		return false, errors.New("job type is required")
+	}
+	if job.ID == "" {
+		job.ID = uuid.NewString()
+	}
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = q.now().UTC()
+	}
+	runAt := job.RunAt
+	if runAt.IsZero() {
+		runAt = q.now()
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return false, err
+	}
+	added, err := enqueueScript.Run(ctx, q.client,
+		[]string{q.key("ready"), q.jobKey(job.ID)},
+		job.ID, data, runAt.UnixMilli()).Int()
+	if err != nil {
+		return false, fmt.Errorf("enqueue %s job: %w", job.Type, err)
+	}
+	return added == 1, nil
+}
+
+// claimScript first returns in-flight jobs whose visibility deadline passed to the
+// ready set, then moves the oldest due job to the in-flight set.
+var claimScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, id in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], id)
+	redis.call('ZADD', KEYS[1], ARGV[1], id)
+end
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+	return false
+end
+local id = ids[1]
+local jobKey = ARGV[3] .. id
+redis.call('ZREM', KEYS[1], id)
+if redis.call('EXISTS', jobKey) == 0 then
+	return false
+end
+redis.call('ZADD', KEYS[2], ARGV[2], id)
+local attempts = redis.call('HINCRBY', jobKey, 'attempts', 1)
+local fields = redis.call('HMGET', jobKey, 'data', 'last_error')
+return {fields[1], attempts, fields[2] or ''}
+`)
+
+// Claim takes the next due job and hides it from other workers for visibility. It
+// returns nil when no job is due.
+func (q *Queue) Claim(ctx context.Context, visibility time.Duration) (*Job, error) {
+	now := q.now()
+	result, err := claimScript.Run(ctx, q.client,
+		[]string{q.key("ready"), q.key("inflight")},
+		now.UnixMilli(), now.Add(visibility).UnixMilli(), q.key("job:")).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claim job: %w", err)
+	}
+	if len(result) != 3 {
+		return nil, fmt.Errorf("claim job: unexpected reply %v", result)
+	}
+
+	var job Job
+	data, _ := result[0].(string)
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, fmt.Errorf("decode claimed job: %w", err)
+	}
+	attempts, _ := result[1].(int64)
+	job.Attempts = int(attempts)
+	job.LastError, _ = result[2].(string)
+	return &job, nil
+}
+
+// Extend pushes the visibility deadline of a claimed job out by visibility.
+func (q *Queue) Extend(ctx context.Context, job *Job, visibility time.Duration) error {
+	deadline := float64(q.now().Add(visibility).UnixMilli())
+	return q.client.ZAddXX(ctx, q.key("inflight"), &redis.Z{Score: deadline, Member: job.ID}).Err()
+}
+
+// Ack removes a finished job.
+func (q *Queue) Ack(ctx context.Context, job *Job) error {
+	pipe := q.client.TxPipeline()
+	pipe.ZRem(ctx, q.key("inflight"), job.ID)
+	pipe.Del(ctx, q.jobKey(job.ID))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Retry makes a failed job due again after delay.
+func (q *Queue) Retry(ctx context.Context, job *Job, delay time.Duration, cause error) error {
+	return q.move(ctx, job, "ready", q.now().Add(delay), cause)
+}
+
+// DeadLetter parks a job that will not be retried.
+func (q *Queue) DeadLetter(ctx context.Context, job *Job, cause error) error {
+	return q.move(ctx, job, "dead", q.now(), cause)
+}
+
+func (q *Queue) move(ctx context.Context, job *Job, set string, score time.Time, cause error) error {
+	pipe := q.client.TxPipeline()
+	pipe.ZRem(ctx, q.key("inflight"), job.ID)
+	if cause != nil {
+		pipe.HSet(ctx, q.jobKey(job.ID), "last_error", cause.Error())
+	}
+	pipe.ZAdd(ctx, q.key(set), &redis.Z{Score: float64(score.UnixMilli()), Member: job.ID})
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeadLetters returns up to limit dead-lettered jobs, oldest first.
+func (q *Queue) DeadLetters(ctx context.Context, limit int64) ([]Job, error) {
+	ids, err := q.client.ZRange(ctx, q.key("dead"), 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]Job, 0, len(ids))
+	for _, id := range ids {
+		fields, err := q.client.HGetAll(ctx, q.jobKey(id)).Result()
+		if err != nil {
+			return nil, err
+		}
+		var job Job
+		if err := json.Unmarshal([]byte(fields["data"]), &job); err != nil {
+			continue
+		}
+		job.Attempts, _ = strconv.Atoi(fields["attempts"])
+		job.LastError = fields["last_error"]
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Redrive moves a dead-lettered job back to the ready set with a fresh attempt count.
+func (q *Queue) Redrive(ctx context.Context, id string) (bool, error) {
+	removed, err := q.client.ZRem(ctx, q.key("dead"), id).Result()
+	if err != nil || removed == 0 {
+		return false, err
+	}
+	pipe := q.client.TxPipeline()
+	pipe.HSet(ctx, q.jobKey(id), "attempts", 0)
+	pipe.ZAdd(ctx, q.key("ready"), &redis.Z{Score: float64(q.now().UnixMilli()), Member: id})
+	_, err = pipe.Exec(ctx)
+	return err == nil, err
+}
+
+// Stats reports the number of jobs in each state.
+type Stats struct {
+	Ready    int64 `json:"ready"`
+	InFlight int64 `json:"in_flight"`
+	Dead     int64 `json:"dead"`
+}
+
+// Stats counts ready, in-flight and dead-lettered jobs.
+func (q *Queue) Stats(ctx context.Context) (Stats, error) {
+	pipe := q.client.Pipeline()
+	ready := pipe.ZCard(ctx, q.key("ready"))
+	inFlight := pipe.ZCard(ctx, q.key("inflight"))
+	dead := pipe.ZCard(ctx, q.key("dead"))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Stats{}, err
+	}
+	return Stats{Ready: ready.Val(), InFlight: inFlight.Val(), Dead: dead.Val()}, nil
+}
@@ -0,0 +1,230 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Handler processes one job. Returning an error retries the job with backoff until
+// its attempts run out; wrap the error with Permanent to dead-letter it at once.
+type Handler func(ctx context.Context, job *Job) error
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Options tunes a Runner.
+type Options struct {
+	// Concurrency is the number of jobs processed at once.
+	Concurrency int
+	// PollInterval is how long an idle worker waits before checking for jobs again.
+	PollInterval time.Duration
+	// VisibilityTimeout hides a claimed job from other workers. It is extended while
+	// the handler runs, so it only bounds how quickly a crashed worker's jobs return.
+	VisibilityTimeout time.Duration
+	MaxAttempts       int
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+}
+
+// DefaultOptions returns the options used by NewRunner.
+func DefaultOptions() Options {
+	return Options{
+		Concurrency:       4,
+		PollInterval:      time.Second,
+		VisibilityTimeout: 30 * time.Second,
+		MaxAttempts:       5,
+		InitialBackoff:    time.Second,
+		MaxBackoff:        5 * time.Minute,
+	}
+}
+
+// Runner claims jobs from a queue and dispatches them to the handler registered
+// for their type.
+type Runner struct {
+	queue  *Queue
+	logger *zap.Logger
+	opts   Options
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// NewRunner returns a runner using DefaultOptions.
+func NewRunner(queue *Queue, logger *zap.Logger) *Runner {
+	return NewRunnerWithOptions(queue, logger, DefaultOptions())
+}
+
+// NewRunnerWithOptions returns a runner using explicit options.
+func NewRunnerWithOptions(queue *Queue, logger *zap.Logger, opts Options) *Runner {
+	defaults := DefaultOptions()
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaults.Concurrency
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaults.PollInterval
+	}
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = defaults.VisibilityTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaults.MaxAttempts
+	}
+	if opts.MaxBackoff < opts.InitialBackoff {
+		opts.MaxBackoff = opts.InitialBackoff
+	}
+	return &Runner{
+		queue:    queue,
+		logger:   logger.Named("worker"),
+		opts:     opts,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Handle registers handler for jobs of jobType, replacing any earlier handler.
+func (r *Runner) Handle(jobType string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[jobType] = handler
+}
+
+// Run processes jobs until ctx is cancelled and then waits for running jobs to
+// finish. Jobs interrupted by cancellation are retried by another worker once their
+// visibility timeout passes.
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.loop(ctx)
+		}()
+	}
+	r.logger.Info("worker started", zap.Int("concurrency", r.opts.Concurrency))
+	wg.Wait()
+	r.logger.Info("worker stopped")
+}
+
+func (r *Runner) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		processed, err := r.ProcessNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("failed to claim job", zap.Error(err))
+		}
+		if processed {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.opts.PollInterval):
+		}
+	}
+}
+
+// ProcessNext claims and handles one due job, reporting whether there was one.
+func (r *Runner) ProcessNext(ctx context.Context) (bool, error) {
+	job, err := r.queue.Claim(ctx, r.opts.VisibilityTimeout)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	logger := r.logger.With(zap.String("job_id", job.ID), zap.String("job_type", job.Type), zap.Int("attempt", job.Attempts))
+	// Settling the job must not be skipped because the runner is stopping.
+	settleCtx := context.WithoutCancel(ctx)
+
+	handleErr := r.handle(ctx, job)
+	switch {
+	case handleErr == nil:
+		if err := r.queue.Ack(settleCtx, job); err != nil {
+			logger.Error("failed to acknowledge job", zap.Error(err))
+		}
+	case ctx.Err() != nil:
+		// Shutting down: leave the job in flight so it is redelivered after the
+		// visibility timeout instead of counting this as a failed attempt.
+		logger.Warn("job interrupted by shutdown", zap.Error(handleErr))
+	case r.exhausted(job, handleErr):
+		logger.Error("job failed permanently, moving to dead letters", zap.Error(handleErr))
+		if err := r.queue.DeadLetter(settleCtx, job, handleErr); err != nil {
+			logger.Error("failed to dead-letter job", zap.Error(err))
+		}
+	default:
+		delay := r.backoff(job.Attempts)
+		logger.Warn("job failed, retrying", zap.Error(handleErr), zap.Duration("retry_in", delay))
+		if err := r.queue.Retry(settleCtx, job, delay, handleErr); err != nil {
+			logger.Error("failed to schedule job retry", zap.Error(err))
+		}
+	}
+	return true, nil
+}
+
+func (r *Runner) handle(ctx context.Context, job *Job) (err error) {
+	r.mu.RLock()
+	handler, ok := r.handlers[job.Type]
+	r.mu.RUnlock()
+	if !ok {
+		return Permanent(fmt.Errorf("no handler registered for job type %q", job.Type))
+	}
+
+	// Keep the job hidden from other workers for as long as the handler runs.
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go func() {
+		ticker := time.NewTicker(r.opts.VisibilityTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-heartbeatCtx.Done():
+				return
+			case <-ticker.C:
+				if err := r.queue.Extend(heartbeatCtx, job, r.opts.VisibilityTimeout); err != nil && heartbeatCtx.Err() == nil {
+					r.logger.Warn("failed to extend job visibility", zap.String("job_id", job.ID), zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job handler panicked: %v", recovered)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+func (r *Runner) exhausted(job *Job, err error) bool {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return true
+	}
+	maxAttempts := r.opts.MaxAttempts
+	if job.MaxAttempts > 0 {
+		maxAttempts = job.MaxAttempts
+	}
+	return job.Attempts >= maxAttempts
+}
+
+func (r *Runner) backoff(attempt int) time.Duration {
+	delay := r.opts.InitialBackoff
+	for i := 1; i < attempt && delay < r.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.opts.MaxBackoff {
+		delay = r.opts.MaxBackoff
+	}
+	return delay
+}
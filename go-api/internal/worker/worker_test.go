@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+func newTestQueue(t *testing.T) (*Queue, *time.Time) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	queue := NewQueue(client)
+	queue.now = func() time.Time { return now }
+	return queue, &now
+}
+
+func TestQueueDeduplicatesAndRedeliversAfterVisibilityTimeout(t *testing.T) {
+	queue, now := newTestQueue(t)
+	ctx := context.Background()
+
+	job := Job{ID: "purge:2024-01-01", Type: "logs.purge"}
+	if added, err := queue.Enqueue(ctx, job); err != nil || !added {
+		t.Fatalf("expected job to be enqueued, got %v (%v)", added, err)
+	}
+	if added, _ := queue.Enqueue(ctx, job); added {
+		t.Fatal("expected a job with the same ID to be ignored")
+	}
+
+	claimed, err := queue.Claim(ctx, time.Minute)
+	if err != nil || claimed == nil || claimed.Attempts != 1 {
+		t.Fatalf("expected the first attempt to be claimed, got %+v (%v)", claimed, err)
+	}
+	if again, _ := queue.Claim(ctx, time.Minute); again != nil {
+		t.Fatalf("expected the in-flight job to be hidden, got %+v", again)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	redelivered, err := queue.Claim(ctx, time.Minute)
+	if err != nil || redelivered == nil || redelivered.ID != job.ID || redelivered.Attempts != 2 {
+		t.Fatalf("expected the job to be redelivered after its visibility timeout, got %+v (%v)", redelivered, err)
+	}
+	if err := queue.Ack(ctx, redelivered); err != nil {
+		t.Fatalf("Ack returned error: %v", err)
+	}
+	if stats, _ := queue.Stats(ctx); stats != (Stats{}) {
+		t.Fatalf("expected an empty queue after ack, got %+v", stats)
+	}
+}
+
+func TestRunnerRetriesWithBackoffThenDeadLetters(t *testing.T) {
+	queue, now := newTestQueue(t)
+	ctx := context.Background()
+
+	runner := NewRunnerWithOptions(queue, zap.NewNop(), Options{
+		MaxAttempts:    2,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     time.Minute,
+	})
+	calls := 0
+	runner.Handle("webhook.deliver", func(ctx context.Context, job *Job) error {
+		calls++
+		return errors.New("endpoint unavailable")
+	})
+
+	job, err := NewJob("webhook.deliver", map[string]string{"url": "https://example.com/hook"})
+	if err != nil {
+		t.Fatalf("NewJob returned error: %v", err)
+	}
+	if _, err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+
+	if processed, err := runner.ProcessNext(ctx); !processed || err != nil {
+		t.Fatalf("expected the first attempt to run, got %v (%v)", processed, err)
+	}
+	if processed, _ := runner.ProcessNext(ctx); processed {
+		t.Fatal("expected the retry to wait for its backoff")
+	}
+	*now = now.Add(10 * time.Second)
+	if processed, _ := runner.ProcessNext(ctx); !processed {
+		t.Fatal("expected the retry to run once the backoff elapsed")
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 handler calls, got %d", calls)
+	}
+
+	dead, err := queue.DeadLetters(ctx, 10)
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("expected one dead letter, got %+v (%v)", dead, err)
+	}
+	if dead[0].LastError != "endpoint unavailable" || dead[0].Attempts != 2 {
+		t.Fatalf("unexpected dead letter: %+v", dead[0])
+	}
+	var payload map[string]string
+	if err := dead[0].Decode(&payload); err != nil || payload["url"] != "https://example.com/hook" {
+		t.Fatalf("expected the payload to survive, got %v (%v)", payload, err)
+	}
+
+	if ok, err := queue.Redrive(ctx, job.ID); !ok || err != nil {
+		t.Fatalf("expected redrive to succeed, got %v (%v)", ok, err)
+	}
+	if stats, _ := queue.Stats(ctx); stats.Ready != 1 || stats.Dead != 0 {
+		t.Fatalf("expected the job to be ready again, got %+v", stats)
+	}
+}
+
+func TestRunnerDeadLettersPermanentErrorsAndUnknownTypes(t *testing.T) {
+	queue, _ := newTestQueue(t)
+	ctx := context.Background()
+
+	runner := NewRunner(queue, zap.NewNop())
+	runner.Handle("archive", func(context.Context, *Job) error {
+		return Permanent(errors.New("bucket does not exist"))
+	})
+	for _, jobType := range []string{"archive", "unknown"} {
+		if _, err := queue.Enqueue(ctx, Job{Type: jobType}); err != nil {
+			t.Fatalf("Enqueue returned error: %v", err)
+		}
+		if processed, _ := runner.ProcessNext(ctx); !processed {
+			t.Fatalf("expected the %s job to be processed", jobType)
+		}
+	}
+	if stats, _ := queue.Stats(ctx); stats.Dead != 2 || stats.Ready != 0 {
+		t.Fatalf("expected both jobs to be dead-lettered without retries, got %+v", stats)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/config"
+	"github.com/example/ai-check/internal/cron"
+	"github.com/example/ai-check/internal/eventbus"
+	"github.com/example/ai-check/internal/logbuffer"
+	"github.com/example/ai-check/internal/metering"
+	"github.com/example/ai-check/internal/metrics"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/tenants"
+	"github.com/example/ai-check/internal/warehouse"
+	"github.com/example/ai-check/internal/webhooks"
+	"github.com/example/ai-check/internal/worker"
+)
+
+// purgeLogsJob deletes verification logs older than a cutoff.
+const purgeLogsJob = "logs.purge"
+
+type purgeLogsPayload struct {
+	Cutoff    time.Time `json:"cutoff"`
+	BatchSize int       `json:"batch_size"`
+	// Scheduled is when the purge was due; tenant retentions count back from it.
+	// Jobs queued before it was recorded use the time they run.
+	Scheduled time.Time `json:"scheduled,omitempty"`
+}
+
+// purgeErasedJob deletes the verification logs users erased, with their images,
+// once they were erased before a cutoff.
+const purgeErasedJob = "logs.purge_erased"
+
+type purgeErasedPayload struct {
+	Cutoff    time.Time `json:"cutoff"`
+	BatchSize int       `json:"batch_size"`
+}
+
+// imageDeleter removes stored images; *storage.S3 implements it.
+type imageDeleter interface {
+	Delete(ctx context.Context, key string) error
+}
+
+func workerOptions(cfg config.WorkerConfig) worker.Options {
+	return worker.Options{
+		Concurrency:       cfg.Concurrency,
+		PollInterval:      cfg.PollInterval,
+		VisibilityTimeout: cfg.VisibilityTimeout,
+		MaxAttempts:       cfg.MaxAttempts,
+		InitialBackoff:    cfg.InitialBackoff,
+		MaxBackoff:        cfg.MaxBackoff,
+	}
+}
+
+// newJobRunner builds a runner with a handler for every job type the service knows.
+// images deletes the images of purged erasures; nil when images are not kept.
+// promMetrics, when set, counts the purged logs.
+func newJobRunner(queue *worker.Queue, repo *repository.VerificationRepository, images imageDeleter, tenantStore *tenants.Store, hooks *webhooks.Service, meter *metering.Meter, exporter *warehouse.Exporter, relay *eventbus.Relay, promMetrics *metrics.Metrics, cfg config.WorkerConfig, logger *zap.Logger) *worker.Runner {
+	runner := worker.NewRunnerWithOptions(queue, logger, workerOptions(cfg))
+	observePurged := func(reason string, rows int64) {
+		if promMetrics != nil && rows > 0 {
+			promMetrics.ObserveLogsPurged(reason, rows)
+		}
+	}
+	runner.Handle(purgeLogsJob, func(ctx context.Context, job *worker.Job) error {
+		var payload purgeLogsPayload
+		if err := job.Decode(&payload); err != nil {
+			return worker.Permanent(fmt.Errorf("decode payload: %w", err))
+		}
+		var retentions map[string]time.Duration
+		if tenantStore != nil {
+			var err error
+			if retentions, err = tenantStore.Retentions(ctx); err != nil {
+				return fmt.Errorf("load tenant retentions: %w", err)
+			}
+		}
+		scheduled := payload.Scheduled
+		if scheduled.IsZero() {
+			scheduled = time.Now().UTC()
+		}
+		// Tenants with their own retention are purged separately, so the global cutoff
+		// never deletes logs they keep longer.
+		excluded := make([]string, 0, len(retentions))
+		for tenantID, retention := range retentions {
+			excluded = append(excluded, tenantID)
+			cutoff := scheduled.Add(-retention)
+			deleted, err := repo.DeleteTenantOlderThan(ctx, tenantID, cutoff, payload.BatchSize)
+			observePurged("retention", deleted)
+			if err != nil {
+				return fmt.Errorf("purge of tenant %s failed after deleting %d rows: %w", tenantID, deleted, err)
+			}
+			logger.Info("tenant retention purge completed", zap.String("tenant_id", tenantID), zap.Int64("deleted", deleted), zap.Time("cutoff", cutoff))
+		}
+		deleted, err := repo.DeleteOlderThan(ctx, payload.Cutoff, payload.BatchSize, excluded...)
+		observePurged("retention", deleted)
+		if err != nil {
+			return fmt.Errorf("purge failed after deleting %d rows: %w", deleted, err)
+		}
+		logger.Info("retention purge completed", zap.Int64("deleted", deleted), zap.Time("cutoff", payload.Cutoff))
+		return nil
+	})
+	runner.Handle(purgeErasedJob, func(ctx context.Context, job *worker.Job) error {
+		var payload purgeErasedPayload
+		if err := job.Decode(&payload); err != nil {
+			return worker.Permanent(fmt.Errorf("decode payload: %w", err))
+		}
+		if payload.BatchSize <= 0 {
+			payload.BatchSize = 1000
+		}
+		// Images go first, so a failed delete keeps the logs that locate them for the
+		// retry.
+		deleteImages := func(logs []*repository.VerificationLog) error {
+			for _, log := range logs {
+				if log.ImageKey == "" || images == nil {
+					continue
+				}
+				if err := images.Delete(ctx, log.ImageKey); err != nil {
+					return fmt.Errorf("delete image of %s: %w", log.RequestID, err)
+				}
+			}
+			return nil
+		}
+		for {
+			userIDs, err := repo.ErasedUsers(ctx, payload.Cutoff, payload.BatchSize)
+			if err != nil {
+				return fmt.Errorf("list erased users: %w", err)
+			}
+			for _, userID := range userIDs {
+				purged, err := repo.PurgeUser(ctx, userID, payload.Cutoff, payload.BatchSize, deleteImages)
+				observePurged("erasure", purged)
+				if err != nil {
+					return fmt.Errorf("purge of user %s failed after deleting %d rows: %w", userID, purged, err)
+				}
+				logger.Info("erasure purge completed", zap.String("user_id", userID), zap.Int64("deleted", purged), zap.Time("cutoff", payload.Cutoff))
+			}
+			if len(userIDs) < payload.BatchSize {
+				return nil
+			}
+		}
+	})
+	// Logs buffered by any instance are saved, whether or not this one buffers.
+	runner.Handle(logbuffer.ReplayJob, logbuffer.New(queue, repo, logger).Replay)
+	if hooks != nil {
+		runner.Handle(webhooks.DeliverJob, hooks.Deliver)
+	}
+	if meter != nil {
+		runner.Handle(metering.ReportJob, meter.Report)
+	}
+	if exporter != nil {
+		runner.Handle(warehouse.ExportJob, exporter.Export)
+	}
+	if relay != nil {
+		runner.Handle(eventbus.PublishJob, relay.Send)
+	}
+	return runner
+}
+
+// schedulePurge enqueues a purge of logs older than retention. The job ID is derived
+// from the interval window so replicas scheduling the same run enqueue it once.
+func schedulePurge(ctx context.Context, queue *worker.Queue, retention, interval time.Duration, batchSize int) error {
+	now := time.Now().UTC()
+	return enqueueOnce(ctx, queue, purgeLogsJob, now.Truncate(interval), purgeLogsPayload{Cutoff: now.Add(-retention), BatchSize: batchSize, Scheduled: now})
+}
+
+// enqueueOnce enqueues a job identified by its type and window, so every replica
+// scheduling the same window enqueues it once.
+func enqueueOnce(ctx context.Context, queue *worker.Queue, jobType string, window time.Time, payload interface{}) error {
+	job, err := worker.NewJob(jobType, payload)
+	if err != nil {
+		return err
+	}
+	job.ID = fmt.Sprintf("%s:%d", jobType, window.Unix())
+	_, err = queue.Enqueue(ctx, job)
+	return err
+}
+
+// newScheduler registers a cron task for every configured maintenance job. It
+// returns nil when cron is disabled.
+func newScheduler(cfg *config.Config, client *redis.Client, queue *worker.Queue, meter *metering.Meter, logger *zap.Logger) (*cron.Scheduler, error) {
+	if !cfg.Cron.Enabled {
+		return nil, nil
+	}
+	opts := cron.DefaultOptions()
+	opts.LeaseTTL = cfg.Cron.LeaseTTL
+	scheduler := cron.NewSchedulerWithOptions(client, logger, opts)
+
+	if purge := cfg.Cron.PurgeLogs; purge.Retention > 0 {
+		err := scheduler.Register("purge_logs", purge.Schedule, func(ctx context.Context, scheduled time.Time) error {
+			return enqueueOnce(ctx, queue, purgeLogsJob, scheduled, purgeLogsPayload{Cutoff: scheduled.Add(-purge.Retention), BatchSize: purge.BatchSize, Scheduled: scheduled})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cron.purge_logs: %w", err)
+		}
+	}
+	if purge := cfg.Cron.PurgeErased; purge.Schedule != "" {
+		err := scheduler.Register("purge_erased", purge.Schedule, func(ctx context.Context, scheduled time.Time) error {
+			return enqueueOnce(ctx, queue, purgeErasedJob, scheduled, purgeErasedPayload{Cutoff: scheduled.Add(-purge.Grace), BatchSize: purge.BatchSize})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cron.purge_erased: %w", err)
+		}
+	}
+	if meter != nil && meter.Reporting() {
+		err := scheduler.Register("usage_report", "@every "+cfg.Metering.Stripe.ReportInterval.String(), func(ctx context.Context, scheduled time.Time) error {
+			return enqueueOnce(ctx, queue, metering.ReportJob, scheduled, struct{}{})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("metering.stripe.report_interval: %w", err)
+		}
+	}
+	if cfg.Warehouse.Sink != "" {
+		err := scheduler.Register("warehouse_export", cfg.Warehouse.Schedule, func(ctx context.Context, scheduled time.Time) error {
+			return enqueueOnce(ctx, queue, warehouse.ExportJob, scheduled, struct{}{})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("warehouse.schedule: %w", err)
+		}
+	}
+	return scheduler, nil
+}
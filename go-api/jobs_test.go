@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/config"
+	"github.com/example/ai-check/internal/cron"
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/metrics"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/tenants"
+	"github.com/example/ai-check/internal/worker"
+	"github.com/example/ai-check/migrations"
+)
+
+func TestScheduledPurgeRunsOncePerInterval(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	old := &repository.VerificationLog{RequestID: "old", UserID: "user-1", SHA1Hash: "old-hash"}
+	if err := repo.SaveLog(ctx, old); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+	db.Model(old).Update("created_at", time.Now().Add(-48*time.Hour))
+	if err := repo.SaveLog(ctx, &repository.VerificationLog{RequestID: "new", UserID: "user-1", SHA1Hash: "new-hash"}); err != nil {
+		t.Fatalf("SaveLog returned error: %v", err)
+	}
+
+	server, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer server.Close()
+	defer client.Close()
+	queue := worker.NewQueue(client)
+
+	for i := 0; i < 2; i++ {
+		if err := schedulePurge(ctx, queue, 24*time.Hour, time.Hour, 100); err != nil {
+			t.Fatalf("schedulePurge returned error: %v", err)
+		}
+	}
+	if stats, _ := queue.Stats(ctx); stats.Ready != 1 {
+		t.Fatalf("expected replicas scheduling the same window to enqueue one job, got %+v", stats)
+	}
+
+	promMetrics := metrics.New()
+	runner := newJobRunner(queue, repo, nil, nil, nil, nil, nil, nil, promMetrics, config.Default().Worker, zap.NewNop())
+	if processed, err := runner.ProcessNext(ctx); !processed || err != nil {
+		t.Fatalf("expected the purge job to run, got %v (%v)", processed, err)
+	}
+	var remaining int64
+	db.Model(&repository.VerificationLog{}).Count(&remaining)
+	if remaining != 1 {
+		t.Fatalf("expected only the recent log to remain, got %d", remaining)
+	}
+	var exposition strings.Builder
+	if err := promMetrics.Write(&exposition); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if !strings.Contains(exposition.String(), `ai_check_logs_purged_total{reason="retention"} 1`) {
+		t.Fatalf("expected the purged log to be counted, got\n%s", exposition.String())
+	}
+}
+
+func TestPurgeAppliesTenantRetentions(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if _, err := migrations.New(db, zap.NewNop()).Up(ctx); err != nil {
+		t.Fatalf("Up returned error: %v", err)
+	}
+	server, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer server.Close()
+	defer client.Close()
+	store := newTenantStore(db, client, config.Default().Tenants, zap.NewNop())
+	if _, err := store.Put(ctx, "keeper", tenants.Settings{RetentionDays: 7}); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	if _, err := store.Put(ctx, "shredder", tenants.Settings{RetentionDays: 1}); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+
+	for _, log := range []struct {
+		id, tenant string
+		age        time.Duration
+	}{
+		{"global-old", "", 3 * 24 * time.Hour},
+		{"global-new", "", time.Hour},
+		{"keeper-old", "keeper", 3 * 24 * time.Hour},
+		{"shredder-old", "shredder", 36 * time.Hour},
+		{"shredder-new", "shredder", time.Hour},
+	} {
+		row := &repository.VerificationLog{RequestID: log.id, UserID: "user-1", TenantID: log.tenant, SHA1Hash: log.id}
+		if err := repo.SaveLog(ctx, row); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+		db.Model(row).Update("created_at", time.Now().Add(-log.age))
+	}
+
+	queue := worker.NewQueue(client)
+	if err := schedulePurge(ctx, queue, 2*24*time.Hour, time.Hour, 100); err != nil {
+		t.Fatalf("schedulePurge returned error: %v", err)
+	}
+	runner := newJobRunner(queue, repo, nil, store, nil, nil, nil, nil, nil, config.Default().Worker, zap.NewNop())
+	if processed, err := runner.ProcessNext(ctx); !processed || err != nil {
+		t.Fatalf("expected the purge job to run, got %v (%v)", processed, err)
+	}
+	var remaining []string
+	db.Model(&repository.VerificationLog{}).Order("request_id").Pluck("request_id", &remaining)
+	if want := []string{"global-new", "keeper-old", "shredder-new"}; strings.Join(remaining, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v to remain, got %v", want, remaining)
+	}
+}
+
+func TestSchedulerRegistersConfiguredTasks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer server.Close()
+	defer client.Close()
+	queue := worker.NewQueue(client)
+
+	cfg := config.Default()
+	if scheduler, err := newScheduler(cfg, client, queue, nil, zap.NewNop()); err != nil || scheduler == nil {
+		t.Fatalf("expected a scheduler without tasks, got %v (%v)", scheduler, err)
+	}
+	cfg.Cron.PurgeLogs.Retention = 30 * 24 * time.Hour
+	cfg.Cron.PurgeLogs.Schedule = "0 3 * *"
+	if _, err := newScheduler(cfg, client, queue, nil, zap.NewNop()); err == nil {
+		t.Fatal("expected an invalid schedule to fail startup")
+	}
+	cfg.Cron.PurgeLogs.Schedule = "0 3 * * *"
+	scheduler, err := newScheduler(cfg, client, queue, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("newScheduler returned error: %v", err)
+	}
+
+	router := newAdminRouter(config.AdminConfig{}, adminServices{scheduler: scheduler, queue: queue})
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/admin/api/jobs", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		Instance  string            `json:"instance"`
+		Schedules []cron.TaskStatus `json:"schedules"`
+		Queue     worker.Stats      `json:"queue"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if body.Instance != scheduler.InstanceID() || len(body.Schedules) != 2 || body.Schedules[0].Name != "purge_logs" || body.Schedules[1].Name != "purge_erased" {
+		t.Fatalf("unexpected jobs response %s", resp.Body.String())
+	}
+
+	cfg.Cron.Enabled = false
+	if scheduler, _ := newScheduler(cfg, client, queue, nil, zap.NewNop()); scheduler != nil {
+		t.Fatal("expected no scheduler when cron is disabled")
+	}
+}
+
+type recordingImageDeleter struct {
+	deleted []string
+}
+
+func (d *recordingImageDeleter) Delete(ctx context.Context, key string) error {
+	d.deleted = append(d.deleted, key)
+	return nil
+}
+
+func TestPurgeErasedDeletesLogsAndImagesAfterTheGrace(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+	for _, log := range []*repository.VerificationLog{
+		{RequestID: "erased-old", UserID: "user-1", SHA1Hash: "hash-1", ImageKey: "user-1/erased-old"},
+		{RequestID: "erased-new", UserID: "user-2", SHA1Hash: "hash-2", ImageKey: "user-2/erased-new"},
+		{RequestID: "kept", UserID: "user-3", SHA1Hash: "hash-3", ImageKey: "user-3/kept"},
+	} {
+		if err := repo.SaveLog(ctx, log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+	for _, userID := range []string{"user-1", "user-2"} {
+		if _, err := repo.SoftDeleteUserLogs(ctx, userID, 10, nil); err != nil {
+			t.Fatalf("SoftDeleteUserLogs returned error: %v", err)
+		}
+	}
+	db.Unscoped().Model(&repository.VerificationLog{}).Where("user_id = ?", "user-1").Update("deleted_at", time.Now().Add(-96*time.Hour))
+
+	server, client, err := devmode.StartRedis()
+	if err != nil {
+		t.Fatalf("StartRedis returned error: %v", err)
+	}
+	defer server.Close()
+	defer client.Close()
+	queue := worker.NewQueue(client)
+	if err := enqueueOnce(ctx, queue, purgeErasedJob, time.Now(), purgeErasedPayload{Cutoff: time.Now().Add(-72 * time.Hour), BatchSize: 10}); err != nil {
+		t.Fatalf("enqueueOnce returned error: %v", err)
+	}
+
+	images := &recordingImageDeleter{}
+	runner := newJobRunner(queue, repo, images, nil, nil, nil, nil, nil, nil, config.Default().Worker, zap.NewNop())
+	if processed, err := runner.ProcessNext(ctx); !processed || err != nil {
+		t.Fatalf("expected the purge job to run, got %v (%v)", processed, err)
+	}
+	if len(images.deleted) != 1 || images.deleted[0] != "user-1/erased-old" {
+		t.Fatalf("expected only the image of the old erasure to be deleted, got %v", images.deleted)
+	}
+	var remaining []string
+	db.Unscoped().Model(&repository.VerificationLog{}).Order("request_id").Pluck("request_id", &remaining)
+	if strings.Join(remaining, ",") != "erased-new,kept" {
+		t.Fatalf("expected the erasure within the grace to be kept, got %v", remaining)
+	}
+}
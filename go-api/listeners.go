@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/example/ai-check/internal/config"
+)
+
+// listenTCP binds the public TCP listener, optionally with SO_REUSEPORT.
+func listenTCP(addr string, reusePort bool) (net.Listener, error) {
+	lc := net.ListenConfig{}
+	if reusePort {
+		lc.Control = setReusePort
+	}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
+
+// listenUnixSocket binds a Unix domain socket, replacing a stale socket file left by
+// a previous run, and creates it with the configured permissions. A socket that still
+// accepts connections belongs to a running process and is left alone. The file is
+// removed again when the listener is closed.
+func listenUnixSocket(cfg config.UnixSocket) (net.Listener, error) {
+	mode, err := cfg.FileMode()
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Lstat(cfg.Path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", cfg.Path)
+		}
+		if err := removeStaleSocket(cfg.Path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var listener net.Listener
+	err = withFileMode(mode, func() error {
+		listener, err = net.Listen("unix", cfg.Path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return listener, nil
+}
+
+// removeStaleSocket removes the socket at path if nothing listens on it.
+func removeStaleSocket(path string) error {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("check socket %s: %w", path, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove stale socket: %w", err)
+	}
+	return nil
+}
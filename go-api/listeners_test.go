@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/ai-check/internal/config"
+)
+
+func TestListenUnixSocketServesHTTPWithConfiguredMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+
+	// A leftover socket from a previous run must not prevent startup.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to create stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := listenUnixSocket(config.UnixSocket{Path: path, Mode: "0600"})
+	if err != nil {
+		t.Fatalf("listenUnixSocket returned error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("expected mode 0600, got %o", perm)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://unix/health")
+	if err != nil {
+		t.Fatalf("request over unix socket failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "ok" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestListenUnixSocketRefusesToReplaceRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	if _, err := listenUnixSocket(config.UnixSocket{Path: path, Mode: "0660"}); err == nil {
+		t.Fatal("expected an error for a non-socket path")
+	}
+}
+
+func TestListenUnixSocketRefusesSocketInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	running, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to create socket: %v", err)
+	}
+	defer running.Close()
+
+	if _, err := listenUnixSocket(config.UnixSocket{Path: path, Mode: "0660"}); err == nil {
+		t.Fatal("expected an error for a socket another process listens on")
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("the running socket was removed: %v", err)
+	}
+	conn.Close()
+}
+
+func TestListenTCPWithReusePortSharesAddress(t *testing.T) {
+	if !reusePortSupported {
+		t.Skip("SO_REUSEPORT is not supported on this platform")
+	}
+
+	first, err := listenTCP("127.0.0.1:0", true)
+	if err != nil {
+		t.Fatalf("first listen failed: %v", err)
+	}
+	defer first.Close()
+
+	second, err := listenTCP(first.Addr().String(), true)
+	if err != nil {
+		t.Fatalf("expected a second listener on %s, got %v", first.Addr(), err)
+	}
+	second.Close()
+
+	if _, err := listenTCP(first.Addr().String(), false); err == nil {
+		t.Fatal("expected binding without SO_REUSEPORT to fail while the address is in use")
+	}
+}
@@ -2,116 +2,341 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	gormlogger "gorm.io/gorm/logger"
 
-	"github.com/example/ai-check/internal/auth"
-	"github.com/example/ai-check/internal/grpcclient"
-	"github.com/example/ai-check/internal/handlers"
+	"github.com/example/ai-check/internal/buildinfo"
+	"github.com/example/ai-check/internal/config"
 	"github.com/example/ai-check/internal/logging"
 	"github.com/example/ai-check/internal/repository"
-	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/migrations"
 )
 
 func main() {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	logger, err := logging.NewLogger()
+	logger, err := logging.NewLoggerWithLevel(logLevel)
 	if err != nil {
 		panic(err)
 	}
 	defer logger.Sync() //nolint:errcheck
 
-	db := initDatabase(ctx, logger)
-	repo := repository.NewVerificationRepository(db, logger)
-	if err := repo.AutoMigrate(ctx); err != nil {
-		logger.Fatal("auto migrate failed", zap.Error(err))
+	os.Exit(runCLI(os.Args[1:], logger))
+}
+
+// command is a single ai-check subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, logger *zap.Logger) error
+}
+
+var commands = []command{
+	{name: "serve", summary: "run the HTTP API", run: runServe},
+	{name: "migrate", summary: "apply pending database migrations, or list them with -status", run: runMigrate},
+	{name: "worker", summary: "run background maintenance jobs", run: runWorker},
+	{name: "purge", summary: "delete verification logs older than a retention period", run: runPurge},
+	{name: "recount-metrics", summary: "recompute the metrics counters from the verification logs", run: runRecountMetrics},
+	{name: "backfill-hashes", summary: "record SHA-256 hashes of verifications from their stored images", run: runBackfillHashes},
+	{name: "openapi", summary: "print the OpenAPI document of the HTTP API", run: runOpenAPI},
+	{name: "healthcheck", summary: "probe a running API instance and exit non-zero when unhealthy", run: runHealthcheck},
+	{name: "version", summary: "print build information", run: runVersion},
+}
+
+// runCLI dispatches to a subcommand and returns the process exit code. Invoking the
+// binary without a subcommand (or with only flags) runs "serve" for compatibility.
+func runCLI(args []string, logger *zap.Logger) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	redisCtx, redisCancel := context.WithTimeout(ctx, 5*time.Second)
-	defer redisCancel()
-	redisClient := initRedis(redisCtx, logger)
+	if name == "help" {
+		printUsage(os.Stdout)
+		return 0
+	}
 
-	imageProcessorAddr := getEnv("IMAGE_PROCESSOR_ADDR", "rust-service:50051")
-	client, conn, err := grpcclient.DialImageProcessor(ctx, imageProcessorAddr, logger)
-	if err != nil {
-		logger.Fatal("failed to connect to image processor", zap.Error(err))
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(args, logger); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			var usageErr *usageError
+			if errors.As(err, &usageErr) {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, usageErr.err)
+				return 2
+			}
+			var configErr *config.ValidationError
+			if errors.As(err, &configErr) {
+				fmt.Fprintf(os.Stderr, "%s: invalid configuration, %v\n", cmd.name, configErr)
+				return 1
+			}
+			logger.Error("command failed", zap.String("command", cmd.name), zap.Error(err))
+			return 1
+		}
+		return 0
 	}
-	defer conn.Close()
 
-	cache := usecase.NewRedisCache(redisClient)
-	uc := usecase.NewVerificationUseCase(repo, cache, client, logger)
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	return 2
+}
 
-	r := gin.Default()
-	r.MaxMultipartMemory = handlers.MaxUploadSize
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ai-check <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'ai-check <command> -h' for command flags.")
+}
 
-	jwtSecret := getEnv("JWT_SECRET", "dev-secret")
-	jwtAudience := os.Getenv("JWT_AUDIENCE")
-	authMiddleware := auth.JWTMiddleware(jwtSecret, jwtAudience)
+// runVersion prints the build description of the binary.
+func runVersion(args []string, _ *zap.Logger) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &usageError{err: err}
+	}
+	info := buildinfo.Get()
+	fmt.Printf("ai-check %s (commit %s, built %s, %s)\n", info.Version, valueOrUnknown(info.Commit), valueOrUnknown(info.BuildTime), info.GoVersion)
+	return nil
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+// usageError marks invalid command-line input so it is reported without a stack of log fields.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
 
-	handlers.RegisterRoutes(r, uc, authMiddleware)
+func (e *usageError) Unwrap() error { return e.err }
 
-	server := &http.Server{
-		Addr:    ":8080",
-		Handler: r,
+// newFlagSet creates a flag set carrying the -config flag shared by every command.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML configuration file")
+	return fs, configPath
+}
+
+// parseFlags parses command arguments and loads the shared configuration.
+func parseFlags(fs *flag.FlagSet, configPath *string, args []string) (*config.Config, error) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, &usageError{err: err}
+	}
+	if fs.NArg() > 0 {
+		return nil, &usageError{err: fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))}
 	}
 
-	logger.Info("Golang API listening", zap.String("addr", ":8080"))
-	if err := serveHTTPServer(server, 15*time.Second, logger); err != nil {
-		logger.Fatal("server failed", zap.Error(err))
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
+	applyLogLevel(cfg.Log)
+	return cfg, nil
 }
 
-func initDatabase(ctx context.Context, zapLogger *zap.Logger) *gorm.DB {
-	dsn := getEnv("DATABASE_DSN", "host=postgres user=postgres password=postgres dbname=aiverify port=5432 sslmode=disable")
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Info)})
+// regionLogger tags every log line with the deployment region, when one is set.
+func regionLogger(logger *zap.Logger, region string) *zap.Logger {
+	if region == "" {
+		return logger
+	}
+	return logger.With(zap.String("region", region))
+}
+
+func newRepository(db *gorm.DB, cfg config.DatabaseConfig, logger *zap.Logger) *repository.VerificationRepository {
+	return repository.NewVerificationRepositoryWithRetry(db, logger, repository.RetryPolicy{
+		Attempts:       cfg.RetryAttempts,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+	})
+}
+
+// prepareSchema applies the pending migrations when migrate is set, and otherwise
+// returns an error wrapping migrations.ErrSchemaOutdated when there are any, so serve
+// does not run against a schema it does not expect.
+func prepareSchema(ctx context.Context, db *gorm.DB, migrate bool, logger *zap.Logger) error {
+	migrator := migrations.New(db, logger)
+	if !migrate {
+		return migrator.Check(ctx)
+	}
+	_, err := migrator.Up(ctx)
+	return err
+}
+
+// initDatabase opens the connection pool and waits for Postgres to answer a ping.
+// When password is non-nil it is consulted for every new connection, so a rotated
+// password is picked up without restarting.
+func initDatabase(ctx context.Context, cfg config.DatabaseConfig, startup config.StartupConfig, zapLogger *zap.Logger, password func() string) (*gorm.DB, error) {
+	db, sqlDB, err := openDatabase(cfg, password)
 	if err != nil {
-		zapLogger.Fatal("failed to connect to database", zap.Error(err))
+		return nil, err
 	}
+	if err := waitForDependency(ctx, startup, zapLogger, "postgres", sqlDB.PingContext); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
 
-	sqlDB, err := db.DB()
+// openReader opens the connection pool of the local streaming replica, sized and
+// authenticated like the primary. Like openReplica it does not wait for the reader:
+// reads go to the primary while it is unavailable.
+func openReader(cfg config.DatabaseConfig, password func() string) (*gorm.DB, error) {
+	cfg.DSN = cfg.ReaderDSN
+	db, _, err := openDatabase(cfg, password)
 	if err != nil {
-		zapLogger.Fatal("failed to access db handle", zap.Error(err))
+		return nil, fmt.Errorf("reader: %w", err)
 	}
-	sqlDB.SetMaxIdleConns(5)
-	sqlDB.SetMaxOpenConns(10)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	return db, nil
+}
 
-	if err := sqlDB.PingContext(ctx); err != nil {
-		zapLogger.Fatal("database ping failed", zap.Error(err))
+// openReplica opens the connection pool of the remote-region read replica, sized like
+// the local pool. It does not wait for the replica: it is only needed once the local
+// database fails, and an unreachable replica must not hold up startup.
+func openReplica(cfg config.DatabaseConfig, region config.RegionConfig) (*gorm.DB, error) {
+	cfg.DSN = region.ReplicaDSN
+	cfg.Password = ""
+	db, _, err := openDatabase(cfg, nil)
+	if err != nil {
+		return nil, fmt.Errorf("replica: %w", err)
+	}
+	return db, nil
+}
+
+// openDatabase opens a connection pool without connecting.
+func openDatabase(cfg config.DatabaseConfig, password func() string) (*gorm.DB, *sql.DB, error) {
+	connConfig, err := pgx.ParseConfig(cfg.DSN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse database dsn: %w", err)
+	}
+	if cfg.Password != "" {
+		connConfig.Password = cfg.Password
 	}
+	var openOpts []stdlib.OptionOpenDB
+	if password != nil {
+		openOpts = append(openOpts, stdlib.OptionBeforeConnect(func(_ context.Context, cc *pgx.ConnConfig) error {
+			if current := password(); current != "" {
+				cc.Password = current
+			}
+			return nil
+		}))
+	}
+	sqlDB := stdlib.OpenDB(*connConfig, openOpts...)
 
-	return db
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:               gormlogger.Default.LogMode(gormlogger.Info),
+		DisableAutomaticPing: true,
+		PrepareStmt:          cfg.PrepareStatements,
+	})
+	if err != nil {
+		sqlDB.Close()
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return db, sqlDB, nil
 }
 
-func initRedis(ctx context.Context, zapLogger *zap.Logger) *redis.Client {
-	addr := getEnv("REDIS_ADDR", "redis:6379")
-	client := redis.NewClient(&redis.Options{Addr: addr})
-	if err := client.Ping(ctx).Err(); err != nil {
-		zapLogger.Fatal("redis connection failed", zap.Error(err))
+// initRedis creates the Redis client and waits for Redis to answer a ping.
+// Like initDatabase, a non-nil credentials func is consulted for every new connection.
+func initRedis(ctx context.Context, cfg config.RedisConfig, startup config.StartupConfig, zapLogger *zap.Logger, credentials func() (string, string)) (*redis.Client, error) {
+	var client *redis.Client
+	if cfg.Sentinel.MasterName != "" {
+		opts := &redis.FailoverOptions{
+			MasterName:       cfg.Sentinel.MasterName,
+			SentinelAddrs:    cfg.Sentinel.Addrs,
+			SentinelPassword: cfg.Sentinel.Password,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			DialTimeout:      cfg.DialTimeout,
+		}
+		if credentials != nil {
+			opts.Username, opts.Password = "", ""
+			opts.OnConnect = redisAuth(credentials)
+		}
+		client = redis.NewFailoverClient(opts)
+	} else {
+		opts := &redis.Options{Addr: cfg.Addr, Username: cfg.Username, Password: cfg.Password, DialTimeout: cfg.DialTimeout}
+		if credentials != nil {
+			opts.Username, opts.Password = "", ""
+			opts.OnConnect = redisAuth(credentials)
+		}
+		client = redis.NewClient(opts)
+	}
+	ping := func(ctx context.Context) error { return client.Ping(ctx).Err() }
+	if err := waitForDependency(ctx, startup, zapLogger, "redis", ping); err != nil {
+		client.Close()
+		return nil, err
 	}
-	return client
+	return client, nil
 }
 
-func serveHTTPServer(server *http.Server, shutdownTimeout time.Duration, logger *zap.Logger) error {
-	return serveHTTPServerWithOptions(server, shutdownTimeout, logger, nil, nil)
+// redisAuth authenticates new connections with the current credentials, so rotated
+// secrets apply without a restart.
+func redisAuth(credentials func() (string, string)) func(ctx context.Context, cn *redis.Conn) error {
+	return func(ctx context.Context, cn *redis.Conn) error {
+		username, password := credentials()
+		switch {
+		case password == "":
+			return nil
+		case username != "":
+			return cn.AuthACL(ctx, username, password).Err()
+		default:
+			return cn.Auth(ctx, password).Err()
+		}
+	}
 }
 
-func serveHTTPServerWithListener(server *http.Server, shutdownTimeout time.Duration, logger *zap.Logger, listener net.Listener) error {
-	return serveHTTPServerWithOptions(server, shutdownTimeout, logger, listener, nil)
+// openCacheCluster connects to the Redis Cluster holding the result cache, with the
+// credentials of the main Redis. It does not wait for the cluster: the client learns
+// the slots of the nodes on first use.
+func openCacheCluster(cfg config.RedisConfig, credentials func() (string, string)) *redis.ClusterClient {
+	opts := &redis.ClusterOptions{
+		Addrs:       cfg.CacheCluster.Addrs,
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		DialTimeout: cfg.DialTimeout,
+	}
+	if credentials != nil {
+		opts.Username, opts.Password = "", ""
+		opts.OnConnect = redisAuth(credentials)
+	}
+	return redis.NewClusterClient(opts)
 }
 
 func serveHTTPServerWithOptions(server *http.Server, shutdownTimeout time.Duration, logger *zap.Logger, listener net.Listener, signalCh <-chan os.Signal) error {
@@ -163,10 +388,3 @@ func serveHTTPServerWithOptions(server *http.Server, shutdownTimeout time.Durati
 		return <-errCh
 	}
 }
-
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return fallback
-}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/lifecycle"
+	"github.com/example/ai-check/internal/metrics"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/storage"
+	"github.com/example/ai-check/internal/worker"
+	"github.com/example/ai-check/migrations"
+)
+
+// runMigrate applies the pending database migrations and exits. With -status it
+// lists the migrations and whether each was applied instead.
+func runMigrate(args []string, logger *zap.Logger) error {
+	fs, configPath := newFlagSet("migrate")
+	status := fs.Bool("status", false, "list the migrations and whether each was applied, without applying any")
+	cfg, err := parseFlags(fs, configPath, args)
+	if err != nil {
+		return err
+	}
+	if _, err := loadSecrets(cfg, logger); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	plan := newShutdownPlan(logger, cfg.Shutdown.StageTimeout)
+	defer plan.run() //nolint:errcheck
+
+	db, err := initDatabase(ctx, cfg.Database, requireDependencies(cfg.Startup), logger, nil)
+	if err != nil {
+		return err
+	}
+	plan.addDatabase(db)
+	migrator := migrations.New(db, logger)
+	if *status {
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return writeMigrationStatus(os.Stdout, statuses)
+	}
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Info("database schema is up to date", zap.Int("applied", len(applied)))
+	return nil
+}
+
+// writeMigrationStatus prints one line per migration: its version, name and when it
+// was applied, "pending", or "unknown" for migrations of a newer release.
+func writeMigrationStatus(w io.Writer, statuses []migrations.Status) error {
+	for _, status := range statuses {
+		state := "pending"
+		switch {
+		case status.Unknown:
+			state = "unknown, applied " + status.AppliedAt.UTC().Format(time.RFC3339)
+		case !status.AppliedAt.IsZero():
+			state = "applied " + status.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		if _, err := fmt.Fprintf(w, "%d_%s\t%s\n", status.Version, status.Name, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPurge deletes verification logs older than the requested retention period once.
+func runPurge(args []string, logger *zap.Logger) error {
+	fs, configPath := newFlagSet("purge")
+	olderThan := fs.Duration("older-than", 0, "delete logs created longer ago than this duration (required)")
+	batchSize := fs.Int("batch-size", 1000, "rows deleted per statement")
+	cfg, err := parseFlags(fs, configPath, args)
+	if err != nil {
+		return err
+	}
+	if _, err := loadSecrets(cfg, logger); err != nil {
+		return err
+	}
+	if *olderThan <= 0 {
+		return &usageError{err: errors.New("-older-than must be a positive duration")}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	plan := newShutdownPlan(logger, cfg.Shutdown.StageTimeout)
+	defer plan.run() //nolint:errcheck
+
+	db, err := initDatabase(ctx, cfg.Database, requireDependencies(cfg.Startup), logger, nil)
+	if err != nil {
+		return err
+	}
+	plan.addDatabase(db)
+	repo := newRepository(db, cfg.Database, logger)
+
+	cutoff := time.Now().UTC().Add(-*olderThan)
+	deleted, err := repo.DeleteOlderThan(ctx, cutoff, *batchSize)
+	if err != nil {
+		return fmt.Errorf("purge failed after deleting %d rows: %w", deleted, err)
+	}
+	logger.Info("purged verification logs", zap.Int64("deleted", deleted), zap.Time("cutoff", cutoff))
+	return nil
+}
+
+// runRecountMetrics recomputes the metrics counters from the verification logs, for
+// logs changed outside the API, e.g. by hand or by restoring a backup.
+func runRecountMetrics(args []string, logger *zap.Logger) error {
+	fs, configPath := newFlagSet("recount-metrics")
+	cfg, err := parseFlags(fs, configPath, args)
+	if err != nil {
+		return err
+	}
+	if _, err := loadSecrets(cfg, logger); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	plan := newShutdownPlan(logger, cfg.Shutdown.StageTimeout)
+	defer plan.run() //nolint:errcheck
+
+	db, err := initDatabase(ctx, cfg.Database, requireDependencies(cfg.Startup), logger, nil)
+	if err != nil {
+		return err
+	}
+	plan.addDatabase(db)
+	repo := newRepository(db, cfg.Database, logger)
+	if err := repo.RebuildMetricsCounters(ctx); err != nil {
+		return fmt.Errorf("recount failed: %w", err)
+	}
+	logger.Info("recounted verification metrics")
+	return nil
+}
+
+// runBackfillHashes records the SHA-256 of verifications made before it was stored,
+// reading their images back from object storage. Verifications whose image was not
+// kept cannot be backfilled and keep matching duplicates by SHA-1. It can be
+// interrupted and rerun.
+func runBackfillHashes(args []string, logger *zap.Logger) error {
+	fs, configPath := newFlagSet("backfill-hashes")
+	batchSize := fs.Int("batch-size", 100, "logs loaded per query")
+	cfg, err := parseFlags(fs, configPath, args)
+	if err != nil {
+		return err
+	}
+	if _, err := loadSecrets(cfg, logger); err != nil {
+		return err
+	}
+	if *batchSize <= 0 {
+		return &usageError{err: errors.New("-batch-size must be positive")}
+	}
+	store, err := newImageStore(cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to configure image storage: %w", err)
+	}
+	images, ok := store.(imageOpener)
+	if !ok {
+		return &usageError{err: errors.New("image storage must be configured to backfill hashes")}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	plan := newShutdownPlan(logger, cfg.Shutdown.StageTimeout)
+	defer plan.run() //nolint:errcheck
+
+	db, err := initDatabase(ctx, cfg.Database, requireDependencies(cfg.Startup), logger, nil)
+	if err != nil {
+		return err
+	}
+	plan.addDatabase(db)
+	repo := newRepository(db, cfg.Database, logger)
+
+	stats, err := backfillHashes(ctx, repo, images, *batchSize)
+	if err != nil {
+		return fmt.Errorf("backfill failed after %d logs: %w", stats.Backfilled, err)
+	}
+	logger.Info("backfilled SHA-256 hashes",
+		zap.Int("backfilled", stats.Backfilled),
+		zap.Int("missing_images", stats.Missing),
+		zap.Int("mismatched_images", stats.Mismatched))
+	return nil
+}
+
+// imageOpener reads stored images back; *storage.S3 implements it.
+type imageOpener interface {
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+type backfillStats struct {
+	Backfilled int
+	// Missing counts logs whose image is gone from storage.
+	Missing int
+	// Mismatched counts logs whose stored image does not have the logged SHA-1, so
+	// its SHA-256 would not describe the verified image.
+	Mismatched int
+}
+
+func backfillHashes(ctx context.Context, repo *repository.VerificationRepository, images imageOpener, batchSize int) (backfillStats, error) {
+	var stats backfillStats
+	var afterID uint
+	for {
+		logs, err := repo.ListMissingSHA256(ctx, afterID, batchSize)
+		if err != nil {
+			return stats, err
+		}
+		if len(logs) == 0 {
+			return stats, nil
+		}
+		for _, log := range logs {
+			afterID = log.ID
+			sha256Hash, sha1Hash, err := hashStoredImage(ctx, images, log.ImageKey)
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				stats.Missing++
+				continue
+			case err != nil:
+				return stats, err
+			case sha1Hash != log.SHA1Hash:
+				stats.Mismatched++
+				continue
+			}
+			if err := repo.SetSHA256Hash(ctx, log.ID, sha256Hash); err != nil {
+				return stats, err
+			}
+			stats.Backfilled++
+		}
+	}
+}
+
+func hashStoredImage(ctx context.Context, images imageOpener, key string) (string, string, error) {
+	body, err := images.Open(ctx, key)
+	if err != nil {
+		return "", "", err
+	}
+	defer body.Close()
+	sha256Hasher, sha1Hasher := sha256.New(), sha1.New()
+	if _, err := io.Copy(io.MultiWriter(sha256Hasher, sha1Hasher), body); err != nil {
+		return "", "", fmt.Errorf("read image %s: %w", key, err)
+	}
+	return hex.EncodeToString(sha256Hasher.Sum(nil)), hex.EncodeToString(sha1Hasher.Sum(nil)), nil
+}
+
+// runWorker processes background jobs until it receives SIGINT or SIGTERM. With
+// -retention it also schedules a purge of old logs every interval, and with
+// worker.metrics_addr or -metrics-addr it serves the Prometheus metrics of its jobs.
+func runWorker(args []string, logger *zap.Logger) error {
+	fs, configPath := newFlagSet("worker")
+	retention := fs.Duration("retention", 0, "purge logs older than this duration on every run (0 disables purging)")
+	interval := fs.Duration("interval", time.Hour, "time between scheduled purges")
+	batchSize := fs.Int("batch-size", 1000, "rows deleted per statement")
+	metricsAddr := fs.String("metrics-addr", "", "serve Prometheus metrics on this address, e.g. :9102 (overrides worker.metrics_addr)")
+	cfg, err := parseFlags(fs, configPath, args)
+	if err != nil {
+		return err
+	}
+	logger = regionLogger(logger, cfg.Region.Name)
+	store, err := loadSecrets(cfg, logger)
+	if err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return &usageError{err: errors.New("-interval must be a positive duration")}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	plan := newShutdownPlan(logger, cfg.Shutdown.StageTimeout)
+	defer plan.run() //nolint:errcheck
+
+	dbPassword, redisCredentials := store.connectCredentials()
+	db, err := initDatabase(ctx, cfg.Database, requireDependencies(cfg.Startup), logger, dbPassword)
+	if err != nil {
+		return err
+	}
+	plan.addDatabase(db)
+	redisClient, err := initRedis(ctx, cfg.Redis, requireDependencies(cfg.Startup), logger, redisCredentials)
+	if err != nil {
+		return err
+	}
+	plan.addCloser("redis", redisClient.Close)
+
+	queue := worker.NewQueue(redisClient)
+	hooks := newWebhookService(db, queue, cfg.Webhooks, logger)
+	meter := newMeter(db, cfg.Metering, logger)
+	repo := newRepository(db, cfg.Database, logger)
+	exporter, err := newExporter(db, repo, cfg.Warehouse, logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure warehouse export: %w", err)
+	}
+	relay, err := newEventRelay(queue, redisClient, cfg.Events, logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure event streaming: %w", err)
+	}
+	imageStore, err := newImageStore(cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to configure image storage: %w", err)
+	}
+	images, _ := imageStore.(imageDeleter)
+	var promMetrics *metrics.Metrics
+	if *metricsAddr == "" {
+		*metricsAddr = cfg.Worker.MetricsAddr
+	}
+	if *metricsAddr != "" {
+		promMetrics = metrics.New()
+		if err := serveWorkerMetrics(*metricsAddr, promMetrics, plan, logger); err != nil {
+			return err
+		}
+	}
+	runner := newJobRunner(queue, repo, images, newTenantStore(db, redisClient, cfg.Tenants, logger), hooks, meter, exporter, relay, promMetrics, cfg.Worker, logger)
+	scheduler, err := newScheduler(cfg, redisClient, queue, meter, logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure cron: %w", err)
+	}
+	background := lifecycle.New(logger.Named("lifecycle"), cfg.Shutdown.StageTimeout)
+	if scheduler != nil {
+		background.Go("cron", scheduler.Run)
+	}
+	if *retention > 0 {
+		background.Go("retention", func(ctx context.Context) {
+			ticker := time.NewTicker(*interval)
+			defer ticker.Stop()
+			for {
+				if err := schedulePurge(ctx, queue, *retention, *interval, *batchSize); err != nil && ctx.Err() == nil {
+					logger.Error("failed to schedule retention purge", zap.Error(err))
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		})
+	}
+	background.Go("worker", runner.Run)
+	plan.addBackground(background)
+
+	<-ctx.Done()
+	return nil
+}
+
+// serveWorkerMetrics serves the worker's Prometheus metrics on addr until the plan
+// shuts it down. The address is bound before it returns, so a bad or taken address
+// stops the worker from starting.
+func serveWorkerMetrics(addr string, m *metrics.Metrics, plan *shutdownPlan, logger *zap.Logger) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on metrics address %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	metricsServer := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := metricsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("metrics server failed", zap.Error(err), zap.String("addr", addr))
+		}
+	}()
+	plan.add("metrics-http", metricsServer.Shutdown)
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/metrics"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/storage"
+)
+
+type memoryImages map[string][]byte
+
+func (m memoryImages) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, fmt.Errorf("get object %s: %w", key, storage.ErrNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func TestBackfillHashesFromStoredImages(t *testing.T) {
+	ctx := context.Background()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	repo := repository.NewVerificationRepository(db, zap.NewNop())
+	if err := repo.AutoMigrate(ctx); err != nil {
+		t.Fatalf("AutoMigrate returned error: %v", err)
+	}
+
+	sha1Hex := func(data string) string {
+		sum := sha1.Sum([]byte(data))
+		return hex.EncodeToString(sum[:])
+	}
+	images := memoryImages{"kept": []byte("kept-image"), "replaced": []byte("other-image")}
+	for _, log := range []*repository.VerificationLog{
+		{RequestID: "kept", ImageKey: "kept", SHA1Hash: sha1Hex("kept-image")},
+		{RequestID: "replaced", ImageKey: "replaced", SHA1Hash: sha1Hex("replaced-image")},
+		{RequestID: "gone", ImageKey: "gone", SHA1Hash: sha1Hex("gone-image")},
+		{RequestID: "unstored", SHA1Hash: sha1Hex("unstored-image")},
+		{RequestID: "current", ImageKey: "current", SHA1Hash: sha1Hex("current-image"), SHA256Hash: "already-set"},
+	} {
+		log.UserID = "user-1"
+		if err := repo.SaveLog(ctx, log); err != nil {
+			t.Fatalf("SaveLog returned error: %v", err)
+		}
+	}
+
+	stats, err := backfillHashes(ctx, repo, images, 2)
+	if err != nil {
+		t.Fatalf("backfillHashes returned error: %v", err)
+	}
+	if stats != (backfillStats{Backfilled: 1, Missing: 1, Mismatched: 1}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	want := sha256.Sum256([]byte("kept-image"))
+	kept, err := repo.FindByRequestIDAndUser(ctx, "kept", "user-1")
+	if err != nil || kept.SHA256Hash != hex.EncodeToString(want[:]) {
+		t.Fatalf("expected the SHA-256 of the stored image, got %+v (%v)", kept, err)
+	}
+
+	// Backfilled logs now match duplicates by SHA-256, the rest still by SHA-1.
+	duplicates, err := repo.FindDuplicatesByHash(ctx, "user-1", kept.SHA256Hash, "", "", 0, 10)
+	if err != nil || len(duplicates) != 1 || duplicates[0].RequestID != "kept" {
+		t.Fatalf("expected the backfilled log to match by SHA-256, got %v (%v)", duplicates, err)
+	}
+	duplicates, err = repo.FindDuplicatesByHash(ctx, "user-1", "new-sha256", sha1Hex("unstored-image"), "", 0, 10)
+	if err != nil || len(duplicates) != 1 || duplicates[0].RequestID != "unstored" {
+		t.Fatalf("expected a log without a SHA-256 to match by SHA-1, got %v (%v)", duplicates, err)
+	}
+	if duplicates, _ := repo.FindDuplicatesByHash(ctx, "user-1", "new-sha256", sha1Hex("current-image"), "", 0, 10); len(duplicates) != 0 {
+		t.Fatalf("expected logs with a different SHA-256 not to match by SHA-1, got %v", duplicates)
+	}
+
+	if stats, err := backfillHashes(ctx, repo, images, 2); err != nil || stats.Backfilled != 0 {
+		t.Fatalf("expected a rerun to have nothing left to backfill, got %+v (%v)", stats, err)
+	}
+}
+
+func TestServeWorkerMetricsFailsOnTakenAddress(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer taken.Close()
+
+	plan := newShutdownPlan(zap.NewNop(), time.Second)
+	defer plan.run() //nolint:errcheck
+	if err := serveWorkerMetrics(taken.Addr().String(), metrics.New(), plan, zap.NewNop()); err == nil {
+		t.Fatal("expected an error for an address already in use")
+	}
+
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := free.Addr().String()
+	free.Close()
+	if err := serveWorkerMetrics(addr, metrics.New(), plan, zap.NewNop()); err != nil {
+		t.Fatalf("serveWorkerMetrics returned error: %v", err)
+	}
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape metrics: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,227 @@
+package migrations
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/repository"
+)
+
+// baseline creates every table the service used before migrations were versioned,
+// or updates older schemas to them, including their index changes and backfills.
+// The tables are created from the snapshot of the models below, not from the models
+// of the repository package, so the schema of this version stays the same as those
+// models change. Change the schema in a new migration, not here.
+var baseline = Migration{
+	Version: 20261016001,
+	Name:    "baseline",
+	Up: func(ctx context.Context, tx *gorm.DB, logger *zap.Logger) error {
+		// Earlier schemas indexed the hash alone, so no two users could verify the
+		// same image, and later ones per user but not per tenant. Drop those indexes
+		// for AutoMigrate to recreate them per user and tenant.
+		if tx.Migrator().HasTable(&baselineVerificationLog{}) {
+			indexes, err := tx.Migrator().GetIndexes(&baselineVerificationLog{})
+			if err != nil {
+				return err
+			}
+			for _, index := range indexes {
+				if slices.Contains(baselineUserIndexes, index.Name()) && !slices.Contains(index.Columns(), "tenant_id") {
+					if err := tx.Migrator().DropIndex(&baselineVerificationLog{}, index.Name()); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		err := tx.AutoMigrate(
+			&baselineVerificationLog{},
+			&baselineVerificationCategory{},
+			&baselineMetricsCounter{},
+			&baselineWebhookEndpoint{},
+			&baselineWebhookDelivery{},
+			&baselineUsageRecord{},
+			&baselineBillingAccount{},
+			&baselineExportCheckpoint{},
+			&baselineUserProfile{},
+			&baselineOutboxEvent{},
+			&baselineDispute{},
+			&baselineTenantSettings{},
+		)
+		if err != nil {
+			return err
+		}
+		// Logs written before tenants were recorded have no tenant_id. They belong to
+		// no tenant, which the repository matches as ''.
+		if err := tx.Exec("UPDATE verification_logs SET tenant_id = '' WHERE tenant_id IS NULL").Error; err != nil {
+			return err
+		}
+		// Counters made before the hourly and tenant counters, or before the counters
+		// themselves, are recounted from the logs.
+		return repository.RebuildMetricsCountersIn(tx)
+	},
+}
+
+// baselineUserIndexes are the indexes of a user's verifications.
+var baselineUserIndexes = []string{"idx_verification_logs_user_hash", "idx_verification_logs_user_id", "idx_verification_logs_user_sha256"}
+
+type baselineVerificationLog struct {
+	ID                  uint                           `gorm:"primaryKey;index:idx_verification_logs_user_id,priority:3;index:idx_verification_logs_user_sha256,priority:4"`
+	RequestID           string                         `gorm:"column:request_id;uniqueIndex;size:64"`
+	UserID              string                         `gorm:"column:user_id;size:64;uniqueIndex:idx_verification_logs_user_hash,priority:1;index:idx_verification_logs_user_id,priority:1;index:idx_verification_logs_user_sha256,priority:1"`
+	TenantID            string                         `gorm:"column:tenant_id;size:64;index;uniqueIndex:idx_verification_logs_user_hash,priority:3;index:idx_verification_logs_user_id,priority:2;index:idx_verification_logs_user_sha256,priority:3"`
+	SHA1Hash            string                         `gorm:"column:sha1_hash;size:40;not null;index;uniqueIndex:idx_verification_logs_user_hash,priority:2"`
+	SHA256Hash          string                         `gorm:"column:sha256_hash;size:64;index;index:idx_verification_logs_user_sha256,priority:2"`
+	Score               float32                        `gorm:"column:score"`
+	RawScore            float32                        `gorm:"column:raw_score"`
+	ModelVersion        string                         `gorm:"column:model_version;size:64;index"`
+	Success             bool                           `gorm:"column:success"`
+	Details             string                         `gorm:"column:details;type:text"`
+	ProcessingLatencyMs float64                        `gorm:"column:processing_latency_ms"`
+	Verdict             string                         `gorm:"column:verdict;size:16"`
+	ImageKey            string                         `gorm:"column:image_key;size:512"`
+	Region              string                         `gorm:"column:region;size:32;index"`
+	Variant             string                         `gorm:"column:variant;size:64;index"`
+	PerceptualHash      string                         `gorm:"column:perceptual_hash;size:16"`
+	MalwareScan         string                         `gorm:"column:malware_scan;size:16"`
+	MalwareSignature    string                         `gorm:"column:malware_signature;size:128"`
+	ImageMetadata       []byte                         `gorm:"column:image_metadata;type:jsonb"`
+	Status              string                         `gorm:"column:status;size:16;not null;default:'completed';index"`
+	CreatedAt           time.Time                      `gorm:"column:created_at"`
+	DeletedAt           gorm.DeletedAt                 `gorm:"column:deleted_at;index"`
+	Categories          []baselineVerificationCategory `gorm:"foreignKey:VerificationLogID;constraint:OnDelete:CASCADE"`
+}
+
+func (baselineVerificationLog) TableName() string { return "verification_logs" }
+
+type baselineVerificationCategory struct {
+	ID                uint    `gorm:"primaryKey"`
+	VerificationLogID uint    `gorm:"column:verification_log_id;not null;uniqueIndex:idx_verification_categories_log_category"`
+	Category          string  `gorm:"column:category;size:64;not null;uniqueIndex:idx_verification_categories_log_category"`
+	Score             float32 `gorm:"column:score"`
+	Threshold         float32 `gorm:"column:threshold"`
+	Flagged           bool    `gorm:"column:flagged"`
+}
+
+func (baselineVerificationCategory) TableName() string { return "verification_categories" }
+
+type baselineMetricsCounter struct {
+	Scope        string  `gorm:"column:scope;primaryKey;size:128"`
+	Shard        int     `gorm:"column:shard;primaryKey;autoIncrement:false"`
+	TotalCount   int64   `gorm:"column:total_count"`
+	SuccessCount int64   `gorm:"column:success_count"`
+	ScoreSum     float64 `gorm:"column:score_sum"`
+	LatencySumMs float64 `gorm:"column:latency_sum_ms"`
+}
+
+func (baselineMetricsCounter) TableName() string { return "verification_metrics_counters" }
+
+type baselineWebhookEndpoint struct {
+	ID        string    `gorm:"primaryKey;size:36"`
+	UserID    string    `gorm:"column:user_id;size:64;index"`
+	URL       string    `gorm:"column:url;size:2048"`
+	Secret    string    `gorm:"column:secret;size:128"`
+	Events    string    `gorm:"column:events;size:512"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (baselineWebhookEndpoint) TableName() string { return "webhook_endpoints" }
+
+type baselineWebhookDelivery struct {
+	ID           string     `gorm:"primaryKey;size:36"`
+	EndpointID   string     `gorm:"column:endpoint_id;size:36;uniqueIndex:idx_webhook_deliveries_endpoint_event;index:idx_webhook_deliveries_endpoint_created"`
+	EventID      string     `gorm:"column:event_id;size:64;uniqueIndex:idx_webhook_deliveries_endpoint_event"`
+	Event        string     `gorm:"column:event;size:64"`
+	Payload      string     `gorm:"column:payload;type:text"`
+	Status       string     `gorm:"column:status;size:16"`
+	Attempts     int        `gorm:"column:attempts"`
+	ResponseCode int        `gorm:"column:response_code"`
+	ResponseBody string     `gorm:"column:response_body;type:text"`
+	LastError    string     `gorm:"column:last_error;type:text"`
+	CreatedAt    time.Time  `gorm:"column:created_at;index:idx_webhook_deliveries_endpoint_created"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at"`
+	DeliveredAt  *time.Time `gorm:"column:delivered_at"`
+}
+
+func (baselineWebhookDelivery) TableName() string { return "webhook_deliveries" }
+
+type baselineUsageRecord struct {
+	ID            uint      `gorm:"primaryKey"`
+	UserID        string    `gorm:"column:user_id;size:64;uniqueIndex:idx_usage_records_user_period"`
+	Period        string    `gorm:"column:period;size:7;uniqueIndex:idx_usage_records_user_period;index"`
+	Units         int64     `gorm:"column:units"`
+	Verifications int64     `gorm:"column:verifications"`
+	ReportedUnits int64     `gorm:"column:reported_units"`
+	UpdatedAt     time.Time `gorm:"column:updated_at"`
+}
+
+func (baselineUsageRecord) TableName() string { return "usage_records" }
+
+type baselineBillingAccount struct {
+	UserID           string    `gorm:"primaryKey;column:user_id;size:64"`
+	StripeCustomerID string    `gorm:"column:stripe_customer_id;size:255"`
+	UpdatedAt        time.Time `gorm:"column:updated_at"`
+}
+
+func (baselineBillingAccount) TableName() string { return "billing_accounts" }
+
+type baselineExportCheckpoint struct {
+	Name      string    `gorm:"primaryKey;column:name;size:128"`
+	LastID    uint      `gorm:"column:last_id"`
+	Exported  int64     `gorm:"column:exported"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+func (baselineExportCheckpoint) TableName() string { return "export_checkpoints" }
+
+type baselineUserProfile struct {
+	UserID          string     `gorm:"primaryKey;column:user_id;size:64"`
+	Tier            string     `gorm:"column:tier;size:32"`
+	MonthlyQuota    int64      `gorm:"column:monthly_quota"`
+	Suspended       bool       `gorm:"column:suspended;index"`
+	SuspendedReason string     `gorm:"column:suspended_reason;size:512"`
+	SuspendedAt     *time.Time `gorm:"column:suspended_at"`
+	CreatedAt       time.Time  `gorm:"column:created_at"`
+	UpdatedAt       time.Time  `gorm:"column:updated_at"`
+}
+
+func (baselineUserProfile) TableName() string { return "user_profiles" }
+
+type baselineOutboxEvent struct {
+	ID          string     `gorm:"primaryKey;size:64"`
+	Type        string     `gorm:"column:type;size:64"`
+	UserID      string     `gorm:"column:user_id;size:64"`
+	TenantID    string     `gorm:"column:tenant_id;size:64"`
+	Payload     string     `gorm:"column:payload;type:text"`
+	CreatedAt   time.Time  `gorm:"column:created_at"`
+	AvailableAt time.Time  `gorm:"column:available_at;index:idx_event_outbox_pending,priority:2"`
+	PublishedAt *time.Time `gorm:"column:published_at;index:idx_event_outbox_pending,priority:1"`
+	Attempts    int        `gorm:"column:attempts"`
+	LastError   string     `gorm:"column:last_error;type:text"`
+}
+
+func (baselineOutboxEvent) TableName() string { return "event_outbox" }
+
+type baselineDispute struct {
+	ID           uint       `gorm:"primaryKey"`
+	RequestID    string     `gorm:"column:request_id;size:64;uniqueIndex"`
+	UserID       string     `gorm:"column:user_id;size:64;index"`
+	Reason       string     `gorm:"column:reason;type:text"`
+	State        string     `gorm:"column:state;size:16;index"`
+	ReviewerNote string     `gorm:"column:reviewer_note;type:text"`
+	CreatedAt    time.Time  `gorm:"column:created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at"`
+	ResolvedAt   *time.Time `gorm:"column:resolved_at"`
+}
+
+func (baselineDispute) TableName() string { return "disputes" }
+
+type baselineTenantSettings struct {
+	TenantID  string    `gorm:"column:tenant_id;primaryKey;size:64"`
+	Settings  string    `gorm:"column:settings;type:text;not null"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+func (baselineTenantSettings) TableName() string { return "tenant_settings" }
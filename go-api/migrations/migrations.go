@@ -0,0 +1,188 @@
+// Package migrations applies the versioned changes that make up the database schema.
+//
+// Each migration runs once, in a transaction that also records its version in the
+// schema_migrations table, so a failed migration leaves neither its changes nor its
+// record behind. Migrations are Go functions rather than SQL files, so index
+// changes and data backfills can be written for PostgreSQL and for the SQLite
+// database of serve -dev alike.
+//
+// The baseline migration creates the schema the service had before it was
+// versioned, with gorm's AutoMigrate on a snapshot of the models of that version,
+// and so also brings older databases up to it. Changing a model of the repository
+// package takes a new migration making the same change; a test compares the
+// migrated schema with the models.
+//
+// 20240215001_add_sha1_hash.sql predates this package and was applied by hand;
+// the baseline covers it.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrSchemaOutdated reports a database lacking migrations of this binary.
+var ErrSchemaOutdated = errors.New("database schema is out of date")
+
+// lockKey names the PostgreSQL advisory lock held while a migration is applied.
+const lockKey int64 = 0x61692d636865636b // "ai-check"
+
+// Migration is one versioned change of the schema.
+type Migration struct {
+	// Version orders the migrations, as the date the migration was written followed
+	// by a sequence number, e.g. 20261016001.
+	Version int64
+	// Name describes the change in a few words, in snake case.
+	Name string
+	// Up applies the change within the transaction tx.
+	Up func(ctx context.Context, tx *gorm.DB, logger *zap.Logger) error
+}
+
+// All returns the migrations of the service, oldest first. New migrations are
+// appended to it.
+func All() []Migration {
+	return []Migration{
+		baseline,
+	}
+}
+
+// Status is the state of one migration in a database.
+type Status struct {
+	Version int64
+	Name    string
+	// AppliedAt is when the migration was applied, or zero while it is pending.
+	AppliedAt time.Time
+	// Unknown marks migrations recorded in the database that this binary does not
+	// have, such as those of a newer release.
+	Unknown bool
+}
+
+// record is the row of an applied migration.
+type record struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (record) TableName() string { return "schema_migrations" }
+
+// Migrator applies migrations to a database and reports their state.
+type Migrator struct {
+	db         *gorm.DB
+	logger     *zap.Logger
+	migrations []Migration
+}
+
+// New returns a Migrator applying All to db.
+func New(db *gorm.DB, logger *zap.Logger) *Migrator {
+	return newMigrator(db, logger, All())
+}
+
+func newMigrator(db *gorm.DB, logger *zap.Logger, migrations []Migration) *Migrator {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Migrator{db: db, logger: logger, migrations: migrations}
+}
+
+// Up applies the pending migrations in version order and returns those it applied.
+// It stops at the first that fails. Instances applying migrations at the same time
+// on PostgreSQL take turns, and each migration is applied once.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.db.WithContext(ctx).AutoMigrate(&record{}); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	var applied []Migration
+	for _, migration := range m.migrations {
+		ran, err := m.apply(ctx, migration)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		if ran {
+			m.logger.Info("applied database migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// apply runs migration unless it was recorded, and reports whether it ran.
+func (m *Migrator) apply(ctx context.Context, migration Migration) (bool, error) {
+	ran := false
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			// Instances that start together wait here, and then find the migration
+			// recorded by the first.
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+				return err
+			}
+		}
+		var count int64
+		if err := tx.Model(&record{}).Where("version = ?", migration.Version).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if err := migration.Up(ctx, tx, m.logger); err != nil {
+			return err
+		}
+		ran = true
+		return tx.Create(&record{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+	})
+	return ran, err
+}
+
+// Status returns the state of every migration, in version order, followed by the
+// unknown migrations recorded in the database.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	db := m.db.WithContext(ctx)
+	var records []record
+	if db.Migrator().HasTable(&record{}) {
+		if err := db.Order("version").Find(&records).Error; err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %w", err)
+		}
+	}
+	applied := make(map[int64]time.Time, len(records))
+	for _, r := range records {
+		applied[r.Version] = r.AppliedAt
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	known := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		statuses = append(statuses, Status{Version: migration.Version, Name: migration.Name, AppliedAt: applied[migration.Version]})
+	}
+	for _, r := range records {
+		if !known[r.Version] {
+			statuses = append(statuses, Status{Version: r.Version, Name: r.Name, AppliedAt: r.AppliedAt, Unknown: true})
+		}
+	}
+	return statuses, nil
+}
+
+// Check returns an error wrapping ErrSchemaOutdated when migrations are pending.
+// Unknown migrations are accepted, so an older release keeps running against a
+// schema migrated by a newer one.
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	var pending []Status
+	for _, status := range statuses {
+		if !status.Unknown && status.AppliedAt.IsZero() {
+			pending = append(pending, status)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d pending migrations, starting with %d_%s; run 'ai-check migrate'",
+			ErrSchemaOutdated, len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
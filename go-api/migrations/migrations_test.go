@@ -0,0 +1,185 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/devmode"
+	"github.com/example/ai-check/internal/repository"
+)
+
+func openDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := devmode.OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase returned error: %v", err)
+	}
+	return db
+}
+
+func TestMigrationsAreOrderedAndUnique(t *testing.T) {
+	var previous int64
+	for _, migration := range All() {
+		if migration.Version <= previous {
+			t.Fatalf("migration %d_%s does not follow %d", migration.Version, migration.Name, previous)
+		}
+		if migration.Name == "" || migration.Up == nil {
+			t.Fatalf("migration %d lacks a name or an Up function", migration.Version)
+		}
+		previous = migration.Version
+	}
+}
+
+func TestUpAppliesPendingMigrationsOnce(t *testing.T) {
+	ctx := context.Background()
+	db := openDatabase(t)
+	migrator := New(db, zap.NewNop())
+
+	if err := migrator.Check(ctx); !errors.Is(err, ErrSchemaOutdated) {
+		t.Fatalf("expected ErrSchemaOutdated before migrating, got %v", err)
+	}
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up returned error: %v", err)
+	}
+	if len(applied) != len(All()) {
+		t.Fatalf("expected every migration to be applied, got %d", len(applied))
+	}
+	if !db.Migrator().HasTable(&repository.VerificationLog{}) || !db.Migrator().HasTable(&repository.TenantSettings{}) {
+		t.Fatal("expected the baseline to create the tables")
+	}
+	if err := migrator.Check(ctx); err != nil {
+		t.Fatalf("Check returned error after migrating: %v", err)
+	}
+
+	applied, err = migrator.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("expected a second Up to apply nothing, got %d, %v", len(applied), err)
+	}
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	ctx := context.Background()
+	db := openDatabase(t)
+	type widget struct {
+		ID uint
+	}
+	migrator := newMigrator(db, zap.NewNop(), []Migration{
+		{Version: 1, Name: "widgets", Up: func(ctx context.Context, tx *gorm.DB, logger *zap.Logger) error {
+			return tx.AutoMigrate(&widget{})
+		}},
+		{Version: 2, Name: "broken", Up: func(ctx context.Context, tx *gorm.DB, logger *zap.Logger) error {
+			if err := tx.Exec("CREATE TABLE gadgets (id INTEGER)").Error; err != nil {
+				return err
+			}
+			return errors.New("backfill failed")
+		}},
+	})
+
+	applied, err := migrator.Up(ctx)
+	if err == nil || len(applied) != 1 {
+		t.Fatalf("expected the second migration to fail after the first, got %d, %v", len(applied), err)
+	}
+	if db.Migrator().HasTable("gadgets") {
+		t.Fatal("expected the changes of the failed migration to be rolled back")
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status returned error: %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].AppliedAt.IsZero() || !statuses[1].AppliedAt.IsZero() {
+		t.Fatalf("expected the first migration applied and the second pending, got %+v", statuses)
+	}
+	if err := migrator.Check(ctx); !errors.Is(err, ErrSchemaOutdated) {
+		t.Fatalf("expected ErrSchemaOutdated, got %v", err)
+	}
+}
+
+func TestCheckAcceptsMigrationsOfNewerReleases(t *testing.T) {
+	ctx := context.Background()
+	db := openDatabase(t)
+	if _, err := New(db, zap.NewNop()).Up(ctx); err != nil {
+		t.Fatalf("Up returned error: %v", err)
+	}
+	newer := record{Version: 99991231001, Name: "from_the_future", AppliedAt: time.Now().UTC()}
+	if err := db.Create(&newer).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	migrator := New(db, zap.NewNop())
+	if err := migrator.Check(ctx); err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status returned error: %v", err)
+	}
+	last := statuses[len(statuses)-1]
+	if !last.Unknown || last.Version != newer.Version || last.Name != newer.Name {
+		t.Fatalf("expected the newer migration listed as unknown, got %+v", last)
+	}
+}
+
+// TestMigrationsMatchTheModels fails when a model of the repository package changed
+// without a migration making the change. SQLite keeps no column sizes, so changing
+// only a size goes unnoticed.
+func TestMigrationsMatchTheModels(t *testing.T) {
+	ctx := context.Background()
+	migrated := openDatabase(t)
+	if _, err := New(migrated, zap.NewNop()).Up(ctx); err != nil {
+		t.Fatalf("Up returned error: %v", err)
+	}
+
+	modeled := openDatabase(t)
+	logger := zap.NewNop()
+	for _, migrate := range []func(context.Context) error{
+		repository.NewVerificationRepository(modeled, logger).AutoMigrate,
+		repository.NewWebhookRepository(modeled, logger).AutoMigrate,
+		repository.NewUsageRepository(modeled, logger).AutoMigrate,
+		repository.NewExportRepository(modeled, logger).AutoMigrate,
+		repository.NewUserRepository(modeled, logger).AutoMigrate,
+		repository.NewOutboxRepository(modeled, logger).AutoMigrate,
+		repository.NewDisputeRepository(modeled, logger).AutoMigrate,
+		repository.NewTenantRepository(modeled, logger).AutoMigrate,
+	} {
+		if err := migrate(ctx); err != nil {
+			t.Fatalf("AutoMigrate returned error: %v", err)
+		}
+	}
+
+	got, want := schemaOf(t, migrated), schemaOf(t, modeled)
+	for name, sql := range want {
+		if got[name] != sql {
+			t.Errorf("%s differs from the models:\nmigrated: %s\nmodeled:  %s", name, got[name], sql)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("%s is not in the models", name)
+		}
+	}
+}
+
+// schemaOf returns the statements creating the tables and indexes of db by name,
+// leaving out schema_migrations.
+func schemaOf(t *testing.T, db *gorm.DB) map[string]string {
+	t.Helper()
+	var rows []struct {
+		Name string
+		SQL  string
+	}
+	err := db.Raw("SELECT name, sql FROM sqlite_master WHERE type IN ('table', 'index') AND sql IS NOT NULL AND tbl_name <> ?", record{}.TableName()).Scan(&rows).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := make(map[string]string, len(rows))
+	for _, row := range rows {
+		schema[row.Name] = row.SQL
+	}
+	return schema
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/handlers"
+)
+
+//go:generate go run . openapi -o openapi.json
+
+// runOpenAPI prints the OpenAPI document of the HTTP API, the one served at
+// GET /openapi.json. openapi.json in this directory is generated with it.
+func runOpenAPI(args []string, _ *zap.Logger) error {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	output := fs.String("o", "", "file to write the document to (defaults to standard output)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &usageError{err: err}
+	}
+
+	doc, err := handlers.OpenAPI().JSON()
+	if err != nil {
+		return fmt.Errorf("encode OpenAPI document: %w", err)
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(doc)
+		return err
+	}
+	return os.WriteFile(*output, doc, 0o644)
+}
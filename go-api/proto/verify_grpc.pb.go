@@ -0,0 +1,181 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.1
+// source: proto/verify.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	ImageProcessor_ProcessImage_FullMethodName       = "/verify.ImageProcessor/ProcessImage"
+	ImageProcessor_ProcessImageStream_FullMethodName = "/verify.ImageProcessor/ProcessImageStream"
+)
+
+// ImageProcessorClient is the client API for ImageProcessor service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ImageProcessorClient interface {
+	ProcessImage(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error)
+	ProcessImageStream(ctx context.Context, opts ...grpc.CallOption) (ImageProcessor_ProcessImageStreamClient, error)
+}
+
+type imageProcessorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewImageProcessorClient(cc grpc.ClientConnInterface) ImageProcessorClient {
+	return &imageProcessorClient{cc}
+}
+
+func (c *imageProcessorClient) ProcessImage(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error) {
+	out := new(VerifyResponse)
+	err := c.cc.Invoke(ctx, ImageProcessor_ProcessImage_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageProcessorClient) ProcessImageStream(ctx context.Context, opts ...grpc.CallOption) (ImageProcessor_ProcessImageStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ImageProcessor_ServiceDesc.Streams[0], ImageProcessor_ProcessImageStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &imageProcessorProcessImageStreamClient{stream}
+	return x, nil
+}
+
+type ImageProcessor_ProcessImageStreamClient interface {
+	Send(*VerifyChunk) error
+	CloseAndRecv() (*VerifyResponse, error)
+	grpc.ClientStream
+}
+
+type imageProcessorProcessImageStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *imageProcessorProcessImageStreamClient) Send(m *VerifyChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *imageProcessorProcessImageStreamClient) CloseAndRecv() (*VerifyResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(VerifyResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ImageProcessorServer is the server API for ImageProcessor service.
+// All implementations must embed UnimplementedImageProcessorServer
+// for forward compatibility
+type ImageProcessorServer interface {
+	ProcessImage(context.Context, *VerifyRequest) (*VerifyResponse, error)
+	ProcessImageStream(ImageProcessor_ProcessImageStreamServer) error
+	mustEmbedUnimplementedImageProcessorServer()
+}
+
+// UnimplementedImageProcessorServer must be embedded to have forward compatible implementations.
+type UnimplementedImageProcessorServer struct {
+}
+
+func (UnimplementedImageProcessorServer) ProcessImage(context.Context, *VerifyRequest) (*VerifyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ProcessImage not implemented")
+}
+func (UnimplementedImageProcessorServer) ProcessImageStream(ImageProcessor_ProcessImageStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ProcessImageStream not implemented")
+}
+func (UnimplementedImageProcessorServer) mustEmbedUnimplementedImageProcessorServer() {}
+
+// UnsafeImageProcessorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ImageProcessorServer will
+// result in compilation errors.
+type UnsafeImageProcessorServer interface {
+	mustEmbedUnimplementedImageProcessorServer()
+}
+
+func RegisterImageProcessorServer(s grpc.ServiceRegistrar, srv ImageProcessorServer) {
+	s.RegisterService(&ImageProcessor_ServiceDesc, srv)
+}
+
+func _ImageProcessor_ProcessImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageProcessorServer).ProcessImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ImageProcessor_ProcessImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageProcessorServer).ProcessImage(ctx, req.(*VerifyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ImageProcessor_ProcessImageStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ImageProcessorServer).ProcessImageStream(&imageProcessorProcessImageStreamServer{stream})
+}
+
+type ImageProcessor_ProcessImageStreamServer interface {
+	SendAndClose(*VerifyResponse) error
+	Recv() (*VerifyChunk, error)
+	grpc.ServerStream
+}
+
+type imageProcessorProcessImageStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *imageProcessorProcessImageStreamServer) SendAndClose(m *VerifyResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *imageProcessorProcessImageStreamServer) Recv() (*VerifyChunk, error) {
+	m := new(VerifyChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ImageProcessor_ServiceDesc is the grpc.ServiceDesc for ImageProcessor service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ImageProcessor_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "verify.ImageProcessor",
+	HandlerType: (*ImageProcessorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ProcessImage",
+			Handler:    _ImageProcessor_ProcessImage_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ProcessImageStream",
+			Handler:       _ImageProcessor_ProcessImageStream_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/verify.proto",
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/example/ai-check/internal/config"
+	"github.com/example/ai-check/internal/middleware"
+	"github.com/example/ai-check/internal/ratelimit"
+)
+
+// logLevel backs every logger built by main so the level can follow configuration reloads.
+var logLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+
+func applyLogLevel(cfg config.LogConfig) {
+	if level, err := zapcore.ParseLevel(cfg.Level); err == nil {
+		logLevel.SetLevel(level)
+	}
+}
+
+// reloadLimits applies the rate limits and the concurrency limits of cfg.
+func reloadLimits(cfg config.LimitsConfig, rate *ratelimit.Limiter, concurrency *middleware.ConcurrencyLimiter) {
+	rate.SetGroups(rateLimitGroups(cfg.Rate)...)
+	concurrency.SetLimits(cfg.MaxInFlight, cfg.Routes)
+}
+
+// configReloader re-reads the configuration on SIGHUP or when the file changes and
+// hands valid results to apply. Invalid configurations are logged and ignored so a
+// bad edit never takes down a running instance.
+type configReloader struct {
+	path    string
+	logger  *zap.Logger
+	current *config.Config
+	apply   func(*config.Config)
+	// prepare, when set, adjusts every freshly loaded configuration before it is
+	// compared and applied, e.g. to overlay values from a secrets manager.
+	prepare  func(*config.Config)
+	requests chan struct{}
+	modTime  time.Time
+}
+
+func newConfigReloader(path string, current *config.Config, logger *zap.Logger, apply func(*config.Config)) *configReloader {
+	r := &configReloader{
+		path:     path,
+		logger:   logger.Named("config_reloader"),
+		current:  current,
+		apply:    apply,
+		requests: make(chan struct{}, 1),
+	}
+	r.modTime = r.fileModTime()
+	return r
+}
+
+// run blocks until ctx is cancelled, reloading on SIGHUP and, when watchInterval is
+// positive, whenever the file's modification time changes.
+func (r *configReloader) run(ctx context.Context, watchInterval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if watchInterval > 0 && r.path != "" {
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.logger.Info("received SIGHUP, reloading configuration")
+			r.reload()
+		case <-r.requests:
+			r.reload()
+		case <-tick:
+			if modTime := r.fileModTime(); !modTime.Equal(r.modTime) {
+				r.modTime = modTime
+				r.logger.Info("configuration file changed, reloading", zap.String("path", r.path))
+				r.reload()
+			}
+		}
+	}
+}
+
+// requestReload asks run to reload the configuration; requests made while one is
+// already pending are coalesced.
+func (r *configReloader) requestReload() {
+	select {
+	case r.requests <- struct{}{}:
+	default:
+	}
+}
+
+// reload loads and applies the configuration, returning whether it was accepted.
+func (r *configReloader) reload() bool {
+	next, err := config.Load(r.path)
+	if err != nil {
+		r.logger.Error("configuration reload rejected", zap.Error(err))
+		return false
+	}
+	if r.prepare != nil {
+		r.prepare(next)
+		if err := next.Validate(); err != nil {
+			r.logger.Error("configuration reload rejected", zap.Error(err))
+			return false
+		}
+	}
+
+	for _, section := range restartOnlySections(r.current, next) {
+		r.logger.Warn("configuration change requires a restart to take effect", zap.String("section", section))
+	}
+
+	r.apply(next)
+	r.current = next
+	r.logger.Info("configuration reloaded")
+	return true
+}
+
+func (r *configReloader) fileModTime() time.Time {
+	if r.path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// restartOnlySections lists configuration sections that changed but are only read at startup.
+func restartOnlySections(current, next *config.Config) []string {
+	var sections []string
+	if !reflect.DeepEqual(current.HTTP, next.HTTP) {
+		sections = append(sections, "http")
+	}
+	if !reflect.DeepEqual(current.Database, next.Database) {
+		sections = append(sections, "database")
+	}
+	// Rate and concurrency limits are reloaded; the adaptive limit and the
+	// Retry-After of shed requests are not.
+	if !reflect.DeepEqual(current.Limits.Adaptive, next.Limits.Adaptive) {
+		sections = append(sections, "limits.adaptive")
+	}
+	if current.Limits.RetryAfter != next.Limits.RetryAfter {
+		sections = append(sections, "limits.retry_after")
+	}
+	if !reflect.DeepEqual(current.Experiment, next.Experiment) {
+		sections = append(sections, "experiment")
+	}
+	if current.Region != next.Region {
+		sections = append(sections, "region")
+	}
+	if !reflect.DeepEqual(current.Redis, next.Redis) {
+		sections = append(sections, "redis")
+	}
+	if current.Processor != next.Processor {
+		sections = append(sections, "processor")
+	}
+	if current.Tenants != next.Tenants {
+		sections = append(sections, "tenants")
+	}
+	if current.MalwareScan != next.MalwareScan {
+		sections = append(sections, "malware_scan")
+	}
+	// Token clients are reloaded; turning the issuer on or off and its TTLs are not.
+	currentTokens, nextTokens := current.Auth.Tokens, next.Auth.Tokens
+	if currentTokens.Enabled != nextTokens.Enabled || currentTokens.AccessTTL != nextTokens.AccessTTL || currentTokens.RefreshTTL != nextTokens.RefreshTTL {
+		sections = append(sections, "auth.tokens")
+	}
+	return sections
+}
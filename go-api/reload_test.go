@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/config"
+	"github.com/example/ai-check/internal/middleware"
+)
+
+func TestConfigReloaderAppliesValidChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "auth:\n  jwt_secret: first-secret-0123456789abcdefghijklm\n")
+
+	current, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load initial config: %v", err)
+	}
+
+	var applied *config.Config
+	reloader := newConfigReloader(path, current, zap.NewNop(), func(next *config.Config) {
+		applied = next
+	})
+
+	writeConfig(t, path, "auth:\n  jwt_secret: second-secret-0123456789abcdefghijkl\n  jwt_previous_secrets: [first-secret-0123456789abcdefghijklm]\nlog:\n  level: debug\n")
+	if !reloader.reload() {
+		t.Fatal("expected reload to succeed")
+	}
+	if applied == nil || applied.Auth.JWTSecret != "second-secret-0123456789abcdefghijkl" {
+		t.Fatalf("expected rotated secret to be applied, got %+v", applied)
+	}
+	if reloader.current != applied {
+		t.Fatal("expected reloader to track the applied configuration")
+	}
+}
+
+func TestConfigReloaderRejectsInvalidChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "log:\n  level: info\n")
+
+	current, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load initial config: %v", err)
+	}
+
+	applied := false
+	reloader := newConfigReloader(path, current, zap.NewNop(), func(*config.Config) {
+		applied = true
+	})
+
+	writeConfig(t, path, "log:\n  level: loud\n")
+	if reloader.reload() {
+		t.Fatal("expected invalid configuration to be rejected")
+	}
+	if applied {
+		t.Fatal("expected invalid configuration not to be applied")
+	}
+	if reloader.current != current {
+		t.Fatal("expected previous configuration to remain active")
+	}
+}
+
+func TestConfigReloaderAppliesRateLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	rateConfig := func(requests int) string {
+		return fmt.Sprintf("limits:\n  rate:\n    enabled: true\n    groups:\n      verify:\n        routes: [\"POST /verify\"]\n        per_user:\n          requests: %d\n          per: 1m\n", requests)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, rateConfig(1))
+	current, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load initial config: %v", err)
+	}
+
+	limiter := newRateLimiter(current.Limits.Rate, client, zap.NewNop())
+	concurrency := middleware.NewConcurrencyLimiter(current.Limits.MaxInFlight, current.Limits.Routes, current.Limits.RetryAfter)
+	reloader := newConfigReloader(path, current, zap.NewNop(), func(next *config.Config) {
+		reloadLimits(next.Limits, limiter, concurrency)
+	})
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(auth.WithUserID(c.Request.Context(), c.GetHeader("X-User")))
+	}, limiter.Middleware())
+	router.POST("/verify", func(c *gin.Context) { c.Status(http.StatusOK) })
+	verify := func(userID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/verify", nil)
+		req.Header.Set("X-User", userID)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	if first, second := verify("user-1"), verify("user-1"); first != http.StatusOK || second != http.StatusTooManyRequests {
+		t.Fatalf("expected one request a minute, got %d then %d", first, second)
+	}
+	writeConfig(t, path, rateConfig(3))
+	if !reloader.reload() {
+		t.Fatal("expected reload to succeed")
+	}
+	for i := 0; i < 3; i++ {
+		if code := verify("user-2"); code != http.StatusOK {
+			t.Fatalf("expected request %d to pass under the reloaded limit, got %d", i+1, code)
+		}
+	}
+	if code := verify("user-2"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the fourth request to be limited, got %d", code)
+	}
+}
+
+func writeConfig(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/config"
+	"github.com/example/ai-check/internal/secrets"
+)
+
+// secretStore holds the values last read from the configured secrets manager. Startup
+// values from the file and environment stay in effect for keys the secret lacks.
+type secretStore struct {
+	provider secrets.Provider
+	timeout  time.Duration
+	logger   *zap.Logger
+
+	mu       sync.RWMutex
+	values   secrets.Values
+	fallback config.Config
+}
+
+// loadSecrets reads the configured secret, if any, overlays it on cfg and re-validates
+// the result. It returns nil when no secrets provider is configured.
+func loadSecrets(cfg *config.Config, logger *zap.Logger) (*secretStore, error) {
+	if cfg.Secrets.Provider == "" {
+		return nil, nil
+	}
+	provider, err := newSecretsProvider(cfg.Secrets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	store := &secretStore{
+		provider: provider,
+		timeout:  cfg.Secrets.Timeout,
+		logger:   logger.Named("secrets"),
+		fallback: *cfg,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Secrets.Timeout)
+	defer cancel()
+	if _, err := store.refresh(ctx); err != nil {
+		return nil, err
+	}
+	store.apply(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration after applying %s secrets: %w", cfg.Secrets.Provider, err)
+	}
+	store.logger.Info("loaded secrets", zap.String("provider", cfg.Secrets.Provider), zap.Strings("keys", store.keys()))
+	return store, nil
+}
+
+func newSecretsProvider(cfg config.SecretsConfig) (secrets.Provider, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+	switch cfg.Provider {
+	case "vault":
+		return secrets.NewVault(secrets.VaultOptions{
+			Addr:      cfg.Vault.Addr,
+			Token:     cfg.Vault.Token,
+			TokenFile: cfg.Vault.TokenFile,
+			Namespace: cfg.Vault.Namespace,
+			Mount:     cfg.Vault.Mount,
+			Path:      cfg.Vault.Path,
+			Client:    client,
+		})
+	case "aws":
+		return secrets.NewAWSSecretsManager(secrets.AWSOptions{
+			Region:          cfg.AWS.Region,
+			SecretID:        cfg.AWS.SecretID,
+			Endpoint:        cfg.AWS.Endpoint,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			Client:          client,
+		})
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", cfg.Provider)
+	}
+}
+
+// refresh renews the provider's own credentials when it supports that, re-reads the
+// secret and reports whether any value changed.
+func (s *secretStore) refresh(ctx context.Context) (bool, error) {
+	if renewer, ok := s.provider.(secrets.Renewer); ok {
+		if err := renewer.Renew(ctx); err != nil {
+			s.logger.Warn("failed to renew secrets provider credentials", zap.Error(err))
+		}
+	}
+	values, err := s.provider.Fetch(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to load secrets: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := !values.Equal(s.values)
+	s.values = values
+	return changed, nil
+}
+
+// run re-reads the secret every interval until ctx is cancelled and calls onChange
+// after a refresh that changed any value. Failed refreshes keep the previous values.
+func (s *secretStore) run(ctx context.Context, interval time.Duration, onChange func()) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		refreshCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		changed, err := s.refresh(refreshCtx)
+		cancel()
+		if err != nil {
+			s.logger.Error("secret refresh failed, keeping previous values", zap.Error(err))
+			continue
+		}
+		if changed {
+			s.logger.Info("secrets rotated", zap.Strings("keys", s.keys()))
+			onChange()
+		}
+	}
+}
+
+// apply overlays the current secret values on cfg. It is a no-op on a nil store.
+func (s *secretStore) apply(cfg *config.Config) {
+	if s == nil {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if value := s.values[secrets.KeyJWTSecret]; value != "" {
+		cfg.Auth.JWTSecret = value
+	}
+	if previous := s.values.List(secrets.KeyJWTPreviousSecrets); len(previous) > 0 {
+		cfg.Auth.JWTPreviousSecrets = previous
+	}
+	if value := s.values[secrets.KeyDatabasePassword]; value != "" {
+		cfg.Database.Password = value
+	}
+	if value := s.values[secrets.KeyRedisUsername]; value != "" {
+		cfg.Redis.Username = value
+	}
+	if value := s.values[secrets.KeyRedisPassword]; value != "" {
+		cfg.Redis.Password = value
+	}
+}
+
+// databasePassword returns the password for the next Postgres connection.
+func (s *secretStore) databasePassword() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if value := s.values[secrets.KeyDatabasePassword]; value != "" {
+		return value
+	}
+	return s.fallback.Database.Password
+}
+
+// redisCredentials returns the username and password for the next Redis connection.
+func (s *secretStore) redisCredentials() (string, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	username, password := s.fallback.Redis.Username, s.fallback.Redis.Password
+	if value := s.values[secrets.KeyRedisUsername]; value != "" {
+		username = value
+	}
+	if value := s.values[secrets.KeyRedisPassword]; value != "" {
+		password = value
+	}
+	return username, password
+}
+
+// connectCredentials returns per-connection credential lookups for Postgres and
+// Redis, or nils when the static configuration should be used.
+func (s *secretStore) connectCredentials() (func() string, func() (string, string)) {
+	if s == nil {
+		return nil, nil
+	}
+	return s.databasePassword, s.redisCredentials
+}
+
+func (s *secretStore) keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []string
+	for _, key := range []string{secrets.KeyJWTSecret, secrets.KeyJWTPreviousSecrets, secrets.KeyDatabasePassword, secrets.KeyRedisUsername, secrets.KeyRedisPassword} {
+		if s.values[key] != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
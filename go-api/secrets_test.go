@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/config"
+	"github.com/example/ai-check/internal/secrets"
+)
+
+type stubSecretsProvider struct {
+	mu     sync.Mutex
+	values secrets.Values
+}
+
+func (p *stubSecretsProvider) Fetch(context.Context) (secrets.Values, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	values := make(secrets.Values, len(p.values))
+	for key, value := range p.values {
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (p *stubSecretsProvider) set(key, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.values[key] = value
+}
+
+func TestLoadSecretsOverlaysVaultValues(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/ai-check" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"data":{"jwt_secret":"vault-secret-0123456789abcdefghijklmn","database_password":"pg-pass"}}}`)
+	}))
+	defer vault.Close()
+
+	cfg := config.Default()
+	cfg.Redis.Password = "from-env"
+	cfg.Secrets.Provider = "vault"
+	cfg.Secrets.Vault.Addr = vault.URL
+	cfg.Secrets.Vault.Token = "root"
+	cfg.Secrets.Vault.Path = "ai-check"
+
+	store, err := loadSecrets(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("loadSecrets returned error: %v", err)
+	}
+	if cfg.Auth.JWTSecret != "vault-secret-0123456789abcdefghijklmn" || cfg.Database.Password != "pg-pass" {
+		t.Fatalf("expected secrets to be applied, got auth=%+v database password %q", cfg.Auth, cfg.Database.Password)
+	}
+	if _, password := store.redisCredentials(); password != "from-env" {
+		t.Fatalf("expected the configured redis password to remain, got %q", password)
+	}
+}
+
+func TestLoadSecretsRejectsInvalidSecretValues(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"data":{"jwt_secret":"short"}}}`)
+	}))
+	defer vault.Close()
+
+	cfg := config.Default()
+	cfg.Secrets.Provider = "vault"
+	cfg.Secrets.Vault.Addr = vault.URL
+	cfg.Secrets.Vault.Token = "root"
+	cfg.Secrets.Vault.Path = "ai-check"
+
+	if _, err := loadSecrets(cfg, zap.NewNop()); err == nil {
+		t.Fatal("expected a too-short JWT secret from vault to be rejected")
+	}
+}
+
+func TestSecretRotationReloadsConfiguration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "log:\n  level: info\n")
+	current, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load initial config: %v", err)
+	}
+
+	provider := &stubSecretsProvider{values: secrets.Values{
+		secrets.KeyJWTSecret:        "first-secret-0123456789abcdefghijklm",
+		secrets.KeyDatabasePassword: "first-password",
+	}}
+	store := &secretStore{provider: provider, timeout: time.Second, logger: zap.NewNop(), fallback: *current}
+	if _, err := store.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh returned error: %v", err)
+	}
+	store.apply(current)
+
+	applied := make(chan *config.Config, 1)
+	reloader := newConfigReloader(path, current, zap.NewNop(), func(next *config.Config) { applied <- next })
+	reloader.prepare = store.apply
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.run(ctx, 0)
+	go store.run(ctx, 10*time.Millisecond, reloader.requestReload)
+
+	provider.set(secrets.KeyJWTSecret, "second-secret-0123456789abcdefghijkl")
+	provider.set(secrets.KeyDatabasePassword, "second-password")
+	select {
+	case next := <-applied:
+		if next.Auth.JWTSecret != "second-secret-0123456789abcdefghijkl" {
+			t.Fatalf("expected the rotated JWT secret to be applied, got %q", next.Auth.JWTSecret)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the rotated secret to be applied")
+	}
+	if got := store.databasePassword(); got != "second-password" {
+		t.Fatalf("expected new connections to use the rotated password, got %q", got)
+	}
+}
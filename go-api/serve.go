@@ -0,0 +1,601 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/example/ai-check/internal/adaptive"
+	"github.com/example/ai-check/internal/auth"
+	"github.com/example/ai-check/internal/buildinfo"
+	"github.com/example/ai-check/internal/calibration"
+	"github.com/example/ai-check/internal/chaos"
+	"github.com/example/ai-check/internal/config"
+	"github.com/example/ai-check/internal/dbpool"
+	"github.com/example/ai-check/internal/deferred"
+	"github.com/example/ai-check/internal/disputes"
+	"github.com/example/ai-check/internal/handlers"
+	"github.com/example/ai-check/internal/imagefetch"
+	"github.com/example/ai-check/internal/imagelimits"
+	"github.com/example/ai-check/internal/imageprocessor"
+	"github.com/example/ai-check/internal/lifecycle"
+	"github.com/example/ai-check/internal/livemetrics"
+	"github.com/example/ai-check/internal/logbuffer"
+	"github.com/example/ai-check/internal/metrics"
+	"github.com/example/ai-check/internal/middleware"
+	"github.com/example/ai-check/internal/outbox"
+	"github.com/example/ai-check/internal/preprocess"
+	"github.com/example/ai-check/internal/repository"
+	"github.com/example/ai-check/internal/resultstream"
+	"github.com/example/ai-check/internal/storage"
+	"github.com/example/ai-check/internal/usecase"
+	"github.com/example/ai-check/internal/users"
+	"github.com/example/ai-check/internal/worker"
+	"github.com/example/ai-check/migrations"
+)
+
+// runServe starts the public HTTP API and blocks until it is shut down.
+func runServe(args []string, logger *zap.Logger) error {
+	fs, configPath := newFlagSet("serve")
+	dev := fs.Bool("dev", false, "run with embedded SQLite, in-process Redis and a stub image processor")
+	devDB := fs.String("dev-db", "ai-check-dev.db", "SQLite database file used with -dev (\":memory:\" for a throwaway database)")
+	chaosRules := fs.String("chaos", "", "inject faults with -dev, as comma-separated target:kind:rate[:delay] rules (see the Readme)")
+	cfg, err := parseFlags(fs, configPath, args)
+	if err != nil {
+		return err
+	}
+	var injector *chaos.Injector
+	if *chaosRules != "" {
+		if !*dev {
+			return errors.New("-chaos is only available with -dev")
+		}
+		rules, err := chaos.ParseRules(*chaosRules)
+		if err != nil {
+			return err
+		}
+		if injector, err = chaos.New(rules...); err != nil {
+			return err
+		}
+	}
+	logger = regionLogger(logger, cfg.Region.Name)
+	store, err := loadSecrets(cfg, logger)
+	if err != nil {
+		return err
+	}
+	build := buildinfo.Get()
+	logger.Info("starting ai-check",
+		zap.String("version", build.Version),
+		zap.String("commit", build.Commit),
+		zap.String("build_time", build.BuildTime),
+		zap.String("go_version", build.GoVersion),
+	)
+	for _, warning := range cfg.Warnings() {
+		logger.Warn(warning)
+	}
+
+	// Startup retries are abandoned on SIGINT/SIGTERM rather than waiting out the backoff.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Dependencies are closed in reverse registration order once the HTTP server has
+	// drained: the processor first, then Redis, then the database pool.
+	plan := newShutdownPlan(logger, cfg.Shutdown.StageTimeout)
+	defer plan.run() //nolint:errcheck
+
+	var promMetrics *metrics.Metrics
+	if cfg.HTTP.Metrics {
+		promMetrics = metrics.New()
+	}
+	tracer := newTracer(cfg.Tracing, logger)
+	if tracer != nil {
+		// Registered first, the exporter stops last, after the spans of the shutdown.
+		plan.add("tracing", tracer.Shutdown)
+		logger.Info("exporting traces", zap.String("endpoint", cfg.Tracing.Endpoint), zap.Float64("sample_ratio", cfg.Tracing.SampleRatio))
+	}
+	var deps *dependencies
+	if *dev {
+		deps, err = startDevDependencies(plan, *devDB, cfg.Auth, logger)
+	} else {
+		deps, err = connectDependencies(ctx, cfg, store, promMetrics, tracer, plan, logger)
+	}
+	if err != nil {
+		return err
+	}
+	if tracer != nil {
+		if err := deps.db.Use(tracer.GormPlugin()); err != nil {
+			return fmt.Errorf("failed to trace the database: %w", err)
+		}
+		deps.redis.AddHook(tracer.RedisHook())
+	}
+
+	// Background components are cancelled together on shutdown, after the listeners
+	// have stopped and before the dependencies they use are closed.
+	background := lifecycle.New(logger.Named("lifecycle"), cfg.Shutdown.StageTimeout)
+
+	// The development SQLite database keeps its single connection.
+	var poolTuner *dbpool.Tuner
+	if !*dev {
+		poolTuner, err = newPoolTuner(deps.db, cfg.Database, logger)
+		if err != nil {
+			return fmt.Errorf("failed to configure database pool: %w", err)
+		}
+		background.Go("db-pool-tuner", poolTuner.Run)
+	}
+
+	repo := newRepository(deps.db, cfg.Database, logger)
+	if cfg.Region.ReplicaDSN != "" && !*dev {
+		replica, err := openReplica(cfg.Database, cfg.Region)
+		if err != nil {
+			return err
+		}
+		plan.addCloser("postgres-replica", func() error {
+			sqlDB, err := replica.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.Close()
+		})
+		if tracer != nil {
+			if err := replica.Use(tracer.GormPlugin()); err != nil {
+				return fmt.Errorf("failed to trace the read replica: %w", err)
+			}
+		}
+		repo.SetReadReplica(replica, cfg.Region.FailoverCooldown)
+	}
+	if cfg.Database.ReaderDSN != "" && !*dev {
+		dbPassword, _ := store.connectCredentials()
+		reader, err := openReader(cfg.Database, dbPassword)
+		if err != nil {
+			return err
+		}
+		plan.addCloser("postgres-reader", func() error {
+			sqlDB, err := reader.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.Close()
+		})
+		if tracer != nil {
+			if err := reader.Use(tracer.GormPlugin()); err != nil {
+				return fmt.Errorf("failed to trace the database reader: %w", err)
+			}
+		}
+		repo.SetReader(reader)
+	}
+	if err := prepareSchema(ctx, deps.db, cfg.Database.MigrateOnStart || *dev, logger); err != nil {
+		if !cfg.Startup.Degraded || errors.Is(err, migrations.ErrSchemaOutdated) {
+			return err
+		}
+		logger.Error("database schema check failed, continuing degraded; run 'ai-check migrate' once the database is reachable", zap.Error(err))
+	}
+
+	// Faults are injected once the schema is migrated, so startup does not fail.
+	if injector != nil {
+		if err := injector.Database(deps.db); err != nil {
+			return fmt.Errorf("failed to inject database faults: %w", err)
+		}
+		deps.processor = injector.Processor(deps.processor)
+		logger.Warn("injecting faults into dependencies", zap.String("rules", *chaosRules))
+	}
+
+	queue := worker.NewQueue(deps.redis)
+	tenantStore := newTenantStore(deps.db, deps.redis, cfg.Tenants, logger)
+	hooks := newWebhookService(deps.db, queue, cfg.Webhooks, logger)
+	meter := newMeter(deps.db, cfg.Metering, logger)
+	relay, err := newEventRelay(queue, deps.redis, cfg.Events, logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure event streaming: %w", err)
+	}
+	imageStore, err := newImageStore(cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to configure image storage: %w", err)
+	}
+	// Images kept on local disk are downloaded through the API itself.
+	localImages, _ := imageStore.(*storage.Local)
+	if cfg.Worker.InProcess {
+		exporter, err := newExporter(deps.db, repo, cfg.Warehouse, logger)
+		if err != nil {
+			return fmt.Errorf("failed to configure warehouse export: %w", err)
+		}
+		images, _ := imageStore.(imageDeleter)
+		background.Go("worker", newJobRunner(queue, repo, images, tenantStore, hooks, meter, exporter, relay, promMetrics, cfg.Worker, logger).Run)
+	}
+	scheduler, err := newScheduler(cfg, deps.redis, queue, meter, logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure cron: %w", err)
+	}
+	if scheduler != nil {
+		background.Go("cron", scheduler.Run)
+	}
+
+	monitor, err := newMonitor(cfg.Notifications, deps.redis, logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure notifications: %w", err)
+	}
+	processor := deps.processor
+	var publishers usecase.Publishers
+	if hooks != nil {
+		publishers = append(publishers, hooks)
+	}
+	if meter != nil {
+		publishers = append(publishers, meter)
+	}
+	if relay != nil {
+		publishers = append(publishers, relay)
+	}
+	if monitor != nil {
+		processor = monitor.ObserveProcessor(processor)
+		publishers = append(publishers, monitor)
+	}
+	resultStream := resultstream.New(deps.redis)
+	publishers = append(publishers, resultStream)
+
+	var cache usecase.Cache = usecase.NewRedisCache(deps.redis)
+	if len(cfg.Redis.CacheCluster.Addrs) > 0 && !*dev {
+		_, redisCredentials := store.connectCredentials()
+		cluster := openCacheCluster(cfg.Redis, redisCredentials)
+		plan.addCloser("redis-cache-cluster", cluster.Close)
+		if tracer != nil {
+			cluster.AddHook(tracer.RedisHook())
+		}
+		cache = usecase.NewRedisClusterCache(cluster)
+	}
+	if injector != nil {
+		cache = injector.Cache(cache)
+	}
+	uc := usecase.NewVerificationUseCaseWithOptions(repo, cache, processor, logger, verificationOptions(cfg))
+	uc.SetRegion(cfg.Region.Name)
+	uc.SetTenantPolicies(tenantStore)
+	recorder := livemetrics.NewRecorder()
+	uc.SetMetricsObserver(recorder)
+	if promMetrics != nil {
+		uc.SetInstrumentation(promMetrics)
+	}
+	var verificationLimit *adaptive.Limiter
+	if cfg.Limits.Adaptive.Enabled {
+		verificationLimit, err = adaptive.NewLimiter(adaptive.Settings{
+			InitialLimit: cfg.Limits.Adaptive.InitialLimit,
+			MinLimit:     cfg.Limits.Adaptive.MinLimit,
+			MaxLimit:     cfg.Limits.Adaptive.MaxLimit,
+			Tolerance:    cfg.Limits.Adaptive.Tolerance,
+			Backoff:      cfg.Limits.Adaptive.Backoff,
+		}, logger)
+		if err != nil {
+			return fmt.Errorf("failed to configure adaptive limit: %w", err)
+		}
+		uc.SetConcurrencyLimiter(verificationLimit)
+	}
+	liveMetrics := livemetrics.NewFeed(recorder, livemetrics.DefaultInterval)
+	// Stopping the feed closes the open streams, which the HTTP server cannot drain.
+	background.Go("live-metrics", liveMetrics.Run)
+	modelExperiment, err := newExperiment(ctx, cfg.Experiment, cfg.Processor, processor, *dev, monitor, promMetrics, tracer, plan, logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure experiment: %w", err)
+	}
+	if modelExperiment != nil {
+		uc.SetExperiment(modelExperiment)
+		logger.Info("running model experiment", zap.String("experiment", modelExperiment.Name()), zap.Int("variants", len(modelExperiment.Variants())))
+	}
+	if imageStore != nil {
+		uc.SetImageStore(imageStore)
+		logger.Info("keeping uploaded images", zap.String("provider", cfg.Storage.Provider), zap.String("bucket", cfg.Storage.Bucket))
+	}
+	if scanner := newMalwareScanner(cfg.MalwareScan); scanner != nil {
+		uc.SetMalwareScanner(scanner)
+		logger.Info("scanning uploads for malware", zap.String("provider", cfg.MalwareScan.Provider))
+	}
+	if pre := cfg.Preprocess; pre.Enabled {
+		pipeline, err := preprocess.New(preprocess.Options{
+			MaxWidth:      pre.MaxWidth,
+			MaxHeight:     pre.MaxHeight,
+			AutoRotate:    pre.AutoRotate,
+			StripMetadata: pre.StripMetadata,
+			Format:        pre.Format,
+			JPEGQuality:   pre.JPEGQuality,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to configure preprocessing: %w", err)
+		}
+		uc.SetPreprocessor(pipeline)
+		logger.Info("preprocessing images", zap.Int("max_width", pre.MaxWidth), zap.Int("max_height", pre.MaxHeight), zap.String("format", pre.Format))
+	}
+	if len(publishers) > 0 {
+		uc.SetEventPublisher(publishers)
+	}
+	if outboxCfg := cfg.Events.Outbox; outboxCfg.Enabled {
+		outboxRepo := repository.NewOutboxRepository(deps.db, logger)
+		uc.SetOutbox(outboxRepo)
+		opts := outbox.DefaultOptions()
+		opts.Interval = outboxCfg.PollInterval
+		opts.BatchSize = outboxCfg.BatchSize
+		opts.Retention = outboxCfg.Retention
+		outboxRelay := outbox.NewRelayWithOptions(outboxRepo, publishers, logger, opts)
+		// Events claimed when the relay stops are relayed again once their lease ends.
+		background.Go("outbox-relay", outboxRelay.Run)
+	}
+	if buffer := cfg.Verification.WriteBuffer; buffer.Enabled {
+		uc.SetLogBuffer(logbuffer.NewWithOptions(queue, repo, logger, logbuffer.Options{MaxAttempts: buffer.MaxAttempts}))
+	}
+	if queued := cfg.Verification.Deferred; queued.Enabled {
+		// Only API instances reach the processor, so each of them completes queued
+		// verifications, from a queue of their own.
+		deferredQueue := worker.NewQueueWithPrefix(deps.redis, deferred.QueuePrefix)
+		uc.SetDeferredQueue(deferred.NewWithOptions(deferredQueue, deferred.Options{MaxAttempts: queued.MaxAttempts}))
+		runner := worker.NewRunnerWithOptions(deferredQueue, logger.Named("deferred"), workerOptions(cfg.Worker))
+		runner.Handle(deferred.ProcessJob, deferred.Handler(uc))
+		background.Go("deferred-worker", runner.Run)
+	}
+
+	accounts := users.NewServiceWithOptions(repository.NewUserRepository(deps.db, logger), logger, users.Options{
+		TierQuotas:          cfg.Users.TierQuotas,
+		DefaultMonthlyQuota: cfg.Users.DefaultMonthlyQuota,
+	})
+	feedback := disputes.NewService(repository.NewDisputeRepository(deps.db, logger), logger)
+
+	credentials := auth.NewCredentials(cfg.Auth.JWTAudience, jwtSecrets(cfg.Auth)...)
+	credentials.SetRequireScopes(cfg.Auth.RequireScopes)
+	if cfg.Auth.JWKSURL != "" {
+		opts := auth.DefaultKeySetOptions()
+		opts.Issuer = cfg.Auth.JWTIssuer
+		opts.RefreshInterval = cfg.Auth.JWKSRefreshInterval
+		keys := auth.NewKeySetWithOptions(cfg.Auth.JWKSURL, opts)
+		// Keys that cannot be fetched yet are fetched again by the first tokens.
+		if err := keys.Refresh(ctx); err != nil {
+			logger.Warn("failed to fetch the JWKS", zap.String("url", cfg.Auth.JWKSURL), zap.Error(err))
+		}
+		credentials.SetKeySet(keys)
+	}
+	var tokenIssuer *auth.Issuer
+	if tokens := cfg.Auth.Tokens; tokens.Enabled {
+		revocations := auth.NewRevocations(deps.redis)
+		credentials.SetRevocations(revocations)
+		tokenIssuer = auth.NewIssuer(credentials, revocations, auth.IssuerOptions{
+			AccessTTL:  tokens.AccessTTL,
+			RefreshTTL: tokens.RefreshTTL,
+		}, tokenClients(tokens))
+	}
+	authMiddleware := auth.JWTMiddlewareWithCredentials(credentials)
+	rateLimiter := newRateLimiter(cfg.Limits.Rate, deps.redis, logger)
+	concurrencyLimiter := middleware.NewConcurrencyLimiter(cfg.Limits.MaxInFlight, cfg.Limits.Routes, cfg.Limits.RetryAfter)
+
+	reloader := newConfigReloader(*configPath, cfg, logger, func(next *config.Config) {
+		applyLogLevel(next.Log)
+		credentials.Update(next.Auth.JWTAudience, jwtSecrets(next.Auth)...)
+		credentials.SetRequireScopes(next.Auth.RequireScopes)
+		if tokenIssuer != nil {
+			tokenIssuer.SetClients(tokenClients(next.Auth.Tokens))
+		}
+		uc.UpdateOptions(verificationOptions(next))
+		reloadLimits(next.Limits, rateLimiter, concurrencyLimiter)
+	})
+	if store != nil {
+		// Rotated secrets reach the JWT credentials through a regular reload; Postgres
+		// and Redis read them directly when opening new connections.
+		reloader.prepare = store.apply
+		background.Go("secrets", func(ctx context.Context) {
+			store.run(ctx, cfg.Secrets.RefreshInterval, reloader.requestReload)
+		})
+	}
+	background.Go("config-reloader", func(ctx context.Context) {
+		reloader.run(ctx, cfg.Reload.WatchInterval)
+	})
+	plan.addBackground(background)
+
+	readiness := deps.readiness(cfg.Health)
+	imageLimits := &imagelimits.Limits{
+		MaxWidth:       cfg.HTTP.Images.MaxWidth,
+		MaxHeight:      cfg.HTTP.Images.MaxHeight,
+		MaxPixels:      cfg.HTTP.Images.MaxPixels,
+		MinWidth:       cfg.HTTP.Images.MinWidth,
+		MinHeight:      cfg.HTTP.Images.MinHeight,
+		MaxAspectRatio: cfg.HTTP.Images.MaxAspectRatio,
+	}
+	var imageFetcher *imagefetch.Fetcher
+	if cfg.HTTP.ImageURLs.Enabled {
+		imageFetcher = imagefetch.New(imagefetch.Options{
+			Timeout:              cfg.HTTP.ImageURLs.Timeout,
+			MaxSize:              cfg.HTTP.MaxUploadSize,
+			AllowHTTP:            cfg.HTTP.ImageURLs.AllowHTTP,
+			AllowPrivateNetworks: cfg.HTTP.ImageURLs.AllowPrivateNetworks,
+		})
+	}
+	apiHandler := handlers.NewHandler(uc, authMiddleware, handlers.Options{
+		MaxUploadSize:  cfg.HTTP.MaxUploadSize,
+		SpoolThreshold: cfg.HTTP.Spool.Threshold,
+		Metrics:        promMetrics,
+		Tracer:         tracer,
+		Readiness:      readiness,
+		Webhooks:       hooks,
+		Usage:          meter,
+		Users:          accounts,
+		Disputes:       feedback,
+		LiveMetrics:    liveMetrics,
+		ResultStream:   resultStream,
+		Tenants:        tenantStore,
+		ImageFetcher:   imageFetcher,
+		ImageLimits:    imageLimits,
+		RetryAfter:     cfg.Limits.RetryAfter,
+		RateLimiter:    rateLimiter,
+		TokenIssuer:    tokenIssuer,
+		AdminRole:      cfg.Auth.AdminRole,
+		LocalImages:    localImages,
+		Docs:           cfg.HTTP.Docs,
+		Logger:         logger.Named("http"),
+		ClientIP: middleware.ClientIPConfig{
+			TrustedProxies: cfg.HTTP.Proxy.TrustedProxies,
+			Headers:        cfg.HTTP.Proxy.ClientIPHeaders,
+			Platform:       cfg.HTTP.Proxy.TrustedPlatform,
+		},
+		Middleware: []gin.HandlerFunc{
+			concurrencyLimiter.Middleware(),
+		},
+	})
+
+	if cfg.Admin.Addr != "" {
+		adminServer := startAdminServer(cfg.Admin.Addr, newAdminRouter(cfg.Admin, adminServices{
+			verifications: uc,
+			readiness:     readiness,
+			liveMetrics:   liveMetrics,
+			metrics:       promMetrics,
+			meter:         meter,
+			users:         accounts,
+			disputes:      feedback,
+			tenants:       tenantStore,
+			webhooks:      hooks,
+			scheduler:     scheduler,
+			queue:         queue,
+			databasePool:  poolTuner,
+			adaptiveLimit: verificationLimit,
+		}), logger)
+		plan.add("admin-http", adminServer.Shutdown)
+	}
+
+	server := &http.Server{
+		Addr:    cfg.HTTP.Addr,
+		Handler: apiHandler,
+	}
+	// Result streams wait for verifications to leave the queue, so end them for
+	// shutdown to drain the server.
+	server.RegisterOnShutdown(resultStream.Close)
+
+	var certManager *autocert.Manager
+	if cfg.HTTP.TLS.Enabled() {
+		server.TLSConfig, certManager, err = buildTLSConfig(cfg.HTTP.TLS)
+		if err != nil {
+			return fmt.Errorf("failed to configure TLS: %w", err)
+		}
+	}
+	if err := configureHTTP2(server, cfg.HTTP.HTTP2); err != nil {
+		return fmt.Errorf("failed to configure HTTP/2: %w", err)
+	}
+	if cfg.GRPC.Addr != "" {
+		if err := startGRPCServer(plan, cfg.GRPC.Addr, server.TLSConfig, uc, credentials, accounts, tenantStore, cfg.HTTP.MaxUploadSize, imageLimits, logger); err != nil {
+			return err
+		}
+	}
+
+	if cfg.HTTP.UnixSocket.Path != "" {
+		unixListener, err := listenUnixSocket(cfg.HTTP.UnixSocket)
+		if err != nil {
+			return fmt.Errorf("failed to listen on unix socket %s: %w", cfg.HTTP.UnixSocket.Path, err)
+		}
+		// Shutting down the server closes this listener too; the stage only matters
+		// when the TCP listener fails before a graceful shutdown.
+		plan.addCloser("unix-socket", func() error {
+			if err := unixListener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			return nil
+		})
+		go func() {
+			if err := server.Serve(unixListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("unix socket listener failed", zap.Error(err), zap.String("path", cfg.HTTP.UnixSocket.Path))
+			}
+		}()
+		logger.Info("Golang API listening on unix socket", zap.String("path", cfg.HTTP.UnixSocket.Path))
+	}
+
+	// On SIGTERM, /health/ready starts failing immediately while requests keep being served
+	// for the drain delay; only then does the graceful shutdown begin.
+	termSignals := make(chan os.Signal, 1)
+	signal.Notify(termSignals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(termSignals)
+	signals := drainOnSignal(termSignals, cfg.Shutdown.DrainDelay, func() { readiness.SetDraining(true) }, logger)
+
+	listener, err := listenTCP(cfg.HTTP.Addr, cfg.HTTP.ReusePort)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", cfg.HTTP.Addr, err)
+	}
+
+	if server.TLSConfig == nil {
+		logger.Info("Golang API listening", zap.String("addr", cfg.HTTP.Addr), zap.Bool("h2c", cfg.HTTP.HTTP2.H2C), zap.Bool("reuse_port", cfg.HTTP.ReusePort))
+		return serveHTTPServerWithOptions(server, cfg.HTTP.ShutdownTimeout, logger, listener, signals)
+	}
+
+	if cfg.HTTP.TLS.RedirectAddr != "" {
+		redirectServer := newRedirectServer(cfg.HTTP.TLS.RedirectAddr, cfg.HTTP.Addr, certManager)
+		go func() {
+			if err := redirectServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("redirect server failed", zap.Error(err), zap.String("addr", redirectServer.Addr))
+			}
+		}()
+		plan.add("https-redirect", redirectServer.Shutdown)
+		logger.Info("HTTP to HTTPS redirect listening", zap.String("addr", redirectServer.Addr))
+	}
+
+	logger.Info("Golang API listening with TLS", zap.String("addr", cfg.HTTP.Addr), zap.Bool("autocert", certManager != nil))
+	return serveHTTPServerWithOptions(server, cfg.HTTP.ShutdownTimeout, logger, tls.NewListener(listener, server.TLSConfig), signals)
+}
+
+func verificationOptions(cfg *config.Config) usecase.Options {
+	return usecase.Options{
+		RetryAttempts:          cfg.Verification.RetryAttempts,
+		InitialBackoff:         cfg.Verification.InitialBackoff,
+		MaxBackoff:             cfg.Verification.MaxBackoff,
+		RetryJitter:            cfg.Verification.RetryJitter,
+		RetryBudget:            cfg.Verification.RetryBudget,
+		ProcessingTTL:          cfg.Verification.ProcessingTTL,
+		ResultTTL:              cfg.Verification.ResultTTL,
+		NotFoundTTL:            cfg.Verification.NotFoundTTL,
+		MetricsSummaryTTL:      cfg.Verification.MetricsSummaryTTL,
+		MetricsSummaryStaleTTL: cfg.Verification.MetricsSummaryStaleTTL,
+		ImageURLTTL:            cfg.Storage.SignedURLTTL,
+		DetachedTimeout:        cfg.Verification.DetachedTimeout,
+		RequestIDFormat:        cfg.Verification.RequestIDFormat,
+		ReviewThreshold:        float32(cfg.Verification.ReviewThreshold),
+		RejectThreshold:        float32(cfg.Verification.RejectThreshold),
+		MaxDuplicates:          cfg.Verification.MaxDuplicates,
+		DuplicatePrecheck:      cfg.Verification.DuplicatePrecheck,
+		SpoolThreshold:         cfg.HTTP.Spool.Threshold,
+		SpoolDir:               cfg.HTTP.Spool.Dir,
+		CategoryThresholds: map[string]float32{
+			imageprocessor.CategoryAIGenerated: float32(cfg.Verification.CategoryThresholds.AIGenerated),
+			imageprocessor.CategoryManipulated: float32(cfg.Verification.CategoryThresholds.Manipulated),
+			imageprocessor.CategoryNSFW:        float32(cfg.Verification.CategoryThresholds.NSFW),
+			imageprocessor.CategoryWatermarked: float32(cfg.Verification.CategoryThresholds.Watermarked),
+		},
+		Calibration: calibrationCurves(cfg.Verification.Calibration),
+	}
+}
+
+func calibrationCurves(versions map[string][]config.CalibrationPoint) calibration.Curves {
+	curves := make(calibration.Curves, len(versions))
+	for version, points := range versions {
+		curve := make(calibration.Curve, 0, len(points))
+		for _, point := range points {
+			curve = append(curve, calibration.Point{Raw: float32(point.Raw), Calibrated: float32(point.Calibrated)})
+		}
+		curves[version] = curve
+	}
+	return curves
+}
+
+func jwtSecrets(cfg config.AuthConfig) []string {
+	return append([]string{cfg.JWTSecret}, cfg.JWTPreviousSecrets...)
+}
+
+// tokenClients lists the clients the token issuer serves.
+func tokenClients(cfg config.TokensConfig) []auth.Client {
+	clients := make([]auth.Client, 0, len(cfg.Clients))
+	for _, client := range cfg.Clients {
+		clients = append(clients, auth.Client{
+			ID:         client.ID,
+			SecretHash: client.SecretHash,
+			Subject:    client.Subject,
+			TenantID:   client.Tenant,
+			Roles:      client.Roles,
+			Scopes:     client.Scopes,
+		})
+	}
+	return clients
+}
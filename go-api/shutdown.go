@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/example/ai-check/internal/lifecycle"
+)
+
+// shutdownStage is a single step of the shutdown sequence.
+type shutdownStage struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// shutdownPlan closes dependencies in the reverse order they were registered, so
+// components are torn down before the resources they depend on.
+type shutdownPlan struct {
+	logger       *zap.Logger
+	stageTimeout time.Duration
+	stages       []shutdownStage
+}
+
+func newShutdownPlan(logger *zap.Logger, stageTimeout time.Duration) *shutdownPlan {
+	return &shutdownPlan{logger: logger.Named("shutdown"), stageTimeout: stageTimeout}
+}
+
+// add registers a stage using the plan's default timeout.
+func (p *shutdownPlan) add(name string, run func(ctx context.Context) error) {
+	p.addWithTimeout(name, p.stageTimeout, run)
+}
+
+// addWithTimeout registers a stage with an explicit timeout.
+func (p *shutdownPlan) addWithTimeout(name string, timeout time.Duration, run func(ctx context.Context) error) {
+	p.stages = append(p.stages, shutdownStage{name: name, timeout: timeout, run: run})
+}
+
+// addCloser registers a stage for a dependency that only exposes Close.
+func (p *shutdownPlan) addCloser(name string, closeFn func() error) {
+	p.add(name, func(context.Context) error { return closeFn() })
+}
+
+// addDatabase registers closing the connection pool behind a gorm handle.
+func (p *shutdownPlan) addDatabase(db *gorm.DB) {
+	p.addCloser("postgres", func() error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
+}
+
+// addBackground registers draining the background components of manager, allowing
+// the longest of their timeouts. Register it once the components are started.
+func (p *shutdownPlan) addBackground(manager *lifecycle.Manager) {
+	p.addWithTimeout("background", max(manager.Timeout(), p.stageTimeout), manager.Stop)
+}
+
+// run executes every stage, newest first. A stage that fails or exceeds its timeout is
+// logged and does not prevent later stages from running.
+func (p *shutdownPlan) run() error {
+	var errs []error
+	for i := len(p.stages) - 1; i >= 0; i-- {
+		stage := p.stages[i]
+		started := time.Now()
+		if err := runStage(stage); err != nil {
+			p.logger.Error("shutdown stage failed", zap.String("stage", stage.name), zap.Error(err), zap.Duration("elapsed", time.Since(started)))
+			errs = append(errs, fmt.Errorf("%s: %w", stage.name, err))
+			continue
+		}
+		p.logger.Info("shutdown stage completed", zap.String("stage", stage.name), zap.Duration("elapsed", time.Since(started)))
+	}
+	p.stages = nil
+	return errors.Join(errs...)
+}
+
+func runStage(stage shutdownStage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), stage.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stage.run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", stage.timeout)
+	}
+}
+
+// drainOnSignal forwards the first termination signal only after delay has passed,
+// calling onDrain as soon as it arrives. This gives load balancers time to notice a
+// failing readiness probe while the listener keeps serving. A second signal skips the
+// rest of the delay.
+func drainOnSignal(signals <-chan os.Signal, delay time.Duration, onDrain func(), logger *zap.Logger) <-chan os.Signal {
+	forward := make(chan os.Signal, 1)
+	go func() {
+		sig, ok := <-signals
+		if !ok {
+			close(forward)
+			return
+		}
+		if delay > 0 {
+			onDrain()
+			logger.Info("draining before shutdown", zap.String("signal", sig.String()), zap.Duration("delay", delay))
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-signals:
+				timer.Stop()
+				logger.Warn("received second signal, skipping remaining drain delay")
+			}
+		}
+		forward <- sig
+	}()
+	return forward
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestShutdownPlanRunsStagesInReverseOrder(t *testing.T) {
+	plan := newShutdownPlan(zap.NewNop(), time.Second)
+
+	var order []string
+	for _, name := range []string{"postgres", "redis", "grpc"} {
+		name := name
+		plan.addCloser(name, func() error {
+			order = append(order, name)
+			return nil
+		})
+	}
+
+	if err := plan.run(); err != nil {
+		t.Fatalf("expected clean shutdown, got %v", err)
+	}
+	if expected := []string{"grpc", "redis", "postgres"}; !reflect.DeepEqual(order, expected) {
+		t.Fatalf("expected order %v, got %v", expected, order)
+	}
+}
+
+func TestShutdownPlanContinuesAfterFailedOrSlowStage(t *testing.T) {
+	plan := newShutdownPlan(zap.NewNop(), time.Second)
+
+	closed := false
+	plan.addCloser("postgres", func() error {
+		closed = true
+		return nil
+	})
+	plan.addWithTimeout("stuck", 20*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	plan.addCloser("grpc", func() error { return errors.New("boom") })
+
+	err := plan.run()
+	if err == nil {
+		t.Fatal("expected shutdown error, got nil")
+	}
+	for _, fragment := range []string{"grpc: boom", "stuck: timed out"} {
+		if !strings.Contains(err.Error(), fragment) {
+			t.Fatalf("expected error to mention %q, got %v", fragment, err)
+		}
+	}
+	if !closed {
+		t.Fatal("expected later stages to run after failures")
+	}
+}
+
+func TestDrainOnSignalDelaysForwardingAndMarksDraining(t *testing.T) {
+	signals := make(chan os.Signal, 1)
+	var drained atomic.Bool
+	forwarded := drainOnSignal(signals, 50*time.Millisecond, func() { drained.Store(true) }, zap.NewNop())
+
+	started := time.Now()
+	signals <- syscall.SIGTERM
+	sig := <-forwarded
+	if sig != syscall.SIGTERM {
+		t.Fatalf("expected SIGTERM to be forwarded, got %v", sig)
+	}
+	if elapsed := time.Since(started); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected the signal to be held for the drain delay, forwarded after %s", elapsed)
+	}
+	if !drained.Load() {
+		t.Fatal("expected onDrain to be called")
+	}
+}
+
+func TestDrainOnSignalSecondSignalSkipsDelay(t *testing.T) {
+	signals := make(chan os.Signal, 2)
+	forwarded := drainOnSignal(signals, time.Hour, func() {}, zap.NewNop())
+
+	signals <- syscall.SIGTERM
+	signals <- syscall.SIGINT
+	select {
+	case <-forwarded:
+	case <-time.After(time.Second):
+		t.Fatal("expected a second signal to end the drain delay")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/config"
+)
+
+// waitForDependency calls connect until it succeeds, backing off exponentially between
+// attempts. When every attempt fails and degraded startup is enabled, the failure is
+// logged and nil is returned so the caller can continue without the dependency.
+func waitForDependency(ctx context.Context, cfg config.StartupConfig, logger *zap.Logger, name string, connect func(ctx context.Context) error) error {
+	backoff := cfg.InitialBackoff
+	var err error
+	for attempt := 1; attempt <= cfg.Attempts; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, cfg.AttemptTimeout)
+		err = connect(attemptCtx)
+		cancel()
+		if err == nil {
+			if attempt > 1 {
+				logger.Info("dependency became available", zap.String("dependency", name), zap.Int("attempt", attempt))
+			}
+			return nil
+		}
+		if attempt == cfg.Attempts {
+			break
+		}
+
+		logger.Warn("dependency unavailable, retrying",
+			zap.String("dependency", name),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", name, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > cfg.MaxBackoff {
+			backoff = cfg.MaxBackoff
+		}
+	}
+
+	if cfg.Degraded {
+		logger.Error("dependency unavailable, starting degraded", zap.String("dependency", name), zap.Error(err))
+		return nil
+	}
+	return fmt.Errorf("%s unavailable after %d attempts: %w", name, cfg.Attempts, err)
+}
+
+// requireDependencies disables degraded startup for commands that cannot do any useful
+// work without their dependencies.
+func requireDependencies(cfg config.StartupConfig) config.StartupConfig {
+	cfg.Degraded = false
+	return cfg
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/example/ai-check/internal/config"
+)
+
+func testStartupConfig(attempts int, degraded bool) config.StartupConfig {
+	return config.StartupConfig{
+		Attempts:       attempts,
+		AttemptTimeout: time.Second,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+		Degraded:       degraded,
+	}
+}
+
+func TestWaitForDependencyRetriesUntilAvailable(t *testing.T) {
+	calls := 0
+	err := waitForDependency(context.Background(), testStartupConfig(5, false), zap.NewNop(), "postgres", func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected dependency to become available, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestWaitForDependencyFailsAfterAttempts(t *testing.T) {
+	calls := 0
+	err := waitForDependency(context.Background(), testStartupConfig(3, false), zap.NewNop(), "redis", func(context.Context) error {
+		calls++
+		return errors.New("connection refused")
+	})
+	if err == nil {
+		t.Fatal("expected an error once attempts are exhausted")
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestWaitForDependencyStartsDegraded(t *testing.T) {
+	err := waitForDependency(context.Background(), testStartupConfig(2, true), zap.NewNop(), "processor", func(context.Context) error {
+		return errors.New("connection refused")
+	})
+	if err != nil {
+		t.Fatalf("expected degraded startup to continue, got %v", err)
+	}
+	if requireDependencies(testStartupConfig(2, true)).Degraded {
+		t.Fatal("expected requireDependencies to disable degraded startup")
+	}
+}
+
+func TestWaitForDependencyStopsOnCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := testStartupConfig(10, true)
+	cfg.InitialBackoff, cfg.MaxBackoff = time.Hour, time.Hour
+
+	err := waitForDependency(ctx, cfg, zap.NewNop(), "postgres", func(context.Context) error {
+		cancel()
+		return errors.New("connection refused")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation to abort startup even in degraded mode, got %v", err)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import "io/fs"
+
+// withFileMode runs create. Without a umask, the files it creates get the platform's
+// default permissions.
+func withFileMode(_ fs.FileMode, create func() error) error {
+	return create()
+}
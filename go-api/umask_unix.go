@@ -0,0 +1,23 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"io/fs"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// umaskMu serializes withFileMode, as the umask belongs to the whole process.
+var umaskMu sync.Mutex
+
+// withFileMode runs create with the umask set so that the files it creates get mode,
+// so they never exist with wider permissions, and restores the umask afterwards.
+func withFileMode(mode fs.FileMode, create func() error) error {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+	old := unix.Umask(int(fs.ModePerm &^ mode.Perm()))
+	defer unix.Umask(old)
+	return create()
+}
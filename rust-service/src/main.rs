@@ -1,43 +1,55 @@
 use std::net::SocketAddr;
 
-use tonic::{transport::Server, Request, Response, Status};
+use tonic::{transport::Server, Request, Response, Status, Streaming};
 use tracing::{error, info};
 
 use rust_service::{image, triton_client::TritonClient, verify};
 
 use verify::image_processor_server::{ImageProcessor, ImageProcessorServer};
-use verify::{VerifyRequest, VerifyResponse};
+use verify::{CategoryScore, VerifyChunk, VerifyRequest, VerifyResponse};
+
+/// Largest image accepted over `ProcessImageStream`, bounding the memory a single
+/// stream can claim.
+const MAX_STREAMED_IMAGE_BYTES: usize = 64 << 20;
 
 struct ImageProcessorService {
     triton: TritonClient,
+    /// Moderation categories reported by the model after the verification score, in
+    /// output order.
+    category_labels: Vec<String>,
 }
 
-#[tonic::async_trait]
-impl ImageProcessor for ImageProcessorService {
-    async fn process_image(
-        &self,
-        request: Request<VerifyRequest>,
-    ) -> Result<Response<VerifyResponse>, Status> {
-        let request = request.into_inner();
-        if request.image_data.is_empty() {
+impl ImageProcessorService {
+    async fn verify(&self, user_id: &str, image_data: &[u8]) -> Result<VerifyResponse, Status> {
+        if image_data.is_empty() {
             return Err(Status::invalid_argument("image data cannot be empty"));
         }
-        if request.user_id.is_empty() {
+        if user_id.is_empty() {
             return Err(Status::invalid_argument("user_id is required"));
         }
 
-        let tensor = image::preprocess(&request.image_data)
+        let tensor = image::preprocess(image_data)
             .map_err(|err| Status::internal(format!("image preprocessing failed: {err}")))?;
 
-        let scores = self
+        let inference = self
             .triton
             .infer(&tensor)
             .await
             .map_err(|err| Status::internal(format!("triton inference failed: {err}")))?;
+        let scores = inference.scores;
 
         let score = scores.first().copied().unwrap_or_default();
         let success = score >= 0.5;
-        let response = VerifyResponse {
+        let categories = self
+            .category_labels
+            .iter()
+            .zip(scores.iter().skip(1))
+            .map(|(category, score)| CategoryScore {
+                category: category.clone(),
+                score: *score,
+            })
+            .collect();
+        Ok(VerifyResponse {
             success,
             score,
             message: if success {
@@ -45,8 +57,40 @@ impl ImageProcessor for ImageProcessorService {
             } else {
                 "Verification failed".to_string()
             },
-        };
+            categories,
+            model_version: inference.model_version,
+        })
+    }
+}
 
+#[tonic::async_trait]
+impl ImageProcessor for ImageProcessorService {
+    async fn process_image(
+        &self,
+        request: Request<VerifyRequest>,
+    ) -> Result<Response<VerifyResponse>, Status> {
+        let request = request.into_inner();
+        let response = self.verify(&request.user_id, &request.image_data).await?;
+        Ok(Response::new(response))
+    }
+
+    async fn process_image_stream(
+        &self,
+        request: Request<Streaming<VerifyChunk>>,
+    ) -> Result<Response<VerifyResponse>, Status> {
+        let mut stream = request.into_inner();
+        let mut user_id = String::new();
+        let mut image_data = Vec::new();
+        while let Some(chunk) = stream.message().await? {
+            if user_id.is_empty() {
+                user_id = chunk.user_id;
+            }
+            if image_data.len() + chunk.data.len() > MAX_STREAMED_IMAGE_BYTES {
+                return Err(Status::invalid_argument("image data is too large"));
+            }
+            image_data.extend_from_slice(&chunk.data);
+        }
+        let response = self.verify(&user_id, &image_data).await?;
         Ok(Response::new(response))
     }
 }
@@ -70,6 +114,15 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
         .map(|value| matches!(value.as_str(), "1" | "true" | "TRUE" | "True"))
         .unwrap_or(false);
     let triton_ca_cert = std::env::var("TRITON_CA_CERT_PATH").ok();
+    let category_labels = std::env::var("TRITON_CATEGORY_LABELS")
+        .map(|value| {
+            value
+                .split(',')
+                .map(|label| label.trim().to_string())
+                .filter(|label| !label.is_empty())
+                .collect()
+        })
+        .unwrap_or_default();
 
     let service = ImageProcessorService {
         triton: TritonClient::new(
@@ -80,6 +133,7 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
             triton_use_tls,
             triton_ca_cert,
         ),
+        category_labels,
     };
 
     info!(%addr, "Starting Rust image processor");
@@ -26,6 +26,14 @@ pub enum TritonError {
     Configuration(String),
 }
 
+/// Scores returned by the model, with the version of the model that produced them.
+#[derive(Debug, Clone, PartialEq)]
+pub struct Inference {
+    pub scores: Vec<f32>,
+    /// Empty when Triton does not report the version.
+    pub model_version: String,
+}
+
 #[derive(Clone)]
 pub struct TritonClient {
     endpoint: String,
@@ -58,7 +66,7 @@ impl TritonClient {
         }
     }
 
-    pub async fn infer(&self, tensor: &ImageTensor) -> Result<Vec<f32>, TritonError> {
+    pub async fn infer(&self, tensor: &ImageTensor) -> Result<Inference, TritonError> {
         if tensor.data.is_empty() {
             return Err(TritonError::InvalidResponse(
                 "tensor data cannot be empty".into(),
@@ -93,7 +101,12 @@ impl TritonClient {
             .map_err(|err| TritonError::Transport(err.to_string()))?
             .into_inner();
 
-        self.extract_scores(response)
+        let model_version = response.model_version.clone();
+        let scores = self.extract_scores(response)?;
+        Ok(Inference {
+            scores,
+            model_version,
+        })
     }
 
     fn build_input_tensor(&self, tensor: &ImageTensor) -> InferInputTensor {
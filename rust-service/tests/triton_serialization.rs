@@ -58,8 +58,9 @@ async fn infer_request_serializes_expected_tensor() {
         data: vec![0.1, 0.2, 0.3, 0.4, 0.5, 0.6],
     };
 
-    let scores = client.infer(&tensor).await.unwrap();
-    assert_eq!(scores, vec![0.25, 0.75]);
+    let inference = client.infer(&tensor).await.unwrap();
+    assert_eq!(inference.scores, vec![0.25, 0.75]);
+    assert_eq!(inference.model_version, "3");
 
     shutdown_tx.send(()).unwrap();
     server.await.unwrap();
@@ -170,6 +171,7 @@ impl GrpcInferenceService for MockTriton {
 
         let response = ModelInferResponse {
             model_name: self.model_name.clone(),
+            model_version: "3".to_string(),
             outputs: vec![response_tensor],
             raw_output_contents: Vec::new(),
             ..Default::default()